@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +16,8 @@ import (
 	"github.com/mark3labs/mcp-go/util"
 	"github.com/zerodha/kite-mcp-server/app/metrics"
 	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/httpfixture"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
 	"github.com/zerodha/kite-mcp-server/kc/templates"
 	"github.com/zerodha/kite-mcp-server/mcp"
 )
@@ -28,6 +31,13 @@ type App struct {
 	statusTemplate *template.Template
 	logger         *slog.Logger
 	metrics        *metrics.Manager
+	kiteHTTPClient *http.Client // set when Kite API calls use HTTP fixtures
+
+	settings    *ConfigFile    // loaded from Config.ConfigFile, nil without one
+	envConfig   Config         // the configuration before the file was applied
+	logLevel    *slog.LevelVar // changed by the log level of the file
+	envLogLevel slog.Level     // the level without the file setting one
+	rateLimiter *mcp.RateLimiter
 }
 
 // StatusPageData holds template data for the status page
@@ -39,6 +49,8 @@ type StatusPageData struct {
 
 // Config holds the application configuration
 type Config struct {
+	ConfigFile string // YAML file overriding the settings below, reloaded on SIGHUP or change
+
 	KiteAPIKey    string
 	KiteAPISecret string
 	AppMode       string
@@ -47,6 +59,19 @@ type Config struct {
 
 	ExcludedTools   string
 	AdminSecretPath string
+	TradebookDir    string
+
+	ScheduledOrdersFile  string
+	MarketHolidaysFile   string
+	InstrumentsSnapshot  string
+	CorporateActionsFile string
+
+	InstrumentsSource    string // URL or path, the Kite JSON dump if empty
+	InstrumentsFormat    string // json or csv, detected if empty
+	InstrumentsExchanges string // comma separated, to load per exchange dumps
+
+	HTTPFixturesMode string // record or replay Kite API calls, off if empty
+	HTTPFixturesFile string
 }
 
 // Server mode constants
@@ -66,6 +91,8 @@ const (
 func NewApp(logger *slog.Logger) *App {
 	return &App{
 		Config: &Config{
+			ConfigFile: os.Getenv("CONFIG_FILE"),
+
 			KiteAPIKey:    os.Getenv("KITE_API_KEY"),
 			KiteAPISecret: os.Getenv("KITE_API_SECRET"),
 			AppMode:       os.Getenv("APP_MODE"),
@@ -74,10 +101,24 @@ func NewApp(logger *slog.Logger) *App {
 
 			ExcludedTools:   os.Getenv("EXCLUDED_TOOLS"),
 			AdminSecretPath: os.Getenv("ADMIN_ENDPOINT_SECRET_PATH"),
+			TradebookDir:    os.Getenv("TRADEBOOK_DIR"),
+
+			ScheduledOrdersFile:  os.Getenv("SCHEDULED_ORDERS_FILE"),
+			MarketHolidaysFile:   os.Getenv("MARKET_HOLIDAYS_FILE"),
+			InstrumentsSnapshot:  os.Getenv("INSTRUMENTS_SNAPSHOT_FILE"),
+			CorporateActionsFile: os.Getenv("CORPORATE_ACTIONS_FILE"),
+
+			InstrumentsSource:    os.Getenv("INSTRUMENTS_SOURCE"),
+			InstrumentsFormat:    os.Getenv("INSTRUMENTS_FORMAT"),
+			InstrumentsExchanges: os.Getenv("INSTRUMENTS_EXCHANGES"),
+
+			HTTPFixturesMode: os.Getenv("HTTP_FIXTURES_MODE"),
+			HTTPFixturesFile: os.Getenv("HTTP_FIXTURES_FILE"),
 		},
-		Version:   "v0.0.0", // Ideally injected at build time
-		startTime: time.Now(),
-		logger:    logger,
+		Version:     "v0.0.0", // Ideally injected at build time
+		startTime:   time.Now(),
+		logger:      logger,
+		rateLimiter: mcp.NewRateLimiter(),
 		metrics: metrics.New(metrics.Config{
 			ServiceName:     "kite-mcp-server",
 			AdminSecretPath: os.Getenv("ADMIN_ENDPOINT_SECRET_PATH"),
@@ -93,30 +134,57 @@ func (app *App) SetVersion(version string) {
 
 // LoadConfig loads and validates the application configuration
 func (app *App) LoadConfig() error {
-	if app.Config.AppMode == "" {
-		app.Config.AppMode = DefaultAppMode
-	}
-
-	if app.Config.AppPort == "" {
-		app.Config.AppPort = DefaultPort
+	if app.Config.ConfigFile != "" {
+		if err := app.applyConfigFile(); err != nil {
+			return err
+		}
 	}
 
-	if app.Config.AppHost == "" {
-		app.Config.AppHost = DefaultHost
-	}
+	app.Config.setDefaults()
 
 	// Check if API KEY or SECRET is missing
 	if app.Config.KiteAPIKey == "" || app.Config.KiteAPISecret == "" {
 		return fmt.Errorf("KITE_API_KEY or KITE_API_SECRET is missing")
 	}
 
+	switch app.Config.HTTPFixturesMode {
+	case "":
+	case httpfixture.ModeRecord, httpfixture.ModeReplay:
+		if app.Config.HTTPFixturesFile == "" {
+			return fmt.Errorf("HTTP_FIXTURES_FILE is required with HTTP_FIXTURES_MODE")
+		}
+	default:
+		return fmt.Errorf("HTTP_FIXTURES_MODE must be %s or %s", httpfixture.ModeRecord, httpfixture.ModeReplay)
+	}
+
+	if err := app.instrumentsSource().Validate(); err != nil {
+		return fmt.Errorf("invalid INSTRUMENTS_SOURCE: %w", err)
+	}
+
 	return nil
 }
 
+// setDefaults sets the defaults of the settings left empty
+func (c *Config) setDefaults() {
+	if c.AppMode == "" {
+		c.AppMode = DefaultAppMode
+	}
+
+	if c.AppPort == "" {
+		c.AppPort = DefaultPort
+	}
+
+	if c.AppHost == "" {
+		c.AppHost = DefaultHost
+	}
+}
+
 // RunServer initializes and starts the server based on the configured mode
 func (app *App) RunServer() error {
 	url := app.buildServerURL()
-	app.configureHTTPClient()
+	if err := app.configureHTTPClient(); err != nil {
+		return err
+	}
 
 	kcManager, mcpServer, err := app.initializeServices()
 	if err != nil {
@@ -124,7 +192,10 @@ func (app *App) RunServer() error {
 	}
 
 	srv := app.createHTTPServer(url)
-	app.setupGracefulShutdown(srv, kcManager)
+	ctx := app.setupGracefulShutdown(srv, kcManager)
+	if app.Config.ConfigFile != "" {
+		app.watchConfigFile(ctx, kcManager, mcpServer, configPollInterval)
+	}
 
 	return app.startServer(srv, kcManager, mcpServer, url)
 }
@@ -134,20 +205,56 @@ func (app *App) buildServerURL() string {
 	return app.Config.AppHost + ":" + app.Config.AppPort
 }
 
-// configureHTTPClient sets up the default HTTP client with timeout
-func (app *App) configureHTTPClient() {
+// instrumentsSource returns where instruments are loaded from.
+func (app *App) instrumentsSource() instruments.Source {
+	source := instruments.Source{
+		URL:    app.Config.InstrumentsSource,
+		Format: strings.ToLower(app.Config.InstrumentsFormat),
+	}
+	for _, exchange := range strings.Split(app.Config.InstrumentsExchanges, ",") {
+		if exchange = strings.TrimSpace(exchange); exchange != "" {
+			source.Exchanges = append(source.Exchanges, exchange)
+		}
+	}
+	return source
+}
+
+// configureHTTPClient sets up the default HTTP client with timeout. With
+// HTTP fixtures enabled, it records or replays the Kite API calls, which
+// then go through this client.
+func (app *App) configureHTTPClient() error {
 	http.DefaultClient.Timeout = 30 * time.Second
 	app.logger.Debug("HTTP client timeout set to 30 seconds")
+
+	if app.Config.HTTPFixturesMode == "" {
+		return nil
+	}
+	transport, err := httpfixture.Transport(app.Config.HTTPFixturesMode, app.Config.HTTPFixturesFile, http.DefaultTransport)
+	if err != nil {
+		return fmt.Errorf("failed to set up HTTP fixtures: %w", err)
+	}
+	http.DefaultClient.Transport = transport
+	app.kiteHTTPClient = http.DefaultClient
+	app.logger.Warn("Kite API calls use HTTP fixtures", "mode", app.Config.HTTPFixturesMode, "file", app.Config.HTTPFixturesFile)
+	return nil
 }
 
 // initializeServices creates and configures Kite Connect manager and MCP server
 func (app *App) initializeServices() (*kc.Manager, *server.MCPServer, error) {
 	app.logger.Info("Creating Kite Connect manager...")
 	kcManager, err := kc.New(kc.Config{
-		APIKey:    app.Config.KiteAPIKey,
-		APISecret: app.Config.KiteAPISecret,
-		Logger:    app.logger,
-		Metrics:   app.metrics,
+		APIKey:       app.Config.KiteAPIKey,
+		APISecret:    app.Config.KiteAPISecret,
+		Logger:       app.logger,
+		Metrics:      app.metrics,
+		TradebookDir: app.Config.TradebookDir,
+
+		ScheduledOrdersFile:  app.Config.ScheduledOrdersFile,
+		MarketHolidaysFile:   app.Config.MarketHolidaysFile,
+		InstrumentsSnapshot:  app.Config.InstrumentsSnapshot,
+		CorporateActionsFile: app.Config.CorporateActionsFile,
+		InstrumentsConfig:    app.instrumentsConfig(),
+		HTTPClient:           app.kiteHTTPClient,
 	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create Kite Connect manager: %w", err)
@@ -155,6 +262,7 @@ func (app *App) initializeServices() (*kc.Manager, *server.MCPServer, error) {
 
 	// Store reference for template data
 	app.kcManager = kcManager
+	kcManager.SessionManager().SetSessionDuration(app.sessionDuration())
 
 	// Initialize the status template early for the status page
 	if err := app.initStatusPageTemplate(); err != nil {
@@ -165,15 +273,20 @@ func (app *App) initializeServices() (*kc.Manager, *server.MCPServer, error) {
 
 	// Create MCP server
 	app.logger.Info("Creating MCP server...")
+	app.applyRateLimits()
 	mcpServer := server.NewMCPServer(
 		"Kite MCP Server",
 		app.Version,
+		server.WithToolCapabilities(true),
+		server.WithToolHandlerMiddleware(app.rateLimiter.Middleware()),
 	)
 	app.logger.Debug("MCP server created successfully")
 
 	// Register tools that will interact with MCP sessions and Kite API
 	app.logger.Info("Registering MCP tools...")
 	mcp.RegisterTools(mcpServer, kcManager, app.Config.ExcludedTools, app.logger)
+	mcp.RegisterOrderNotifications(mcpServer, kcManager)
+	mcp.RegisterInstrumentChangeNotifications(mcpServer, kcManager)
 	app.logger.Debug("MCP tools registered successfully")
 
 	return kcManager, mcpServer, nil
@@ -188,8 +301,9 @@ func (app *App) createHTTPServer(url string) *http.Server {
 	}
 }
 
-// setupGracefulShutdown configures graceful shutdown for the server
-func (app *App) setupGracefulShutdown(srv *http.Server, kcManager *kc.Manager) {
+// setupGracefulShutdown configures graceful shutdown for the server,
+// returning a context done once it shuts down
+func (app *App) setupGracefulShutdown(srv *http.Server, kcManager *kc.Manager) context.Context {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 
 	go func() {
@@ -210,6 +324,7 @@ func (app *App) setupGracefulShutdown(srv *http.Server, kcManager *kc.Manager) {
 
 		app.logger.Info("Server shutdown complete")
 	}()
+	return ctx
 }
 
 // startServer selects the appropriate server mode to start
@@ -238,6 +353,7 @@ func (app *App) startServer(srv *http.Server, kcManager *kc.Manager, mcpServer *
 func (app *App) setupMux(kcManager *kc.Manager) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/callback", kcManager.HandleKiteCallback())
+	mux.HandleFunc("/postback", kcManager.HandleOrderPostback())
 	if app.Config.AdminSecretPath != "" {
 		mux.HandleFunc("/admin/", app.metrics.AdminHTTPHandler())
 	}
@@ -350,7 +466,7 @@ func (app *App) startHTTPServer(srv *http.Server, kcManager *kc.Manager, mcpServ
 	mux.HandleFunc("/mcp", withSessionType(mcp.SessionTypeMCP, streamable.ServeHTTP))
 
 	app.logger.Info("MCP session manager configured with automatic cleanup for both MCP and Kite sessions")
-	app.logger.Info("MCP Session manager configured", "session_expiry", kc.DefaultSessionDuration)
+	app.logger.Info("MCP Session manager configured", "session_expiry", kcManager.SessionManager().GetSessionDuration())
 	app.logger.Info("Serving documentation at root URL")
 
 	app.configureAndStartServer(srv, mux)
@@ -108,6 +108,67 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_HTTPFixtures(t *testing.T) {
+	tests := []struct {
+		mode    string
+		file    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"record", "fixtures.json", false},
+		{"replay", "fixtures.json", false},
+		{"replay", "", true},
+		{"playback", "fixtures.json", true},
+	}
+
+	for _, tt := range tests {
+		app := &App{Config: &Config{
+			KiteAPIKey:       "test_key",
+			KiteAPISecret:    "test_secret",
+			HTTPFixturesMode: tt.mode,
+			HTTPFixturesFile: tt.file,
+		}}
+		err := app.LoadConfig()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Mode %q with file %q: expected error %v, got %v", tt.mode, tt.file, tt.wantErr, err)
+		}
+	}
+}
+
+func TestLoadConfig_InstrumentsSource(t *testing.T) {
+	tests := []struct {
+		source    string
+		format    string
+		exchanges string
+		wantErr   bool
+	}{
+		{"", "", "", false},
+		{"https://api.kite.trade/instruments", "CSV", "NSE, NFO", false},
+		{"/var/lib/kite/instruments.json", "", "", false},
+		{"instruments.xml", "xml", "", true},
+		{"", "", "NSE", true},
+	}
+
+	for _, tt := range tests {
+		app := &App{Config: &Config{
+			KiteAPIKey:           "test_key",
+			KiteAPISecret:        "test_secret",
+			InstrumentsSource:    tt.source,
+			InstrumentsFormat:    tt.format,
+			InstrumentsExchanges: tt.exchanges,
+		}}
+		err := app.LoadConfig()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Source %q in format %q for %q: expected error %v, got %v", tt.source, tt.format, tt.exchanges, tt.wantErr, err)
+		}
+	}
+
+	app := &App{Config: &Config{InstrumentsSource: "https://api.kite.trade/instruments", InstrumentsExchanges: "NSE, ,NFO"}}
+	if source := app.instrumentsSource(); len(source.Exchanges) != 2 || source.Exchanges[1] != "NFO" {
+		t.Errorf("Expected the exchanges NSE and NFO, got %v", source.Exchanges)
+	}
+}
+
 func TestStartServer_InvalidMode(t *testing.T) {
 	app := &App{
 		Config: &Config{
@@ -0,0 +1,467 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/zerodha/kite-mcp-server/app/metrics"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// configPollInterval is how often the configuration file is checked for
+// changes.
+const configPollInterval = 2 * time.Second
+
+// ConfigFile is the YAML configuration file set by CONFIG_FILE. Settings it
+// leaves out keep their environment variable or default value. Unknown keys
+// are rejected.
+//
+// The excluded tools, log level, instruments update schedule, instruments
+// source, session duration and rate limits are applied again on SIGHUP or
+// when the file changes. The other settings need a restart.
+type ConfigFile struct {
+	App         AppSettings         `yaml:"app"`
+	Instruments InstrumentsSettings `yaml:"instruments"`
+	Metrics     MetricsSettings     `yaml:"metrics"`
+	Sessions    SessionSettings     `yaml:"sessions"`
+	RateLimits  RateLimitSettings   `yaml:"rate_limits"`
+	Tools       ToolSettings        `yaml:"tools"`
+}
+
+// AppSettings configure the server.
+type AppSettings struct {
+	Mode      string `yaml:"mode"` // sse, stdio, http or hybrid
+	Host      string `yaml:"host"`
+	Port      int    `yaml:"port"`
+	AdminPath string `yaml:"admin_endpoint_secret_path"`
+	LogLevel  string `yaml:"log_level"` // debug, info, warn or error
+}
+
+// InstrumentsSettings configure how instruments are loaded and updated.
+type InstrumentsSettings struct {
+	UpdateTime             string        `yaml:"update_time"` // HH:MM IST
+	RetryAttempts          *int          `yaml:"retry_attempts"`
+	RetryDelay             time.Duration `yaml:"retry_delay"`
+	Scheduler              *bool         `yaml:"scheduler"`
+	MemoryLimit            *int64        `yaml:"memory_limit"` // bytes, 0 is no limit
+	SnapshotFile           string        `yaml:"snapshot_file"`
+	OfflineRefreshInterval time.Duration `yaml:"offline_refresh_interval"`
+	Source                 string        `yaml:"source"`
+	Format                 string        `yaml:"format"`
+	Exchanges              []string      `yaml:"exchanges"`
+}
+
+// MetricsSettings configure the metrics kept for the admin endpoint.
+type MetricsSettings struct {
+	ServiceName          string `yaml:"service_name"`
+	HistoricalDays       int    `yaml:"historical_days"`
+	CleanupRetentionDays int    `yaml:"cleanup_retention_days"`
+	AutoCleanup          *bool  `yaml:"auto_cleanup"`
+}
+
+// SessionSettings configure MCP sessions.
+type SessionSettings struct {
+	// Duration is how long new sessions last. Sessions already open keep
+	// their expiry when it changes.
+	Duration time.Duration `yaml:"duration"`
+}
+
+// RateLimitSettings limit the tool calls of each MCP session per minute,
+// 0 being unlimited.
+type RateLimitSettings struct {
+	ToolCallsPerMinute int            `yaml:"tool_calls_per_minute"`
+	Tools              map[string]int `yaml:"tools"` // calls per minute of a tool
+}
+
+// ToolSettings configure which tools are available.
+type ToolSettings struct {
+	// Exclude lists the tools not registered, replacing EXCLUDED_TOOLS
+	// when set. An empty list excludes none.
+	Exclude []string `yaml:"exclude"`
+}
+
+// loadConfigFile reads and validates a configuration file.
+func loadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	return parseConfigFile(data)
+}
+
+// parseConfigFile decodes and validates a configuration file.
+func parseConfigFile(data []byte) (*ConfigFile, error) {
+	file := &ConfigFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	return file, nil
+}
+
+// Validate reports every setting out of its range.
+func (f *ConfigFile) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+
+	switch f.App.Mode {
+	case "", ModeSSE, ModeStdIO, ModeHTTP, ModeHybrid:
+	default:
+		invalid("app.mode", "unknown mode %q, expected %s, %s, %s or %s", f.App.Mode, ModeSSE, ModeStdIO, ModeHTTP, ModeHybrid)
+	}
+	if f.App.Port < 0 || f.App.Port > 65535 {
+		invalid("app.port", "%d is not a port", f.App.Port)
+	}
+	if f.App.LogLevel != "" {
+		if _, err := parseLogLevel(f.App.LogLevel); err != nil {
+			invalid("app.log_level", "%v", err)
+		}
+	}
+
+	in := f.Instruments
+	if in.UpdateTime != "" {
+		if _, err := time.Parse("15:04", in.UpdateTime); err != nil {
+			invalid("instruments.update_time", "%q is not a HH:MM time", in.UpdateTime)
+		}
+	}
+	if in.RetryAttempts != nil && *in.RetryAttempts < 1 {
+		invalid("instruments.retry_attempts", "must be at least 1")
+	}
+	if in.RetryDelay < 0 {
+		invalid("instruments.retry_delay", "must not be negative")
+	}
+	if in.MemoryLimit != nil && *in.MemoryLimit < 0 {
+		invalid("instruments.memory_limit", "must not be negative")
+	}
+	if in.OfflineRefreshInterval < 0 {
+		invalid("instruments.offline_refresh_interval", "must not be negative")
+	}
+	if err := (instruments.Source{Format: strings.ToLower(in.Format)}).Validate(); err != nil {
+		invalid("instruments.format", "%v", err)
+	}
+
+	if f.Metrics.HistoricalDays < 0 {
+		invalid("metrics.historical_days", "must not be negative")
+	}
+	if f.Metrics.CleanupRetentionDays < 0 {
+		invalid("metrics.cleanup_retention_days", "must not be negative")
+	}
+
+	if f.Sessions.Duration < 0 {
+		invalid("sessions.duration", "must not be negative")
+	}
+
+	toolNames := mcp.ToolNames()
+	if f.RateLimits.ToolCallsPerMinute < 0 {
+		invalid("rate_limits.tool_calls_per_minute", "must not be negative")
+	}
+	for name, limit := range f.RateLimits.Tools {
+		if !toolNames[name] {
+			invalid("rate_limits.tools", "unknown tool %q", name)
+		}
+		if limit < 0 {
+			invalid("rate_limits.tools."+name, "must not be negative")
+		}
+	}
+	for _, name := range f.Tools.Exclude {
+		if !toolNames[name] {
+			invalid("tools.exclude", "unknown tool %q", name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// applyTo sets the settings of the file in the configuration.
+func (f *ConfigFile) applyTo(config *Config) {
+	setString := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	setString(&config.AppMode, f.App.Mode)
+	setString(&config.AppHost, f.App.Host)
+	if f.App.Port != 0 {
+		config.AppPort = strconv.Itoa(f.App.Port)
+	}
+	setString(&config.AdminSecretPath, f.App.AdminPath)
+	if f.Tools.Exclude != nil {
+		config.ExcludedTools = strings.Join(f.Tools.Exclude, ",")
+	}
+	setString(&config.InstrumentsSnapshot, f.Instruments.SnapshotFile)
+	setString(&config.InstrumentsSource, f.Instruments.Source)
+	setString(&config.InstrumentsFormat, f.Instruments.Format)
+	if f.Instruments.Exchanges != nil {
+		config.InstrumentsExchanges = strings.Join(f.Instruments.Exchanges, ",")
+	}
+}
+
+// parseLogLevel parses a LOG_LEVEL value.
+func parseLogLevel(level string) (slog.Level, error) {
+	switch level {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+}
+
+// applyConfigFile loads the configuration file over the environment
+// variables, keeping them to fall back to when the file is reloaded.
+func (app *App) applyConfigFile() error {
+	settings, err := loadConfigFile(app.Config.ConfigFile)
+	if err != nil {
+		return err
+	}
+	app.envConfig = *app.Config
+	app.settings = settings
+	settings.applyTo(app.Config)
+	app.applyLogLevel()
+
+	// Metrics are created before the configuration is loaded
+	app.metrics.Shutdown()
+	app.metrics = metrics.New(app.metricsConfig())
+
+	app.logger.Info("Configuration file loaded", "file", app.Config.ConfigFile)
+	return nil
+}
+
+// SetLogLevel sets the level of the logger, for the log level of the
+// configuration file to change it. Without a configuration file setting
+// it, the level stays as it is.
+func (app *App) SetLogLevel(level *slog.LevelVar) {
+	app.logLevel = level
+	app.envLogLevel = level.Level()
+}
+
+// applyLogLevel sets the logger to the level of the settings.
+func (app *App) applyLogLevel() {
+	if app.logLevel == nil {
+		return
+	}
+	level := app.envLogLevel
+	if app.settings != nil && app.settings.App.LogLevel != "" {
+		level, _ = parseLogLevel(app.settings.App.LogLevel)
+	}
+	if level != app.logLevel.Level() {
+		app.logLevel.Set(level)
+		app.logger.Info("Log level set", "level", level)
+	}
+}
+
+// metricsConfig returns the metrics configuration of the settings.
+func (app *App) metricsConfig() metrics.Config {
+	config := metrics.Config{
+		ServiceName:     "kite-mcp-server",
+		AdminSecretPath: app.Config.AdminSecretPath,
+		AutoCleanup:     true,
+	}
+	if app.settings == nil {
+		return config
+	}
+	s := app.settings.Metrics
+	if s.ServiceName != "" {
+		config.ServiceName = s.ServiceName
+	}
+	config.HistoricalDays = s.HistoricalDays
+	config.CleanupRetentionDays = s.CleanupRetentionDays
+	if s.AutoCleanup != nil {
+		config.AutoCleanup = *s.AutoCleanup
+	}
+	return config
+}
+
+// instrumentsConfig returns the instruments update configuration of the
+// settings.
+func (app *App) instrumentsConfig() *instruments.UpdateConfig {
+	config := instruments.DefaultUpdateConfig()
+	config.Source = app.instrumentsSource()
+	if app.settings == nil {
+		return config
+	}
+	s := app.settings.Instruments
+	if at, err := time.Parse("15:04", s.UpdateTime); err == nil {
+		config.UpdateHour, config.UpdateMinute = at.Hour(), at.Minute()
+	}
+	if s.RetryAttempts != nil {
+		config.RetryAttempts = *s.RetryAttempts
+	}
+	if s.RetryDelay > 0 {
+		config.RetryDelay = s.RetryDelay
+	}
+	if s.Scheduler != nil {
+		config.EnableScheduler = *s.Scheduler
+	}
+	if s.MemoryLimit != nil {
+		config.MemoryLimit = *s.MemoryLimit
+	}
+	if s.OfflineRefreshInterval > 0 {
+		config.OfflineRefreshInterval = s.OfflineRefreshInterval
+	}
+	return config
+}
+
+// sessionDuration returns how long new MCP sessions last.
+func (app *App) sessionDuration() time.Duration {
+	if app.settings != nil && app.settings.Sessions.Duration > 0 {
+		return app.settings.Sessions.Duration
+	}
+	return kc.DefaultSessionDuration
+}
+
+// applyRateLimits sets the rate limits of the settings.
+func (app *App) applyRateLimits() {
+	if app.settings == nil {
+		return
+	}
+	app.rateLimiter.SetLimits(app.settings.RateLimits.ToolCallsPerMinute, app.settings.RateLimits.Tools)
+}
+
+// restartRequired returns the settings that differ between two
+// configurations and only apply on restart.
+func restartRequired(running, reloaded *Config, before, after *ConfigFile) []string {
+	changed := map[string]bool{
+		"app.mode":                             running.AppMode != reloaded.AppMode,
+		"app.host":                             running.AppHost != reloaded.AppHost,
+		"app.port":                             running.AppPort != reloaded.AppPort,
+		"app.admin_endpoint_secret_path":       running.AdminSecretPath != reloaded.AdminSecretPath,
+		"instruments.snapshot_file":            running.InstrumentsSnapshot != reloaded.InstrumentsSnapshot,
+		"instruments.scheduler":                !reflect.DeepEqual(before.Instruments.Scheduler, after.Instruments.Scheduler),
+		"instruments.offline_refresh_interval": before.Instruments.OfflineRefreshInterval != after.Instruments.OfflineRefreshInterval,
+		"metrics":                              !reflect.DeepEqual(before.Metrics, after.Metrics),
+	}
+	var keys []string
+	for key, differs := range changed {
+		if differs {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reloadConfig loads the configuration file again and applies the
+// settings that can change at runtime. Sessions stay open. An invalid file
+// is an error and leaves the configuration as it is.
+func (app *App) reloadConfig(kcManager *kc.Manager, mcpServer *server.MCPServer) error {
+	settings, err := loadConfigFile(app.Config.ConfigFile)
+	if err != nil {
+		return err
+	}
+	reloaded := app.envConfig
+	settings.applyTo(&reloaded)
+	reloaded.setDefaults()
+	previous := *app.Config
+	app.Config.InstrumentsSource = reloaded.InstrumentsSource
+	app.Config.InstrumentsFormat = reloaded.InstrumentsFormat
+	app.Config.InstrumentsExchanges = reloaded.InstrumentsExchanges
+	if err := app.instrumentsSource().Validate(); err != nil {
+		app.Config.InstrumentsSource = previous.InstrumentsSource
+		app.Config.InstrumentsFormat = previous.InstrumentsFormat
+		app.Config.InstrumentsExchanges = previous.InstrumentsExchanges
+		return fmt.Errorf("invalid instruments source: %w", err)
+	}
+
+	if keys := restartRequired(app.Config, &reloaded, app.settings, settings); len(keys) > 0 {
+		app.logger.Warn("Configuration changes need a restart to apply", "settings", strings.Join(keys, ", "))
+	}
+	app.settings = settings
+	app.Config.ExcludedTools = reloaded.ExcludedTools
+
+	app.applyLogLevel()
+	app.applyRateLimits()
+	mcp.UpdateExcludedTools(mcpServer, kcManager, previous.ExcludedTools, app.Config.ExcludedTools, app.logger)
+
+	// The scheduler reads the update time on every check, and loads read
+	// the source, so both apply from the next update
+	reloadedInstruments := app.instrumentsConfig()
+	instrumentsConfig := *kcManager.Instruments.GetConfig()
+	instrumentsConfig.UpdateHour = reloadedInstruments.UpdateHour
+	instrumentsConfig.UpdateMinute = reloadedInstruments.UpdateMinute
+	instrumentsConfig.RetryAttempts = reloadedInstruments.RetryAttempts
+	instrumentsConfig.RetryDelay = reloadedInstruments.RetryDelay
+	instrumentsConfig.MemoryLimit = reloadedInstruments.MemoryLimit
+	instrumentsConfig.Source = reloadedInstruments.Source
+	kcManager.UpdateInstrumentsConfig(&instrumentsConfig)
+
+	kcManager.SessionManager().SetSessionDuration(app.sessionDuration())
+
+	app.logger.Info("Configuration reloaded", "file", app.Config.ConfigFile)
+	return nil
+}
+
+// fileVersion identifies a version of a file by its size and modification
+// time, zero if it can't be read.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func versionOf(path string) fileVersion {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}
+}
+
+// watchConfigFile reloads the configuration file on SIGHUP and when it
+// changes, until ctx is done.
+func (app *App) watchConfigFile(ctx context.Context, kcManager *kc.Manager, mcpServer *server.MCPServer, interval time.Duration) {
+	path := app.Config.ConfigFile
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	loaded := versionOf(path)
+
+	go func() {
+		defer signal.Stop(hangup)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				app.logger.Info("Reloading configuration on SIGHUP", "file", path)
+			case <-ticker.C:
+				version := versionOf(path)
+				if version == loaded {
+					continue
+				}
+				app.logger.Info("Configuration file changed, reloading", "file", path)
+			}
+			loaded = versionOf(path)
+			if err := app.reloadConfig(kcManager, mcpServer); err != nil {
+				app.logger.Error("Failed to reload configuration, keeping the current one", "error", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gomcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const testConfigFile = `
+app:
+  mode: sse
+  port: 9090
+  log_level: debug
+instruments:
+  update_time: "07:45"
+  retry_attempts: 5
+  retry_delay: 30s
+  scheduler: false
+  memory_limit: 0
+  source: ../kc/instruments/testdata/instruments.csv
+  format: csv
+metrics:
+  historical_days: 3
+sessions:
+  duration: 2h
+rate_limits:
+  tool_calls_per_minute: 60
+  tools:
+    place_order: 10
+tools:
+  exclude: [place_order, modify_order]
+`
+
+// writeConfigFile writes a configuration file into dir.
+func writeConfigFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseConfigFile(t *testing.T) {
+	file, err := parseConfigFile([]byte(testConfigFile))
+	if err != nil {
+		t.Fatalf("Expected the config file to parse, got: %v", err)
+	}
+
+	if file.App.Mode != ModeSSE || file.App.Port != 9090 || file.App.LogLevel != "debug" {
+		t.Errorf("Expected the app settings, got %+v", file.App)
+	}
+	if file.Instruments.RetryDelay != 30*time.Second || *file.Instruments.RetryAttempts != 5 || *file.Instruments.Scheduler {
+		t.Errorf("Expected the instruments settings, got %+v", file.Instruments)
+	}
+	if file.Instruments.MemoryLimit == nil || *file.Instruments.MemoryLimit != 0 {
+		t.Error("Expected a memory limit of 0 told apart from none")
+	}
+	if file.Sessions.Duration != 2*time.Hour {
+		t.Errorf("Expected a session duration of 2h, got %v", file.Sessions.Duration)
+	}
+	if file.RateLimits.ToolCallsPerMinute != 60 || file.RateLimits.Tools["place_order"] != 10 {
+		t.Errorf("Expected the rate limits, got %+v", file.RateLimits)
+	}
+	if strings.Join(file.Tools.Exclude, ",") != "place_order,modify_order" {
+		t.Errorf("Expected the excluded tools, got %v", file.Tools.Exclude)
+	}
+
+	if _, err := parseConfigFile(nil); err != nil {
+		t.Errorf("Expected an empty config file to be valid, got: %v", err)
+	}
+}
+
+func TestParseConfigFile_Invalid(t *testing.T) {
+	tests := []struct {
+		content string
+		wantErr string
+	}{
+		{"app:\n  prot: 9090\n", "field prot not found"},
+		{"app:\n  mode: grpc\n", "app.mode"},
+		{"app:\n  port: 70000\n", "app.port"},
+		{"app:\n  log_level: verbose\n", "app.log_level"},
+		{"instruments:\n  update_time: \"25:00\"\n", "instruments.update_time"},
+		{"instruments:\n  retry_attempts: 0\n", "instruments.retry_attempts"},
+		{"instruments:\n  retry_delay: soon\n", "into time.Duration"},
+		{"instruments:\n  format: xml\n", "instruments.format"},
+		{"sessions:\n  duration: -1h\n", "sessions.duration"},
+		{"rate_limits:\n  tools:\n    place_orders: 5\n", "unknown tool \"place_orders\""},
+		{"tools:\n  exclude: [place_order, no_such_tool]\n", "unknown tool \"no_such_tool\""},
+	}
+
+	for _, tt := range tests {
+		_, err := parseConfigFile([]byte(tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Config %q: expected an error about %s, got %v", tt.content, tt.wantErr, err)
+		}
+	}
+}
+
+func TestLoadConfig_ConfigFile(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), testConfigFile)
+	level := &slog.LevelVar{}
+	app := NewApp(testLogger())
+	app.SetLogLevel(level)
+	app.Config.KiteAPIKey = "test_key"
+	app.Config.KiteAPISecret = "test_secret"
+	app.Config.ConfigFile = path
+	app.Config.AppMode = ModeHTTP
+	app.Config.AppHost = "0.0.0.0"
+	app.Config.ExcludedTools = "get_holdings"
+
+	if err := app.LoadConfig(); err != nil {
+		t.Fatalf("Expected the config file to load, got: %v", err)
+	}
+	defer app.metrics.Shutdown()
+
+	// The file overrides the environment, which fills in what it leaves out
+	if app.Config.AppMode != ModeSSE || app.Config.AppPort != "9090" || app.Config.AppHost != "0.0.0.0" {
+		t.Errorf("Expected mode sse on 0.0.0.0:9090, got %s on %s:%s", app.Config.AppMode, app.Config.AppHost, app.Config.AppPort)
+	}
+	if app.Config.ExcludedTools != "place_order,modify_order" {
+		t.Errorf("Expected the excluded tools of the file, got %q", app.Config.ExcludedTools)
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("Expected the debug log level, got %v", level.Level())
+	}
+	if config := app.instrumentsConfig(); config.UpdateHour != 7 || config.UpdateMinute != 45 || config.EnableScheduler || config.Source.Format != "csv" {
+		t.Errorf("Expected the instruments settings applied, got %+v", config)
+	}
+	if app.sessionDuration() != 2*time.Hour {
+		t.Errorf("Expected a session duration of 2h, got %v", app.sessionDuration())
+	}
+
+	app = NewApp(testLogger())
+	app.Config.KiteAPIKey = "test_key"
+	app.Config.KiteAPISecret = "test_secret"
+	app.Config.ConfigFile = writeConfigFile(t, t.TempDir(), "app:\n  mode: grpc\n")
+	if err := app.LoadConfig(); err == nil || !strings.Contains(err.Error(), "app.mode") {
+		t.Errorf("Expected an invalid config file to fail loading, got %v", err)
+	}
+}
+
+// toolNames lists the tools of an MCP server.
+func toolNames(t *testing.T, srv *server.MCPServer) map[string]bool {
+	t.Helper()
+	resp, ok := srv.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)).(gomcp.JSONRPCResponse)
+	if !ok {
+		t.Fatal("Expected the tools listed")
+	}
+	names := make(map[string]bool)
+	for _, tool := range resp.Result.(gomcp.ListToolsResult).Tools {
+		names[tool.Name] = true
+	}
+	return names
+}
+
+func TestReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, testConfigFile)
+	level := &slog.LevelVar{}
+	app := NewApp(testLogger())
+	app.SetLogLevel(level)
+	app.Config.KiteAPIKey = "test_key"
+	app.Config.KiteAPISecret = "test_secret"
+	app.Config.ConfigFile = path
+	if err := app.LoadConfig(); err != nil {
+		t.Fatalf("Expected the config file to load, got: %v", err)
+	}
+	defer app.metrics.Shutdown()
+
+	kcManager, mcpServer, err := app.initializeServices()
+	if err != nil {
+		t.Fatalf("Expected the services to start, got: %v", err)
+	}
+	defer kcManager.Shutdown()
+
+	if tools := toolNames(t, mcpServer); tools["place_order"] || !tools["get_holdings"] {
+		t.Error("Expected place_order excluded and get_holdings registered")
+	}
+	sessionID := kcManager.SessionManager().Generate()
+
+	reloaded := strings.NewReplacer(
+		"log_level: debug", "log_level: warn",
+		"mode: sse", "mode: hybrid",
+		`update_time: "07:45"`, `update_time: "06:30"`,
+		"duration: 2h", "duration: 30m",
+		"exclude: [place_order, modify_order]", "exclude: [get_holdings, modify_order]",
+	).Replace(testConfigFile)
+	writeConfigFile(t, dir, reloaded)
+	if err := app.reloadConfig(kcManager, mcpServer); err != nil {
+		t.Fatalf("Expected the config file to reload, got: %v", err)
+	}
+
+	tools := toolNames(t, mcpServer)
+	if !tools["place_order"] || tools["get_holdings"] || tools["modify_order"] {
+		t.Error("Expected place_order registered again, and get_holdings and modify_order excluded")
+	}
+	if level.Level() != slog.LevelWarn {
+		t.Errorf("Expected the warn log level, got %v", level.Level())
+	}
+	if config := kcManager.Instruments.GetConfig(); config.UpdateHour != 6 || config.UpdateMinute != 30 || config.TradingDay == nil {
+		t.Errorf("Expected the update time changed and the trading days kept, got %+v", config)
+	}
+	if kcManager.SessionManager().GetSessionDuration() != 30*time.Minute {
+		t.Errorf("Expected new sessions to last 30m, got %v", kcManager.SessionManager().GetSessionDuration())
+	}
+	if _, err := kcManager.SessionManager().Validate(sessionID); err != nil {
+		t.Errorf("Expected the open session kept, got: %v", err)
+	}
+	if app.Config.AppMode != ModeSSE {
+		t.Errorf("Expected the mode to only change on restart, got %s", app.Config.AppMode)
+	}
+
+	// An invalid file keeps the configuration in use
+	writeConfigFile(t, dir, "tools:\n  exclude: [no_such_tool]\n")
+	if err := app.reloadConfig(kcManager, mcpServer); err == nil {
+		t.Error("Expected an invalid config file to fail reloading")
+	}
+	if app.Config.ExcludedTools != "get_holdings,modify_order" || level.Level() != slog.LevelWarn {
+		t.Errorf("Expected the configuration kept, got excluded tools %q", app.Config.ExcludedTools)
+	}
+
+	// Changes to the file are picked up while watching it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app.watchConfigFile(ctx, kcManager, mcpServer, 10*time.Millisecond)
+	writeConfigFile(t, dir, "app:\n  log_level: error\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for level.Level() != slog.LevelError && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if level.Level() != slog.LevelError {
+		t.Errorf("Expected the changed file reloaded, got log level %v", level.Level())
+	}
+}
@@ -5,7 +5,9 @@ go 1.24.2
 require (
 	github.com/google/uuid v1.6.0
 	github.com/mark3labs/mcp-go v0.31.0
+	github.com/stretchr/testify v1.10.0
 	github.com/zerodha/gokiteconnect/v4 v4.3.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,7 +16,5 @@ require (
 	github.com/google/go-querystring v1.0.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
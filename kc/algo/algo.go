@@ -0,0 +1,678 @@
+// Package algo works large orders as a series of smaller child orders
+// spread over time. A TWAP algo releases the quantity evenly over a window;
+// a participation-rate algo follows the traded volume reported by quotes
+// and takes a fixed share of it, so its fills track the market's VWAP.
+package algo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+const (
+	// DefaultTickInterval is how often running algos are stepped.
+	DefaultTickInterval = 5 * time.Second
+
+	// DefaultRetention is how long finished algos remain inspectable.
+	DefaultRetention = 24 * time.Hour
+
+	// MaxFailures is how many child orders may fail to place or be
+	// rejected without a fill in between before the algo gives up.
+	MaxFailures = 3
+
+	// MaxDuration bounds the execution window to a trading day.
+	MaxDuration = 24 * time.Hour
+
+	// MaxParticipationRate keeps an algo from dominating the volume it
+	// follows.
+	MaxParticipationRate = 0.5
+
+	// Tag marks the child orders placed by the engine.
+	Tag = "ALGO"
+)
+
+var (
+	ErrNotFound    = errors.New("algo order not found")
+	ErrFinished    = errors.New("algo order has already finished")
+	ErrInvalidSpec = errors.New("invalid algo order")
+)
+
+// Type selects how an algo releases quantity.
+type Type string
+
+const (
+	TypeTWAP          Type = "twap"          // equal slices at equal intervals
+	TypeParticipation Type = "participation" // a share of the traded volume
+)
+
+// Status is the lifecycle stage of an algo.
+type Status string
+
+const (
+	StatusRunning   Status = "RUNNING"   // releasing child orders
+	StatusCompleted Status = "COMPLETED" // the full quantity filled
+	StatusExpired   Status = "EXPIRED"   // the window ended before the full quantity filled
+	StatusCancelled Status = "CANCELLED" // cancelled by the user
+	StatusFailed    Status = "FAILED"    // child orders kept failing
+)
+
+// Quote is the market data an algo steers by. Volume is the cumulative
+// traded volume of the day and AveragePrice its volume-weighted price.
+type Quote struct {
+	LastPrice    float64
+	Volume       int
+	AveragePrice float64
+}
+
+// Broker places and tracks the child orders of one user.
+type Broker interface {
+	PlaceOrder(params kiteconnect.OrderParams) (string, error)
+	CancelOrder(orderID string) error
+	OrderStatus(orderID string) (orderwatch.Update, error)
+	Quote(instrument string) (Quote, error)
+}
+
+// Clock tells the engine the time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Calendar tells the engine whether an exchange is trading.
+type Calendar interface {
+	IsOpen(exchange string, t time.Time) bool
+}
+
+// Spec describes an algo order. A non-zero LimitPrice places the children
+// as LIMIT orders at that price and holds back releases while the market
+// trades beyond it; otherwise children are MARKET orders.
+type Spec struct {
+	UserID            string        `json:"user_id,omitempty"`
+	Type              Type          `json:"type"`
+	Exchange          string        `json:"exchange"`
+	Tradingsymbol     string        `json:"tradingsymbol"`
+	TransactionType   string        `json:"transaction_type"`
+	Product           string        `json:"product"`
+	Quantity          int           `json:"quantity"`
+	LimitPrice        float64       `json:"limit_price,omitempty"`
+	Duration          time.Duration `json:"-"`
+	Slices            int           `json:"slices,omitempty"`             // TWAP; defaults to one per minute
+	ParticipationRate float64       `json:"participation_rate,omitempty"` // participation, e.g. 0.1 for 10%
+	LotSize           int           `json:"lot_size,omitempty"`           // children are whole lots
+	MaxChildQuantity  int           `json:"max_child_quantity,omitempty"` // optional per-order cap, e.g. below the freeze quantity
+}
+
+// Instrument returns the EXCHANGE:TRADINGSYMBOL key of the spec.
+func (s Spec) Instrument() string {
+	return s.Exchange + ":" + s.Tradingsymbol
+}
+
+// Validate checks the spec and fills in the TWAP slice count.
+func (s *Spec) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSpec, fmt.Sprintf(format, args...))
+	}
+
+	if s.Exchange == "" || s.Tradingsymbol == "" {
+		return invalid("exchange and tradingsymbol are required")
+	}
+	if s.TransactionType != kiteconnect.TransactionTypeBuy && s.TransactionType != kiteconnect.TransactionTypeSell {
+		return invalid("transaction type must be BUY or SELL")
+	}
+	if s.Quantity <= 0 {
+		return invalid("quantity must be positive")
+	}
+	if s.LotSize > 1 && s.Quantity%s.LotSize != 0 {
+		return invalid("quantity %d is not a multiple of the lot size %d", s.Quantity, s.LotSize)
+	}
+	if s.LimitPrice < 0 {
+		return invalid("limit price cannot be negative")
+	}
+	if s.Duration < time.Minute || s.Duration > MaxDuration {
+		return invalid("duration must be between 1 minute and %s", MaxDuration)
+	}
+	if s.MaxChildQuantity < 0 {
+		return invalid("max child quantity cannot be negative")
+	}
+	if s.MaxChildQuantity > 0 && s.MaxChildQuantity < s.lot() {
+		return invalid("max child quantity %d is below one lot", s.MaxChildQuantity)
+	}
+
+	switch s.Type {
+	case TypeTWAP:
+		lots := s.Quantity / s.lot()
+		if s.Slices == 0 {
+			s.Slices = min(int(s.Duration/time.Minute), lots)
+		}
+		if s.Slices < 1 || s.Slices > lots {
+			return invalid("slices must be between 1 and %d, the number of lots", lots)
+		}
+		if s.Duration/time.Duration(s.Slices) < 10*time.Second {
+			return invalid("slices must be at least 10 seconds apart")
+		}
+	case TypeParticipation:
+		if s.ParticipationRate <= 0 || s.ParticipationRate > MaxParticipationRate {
+			return invalid("participation rate must be above 0 and at most %v", MaxParticipationRate)
+		}
+	default:
+		return invalid("algo must be %q or %q", TypeTWAP, TypeParticipation)
+	}
+	return nil
+}
+
+func (s Spec) lot() int {
+	return max(s.LotSize, 1)
+}
+
+// Child is the last known state of one child order.
+type Child struct {
+	OrderID        string    `json:"order_id"`
+	Quantity       int       `json:"quantity"`
+	FilledQuantity int       `json:"filled_quantity"`
+	AveragePrice   float64   `json:"average_price,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	StatusMessage  string    `json:"status_message,omitempty"`
+	PlacedAt       time.Time `json:"placed_at"`
+}
+
+func (c *Child) open() bool {
+	return !orderwatch.IsTerminal(c.Status)
+}
+
+// committed is the quantity the child may still fill or has filled.
+func (c *Child) committed() int {
+	if c.open() {
+		return c.Quantity
+	}
+	return c.FilledQuantity
+}
+
+// Order is a snapshot of an algo and its progress.
+type Order struct {
+	ID                string    `json:"algo_id"`
+	Spec              Spec      `json:"spec"`
+	Status            Status    `json:"status"`
+	FilledQuantity    int       `json:"filled_quantity"`
+	AveragePrice      float64   `json:"average_price,omitempty"`
+	OpenQuantity      int       `json:"open_quantity"` // in working child orders
+	PercentComplete   float64   `json:"percent_complete"`
+	MarketVolume      int       `json:"market_volume"`                // traded since the algo started
+	MarketVWAP        float64   `json:"market_vwap,omitempty"`        // the day's VWAP at the last step
+	ParticipationRate float64   `json:"participation_rate,omitempty"` // filled share of MarketVolume
+	Children          []Child   `json:"children"`
+	Error             string    `json:"error,omitempty"`
+	StartedAt         time.Time `json:"started_at"`
+	EndsAt            time.Time `json:"ends_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// tracked is an algo with its broker and stepping state. mu serializes
+// steps and user actions on one algo.
+type tracked struct {
+	mu         sync.Mutex
+	order      Order
+	broker     Broker
+	lastVolume int // cumulative day volume at the last quote, 0 before the first
+	failures   int
+}
+
+// Config configures an Engine.
+type Config struct {
+	Logger   *slog.Logger
+	Clock    Clock    // optional - defaults to the system clock
+	Calendar Calendar // optional - if nil, algos run whenever they are stepped
+}
+
+// Engine runs algo orders. Locks are taken in the order tickMu, mu,
+// tracked.mu.
+type Engine struct {
+	mu    sync.Mutex
+	algos map[string]*tracked
+
+	logger   *slog.Logger
+	clock    Clock
+	calendar Calendar
+
+	tickMu sync.Mutex
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// New creates an engine. Call Start to step running algos in the
+// background.
+func New(cfg Config) *Engine {
+	e := &Engine{
+		algos:    make(map[string]*tracked),
+		logger:   cfg.Logger,
+		clock:    cfg.Clock,
+		calendar: cfg.Calendar,
+		stop:     make(chan struct{}),
+	}
+	if e.clock == nil {
+		e.clock = systemClock{}
+	}
+	return e
+}
+
+// Place starts an algo and releases its first child order right away.
+func (e *Engine) Place(broker Broker, spec Spec) (Order, error) {
+	if err := spec.Validate(); err != nil {
+		return Order{}, err
+	}
+
+	now := e.clock.Now()
+	t := &tracked{
+		broker: broker,
+		order: Order{
+			ID:        newID(),
+			Spec:      spec,
+			Status:    StatusRunning,
+			Children:  []Child{},
+			StartedAt: now,
+			EndsAt:    now.Add(spec.Duration),
+			UpdatedAt: now,
+		},
+	}
+
+	e.mu.Lock()
+	e.pruneLocked(now)
+	e.algos[t.order.ID] = t
+	e.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.step(t, now)
+	return t.order.snapshot(), nil
+}
+
+// Get returns an algo owned by userID.
+func (e *Engine) Get(id, userID string) (Order, error) {
+	t, err := e.lookup(id, userID)
+	if err != nil {
+		return Order{}, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.order.snapshot(), nil
+}
+
+// List returns the algos of userID, newest first.
+func (e *Engine) List(userID string) []Order {
+	list := []Order{}
+	for _, t := range e.all() {
+		t.mu.Lock()
+		if t.order.Spec.UserID == userID {
+			list = append(list, t.order.snapshot())
+		}
+		t.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedAt.After(list[j].StartedAt)
+	})
+	return list
+}
+
+// Cancel stops an algo and cancels its working child orders. Quantity
+// that already filled stays filled.
+func (e *Engine) Cancel(id, userID string) (Order, error) {
+	t, err := e.lookup(id, userID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.order.Status != StatusRunning {
+		return t.order.snapshot(), ErrFinished
+	}
+	if err := e.cancelChildren(t); err != nil {
+		return t.order.snapshot(), fmt.Errorf("failed to cancel algo order: %w", err)
+	}
+	e.finish(t, StatusCancelled, "cancelled by user")
+	return t.order.snapshot(), nil
+}
+
+// Start steps running algos every interval until Shutdown.
+func (e *Engine) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.Tick()
+			}
+		}
+	}()
+}
+
+// Shutdown stops the loop started by Start. Running algos keep their
+// working child orders.
+func (e *Engine) Shutdown() {
+	e.once.Do(func() { close(e.stop) })
+}
+
+// Tick steps every running algo once.
+func (e *Engine) Tick() {
+	e.tickMu.Lock()
+	defer e.tickMu.Unlock()
+
+	now := e.clock.Now()
+	e.mu.Lock()
+	e.pruneLocked(now)
+	e.mu.Unlock()
+
+	for _, t := range e.all() {
+		t.mu.Lock()
+		if t.order.Status == StatusRunning {
+			e.step(t, now)
+		}
+		t.mu.Unlock()
+	}
+}
+
+func (e *Engine) all() []*tracked {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	all := make([]*tracked, 0, len(e.algos))
+	for _, t := range e.algos {
+		all = append(all, t)
+	}
+	return all
+}
+
+func (e *Engine) lookup(id, userID string) (*tracked, error) {
+	e.mu.Lock()
+	t, ok := e.algos[id]
+	e.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	t.mu.Lock()
+	owner := t.order.Spec.UserID
+	t.mu.Unlock()
+	if owner != userID {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// pruneLocked forgets algos that finished more than DefaultRetention ago.
+// e.mu must be held.
+func (e *Engine) pruneLocked(now time.Time) {
+	for id, t := range e.algos {
+		t.mu.Lock()
+		expired := t.order.Status != StatusRunning && now.Sub(t.order.UpdatedAt) > DefaultRetention
+		t.mu.Unlock()
+		if expired {
+			delete(e.algos, id)
+		}
+	}
+}
+
+// step refreshes the children of a running algo, finishes it when it is
+// done and releases whatever quantity is due. t.mu must be held.
+func (e *Engine) step(t *tracked, now time.Time) {
+	o := &t.order
+	o.UpdatedAt = now
+	e.refreshChildren(t)
+	if t.failures >= MaxFailures {
+		e.fail(t, o.Error)
+		return
+	}
+
+	if o.FilledQuantity >= o.Spec.Quantity {
+		e.finish(t, StatusCompleted, "")
+		return
+	}
+	if !now.Before(o.EndsAt) {
+		if err := e.cancelChildren(t); err != nil {
+			o.Error = fmt.Sprintf("window ended but cancelling child orders failed: %v", err)
+			return
+		}
+		e.finish(t, StatusExpired, "")
+		return
+	}
+	if e.calendar != nil && !e.calendar.IsOpen(o.Spec.Exchange, now) {
+		return
+	}
+
+	quote, err := t.broker.Quote(o.Spec.Instrument())
+	if err != nil {
+		e.logger.Warn("Failed to get quote for algo", "algo_id", o.ID, "error", err)
+		return
+	}
+	e.trackVolume(t, quote)
+	if !o.Spec.priceOK(quote.LastPrice) {
+		return
+	}
+
+	for _, qty := range o.Spec.childQuantities(e.due(t, now)) {
+		if !e.placeChild(t, qty, now) {
+			break
+		}
+	}
+}
+
+// trackVolume accumulates the market volume traded since the algo started.
+func (e *Engine) trackVolume(t *tracked, quote Quote) {
+	o := &t.order
+	if t.lastVolume > 0 && quote.Volume >= t.lastVolume {
+		o.MarketVolume += quote.Volume - t.lastVolume
+	}
+	t.lastVolume = quote.Volume
+	o.MarketVWAP = quote.AveragePrice
+	if o.MarketVolume > 0 {
+		o.ParticipationRate = float64(o.FilledQuantity) / float64(o.MarketVolume)
+	}
+}
+
+// priceOK reports whether children may be released at the last price.
+func (s Spec) priceOK(lastPrice float64) bool {
+	switch {
+	case s.LimitPrice == 0:
+		return true
+	case s.TransactionType == kiteconnect.TransactionTypeBuy:
+		return lastPrice <= s.LimitPrice
+	default:
+		return lastPrice >= s.LimitPrice
+	}
+}
+
+// due returns the quantity to release now: the algo's target so far less
+// what its children already hold.
+func (e *Engine) due(t *tracked, now time.Time) int {
+	o := &t.order
+	spec := o.Spec
+
+	target := 0
+	switch spec.Type {
+	case TypeTWAP:
+		interval := spec.Duration / time.Duration(spec.Slices)
+		slice := min(int(now.Sub(o.StartedAt)/interval)+1, spec.Slices)
+		target = spec.Quantity * slice / spec.Slices
+		if slice < spec.Slices {
+			target -= target % spec.lot()
+		}
+	case TypeParticipation:
+		target = int(spec.ParticipationRate * float64(o.MarketVolume))
+		target -= target % spec.lot()
+	}
+
+	committed := 0
+	for i := range o.Children {
+		committed += o.Children[i].committed()
+	}
+	return max(min(target, spec.Quantity)-committed, 0)
+}
+
+// childQuantities splits qty into whole-lot orders within the child cap.
+func (s Spec) childQuantities(qty int) []int {
+	qty -= qty % s.lot()
+	if qty <= 0 {
+		return nil
+	}
+	if s.MaxChildQuantity <= 0 {
+		return []int{qty}
+	}
+
+	maxChild := s.MaxChildQuantity - s.MaxChildQuantity%s.lot()
+	var out []int
+	for qty > 0 {
+		child := min(qty, maxChild)
+		out = append(out, child)
+		qty -= child
+	}
+	return out
+}
+
+// placeChild places one child order and reports whether it was accepted.
+func (e *Engine) placeChild(t *tracked, qty int, now time.Time) bool {
+	o := &t.order
+	params := kiteconnect.OrderParams{
+		Exchange:        o.Spec.Exchange,
+		Tradingsymbol:   o.Spec.Tradingsymbol,
+		TransactionType: o.Spec.TransactionType,
+		Product:         o.Spec.Product,
+		OrderType:       kiteconnect.OrderTypeMarket,
+		Quantity:        qty,
+		Validity:        kiteconnect.ValidityDay,
+		Tag:             Tag,
+	}
+	if o.Spec.LimitPrice > 0 {
+		params.OrderType = kiteconnect.OrderTypeLimit
+		params.Price = o.Spec.LimitPrice
+	}
+
+	orderID, err := t.broker.PlaceOrder(params)
+	if err != nil {
+		t.failures++
+		o.Error = fmt.Sprintf("failed to place child order: %v", err)
+		e.logger.Warn("Failed to place algo child order", "algo_id", o.ID, "quantity", qty, "error", err)
+		if t.failures >= MaxFailures {
+			e.fail(t, o.Error)
+		}
+		return false
+	}
+
+	o.Children = append(o.Children, Child{OrderID: orderID, Quantity: qty, PlacedAt: now})
+	o.OpenQuantity += qty
+	return true
+}
+
+// refreshChildren updates the open children from the order book and
+// recomputes the fills of the algo.
+func (e *Engine) refreshChildren(t *tracked) {
+	o := &t.order
+	for i := range o.Children {
+		c := &o.Children[i]
+		if !c.open() {
+			continue
+		}
+		u, err := t.broker.OrderStatus(c.OrderID)
+		if err != nil {
+			e.logger.Warn("Failed to refresh algo child order", "algo_id", o.ID, "order_id", c.OrderID, "error", err)
+			continue
+		}
+		prevFilled := c.FilledQuantity
+		c.Status = u.Status
+		c.StatusMessage = u.StatusMessage
+		c.FilledQuantity = int(u.FilledQuantity)
+		c.AveragePrice = u.AveragePrice
+		switch {
+		case c.Status == orderwatch.StatusRejected:
+			t.failures++
+			o.Error = fmt.Sprintf("child order %s rejected: %s", c.OrderID, c.StatusMessage)
+		case c.FilledQuantity > prevFilled:
+			t.failures = 0
+			o.Error = ""
+		}
+	}
+	o.recompute()
+}
+
+func (o *Order) recompute() {
+	filled, open := 0, 0
+	value := 0.0
+	for i := range o.Children {
+		c := &o.Children[i]
+		filled += c.FilledQuantity
+		value += float64(c.FilledQuantity) * c.AveragePrice
+		if c.open() {
+			open += c.Quantity - c.FilledQuantity
+		}
+	}
+	o.FilledQuantity = filled
+	o.OpenQuantity = open
+	o.AveragePrice = 0
+	if filled > 0 {
+		o.AveragePrice = value / float64(filled)
+	}
+	o.PercentComplete = float64(filled) * 100 / float64(o.Spec.Quantity)
+	if o.MarketVolume > 0 {
+		o.ParticipationRate = float64(filled) / float64(o.MarketVolume)
+	}
+}
+
+// cancelChildren cancels every working child order.
+func (e *Engine) cancelChildren(t *tracked) error {
+	var errs []error
+	for i := range t.order.Children {
+		c := &t.order.Children[i]
+		if !c.open() {
+			continue
+		}
+		if err := t.broker.CancelOrder(c.OrderID); err != nil {
+			errs = append(errs, fmt.Errorf("order %s: %w", c.OrderID, err))
+			continue
+		}
+		c.Status = orderwatch.StatusCancelled
+	}
+	t.order.recompute()
+	return errors.Join(errs...)
+}
+
+// fail cancels the working children and marks the algo failed.
+func (e *Engine) fail(t *tracked, message string) {
+	if err := e.cancelChildren(t); err != nil {
+		message = fmt.Sprintf("%s; cancelling child orders failed: %v", message, err)
+	}
+	e.finish(t, StatusFailed, message)
+}
+
+func (e *Engine) finish(t *tracked, status Status, message string) {
+	t.order.Status = status
+	t.order.Error = message
+	t.order.UpdatedAt = e.clock.Now()
+	e.logger.Info("Algo order finished", "algo_id", t.order.ID, "status", status,
+		"filled", t.order.FilledQuantity, "quantity", t.order.Spec.Quantity)
+}
+
+// snapshot copies the order so that callers cannot race with steps.
+func (o Order) snapshot() Order {
+	o.Children = slices.Clone(o.Children)
+	return o
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "algo_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,519 @@
+package algo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type simOrder struct {
+	params kiteconnect.OrderParams
+	update orderwatch.Update
+}
+
+// simMarket is a single-instrument market. MARKET orders fill at the last
+// price; LIMIT orders fill once the price reaches them. Every fill adds to
+// the traded volume, as it would on the exchange.
+type simMarket struct {
+	mu        sync.Mutex
+	price     float64
+	volume    int
+	value     float64
+	orders    map[string]*simOrder
+	placed    []kiteconnect.OrderParams
+	nextID    int
+	rejectAll bool
+	placeErr  error
+}
+
+func newSimMarket(price float64, volume int) *simMarket {
+	return &simMarket{price: price, volume: volume, value: price * float64(volume), orders: make(map[string]*simOrder)}
+}
+
+// trade moves the market to price with volume traded by others.
+func (m *simMarket) trade(price float64, volume int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.price = price
+	m.volume += volume
+	m.value += price * float64(volume)
+	for _, o := range m.orders {
+		m.matchLocked(o)
+	}
+}
+
+func (m *simMarket) matchLocked(o *simOrder) {
+	if orderwatch.IsTerminal(o.update.Status) {
+		return
+	}
+	p := o.params
+	marketable := p.OrderType == kiteconnect.OrderTypeMarket ||
+		(p.TransactionType == kiteconnect.TransactionTypeBuy && m.price <= p.Price) ||
+		(p.TransactionType == kiteconnect.TransactionTypeSell && m.price >= p.Price)
+	if !marketable {
+		return
+	}
+	o.update.Status = orderwatch.StatusComplete
+	o.update.FilledQuantity = float64(p.Quantity)
+	o.update.PendingQuantity = 0
+	o.update.AveragePrice = m.price
+	m.volume += p.Quantity
+	m.value += m.price * float64(p.Quantity)
+}
+
+func (m *simMarket) PlaceOrder(params kiteconnect.OrderParams) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.placeErr != nil {
+		return "", m.placeErr
+	}
+	m.nextID++
+	id := fmt.Sprintf("child-%d", m.nextID)
+	m.placed = append(m.placed, params)
+	o := &simOrder{params: params, update: orderwatch.Update{OrderID: id, Status: "OPEN", PendingQuantity: float64(params.Quantity)}}
+	m.orders[id] = o
+	if m.rejectAll {
+		o.update.Status = orderwatch.StatusRejected
+		o.update.StatusMessage = "insufficient funds"
+		return id, nil
+	}
+	m.matchLocked(o)
+	return id, nil
+}
+
+func (m *simMarket) CancelOrder(orderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.orders[orderID]
+	if !ok || orderwatch.IsTerminal(o.update.Status) {
+		return errors.New("order cannot be cancelled")
+	}
+	o.update.Status = orderwatch.StatusCancelled
+	return nil
+}
+
+func (m *simMarket) OrderStatus(orderID string) (orderwatch.Update, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.orders[orderID]
+	if !ok {
+		return orderwatch.Update{}, errors.New("order not found")
+	}
+	return o.update, nil
+}
+
+func (m *simMarket) Quote(instrument string) (Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Quote{LastPrice: m.price, Volume: m.volume, AveragePrice: m.value / float64(m.volume)}, nil
+}
+
+func (m *simMarket) placedQuantities() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []int
+	for _, p := range m.placed {
+		out = append(out, p.Quantity)
+	}
+	return out
+}
+
+type closedCalendar struct{ closed bool }
+
+func (c *closedCalendar) IsOpen(string, time.Time) bool { return !c.closed }
+
+func newTestEngine() (*Engine, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	return New(Config{Logger: testLogger(), Clock: clock}), clock
+}
+
+func twapSpec(qty int) Spec {
+	return Spec{
+		UserID:          "AB1234",
+		Type:            TypeTWAP,
+		Exchange:        "NSE",
+		Tradingsymbol:   "INFY",
+		TransactionType: kiteconnect.TransactionTypeBuy,
+		Product:         kiteconnect.ProductCNC,
+		Quantity:        qty,
+		Duration:        5 * time.Minute,
+		Slices:          5,
+	}
+}
+
+func equalInts(a, b []int) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestSpecValidate(t *testing.T) {
+	spec := twapSpec(100)
+	spec.Slices = 0
+	if err := spec.Validate(); err != nil {
+		t.Fatalf("Expected valid spec, got %v", err)
+	}
+	if spec.Slices != 5 {
+		t.Errorf("Expected one slice per minute, got %d", spec.Slices)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Spec)
+		err    string
+	}{
+		{"side", func(s *Spec) { s.TransactionType = "HOLD" }, "transaction type"},
+		{"lot", func(s *Spec) { s.LotSize = 75 }, "not a multiple of the lot size"},
+		{"short", func(s *Spec) { s.Duration = 30 * time.Second }, "duration"},
+		{"too many slices", func(s *Spec) { s.Slices = 101 }, "slices must be between 1 and 100"},
+		{"slices too close", func(s *Spec) { s.Slices = 60 }, "10 seconds apart"},
+		{"rate", func(s *Spec) { s.Type = TypeParticipation; s.ParticipationRate = 0.8 }, "participation rate"},
+		{"type", func(s *Spec) { s.Type = "vwap" }, "algo must be"},
+		{"child cap", func(s *Spec) { s.LotSize = 50; s.MaxChildQuantity = 20 }, "below one lot"},
+	}
+	for _, tt := range tests {
+		spec := twapSpec(100)
+		tt.modify(&spec)
+		err := spec.Validate()
+		if !errors.Is(err, ErrInvalidSpec) || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestTWAP(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+
+	order, err := e.Place(market, twapSpec(100))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.FilledQuantity != 0 || len(order.Children) != 1 {
+		t.Fatalf("Expected the first slice to be placed on start, got %+v", order)
+	}
+
+	// Ticks within a slice release nothing more.
+	clock.Advance(30 * time.Second)
+	e.Tick()
+	if got := market.placedQuantities(); !equalInts(got, []int{20}) {
+		t.Fatalf("Expected one child of 20, got %v", got)
+	}
+
+	prices := []float64{1502, 1504, 1506, 1508}
+	for _, price := range prices {
+		market.trade(price, 5000)
+		clock.Advance(time.Minute)
+		e.Tick()
+	}
+	if got := market.placedQuantities(); !equalInts(got, []int{20, 20, 20, 20, 20}) {
+		t.Fatalf("Expected five children of 20, got %v", got)
+	}
+
+	clock.Advance(10 * time.Second)
+	e.Tick()
+	order, err = e.Get(order.ID, "AB1234")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.Status != StatusCompleted || order.FilledQuantity != 100 {
+		t.Fatalf("Expected completed with 100 filled, got %s with %d", order.Status, order.FilledQuantity)
+	}
+	if order.AveragePrice != 1504 {
+		t.Errorf("Expected average price 1504, got %v", order.AveragePrice)
+	}
+	if order.PercentComplete != 100 {
+		t.Errorf("Expected 100%% complete, got %v", order.PercentComplete)
+	}
+	// Volume is observed up to the last quote, before the final child filled.
+	if order.MarketVolume != 20080 {
+		t.Errorf("Expected market volume of 20080 since start, got %d", order.MarketVolume)
+	}
+}
+
+func TestTWAPWholeLots(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(21500, 100000)
+
+	spec := twapSpec(100)
+	spec.Exchange, spec.Tradingsymbol, spec.Product = "NFO", "NIFTY24JANFUT", kiteconnect.ProductNRML
+	spec.LotSize = 25
+	spec.Slices = 3
+	spec.Duration = 3 * time.Minute
+	if _, err := e.Place(market, spec); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for range 3 {
+		clock.Advance(time.Minute)
+		e.Tick()
+	}
+	if got := market.placedQuantities(); !equalInts(got, []int{25, 25, 50}) {
+		t.Errorf("Expected whole-lot children 25, 25, 50, got %v", got)
+	}
+}
+
+func TestMaxChildQuantity(t *testing.T) {
+	e, _ := newTestEngine()
+	market := newSimMarket(1500, 100000)
+
+	spec := twapSpec(1000)
+	spec.Slices = 1
+	spec.MaxChildQuantity = 300
+	if _, err := e.Place(market, spec); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := market.placedQuantities(); !equalInts(got, []int{300, 300, 300, 100}) {
+		t.Errorf("Expected children capped at 300, got %v", got)
+	}
+}
+
+func TestParticipation(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+
+	spec := twapSpec(300)
+	spec.Type = TypeParticipation
+	spec.Slices = 0
+	spec.ParticipationRate = 0.1
+	spec.Duration = time.Hour
+	order, err := e.Place(market, spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(order.Children) != 0 {
+		t.Fatalf("Expected no child before any volume trades, got %v", order.Children)
+	}
+
+	market.trade(1501, 1000)
+	clock.Advance(5 * time.Second)
+	e.Tick()
+	if got := market.placedQuantities(); !equalInts(got, []int{100}) {
+		t.Fatalf("Expected 10%% of 1000, got %v", got)
+	}
+
+	// Our own fills count towards the market volume, so the share we take
+	// never exceeds the rate.
+	for _, volume := range []int{900, 0, 0, 3000, 0, 2000, 0, 0} {
+		market.trade(1502, volume)
+		clock.Advance(5 * time.Second)
+		e.Tick()
+
+		placed := 0
+		for _, qty := range market.placedQuantities() {
+			placed += qty
+		}
+		market.mu.Lock()
+		traded := market.volume - 100000
+		market.mu.Unlock()
+		if float64(placed) > 0.1*float64(traded) {
+			t.Fatalf("Expected at most 10%% of %d traded, placed %d", traded, placed)
+		}
+	}
+
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.Status != StatusCompleted || order.FilledQuantity != 300 {
+		t.Fatalf("Expected completed with 300 filled, got %s with %d", order.Status, order.FilledQuantity)
+	}
+	if order.ParticipationRate <= 0 || order.ParticipationRate > 0.1 {
+		t.Errorf("Expected participation of at most 10%%, got %v", order.ParticipationRate)
+	}
+	if order.MarketVWAP == 0 {
+		t.Error("Expected the market VWAP to be reported")
+	}
+}
+
+func TestParticipationExpires(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+
+	spec := twapSpec(300)
+	spec.Type = TypeParticipation
+	spec.ParticipationRate = 0.1
+	spec.LimitPrice = 1500
+	spec.Duration = time.Minute
+	order, _ := e.Place(market, spec)
+
+	market.trade(1500, 1000)
+	clock.Advance(5 * time.Second)
+	e.Tick()
+
+	// The limit child fills, then the market moves away.
+	market.trade(1510, 2000)
+	clock.Advance(5 * time.Second)
+	e.Tick()
+	if got := market.placedQuantities(); !equalInts(got, []int{100}) {
+		t.Fatalf("Expected no release above the limit price, got %v", got)
+	}
+
+	clock.Advance(time.Minute)
+	e.Tick()
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.Status != StatusExpired || order.FilledQuantity != 100 {
+		t.Errorf("Expected expired with 100 filled, got %s with %d", order.Status, order.FilledQuantity)
+	}
+}
+
+func TestLimitPriceHoldsReleases(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1510, 100000)
+
+	spec := twapSpec(100)
+	spec.LimitPrice = 1500
+	order, _ := e.Place(market, spec)
+	if len(order.Children) != 0 {
+		t.Fatalf("Expected no child while the price is above the limit, got %v", order.Children)
+	}
+
+	clock.Advance(2 * time.Minute)
+	market.trade(1499, 1000)
+	e.Tick()
+	if got := market.placedQuantities(); !equalInts(got, []int{60}) {
+		t.Fatalf("Expected the missed slices to be caught up, got %v", got)
+	}
+	if market.placed[0].OrderType != kiteconnect.OrderTypeLimit || market.placed[0].Price != 1500 {
+		t.Errorf("Expected a LIMIT child at 1500, got %+v", market.placed[0])
+	}
+}
+
+func TestCancel(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+
+	spec := twapSpec(100)
+	spec.LimitPrice = 1500
+	order, _ := e.Place(market, spec)
+
+	// The price rises through the limit, leaving the next child working.
+	market.trade(1501, 1000)
+	clock.Advance(time.Minute)
+	market.trade(1500, 1000)
+	e.Tick()
+	market.trade(1501, 1000)
+	clock.Advance(time.Minute)
+	e.Tick()
+
+	if _, err := e.Cancel(order.ID, "XY0000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another user's algo to be hidden, got %v", err)
+	}
+	order, err := e.Cancel(order.ID, "AB1234")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.Status != StatusCancelled {
+		t.Errorf("Expected cancelled, got %s", order.Status)
+	}
+	for _, c := range order.Children {
+		if c.Status != orderwatch.StatusComplete && c.Status != orderwatch.StatusCancelled {
+			t.Errorf("Expected child %s to be finished, got %s", c.OrderID, c.Status)
+		}
+	}
+	if order.OpenQuantity != 0 {
+		t.Errorf("Expected no open quantity, got %d", order.OpenQuantity)
+	}
+	if _, err := e.Cancel(order.ID, "AB1234"); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected ErrFinished, got %v", err)
+	}
+
+	placed := len(market.placedQuantities())
+	clock.Advance(time.Minute)
+	e.Tick()
+	if len(market.placedQuantities()) != placed {
+		t.Error("Expected no children after cancel")
+	}
+}
+
+func TestRejectionsFail(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+	market.rejectAll = true
+
+	order, _ := e.Place(market, twapSpec(100))
+	for range 3 {
+		clock.Advance(15 * time.Second)
+		e.Tick()
+	}
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.Status != StatusFailed || !strings.Contains(order.Error, "insufficient funds") {
+		t.Errorf("Expected failed with the rejection reason, got %s: %s", order.Status, order.Error)
+	}
+}
+
+func TestPlaceErrorsFail(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+	market.placeErr = errors.New("network down")
+
+	order, _ := e.Place(market, twapSpec(100))
+	for range 2 {
+		clock.Advance(15 * time.Second)
+		e.Tick()
+	}
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.Status != StatusFailed || !strings.Contains(order.Error, "network down") {
+		t.Errorf("Expected failed after repeated errors, got %s: %s", order.Status, order.Error)
+	}
+}
+
+func TestClosedMarketPauses(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}
+	cal := &closedCalendar{closed: true}
+	e := New(Config{Logger: testLogger(), Clock: clock, Calendar: cal})
+	market := newSimMarket(1500, 100000)
+
+	e.Place(market, twapSpec(100))
+	clock.Advance(time.Minute)
+	e.Tick()
+	if got := len(market.placedQuantities()); got != 0 {
+		t.Fatalf("Expected no children while closed, got %d", got)
+	}
+
+	cal.closed = false
+	e.Tick()
+	if got := market.placedQuantities(); !equalInts(got, []int{40}) {
+		t.Errorf("Expected the due quantity once open, got %v", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	e, clock := newTestEngine()
+	market := newSimMarket(1500, 100000)
+
+	first, _ := e.Place(market, twapSpec(100))
+	clock.Advance(time.Second)
+	second, _ := e.Place(market, twapSpec(50))
+	other := twapSpec(100)
+	other.UserID = "XY0000"
+	e.Place(market, other)
+
+	list := e.List("AB1234")
+	if len(list) != 2 || list[0].ID != second.ID || list[1].ID != first.ID {
+		t.Errorf("Expected the user's algos newest first, got %+v", list)
+	}
+}
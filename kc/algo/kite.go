@@ -0,0 +1,58 @@
+package algo
+
+import (
+	"errors"
+	"fmt"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+// KiteClient is the part of the Kite Connect API used by KiteBroker.
+type KiteClient interface {
+	PlaceOrder(variety string, orderParams kiteconnect.OrderParams) (kiteconnect.OrderResponse, error)
+	CancelOrder(variety string, orderID string, parentOrderID *string) (kiteconnect.OrderResponse, error)
+	GetOrderHistory(orderID string) ([]kiteconnect.Order, error)
+	GetQuote(instruments ...string) (kiteconnect.Quote, error)
+}
+
+// KiteBroker places child orders as regular orders through a Kite client.
+type KiteBroker struct {
+	Client KiteClient
+}
+
+func (b KiteBroker) PlaceOrder(params kiteconnect.OrderParams) (string, error) {
+	resp, err := b.Client.PlaceOrder(kiteconnect.VarietyRegular, params)
+	if err != nil {
+		return "", err
+	}
+	return resp.OrderID, nil
+}
+
+func (b KiteBroker) CancelOrder(orderID string) error {
+	_, err := b.Client.CancelOrder(kiteconnect.VarietyRegular, orderID, nil)
+	return err
+}
+
+func (b KiteBroker) OrderStatus(orderID string) (orderwatch.Update, error) {
+	history, err := b.Client.GetOrderHistory(orderID)
+	if err != nil {
+		return orderwatch.Update{}, err
+	}
+	if len(history) == 0 {
+		return orderwatch.Update{}, errors.New("order has no history")
+	}
+	return orderwatch.FromOrder(history[len(history)-1]), nil
+}
+
+func (b KiteBroker) Quote(instrument string) (Quote, error) {
+	quotes, err := b.Client.GetQuote(instrument)
+	if err != nil {
+		return Quote{}, err
+	}
+	q, ok := quotes[instrument]
+	if !ok {
+		return Quote{}, fmt.Errorf("no quote for %s", instrument)
+	}
+	return Quote{LastPrice: q.LastPrice, Volume: q.Volume, AveragePrice: q.AveragePrice}, nil
+}
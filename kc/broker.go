@@ -0,0 +1,82 @@
+package kc
+
+import (
+	"net/http"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// KiteClient is the part of the Kite Connect API used by the server. It is
+// implemented by *kiteconnect.Client, and by fakes that stand in for Kite in
+// tests.
+type KiteClient interface {
+	// Session
+	GetLoginURL() string
+	GenerateSession(requestToken string, apiSecret string) (kiteconnect.UserSession, error)
+	SetAccessToken(accessToken string)
+	InvalidateAccessToken() (bool, error)
+
+	// User and portfolio
+	GetUserProfile() (kiteconnect.UserProfile, error)
+	GetUserMargins() (kiteconnect.AllMargins, error)
+	GetHoldings() (kiteconnect.Holdings, error)
+	GetPositions() (kiteconnect.Positions, error)
+	ConvertPosition(positionParams kiteconnect.ConvertPositionParams) (bool, error)
+
+	// Orders
+	GetOrders() (kiteconnect.Orders, error)
+	GetTrades() (kiteconnect.Trades, error)
+	GetOrderHistory(orderID string) ([]kiteconnect.Order, error)
+	GetOrderTrades(orderID string) ([]kiteconnect.Trade, error)
+	PlaceOrder(variety string, orderParams kiteconnect.OrderParams) (kiteconnect.OrderResponse, error)
+	ModifyOrder(variety string, orderID string, orderParams kiteconnect.OrderParams) (kiteconnect.OrderResponse, error)
+	CancelOrder(variety string, orderID string, parentOrderID *string) (kiteconnect.OrderResponse, error)
+
+	// Margins and charges
+	GetOrderMargins(marparam kiteconnect.GetMarginParams) ([]kiteconnect.OrderMargins, error)
+	GetBasketMargins(baskparam kiteconnect.GetBasketParams) (kiteconnect.BasketMargins, error)
+	GetOrderCharges(chargeParam kiteconnect.GetChargesParams) ([]kiteconnect.OrderCharges, error)
+
+	// GTTs
+	GetGTTs() (kiteconnect.GTTs, error)
+	GetGTT(triggerID int) (kiteconnect.GTT, error)
+	PlaceGTT(o kiteconnect.GTTParams) (kiteconnect.GTTResponse, error)
+	ModifyGTT(triggerID int, o kiteconnect.GTTParams) (kiteconnect.GTTResponse, error)
+	DeleteGTT(triggerID int) (kiteconnect.GTTResponse, error)
+
+	// Market data
+	GetQuote(instruments ...string) (kiteconnect.Quote, error)
+	GetLTP(instruments ...string) (kiteconnect.QuoteLTP, error)
+	GetOHLC(instruments ...string) (kiteconnect.QuoteOHLC, error)
+	GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]kiteconnect.HistoricalData, error)
+
+	// Mutual funds
+	GetMFInstruments() (kiteconnect.MFInstruments, error)
+	GetMFHoldings() (kiteconnect.MFHoldings, error)
+	GetMFAllottedISINs() (kiteconnect.MFAllottedISINs, error)
+	GetMFOrders() (kiteconnect.MFOrders, error)
+	PlaceMFOrder(orderParams kiteconnect.MFOrderParams) (kiteconnect.MFOrderResponse, error)
+	CancelMFOrder(orderID string) (kiteconnect.MFOrderResponse, error)
+	GetMFSIPs() (kiteconnect.MFSIPs, error)
+	PlaceMFSIP(sipParams kiteconnect.MFSIPParams) (kiteconnect.MFSIPResponse, error)
+	ModifyMFSIP(sipID string, sipParams kiteconnect.MFSIPModifyParams) (kiteconnect.MFSIPResponse, error)
+	CancelMFSIP(sipID string) (kiteconnect.MFSIPResponse, error)
+}
+
+var _ KiteClient = (*kiteconnect.Client)(nil)
+
+// KiteClientFactory creates the Kite client of a new session.
+type KiteClientFactory func(apiKey string) KiteClient
+
+// kiteconnectClients returns a factory of Kite Connect API clients. A nil
+// httpClient keeps the client library's own, with its request timeout.
+func kiteconnectClients(httpClient *http.Client) KiteClientFactory {
+	return func(apiKey string) KiteClient {
+		client := kiteconnect.New(apiKey)
+		if httpClient != nil {
+			client.SetHTTPClient(httpClient)
+		}
+		return client
+	}
+}
@@ -0,0 +1,409 @@
+// Package calendar knows when Indian exchanges trade: the regular session
+// timings of each exchange, weekends, a loadable list of trading holidays
+// and special sessions such as muhurat trading.
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IST is the timezone all exchange timings are expressed in.
+var IST = mustLoadIST()
+
+func mustLoadIST() *time.Location {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		return time.FixedZone("IST", 5*3600+1800)
+	}
+	return loc
+}
+
+// Phases of an exchange's trading day.
+const (
+	PhasePreOpen = "pre_open" // order entry before the normal session
+	PhaseOpen    = "open"     // normal trading session
+	PhaseClosed  = "closed"   // before the session, after it, or a non-trading day
+)
+
+// Session names.
+const (
+	SessionNormal  = "normal"
+	SessionMuhurat = "muhurat"
+)
+
+// Hours are the regular session timings of an exchange as offsets from
+// midnight IST. PreOpen is zero for exchanges without a pre-open session.
+type Hours struct {
+	PreOpen time.Duration
+	Open    time.Duration
+	Close   time.Duration
+	// WinterClose, when set, is the close while US daylight saving time is
+	// off, for exchanges following the US markets like MCX.
+	WinterClose time.Duration
+}
+
+// closeOn returns the close on day.
+func (h Hours) closeOn(day time.Time) time.Duration {
+	if h.WinterClose != 0 && !usDaylightSaving(day) {
+		return h.WinterClose
+	}
+	return h.Close
+}
+
+// usDaylightSaving reports whether US daylight saving time is on the day,
+// from the second Sunday of March to the first Sunday of November.
+func usDaylightSaving(day time.Time) bool {
+	sunday := func(month time.Month, nth int) time.Time {
+		first := time.Date(day.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+		offset := (7 - int(first.Weekday())) % 7
+		return first.AddDate(0, 0, offset+7*(nth-1))
+	}
+	date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return !date.Before(sunday(time.March, 2)) && date.Before(sunday(time.November, 1))
+}
+
+// DefaultHours are the regular session timings of the exchanges Kite
+// trades on.
+var DefaultHours = map[string]Hours{
+	"NSE": {PreOpen: 9 * time.Hour, Open: 9*time.Hour + 15*time.Minute, Close: 15*time.Hour + 30*time.Minute},
+	"BSE": {PreOpen: 9 * time.Hour, Open: 9*time.Hour + 15*time.Minute, Close: 15*time.Hour + 30*time.Minute},
+	"NFO": {PreOpen: 9 * time.Hour, Open: 9*time.Hour + 15*time.Minute, Close: 15*time.Hour + 30*time.Minute},
+	"BFO": {PreOpen: 9 * time.Hour, Open: 9*time.Hour + 15*time.Minute, Close: 15*time.Hour + 30*time.Minute},
+	"CDS": {Open: 9 * time.Hour, Close: 17 * time.Hour},
+	"BCD": {Open: 9 * time.Hour, Close: 17 * time.Hour},
+	"MCX": {Open: 9 * time.Hour, Close: 23*time.Hour + 30*time.Minute, WinterClose: 23*time.Hour + 55*time.Minute},
+}
+
+// Holiday is a day an exchange does not trade. A holiday without exchanges
+// applies to all of them.
+type Holiday struct {
+	Date        string   `json:"date"` // YYYY-MM-DD
+	Description string   `json:"description"`
+	Exchanges   []string `json:"exchanges,omitempty"`
+}
+
+// SpecialSession replaces the regular session of a day, or opens an
+// exchange on a weekend or holiday, e.g. muhurat trading on Diwali. Times
+// are HH:MM in IST. A special session without exchanges applies to all of
+// them.
+type SpecialSession struct {
+	Date        string   `json:"date"` // YYYY-MM-DD
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Exchanges   []string `json:"exchanges,omitempty"`
+	PreOpen     string   `json:"pre_open,omitempty"`
+	Open        string   `json:"open"`
+	Close       string   `json:"close"`
+}
+
+// Holidays is the loadable list of holidays and special sessions.
+type Holidays struct {
+	Holidays        []Holiday        `json:"holidays"`
+	SpecialSessions []SpecialSession `json:"special_sessions,omitempty"`
+}
+
+// Session is the trading session of an exchange on one day.
+type Session struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	PreOpen     time.Time `json:"pre_open,omitzero"`
+	Open        time.Time `json:"open"`
+	Close       time.Time `json:"close"`
+}
+
+// Status describes whether an exchange is trading at a point in time.
+type Status struct {
+	Exchange string    `json:"exchange"`
+	Phase    string    `json:"phase"`
+	Open     bool      `json:"open"`
+	Holiday  string    `json:"holiday,omitempty"`
+	Session  *Session  `json:"session,omitempty"` // today's session, if the exchange trades today
+	NextOpen time.Time `json:"next_open,omitzero"`
+	Time     time.Time `json:"time"`
+}
+
+// maxLookahead bounds the search for the next session.
+const maxLookahead = 31
+
+// Calendar answers trading-hours questions for each exchange. It is safe
+// for concurrent use and the holiday list can be replaced while in use.
+type Calendar struct {
+	mu       sync.RWMutex
+	hours    map[string]Hours
+	holidays map[string][]Holiday        // by date
+	special  map[string][]SpecialSession // by date
+}
+
+// New returns a calendar with the default session timings and no
+// holidays.
+func New() *Calendar {
+	return &Calendar{
+		hours:    DefaultHours,
+		holidays: make(map[string][]Holiday),
+		special:  make(map[string][]SpecialSession),
+	}
+}
+
+// LoadFile returns a calendar with the holidays in the JSON file at path.
+func LoadFile(path string) (*Calendar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening holidays file: %w", err)
+	}
+	defer f.Close()
+
+	c := New()
+	if err := c.Load(f); err != nil {
+		return nil, fmt.Errorf("error loading holidays file %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Load replaces the holidays and special sessions with the JSON list read
+// from r.
+func (c *Calendar) Load(r io.Reader) error {
+	var list Holidays
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return fmt.Errorf("error decoding holidays: %w", err)
+	}
+	return c.Set(list)
+}
+
+// Set replaces the holidays and special sessions.
+func (c *Calendar) Set(list Holidays) error {
+	holidays := make(map[string][]Holiday)
+	for _, h := range list.Holidays {
+		if _, err := time.Parse(time.DateOnly, h.Date); err != nil {
+			return fmt.Errorf("invalid holiday date %q", h.Date)
+		}
+		holidays[h.Date] = append(holidays[h.Date], h)
+	}
+
+	special := make(map[string][]SpecialSession)
+	for _, s := range list.SpecialSessions {
+		if err := s.validate(); err != nil {
+			return err
+		}
+		special[s.Date] = append(special[s.Date], s)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.holidays = holidays
+	c.special = special
+	return nil
+}
+
+func (s SpecialSession) validate() error {
+	if _, err := time.Parse(time.DateOnly, s.Date); err != nil {
+		return fmt.Errorf("invalid special session date %q", s.Date)
+	}
+	if s.Name == "" {
+		return fmt.Errorf("special session on %s needs a name", s.Date)
+	}
+	open, err := parseClock(s.Open)
+	if err != nil {
+		return fmt.Errorf("invalid open time of special session on %s: %w", s.Date, err)
+	}
+	close, err := parseClock(s.Close)
+	if err != nil {
+		return fmt.Errorf("invalid close time of special session on %s: %w", s.Date, err)
+	}
+	if close <= open {
+		return fmt.Errorf("special session on %s closes before it opens", s.Date)
+	}
+	if s.PreOpen != "" {
+		preOpen, err := parseClock(s.PreOpen)
+		if err != nil {
+			return fmt.Errorf("invalid pre-open time of special session on %s: %w", s.Date, err)
+		}
+		if preOpen >= open {
+			return fmt.Errorf("special session on %s has its pre-open after the open", s.Date)
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into an offset from midnight.
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, errors.New("expected HH:MM")
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Exchanges returns the exchanges with known session timings.
+func (c *Calendar) Exchanges() []string {
+	exchanges := make([]string, 0, len(c.hours))
+	for exchange := range c.hours {
+		exchanges = append(exchanges, exchange)
+	}
+	sort.Strings(exchanges)
+	return exchanges
+}
+
+// Known reports whether the calendar has session timings for exchange.
+func (c *Calendar) Known(exchange string) bool {
+	_, ok := c.hours[strings.ToUpper(exchange)]
+	return ok
+}
+
+// hoursOf returns the regular timings of exchange. Unknown exchanges trade
+// the equity session.
+func (c *Calendar) hoursOf(exchange string) Hours {
+	if h, ok := c.hours[exchange]; ok {
+		return h
+	}
+	return c.hours["NSE"]
+}
+
+func applies(exchanges []string, exchange string) bool {
+	return len(exchanges) == 0 || slices.Contains(exchanges, exchange)
+}
+
+// Holiday returns the description of the holiday of exchange on the day of
+// t, with ok false if the day is not a holiday. Weekends are not holidays.
+func (c *Calendar) Holiday(exchange string, day time.Time) (string, bool) {
+	exchange = strings.ToUpper(exchange)
+	date := day.In(IST).Format(time.DateOnly)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, h := range c.holidays[date] {
+		if applies(h.Exchanges, exchange) {
+			return h.Description, true
+		}
+	}
+	return "", false
+}
+
+// TradingSession returns the session of exchange on the day of t, with ok
+// false if the exchange does not trade that day. A special session takes
+// precedence over weekends, holidays and the regular timings.
+func (c *Calendar) TradingSession(exchange string, day time.Time) (Session, bool) {
+	exchange = strings.ToUpper(exchange)
+	day = day.In(IST)
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, IST)
+	date := midnight.Format(time.DateOnly)
+
+	c.mu.RLock()
+	for _, s := range c.special[date] {
+		if !applies(s.Exchanges, exchange) {
+			continue
+		}
+		c.mu.RUnlock()
+		// Times were validated by Set.
+		open, _ := parseClock(s.Open)
+		close, _ := parseClock(s.Close)
+		session := Session{
+			Name:        s.Name,
+			Description: s.Description,
+			Open:        midnight.Add(open),
+			Close:       midnight.Add(close),
+		}
+		if s.PreOpen != "" {
+			preOpen, _ := parseClock(s.PreOpen)
+			session.PreOpen = midnight.Add(preOpen)
+		}
+		return session, true
+	}
+	c.mu.RUnlock()
+
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return Session{}, false
+	}
+	if _, holiday := c.Holiday(exchange, day); holiday {
+		return Session{}, false
+	}
+
+	hours := c.hoursOf(exchange)
+	session := Session{
+		Name:  SessionNormal,
+		Open:  midnight.Add(hours.Open),
+		Close: midnight.Add(hours.closeOn(midnight)),
+	}
+	if hours.PreOpen != 0 {
+		session.PreOpen = midnight.Add(hours.PreOpen)
+	}
+	return session, true
+}
+
+// Session returns the trading hours of exchange on the day of t, with ok
+// false if the exchange does not trade that day.
+func (c *Calendar) Session(exchange string, day time.Time) (open, close time.Time, ok bool) {
+	session, ok := c.TradingSession(exchange, day)
+	return session.Open, session.Close, ok
+}
+
+// IsTradingDay reports whether exchange has a session on the day of t.
+func (c *Calendar) IsTradingDay(exchange string, day time.Time) bool {
+	_, ok := c.TradingSession(exchange, day)
+	return ok
+}
+
+// IsOpen reports whether exchange is in a trading session at t. Pre-open
+// does not count as open.
+func (c *Calendar) IsOpen(exchange string, t time.Time) bool {
+	session, ok := c.TradingSession(exchange, t)
+	return ok && !t.Before(session.Open) && t.Before(session.Close)
+}
+
+// Phase returns the phase of exchange's trading day at t.
+func (c *Calendar) Phase(exchange string, t time.Time) string {
+	session, ok := c.TradingSession(exchange, t)
+	switch {
+	case !ok || t.Before(session.PreOpen) || !t.Before(session.Close):
+		return PhaseClosed
+	case t.Before(session.Open):
+		if session.PreOpen.IsZero() {
+			return PhaseClosed
+		}
+		return PhasePreOpen
+	default:
+		return PhaseOpen
+	}
+}
+
+// NextOpen returns when the next session of exchange opens after t, or
+// the zero time if there is none within a month.
+func (c *Calendar) NextOpen(exchange string, t time.Time) time.Time {
+	day := t.In(IST)
+	for range maxLookahead {
+		if session, ok := c.TradingSession(exchange, day); ok && session.Open.After(t) {
+			return session.Open
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, IST)
+	}
+	return time.Time{}
+}
+
+// Status returns the trading status of exchange at t.
+func (c *Calendar) Status(exchange string, t time.Time) Status {
+	exchange = strings.ToUpper(exchange)
+	status := Status{
+		Exchange: exchange,
+		Phase:    c.Phase(exchange, t),
+		Time:     t.In(IST),
+	}
+	status.Open = status.Phase == PhaseOpen
+	if description, ok := c.Holiday(exchange, t); ok {
+		status.Holiday = description
+	}
+	if session, ok := c.TradingSession(exchange, t); ok {
+		status.Session = &session
+	}
+	if !status.Open {
+		status.NextOpen = c.NextOpen(exchange, t)
+	}
+	return status
+}
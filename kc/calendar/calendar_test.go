@@ -0,0 +1,181 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(date string, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, IST)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func loadTestCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	c, err := LoadFile("testdata/holidays.json")
+	if err != nil {
+		t.Fatalf("Expected no error loading holidays, got %v", err)
+	}
+	return c
+}
+
+// 2024-01-02 is a Tuesday.
+func TestIsOpen(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	tests := []struct {
+		exchange string
+		at       time.Time
+		open     bool
+	}{
+		{"NSE", at("2024-01-02", "09:15"), true},
+		{"NSE", at("2024-01-02", "09:14"), false},
+		{"NSE", at("2024-01-02", "15:30"), false},
+		{"nse", at("2024-01-02", "11:00"), true},
+		{"NSE", at("2024-01-06", "11:00"), false}, // Saturday
+		{"NSE", at("2024-01-26", "11:00"), false}, // holiday
+		{"MCX", at("2024-01-26", "11:00"), false}, // holiday on all exchanges
+		{"MCX", at("2024-01-02", "20:00"), true},
+		{"MCX", at("2024-01-02", "23:40"), true}, // US daylight saving time off
+		{"MCX", at("2024-01-02", "23:55"), false},
+		{"MCX", at("2024-07-02", "23:40"), false}, // US daylight saving time on
+		{"MCX", at("2024-03-08", "23:40"), true},  // the Friday before it starts
+		{"MCX", at("2024-03-11", "23:40"), false},
+		{"MCX", at("2024-10-31", "23:40"), false}, // the week before it ends
+		{"MCX", at("2024-11-04", "23:40"), true},
+		{"NFO", at("2024-01-02", "20:00"), false},
+		{"CDS", at("2024-01-02", "16:30"), true},
+		{"NSE", at("2024-11-15", "11:00"), false}, // holiday on NSE only
+		{"MCX", at("2024-11-15", "20:00"), true},
+		{"NSE", at("2024-11-01", "11:00"), false}, // Diwali
+		{"NSE", at("2024-11-01", "18:30"), true},  // muhurat
+		{"NFO", at("2024-11-01", "18:30"), true},
+		{"MCX", at("2024-11-01", "18:30"), false},
+	}
+	for _, tt := range tests {
+		if got := c.IsOpen(tt.exchange, tt.at); got != tt.open {
+			t.Errorf("Expected IsOpen(%s, %s) = %v, got %v", tt.exchange, tt.at, tt.open, got)
+		}
+	}
+}
+
+func TestPhase(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	tests := []struct {
+		exchange string
+		at       time.Time
+		phase    string
+	}{
+		{"NSE", at("2024-01-02", "08:59"), PhaseClosed},
+		{"NSE", at("2024-01-02", "09:00"), PhasePreOpen},
+		{"NSE", at("2024-01-02", "09:14"), PhasePreOpen},
+		{"NSE", at("2024-01-02", "09:15"), PhaseOpen},
+		{"NSE", at("2024-01-02", "15:30"), PhaseClosed},
+		{"NFO", at("2024-01-02", "09:05"), PhasePreOpen},
+		{"CDS", at("2024-01-02", "08:55"), PhaseClosed}, // no pre-open
+		{"NSE", at("2024-11-01", "17:50"), PhasePreOpen},
+	}
+	for _, tt := range tests {
+		if got := c.Phase(tt.exchange, tt.at); got != tt.phase {
+			t.Errorf("Expected Phase(%s, %s) = %s, got %s", tt.exchange, tt.at, tt.phase, got)
+		}
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	tests := []struct {
+		exchange string
+		at       time.Time
+		next     time.Time
+	}{
+		{"NSE", at("2024-01-02", "08:00"), at("2024-01-02", "09:15")},
+		{"NSE", at("2024-01-02", "10:00"), at("2024-01-03", "09:15")},
+		{"NSE", at("2024-01-05", "16:00"), at("2024-01-08", "09:15")}, // over the weekend
+		{"NSE", at("2024-01-25", "16:00"), at("2024-01-29", "09:15")}, // over the holiday and weekend
+		{"NSE", at("2024-11-01", "09:00"), at("2024-11-01", "18:00")}, // muhurat
+		{"MCX", at("2024-01-02", "23:45"), at("2024-01-03", "09:00")},
+	}
+	for _, tt := range tests {
+		if got := c.NextOpen(tt.exchange, tt.at); !got.Equal(tt.next) {
+			t.Errorf("Expected NextOpen(%s, %s) = %s, got %s", tt.exchange, tt.at, tt.next, got)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	c := loadTestCalendar(t)
+
+	status := c.Status("nse", at("2024-11-01", "11:00"))
+	if status.Exchange != "NSE" {
+		t.Errorf("Expected exchange NSE, got %s", status.Exchange)
+	}
+	if status.Open || status.Phase != PhaseClosed {
+		t.Errorf("Expected closed, got %s", status.Phase)
+	}
+	if status.Holiday != "Diwali Laxmi Pujan" {
+		t.Errorf("Expected Diwali holiday, got %q", status.Holiday)
+	}
+	if status.Session == nil || status.Session.Name != SessionMuhurat {
+		t.Fatalf("Expected muhurat session, got %+v", status.Session)
+	}
+	if !status.NextOpen.Equal(at("2024-11-01", "18:00")) {
+		t.Errorf("Expected next open at 18:00, got %s", status.NextOpen)
+	}
+
+	status = c.Status("NSE", at("2024-01-02", "10:00"))
+	if !status.Open || status.Phase != PhaseOpen {
+		t.Errorf("Expected open, got %s", status.Phase)
+	}
+	if status.Session == nil || status.Session.Name != SessionNormal {
+		t.Errorf("Expected normal session, got %+v", status.Session)
+	}
+	if !status.NextOpen.IsZero() {
+		t.Errorf("Expected no next open while open, got %s", status.NextOpen)
+	}
+
+	status = c.Status("NSE", at("2024-01-06", "10:00"))
+	if status.Session != nil || status.Holiday != "" {
+		t.Errorf("Expected no session or holiday on a weekend, got %+v", status)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"bad json", `{`, "error decoding holidays"},
+		{"bad date", `{"holidays": [{"date": "26-01-2024"}]}`, "invalid holiday date"},
+		{"no name", `{"special_sessions": [{"date": "2024-11-01", "open": "18:00", "close": "19:00"}]}`, "needs a name"},
+		{"bad time", `{"special_sessions": [{"date": "2024-11-01", "name": "muhurat", "open": "6pm", "close": "19:00"}]}`, "invalid open time"},
+		{"inverted", `{"special_sessions": [{"date": "2024-11-01", "name": "muhurat", "open": "19:00", "close": "18:00"}]}`, "closes before it opens"},
+	}
+	for _, tt := range tests {
+		c := New()
+		err := c.Load(strings.NewReader(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestSetReplacesHolidays(t *testing.T) {
+	c := loadTestCalendar(t)
+	if err := c.Set(Holidays{Holidays: []Holiday{{Date: "2024-01-03", Description: "Test"}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !c.IsTradingDay("NSE", at("2024-01-26", "00:00")) {
+		t.Error("Expected the old holiday to be replaced")
+	}
+	if c.IsTradingDay("NSE", at("2024-01-03", "00:00")) {
+		t.Error("Expected the new holiday to apply")
+	}
+}
@@ -0,0 +1,126 @@
+package history
+
+import (
+	"math"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+)
+
+// continuous returns the back-adjusted continuous day series of the near
+// month futures of inst's underlying.
+//
+// Kite stitches the expired near month contracts into the series of the
+// nearest live one, and the expired next month contracts into the series
+// of the one after it, both unadjusted. On the expiry day of a near month
+// contract the second series holds the contract the first rolls to, so
+// the gap between their closes that day is the roll gap, which is added
+// to every earlier candle.
+//
+// Expired contracts aren't listed, and the weekday they expire on has
+// changed over the years, so the rolls are found in the series themselves.
+func (s *Service) continuous(client Client, inst instruments.Instrument, interval string, from, to time.Time, oi bool) ([]kiteconnect.HistoricalData, []Adjustment, error) {
+	if inst.InstrumentType != "FUT" {
+		return nil, nil, ErrNotFuture
+	}
+	if interval != "day" {
+		return nil, nil, ErrNotDaily
+	}
+
+	contracts := s.instruments.Search(instruments.SearchOptions{
+		Name:           inst.Name,
+		Exchange:       inst.Exchange,
+		InstrumentType: "FUT",
+		ExpiryFrom:     day(now()),
+	})
+	if len(contracts) < 2 {
+		return nil, nil, ErrNoNextContract
+	}
+	near, next := contracts[0].Instrument, contracts[1].Instrument
+
+	// Both series are fetched with open interest to find the rolls by
+	candles, err := client.GetHistoricalData(int(near.InstrumentToken), interval, from, to, true, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	nextCandles, err := client.GetHistoricalData(int(next.InstrumentToken), interval, from, to, true, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	nextByDay := make(map[time.Time]kiteconnect.HistoricalData, len(nextCandles))
+	for _, c := range nextCandles {
+		nextByDay[day(c.Date.Time)] = c
+	}
+
+	adjustments := rolls(candles, nextByDay)
+	for _, a := range adjustments {
+		adjustPrices(candles, a.Date, func(c *kiteconnect.HistoricalData) {
+			c.Open += a.Gap
+			c.High += a.Gap
+			c.Low += a.Gap
+			c.Close += a.Gap
+		})
+	}
+	if !oi {
+		for i := range candles {
+			candles[i].OI = 0
+		}
+	}
+	return candles, adjustments, nil
+}
+
+// rolls finds the days the stitched near month series moves to the next
+// contract: the days it carries on from the next month series of the day
+// before rather than from itself, as the next month series moves on to the
+// contract after. Open interest tells the contracts apart far better than
+// prices do, so it is compared where both series have it, and closes
+// otherwise. Monthly contracts expire in the last days of their month, so
+// the clearest such day in those of each month is taken.
+func rolls(candles []kiteconnect.HistoricalData, next map[time.Time]kiteconnect.HistoricalData) []Adjustment {
+	var out []Adjustment
+	var month time.Month // of the expiry the last roll follows
+	var best float64
+	for i := 1; i < len(candles); i++ {
+		prev, cur := candles[i-1], candles[i]
+		expiry := day(prev.Date.Time)
+		if expiry.AddDate(0, 0, 10).Month() == expiry.Month() {
+			continue
+		}
+		rolledTo, ok := next[expiry]
+		if !ok {
+			continue
+		}
+		after, ok := next[day(cur.Date.Time)]
+		if !ok {
+			continue
+		}
+
+		value := func(c kiteconnect.HistoricalData) float64 { return c.Close }
+		if prev.OI > 0 && cur.OI > 0 && rolledTo.OI > 0 && after.OI > 0 {
+			value = func(c kiteconnect.HistoricalData) float64 { return float64(c.OI) }
+		}
+		stayed := math.Abs(value(cur) - value(prev))
+		rolled := math.Abs(value(cur) - value(rolledTo))
+		if rolled >= stayed || math.Abs(value(after)-value(rolledTo)) <= rolled {
+			continue
+		}
+
+		roll := Adjustment{
+			Date: day(cur.Date.Time),
+			Type: ActionRoll,
+			Gap:  rolledTo.Close - prev.Close,
+		}
+		// Keep the clearest roll of the month. Candles are at most a few
+		// days apart, so the months of the expiries only repeat in turn.
+		score := stayed - rolled
+		if len(out) > 0 && expiry.Month() == month {
+			if score > best {
+				out[len(out)-1], best = roll, score
+			}
+			continue
+		}
+		out, month, best = append(out, roll), expiry.Month(), score
+	}
+	return out
+}
@@ -0,0 +1,291 @@
+// Package history serves historical candles fit for analysis and
+// backtesting: equity series adjusted for splits and bonuses from a
+// loadable list of corporate actions, and futures series stitched across
+// expired contracts and back-adjusted at every roll.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+)
+
+// Types of corporate actions and adjustments.
+const (
+	ActionSplit = "split"
+	ActionBonus = "bonus"
+	ActionRoll  = "roll" // a continuous futures series moving to the next contract
+)
+
+var (
+	ErrNotFuture        = errors.New("continuous series are only available for futures")
+	ErrNotDaily         = errors.New("continuous series are only available for day candles")
+	ErrNoNextContract   = errors.New("no next month contract to measure the roll gaps against")
+	ErrUnknownContract  = errors.New("unknown instrument token")
+	errInvalidRatio     = errors.New("expected a ratio like 1:5")
+	errNonPositiveRatio = errors.New("ratio must be positive")
+)
+
+// Client is the part of the Kite Connect API the history service reads
+// candles from.
+type Client interface {
+	GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]kiteconnect.HistoricalData, error)
+}
+
+// CorporateAction is a split or bonus issue changing the number of shares
+// of an equity from its ex-date. For a split, Ratio is the shares after
+// the split for each share before it, 5:1 for a split of one share into
+// five. For a bonus, it is the bonus shares for each held, 1:1 for one
+// bonus share for every share held. An action without an exchange
+// applies to the equity on all of them.
+type CorporateAction struct {
+	Exchange      string `json:"exchange,omitempty"`
+	Tradingsymbol string `json:"tradingsymbol"`
+	ExDate        string `json:"ex_date"` // YYYY-MM-DD
+	Type          string `json:"type"`
+	Ratio         string `json:"ratio"`
+	Description   string `json:"description,omitempty"`
+}
+
+// CorporateActions is the loadable list of corporate actions.
+type CorporateActions struct {
+	Actions []CorporateAction `json:"actions"`
+}
+
+// Factor returns the number of shares after the action for each share
+// before it, which prices before the ex-date are divided by.
+func (a CorporateAction) Factor() (float64, error) {
+	num, den, ok := strings.Cut(a.Ratio, ":")
+	if !ok {
+		return 0, errInvalidRatio
+	}
+	n, err1 := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	d, err2 := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err1 != nil || err2 != nil {
+		return 0, errInvalidRatio
+	}
+	if n <= 0 || d <= 0 {
+		return 0, errNonPositiveRatio
+	}
+	switch a.Type {
+	case ActionSplit:
+		return n / d, nil
+	case ActionBonus:
+		return (n + d) / d, nil
+	default:
+		return 0, fmt.Errorf("unknown corporate action type %q", a.Type)
+	}
+}
+
+func (a CorporateAction) validate() error {
+	if a.Tradingsymbol == "" {
+		return errors.New("corporate action needs a tradingsymbol")
+	}
+	if _, err := time.Parse(time.DateOnly, a.ExDate); err != nil {
+		return fmt.Errorf("invalid ex-date %q of %s", a.ExDate, a.Tradingsymbol)
+	}
+	if _, err := a.Factor(); err != nil {
+		return fmt.Errorf("invalid %s of %s on %s: %w", a.Type, a.Tradingsymbol, a.ExDate, err)
+	}
+	return nil
+}
+
+// Adjustment is a change applied to the candles before its date: prices
+// divided by Factor for splits and bonuses, or Gap added for rolls.
+type Adjustment struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	Factor      float64   `json:"factor,omitempty"`
+	Gap         float64   `json:"gap,omitempty"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Request selects the candles of an instrument. From and To are read as
+// IST wall clock times, like the Kite Connect API does.
+type Request struct {
+	InstrumentToken uint32
+	Interval        string
+	From            time.Time
+	To              time.Time
+	OI              bool
+
+	// Continuous stitches the near month contracts of the future's
+	// underlying into one back-adjusted series.
+	Continuous bool
+
+	// Unadjusted skips the corporate action adjustments.
+	Unadjusted bool
+}
+
+// Series is the candles of an instrument and the adjustments made to them.
+type Series struct {
+	InstrumentToken uint32                       `json:"instrument_token"`
+	Exchange        string                       `json:"exchange,omitempty"`
+	Tradingsymbol   string                       `json:"tradingsymbol,omitempty"`
+	Interval        string                       `json:"interval"`
+	Continuous      bool                         `json:"continuous,omitempty"`
+	Candles         []kiteconnect.HistoricalData `json:"candles"`
+	Adjustments     []Adjustment                 `json:"adjustments,omitempty"`
+}
+
+// Config holds configuration for creating a history service.
+type Config struct {
+	Instruments *instruments.Manager // required
+}
+
+// Service fetches and adjusts historical candles. It is safe for
+// concurrent use and the corporate actions can be replaced while in use.
+type Service struct {
+	instruments *instruments.Manager
+
+	mu      sync.RWMutex
+	actions map[string][]CorporateAction // by tradingsymbol, earliest first
+}
+
+// New returns a history service without corporate actions.
+func New(cfg Config) *Service {
+	return &Service{
+		instruments: cfg.Instruments,
+		actions:     make(map[string][]CorporateAction),
+	}
+}
+
+// LoadFile replaces the corporate actions with the JSON list in the file
+// at path.
+func (s *Service) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening corporate actions file: %w", err)
+	}
+	defer f.Close()
+
+	if err := s.Load(f); err != nil {
+		return fmt.Errorf("error loading corporate actions file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load replaces the corporate actions with the JSON list read from r.
+func (s *Service) Load(r io.Reader) error {
+	var list CorporateActions
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return fmt.Errorf("error decoding corporate actions: %w", err)
+	}
+	return s.Set(list)
+}
+
+// Set replaces the corporate actions.
+func (s *Service) Set(list CorporateActions) error {
+	actions := make(map[string][]CorporateAction)
+	for _, a := range list.Actions {
+		if err := a.validate(); err != nil {
+			return err
+		}
+		a.Exchange = strings.ToUpper(a.Exchange)
+		a.Tradingsymbol = strings.ToUpper(a.Tradingsymbol)
+		actions[a.Tradingsymbol] = append(actions[a.Tradingsymbol], a)
+	}
+	for _, list := range actions {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ExDate < list[j].ExDate })
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = actions
+	return nil
+}
+
+// Actions returns the corporate actions of an equity on an exchange,
+// earliest first.
+func (s *Service) Actions(exchange, tradingsymbol string) []CorporateAction {
+	exchange = strings.ToUpper(exchange)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []CorporateAction
+	for _, a := range s.actions[strings.ToUpper(tradingsymbol)] {
+		if a.Exchange == "" || a.Exchange == exchange {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Candles fetches the candles of req. Equity series are adjusted for the
+// corporate actions that went ex since the start of the range, so they
+// compare with today's prices. Instruments missing from
+// the loaded instruments are returned as Kite serves them.
+func (s *Service) Candles(client Client, req Request) (Series, error) {
+	from, to := req.From.In(calendar.IST), req.To.In(calendar.IST)
+	series := Series{InstrumentToken: req.InstrumentToken, Interval: req.Interval}
+
+	inst, err := s.instruments.GetByInstToken(req.InstrumentToken)
+	if err != nil {
+		if req.Continuous {
+			return Series{}, ErrUnknownContract
+		}
+		series.Candles, err = client.GetHistoricalData(int(req.InstrumentToken), req.Interval, from, to, false, req.OI)
+		return series, err
+	}
+	series.Exchange, series.Tradingsymbol = inst.Exchange, inst.Tradingsymbol
+
+	if req.Continuous {
+		series.Continuous = true
+		series.Candles, series.Adjustments, err = s.continuous(client, inst, req.Interval, from, to, req.OI)
+		return series, err
+	}
+
+	series.Candles, err = client.GetHistoricalData(int(req.InstrumentToken), req.Interval, from, to, false, req.OI)
+	if err != nil || req.Unadjusted {
+		return series, err
+	}
+	for _, a := range s.Actions(inst.Exchange, inst.Tradingsymbol) {
+		// Validated by Set
+		exDate, _ := time.ParseInLocation(time.DateOnly, a.ExDate, calendar.IST)
+		factor, _ := a.Factor()
+		// Candles are adjusted to today's shares, so actions after the
+		// range still apply but those yet to go ex don't
+		if !exDate.After(from) || exDate.After(now()) {
+			continue
+		}
+		adjustPrices(series.Candles, exDate, func(c *kiteconnect.HistoricalData) {
+			c.Open /= factor
+			c.High /= factor
+			c.Low /= factor
+			c.Close /= factor
+			c.Volume = int(math.Round(float64(c.Volume) * factor))
+		})
+		series.Adjustments = append(series.Adjustments, Adjustment{Date: exDate, Type: a.Type, Factor: factor, Description: a.Description})
+	}
+	return series, nil
+}
+
+// adjustPrices applies adjust to the candles of the days before date.
+func adjustPrices(candles []kiteconnect.HistoricalData, date time.Time, adjust func(*kiteconnect.HistoricalData)) {
+	for i := range candles {
+		if day(candles[i].Date.Time).Before(date) {
+			adjust(&candles[i])
+		}
+	}
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+// day returns the start of the IST day of t.
+func day(t time.Time) time.Time {
+	y, m, d := t.In(calendar.IST).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, calendar.IST)
+}
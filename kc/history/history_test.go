@@ -0,0 +1,324 @@
+package history
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+)
+
+// fakeClient serves the day candles set per instrument token.
+type fakeClient struct {
+	candles    map[int][]kiteconnect.HistoricalData
+	continuous map[int]bool // whether the last fetch of a token asked for a continuous series
+}
+
+func (c *fakeClient) GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]kiteconnect.HistoricalData, error) {
+	c.continuous[instrumentToken] = continuous
+	var out []kiteconnect.HistoricalData
+	for _, candle := range c.candles[instrumentToken] {
+		if !candle.Date.Before(fromDate) && !candle.Date.After(toDate) {
+			out = append(out, candle)
+		}
+	}
+	return out, nil
+}
+
+func date(s string) time.Time {
+	d, _ := time.ParseInLocation(time.DateOnly, s, calendar.IST)
+	return d
+}
+
+// dailyCandles returns a candle on each weekday from first to last closing
+// at price(day), skipping holidays.
+func dailyCandles(first, last string, holidays []string, price func(time.Time) float64) []kiteconnect.HistoricalData {
+	var out []kiteconnect.HistoricalData
+	for d := date(first); !d.After(date(last)); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday || strings.Contains(strings.Join(holidays, ","), d.Format(time.DateOnly)) {
+			continue
+		}
+		p := price(d)
+		out = append(out, kiteconnect.HistoricalData{Date: models.Time{Time: d}, Open: p, High: p + 2, Low: p - 2, Close: p, Volume: 1000})
+	}
+	return out
+}
+
+func newTestService(t *testing.T, today string) (*Service, *fakeClient) {
+	t.Helper()
+	restore := now
+	now = func() time.Time { return date(today).Add(10 * time.Hour) }
+	t.Cleanup(func() { now = restore })
+
+	testData := map[uint32]*instruments.Instrument{
+		408065: {ID: "NSE:INFY", InstrumentToken: 408065, Tradingsymbol: "INFY", Name: "INFOSYS", Exchange: "NSE", InstrumentType: "EQ"},
+		500001: {ID: "BSE:INFY", InstrumentToken: 500001, Tradingsymbol: "INFY", Name: "INFOSYS", Exchange: "BSE", InstrumentType: "EQ"},
+		1001:   {ID: "NFO:NIFTY25MARFUT", InstrumentToken: 1001, Tradingsymbol: "NIFTY25MARFUT", Name: "NIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-03-27"},
+		1002:   {ID: "NFO:NIFTY25APRFUT", InstrumentToken: 1002, Tradingsymbol: "NIFTY25APRFUT", Name: "NIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-04-24"},
+		1003:   {ID: "NFO:NIFTY25MAYFUT", InstrumentToken: 1003, Tradingsymbol: "NIFTY25MAYFUT", Name: "NIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-05-29"},
+		3001:   {ID: "NFO:BANKNIFTY25OCTFUT", InstrumentToken: 3001, Tradingsymbol: "BANKNIFTY25OCTFUT", Name: "BANKNIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-10-28"},
+		3002:   {ID: "NFO:BANKNIFTY25NOVFUT", InstrumentToken: 3002, Tradingsymbol: "BANKNIFTY25NOVFUT", Name: "BANKNIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-11-25"},
+		2001:   {ID: "NFO:FINNIFTY25MARFUT", InstrumentToken: 2001, Tradingsymbol: "FINNIFTY25MARFUT", Name: "FINNIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-03-27"},
+	}
+	updateConfig := instruments.DefaultUpdateConfig()
+	updateConfig.EnableScheduler = false
+	im, err := instruments.New(instruments.Config{
+		UpdateConfig: updateConfig,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		TestData:     testData,
+	})
+	if err != nil {
+		t.Fatalf("Expected instruments to load, got %v", err)
+	}
+	t.Cleanup(im.Shutdown)
+
+	client := &fakeClient{candles: make(map[int][]kiteconnect.HistoricalData), continuous: make(map[int]bool)}
+	return New(Config{Instruments: im}), client
+}
+
+func TestCorporateActionFactor(t *testing.T) {
+	tests := []struct {
+		action   CorporateAction
+		expected float64
+		err      string
+	}{
+		{CorporateAction{Type: ActionSplit, Ratio: "5:1"}, 5, ""},
+		{CorporateAction{Type: ActionSplit, Ratio: "10 : 4"}, 2.5, ""},
+		{CorporateAction{Type: ActionBonus, Ratio: "1:1"}, 2, ""},
+		{CorporateAction{Type: ActionBonus, Ratio: "1:2"}, 1.5, ""},
+		{CorporateAction{Type: ActionSplit, Ratio: "5"}, 0, "expected a ratio"},
+		{CorporateAction{Type: ActionBonus, Ratio: "0:1"}, 0, "must be positive"},
+		{CorporateAction{Type: "dividend", Ratio: "1:1"}, 0, "unknown corporate action"},
+	}
+	for _, tt := range tests {
+		factor, err := tt.action.Factor()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected an error containing %q for %+v, got %v", tt.err, tt.action, err)
+			}
+			continue
+		}
+		if err != nil || factor != tt.expected {
+			t.Errorf("Expected factor %v for %+v, got %v (%v)", tt.expected, tt.action, factor, err)
+		}
+	}
+}
+
+func TestLoadCorporateActions(t *testing.T) {
+	s, _ := newTestService(t, "2025-03-10")
+	if err := s.LoadFile("testdata/corporate_actions.json"); err != nil {
+		t.Fatalf("Expected the corporate actions to load, got %v", err)
+	}
+
+	actions := s.Actions("nse", "INFY")
+	if len(actions) != 2 || actions[0].ExDate != "2025-01-03" || actions[1].ExDate != "2025-01-08" {
+		t.Errorf("Expected the bonus and split of INFY on NSE, earliest first, got %+v", actions)
+	}
+	if actions := s.Actions("BSE", "INFY"); len(actions) != 1 || actions[0].Type != ActionBonus {
+		t.Errorf("Expected only the bonus of INFY on BSE, got %+v", actions)
+	}
+
+	for _, invalid := range []string{
+		`{"actions": [{"tradingsymbol": "INFY", "ex_date": "08-01-2025", "type": "split", "ratio": "5:1"}]}`,
+		`{"actions": [{"tradingsymbol": "INFY", "ex_date": "2025-01-08", "type": "split", "ratio": "five"}]}`,
+		`{"actions": [{"ex_date": "2025-01-08", "type": "split", "ratio": "5:1"}]}`,
+		`{"actions": [`,
+	} {
+		if err := s.Load(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error loading %s", invalid)
+		}
+	}
+	if len(s.Actions("NSE", "INFY")) != 2 {
+		t.Error("Expected a failed load to keep the loaded corporate actions")
+	}
+	if err := s.LoadFile("testdata/missing.json"); err == nil {
+		t.Error("Expected an error loading a missing file")
+	}
+}
+
+func TestCandlesAdjustedForCorporateActions(t *testing.T) {
+	s, client := newTestService(t, "2025-01-10")
+	if err := s.LoadFile("testdata/corporate_actions.json"); err != nil {
+		t.Fatalf("Expected the corporate actions to load, got %v", err)
+	}
+	// 1000 before the bonus, 500 until the split and 100 after it
+	client.candles[408065] = dailyCandles("2025-01-01", "2025-01-10", nil, func(d time.Time) float64 {
+		switch {
+		case d.Before(date("2025-01-03")):
+			return 1000
+		case d.Before(date("2025-01-08")):
+			return 500
+		}
+		return 100
+	})
+	client.candles[500001] = client.candles[408065]
+
+	request := Request{InstrumentToken: 408065, Interval: "day", From: date("2025-01-01"), To: date("2025-01-10")}
+	series, err := s.Candles(client, request)
+	if err != nil {
+		t.Fatalf("Expected candles, got %v", err)
+	}
+	if series.Exchange != "NSE" || series.Tradingsymbol != "INFY" || len(series.Adjustments) != 2 {
+		t.Errorf("Expected the bonus and split adjustments of NSE:INFY, got %+v", series)
+	}
+	for _, c := range series.Candles {
+		if math.Abs(c.Close-100) > 1e-9 {
+			t.Errorf("Expected %s to close at 100 adjusted, got %v", c.Date.Format(time.DateOnly), c.Close)
+		}
+	}
+	if first := series.Candles[0]; first.Volume != 10000 || math.Abs(first.High-1002.0/10) > 1e-9 {
+		t.Errorf("Expected the first candle's volume scaled by 10 and prices divided by it, got %+v", first)
+	}
+	if client.continuous[408065] {
+		t.Error("Expected the equity to be fetched without the continuous flag")
+	}
+
+	// Only the bonus applies on BSE
+	request.InstrumentToken = 500001
+	if series, _ := s.Candles(client, request); len(series.Adjustments) != 1 || series.Candles[0].Close != 500 || series.Candles[len(series.Candles)-1].Close != 100 {
+		t.Errorf("Expected only the bonus adjustment on BSE, got %+v", series.Adjustments)
+	}
+
+	request.InstrumentToken, request.Unadjusted = 408065, true
+	if series, _ := s.Candles(client, request); len(series.Adjustments) != 0 || series.Candles[0].Close != 1000 {
+		t.Errorf("Expected unadjusted candles, got %+v", series.Adjustments)
+	}
+
+	// Candles are adjusted to today's shares, before the split went ex
+	s2, client2 := newTestService(t, "2025-01-07")
+	if err := s2.Set(CorporateActions{Actions: []CorporateAction{{Tradingsymbol: "INFY", ExDate: "2025-01-08", Type: ActionSplit, Ratio: "5:1"}}}); err != nil {
+		t.Fatalf("Expected the split to be set, got %v", err)
+	}
+	client2.candles[408065] = client.candles[408065]
+	if series, _ := s2.Candles(client2, Request{InstrumentToken: 408065, Interval: "day", From: date("2025-01-01"), To: date("2025-01-07")}); len(series.Adjustments) != 0 {
+		t.Errorf("Expected no adjustment for a split yet to go ex, got %+v", series.Adjustments)
+	}
+
+	// Unknown instruments are served as they are
+	client.candles[7] = client.candles[408065]
+	if series, err := s.Candles(client, Request{InstrumentToken: 7, Interval: "day", From: date("2025-01-01"), To: date("2025-01-10")}); err != nil || series.Candles[0].Close != 1000 {
+		t.Errorf("Expected the unknown instrument's candles unadjusted, got %v", err)
+	}
+}
+
+func TestContinuousFutures(t *testing.T) {
+	s, client := newTestService(t, "2025-03-10")
+
+	// The index stays at 100 and each contract trades 10 over the one
+	// expiring before it. The January contract expires on the last
+	// Thursday, the February one a day early for the holiday.
+	price := func(d time.Time, months int) float64 {
+		contract := 0
+		if d.After(date("2025-01-30")) {
+			contract++
+		}
+		if d.After(date("2025-02-26")) {
+			contract++
+		}
+		return 100 + float64(contract+months)*10
+	}
+	holidays := []string{"2025-02-27"}
+	client.candles[1001] = dailyCandles("2025-01-20", "2025-03-07", holidays, func(d time.Time) float64 { return price(d, 0) })
+	client.candles[1002] = dailyCandles("2025-01-20", "2025-03-07", holidays, func(d time.Time) float64 { return price(d, 1) })
+
+	// Asking for any contract of the underlying stitches the near month
+	series, err := s.Candles(client, Request{InstrumentToken: 1002, Interval: "day", From: date("2025-01-20"), To: date("2025-03-07"), Continuous: true})
+	if err != nil {
+		t.Fatalf("Expected a continuous series, got %v", err)
+	}
+	if !client.continuous[1001] || !client.continuous[1002] {
+		t.Error("Expected both series fetched with the continuous flag")
+	}
+	expected := []string{"2025-01-31", "2025-02-28"}
+	if len(series.Adjustments) != len(expected) {
+		t.Fatalf("Expected rolls after %v, got %+v", expected, series.Adjustments)
+	}
+	for i, a := range series.Adjustments {
+		if a.Type != ActionRoll || a.Date.Format(time.DateOnly) != expected[i] || a.Gap != 10 {
+			t.Errorf("Expected a roll gap of 10 from %s, got %+v", expected[i], a)
+		}
+	}
+	for _, c := range series.Candles {
+		if c.Close != 120 || c.High != 122 {
+			t.Errorf("Expected %s back-adjusted to the current contract's 120, got %v", c.Date.Format(time.DateOnly), c.Close)
+		}
+	}
+
+	for _, tt := range []struct {
+		request Request
+		err     error
+	}{
+		{Request{InstrumentToken: 408065, Interval: "day", Continuous: true}, ErrNotFuture},
+		{Request{InstrumentToken: 1001, Interval: "minute", Continuous: true}, ErrNotDaily},
+		{Request{InstrumentToken: 2001, Interval: "day", Continuous: true}, ErrNoNextContract},
+		{Request{InstrumentToken: 7, Interval: "day", Continuous: true}, ErrUnknownContract},
+	} {
+		if _, err := s.Candles(client, tt.request); err != tt.err {
+			t.Errorf("Expected %v for %+v, got %v", tt.err, tt.request, err)
+		}
+	}
+}
+
+func TestContinuousFuturesAcrossExpiryDayChange(t *testing.T) {
+	s, client := newTestService(t, "2025-10-10")
+
+	// Monthly contracts expired on the last Thursday until August and on
+	// the last Tuesday since September. Each trades 10 over the one
+	// expiring before it, and the index swings by more than that each
+	// day, so only the open interest tells the contracts apart: each
+	// contract's builds up until the week before its expiry.
+	expiries := []time.Time{date("2025-07-31"), date("2025-08-28"), date("2025-09-30"), date("2025-10-28"), date("2025-11-25")}
+	contract := func(d time.Time, months int) int {
+		k := 0
+		for k < len(expiries) && d.After(expiries[k]) {
+			k++
+		}
+		return k + months
+	}
+	swing := func(d time.Time) float64 {
+		if d.YearDay()%2 == 0 {
+			return 15
+		}
+		return -15
+	}
+	series := func(months int) []kiteconnect.HistoricalData {
+		candles := dailyCandles("2025-07-14", "2025-10-09", nil, func(d time.Time) float64 {
+			return 100 + float64(contract(d, months))*10 + swing(d)
+		})
+		for i := range candles {
+			d := day(candles[i].Date.Time)
+			toExpiry := expiries[contract(d, months)].Sub(d).Hours() / 24
+			candles[i].OI = int(max(1000, 100000-3000*math.Abs(toExpiry-7)))
+		}
+		return candles
+	}
+	client.candles[3001], client.candles[3002] = series(0), series(1)
+
+	got, err := s.Candles(client, Request{InstrumentToken: 3001, Interval: "day", From: date("2025-07-14"), To: date("2025-10-09"), Continuous: true})
+	if err != nil {
+		t.Fatalf("Expected a continuous series, got %v", err)
+	}
+	expected := []string{"2025-08-01", "2025-08-29", "2025-10-01"}
+	if len(got.Adjustments) != len(expected) {
+		t.Fatalf("Expected rolls after %v, got %+v", expected, got.Adjustments)
+	}
+	for i, a := range got.Adjustments {
+		if a.Date.Format(time.DateOnly) != expected[i] || a.Gap != 10 {
+			t.Errorf("Expected a roll gap of 10 from %s, got %+v", expected[i], a)
+		}
+	}
+	for _, c := range got.Candles {
+		if c.Close != 130+swing(day(c.Date.Time)) {
+			t.Errorf("Expected %s back-adjusted to the current contract, got %v", c.Date.Format(time.DateOnly), c.Close)
+		}
+		if c.OI != 0 {
+			t.Errorf("Expected the open interest left out when not asked for, got %d on %s", c.OI, c.Date.Format(time.DateOnly))
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package httpfixture records HTTP exchanges with the Kite API into fixture
+// files and replays them. Recorded requests and responses are sanitized:
+// credentials and personal details are redacted before anything is written,
+// so fixtures captured from real sessions can be checked in as test data.
+package httpfixture
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Modes of the HTTP fixtures.
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// Request is a sanitized recorded request.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a sanitized recorded response. Bodies that are not valid
+// UTF-8, like gzipped instrument dumps, are kept base64 encoded.
+type Response struct {
+	StatusCode      int    `json:"status_code"`
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	Body            string `json:"body,omitempty"`
+	BodyBase64      string `json:"body_base64,omitempty"`
+}
+
+// Exchange is a recorded request and the response it got.
+type Exchange struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Fixture is the content of a fixture file.
+type Fixture struct {
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// Load reads a fixture file.
+func Load(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading HTTP fixtures: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("error decoding HTTP fixtures %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture file atomically.
+func (f *Fixture) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating HTTP fixtures directory: %w", err)
+	}
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return fmt.Errorf("error encoding HTTP fixtures: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("error writing HTTP fixtures: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing HTTP fixtures: %w", err)
+	}
+	return nil
+}
+
+// Transport returns the round tripper of the mode: a Recorder saving to
+// path that sends requests through base, or a Replayer serving path.
+func Transport(mode, path string, base http.RoundTripper) (http.RoundTripper, error) {
+	if path == "" {
+		return nil, errors.New("HTTP fixtures need a file")
+	}
+	switch mode {
+	case ModeRecord:
+		return NewRecorder(path, base), nil
+	case ModeReplay:
+		return NewReplayer(path)
+	default:
+		return nil, fmt.Errorf("unknown HTTP fixtures mode %q, use %s or %s", mode, ModeRecord, ModeReplay)
+	}
+}
+
+// Recorder is a round tripper that sends requests through its base
+// transport and appends the sanitized exchanges to a fixture file.
+type Recorder struct {
+	path string
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder creates a recorder saving to path. A nil base uses
+// http.DefaultTransport.
+func NewRecorder(path string, base http.RoundTripper) *Recorder {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Recorder{path: path, base: base}
+}
+
+// RoundTrip sends the request and records the exchange. The fixture file
+// is rewritten after every exchange, so a recording survives a crash.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response to record: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	exchange := Exchange{
+		Request:  sanitizeRequest(req, reqBody),
+		Response: sanitizeResponse(resp, respBody),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixture.Exchanges = append(r.fixture.Exchanges, exchange)
+	if err := r.fixture.Save(r.path); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Replayer is a round tripper that serves recorded responses instead of
+// sending requests. Requests match recorded ones by method, URL and body
+// after sanitizing; repeated requests get their recorded responses in
+// order, the last one again once they run out.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]Response
+	served    map[string]int
+}
+
+// NewReplayer creates a replayer serving the fixture file at path.
+func NewReplayer(path string) (*Replayer, error) {
+	f, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFixtureReplayer(f), nil
+}
+
+// NewFixtureReplayer creates a replayer serving the exchanges of f.
+func NewFixtureReplayer(f *Fixture) *Replayer {
+	r := &Replayer{
+		exchanges: make(map[string][]Response),
+		served:    make(map[string]int),
+	}
+	for _, e := range f.Exchanges {
+		key := e.Request.key()
+		r.exchanges[key] = append(r.exchanges[key], e.Response)
+	}
+	return r
+}
+
+func (r Request) key() string {
+	return r.Method + " " + r.URL + "\n" + r.Body
+}
+
+// RoundTrip serves the recorded response to the request.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := sanitizeRequest(req, body)
+	key := recorded.key()
+
+	r.mu.Lock()
+	responses := r.exchanges[key]
+	if len(responses) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s %s", recorded.Method, recorded.URL)
+	}
+	i := min(r.served[key], len(responses)-1)
+	r.served[key]++
+	r.mu.Unlock()
+
+	return responses[i].httpResponse(req)
+}
+
+func (r Response) httpResponse(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.BodyBase64 != "" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.BodyBase64); err != nil {
+			return nil, fmt.Errorf("error decoding recorded response body: %w", err)
+		}
+	}
+	header := make(http.Header)
+	if r.ContentType != "" {
+		header.Set("Content-Type", r.ContentType)
+	}
+	if r.ContentEncoding != "" {
+		header.Set("Content-Encoding", r.ContentEncoding)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads the request body and puts it back for sending.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func sanitizeRequest(req *http.Request, body []byte) Request {
+	u := *req.URL
+	u.User = nil
+	if u.RawQuery != "" {
+		query := u.Query()
+		redactValues(query)
+		u.RawQuery = query.Encode()
+	}
+	return Request{
+		Method: req.Method,
+		URL:    u.String(),
+		Body:   redactBody(req.Header.Get("Content-Type"), body),
+	}
+}
+
+func sanitizeResponse(resp *http.Response, body []byte) Response {
+	r := Response{
+		StatusCode:      resp.StatusCode,
+		ContentType:     resp.Header.Get("Content-Type"),
+		ContentEncoding: resp.Header.Get("Content-Encoding"),
+	}
+	if r.ContentEncoding != "" || !utf8.Valid(body) {
+		r.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+		return r
+	}
+	r.Body = redactBody(r.ContentType, body)
+	return r
+}
+
+func redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		redactValues(values)
+		return values.Encode()
+	case strings.Contains(contentType, "json"), json.Valid(body):
+		// Numbers are kept as they are, not rounded through float64
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			return string(body)
+		}
+		var data bytes.Buffer
+		encoder := json.NewEncoder(&data)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(redactJSON(v)); err != nil {
+			return string(body)
+		}
+		return strings.TrimSuffix(data.String(), "\n")
+	}
+	return string(body)
+}
@@ -0,0 +1,157 @@
+package httpfixture
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newKite serves a session token and numbered profile responses, checking
+// the credentials it gets.
+func newKite(t *testing.T) *httptest.Server {
+	t.Helper()
+	var profiles atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/session/token":
+			_ = r.ParseForm()
+			if r.Form.Get("request_token") != "req-secret" {
+				t.Errorf("Expected the real request token, got %q", r.Form.Get("request_token"))
+			}
+			_, _ = io.WriteString(w, `{"status":"success","data":{"user_id":"ZQ9876","user_name":"Asha Rao","email":"asha@example.com","access_token":"acc-secret","order_id":240603000123456789}}`)
+		case "/user/profile":
+			n := profiles.Add(1)
+			_, _ = io.WriteString(w, `{"status":"success","data":{"user_id":"ZQ9876","broker":"ZERODHA","n":`+strconv.Itoa(int(n))+`}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"status":"error","message":"Route not found"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "token key:acc-secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func postToken(t *testing.T, client *http.Client, base string) string {
+	t.Helper()
+	form := url.Values{"api_key": {"key"}, "request_token": {"req-secret"}, "checksum": {"sum-secret"}}
+	resp, err := client.PostForm(base+"/session/token", form)
+	if err != nil {
+		t.Fatalf("POST /session/token failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := newKite(t)
+	path := filepath.Join(t.TempDir(), "fixtures", "session.json")
+
+	recorder := &http.Client{Transport: NewRecorder(path, nil)}
+	token := postToken(t, recorder, srv.URL)
+	if !strings.Contains(token, "acc-secret") {
+		t.Errorf("Expected the recorder to pass the real response through, got %s", token)
+	}
+	for i := 0; i < 2; i++ {
+		get(t, recorder, srv.URL+"/user/profile?access_token=acc-secret")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the fixture file, got %v", err)
+	}
+	for _, secret := range []string{"req-secret", "sum-secret", "acc-secret", "ZQ9876", "Asha Rao", "asha@example.com", `"key"`} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q to be redacted from the fixtures:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "240603000123456789") {
+		t.Errorf("Expected large numbers to be kept as recorded:\n%s", data)
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("Expected fixtures to load, got %v", err)
+	}
+	replay := &http.Client{Transport: replayer}
+
+	token = postToken(t, replay, srv.URL)
+	if !strings.Contains(token, `"access_token":"REDACTED"`) || !strings.Contains(token, `"user_id":"XX0000"`) {
+		t.Errorf("Expected the redacted token response, got %s", token)
+	}
+	// Recorded responses come back in order, then the last one repeats
+	for _, want := range []string{`"n":1`, `"n":2`, `"n":2`} {
+		got := get(t, replay, srv.URL+"/user/profile?access_token=other")
+		if !strings.Contains(got, want) || !strings.Contains(got, `"user_id":"XX0000"`) {
+			t.Errorf("Expected a redacted profile with %s, got %s", want, got)
+		}
+	}
+
+	if _, err := replay.Get(srv.URL + "/portfolio/holdings"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("Expected an error for an unrecorded request, got %v", err)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if _, err := Transport(ModeRecord, "", nil); err == nil {
+		t.Error("Expected an error without a fixtures file")
+	}
+	if _, err := Transport("playback", path, nil); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+	if _, err := Transport(ModeReplay, path, nil); err == nil {
+		t.Error("Expected an error replaying a missing file")
+	}
+	if _, err := Transport(ModeRecord, path, nil); err != nil {
+		t.Errorf("Expected a recorder, got %v", err)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"application/x-www-form-urlencoded", "api_secret=s&quantity=1", "api_secret=REDACTED&quantity=1"},
+		{"application/json", `[{"placed_by":"ZQ9876","price":10.5,"meta":{"pan":"ABCDE1234F"}}]`, `[{"meta":{"pan":"REDACTED"},"placed_by":"XX0000","price":10.5}]`},
+		{"application/json", `{"email":null,"user_name":""}`, `{"email":null,"user_name":""}`},
+		{"text/csv", "instrument_token,tradingsymbol\n408065,INFY\n", "instrument_token,tradingsymbol\n408065,INFY\n"},
+	}
+	for _, tt := range tests {
+		if got := redactBody(tt.contentType, []byte(tt.body)); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package httpfixture
+
+import "net/url"
+
+const (
+	// Redacted replaces secrets and personal details.
+	Redacted = "REDACTED"
+
+	// RedactedUserID replaces Kite user IDs. It is a valid looking ID, so
+	// code comparing the IDs of a session and its orders still works.
+	RedactedUserID = "XX0000"
+)
+
+// secretParams are query and form parameters carrying credentials. The API
+// key is redacted too, so fixtures replay for any key.
+var secretParams = map[string]bool{
+	"api_key":       true,
+	"access_token":  true,
+	"request_token": true,
+	"refresh_token": true,
+	"checksum":      true,
+	"api_secret":    true,
+	"enctoken":      true,
+}
+
+// redactedFields are the JSON fields of the Kite API holding credentials
+// or personal details, with their replacements.
+var redactedFields = map[string]string{
+	"access_token":   Redacted,
+	"public_token":   Redacted,
+	"refresh_token":  Redacted,
+	"enctoken":       Redacted,
+	"api_key":        Redacted,
+	"user_name":      Redacted,
+	"user_shortname": Redacted,
+	"email":          Redacted,
+	"avatar_url":     Redacted,
+	"pan":            Redacted,
+	"phone":          Redacted,
+	"bank_account":   Redacted,
+	"dp_ids":         Redacted,
+	"folio":          Redacted,
+	"user_id":        RedactedUserID,
+	"placed_by":      RedactedUserID,
+}
+
+func redactValues(values url.Values) {
+	for key := range values {
+		if secretParams[key] {
+			values[key] = []string{Redacted}
+		}
+	}
+}
+
+// redactJSON replaces the redacted fields anywhere in a decoded JSON value.
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if replacement, ok := redactedFields[key]; ok {
+				if value != nil && value != "" {
+					v[key] = replacement
+				}
+				continue
+			}
+			v[key] = redactJSON(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+	}
+	return v
+}
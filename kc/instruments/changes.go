@@ -0,0 +1,199 @@
+package instruments
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Kinds of instrument changes between loads.
+const (
+	ChangeListed        = "listed"
+	ChangeDelisted      = "delisted"
+	ChangeLotSize       = "lot_size"
+	ChangeCircuitLimits = "circuit_limits"
+	ChangeInactive      = "inactive"
+)
+
+// ChangeKinds are the kinds of instrument changes, in the order diffs list
+// them.
+var ChangeKinds = []string{ChangeListed, ChangeDelisted, ChangeLotSize, ChangeCircuitLimits, ChangeInactive}
+
+const (
+	// maxDiffs is the number of diffs kept, about a week of daily loads.
+	maxDiffs = 7
+
+	// circuitBandTolerance is how many percentage points a circuit band
+	// must move by to count as changed. Limits follow the previous close
+	// every day and are rounded to the tick, so only a move to another
+	// band (2%, 5%, 10%, 20%) is a change.
+	circuitBandTolerance = 1.0
+)
+
+// InstrumentChange is a change to an instrument between two loads.
+type InstrumentChange struct {
+	Kind            string  `json:"kind"`
+	InstrumentToken uint32  `json:"instrument_token"`
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	Name            string  `json:"name,omitempty"`
+	InstrumentType  string  `json:"instrument_type,omitempty"`
+	ExpiryDate      string  `json:"expiry_date,omitempty"`
+	Expired         bool    `json:"expired,omitempty"` // a delisting at expiry
+	OldLotSize      int     `json:"old_lot_size,omitempty"`
+	NewLotSize      int     `json:"new_lot_size,omitempty"`
+	OldCircuitBand  float64 `json:"old_circuit_band,omitempty"` // percent either side of the midpoint
+	NewCircuitBand  float64 `json:"new_circuit_band,omitempty"`
+	LowerCircuit    float64 `json:"lower_circuit_limit,omitempty"`
+	UpperCircuit    float64 `json:"upper_circuit_limit,omitempty"`
+}
+
+// Diff is what changed between two consecutive loads of the instruments.
+type Diff struct {
+	From    time.Time          `json:"from"` // when the earlier instruments were loaded
+	To      time.Time          `json:"to"`
+	Counts  map[string]int     `json:"counts"`
+	Changes []InstrumentChange `json:"changes"`
+}
+
+// Filter returns a copy of the diff with the changes keep returns true for.
+// Counts stay those of the whole diff.
+func (d Diff) Filter(keep func(InstrumentChange) bool) Diff {
+	out := d
+	out.Changes = nil
+	for _, c := range d.Changes {
+		if keep(c) {
+			out.Changes = append(out.Changes, c)
+		}
+	}
+	return out
+}
+
+func newChange(kind string, inst Instrument) InstrumentChange {
+	return InstrumentChange{
+		Kind:            kind,
+		InstrumentToken: inst.InstrumentToken,
+		Exchange:        inst.Exchange,
+		Tradingsymbol:   inst.Tradingsymbol,
+		Name:            inst.Name,
+		InstrumentType:  inst.InstrumentType,
+		ExpiryDate:      inst.ExpiryDate,
+	}
+}
+
+// circuitBand returns the percent the circuit limits of inst allow either
+// side of their midpoint, zero if it has none.
+func circuitBand(inst Instrument) float64 {
+	if inst.LowerCircuitLimit <= 0 || inst.UpperCircuitLimit <= inst.LowerCircuitLimit {
+		return 0
+	}
+	band := (inst.UpperCircuitLimit - inst.LowerCircuitLimit) / (inst.UpperCircuitLimit + inst.LowerCircuitLimit) * 100
+	return math.Round(band*100) / 100
+}
+
+// diffInstruments returns the changes from the instruments loaded at from
+// to those loaded at to, by instrument token.
+func diffInstruments(old, new *store, from, to time.Time) Diff {
+	diff := Diff{From: from, To: to, Counts: make(map[string]int, len(ChangeKinds))}
+	today := dateIST(to)
+
+	for row := range new.len() {
+		if !new.live(row) {
+			continue
+		}
+		inst := new.instrument(row)
+		prev, ok := old.get(inst.InstrumentToken)
+		if !ok {
+			diff.Changes = append(diff.Changes, newChange(ChangeListed, inst))
+			continue
+		}
+		if prev.LotSize != inst.LotSize && prev.LotSize > 0 {
+			c := newChange(ChangeLotSize, inst)
+			c.OldLotSize, c.NewLotSize = prev.LotSize, inst.LotSize
+			diff.Changes = append(diff.Changes, c)
+		}
+		if before, after := circuitBand(prev), circuitBand(inst); before > 0 && after > 0 && math.Abs(after-before) >= circuitBandTolerance {
+			c := newChange(ChangeCircuitLimits, inst)
+			c.OldCircuitBand, c.NewCircuitBand = before, after
+			c.LowerCircuit, c.UpperCircuit = inst.LowerCircuitLimit, inst.UpperCircuitLimit
+			diff.Changes = append(diff.Changes, c)
+		}
+		if prev.Active && !inst.Active {
+			diff.Changes = append(diff.Changes, newChange(ChangeInactive, inst))
+		}
+	}
+	for row := range old.len() {
+		if !old.live(row) {
+			continue
+		}
+		if _, ok := new.byToken[old.instrumentTokens[row]]; !ok {
+			inst := old.instrument(row)
+			c := newChange(ChangeDelisted, inst)
+			if expiry, ok := inst.Expiry(); ok && expiry.Before(today) {
+				c.Expired = true
+			}
+			diff.Changes = append(diff.Changes, c)
+		}
+	}
+
+	order := make(map[string]int, len(ChangeKinds))
+	for i, kind := range ChangeKinds {
+		order[kind] = i
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i], diff.Changes[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.Exchange != b.Exchange {
+			return a.Exchange < b.Exchange
+		}
+		if a.Tradingsymbol != b.Tradingsymbol {
+			return a.Tradingsymbol < b.Tradingsymbol
+		}
+		return a.InstrumentToken < b.InstrumentToken
+	})
+	for _, c := range diff.Changes {
+		diff.Counts[c.Kind]++
+	}
+	return diff
+}
+
+// OnChange registers a listener called with the diff of every load of the
+// instruments after the first, once the new instruments are in use.
+// Listeners run on the loading goroutine and must not block or load
+// instruments.
+func (m *Manager) OnChange(fn func(Diff)) {
+	m.changesMu.Lock()
+	defer m.changesMu.Unlock()
+	m.changeListeners = append(m.changeListeners, fn)
+}
+
+// Changes returns the diffs of the recent loads of the instruments, latest
+// first.
+func (m *Manager) Changes() []Diff {
+	m.changesMu.Lock()
+	defer m.changesMu.Unlock()
+	return append([]Diff(nil), m.diffs...)
+}
+
+// publishChanges records a diff and calls the listeners with it.
+func (m *Manager) publishChanges(diff Diff) {
+	m.changesMu.Lock()
+	m.diffs = append([]Diff{diff}, m.diffs...)
+	if len(m.diffs) > maxDiffs {
+		m.diffs = m.diffs[:maxDiffs]
+	}
+	listeners := append([]func(Diff){}, m.changeListeners...)
+	m.changesMu.Unlock()
+
+	m.logger.Info("Instruments changed since the last load",
+		"listed", diff.Counts[ChangeListed],
+		"delisted", diff.Counts[ChangeDelisted],
+		"lot_size", diff.Counts[ChangeLotSize],
+		"circuit_limits", diff.Counts[ChangeCircuitLimits],
+		"inactive", diff.Counts[ChangeInactive])
+	for _, fn := range listeners {
+		fn(diff)
+	}
+}
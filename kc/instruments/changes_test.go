@@ -0,0 +1,178 @@
+package instruments
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func changesTestLoad(overrides ...func(map[uint32]*Instrument)) map[uint32]*Instrument {
+	load := map[uint32]*Instrument{
+		408065: {ID: "NSE:INFY", InstrumentToken: 408065, Tradingsymbol: "INFY", Name: "INFOSYS", Exchange: "NSE", InstrumentType: "EQ", LotSize: 1, LowerCircuitLimit: 1200, UpperCircuitLimit: 1800, Active: true},
+		738561: {ID: "NSE:RELIANCE", InstrumentToken: 738561, Tradingsymbol: "RELIANCE", Name: "RELIANCE", Exchange: "NSE", InstrumentType: "EQ", LotSize: 1, LowerCircuitLimit: 2400, UpperCircuitLimit: 3600, Active: true},
+		1001:   {ID: "NFO:NIFTYFUT", InstrumentToken: 1001, Tradingsymbol: "NIFTYFUT", Name: "NIFTY", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-01-30", LotSize: 75, Active: true},
+		1002:   {ID: "NFO:NIFTY22500CE", InstrumentToken: 1002, Tradingsymbol: "NIFTY22500CE", Name: "NIFTY", Exchange: "NFO", InstrumentType: "CE", ExpiryDate: "2025-02-27", LotSize: 75, Active: true},
+		1003:   {ID: "NFO:NIFTYOLDCE", InstrumentToken: 1003, Tradingsymbol: "NIFTYOLDCE", Name: "NIFTY", Exchange: "NFO", InstrumentType: "CE", ExpiryDate: "2025-01-02", LotSize: 75, Active: true},
+		1004:   {ID: "NFO:SUZLONFUT", InstrumentToken: 1004, Tradingsymbol: "SUZLONFUT", Name: "SUZLON", Exchange: "NFO", InstrumentType: "FUT", ExpiryDate: "2025-02-27", LotSize: 5000, Active: true},
+	}
+	for _, override := range overrides {
+		override(load)
+	}
+	return load
+}
+
+func TestDiffInstruments(t *testing.T) {
+	old := changesTestLoad()
+	new := changesTestLoad(func(load map[uint32]*Instrument) {
+		// Circuits follow the close within the same 20% band
+		load[408065].LowerCircuitLimit, load[408065].UpperCircuitLimit = 1240, 1860
+		// Moved from the 20% to the 10% band
+		load[738561].LowerCircuitLimit, load[738561].UpperCircuitLimit = 2700, 3300
+		load[1002].LotSize = 65
+		load[1002].Active = false
+		load[1005] = &Instrument{InstrumentToken: 1005, Tradingsymbol: "NIFTYNEWCE", Name: "NIFTY", Exchange: "NFO", InstrumentType: "CE", ExpiryDate: "2025-03-27", LotSize: 75}
+		delete(load, 1003) // expired
+		delete(load, 1004) // dropped from F&O
+	})
+	from, to := time.Date(2025, 1, 6, 8, 0, 0, 0, ist), time.Date(2025, 1, 7, 8, 0, 0, 0, ist)
+
+	diff := diffInstruments(storeOf(old), storeOf(new), from, to)
+	if !diff.From.Equal(from) || !diff.To.Equal(to) {
+		t.Errorf("Expected the diff from %v to %v, got %v to %v", from, to, diff.From, diff.To)
+	}
+	var got []string
+	for _, c := range diff.Changes {
+		got = append(got, fmt.Sprintf("%s %s", c.Kind, c.Tradingsymbol))
+	}
+	expected := []string{
+		"listed NIFTYNEWCE",
+		"delisted NIFTYOLDCE",
+		"delisted SUZLONFUT",
+		"lot_size NIFTY22500CE",
+		"circuit_limits RELIANCE",
+		"inactive NIFTY22500CE",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected changes %v, got %v", expected, got)
+	}
+
+	byKey := make(map[string]InstrumentChange)
+	for _, c := range diff.Changes {
+		byKey[c.Kind+" "+c.Tradingsymbol] = c
+	}
+	if c := byKey["delisted NIFTYOLDCE"]; !c.Expired {
+		t.Errorf("Expected the delisting of an expired contract marked expired, got %+v", c)
+	}
+	if c := byKey["delisted SUZLONFUT"]; c.Expired {
+		t.Errorf("Expected the delisting of a live contract not marked expired, got %+v", c)
+	}
+	if c := byKey["lot_size NIFTY22500CE"]; c.OldLotSize != 75 || c.NewLotSize != 65 {
+		t.Errorf("Expected the lot size to change from 75 to 65, got %+v", c)
+	}
+	if c := byKey["circuit_limits RELIANCE"]; c.OldCircuitBand != 20 || c.NewCircuitBand != 10 || c.UpperCircuit != 3300 {
+		t.Errorf("Expected the circuit band to change from 20%% to 10%%, got %+v", c)
+	}
+	if diff.Counts[ChangeDelisted] != 2 || diff.Counts[ChangeListed] != 1 || diff.Counts[ChangeCircuitLimits] != 1 {
+		t.Errorf("Expected the changes counted by kind, got %v", diff.Counts)
+	}
+
+	filtered := diff.Filter(func(c InstrumentChange) bool { return c.Exchange == "NSE" })
+	if len(filtered.Changes) != 1 || filtered.Counts[ChangeDelisted] != 2 {
+		t.Errorf("Expected the NSE change and the counts of the whole diff, got %+v", filtered)
+	}
+}
+
+func TestChangeFeed(t *testing.T) {
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+
+	var received []Diff
+	manager.OnChange(func(d Diff) {
+		// The new instruments are in use when listeners run
+		if _, err := manager.GetByInstToken(1005); d.Counts[ChangeListed] > 0 && err != nil {
+			t.Errorf("Expected the listed instrument to be loaded, got %v", err)
+		}
+		received = append(received, d)
+	})
+
+	loadedAt := time.Now().Add(-48 * time.Hour)
+	manager.replaceAll(storeOf(changesTestLoad()), loadedAt, false)
+	if len(received) != 0 || len(manager.Changes()) != 0 {
+		t.Fatalf("Expected no diff for the first load, got %d", len(received))
+	}
+
+	manager.replaceAll(storeOf(changesTestLoad(func(load map[uint32]*Instrument) {
+		load[1005] = &Instrument{InstrumentToken: 1005, Tradingsymbol: "NIFTYNEWCE", Exchange: "NFO"}
+	})), loadedAt.Add(24*time.Hour), false)
+	if len(received) != 1 || received[0].Counts[ChangeListed] != 1 || !received[0].From.Equal(loadedAt) {
+		t.Fatalf("Expected a diff with the listing since the first load, got %+v", received)
+	}
+
+	for i := range maxDiffs + 2 {
+		manager.replaceAll(storeOf(changesTestLoad()), loadedAt.Add(time.Duration(i+2)*24*time.Hour), false)
+	}
+	changes := manager.Changes()
+	if len(changes) != maxDiffs || len(received) != maxDiffs+3 {
+		t.Errorf("Expected the last %d diffs kept and every one published, got %d and %d", maxDiffs, len(changes), len(received))
+	}
+	if !changes[0].To.After(changes[1].To) {
+		t.Errorf("Expected the latest diff first, got %v then %v", changes[0].To, changes[1].To)
+	}
+}
+
+func TestConcurrentLoadsDiffInTurn(t *testing.T) {
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+
+	var mu sync.Mutex
+	var received []Diff
+	manager.OnChange(func(d Diff) {
+		mu.Lock()
+		received = append(received, d)
+		mu.Unlock()
+	})
+
+	// Loads large enough for their diffs to overlap if they didn't take
+	// turns
+	bulk := func(load map[uint32]*Instrument) {
+		for token := uint32(100000); token < 150000; token++ {
+			load[token] = &Instrument{InstrumentToken: token, Tradingsymbol: fmt.Sprintf("BULK%d", token), Exchange: "NSE", Active: true}
+		}
+	}
+	loadedAt := time.Now().Add(-48 * time.Hour)
+	manager.replaceAll(storeOf(changesTestLoad(bulk)), loadedAt, false)
+
+	// Each load lists an instrument of its own, so each delists the one
+	// of the load before it
+	const loads = 8
+	var wg sync.WaitGroup
+	for i := range loads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token := uint32(2000 + i)
+			manager.replaceAll(storeOf(changesTestLoad(bulk, func(load map[uint32]*Instrument) {
+				load[token] = &Instrument{InstrumentToken: token, Tradingsymbol: fmt.Sprintf("NEW%d", i), Exchange: "NSE"}
+			})), loadedAt.Add(time.Duration(i+1)*time.Hour), false)
+		}()
+	}
+	wg.Wait()
+
+	if len(received) != loads {
+		t.Fatalf("Expected a diff of every load, got %d", len(received))
+	}
+	delisted := 0
+	for i, d := range received {
+		if i > 0 && !d.From.Equal(received[i-1].To) {
+			t.Errorf("Expected diff %d to be against the load before it, got from %v after %v", i, d.From, received[i-1].To)
+		}
+		if d.Counts[ChangeListed] != 1 {
+			t.Errorf("Expected diff %d to list an instrument, got %d", i, d.Counts[ChangeListed])
+		}
+		delisted += d.Counts[ChangeDelisted]
+	}
+	if delisted != loads-1 {
+		t.Errorf("Expected every load after the first to delist the instrument of the one before, got %d delistings", delisted)
+	}
+}
@@ -0,0 +1,99 @@
+package instruments
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// ist is the timezone of instrument expiries.
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+// dateIST returns the start of the IST day of t.
+func dateIST(t time.Time) time.Time {
+	y, m, d := t.In(ist).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, ist)
+}
+
+// Expiry returns the expiry date of a derivative, at the start of the day
+// in IST, and false for instruments that don't expire.
+func (i Instrument) Expiry() (time.Time, bool) {
+	if len(i.ExpiryDate) < len(time.DateOnly) {
+		return time.Time{}, false
+	}
+	// Dates can come with a time of day, which is always the close
+	expiry, err := time.ParseInLocation(time.DateOnly, i.ExpiryDate[:len(time.DateOnly)], ist)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiry, true
+}
+
+// Expiries returns the distinct expiry dates of the instruments passing
+// the filters of opts, earliest first. Set opts.ExpiryFrom to today to get
+// the upcoming ones.
+func (m *Manager) Expiries(opts SearchOptions) []time.Time {
+	idx := m.searchIndex()
+	seen := make(map[time.Time]bool)
+	var out []time.Time
+	for doc := range int32(len(idx.docs)) {
+		inst := idx.fields(doc)
+		if inst.expiry.IsZero() || seen[inst.expiry] || !opts.match(inst) {
+			continue
+		}
+		seen[inst.expiry] = true
+		out = append(out, inst.expiry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+// NearestExpiry returns the n-th upcoming expiry on or after the day of
+// from of the instruments passing the filters of opts, 0 being the
+// nearest. Passing the name of an underlying and an instrument type of FUT,
+// CE or PE resolves "this week's" or "next week's" contracts.
+func (m *Manager) NearestExpiry(opts SearchOptions, from time.Time, n int) (time.Time, error) {
+	if opts.ExpiryFrom.IsZero() || opts.ExpiryFrom.Before(from) {
+		opts.ExpiryFrom = from
+	}
+	expiries := m.Expiries(opts)
+	if n < 0 || n >= len(expiries) {
+		return time.Time{}, ErrNoDerivatives
+	}
+	return expiries[n], nil
+}
+
+// Strikes returns the distinct strikes of the options passing the filters
+// of opts, lowest first.
+func (m *Manager) Strikes(opts SearchOptions) []float64 {
+	idx := m.searchIndex()
+	seen := make(map[float64]bool)
+	var out []float64
+	for doc := range int32(len(idx.docs)) {
+		inst := idx.fields(doc)
+		if inst.strike <= 0 || seen[inst.strike] || !opts.match(inst) {
+			continue
+		}
+		seen[inst.strike] = true
+		out = append(out, inst.strike)
+	}
+	sort.Float64s(out)
+	return out
+}
+
+// ATMStrike returns the at-the-money strike for the price of the
+// underlying: the strike of the options passing the filters of opts
+// closest to it, the lower one on a tie.
+func (m *Manager) ATMStrike(opts SearchOptions, price float64) (float64, error) {
+	strikes := m.Strikes(opts)
+	if len(strikes) == 0 {
+		return 0, ErrNoDerivatives
+	}
+	best := strikes[0]
+	for _, strike := range strikes[1:] {
+		if math.Abs(strike-price) < math.Abs(best-price) {
+			best = strike
+		}
+	}
+	return best, nil
+}
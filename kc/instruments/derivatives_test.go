@@ -0,0 +1,154 @@
+package instruments
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newChainTestManager loads BANKNIFTY options with strikes 100 apart for
+// two expiries, one of them expired, and a future.
+func newChainTestManager(expired, first, second time.Time) *Manager {
+	chain := make(map[uint32]*Instrument)
+	token := uint32(1000)
+	add := func(inst *Instrument) {
+		token++
+		inst.InstrumentToken = token
+		inst.ID = "NFO:" + inst.Tradingsymbol
+		inst.Name = "BANKNIFTY"
+		inst.Exchange = "NFO"
+		inst.LotSize = 30
+		chain[token] = inst
+	}
+	for _, expiry := range []time.Time{expired, first, second} {
+		for strike := 48000; strike <= 48400; strike += 100 {
+			for _, kind := range []string{"PE", "CE"} {
+				add(&Instrument{
+					Tradingsymbol:  fmt.Sprintf("BANKNIFTY%s%d%s", expiry.Format("06Jan02"), strike, kind),
+					Strike:         float64(strike),
+					InstrumentType: kind,
+					Segment:        "NFO-OPT",
+					ExpiryDate:     expiry.Format(time.DateOnly),
+					Active:         strike != 48400,
+				})
+			}
+		}
+	}
+	add(&Instrument{Tradingsymbol: "BANKNIFTYFUT", InstrumentType: "FUT", Segment: "NFO-FUT", ExpiryDate: second.Format(time.DateOnly) + " 15:30:00"})
+
+	manager := newTestManagerWithoutUpdate()
+	manager.LoadMap(chain)
+	return manager
+}
+
+func TestInstrumentExpiry(t *testing.T) {
+	tests := []struct {
+		expiryDate string
+		expected   string
+	}{
+		{"2024-12-26", "2024-12-26"},
+		{"2024-12-26T15:30:00+05:30", "2024-12-26"},
+		{"", ""},
+		{"26-12-2024", ""},
+	}
+	for _, tt := range tests {
+		expiry, ok := Instrument{ExpiryDate: tt.expiryDate}.Expiry()
+		if ok != (tt.expected != "") {
+			t.Errorf("Expected %q to have an expiry: %v, got %v", tt.expiryDate, tt.expected != "", ok)
+			continue
+		}
+		if ok && (expiry.Format(time.DateOnly) != tt.expected || expiry.Location() != ist || expiry.Hour() != 0) {
+			t.Errorf("Expected %s at the start of the day in IST, got %v", tt.expected, expiry)
+		}
+	}
+}
+
+func TestExpiriesAndStrikes(t *testing.T) {
+	today := dateIST(time.Now())
+	expired, first, second := today.AddDate(0, 0, -5), today, today.AddDate(0, 0, 7)
+	manager := newChainTestManager(expired, first, second)
+	defer manager.Shutdown()
+
+	options := SearchOptions{Name: "banknifty", InstrumentType: "CE"}
+	if expiries := manager.Expiries(options); len(expiries) != 3 || !expiries[0].Equal(expired) {
+		t.Errorf("Expected 3 expiries from %v, got %v", expired, expiries)
+	}
+
+	tests := []struct {
+		n        int
+		expected time.Time
+	}{
+		{0, first}, // expiring today is still upcoming
+		{1, second},
+	}
+	for _, tt := range tests {
+		expiry, err := manager.NearestExpiry(options, time.Now(), tt.n)
+		if err != nil || !expiry.Equal(tt.expected) {
+			t.Errorf("Expected expiry %d to be %v, got %v (%v)", tt.n, tt.expected, expiry, err)
+		}
+	}
+	if _, err := manager.NearestExpiry(options, time.Now(), 2); err != ErrNoDerivatives {
+		t.Errorf("Expected ErrNoDerivatives past the last expiry, got %v", err)
+	}
+	if _, err := manager.NearestExpiry(SearchOptions{Name: "FINNIFTY"}, time.Now(), 0); err != ErrNoDerivatives {
+		t.Errorf("Expected ErrNoDerivatives for an unknown underlying, got %v", err)
+	}
+
+	futures := SearchOptions{Name: "BANKNIFTY", InstrumentType: "FUT"}
+	if expiry, err := manager.NearestExpiry(futures, time.Now(), 0); err != nil || !expiry.Equal(second) {
+		t.Errorf("Expected the future to expire on %v, got %v (%v)", second, expiry, err)
+	}
+
+	week := SearchOptions{Name: "BANKNIFTY", ExpiryFrom: first, ExpiryTo: first}
+	if strikes := manager.Strikes(week); len(strikes) != 5 || strikes[0] != 48000 || strikes[4] != 48400 {
+		t.Errorf("Expected strikes 48000 to 48400, got %v", strikes)
+	}
+	week.ActiveOnly = true
+	if strikes := manager.Strikes(week); len(strikes) != 4 {
+		t.Errorf("Expected the inactive strike to be left out, got %v", strikes)
+	}
+
+	atmTests := []struct {
+		price    float64
+		expected float64
+	}{
+		{48140, 48100},
+		{48150, 48100}, // the lower strike on a tie
+		{48160, 48200},
+		{40000, 48000},
+		{60000, 48300},
+	}
+	for _, tt := range atmTests {
+		if atm, err := manager.ATMStrike(week, tt.price); err != nil || atm != tt.expected {
+			t.Errorf("Expected ATM strike %v at %v, got %v (%v)", tt.expected, tt.price, atm, err)
+		}
+	}
+	if _, err := manager.ATMStrike(futures, 48000); err != ErrNoDerivatives {
+		t.Errorf("Expected ErrNoDerivatives without options, got %v", err)
+	}
+}
+
+func TestSearchListsChain(t *testing.T) {
+	today := dateIST(time.Now())
+	expired, first, second := today.AddDate(0, 0, -5), today.AddDate(0, 0, 1), today.AddDate(0, 0, 8)
+	manager := newChainTestManager(expired, first, second)
+	defer manager.Shutdown()
+
+	results := manager.Search(SearchOptions{Name: "BANKNIFTY", ExpiryFrom: first, StrikeMin: 48100, StrikeMax: 48200})
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Tradingsymbol)
+	}
+	f, s := first.Format("06Jan02"), second.Format("06Jan02")
+	expected := []string{
+		"BANKNIFTY" + f + "48100CE", "BANKNIFTY" + f + "48100PE", "BANKNIFTY" + f + "48200CE", "BANKNIFTY" + f + "48200PE",
+		"BANKNIFTY" + s + "48100CE", "BANKNIFTY" + s + "48100PE", "BANKNIFTY" + s + "48200CE", "BANKNIFTY" + s + "48200PE",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected the chain by expiry and strike %v, got %v", expected, got)
+	}
+
+	if results := manager.Search(SearchOptions{Name: "BANKNIFTY", LotSize: 15}); len(results) != 0 {
+		t.Errorf("Expected no contracts of another lot size, got %d", len(results))
+	}
+}
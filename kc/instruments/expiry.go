@@ -0,0 +1,147 @@
+package instruments
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of expiries.
+const (
+	ExpiryWeekly  = "weekly"
+	ExpiryMonthly = "monthly"
+)
+
+// ContractExpiry is a day the derivatives of an underlying expire.
+type ContractExpiry struct {
+	Date    time.Time `json:"date"`
+	Kind    string    `json:"kind"`
+	Futures bool      `json:"futures"`
+	Options bool      `json:"options"`
+}
+
+// ExpirySchedule is the expiry calendar of the derivatives of an
+// underlying on an exchange, earliest first.
+type ExpirySchedule struct {
+	Exchange   string           `json:"exchange"`
+	Underlying string           `json:"underlying"`
+	Expiries   []ContractExpiry `json:"expiries"`
+}
+
+// Upcoming returns the expiries on or after the day of from.
+func (s ExpirySchedule) Upcoming(from time.Time) []ContractExpiry {
+	day := dateIST(from)
+	i := sort.Search(len(s.Expiries), func(i int) bool { return !s.Expiries[i].Date.Before(day) })
+	return s.Expiries[i:]
+}
+
+// Next returns the first expiry after the day of after of the given kind,
+// or of any kind if kind is empty.
+func (s ExpirySchedule) Next(after time.Time, kind string) (ContractExpiry, bool) {
+	for _, e := range s.Upcoming(dateIST(after).AddDate(0, 0, 1)) {
+		if kind == "" || e.Kind == kind {
+			return e, true
+		}
+	}
+	return ContractExpiry{}, false
+}
+
+// Kind returns the kind of the expiry on the day of date, empty if the
+// underlying has no contracts expiring then.
+func (s ExpirySchedule) Kind(date time.Time) string {
+	if e := s.Upcoming(date); len(e) > 0 && e[0].Date.Equal(dateIST(date)) {
+		return e[0].Kind
+	}
+	return ""
+}
+
+func scheduleKey(exchange, underlying string) string {
+	return strings.ToUpper(exchange) + ":" + strings.ToUpper(underlying)
+}
+
+// buildSchedules derives the expiry calendars of every underlying from
+// the loaded futures and options. The kind comes from the expiry type of
+// the contracts when the dump has it. Otherwise an expiry is monthly when
+// futures expire on it or it is the underlying's last one in its month.
+func (idx *searchIndex) buildSchedules() map[string]*ExpirySchedule {
+	type day struct {
+		ContractExpiry
+		listedKind string
+	}
+	days := make(map[string]map[time.Time]*day)
+	schedules := make(map[string]*ExpirySchedule)
+	for doc := range int32(len(idx.docs)) {
+		inst := idx.fields(doc)
+		expiry := inst.expiry
+		if expiry.IsZero() || inst.name == "" {
+			continue
+		}
+		futures := inst.instrumentType == "FUT"
+		options := inst.instrumentType == "CE" || inst.instrumentType == "PE"
+		if !futures && !options {
+			continue
+		}
+
+		key := scheduleKey(inst.exchange, inst.name)
+		if schedules[key] == nil {
+			schedules[key] = &ExpirySchedule{Exchange: inst.exchange, Underlying: inst.name}
+			days[key] = make(map[time.Time]*day)
+		}
+		d := days[key][expiry]
+		if d == nil {
+			d = &day{ContractExpiry: ContractExpiry{Date: expiry}}
+			days[key][expiry] = d
+		}
+		d.Futures = d.Futures || futures
+		d.Options = d.Options || options
+		switch listed := strings.ToLower(idx.cols.str(idx.cols.expiryTypes, idx.docs[doc])); {
+		case strings.Contains(listed, "week"):
+			d.listedKind = ExpiryWeekly
+		case strings.Contains(listed, "month"):
+			d.listedKind = ExpiryMonthly
+		}
+	}
+
+	for key, schedule := range schedules {
+		for _, d := range days[key] {
+			schedule.Expiries = append(schedule.Expiries, d.ContractExpiry)
+		}
+		sort.Slice(schedule.Expiries, func(i, j int) bool {
+			return schedule.Expiries[i].Date.Before(schedule.Expiries[j].Date)
+		})
+		for i := range schedule.Expiries {
+			e := &schedule.Expiries[i]
+			lastOfMonth := i == len(schedule.Expiries)-1 || schedule.Expiries[i+1].Date.Month() != e.Date.Month()
+			switch {
+			case days[key][e.Date].listedKind != "":
+				e.Kind = days[key][e.Date].listedKind
+			case e.Futures || lastOfMonth:
+				e.Kind = ExpiryMonthly
+			default:
+				e.Kind = ExpiryWeekly
+			}
+		}
+	}
+	return schedules
+}
+
+// schedules returns the expiry calendars of the index, derived once.
+func (idx *searchIndex) schedules() map[string]*ExpirySchedule {
+	idx.schedulesOnce.Do(func() {
+		idx.expirySchedules = idx.buildSchedules()
+	})
+	return idx.expirySchedules
+}
+
+// ExpirySchedule returns the expiry calendar of the derivatives of an
+// underlying on an exchange, like NIFTY on NFO, derived from the loaded
+// contracts.
+func (m *Manager) ExpirySchedule(exchange, underlying string) (ExpirySchedule, error) {
+	schedule, ok := m.searchIndex().schedules()[scheduleKey(exchange, underlying)]
+	if !ok {
+		return ExpirySchedule{}, ErrNoDerivatives
+	}
+	out := *schedule
+	out.Expiries = append([]ContractExpiry(nil), schedule.Expiries...)
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package instruments
+
+import (
+	"testing"
+	"time"
+)
+
+func istDate(s string) time.Time {
+	d, _ := time.ParseInLocation(time.DateOnly, s, ist)
+	return d
+}
+
+func newExpiryTestManager() *Manager {
+	chain := make(map[uint32]*Instrument)
+	token := uint32(2000)
+	add := func(name, symbol, kind, expiry, expiryType string) {
+		token++
+		chain[token] = &Instrument{
+			ID: "NFO:" + symbol, InstrumentToken: token, Tradingsymbol: symbol, Name: name, Exchange: "NFO",
+			InstrumentType: kind, ExpiryDate: expiry, ExpiryType: expiryType, Strike: 100, LotSize: 75,
+		}
+	}
+	// Weekly options, the month's future on the last Thursday and only
+	// options listed for February so far
+	for _, expiry := range []string{"2025-01-02", "2025-01-09", "2025-01-16", "2025-01-30", "2025-02-06", "2025-02-13"} {
+		add("NIFTY", "NIFTY"+expiry+"CE", "CE", expiry, "")
+	}
+	add("NIFTY", "NIFTYJANFUT", "FUT", "2025-01-30", "")
+	// Listed expiry types win over the derived ones
+	add("FINNIFTY", "FINNIFTY1", "PE", "2025-01-07", "Monthly")
+	add("FINNIFTY", "FINNIFTY2", "PE", "2025-01-28", "weekly")
+	add("INFY", "INFYEQ", "EQ", "", "")
+
+	manager := newTestManagerWithoutUpdate()
+	manager.LoadMap(chain)
+	return manager
+}
+
+func TestExpirySchedule(t *testing.T) {
+	manager := newExpiryTestManager()
+	defer manager.Shutdown()
+
+	schedule, err := manager.ExpirySchedule("nfo", "nifty")
+	if err != nil {
+		t.Fatalf("Expected the NIFTY expiry calendar, got %v", err)
+	}
+	expected := []struct {
+		date    string
+		kind    string
+		futures bool
+	}{
+		{"2025-01-02", ExpiryWeekly, false},
+		{"2025-01-09", ExpiryWeekly, false},
+		{"2025-01-16", ExpiryWeekly, false},
+		{"2025-01-30", ExpiryMonthly, true},
+		{"2025-02-06", ExpiryWeekly, false},
+		{"2025-02-13", ExpiryMonthly, false}, // the last listed in its month
+	}
+	if len(schedule.Expiries) != len(expected) {
+		t.Fatalf("Expected %d expiries, got %v", len(expected), schedule.Expiries)
+	}
+	for i, tt := range expected {
+		e := schedule.Expiries[i]
+		if !e.Date.Equal(istDate(tt.date)) || e.Kind != tt.kind || e.Futures != tt.futures || !e.Options {
+			t.Errorf("Expected %s %s with futures %v, got %+v", tt.date, tt.kind, tt.futures, e)
+		}
+	}
+
+	finnifty, err := manager.ExpirySchedule("NFO", "FINNIFTY")
+	if err != nil || len(finnifty.Expiries) != 2 || finnifty.Expiries[0].Kind != ExpiryMonthly || finnifty.Expiries[1].Kind != ExpiryWeekly {
+		t.Errorf("Expected the listed expiry types, got %+v (%v)", finnifty, err)
+	}
+
+	for _, underlying := range []string{"INFY", "BANKNIFTY"} {
+		if _, err := manager.ExpirySchedule("NFO", underlying); err != ErrNoDerivatives {
+			t.Errorf("Expected ErrNoDerivatives for %s, got %v", underlying, err)
+		}
+	}
+
+	// Callers get a copy
+	schedule.Expiries[0].Kind = "changed"
+	if again, _ := manager.ExpirySchedule("NFO", "NIFTY"); again.Expiries[0].Kind != ExpiryWeekly {
+		t.Errorf("Expected the calendar to be unchanged, got %s", again.Expiries[0].Kind)
+	}
+}
+
+func TestExpiryScheduleNext(t *testing.T) {
+	manager := newExpiryTestManager()
+	defer manager.Shutdown()
+	schedule, _ := manager.ExpirySchedule("NFO", "NIFTY")
+
+	tests := []struct {
+		after    string
+		kind     string
+		expected string
+	}{
+		{"2025-01-02", "", "2025-01-09"},
+		{"2025-01-01", "", "2025-01-02"},
+		{"2025-01-02", ExpiryMonthly, "2025-01-30"},
+		{"2025-01-30", ExpiryMonthly, "2025-02-13"},
+		{"2025-01-16", ExpiryWeekly, "2025-02-06"},
+		{"2025-02-13", "", ""},
+	}
+	for _, tt := range tests {
+		next, ok := schedule.Next(istDate(tt.after), tt.kind)
+		if ok != (tt.expected != "") || (ok && !next.Date.Equal(istDate(tt.expected))) {
+			t.Errorf("Expected the %q expiry after %s to be %q, got %v (%v)", tt.kind, tt.after, tt.expected, next.Date, ok)
+		}
+	}
+
+	if kind := schedule.Kind(istDate("2025-01-30").Add(15 * time.Hour)); kind != ExpiryMonthly {
+		t.Errorf("Expected a monthly expiry on 2025-01-30, got %q", kind)
+	}
+	if kind := schedule.Kind(istDate("2025-01-31")); kind != "" {
+		t.Errorf("Expected no expiry on 2025-01-31, got %q", kind)
+	}
+	if upcoming := schedule.Upcoming(istDate("2025-01-20")); len(upcoming) != 3 {
+		t.Errorf("Expected 3 upcoming expiries, got %v", upcoming)
+	}
+}
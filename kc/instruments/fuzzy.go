@@ -0,0 +1,104 @@
+package instruments
+
+import (
+	"strings"
+	"unicode"
+)
+
+// tokenize lowercases s and splits it into alphanumeric words.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Token match scores, best first.
+const (
+	scoreExact  = 10
+	scorePrefix = 7
+	scoreInfix  = 4
+	scoreTypo   = 2
+)
+
+// matchToken scores how well a single query word matches a text word.
+func matchToken(q, w string) int {
+	switch {
+	case q == w:
+		return scoreExact
+	case strings.HasPrefix(w, q):
+		return scorePrefix
+	case len(q) >= 3 && strings.Contains(w, q):
+		return scoreInfix
+	case len(q) >= 4 && withinOneEdit(q, w):
+		return scoreTypo
+	}
+	return 0
+}
+
+// fuzzyScore scores text against a free-form query. Every query word must
+// match some word in the text (exactly, as a prefix, as a substring or with
+// a single typo), otherwise the score is 0. Higher is better.
+func fuzzyScore(query, text string) int {
+	qTokens := tokenize(query)
+	if len(qTokens) == 0 {
+		return 0
+	}
+	words := tokenize(text)
+
+	total := 0
+	for _, q := range qTokens {
+		best := 0
+		for _, w := range words {
+			if s := matchToken(q, w); s > best {
+				best = s
+				if best == scoreExact {
+					break
+				}
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// withinOneEdit reports whether a and b differ by at most one insertion,
+// deletion, substitution or transposition of adjacent characters.
+func withinOneEdit(a, b string) bool {
+	if len(a) == len(b) {
+		for i := 0; i < len(a)-1; i++ {
+			if a[i] != b[i] {
+				if a[i] == b[i+1] && a[i+1] == b[i] && a[i+2:] == b[i+2:] {
+					return true
+				}
+				break
+			}
+		}
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+
+	i, j, edits := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			i++
+			j++
+			continue
+		}
+		edits++
+		if edits > 1 {
+			return false
+		}
+		if len(a) == len(b) {
+			i++
+		}
+		j++
+	}
+	return edits+(len(b)-j)+(len(a)-i) <= 1
+}
@@ -0,0 +1,454 @@
+package instruments
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// SearchOptions are the query and filters of an instrument search.
+type SearchOptions struct {
+	// Query is matched word by word against the tradingsymbol, name,
+	// exchange, instrument type, strike and ISIN of each instrument.
+	Query string
+
+	// Optional filters, matched case insensitively. Name is the underlying
+	// of derivatives, like NIFTY or RELIANCE.
+	Exchange       string
+	Segment        string
+	InstrumentType string
+	Name           string
+
+	// Optional ranges, inclusive. Setting either expiry bound leaves out
+	// instruments without an expiry, and either strike bound those without
+	// a strike.
+	ExpiryFrom time.Time
+	ExpiryTo   time.Time
+	StrikeMin  float64
+	StrikeMax  float64
+
+	ActiveOnly bool
+	LotSize    int
+
+	// Limit caps the number of results, all if 0.
+	Limit int
+}
+
+// hasFilters reports whether opts narrow down the instruments other than
+// by the query.
+func (opts SearchOptions) hasFilters() bool {
+	return opts.Exchange != "" || opts.Segment != "" || opts.InstrumentType != "" || opts.Name != "" ||
+		!opts.ExpiryFrom.IsZero() || !opts.ExpiryTo.IsZero() || opts.StrikeMin > 0 || opts.StrikeMax > 0 ||
+		opts.ActiveOnly || opts.LotSize > 0
+}
+
+// Matches reports whether an instrument passes the filters of opts,
+// leaving out the query.
+func (opts SearchOptions) Matches(inst Instrument) bool {
+	return opts.match(fieldsOf(&inst))
+}
+
+// matchFields are the fields of an instrument the filters of a search
+// look at.
+type matchFields struct {
+	exchange, segment, instrumentType, name string
+	active                                  bool
+	lotSize                                 int
+	strike                                  float64
+	expiry                                  time.Time // zero if none
+}
+
+func fieldsOf(inst *Instrument) matchFields {
+	expiry, _ := inst.Expiry()
+	return matchFields{
+		exchange:       inst.Exchange,
+		segment:        inst.Segment,
+		instrumentType: inst.InstrumentType,
+		name:           inst.Name,
+		active:         inst.Active,
+		lotSize:        inst.LotSize,
+		strike:         inst.Strike,
+		expiry:         expiry,
+	}
+}
+
+// match reports whether an instrument passes the filters of opts.
+func (opts SearchOptions) match(inst matchFields) bool {
+	if (opts.Exchange != "" && !strings.EqualFold(inst.exchange, opts.Exchange)) ||
+		(opts.Segment != "" && !strings.EqualFold(inst.segment, opts.Segment)) ||
+		(opts.InstrumentType != "" && !strings.EqualFold(inst.instrumentType, opts.InstrumentType)) ||
+		(opts.Name != "" && !strings.EqualFold(inst.name, opts.Name)) {
+		return false
+	}
+	if (opts.ActiveOnly && !inst.active) || (opts.LotSize > 0 && inst.lotSize != opts.LotSize) {
+		return false
+	}
+	if !opts.ExpiryFrom.IsZero() || !opts.ExpiryTo.IsZero() {
+		if inst.expiry.IsZero() ||
+			(!opts.ExpiryFrom.IsZero() && inst.expiry.Before(dateIST(opts.ExpiryFrom))) ||
+			(!opts.ExpiryTo.IsZero() && inst.expiry.After(dateIST(opts.ExpiryTo))) {
+			return false
+		}
+	}
+	if opts.StrikeMin > 0 || opts.StrikeMax > 0 {
+		if inst.strike <= 0 ||
+			(opts.StrikeMin > 0 && inst.strike < opts.StrikeMin) ||
+			(opts.StrikeMax > 0 && inst.strike > opts.StrikeMax) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchResult is an instrument matching a search, with its rank score.
+type SearchResult struct {
+	Instrument
+	Score int
+}
+
+// Rank bonuses added to the text score of matching instruments, so that
+// equal text matches list the instrument most people mean first.
+const (
+	bonusSymbol = 20 // the query is the tradingsymbol or ID
+	bonusEquity = 6
+	bonusIndex  = 5
+	bonusFuture = 3
+	bonusNSE    = 2
+	bonusBSE    = 1
+	bonusActive = 1
+)
+
+// searchIndex is an inverted index over the words of the loaded
+// instruments. It is immutable once built; loading instruments builds a
+// new one.
+type searchIndex struct {
+	cols     columns // a view of the indexed rows
+	docs     []int32 // the row of each doc
+	bonus    []int
+	words    []string           // sorted, for prefix ranges
+	postings map[string][]int32 // word -> docs having it
+
+	schedulesOnce   sync.Once
+	expirySchedules map[string]*ExpirySchedule // exchange:underlying -> schedule
+}
+
+// newSearchIndex indexes the instruments of a store.
+func newSearchIndex(loaded *store) *searchIndex {
+	idx := &searchIndex{
+		cols:     loaded.columns,
+		docs:     make([]int32, 0, loaded.count()),
+		bonus:    make([]int, 0, loaded.count()),
+		postings: make(map[string][]int32, loaded.count()),
+	}
+
+	// Names, exchanges and types repeat across the contracts of an
+	// underlying, so each is tokenized once
+	tokenized := make(map[string][]string)
+	cachedTokenize := func(s string) []string {
+		words, ok := tokenized[s]
+		if !ok {
+			words = tokenize(s)
+			tokenized[s] = words
+		}
+		return words
+	}
+
+	var words []string
+	for row := range loaded.len() {
+		if !loaded.live(row) {
+			continue
+		}
+		inst := loaded.instrument(row)
+		doc := int32(len(idx.docs))
+		idx.docs = append(idx.docs, row)
+		idx.bonus = append(idx.bonus, rankBonus(&inst))
+		words = instrumentWords(words[:0], &inst, cachedTokenize)
+		for _, w := range words {
+			p := idx.postings[w]
+			// Words repeat within an instrument, like its name in its symbol
+			if n := len(p); n > 0 && p[n-1] == doc {
+				continue
+			}
+			idx.postings[w] = append(p, doc)
+		}
+	}
+	idx.words = make([]string, 0, len(idx.postings))
+	for w := range idx.postings {
+		idx.words = append(idx.words, w)
+	}
+	sort.Strings(idx.words)
+	return idx
+}
+
+// memoryUsage estimates the bytes the index takes on the heap beyond the
+// store it views: the docs and their bonuses, the words and the postings
+// of each. A nil index takes nothing.
+func (idx *searchIndex) memoryUsage() int64 {
+	if idx == nil {
+		return 0
+	}
+	size := int64(cap(idx.docs))*4 + int64(cap(idx.bonus))*8 + int64(cap(idx.words))*16
+	for _, w := range idx.words {
+		size += int64(len(w))
+	}
+	// The postings are sized for a word per doc up front
+	size += sizedMapBytes(max(len(idx.postings), len(idx.docs)), 16+24)
+	for _, docs := range idx.postings {
+		size += int64(cap(docs)) * 4
+	}
+	return size
+}
+
+// fields returns the fields the filters of a search look at of a doc.
+func (idx *searchIndex) fields(doc int32) matchFields {
+	c, row := &idx.cols, idx.docs[doc]
+	return matchFields{
+		exchange:       c.str(c.exchanges, row),
+		segment:        c.str(c.segments, row),
+		instrumentType: c.str(c.instrumentTypes, row),
+		name:           c.str(c.names, row),
+		active:         c.active[row],
+		lotSize:        int(c.lotSizes[row]),
+		strike:         c.strikes[row],
+		expiry:         c.expiry(row),
+	}
+}
+
+// instrument returns the instrument of a doc.
+func (idx *searchIndex) instrument(doc int32) Instrument {
+	return idx.cols.instrument(idx.docs[doc])
+}
+
+// instrumentWords appends the searchable words of an instrument to words.
+// Symbols like NIFTY24DEC24000CE are also split where letters and digits
+// meet, so "nifty 24000 ce" finds them.
+func instrumentWords(words []string, inst *Instrument, tokenizeCached func(string) []string) []string {
+	start := len(words)
+	if isAlphaNum(inst.Tradingsymbol) {
+		words = append(words, strings.ToLower(inst.Tradingsymbol))
+	} else {
+		words = append(words, tokenize(inst.Tradingsymbol)...)
+	}
+	for _, w := range words[start:] {
+		if parts := splitAlphaNum(w); len(parts) > 1 {
+			words = append(words, parts...)
+		}
+	}
+	words = append(words, tokenizeCached(inst.Name)...)
+	words = append(words, tokenizeCached(inst.Exchange)...)
+	words = append(words, tokenizeCached(inst.InstrumentType)...)
+	if inst.ISIN != "" {
+		words = append(words, strings.ToLower(inst.ISIN))
+	}
+	if inst.Strike > 0 {
+		words = append(words, strconv.FormatFloat(inst.Strike, 'f', -1, 64))
+	}
+	return words
+}
+
+// isAlphaNum reports whether s has only ASCII letters and digits, so it
+// tokenizes to itself lowercased.
+func isAlphaNum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// splitAlphaNum splits a word where it changes between letters and digits.
+func splitAlphaNum(w string) []string {
+	var parts []string
+	start := 0
+	for i := 1; i < len(w); i++ {
+		if unicode.IsDigit(rune(w[i])) != unicode.IsDigit(rune(w[i-1])) {
+			parts = append(parts, w[start:i])
+			start = i
+		}
+	}
+	return append(parts, w[start:])
+}
+
+// rankBonus is the part of an instrument's score that doesn't depend on
+// the query.
+func rankBonus(inst *Instrument) int {
+	bonus := 0
+	switch {
+	case inst.InstrumentType == "EQ":
+		bonus += bonusEquity
+	case inst.Segment == segIndices:
+		bonus += bonusIndex
+	case inst.InstrumentType == "FUT":
+		bonus += bonusFuture
+	}
+	switch inst.Exchange {
+	case "NSE", "NFO", "CDS":
+		bonus += bonusNSE
+	case "BSE", "BFO", "BCD":
+		bonus += bonusBSE
+	}
+	if inst.Active {
+		bonus += bonusActive
+	}
+	return bonus
+}
+
+// matchWord returns the docs having a word matching the query word, with
+// the best score of each. Exact and prefix matches come from the sorted
+// words; substrings and typos are only looked for when there are none.
+func (idx *searchIndex) matchWord(q string) map[int32]int {
+	scores := make(map[int32]int)
+	add := func(w string, score int) {
+		for _, doc := range idx.postings[w] {
+			if score > scores[doc] {
+				scores[doc] = score
+			}
+		}
+	}
+
+	start := sort.SearchStrings(idx.words, q)
+	for _, w := range idx.words[start:] {
+		if !strings.HasPrefix(w, q) {
+			break
+		}
+		add(w, matchToken(q, w))
+	}
+	if len(scores) > 0 {
+		return scores
+	}
+
+	for _, w := range idx.words {
+		if score := matchToken(q, w); score > 0 {
+			add(w, score)
+		}
+	}
+	return scores
+}
+
+// search returns the instruments matching every word of the query that
+// pass the filters, best first.
+func (idx *searchIndex) search(opts SearchOptions) []SearchResult {
+	qWords := tokenize(opts.Query)
+	if len(qWords) == 0 {
+		if !opts.hasFilters() {
+			return []SearchResult{}
+		}
+		return idx.list(opts)
+	}
+
+	var scores map[int32]int
+	for _, q := range qWords {
+		matches := idx.matchWord(q)
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for doc, score := range scores {
+			if s, ok := matches[doc]; ok {
+				scores[doc] = score + s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	// The query can be the symbol or ID, or close to the symbol
+	id := strings.ToUpper(strings.TrimSpace(opts.Query))
+	symbol := strings.Join(qWords, "")
+	out := make([]SearchResult, 0, len(scores))
+	for doc, score := range scores {
+		if !opts.match(idx.fields(doc)) {
+			continue
+		}
+		inst := idx.instrument(doc)
+		score += idx.bonus[doc]
+		if inst.ID == id || strings.EqualFold(inst.Tradingsymbol, symbol) {
+			score += bonusSymbol
+		} else {
+			score += matchToken(symbol, strings.ToLower(inst.Tradingsymbol))
+		}
+		out = append(out, SearchResult{Instrument: inst, Score: score})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		// Shorter symbols are closer to the query, like the equity to its
+		// contracts
+		if len(out[i].Tradingsymbol) != len(out[j].Tradingsymbol) {
+			return len(out[i].Tradingsymbol) < len(out[j].Tradingsymbol)
+		}
+		return out[i].ID < out[j].ID
+	})
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		out = out[:opts.Limit]
+	}
+	return out
+}
+
+// list returns the instruments passing the filters, ordered like an
+// option chain: by name, expiry, strike and type.
+func (idx *searchIndex) list(opts SearchOptions) []SearchResult {
+	var docs []int32
+	for doc := range int32(len(idx.docs)) {
+		if opts.match(idx.fields(doc)) {
+			docs = append(docs, doc)
+		}
+	}
+	c := &idx.cols
+	sort.Slice(docs, func(i, j int) bool {
+		a, b := idx.docs[docs[i]], idx.docs[docs[j]]
+		if na, nb := c.str(c.names, a), c.str(c.names, b); na != nb {
+			return na < nb
+		}
+		if ea, eb := c.expiries[a], c.expiries[b]; ea != eb {
+			return ea < eb
+		}
+		if c.strikes[a] != c.strikes[b] {
+			return c.strikes[a] < c.strikes[b]
+		}
+		return c.str(c.ids, a) < c.str(c.ids, b)
+	})
+	if opts.Limit > 0 && len(docs) > opts.Limit {
+		docs = docs[:opts.Limit]
+	}
+
+	out := make([]SearchResult, len(docs))
+	for i, doc := range docs {
+		out[i] = SearchResult{Instrument: idx.instrument(doc), Score: idx.bonus[doc]}
+	}
+	return out
+}
+
+// Search returns the instruments matching the query and filters, ranked
+// best first. Query words match whole words, prefixes, and when nothing
+// else does, substrings or words with a single typo. Without a query, the
+// instruments passing the filters are listed by name, expiry and strike;
+// without either, nothing is returned.
+func (m *Manager) Search(opts SearchOptions) []SearchResult {
+	return m.searchIndex().search(opts)
+}
+
+// searchIndex returns the search index, building it if instruments were
+// inserted since it was last built.
+func (m *Manager) searchIndex() *searchIndex {
+	m.mutex.RLock()
+	idx := m.index
+	m.mutex.RUnlock()
+	if idx != nil {
+		return idx
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.index == nil {
+		m.index = newSearchIndex(m.loaded)
+	}
+	return m.index
+}
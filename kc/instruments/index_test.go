@@ -0,0 +1,218 @@
+package instruments
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// getSearchTestInstruments returns an equity listed on NSE and BSE with its
+// futures and options, next to other instruments sharing its words.
+func getSearchTestInstruments() map[uint32]*Instrument {
+	list := []*Instrument{
+		{ID: "NFO:RELIANCE24DECFUT", InstrumentToken: 13238274, Tradingsymbol: "RELIANCE24DECFUT", Name: "RELIANCE", InstrumentType: "FUT", Segment: "NFO-FUT", Exchange: "NFO", Active: true},
+		{ID: "NFO:RELIANCE24DEC1300CE", InstrumentToken: 13238530, Tradingsymbol: "RELIANCE24DEC1300CE", Name: "RELIANCE", Strike: 1300, InstrumentType: "CE", Segment: "NFO-OPT", Exchange: "NFO", Active: true},
+		{ID: "NFO:RELIANCE24DEC1300PE", InstrumentToken: 13238786, Tradingsymbol: "RELIANCE24DEC1300PE", Name: "RELIANCE", Strike: 1300, InstrumentType: "PE", Segment: "NFO-OPT", Exchange: "NFO", Active: true},
+		{ID: "BSE:RELIANCE", InstrumentToken: 128083204, Tradingsymbol: "RELIANCE", Name: "RELIANCE INDUSTRIES", ISIN: "INE002A01018", InstrumentType: "EQ", Segment: "BSE", Exchange: "BSE", Active: true},
+		{ID: "NSE:RELIANCE", InstrumentToken: 738561, Tradingsymbol: "RELIANCE", Name: "RELIANCE INDUSTRIES", ISIN: "INE002A01018", InstrumentType: "EQ", Segment: "NSE", Exchange: "NSE", Active: true},
+		{ID: "NSE:RPOWER", InstrumentToken: 3906305, Tradingsymbol: "RPOWER", Name: "RELIANCE POWER", ISIN: "INE614G01033", InstrumentType: "EQ", Segment: "NSE", Exchange: "NSE", Active: true},
+		{ID: "NSE:HDFCBANK", InstrumentToken: 341249, Tradingsymbol: "HDFCBANK", Name: "HDFC BANK", ISIN: "INE040A01034", InstrumentType: "EQ", Segment: "NSE", Exchange: "NSE", Active: true},
+		{ID: "NSE:NIFTY BANK", InstrumentToken: 260105, Tradingsymbol: "NIFTY BANK", Name: "NIFTY BANK", InstrumentType: "EQ", Segment: "INDICES", Exchange: "NSE"},
+	}
+	out := make(map[uint32]*Instrument, len(list))
+	for _, inst := range list {
+		out[inst.InstrumentToken] = inst
+	}
+	return out
+}
+
+func newSearchTestManager() *Manager {
+	manager := newTestManagerWithoutUpdate()
+	manager.LoadMap(getSearchTestInstruments())
+	return manager
+}
+
+func resultIDs(results []SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestSearchRanking(t *testing.T) {
+	manager := newSearchTestManager()
+	defer manager.Shutdown()
+
+	results := manager.Search(SearchOptions{Query: "reliance"})
+	ids := resultIDs(results)
+	expected := []string{"NSE:RELIANCE", "BSE:RELIANCE", "NFO:RELIANCE24DECFUT"}
+	if len(ids) != 6 {
+		t.Fatalf("Expected 6 instruments matching reliance, got %v", ids)
+	}
+	for i, id := range expected {
+		if ids[i] != id {
+			t.Errorf("Expected %s at rank %d, got %v", id, i+1, ids)
+		}
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("Expected results best first, got %v", results)
+		}
+	}
+}
+
+func TestSearchMatching(t *testing.T) {
+	manager := newSearchTestManager()
+	defer manager.Shutdown()
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"RELIANCE", "NSE:RELIANCE"},
+		{"NSE:RELIANCE", "NSE:RELIANCE"},
+		{"relia", "NSE:RELIANCE"},
+		{"relaince", "NSE:RELIANCE"},     // transposed letters
+		{"reliance power", "NSE:RPOWER"}, // every word must match
+		{"hdfc bank", "NSE:HDFCBANK"},
+		{"fcban", "NSE:HDFCBANK"}, // substring
+		{"reliance 1300 pe", "NFO:RELIANCE24DEC1300PE"},
+		{"INE002A01018", "NSE:RELIANCE"},
+	}
+	for _, tt := range tests {
+		results := manager.Search(SearchOptions{Query: tt.query})
+		if len(results) == 0 || results[0].ID != tt.expected {
+			t.Errorf("Expected %s first for %q, got %v", tt.expected, tt.query, resultIDs(results))
+		}
+	}
+
+	for _, query := range []string{"", "::", "tata", "reliance tata"} {
+		if results := manager.Search(SearchOptions{Query: query}); len(results) != 0 {
+			t.Errorf("Expected no results for %q, got %v", query, resultIDs(results))
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	manager := newSearchTestManager()
+	defer manager.Shutdown()
+
+	tests := []struct {
+		opts     SearchOptions
+		expected []string
+	}{
+		{SearchOptions{Query: "reliance", Exchange: "bse"}, []string{"BSE:RELIANCE"}},
+		{SearchOptions{Query: "reliance", Segment: "NFO-OPT"}, []string{"NFO:RELIANCE24DEC1300CE", "NFO:RELIANCE24DEC1300PE"}},
+		{SearchOptions{Query: "reliance", InstrumentType: "fut"}, []string{"NFO:RELIANCE24DECFUT"}},
+		{SearchOptions{Query: "reliance", Exchange: "NSE", InstrumentType: "EQ", Limit: 1}, []string{"NSE:RELIANCE"}},
+		{SearchOptions{Query: "bank", Segment: "INDICES"}, []string{"NSE:NIFTY BANK"}},
+	}
+	for _, tt := range tests {
+		got := resultIDs(manager.Search(tt.opts))
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Expected %v for %+v, got %v", tt.expected, tt.opts, got)
+		}
+	}
+}
+
+func TestSearchIndexRebuild(t *testing.T) {
+	manager := newSearchTestManager()
+	defer manager.Shutdown()
+
+	if results := manager.Search(SearchOptions{Query: "infy"}); len(results) != 0 {
+		t.Fatalf("Expected no INFY before loading it, got %v", resultIDs(results))
+	}
+
+	manager.LoadMap(map[uint32]*Instrument{
+		408065: {ID: "NSE:INFY", InstrumentToken: 408065, Tradingsymbol: "INFY", Name: "INFOSYS", InstrumentType: "EQ", Segment: "NSE", Exchange: "NSE"},
+	})
+	if results := manager.Search(SearchOptions{Query: "infosys"}); len(results) != 1 || results[0].ID != "NSE:INFY" {
+		t.Errorf("Expected INFY after LoadMap, got %v", resultIDs(results))
+	}
+	if results := manager.Search(SearchOptions{Query: "reliance industries"}); len(results) != 2 {
+		t.Errorf("Expected instruments loaded earlier to stay searchable, got %v", resultIDs(results))
+	}
+
+	manager.Insert(&Instrument{ID: "NSE:TCS", InstrumentToken: 2953217, Tradingsymbol: "TCS", Name: "TATA CONSULTANCY SERV LT", InstrumentType: "EQ", Segment: "NSE", Exchange: "NSE"})
+	if results := manager.Search(SearchOptions{Query: "tata consultancy"}); len(results) != 1 || results[0].ID != "NSE:TCS" {
+		t.Errorf("Expected TCS after Insert, got %v", resultIDs(results))
+	}
+}
+
+// getBenchmarkInstruments returns a dump shaped like the Kite one: equities
+// on NSE and BSE, each with futures and a chain of options for three
+// expiries.
+func getBenchmarkInstruments() map[uint32]*Instrument {
+	out := make(map[uint32]*Instrument)
+	token := uint32(1)
+	add := func(inst *Instrument) {
+		inst.InstrumentToken = token
+		inst.ID = inst.Exchange + ":" + inst.Tradingsymbol
+		inst.Active = true
+		out[token] = inst
+		token++
+	}
+	for u := 0; u < 500; u++ {
+		symbol := fmt.Sprintf("STOCK%c%c%d", 'A'+u%26, 'A'+(u/26)%26, u)
+		name := fmt.Sprintf("COMPANY %d INDUSTRIES", u)
+		for _, exchange := range []string{"NSE", "BSE"} {
+			add(&Instrument{Tradingsymbol: symbol, Name: name, InstrumentType: "EQ", Segment: exchange, Exchange: exchange})
+		}
+		for _, expiry := range []string{"24DEC", "25JAN", "25FEB"} {
+			add(&Instrument{Tradingsymbol: symbol + expiry + "FUT", Name: symbol, InstrumentType: "FUT", Segment: "NFO-FUT", Exchange: "NFO"})
+			for s := 1; s <= 40; s++ {
+				for _, kind := range []string{"CE", "PE"} {
+					strike := float64(s * 50)
+					add(&Instrument{
+						Tradingsymbol:  fmt.Sprintf("%s%s%d%s", symbol, expiry, s*50, kind),
+						Name:           symbol,
+						Strike:         strike,
+						InstrumentType: kind,
+						Segment:        "NFO-OPT",
+						Exchange:       "NFO",
+					})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// BenchmarkSearchIndex benchmarks ranked searches of the index
+func BenchmarkSearchIndex(b *testing.B) {
+	manager := newTestManagerWithoutUpdate()
+	manager.LoadMap(getBenchmarkInstruments())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager.Search(SearchOptions{Query: "stockkk270", Limit: 20})
+	}
+}
+
+// BenchmarkSearchLinearScan benchmarks the substring scan the index
+// replaces, for comparison
+func BenchmarkSearchLinearScan(b *testing.B) {
+	manager := newTestManagerWithoutUpdate()
+	manager.LoadMap(getBenchmarkInstruments())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager.Filter(func(inst Instrument) bool {
+			return strings.Contains(strings.ToLower(inst.ID), "stockkk270")
+		})
+	}
+}
+
+// BenchmarkSearchIndexBuild benchmarks building the index of a full dump
+func BenchmarkSearchIndexBuild(b *testing.B) {
+	instruments := storeOf(getBenchmarkInstruments())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newSearchIndex(instruments)
+	}
+}
@@ -1,16 +1,12 @@
 package instruments
 
 import (
-	"bufio"
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"net/http"
-	"strings"
 	"sync"
 	"time"
 )
@@ -22,6 +18,8 @@ const (
 	defaultUpdateMinute   = 0 // 0 minutes
 	defaultRetryAttempts  = 3
 	defaultRetryDelay     = 3 * time.Second
+
+	defaultOfflineRefreshInterval = time.Minute
 )
 
 var (
@@ -37,6 +35,14 @@ var (
 	// ErrSegmentNotFound is returned when segment was not found in the
 	// loaded map.
 	ErrSegmentNotFound = errors.New("instrument segment not found")
+
+	// ErrNoDerivatives is returned when no derivatives match the options
+	// of an expiry or strike lookup.
+	ErrNoDerivatives = errors.New("no matching derivatives")
+
+	// ErrMemoryLimit is returned when loaded instruments would take more
+	// memory than the configured limit.
+	ErrMemoryLimit = errors.New("instruments exceed the memory limit")
 )
 
 // UpdateConfig holds configuration for instrument updates
@@ -51,8 +57,23 @@ type UpdateConfig struct {
 	RetryDelay time.Duration
 	// EnableScheduler enables automatic scheduled updates
 	EnableScheduler bool
-	// MemoryLimit is the maximum memory usage in bytes (0 = no limit)
+	// MemoryLimit is the maximum memory usage in bytes (0 = no limit).
+	// Loads of instruments that would take more, with their search index,
+	// are refused, keeping the instruments in use.
 	MemoryLimit int64
+	// TradingDay optionally reports whether the exchanges trade on a day.
+	// When set, scheduled updates are skipped on other days.
+	TradingDay func(day time.Time) bool
+	// SnapshotPath is the file every load from Kite is saved to, gzip
+	// compressed. When Kite can't be reached at startup the manager starts
+	// from it instead. Empty disables snapshots.
+	SnapshotPath string
+	// OfflineRefreshInterval is how often a manager started from a snapshot
+	// tries to load the instruments from Kite again
+	OfflineRefreshInterval time.Duration
+	// Source is where instruments are loaded from, the Kite JSON dump if
+	// empty
+	Source Source
 }
 
 // DefaultUpdateConfig returns the default update configuration
@@ -64,6 +85,8 @@ func DefaultUpdateConfig() *UpdateConfig {
 		RetryDelay:      defaultRetryDelay,
 		EnableScheduler: true,
 		MemoryLimit:     0, // No limit by default
+
+		OfflineRefreshInterval: defaultOfflineRefreshInterval,
 	}
 }
 
@@ -73,25 +96,35 @@ type UpdateStats struct {
 	LastUpdateCount     int
 	TotalUpdates        int
 	FailedUpdates       int
-	MemoryUsageBytes    int64
 	ScheduledNextUpdate time.Time
+
+	// MemoryUsageBytes is the memory the loaded instruments and the
+	// search index built from them take
+	MemoryUsageBytes int64
+
+	// SnapshotTime is when the instruments in use were fetched from Kite,
+	// if they were loaded from a snapshot. SnapshotAge is how long ago that
+	// was. Both are zero once the instruments are loaded from Kite again.
+	SnapshotTime time.Time
+	SnapshotAge  time.Duration
 }
 
 // Manager provides thread-safe access to instrument data.
 // All public methods are thread-safe and can be called concurrently.
 type Manager struct {
-	// Core instrument maps - protected by mutex
-	isinToInstruments map[string][]*Instrument
-	idToInst          map[string]*Instrument
-	idToToken         map[string]uint32
-	tokenToInstrument map[uint32]*Instrument
+	// loaded are the instruments and their lookups - protected by mutex
+	loaded *store
 
-	// NSE=1, BSE=2 etc. This is extracted from instrument tokens
-	// as they're loaded.
-	segmentIDs map[string]uint32
+	// index is the search index of the loaded instruments, rebuilt when
+	// they are loaded and nil until the next search after an insert
+	index *searchIndex
 
 	lastUpdated time.Time
 
+	// snapshotTime is when the loaded instruments were fetched, if they
+	// came from a snapshot
+	snapshotTime time.Time
+
 	// Configuration and scheduling
 	config *UpdateConfig
 	stats  UpdateStats
@@ -101,10 +134,23 @@ type Manager struct {
 	schedulerCancel context.CancelFunc
 	schedulerDone   chan struct{}
 
+	// refreshing runs the refresh of a manager started from a snapshot
+	refreshing sync.WaitGroup
+
+	// loadMu serialises replacing the loaded instruments, so that every
+	// load is diffed against the one it replaces
+	loadMu sync.Mutex
+
+	// diffs are the changes of the recent loads, latest first, and
+	// changeListeners are called with each new one
+	changesMu       sync.Mutex
+	diffs           []Diff
+	changeListeners []func(Diff)
+
 	// Logger for this manager
 	logger *slog.Logger
 
-	// mutex protects all map fields above
+	// mutex protects all fields above
 	// Read operations use RLock, write operations use Lock
 	mutex sync.RWMutex
 }
@@ -122,6 +168,9 @@ func New(cfg Config) (*Manager, error) {
 	if cfg.UpdateConfig == nil {
 		cfg.UpdateConfig = DefaultUpdateConfig()
 	}
+	if err := cfg.UpdateConfig.Source.Validate(); err != nil {
+		return nil, err
+	}
 
 	manager := newManagerWithConfig(cfg.UpdateConfig, cfg.Logger)
 
@@ -129,9 +178,13 @@ func New(cfg Config) (*Manager, error) {
 		// Test mode - load test data
 		manager.LoadMap(cfg.TestData)
 	} else {
-		// Production mode - load from HTTP
+		// Production mode - load from HTTP, or from the last snapshot
+		// when Kite can't be reached
 		if err := manager.UpdateInstruments(); err != nil {
-			return nil, fmt.Errorf("failed to load initial data: %w", err)
+			if bootErr := manager.bootFromSnapshot(err); bootErr != nil {
+				manager.Shutdown()
+				return nil, fmt.Errorf("failed to load initial data: %w", bootErr)
+			}
 		}
 	}
 
@@ -156,17 +209,13 @@ func newManagerWithConfig(config *UpdateConfig, logger *slog.Logger) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	m := &Manager{
-		isinToInstruments: make(map[string][]*Instrument),
-		idToInst:          make(map[string]*Instrument),
-		idToToken:         make(map[string]uint32),
-		tokenToInstrument: make(map[uint32]*Instrument),
-		segmentIDs:        make(map[string]uint32),
-		lastUpdated:       time.Now(),
-		config:            config,
-		logger:            logger,
-		schedulerCtx:      ctx,
-		schedulerCancel:   cancel,
-		schedulerDone:     make(chan struct{}),
+		loaded:          newStore(0),
+		lastUpdated:     time.Now(),
+		config:          config,
+		logger:          logger,
+		schedulerCtx:    ctx,
+		schedulerCancel: cancel,
+		schedulerDone:   make(chan struct{}),
 	}
 
 	// Start scheduler if enabled
@@ -250,6 +299,10 @@ func (m *Manager) updateInstrumentsWithRetry(force bool) error {
 			lastErr = err
 			m.updateStats(false, count)
 			m.logger.Error("Instrument update failed", "attempt", attempt+1, "error", err)
+			if errors.Is(err, ErrMemoryLimit) {
+				// Loading them again won't make them fit
+				return err
+			}
 			continue
 		}
 
@@ -265,7 +318,7 @@ func (m *Manager) updateInstrumentsWithRetry(force bool) error {
 func (m *Manager) updateInstruments(force bool) (int, error) {
 	// Check if we need to update - using a read lock
 	m.mutex.RLock()
-	count := len(m.tokenToInstrument)
+	count := m.loaded.count()
 	lastUpdated := m.lastUpdated
 	m.mutex.RUnlock()
 
@@ -277,169 +330,128 @@ func (m *Manager) updateInstruments(force bool) (int, error) {
 
 	m.logger.Info("Updating instruments...", "force", force)
 
-	// Load instruments from Kite API
-	m.logger.Info("Loading instruments from Kite API URL")
-	instruments, err := m.loadFromURL()
+	// Load instruments from Kite API, or the configured source
+	m.logger.Info("Loading instruments from source")
+	instruments, err := m.loadFromSource()
 	if err != nil {
-		m.logger.Error("Error loading from URL", "error", err)
-		return 0, fmt.Errorf("error loading from URL: %v", err)
-	}
-
-	// Create temporary maps instead of modifying existing maps under a lock
-	isinToInstruments := make(map[string][]*Instrument)
-	idToInst := make(map[string]*Instrument)
-	idToToken := make(map[string]uint32)
-	tokenToInstrument := make(map[uint32]*Instrument)
-	segmentIDs := make(map[string]uint32)
-
-	// Process all instruments without holding a lock
-	m.logger.Info("Processing instruments", "count", len(instruments))
-	for _, inst := range instruments {
-		// ISIN -> Instrument
-		if inst.ISIN != "" {
-			if _, ok := isinToInstruments[inst.ISIN]; !ok {
-				isinToInstruments[inst.ISIN] = []*Instrument{}
-			}
-			isinToInstruments[inst.ISIN] = append(isinToInstruments[inst.ISIN], inst)
-		}
-
-		// ID -> Token
-		idToToken[inst.ID] = inst.InstrumentToken
-
-		// Get the exchange token out of the instrument and add it to
-		// the segment name -> ID map.
-		seg := inst.Exchange
-		if inst.Segment == segIndices {
-			seg = inst.Segment
-		}
-		if _, ok := segmentIDs[seg]; !ok {
-			segmentIDs[seg] = GetSegmentID(inst.InstrumentToken)
-		}
-
-		// ID -> Instrument
-		idToInst[inst.ID] = inst
-
-		// segment:tradingsymbol
-		// (to cover indices that are mapped by segments)
-		// and not exchanges always.
-		if inst.Segment == segIndices {
-			idToInst[inst.Segment+":"+inst.Tradingsymbol] = inst
-		}
-
-		tokenToInstrument[inst.InstrumentToken] = inst
+		m.logger.Error("Error loading from source", "error", err)
+		return 0, fmt.Errorf("error loading from source: %v", err)
 	}
 
-	// Now that all processing is done, acquire the lock to update the maps
-	m.mutex.Lock()
-	m.isinToInstruments = isinToInstruments
-	m.idToInst = idToInst
-	m.idToToken = idToToken
-	m.tokenToInstrument = tokenToInstrument
-	m.segmentIDs = segmentIDs
-	m.lastUpdated = time.Now()
-	instrumentCount := len(tokenToInstrument)
-	m.mutex.Unlock()
+	instrumentCount, err := m.replaceAll(instruments, time.Now(), false)
+	if err != nil {
+		return 0, err
+	}
+	m.saveSnapshot(instruments)
 
 	m.logger.Info("Loaded instruments", "count", instrumentCount)
 	return instrumentCount, nil
 }
 
-func (m *Manager) loadFromURL() (map[uint32]*Instrument, error) {
-	m.logger.Debug("Creating HTTP request to fetch instruments", "url", instrumentsURL)
-	// HTTP GET request to instruments URL
-	req, err := http.NewRequest("GET", instrumentsURL, nil)
-	if err != nil {
-		m.logger.Error("Error creating HTTP request", "error", err)
-		return nil, fmt.Errorf("error creating request: %v", err)
+// replaceAll replaces the loaded instruments with the given ones, fetched
+// from Kite at loadedAt, either just now or earlier into a snapshot.
+// Instruments that would take more memory than the limit, with their index,
+// are refused.
+func (m *Manager) replaceAll(instruments *store, loadedAt time.Time, fromSnapshot bool) (int, error) {
+	// Build the index without holding a lock
+	m.logger.Info("Processing instruments", "count", instruments.count())
+	index := newSearchIndex(instruments)
+	if err := m.checkMemoryLimit(instruments, index); err != nil {
+		return 0, err
 	}
 
-	// Add compression header
-	req.Header.Add("Accept-Encoding", "gzip")
-	m.logger.Debug("Added gzip Accept-Encoding header")
+	// Concurrent loads (the scheduler, a forced update, the refresh after
+	// starting from a snapshot) take turns to diff and swap
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
 
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	// Diff against the instruments in use, unless this is the first load
+	var diff *Diff
+	m.mutex.RLock()
+	if m.loaded.count() > 0 {
+		d := diffInstruments(m.loaded, instruments, m.lastUpdated, loadedAt)
+		diff = &d
 	}
-	m.logger.Debug("Created HTTP client with timeout", "timeout", "30s")
+	m.mutex.RUnlock()
 
-	// Execute request
-	m.logger.Debug("Sending HTTP request to fetch instruments")
-	resp, err := client.Do(req)
-	if err != nil {
-		m.logger.Error("HTTP request failed", "error", err)
-		return nil, fmt.Errorf("error fetching instruments: %v", err)
+	m.mutex.Lock()
+	m.index = index
+	m.loaded = instruments
+	m.lastUpdated = loadedAt
+	m.snapshotTime = time.Time{}
+	if fromSnapshot {
+		m.snapshotTime = loadedAt
 	}
-	defer func() { _ = resp.Body.Close() }()
-
-	m.logger.Debug("Received HTTP response",
-		"status", resp.StatusCode,
-		"content-length", resp.Header.Get("Content-Length"),
-		"content-encoding", resp.Header.Get("Content-Encoding"))
+	m.mutex.Unlock()
 
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		m.logger.Debug("Response is gzip compressed, creating gzip reader")
-		gzipReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			m.logger.Error("Failed to create gzip reader", "error", err)
-			return nil, fmt.Errorf("error creating gzip reader: %v", err)
-		}
-		defer func() { _ = gzipReader.Close() }()
-		reader = gzipReader
+	if diff != nil {
+		m.publishChanges(*diff)
 	}
-
-	m.logger.Debug("Starting to parse instruments JSON")
-	return m.parseInstrumentsJSON(reader)
+	return instruments.count(), nil
 }
 
-func (m *Manager) parseInstrumentsJSON(reader io.Reader) (map[uint32]*Instrument, error) {
-	m.logger.Debug("Creating instrument map")
-	mp := make(map[uint32]*Instrument)
-
-	// Process JSONL formatted data
-	m.logger.Debug("Creating scanner for JSONL data")
-	scanner := bufio.NewScanner(reader)
-
-	// Increase scanner buffer to handle large lines
-	const maxCapacity = 512 * 1024 // 512KB
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
-	m.logger.Debug("Scanner buffer size increased", "size_bytes", maxCapacity)
+// checkMemoryLimit fails with ErrMemoryLimit when the instruments and their
+// search index, if built yet, take more memory than the limit. Decoding
+// checks it as rows are added, so a dump too large to fit is abandoned
+// before it is all in memory.
+func (m *Manager) checkMemoryLimit(instruments *store, index *searchIndex) error {
+	m.mutex.RLock()
+	limit := m.config.MemoryLimit
+	m.mutex.RUnlock()
+	if usage := instruments.memoryUsage() + index.memoryUsage(); limit > 0 && usage > limit {
+		m.logger.Error("Instruments exceed the memory limit, keeping the ones in use",
+			"count", instruments.count(), "memory_bytes", usage, "limit_bytes", limit)
+		return fmt.Errorf("%w: %d instruments take %d bytes, the limit is %d", ErrMemoryLimit, instruments.count(), usage, limit)
+	}
+	return nil
+}
 
-	count := 0
-	m.logger.Debug("Starting to scan JSON lines")
-	// Read and process each line as a separate JSON object
-	for scanner.Scan() {
-		line := scanner.Text()
+// parseInstrumentsJSON decodes a JSON dump of instruments into a new
+// store.
+func (m *Manager) parseInstrumentsJSON(reader io.Reader) (*store, error) {
+	instruments := newStore(0)
+	if err := m.decodeInstrumentsJSON(reader, instruments); err != nil {
+		return nil, err
+	}
+	instruments.done()
 
-		// Skip empty lines
-		if len(strings.TrimSpace(line)) == 0 {
-			continue
-		}
+	m.logger.Debug("Successfully parsed instruments", "count", instruments.count())
+	return instruments, nil
+}
 
+// decodeInstrumentsJSON decodes the JSON lines of a dump as they stream
+// in, straight into a store. Instruments without an ID get
+// exchange:tradingsymbol.
+func (m *Manager) decodeInstrumentsJSON(reader io.Reader, into *store) error {
+	decoder := json.NewDecoder(reader)
+	for {
 		var instrument Instrument
-		if err := json.Unmarshal([]byte(line), &instrument); err != nil {
-			m.logger.Error("JSON unmarshal error", "error", err, "line", line)
-			return nil, fmt.Errorf("error parsing instrument JSON: %v (line: %s)", err, line)
+		if err := decoder.Decode(&instrument); err == io.EOF {
+			break
+		} else if err != nil {
+			m.logger.Error("JSON decode error", "error", err, "offset", decoder.InputOffset())
+			return fmt.Errorf("error parsing instrument JSON: %v (offset: %d)", err, decoder.InputOffset())
 		}
-
-		// Process each instrument
-		mp[instrument.InstrumentToken] = &instrument
-
-		count++
-		if count%10000 == 0 {
-			m.logger.Debug("Processing instruments progress", "count", count)
+		if instrument.ID == "" {
+			instrument.ID = instrument.Exchange + ":" + instrument.Tradingsymbol
 		}
-	}
+		into.add(&instrument)
 
-	if err := scanner.Err(); err != nil {
-		m.logger.Error("Scanner error", "error", err)
-		return nil, fmt.Errorf("error reading instruments file: %v", err)
+		if n := into.len(); n%10000 == 0 {
+			m.logger.Debug("Processing instruments progress", "count", n)
+			if err := m.checkMemoryLimit(into, nil); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
 
-	m.logger.Debug("Successfully parsed instruments", "count", len(mp))
-	return mp, nil
+// Replace replaces all the loaded instruments with the given ones, like a
+// load from Kite does, publishing what changed.
+func (m *Manager) Replace(tokenToInstrument map[uint32]*Instrument) error {
+	_, err := m.replaceAll(storeOf(tokenToInstrument), time.Now(), false)
+	return err
 }
 
 // LoadMap from tokenToInstrument map to the manager.
@@ -460,6 +472,8 @@ func (m *Manager) LoadMap(tokenToInstrument map[uint32]*Instrument) {
 			m.logger.Debug("LoadMap: progress", "inserted", count, "total", len(tokenToInstrument))
 		}
 	}
+	m.loaded.done()
+	m.index = newSearchIndex(m.loaded)
 	m.logger.Debug("LoadMap: completed", "count", count)
 }
 
@@ -474,53 +488,40 @@ func (m *Manager) Insert(inst *Instrument) {
 // insertUnsafe inserts a new instrument without acquiring locks.
 // This method must be called with the mutex already held.
 func (m *Manager) insertUnsafe(inst *Instrument) {
-	// ISIN -> Instrument
-	if inst.ISIN != "" {
-		if _, ok := m.isinToInstruments[inst.ISIN]; !ok {
-			m.isinToInstruments[inst.ISIN] = []*Instrument{}
-		}
-		m.isinToInstruments[inst.ISIN] = append(m.isinToInstruments[inst.ISIN], inst)
+	m.index = nil
+	if m.loaded == nil {
+		m.loaded = newStore(0)
 	}
-
-	// ID -> Token
-	m.idToToken[inst.ID] = inst.InstrumentToken
-
-	// Get the exchange token out of the instrument and add it to
-	// the segment name -> ID map.
-	seg := inst.Exchange
-	if inst.Segment == segIndices {
-		seg = inst.Segment
-	}
-	if _, ok := m.segmentIDs[seg]; !ok {
-		m.segmentIDs[seg] = GetSegmentID(inst.InstrumentToken)
-	}
-
-	// ID -> Instrument
-	m.idToInst[inst.ID] = inst
-
-	// segment:tradingsymbol
-	// (to cover indices that are mapped by segments)
-	// and not exchanges always.
-	if inst.Segment == segIndices {
-		m.idToInst[inst.Segment+":"+inst.Tradingsymbol] = inst
-	}
-
-	m.tokenToInstrument[inst.InstrumentToken] = inst
+	m.loaded.add(inst)
 }
 
 // Count returns the number of instruments loaded.
 func (m *Manager) Count() int {
 	m.mutex.RLock()
-	count := len(m.tokenToInstrument)
+	count := m.loaded.count()
 	m.mutex.RUnlock()
 	return count
 }
 
+// Partial reports whether the loaded instruments came from a CSV dump,
+// which lacks their ISINs, freeze and order quantities and circuit
+// limits. Their zero values are then unknown, not "no limit".
+func (m *Manager) Partial() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.loaded.partial
+}
+
 // GetUpdateStats returns current update statistics
 func (m *Manager) GetUpdateStats() UpdateStats {
 	m.mutex.RLock()
 	stats := m.stats
 	stats.ScheduledNextUpdate = m.getNextScheduledUpdate()
+	stats.MemoryUsageBytes = m.loaded.memoryUsage() + m.index.memoryUsage()
+	if !m.snapshotTime.IsZero() {
+		stats.SnapshotTime = m.snapshotTime
+		stats.SnapshotAge = time.Since(m.snapshotTime)
+	}
 	m.mutex.RUnlock()
 	return stats
 }
@@ -553,6 +554,7 @@ func (m *Manager) Shutdown() {
 	if m.schedulerDone != nil {
 		<-m.schedulerDone
 	}
+	m.refreshing.Wait()
 	m.logger.Info("Instruments manager shutdown complete")
 }
 
@@ -615,6 +617,7 @@ func (m *Manager) shouldUpdate() bool {
 	m.mutex.RLock()
 	updateHour := m.config.UpdateHour
 	updateMinute := m.config.UpdateMinute
+	tradingDay := m.config.TradingDay
 	m.mutex.RUnlock()
 
 	// Check if it's the right time (hour and minute)
@@ -622,6 +625,11 @@ func (m *Manager) shouldUpdate() bool {
 		return false
 	}
 
+	// Nothing changes on days the exchanges are closed
+	if tradingDay != nil && !tradingDay(nowIST) {
+		return false
+	}
+
 	// Check if we already updated today
 	m.mutex.RLock()
 	lastUpdated := m.stats.LastUpdateTime
@@ -652,7 +660,17 @@ func (m *Manager) getNextScheduledUpdate() time.Time {
 
 	// If the time has already passed today, schedule for tomorrow
 	if nextUpdate.Before(now) {
-		nextUpdate = nextUpdate.Add(24 * time.Hour)
+		nextUpdate = nextUpdate.AddDate(0, 0, 1)
+	}
+
+	// Skip days the exchanges are closed, giving up after a month
+	if m.config.TradingDay != nil {
+		for range 31 {
+			if m.config.TradingDay(nextUpdate) {
+				break
+			}
+			nextUpdate = nextUpdate.AddDate(0, 0, 1)
+		}
 	}
 
 	return nextUpdate
@@ -24,17 +24,13 @@ func newTestManager() *Manager {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	manager := &Manager{
-		isinToInstruments: make(map[string][]*Instrument),
-		idToInst:          make(map[string]*Instrument),
-		idToToken:         make(map[string]uint32),
-		tokenToInstrument: make(map[uint32]*Instrument),
-		segmentIDs:        make(map[string]uint32),
-		lastUpdated:       time.Now(),
-		config:            config,
-		logger:            testLogger(),
-		schedulerCtx:      ctx,
-		schedulerCancel:   cancel,
-		schedulerDone:     make(chan struct{}),
+		loaded:          newStore(0),
+		lastUpdated:     time.Now(),
+		config:          config,
+		logger:          testLogger(),
+		schedulerCtx:    ctx,
+		schedulerCancel: cancel,
+		schedulerDone:   make(chan struct{}),
 	}
 	close(manager.schedulerDone) // Don't run scheduler
 
@@ -55,17 +51,13 @@ func newTestManagerWithoutUpdate() *Manager {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	manager := &Manager{
-		isinToInstruments: make(map[string][]*Instrument),
-		idToInst:          make(map[string]*Instrument),
-		idToToken:         make(map[string]uint32),
-		tokenToInstrument: make(map[uint32]*Instrument),
-		segmentIDs:        make(map[string]uint32),
-		lastUpdated:       time.Now(),
-		config:            config,
-		logger:            testLogger(),
-		schedulerCtx:      ctx,
-		schedulerCancel:   cancel,
-		schedulerDone:     make(chan struct{}),
+		loaded:          newStore(0),
+		lastUpdated:     time.Now(),
+		config:          config,
+		logger:          testLogger(),
+		schedulerCtx:    ctx,
+		schedulerCancel: cancel,
+		schedulerDone:   make(chan struct{}),
 	}
 	close(manager.schedulerDone) // Don't run scheduler
 
@@ -172,11 +164,7 @@ func TestManagerBasicOperations(t *testing.T) {
 // TestManagerConcurrentOperations tests thread safety - this is the critical test
 func TestManagerConcurrentOperations(t *testing.T) {
 	manager := &Manager{
-		isinToInstruments: make(map[string][]*Instrument),
-		idToInst:          make(map[string]*Instrument),
-		idToToken:         make(map[string]uint32),
-		tokenToInstrument: make(map[uint32]*Instrument),
-		segmentIDs:        make(map[string]uint32),
+		loaded: newStore(0),
 	}
 
 	testInsts := getTestInstruments()
@@ -672,6 +660,24 @@ func TestManagerUpdateConfig(t *testing.T) {
 	}
 }
 
+// TestNextScheduledUpdateSkipsClosedDays tests that scheduled updates skip non-trading days
+func TestNextScheduledUpdateSkipsClosedDays(t *testing.T) {
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+
+	firstTradingDay := time.Now().AddDate(0, 0, 3)
+	config := *manager.GetConfig()
+	config.TradingDay = func(day time.Time) bool {
+		return !day.Before(firstTradingDay.Add(-24 * time.Hour))
+	}
+	manager.UpdateConfig(&config)
+
+	next := manager.GetUpdateStats().ScheduledNextUpdate
+	if next.Before(firstTradingDay.Add(-24*time.Hour)) || next.After(firstTradingDay.Add(24*time.Hour)) {
+		t.Errorf("Expected next update around %s, got %s", firstTradingDay, next)
+	}
+}
+
 // TestManagerFromFileWithConfig tests file-based manager with custom configuration
 // TestManagerFromFileWithConfig removed - file loading functionality removed
 
@@ -682,17 +688,13 @@ func TestManagerSchedulerShutdown(t *testing.T) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	manager := &Manager{
-		isinToInstruments: make(map[string][]*Instrument),
-		idToInst:          make(map[string]*Instrument),
-		idToToken:         make(map[string]uint32),
-		tokenToInstrument: make(map[uint32]*Instrument),
-		segmentIDs:        make(map[string]uint32),
-		lastUpdated:       time.Now(),
-		config:            config,
-		logger:            testLogger(),
-		schedulerCtx:      ctx,
-		schedulerCancel:   cancel,
-		schedulerDone:     make(chan struct{}), // Don't pre-close
+		loaded:          newStore(0),
+		lastUpdated:     time.Now(),
+		config:          config,
+		logger:          testLogger(),
+		schedulerCtx:    ctx,
+		schedulerCancel: cancel,
+		schedulerDone:   make(chan struct{}), // Don't pre-close
 	}
 
 	// Start scheduler manually
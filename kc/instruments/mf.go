@@ -0,0 +1,509 @@
+package instruments
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMFInstrumentsURL = "https://api.kite.trade/mf/instruments"
+	defaultMFUpdateHour     = 9 // 9 AM IST, after the previous day's NAVs are published
+
+	// mfLoadBackoff is how long searches fail fast after loading the
+	// catalog on first use failed, before one tries again
+	mfLoadBackoff = time.Minute
+)
+
+var (
+	// mfInstrumentsURL can be overridden for testing
+	mfInstrumentsURL = defaultMFInstrumentsURL
+)
+
+// ErrMFCatalogEmpty is returned when the MF catalog could not be loaded.
+var ErrMFCatalogEmpty = errors.New("mutual fund instruments are not loaded")
+
+// MFInstrument is a mutual fund scheme from the Kite MF instruments list.
+type MFInstrument struct {
+	Tradingsymbol                   string  `json:"tradingsymbol"`
+	AMC                             string  `json:"amc"`
+	Name                            string  `json:"name"`
+	PurchaseAllowed                 bool    `json:"purchase_allowed"`
+	RedemptionAllowed               bool    `json:"redemption_allowed"`
+	MinimumPurchaseAmount           float64 `json:"minimum_purchase_amount"`
+	PurchaseAmountMultiplier        float64 `json:"purchase_amount_multiplier"`
+	MinimumAdditionalPurchaseAmount float64 `json:"minimum_additional_purchase_amount"`
+	MinimumRedemptionQuantity       float64 `json:"minimum_redemption_quantity"`
+	RedemptionQuantityMultiplier    float64 `json:"redemption_quantity_multiplier"`
+	DividendType                    string  `json:"dividend_type"`
+	SchemeType                      string  `json:"scheme_type"`
+	Plan                            string  `json:"plan"`
+	SettlementType                  string  `json:"settlement_type"`
+	LastPrice                       float64 `json:"last_price"`
+	LastPriceDate                   string  `json:"last_price_date"`
+}
+
+// DefaultMFUpdateConfig returns the default update configuration for the MF catalog
+func DefaultMFUpdateConfig() *UpdateConfig {
+	config := DefaultUpdateConfig()
+	config.UpdateHour = defaultMFUpdateHour
+	return config
+}
+
+// MFConfig holds configuration for creating a new MF catalog
+type MFConfig struct {
+	UpdateConfig *UpdateConfig  // defaults to DefaultMFUpdateConfig() if nil
+	Logger       *slog.Logger   // required
+	TestData     []MFInstrument // if set, skips HTTP loading and uses test data
+}
+
+// MFCatalog provides thread-safe access to mutual fund instruments.
+// It is kept separate from the exchange instruments Manager since MF schemes
+// have no instrument tokens and are published on a different schedule. The
+// catalog is loaded on first use and then refreshed daily by its scheduler.
+type MFCatalog struct {
+	bySymbol map[string]*MFInstrument
+	schemes  []*MFInstrument
+
+	config *UpdateConfig
+	stats  UpdateStats
+
+	// loadMu serialises loads so concurrent first searches fetch only once
+	loadMu sync.Mutex
+	// loadFailedAt and loadErr are the last failed load on first use
+	loadFailedAt time.Time
+	loadErr      error
+
+	schedulerCtx    context.Context
+	schedulerCancel context.CancelFunc
+	schedulerDone   chan struct{}
+
+	logger *slog.Logger
+
+	mutex sync.RWMutex
+}
+
+// NewMFCatalog creates a new MF catalog with the given configuration.
+// Unlike New, no data is fetched up front; the list is loaded on first use.
+func NewMFCatalog(cfg MFConfig) *MFCatalog {
+	if cfg.UpdateConfig == nil {
+		cfg.UpdateConfig = DefaultMFUpdateConfig()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &MFCatalog{
+		bySymbol:        make(map[string]*MFInstrument),
+		config:          cfg.UpdateConfig,
+		logger:          cfg.Logger,
+		schedulerCtx:    ctx,
+		schedulerCancel: cancel,
+		schedulerDone:   make(chan struct{}),
+	}
+
+	if cfg.TestData != nil {
+		c.Load(cfg.TestData)
+	}
+
+	if cfg.UpdateConfig.EnableScheduler {
+		go c.startScheduler()
+	} else {
+		close(c.schedulerDone)
+	}
+
+	return c
+}
+
+// Load replaces the catalog with the given schemes.
+func (c *MFCatalog) Load(schemes []MFInstrument) {
+	bySymbol := make(map[string]*MFInstrument, len(schemes))
+	list := make([]*MFInstrument, 0, len(schemes))
+	for i := range schemes {
+		s := schemes[i]
+		bySymbol[s.Tradingsymbol] = &s
+		list = append(list, &s)
+	}
+
+	c.mutex.Lock()
+	c.bySymbol = bySymbol
+	c.schemes = list
+	c.mutex.Unlock()
+}
+
+// Count returns the number of schemes loaded.
+func (c *MFCatalog) Count() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.schemes)
+}
+
+// GetUpdateStats returns current update statistics
+func (c *MFCatalog) GetUpdateStats() UpdateStats {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	stats := c.stats
+	stats.ScheduledNextUpdate = nextScheduledUpdate(time.Now(), c.config.UpdateHour, c.config.UpdateMinute)
+	return stats
+}
+
+// ensureLoaded loads the catalog if it is still empty. Only a single attempt
+// is made, and for mfLoadBackoff after it fails searches fail fast with
+// ErrMFCatalogEmpty instead of fetching the list again.
+func (c *MFCatalog) ensureLoaded() error {
+	if c.Count() > 0 {
+		return nil
+	}
+	if err := c.recentLoadFailure(); err != nil {
+		return err
+	}
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.Count() > 0 {
+		return nil
+	}
+	// Searches that waited on a load that failed don't fetch again
+	if err := c.recentLoadFailure(); err != nil {
+		return err
+	}
+	if err := c.fetch(); err != nil {
+		c.mutex.Lock()
+		c.loadFailedAt = time.Now()
+		c.loadErr = err
+		c.mutex.Unlock()
+		return fmt.Errorf("%w: %v", ErrMFCatalogEmpty, err)
+	}
+	return nil
+}
+
+// recentLoadFailure returns the error of a load on first use that failed
+// within mfLoadBackoff.
+func (c *MFCatalog) recentLoadFailure() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.loadErr == nil || time.Since(c.loadFailedAt) >= mfLoadBackoff {
+		return nil
+	}
+	return fmt.Errorf("%w: %v (retrying in %s)", ErrMFCatalogEmpty, c.loadErr, time.Until(c.loadFailedAt.Add(mfLoadBackoff)).Round(time.Second))
+}
+
+// Update fetches the MF instruments list with retries and replaces the catalog.
+func (c *MFCatalog) Update() error {
+	c.mutex.RLock()
+	maxAttempts := c.config.RetryAttempts
+	retryDelay := c.config.RetryDelay
+	c.mutex.RUnlock()
+
+	var lastErr error
+	for attempt := 0; attempt < max(maxAttempts, 1); attempt++ {
+		if attempt > 0 {
+			c.logger.Warn("Retrying MF instruments update", "attempt", attempt+1, "max_attempts", maxAttempts, "delay", retryDelay)
+			time.Sleep(retryDelay)
+		}
+
+		if err := c.fetch(); err != nil {
+			lastErr = err
+			c.logger.Error("MF instruments update failed", "attempt", attempt+1, "error", err)
+			continue
+		}
+		return nil
+	}
+
+	return fmt.Errorf("MF instruments update failed after %d attempts: %v", maxAttempts, lastErr)
+}
+
+// fetch makes a single attempt to download and load the MF instruments list.
+func (c *MFCatalog) fetch() error {
+	schemes, err := c.loadFromURL()
+	if err != nil {
+		c.updateStats(false, 0)
+		return err
+	}
+
+	c.Load(schemes)
+	c.updateStats(true, len(schemes))
+	c.logger.Info("Loaded MF instruments", "count", len(schemes))
+	return nil
+}
+
+func (c *MFCatalog) updateStats(success bool, count int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats.TotalUpdates++
+	if !success {
+		c.stats.FailedUpdates++
+	} else {
+		c.stats.LastUpdateTime = time.Now()
+		c.stats.LastUpdateCount = count
+	}
+}
+
+func (c *MFCatalog) loadFromURL() ([]MFInstrument, error) {
+	req, err := http.NewRequest("GET", mfInstrumentsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Add("Accept-Encoding", "gzip")
+	req.Header.Add("X-Kite-Version", "3")
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching MF instruments: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching MF instruments: unexpected status %d", resp.StatusCode)
+	}
+
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error creating gzip reader: %v", err)
+		}
+		defer func() { _ = gzipReader.Close() }()
+		reader = gzipReader
+	}
+
+	return parseMFInstrumentsCSV(reader)
+}
+
+// parseMFInstrumentsCSV parses the MF instruments CSV dump. Columns are
+// looked up by header name so that added or reordered columns are tolerated.
+func parseMFInstrumentsCSV(reader io.Reader) ([]MFInstrument, error) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading MF instruments header: %v", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.TrimSpace(strings.ToLower(h))] = i
+	}
+	if _, ok := cols["tradingsymbol"]; !ok {
+		return nil, fmt.Errorf("error parsing MF instruments: missing tradingsymbol column")
+	}
+
+	var out []MFInstrument
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading MF instruments (line %d): %v", line, err)
+		}
+
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		num := func(name string) float64 {
+			v, _ := strconv.ParseFloat(field(name), 64)
+			return v
+		}
+		flag := func(name string) bool {
+			v, _ := strconv.ParseBool(field(name))
+			return v
+		}
+
+		inst := MFInstrument{
+			Tradingsymbol:                   field("tradingsymbol"),
+			AMC:                             field("amc"),
+			Name:                            field("name"),
+			PurchaseAllowed:                 flag("purchase_allowed"),
+			RedemptionAllowed:               flag("redemption_allowed"),
+			MinimumPurchaseAmount:           num("minimum_purchase_amount"),
+			PurchaseAmountMultiplier:        num("purchase_amount_multiplier"),
+			MinimumAdditionalPurchaseAmount: num("minimum_additional_purchase_amount"),
+			MinimumRedemptionQuantity:       num("minimum_redemption_quantity"),
+			RedemptionQuantityMultiplier:    num("redemption_quantity_multiplier"),
+			DividendType:                    field("dividend_type"),
+			SchemeType:                      field("scheme_type"),
+			Plan:                            field("plan"),
+			SettlementType:                  field("settlement_type"),
+			LastPrice:                       num("last_price"),
+			LastPriceDate:                   field("last_price_date"),
+		}
+		if inst.Tradingsymbol == "" {
+			continue
+		}
+		out = append(out, inst)
+	}
+
+	return out, nil
+}
+
+// GetMFByTradingsymbol returns a scheme by its tradingsymbol (ISIN).
+func (c *MFCatalog) GetMFByTradingsymbol(tradingsymbol string) (MFInstrument, error) {
+	if err := c.ensureLoaded(); err != nil {
+		return MFInstrument{}, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	inst, ok := c.bySymbol[tradingsymbol]
+	if !ok {
+		return MFInstrument{}, ErrInstrumentNotFound
+	}
+	return *inst, nil
+}
+
+// MFSearchOptions narrows an MF catalog search. Query, AMC and Category are
+// matched fuzzily; Plan and DividendType are case-insensitive exact matches.
+// Empty fields match everything.
+type MFSearchOptions struct {
+	Query              string
+	AMC                string
+	Category           string
+	Plan               string
+	DividendType       string
+	PurchasableOnly    bool
+	MaxMinimumPurchase float64
+}
+
+// MFSearchResult is a scheme matching a search, with its relevance score.
+type MFSearchResult struct {
+	MFInstrument
+	Score int `json:"score"`
+}
+
+// SearchMF returns schemes matching the options, best matches first.
+func (c *MFCatalog) SearchMF(opts MFSearchOptions) ([]MFSearchResult, error) {
+	if err := c.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if len(c.schemes) == 0 {
+		return nil, ErrMFCatalogEmpty
+	}
+
+	var out []MFSearchResult
+	for _, s := range c.schemes {
+		if opts.Plan != "" && !strings.EqualFold(s.Plan, opts.Plan) {
+			continue
+		}
+		if opts.DividendType != "" && !strings.EqualFold(s.DividendType, opts.DividendType) {
+			continue
+		}
+		if opts.PurchasableOnly && !s.PurchaseAllowed {
+			continue
+		}
+		if opts.MaxMinimumPurchase > 0 && s.MinimumPurchaseAmount > opts.MaxMinimumPurchase {
+			continue
+		}
+
+		score := 1
+		for _, f := range []struct{ query, text string }{
+			{opts.Query, s.Name + " " + s.AMC + " " + s.Tradingsymbol},
+			{opts.AMC, s.AMC},
+			{opts.Category, s.SchemeType + " " + s.Name},
+		} {
+			if f.query == "" {
+				continue
+			}
+			fs := fuzzyScore(f.query, f.text)
+			if fs == 0 {
+				score = 0
+				break
+			}
+			score += fs
+		}
+		if score == 0 {
+			continue
+		}
+
+		out = append(out, MFSearchResult{MFInstrument: *s, Score: score})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// Shutdown stops the catalog's scheduler
+func (c *MFCatalog) Shutdown() {
+	if c.schedulerCancel != nil {
+		c.schedulerCancel()
+	}
+	if c.schedulerDone != nil {
+		<-c.schedulerDone
+	}
+}
+
+// startScheduler refreshes the catalog once a day at the configured time
+func (c *MFCatalog) startScheduler() {
+	defer close(c.schedulerDone)
+
+	ticker := time.NewTicker(5 * time.Minute) // Check every 5 minutes
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.schedulerCtx.Done():
+			return
+
+		case <-ticker.C:
+			c.mutex.RLock()
+			due := scheduleDue(time.Now(), c.config.UpdateHour, c.config.UpdateMinute, c.stats.LastUpdateTime)
+			c.mutex.RUnlock()
+
+			if due {
+				c.logger.Info("Starting scheduled MF instruments update")
+				if err := c.Update(); err != nil {
+					c.logger.Error("Scheduled MF instruments update failed", "error", err)
+				}
+			}
+		}
+	}
+}
+
+// scheduleDue reports whether a daily update at hour:minute IST is due at
+// now, given the time of the last successful update.
+func scheduleDue(now time.Time, hour, minute int, lastUpdate time.Time) bool {
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	nowIST := now.In(ist)
+	if nowIST.Hour() != hour || nowIST.Minute()/5 != minute/5 {
+		return false
+	}
+	if lastUpdate.IsZero() {
+		return true
+	}
+	last := lastUpdate.In(ist)
+	return nowIST.Year() != last.Year() || nowIST.YearDay() != last.YearDay()
+}
+
+// nextScheduledUpdate returns the next hour:minute IST after now.
+func nextScheduledUpdate(now time.Time, hour, minute int) time.Time {
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	nowIST := now.In(ist)
+	next := time.Date(nowIST.Year(), nowIST.Month(), nowIST.Day(), hour, minute, 0, 0, ist)
+	if next.Before(nowIST) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next
+}
@@ -0,0 +1,217 @@
+package instruments
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testMFInstrumentsCSV = `tradingsymbol,amc,name,purchase_allowed,redemption_allowed,minimum_purchase_amount,purchase_amount_multiplier,minimum_additional_purchase_amount,minimum_redemption_quantity,redemption_quantity_multiplier,dividend_type,scheme_type,plan,settlement_type,last_price,last_price_date
+INF209K01YN0,BirlaSunLifeMutualFund_MF,Aditya Birla Sun Life Flexi Cap Fund - Direct Growth,1,1,100,1,100,0.001,0.001,growth,equity,direct,T3,1650.12,2025-01-10
+INF209K01YM2,BirlaSunLifeMutualFund_MF,Aditya Birla Sun Life Flexi Cap Fund - Regular Growth,1,1,100,1,100,0.001,0.001,growth,equity,regular,T3,1510.40,2025-01-10
+INF109K01Z48,ICICIPrudentialMutualFund_MF,ICICI Prudential Liquid Fund - Direct Plan - IDCW,1,1,500,1,500,0.001,0.001,payout,debt,direct,T1,100.20,2025-01-10
+INF846K01EW2,AxisMutualFund_MF,Axis ELSS Tax Saver Fund - Direct Growth,0,1,500,500,500,0.001,0.001,growth,elss,direct,T3,95.31,2025-01-10
+`
+
+func newTestMFCatalog(t *testing.T) *MFCatalog {
+	t.Helper()
+	schemes, err := parseMFInstrumentsCSV(strings.NewReader(testMFInstrumentsCSV))
+	if err != nil {
+		t.Fatalf("Expected no error parsing test data, got: %v", err)
+	}
+
+	config := DefaultMFUpdateConfig()
+	config.EnableScheduler = false
+	return NewMFCatalog(MFConfig{UpdateConfig: config, Logger: testLogger(), TestData: schemes})
+}
+
+func TestParseMFInstrumentsCSV(t *testing.T) {
+	schemes, err := parseMFInstrumentsCSV(strings.NewReader(testMFInstrumentsCSV))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(schemes) != 4 {
+		t.Fatalf("Expected 4 schemes, got %d", len(schemes))
+	}
+
+	s := schemes[3]
+	if s.Tradingsymbol != "INF846K01EW2" || s.PurchaseAllowed || !s.RedemptionAllowed {
+		t.Errorf("Unexpected scheme: %+v", s)
+	}
+	if s.MinimumPurchaseAmount != 500 || s.SchemeType != "elss" || s.Plan != "direct" {
+		t.Errorf("Unexpected scheme attributes: %+v", s)
+	}
+
+	if _, err := parseMFInstrumentsCSV(strings.NewReader("foo,bar\n1,2\n")); err == nil {
+		t.Error("Expected error for CSV without tradingsymbol column")
+	}
+}
+
+func TestMFCatalogSearch(t *testing.T) {
+	catalog := newTestMFCatalog(t)
+
+	tests := []struct {
+		name  string
+		opts  MFSearchOptions
+		first string
+		count int
+	}{
+		{"query by name", MFSearchOptions{Query: "flexi cap"}, "INF209K01YN0", 2},
+		{"query with typo", MFSearchOptions{Query: "flexy cap"}, "INF209K01YN0", 2},
+		{"amc prefix", MFSearchOptions{AMC: "icici"}, "INF109K01Z48", 1},
+		{"category", MFSearchOptions{Category: "elss"}, "INF846K01EW2", 1},
+		{"category from name", MFSearchOptions{Category: "liquid"}, "INF109K01Z48", 1},
+		{"plan filter", MFSearchOptions{Query: "flexi", Plan: "REGULAR"}, "INF209K01YM2", 1},
+		{"purchasable only", MFSearchOptions{Plan: "direct", PurchasableOnly: true}, "", 2},
+		{"minimum purchase", MFSearchOptions{MaxMinimumPurchase: 100}, "", 2},
+		{"no match", MFSearchOptions{Query: "nonexistent scheme"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := catalog.SearchMF(tt.opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(results) != tt.count {
+				t.Fatalf("Expected %d results, got %d: %+v", tt.count, len(results), results)
+			}
+			if tt.first != "" && results[0].Tradingsymbol != tt.first {
+				t.Errorf("Expected %s first, got %s", tt.first, results[0].Tradingsymbol)
+			}
+		})
+	}
+
+	inst, err := catalog.GetMFByTradingsymbol("INF846K01EW2")
+	if err != nil || inst.AMC != "AxisMutualFund_MF" {
+		t.Errorf("Expected Axis scheme, got %+v (err: %v)", inst, err)
+	}
+	if _, err := catalog.GetMFByTradingsymbol("UNKNOWN"); err != ErrInstrumentNotFound {
+		t.Errorf("Expected ErrInstrumentNotFound, got: %v", err)
+	}
+}
+
+func TestMFCatalogLazyLoad(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(testMFInstrumentsCSV))
+	}))
+	defer server.Close()
+
+	original := mfInstrumentsURL
+	mfInstrumentsURL = server.URL
+	defer func() { mfInstrumentsURL = original }()
+
+	config := DefaultMFUpdateConfig()
+	config.EnableScheduler = false
+	catalog := NewMFCatalog(MFConfig{UpdateConfig: config, Logger: testLogger()})
+	defer catalog.Shutdown()
+
+	if catalog.Count() != 0 {
+		t.Fatal("Expected catalog not to load before first use")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := catalog.SearchMF(MFSearchOptions{Query: "axis"}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected a single fetch, got %d", requests.Load())
+	}
+	if stats := catalog.GetUpdateStats(); stats.LastUpdateCount != 4 || stats.TotalUpdates != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestMFCatalogLoadFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	original := mfInstrumentsURL
+	mfInstrumentsURL = server.URL
+	defer func() { mfInstrumentsURL = original }()
+
+	config := DefaultMFUpdateConfig()
+	config.EnableScheduler = false
+	config.RetryDelay = time.Millisecond
+	catalog := NewMFCatalog(MFConfig{UpdateConfig: config, Logger: testLogger()})
+
+	if _, err := catalog.SearchMF(MFSearchOptions{Query: "axis"}); !errors.Is(err, ErrMFCatalogEmpty) {
+		t.Errorf("Expected ErrMFCatalogEmpty, got: %v", err)
+	}
+
+	// Searches right after a failed load fail fast without fetching
+	if _, err := catalog.SearchMF(MFSearchOptions{Query: "axis"}); !errors.Is(err, ErrMFCatalogEmpty) {
+		t.Errorf("Expected ErrMFCatalogEmpty, got: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected a single fetch within the backoff, got %d", n)
+	}
+	catalog.mutex.Lock()
+	catalog.loadFailedAt = catalog.loadFailedAt.Add(-mfLoadBackoff)
+	catalog.mutex.Unlock()
+	if _, err := catalog.SearchMF(MFSearchOptions{Query: "axis"}); !errors.Is(err, ErrMFCatalogEmpty) || requests.Load() != 2 {
+		t.Errorf("Expected the load tried again after the backoff, got %v after %d fetches", err, requests.Load())
+	}
+
+	if err := catalog.Update(); err == nil {
+		t.Error("Expected update to fail")
+	}
+	if stats := catalog.GetUpdateStats(); stats.FailedUpdates != 2+config.RetryAttempts {
+		t.Errorf("Expected %d failed updates, got %d", 2+config.RetryAttempts, stats.FailedUpdates)
+	}
+}
+
+func TestScheduleDue(t *testing.T) {
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	at := time.Date(2025, 1, 10, 9, 2, 0, 0, ist)
+
+	if !scheduleDue(at, 9, 0, time.Time{}) {
+		t.Error("Expected update to be due within the scheduled window")
+	}
+	if scheduleDue(at, 9, 0, at.Add(-time.Minute)) {
+		t.Error("Expected no update when already updated today")
+	}
+	if !scheduleDue(at, 9, 0, at.AddDate(0, 0, -1)) {
+		t.Error("Expected update when last updated yesterday")
+	}
+	if scheduleDue(at.Add(time.Hour), 9, 0, time.Time{}) {
+		t.Error("Expected no update outside the scheduled window")
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query, text string
+		match       bool
+	}{
+		{"hdfc", "HDFC Bank", true},
+		{"hdf", "HDFC Bank", true},
+		{"bank hdfc", "HDFC Bank", true},
+		{"reliance", "RELIANCE INDUSTRIES", true},
+		{"relaince", "RELIANCE INDUSTRIES", true}, // adjacent transposition
+		{"relianse", "RELIANCE INDUSTRIES", true},
+		{"infosys", "RELIANCE INDUSTRIES", false},
+		{"", "anything", false},
+	}
+
+	for _, tt := range tests {
+		if got := fuzzyScore(tt.query, tt.text) > 0; got != tt.match {
+			t.Errorf("fuzzyScore(%q, %q) match = %v, want %v", tt.query, tt.text, got, tt.match)
+		}
+	}
+
+	if fuzzyScore("hdfc", "HDFC Bank") <= fuzzyScore("hdf", "HDFC Bank") {
+		t.Error("Expected exact match to outscore prefix match")
+	}
+}
@@ -5,11 +5,11 @@ func (m *Manager) GetByID(id string) (Instrument, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	inst, ok := m.idToInst[id]
+	row, ok := m.loaded.byID[id]
 	if !ok {
 		return Instrument{}, ErrInstrumentNotFound
 	}
-	return *inst, nil
+	return m.loaded.instrument(row), nil
 }
 
 // GetByTradingsymbol returns an instrument using exchange and trading symbol.
@@ -22,14 +22,14 @@ func (m *Manager) GetByISIN(isin string) ([]Instrument, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	insts, ok := m.isinToInstruments[isin]
+	rows, ok := m.loaded.byISIN[isin]
 	if !ok {
 		return []Instrument{}, ErrInstrumentNotFound
 	}
 
-	out := make([]Instrument, 0, len(insts))
-	for _, i := range insts {
-		out = append(out, *i)
+	out := make([]Instrument, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, m.loaded.instrument(row))
 	}
 
 	return out, nil
@@ -40,11 +40,11 @@ func (m *Manager) GetByInstToken(token uint32) (Instrument, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	inst, ok := m.tokenToInstrument[token]
+	inst, ok := m.loaded.get(token)
 	if !ok {
 		return Instrument{}, ErrInstrumentNotFound
 	}
-	return *inst, nil
+	return inst, nil
 }
 
 // GetByExchToken takes an exchange token.
@@ -53,17 +53,17 @@ func (m *Manager) GetByExchToken(exch string, exchToken uint32) (Instrument, err
 	defer m.mutex.RUnlock()
 
 	// Get the segment ID from the exchange.
-	segID, found := m.segmentIDs[exch]
+	segID, found := m.loaded.segmentIDs[exch]
 	if !found {
 		return Instrument{}, ErrSegmentNotFound
 	}
 
 	instToken := ExchTokenToInstToken(segID, exchToken)
-	inst, found := m.tokenToInstrument[instToken]
+	inst, found := m.loaded.get(instToken)
 	if !found {
 		return Instrument{}, ErrInstrumentNotFound
 	}
-	return *inst, nil
+	return inst, nil
 }
 
 // Filter returns a list of instruments filtered by the given filter.
@@ -73,9 +73,12 @@ func (m *Manager) Filter(filter func(Instrument) bool) []Instrument {
 
 	out := []Instrument{}
 
-	for _, v := range m.tokenToInstrument {
-		if filter(*v) {
-			out = append(out, *v)
+	for row := range m.loaded.len() {
+		if !m.loaded.live(row) {
+			continue
+		}
+		if inst := m.loaded.instrument(row); filter(inst) {
+			out = append(out, inst)
 		}
 	}
 
@@ -89,9 +92,10 @@ func (m *Manager) GetAllByUnderlying(exchange, underlying string) ([]Instrument,
 
 	out := []Instrument{}
 
-	for _, ins := range m.tokenToInstrument {
-		if ins.Exchange == exchange && ins.Name == underlying {
-			out = append(out, *ins)
+	s := m.loaded
+	for row := range s.len() {
+		if s.live(row) && s.str(s.exchanges, row) == exchange && s.str(s.names, row) == underlying {
+			out = append(out, s.instrument(row))
 		}
 	}
 
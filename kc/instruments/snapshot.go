@@ -0,0 +1,157 @@
+package instruments
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Snapshots are the instruments in the JSONL format of the Kite dump,
+// gzip compressed. The gzip header's modification time is when the
+// instruments were fetched from Kite, and its comment is snapshotPartial
+// when they came from a CSV dump.
+
+const snapshotPartial = "partial"
+
+// saveSnapshot writes the instruments fetched from Kite to the snapshot
+// file, if one is configured. Failing to write it is logged, as the fresh
+// instruments are in use either way.
+func (m *Manager) saveSnapshot(instruments *store) {
+	m.mutex.RLock()
+	path := m.config.SnapshotPath
+	m.mutex.RUnlock()
+	if path == "" {
+		return
+	}
+
+	if err := writeSnapshot(path, instruments, time.Now()); err != nil {
+		m.logger.Error("Failed to save instruments snapshot", "path", path, "error", err)
+		return
+	}
+	m.logger.Info("Saved instruments snapshot", "path", path, "count", instruments.count())
+}
+
+func writeSnapshot(path string, instruments *store, fetchedAt time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating snapshot directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	buf := bufio.NewWriter(f)
+	gz := gzip.NewWriter(buf)
+	gz.ModTime = fetchedAt
+	if instruments.partial {
+		gz.Comment = snapshotPartial
+	}
+	encoder := json.NewEncoder(gz)
+	for row := range instruments.len() {
+		if !instruments.live(row) {
+			continue
+		}
+		if err := encoder.Encode(instruments.instrument(row)); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("error encoding snapshot: %w", err)
+		}
+	}
+	if err := errors.Join(gz.Close(), buf.Flush(), f.Close()); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// readSnapshot reads a snapshot file and returns its instruments and when
+// they were fetched from Kite.
+func (m *Manager) readSnapshot(path string) (*store, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error opening snapshot: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error reading snapshot: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	instruments, err := m.parseInstrumentsJSON(gz)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if instruments.count() == 0 {
+		return nil, time.Time{}, errors.New("snapshot has no instruments")
+	}
+	instruments.partial = gz.Comment == snapshotPartial
+	return instruments, gz.ModTime, nil
+}
+
+// bootFromSnapshot loads the instruments from the snapshot after loading
+// them from Kite failed with loadErr, and keeps trying Kite in the
+// background until it succeeds.
+func (m *Manager) bootFromSnapshot(loadErr error) error {
+	m.mutex.RLock()
+	path := m.config.SnapshotPath
+	m.mutex.RUnlock()
+	if path == "" {
+		return loadErr
+	}
+
+	instruments, fetchedAt, err := m.readSnapshot(path)
+	if err != nil {
+		return errors.Join(loadErr, fmt.Errorf("no usable instruments snapshot: %w", err))
+	}
+	count, err := m.replaceAll(instruments, fetchedAt, true)
+	if err != nil {
+		return errors.Join(loadErr, fmt.Errorf("instruments snapshot unusable: %w", err))
+	}
+	m.logger.Warn("Kite is unreachable, started from the instruments snapshot",
+		"path", path, "count", count, "fetched_at", fetchedAt, "error", loadErr)
+
+	m.refreshing.Add(1)
+	go m.refreshUntilOnline()
+	return nil
+}
+
+// refreshUntilOnline loads the instruments from Kite every
+// OfflineRefreshInterval until it succeeds or the manager shuts down.
+func (m *Manager) refreshUntilOnline() {
+	defer m.refreshing.Done()
+
+	m.mutex.RLock()
+	interval := m.config.OfflineRefreshInterval
+	m.mutex.RUnlock()
+	if interval <= 0 {
+		interval = defaultOfflineRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.schedulerCtx.Done():
+			return
+		case <-ticker.C:
+			count, err := m.updateInstruments(true)
+			m.updateStats(err == nil, count)
+			if err != nil {
+				m.logger.Warn("Kite is still unreachable, serving the instruments snapshot", "error", err)
+				continue
+			}
+			m.logger.Info("Refreshed instruments from Kite after starting from the snapshot", "count", count)
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package instruments
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSnapshotConfig returns a config snapshotting to a temporary file and
+// failing fast when Kite is unreachable.
+func newSnapshotConfig(t *testing.T) *UpdateConfig {
+	t.Helper()
+	config := DefaultUpdateConfig()
+	config.EnableScheduler = false
+	config.RetryAttempts = 1
+	config.RetryDelay = time.Millisecond
+	config.SnapshotPath = filepath.Join(t.TempDir(), "snapshots", "instruments.jsonl.gz")
+	return config
+}
+
+// setupFlakyServer serves the instruments of setupTestServer while online
+// is true and fails otherwise.
+func setupFlakyServer(online *atomic.Bool) *httptest.Server {
+	good := setupTestServer()
+	handler := good.Config.Handler
+	good.Close()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+	restore := hijackInstrumentsURL(server.URL)
+	defer restore()
+
+	config := newSnapshotConfig(t)
+	manager, err := New(Config{UpdateConfig: config, Logger: testLogger()})
+	if err != nil {
+		t.Fatalf("Expected no error loading from Kite, got: %v", err)
+	}
+	defer manager.Shutdown()
+
+	if stats := manager.GetUpdateStats(); !stats.SnapshotTime.IsZero() {
+		t.Errorf("Expected no snapshot time after loading from Kite, got %v", stats.SnapshotTime)
+	}
+
+	instruments, fetchedAt, err := manager.readSnapshot(config.SnapshotPath)
+	if err != nil {
+		t.Fatalf("Expected the snapshot to be readable, got: %v", err)
+	}
+	if instruments.count() != manager.Count() {
+		t.Errorf("Expected %d instruments in the snapshot, got %d", manager.Count(), instruments.count())
+	}
+	if time.Since(fetchedAt) > time.Minute {
+		t.Errorf("Expected the snapshot to record the fetch time, got %v", fetchedAt)
+	}
+	if inst, ok := instruments.get(779521); !ok || inst.Tradingsymbol != "SBIN" || inst.TickSize != 0.05 {
+		t.Errorf("Expected SBIN to round trip, got %+v", inst)
+	}
+	if _, err := os.Stat(config.SnapshotPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file left behind, got %v", err)
+	}
+	if instruments.partial {
+		t.Error("Expected the instruments of the JSON dump complete")
+	}
+
+	// Instruments from a CSV dump stay partial through the snapshot
+	instruments.partial = true
+	if err := writeSnapshot(config.SnapshotPath, instruments, fetchedAt); err != nil {
+		t.Fatalf("Expected the snapshot to be written, got: %v", err)
+	}
+	if instruments, _, err = manager.readSnapshot(config.SnapshotPath); err != nil || !instruments.partial {
+		t.Errorf("Expected the snapshot partial, got %v", err)
+	}
+}
+
+func TestOfflineStartupFromSnapshot(t *testing.T) {
+	var online atomic.Bool
+	server := setupFlakyServer(&online)
+	defer server.Close()
+	restore := hijackInstrumentsURL(server.URL)
+	defer restore()
+
+	config := newSnapshotConfig(t)
+	fetchedAt := time.Now().Add(-26 * time.Hour).Truncate(time.Second)
+	testMap := make(map[uint32]*Instrument)
+	for _, inst := range getTestInstruments() {
+		if inst.Tradingsymbol == "SBIN" {
+			testMap[inst.InstrumentToken] = inst
+		}
+	}
+	if err := writeSnapshot(config.SnapshotPath, storeOf(testMap), fetchedAt); err != nil {
+		t.Fatalf("Expected the snapshot to be written, got: %v", err)
+	}
+
+	config.OfflineRefreshInterval = 10 * time.Millisecond
+	manager, err := New(Config{UpdateConfig: config, Logger: testLogger()})
+	if err != nil {
+		t.Fatalf("Expected to start from the snapshot, got: %v", err)
+	}
+	defer manager.Shutdown()
+
+	if manager.Count() != len(testMap) {
+		t.Errorf("Expected %d instruments from the snapshot, got %d", len(testMap), manager.Count())
+	}
+	if _, err := manager.GetByID("NSE:SBIN"); err != nil {
+		t.Errorf("Expected SBIN from the snapshot, got: %v", err)
+	}
+	stats := manager.GetUpdateStats()
+	if !stats.SnapshotTime.Equal(fetchedAt) {
+		t.Errorf("Expected snapshot time %v, got %v", fetchedAt, stats.SnapshotTime)
+	}
+	if stats.SnapshotAge < 26*time.Hour {
+		t.Errorf("Expected a snapshot age of at least 26h, got %v", stats.SnapshotAge)
+	}
+
+	// The background refresh replaces the snapshot once Kite is back
+	online.Store(true)
+	deadline := time.Now().Add(5 * time.Second)
+	for !manager.GetUpdateStats().SnapshotTime.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the instruments to be refreshed from Kite")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := manager.GetByInstToken(738561); err != nil {
+		t.Errorf("Expected RELIANCE from Kite, got: %v", err)
+	}
+	if _, _, err := manager.readSnapshot(config.SnapshotPath); err != nil {
+		t.Errorf("Expected the fresh instruments to be snapshotted, got: %v", err)
+	}
+}
+
+func TestOfflineStartupWithoutSnapshot(t *testing.T) {
+	var online atomic.Bool
+	server := setupFlakyServer(&online)
+	defer server.Close()
+	restore := hijackInstrumentsURL(server.URL)
+	defer restore()
+
+	if _, err := New(Config{UpdateConfig: newSnapshotConfig(t), Logger: testLogger()}); err == nil {
+		t.Error("Expected an error without Kite or a snapshot")
+	}
+
+	config := newSnapshotConfig(t)
+	config.SnapshotPath = ""
+	if _, err := New(Config{UpdateConfig: config, Logger: testLogger()}); err == nil {
+		t.Error("Expected an error without Kite and snapshots disabled")
+	}
+}
@@ -0,0 +1,309 @@
+package instruments
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of instrument dumps.
+const (
+	// FormatJSON is JSON lines of instruments, like the Kite dump at
+	// https://api.kite.trade/instruments.json.
+	FormatJSON = "json"
+	// FormatCSV is the CSV dump of the Kite Connect API, of every exchange
+	// at https://api.kite.trade/instruments or of one at
+	// https://api.kite.trade/instruments/NSE.
+	FormatCSV = "csv"
+)
+
+// Source is where instruments are loaded from. The CSV dumps lack the
+// ISINs, freeze and order quantities and circuit limits of the JSON one,
+// which are unknown while instruments loaded from CSV are in use.
+type Source struct {
+	// URL is an http(s) URL or the path of a local file, gzip compressed
+	// or not. Empty loads the Kite JSON dump.
+	URL string
+	// Format is FormatJSON or FormatCSV. Empty detects it from the dump.
+	Format string
+	// Exchanges, when set, loads the dump of each exchange from
+	// URL/<exchange>, like the per exchange CSV dumps of the Kite Connect
+	// API, instead of URL.
+	Exchanges []string
+}
+
+// csvMissingFields are the fields of an instrument the CSV dump doesn't
+// have. Instruments loaded from it leave them zero, so their ISINs,
+// freeze and order quantities and circuit limits are unknown rather than
+// absent; see Manager.Partial.
+var csvMissingFields = []string{
+	"isin", "series", "multiplier", "delivery_units", "price_units",
+	"freeze_quantity", "max_order_quantity", "expiry_type",
+	"exercise_start_date", "exercise_end_date", "issue_date",
+	"listing_date", "maturity_date", "lower_circuit_limit",
+	"upper_circuit_limit",
+}
+
+// Validate reports whether the source is usable.
+func (s Source) Validate() error {
+	if s.Format != "" && s.Format != FormatJSON && s.Format != FormatCSV {
+		return fmt.Errorf("unknown instruments format %q, expected %s or %s", s.Format, FormatJSON, FormatCSV)
+	}
+	if len(s.Exchanges) > 0 && s.URL == "" {
+		return errors.New("loading instruments by exchange needs a source URL")
+	}
+	return nil
+}
+
+// urls returns the URLs or paths the dumps of the source are at.
+func (s Source) urls() []string {
+	if s.URL == "" {
+		return []string{instrumentsURL}
+	}
+	if len(s.Exchanges) == 0 {
+		return []string{s.URL}
+	}
+	urls := make([]string, len(s.Exchanges))
+	for i, exchange := range s.Exchanges {
+		urls[i] = strings.TrimSuffix(s.URL, "/") + "/" + strings.ToUpper(exchange)
+	}
+	return urls
+}
+
+func isHTTP(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// loadFromSource loads the instruments from the configured source.
+func (m *Manager) loadFromSource() (*store, error) {
+	m.mutex.RLock()
+	source := m.config.Source
+	m.mutex.RUnlock()
+
+	instruments := newStore(0)
+	for _, url := range source.urls() {
+		if err := m.loadDump(url, source.Format, instruments); err != nil {
+			return nil, err
+		}
+	}
+	instruments.done()
+
+	// An empty dump would wipe the loaded instruments and the snapshot
+	if instruments.count() == 0 {
+		return nil, fmt.Errorf("error fetching instruments: empty response")
+	}
+	if instruments.partial {
+		m.logger.Warn("Instruments loaded from a CSV dump lack ISINs, freeze quantities and circuit limits; order slicing, circuit checks and ISIN lookups treat them as unknown",
+			"missing", csvMissingFields)
+	}
+	return instruments, nil
+}
+
+// loadDump decodes the dump at a URL or path into a store.
+func (m *Manager) loadDump(url, format string, into *store) error {
+	var body io.ReadCloser
+	if isHTTP(url) {
+		var err error
+		if body, err = m.fetch(url); err != nil {
+			return err
+		}
+	} else {
+		m.logger.Debug("Opening instruments file", "path", url)
+		f, err := os.Open(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return fmt.Errorf("error opening instruments file: %v", err)
+		}
+		body = f
+	}
+	defer func() { _ = body.Close() }()
+
+	reader := bufio.NewReader(body)
+	// Responses are gzip compressed when the server honours
+	// Accept-Encoding, and files can be too
+	if magic, _ := reader.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		m.logger.Debug("Instruments dump is gzip compressed, creating gzip reader")
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			m.logger.Error("Failed to create gzip reader", "error", err)
+			return fmt.Errorf("error creating gzip reader: %v", err)
+		}
+		defer func() { _ = gzipReader.Close() }()
+		reader = bufio.NewReader(gzipReader)
+	}
+
+	if format == "" {
+		format = detectFormat(reader)
+	}
+	m.logger.Debug("Starting to parse instruments", "url", url, "format", format)
+	if format == FormatCSV {
+		return m.decodeInstrumentsCSV(reader, into)
+	}
+	return m.decodeInstrumentsJSON(reader, into)
+}
+
+// detectFormat tells a JSON dump from a CSV one by its first character.
+func detectFormat(reader *bufio.Reader) string {
+	for n := 1; ; n++ {
+		peeked, _ := reader.Peek(n)
+		if len(peeked) < n {
+			return FormatJSON
+		}
+		switch peeked[n-1] {
+		case ' ', '\t', '\r', '\n':
+		case '{':
+			return FormatJSON
+		default:
+			return FormatCSV
+		}
+	}
+}
+
+// fetch requests a dump over HTTP.
+func (m *Manager) fetch(url string) (io.ReadCloser, error) {
+	m.logger.Debug("Creating HTTP request to fetch instruments", "url", url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		m.logger.Error("Error creating HTTP request", "error", err)
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	// Add compression header
+	req.Header.Add("Accept-Encoding", "gzip")
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	m.logger.Debug("Sending HTTP request to fetch instruments")
+	resp, err := client.Do(req)
+	if err != nil {
+		m.logger.Error("HTTP request failed", "error", err)
+		return nil, fmt.Errorf("error fetching instruments: %v", err)
+	}
+
+	m.logger.Debug("Received HTTP response",
+		"status", resp.StatusCode,
+		"content-length", resp.Header.Get("Content-Length"),
+		"content-encoding", resp.Header.Get("Content-Encoding"))
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("error fetching instruments: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// csvColumns set the field of each column of the Kite CSV dump.
+var csvColumns = map[string]func(inst *Instrument, v string) error{
+	"instrument_token": func(inst *Instrument, v string) error { return parseUint32(v, &inst.InstrumentToken) },
+	"exchange_token":   func(inst *Instrument, v string) error { return parseUint32(v, &inst.ExchangeToken) },
+	"tradingsymbol":    func(inst *Instrument, v string) error { inst.Tradingsymbol = v; return nil },
+	"name":             func(inst *Instrument, v string) error { inst.Name = v; return nil },
+	"last_price":       func(inst *Instrument, v string) error { return parseFloat(v, &inst.LastPrice) },
+	"expiry":           func(inst *Instrument, v string) error { inst.ExpiryDate = v; return nil },
+	"strike":           func(inst *Instrument, v string) error { return parseFloat(v, &inst.Strike) },
+	"tick_size":        func(inst *Instrument, v string) error { return parseFloat(v, &inst.TickSize) },
+	"lot_size":         func(inst *Instrument, v string) error { return parseInt(v, &inst.LotSize) },
+	"instrument_type":  func(inst *Instrument, v string) error { inst.InstrumentType = v; return nil },
+	"segment":          func(inst *Instrument, v string) error { inst.Segment = v; return nil },
+	"exchange":         func(inst *Instrument, v string) error { inst.Exchange = v; return nil },
+}
+
+func parseUint32(v string, out *uint32) error {
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	*out = uint32(n)
+	return err
+}
+
+func parseInt(v string, out *int) error {
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	*out = n
+	return err
+}
+
+func parseFloat(v string, out *float64) error {
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	*out = n
+	return err
+}
+
+// decodeInstrumentsCSV decodes a Kite CSV dump into a store. Columns are
+// found by their header, so their order doesn't matter and unknown ones
+// are skipped. The CSV only lists the instruments that trade, without
+// their IDs, so each is active with an ID of exchange:tradingsymbol, like
+// in the JSON dump. It lacks csvMissingFields, so the store is marked
+// partial.
+func (m *Manager) decodeInstrumentsCSV(reader io.Reader, into *store) error {
+	into.partial = true
+
+	r := csv.NewReader(reader)
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("error reading instruments CSV header: %v", err)
+	}
+	setters := make([]func(*Instrument, string) error, len(header))
+	names := make([]string, len(header))
+	found := make(map[string]bool)
+	for i, name := range header {
+		names[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		setters[i] = csvColumns[names[i]]
+		found[names[i]] = true
+	}
+	for _, required := range []string{"instrument_token", "tradingsymbol", "exchange"} {
+		if !found[required] {
+			return fmt.Errorf("error parsing instruments CSV: no %s column", required)
+		}
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			m.logger.Error("CSV parse error", "error", err)
+			return fmt.Errorf("error parsing instruments CSV: %v", err)
+		}
+
+		instrument := Instrument{Active: true}
+		for i, v := range record {
+			if setters[i] == nil {
+				continue
+			}
+			if err := setters[i](&instrument, v); err != nil {
+				line, _ := r.FieldPos(i)
+				return fmt.Errorf("error parsing instruments CSV: line %d: %s %q: %v", line, names[i], v, err)
+			}
+		}
+		instrument.ID = instrument.Exchange + ":" + instrument.Tradingsymbol
+		into.add(&instrument)
+
+		if n := into.len(); n%10000 == 0 {
+			m.logger.Debug("Processing instruments progress", "count", n)
+			if err := m.checkMemoryLimit(into, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
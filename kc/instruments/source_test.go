@@ -0,0 +1,218 @@
+package instruments
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newSourceConfig returns a config loading instruments from source.
+func newSourceConfig(source Source) *UpdateConfig {
+	config := DefaultUpdateConfig()
+	config.EnableScheduler = false
+	config.RetryAttempts = 1
+	config.Source = source
+	return config
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		t.Fatalf("Expected the data to compress, got: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Expected the data to compress, got: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVJSONParity(t *testing.T) {
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+
+	// The format is detected from each dump
+	fromCSV, fromJSON := newStore(0), newStore(0)
+	if err := manager.loadDump("testdata/instruments.csv", "", fromCSV); err != nil {
+		t.Fatalf("Expected the CSV dump to load, got: %v", err)
+	}
+	if err := manager.loadDump("testdata/instruments.jsonl", "", fromJSON); err != nil {
+		t.Fatalf("Expected the JSON dump to load, got: %v", err)
+	}
+
+	if fromCSV.count() != 7 || fromCSV.count() != fromJSON.count() {
+		t.Fatalf("Expected 7 instruments from both dumps, got %d and %d", fromCSV.count(), fromJSON.count())
+	}
+	if !fromCSV.partial || fromJSON.partial {
+		t.Error("Expected only the store of the CSV dump partial")
+	}
+
+	// The dumps agree on every field the CSV has, and the fields it lacks
+	// are zero in it
+	differing := make(map[string]bool)
+	fields := reflect.TypeOf(Instrument{})
+	for token, row := range fromJSON.byToken {
+		want := fromJSON.instrument(row)
+		got, ok := fromCSV.get(token)
+		if !ok {
+			t.Errorf("Expected %s in the CSV dump", want.ID)
+			continue
+		}
+		wantValue, gotValue := reflect.ValueOf(want), reflect.ValueOf(got)
+		for i := range fields.NumField() {
+			if wantValue.Field(i).Equal(gotValue.Field(i)) {
+				continue
+			}
+			name := fields.Field(i).Tag.Get("json")
+			differing[name] = true
+			if !slices.Contains(csvMissingFields, name) {
+				t.Errorf("Expected the same %s of %s from both dumps, got %v and %v", name, want.ID, wantValue.Field(i), gotValue.Field(i))
+			} else if !gotValue.Field(i).IsZero() {
+				t.Errorf("Expected no %s of %s from the CSV dump, got %v", name, want.ID, gotValue.Field(i))
+			}
+		}
+	}
+	for _, name := range csvMissingFields {
+		if !differing[name] {
+			t.Errorf("Expected %s to differ between the dumps, as the CSV dump lacks it", name)
+		}
+	}
+	if inst, _ := fromCSV.get(341249); inst.Name != "HDFC BANK, LTD" || inst.ID != "NSE:HDFCBANK" {
+		t.Errorf("Expected the quoted name and the ID derived, got %+v", inst)
+	}
+	if inst, _ := fromCSV.get(13491458); !inst.Active || inst.Strike != 22500 || inst.ExpiryDate != "2025-01-30" {
+		t.Errorf("Expected the option active with its strike and expiry, got %+v", inst)
+	}
+}
+
+func TestLoadFromSourceByExchange(t *testing.T) {
+	dump, err := os.ReadFile("testdata/instruments.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(dump)), "\n")
+	byExchange := make(map[string]string)
+	for _, line := range lines[1:] {
+		exchange := line[strings.LastIndex(line, ",")+1:]
+		if byExchange[exchange] == "" {
+			byExchange[exchange] = lines[0] + "\n"
+		}
+		byExchange[exchange] += line + "\n"
+	}
+
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		csv, ok := byExchange[strings.TrimPrefix(r.URL.Path, "/instruments/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// One exchange comes compressed, like Kite does
+		if r.URL.Path == "/instruments/NFO" {
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(gzipped(t, []byte(csv)))
+			return
+		}
+		_, _ = w.Write([]byte(csv))
+	}))
+	defer server.Close()
+
+	manager, err := New(Config{
+		UpdateConfig: newSourceConfig(Source{URL: server.URL + "/instruments/", Format: FormatCSV, Exchanges: []string{"nse", "NFO"}}),
+		Logger:       testLogger(),
+	})
+	if err != nil {
+		t.Fatalf("Expected the instruments to load by exchange, got: %v", err)
+	}
+	defer manager.Shutdown()
+
+	if manager.Count() != 5 {
+		t.Errorf("Expected the 3 NSE and 2 NFO instruments, got %d", manager.Count())
+	}
+	if _, err := manager.GetByID("NFO:NIFTY25JAN22500CE"); err != nil {
+		t.Errorf("Expected the NFO option, got: %v", err)
+	}
+	if _, err := manager.GetByID("BSE:RELIANCE"); err == nil {
+		t.Error("Expected no BSE instruments")
+	}
+	if strings.Join(paths, " ") != "/instruments/NSE /instruments/NFO" {
+		t.Errorf("Expected the dump of each exchange requested, got %v", paths)
+	}
+
+	// Failing to load any exchange fails the load
+	_, err = New(Config{
+		UpdateConfig: newSourceConfig(Source{URL: server.URL + "/instruments", Exchanges: []string{"NSE", "MCX"}}),
+		Logger:       testLogger(),
+	})
+	if err == nil {
+		t.Error("Expected an error when an exchange's dump is missing")
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	dump, err := os.ReadFile("testdata/instruments.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "instruments.csv.gz")
+	if err := os.WriteFile(path, gzipped(t, dump), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{path, "file://" + path} {
+		manager, err := New(Config{UpdateConfig: newSourceConfig(Source{URL: url}), Logger: testLogger()})
+		if err != nil {
+			t.Fatalf("Expected the instruments to load from %s, got: %v", url, err)
+		}
+		if manager.Count() != 7 {
+			t.Errorf("Expected 7 instruments from %s, got %d", url, manager.Count())
+		}
+		if !manager.Partial() {
+			t.Errorf("Expected the instruments from %s partial", url)
+		}
+		manager.Shutdown()
+	}
+
+	if _, err := New(Config{UpdateConfig: newSourceConfig(Source{URL: path + ".missing"}), Logger: testLogger()}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := New(Config{UpdateConfig: newSourceConfig(Source{Format: "xml"}), Logger: testLogger()}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if err := (Source{Exchanges: []string{"NSE"}}).Validate(); err == nil {
+		t.Error("Expected an error for exchanges without a URL")
+	}
+
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+	tests := map[string]string{
+		"missing column": "instrument_token,tradingsymbol\n408065,INFY\n",
+		"bad number":     "instrument_token,tradingsymbol,exchange,lot_size\n408065,INFY,NSE,1\n738561,RELIANCE,NSE,one\n",
+		"ragged row":     "instrument_token,tradingsymbol,exchange\n408065,INFY\n",
+	}
+	for name, csv := range tests {
+		err := manager.decodeInstrumentsCSV(strings.NewReader(csv), newStore(0))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if name == "bad number" && (err == nil || !strings.Contains(err.Error(), "line 3: lot_size")) {
+			t.Errorf("Expected the line and column of the bad value, got: %v", err)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package instruments
+
+import (
+	"math"
+	"time"
+)
+
+// noExpiry is the expiry day of instruments that don't expire.
+const noExpiry = math.MinInt32
+
+// epochIST is the day expiry days are counted from.
+var epochIST = time.Date(1970, 1, 1, 0, 0, 0, 0, ist)
+
+// columns are instruments stored field by field, a slice per field indexed
+// by row. Strings are interned into a table shared by the rows and stored
+// as their number in it, as the exchange, segment, type, name and dates of
+// the thousands of contracts of an underlying are the same. Rows are only
+// ever appended, so a copy of columns stays a consistent view of the rows
+// it has while more are added.
+type columns struct {
+	strings []string // interned, 0 is ""
+
+	ids, tradingsymbols, exchanges, isins, names, series []uint32
+	instrumentTypes, segments, deliveryUnits, priceUnits []uint32
+	expiryTypes, expiryDates, exerciseStartDates         []uint32
+	exerciseEndDates, issueDates, listingDates           []uint32
+	maturityDates                                        []uint32
+
+	instrumentTokens, exchangeTokens, freezeQuantities []uint32
+	lotSizes, multipliers, maxOrderQuantities          []int32
+	lastPrices, strikes, tickSizes                     []float64
+	lowerCircuitLimits, upperCircuitLimits             []float64
+
+	expiries []int32 // days since epochIST, noExpiry if none
+	active   []bool
+}
+
+// uint32Columns returns the columns of interned strings and unsigned
+// numbers.
+func (c *columns) uint32Columns() []*[]uint32 {
+	return []*[]uint32{
+		&c.ids, &c.tradingsymbols, &c.exchanges, &c.isins, &c.names, &c.series,
+		&c.instrumentTypes, &c.segments, &c.deliveryUnits, &c.priceUnits,
+		&c.expiryTypes, &c.expiryDates, &c.exerciseStartDates,
+		&c.exerciseEndDates, &c.issueDates, &c.listingDates, &c.maturityDates,
+		&c.instrumentTokens, &c.exchangeTokens, &c.freezeQuantities,
+	}
+}
+
+func (c *columns) int32Columns() []*[]int32 {
+	return []*[]int32{&c.lotSizes, &c.multipliers, &c.maxOrderQuantities, &c.expiries}
+}
+
+func (c *columns) float64Columns() []*[]float64 {
+	return []*[]float64{&c.lastPrices, &c.strikes, &c.tickSizes, &c.lowerCircuitLimits, &c.upperCircuitLimits}
+}
+
+// len returns the number of rows.
+func (c *columns) len() int32 {
+	return int32(len(c.instrumentTokens))
+}
+
+// str returns the string of a row in a column of interned strings.
+func (c *columns) str(col []uint32, row int32) string {
+	return c.strings[col[row]]
+}
+
+// expiry returns the expiry of a row at the start of the day in IST, zero
+// if it doesn't expire.
+func (c *columns) expiry(row int32) time.Time {
+	if c.expiries[row] == noExpiry {
+		return time.Time{}
+	}
+	return time.Date(1970, 1, 1+int(c.expiries[row]), 0, 0, 0, 0, ist)
+}
+
+// instrument returns the instrument of a row.
+func (c *columns) instrument(row int32) Instrument {
+	return Instrument{
+		ID:                c.str(c.ids, row),
+		InstrumentToken:   c.instrumentTokens[row],
+		ExchangeToken:     c.exchangeTokens[row],
+		Tradingsymbol:     c.str(c.tradingsymbols, row),
+		Exchange:          c.str(c.exchanges, row),
+		ISIN:              c.str(c.isins, row),
+		Name:              c.str(c.names, row),
+		Series:            c.str(c.series, row),
+		LastPrice:         c.lastPrices[row],
+		Strike:            c.strikes[row],
+		TickSize:          c.tickSizes[row],
+		LotSize:           int(c.lotSizes[row]),
+		Multiplier:        int(c.multipliers[row]),
+		InstrumentType:    c.str(c.instrumentTypes, row),
+		Segment:           c.str(c.segments, row),
+		DeliveryUnits:     c.str(c.deliveryUnits, row),
+		PriceUnits:        c.str(c.priceUnits, row),
+		FreezeQuantity:    c.freezeQuantities[row],
+		MaxOrderQuantity:  int(c.maxOrderQuantities[row]),
+		ExpiryType:        c.str(c.expiryTypes, row),
+		ExpiryDate:        c.str(c.expiryDates, row),
+		ExerciseStartDate: c.str(c.exerciseStartDates, row),
+		ExerciseEndDate:   c.str(c.exerciseEndDates, row),
+		IssueDate:         c.str(c.issueDates, row),
+		ListingDate:       c.str(c.listingDates, row),
+		MaturityDate:      c.str(c.maturityDates, row),
+		LowerCircuitLimit: c.lowerCircuitLimits[row],
+		UpperCircuitLimit: c.upperCircuitLimits[row],
+		Active:            c.active[row],
+	}
+}
+
+// store is the loaded instruments and their lookups by token, ID, ISIN
+// and segment. Adding an instrument with the token of one already stored
+// appends a new row and leaves the old one stale.
+type store struct {
+	columns
+
+	// interned finds the strings in the table while instruments are added.
+	// It is dropped once a load is complete and rebuilt by the next add.
+	interned map[string]uint32
+	// stringBytes is the size of the strings the table holds. Symbols that
+	// are the end of their ID share its bytes.
+	stringBytes int64
+
+	byToken map[uint32]int32
+	byID    map[string]int32   // IDs, and segment:tradingsymbol of indices
+	byISIN  map[string][]int32 // the rows of each ISIN
+
+	// NSE=1, BSE=2 etc. This is extracted from instrument tokens
+	// as they're loaded.
+	segmentIDs map[string]uint32
+
+	stale int // rows replaced by a later row of the same token
+
+	// partial is set when the instruments come from a dump without the
+	// csvMissingFields.
+	partial bool
+}
+
+// newStore returns an empty store with room for n instruments.
+func newStore(n int) *store {
+	s := &store{
+		interned:   map[string]uint32{"": 0},
+		byToken:    make(map[uint32]int32, n),
+		byID:       make(map[string]int32, n),
+		byISIN:     make(map[string][]int32),
+		segmentIDs: make(map[string]uint32),
+	}
+	s.strings = []string{""}
+	for _, col := range s.uint32Columns() {
+		*col = make([]uint32, 0, n)
+	}
+	for _, col := range s.int32Columns() {
+		*col = make([]int32, 0, n)
+	}
+	for _, col := range s.float64Columns() {
+		*col = make([]float64, 0, n)
+	}
+	s.active = make([]bool, 0, n)
+	return s
+}
+
+// storeOf returns a store of the given instruments.
+func storeOf(instruments map[uint32]*Instrument) *store {
+	s := newStore(len(instruments))
+	for _, inst := range instruments {
+		s.add(inst)
+	}
+	s.done()
+	return s
+}
+
+// intern returns the number of v in the string table, adding it if it
+// isn't there yet.
+func (s *store) intern(v string) uint32 {
+	if s.interned == nil {
+		s.interned = make(map[string]uint32, len(s.strings))
+		for i, str := range s.strings {
+			s.interned[str] = uint32(i)
+		}
+	}
+	if ref, ok := s.interned[v]; ok {
+		return ref
+	}
+	ref := uint32(len(s.strings))
+	s.strings = append(s.strings, v)
+	s.interned[v] = ref
+	s.stringBytes += int64(len(v))
+	return ref
+}
+
+// internSymbol interns the tradingsymbol of an instrument, as the end of
+// its ID when it is exchange:tradingsymbol.
+func (s *store) internSymbol(inst *Instrument, id uint32) uint32 {
+	if ref, ok := s.interned[inst.Tradingsymbol]; ok {
+		return ref
+	}
+	idString := s.strings[id]
+	if len(idString) != len(inst.Exchange)+1+len(inst.Tradingsymbol) || idString[len(inst.Exchange)+1:] != inst.Tradingsymbol {
+		return s.intern(inst.Tradingsymbol)
+	}
+	ref := uint32(len(s.strings))
+	s.strings = append(s.strings, idString[len(inst.Exchange)+1:])
+	s.interned[inst.Tradingsymbol] = ref
+	return ref
+}
+
+// add appends an instrument to the store.
+func (s *store) add(inst *Instrument) {
+	row := s.len()
+	id := s.intern(inst.ID)
+	s.ids = append(s.ids, id)
+	s.tradingsymbols = append(s.tradingsymbols, s.internSymbol(inst, id))
+	s.exchanges = append(s.exchanges, s.intern(inst.Exchange))
+	s.isins = append(s.isins, s.intern(inst.ISIN))
+	s.names = append(s.names, s.intern(inst.Name))
+	s.series = append(s.series, s.intern(inst.Series))
+	s.instrumentTypes = append(s.instrumentTypes, s.intern(inst.InstrumentType))
+	s.segments = append(s.segments, s.intern(inst.Segment))
+	s.deliveryUnits = append(s.deliveryUnits, s.intern(inst.DeliveryUnits))
+	s.priceUnits = append(s.priceUnits, s.intern(inst.PriceUnits))
+	s.expiryTypes = append(s.expiryTypes, s.intern(inst.ExpiryType))
+	s.expiryDates = append(s.expiryDates, s.intern(inst.ExpiryDate))
+	s.exerciseStartDates = append(s.exerciseStartDates, s.intern(inst.ExerciseStartDate))
+	s.exerciseEndDates = append(s.exerciseEndDates, s.intern(inst.ExerciseEndDate))
+	s.issueDates = append(s.issueDates, s.intern(inst.IssueDate))
+	s.listingDates = append(s.listingDates, s.intern(inst.ListingDate))
+	s.maturityDates = append(s.maturityDates, s.intern(inst.MaturityDate))
+
+	s.instrumentTokens = append(s.instrumentTokens, inst.InstrumentToken)
+	s.exchangeTokens = append(s.exchangeTokens, inst.ExchangeToken)
+	s.freezeQuantities = append(s.freezeQuantities, inst.FreezeQuantity)
+	s.lotSizes = append(s.lotSizes, int32(inst.LotSize))
+	s.multipliers = append(s.multipliers, int32(inst.Multiplier))
+	s.maxOrderQuantities = append(s.maxOrderQuantities, int32(inst.MaxOrderQuantity))
+	s.lastPrices = append(s.lastPrices, inst.LastPrice)
+	s.strikes = append(s.strikes, inst.Strike)
+	s.tickSizes = append(s.tickSizes, inst.TickSize)
+	s.lowerCircuitLimits = append(s.lowerCircuitLimits, inst.LowerCircuitLimit)
+	s.upperCircuitLimits = append(s.upperCircuitLimits, inst.UpperCircuitLimit)
+	s.active = append(s.active, inst.Active)
+
+	expiry := int32(noExpiry)
+	if t, ok := inst.Expiry(); ok {
+		expiry = int32(t.Sub(epochIST) / (24 * time.Hour))
+	}
+	s.expiries = append(s.expiries, expiry)
+
+	if old, ok := s.byToken[inst.InstrumentToken]; ok {
+		s.unlink(old)
+	}
+	s.byToken[inst.InstrumentToken] = row
+	s.byID[s.strings[id]] = row
+
+	// segment:tradingsymbol
+	// (to cover indices that are mapped by segments)
+	// and not exchanges always.
+	seg := inst.Exchange
+	if inst.Segment == segIndices {
+		seg = inst.Segment
+		s.byID[inst.Segment+":"+inst.Tradingsymbol] = row
+	}
+
+	// Get the exchange token out of the instrument and add it to
+	// the segment name -> ID map.
+	if _, ok := s.segmentIDs[seg]; !ok {
+		s.segmentIDs[seg] = GetSegmentID(inst.InstrumentToken)
+	}
+
+	if inst.ISIN != "" {
+		isin := s.strings[s.isins[row]]
+		s.byISIN[isin] = append(s.byISIN[isin], row)
+	}
+}
+
+// unlink drops the lookups of a row replaced by a new one of its token.
+func (s *store) unlink(row int32) {
+	s.stale++
+	if id := s.str(s.ids, row); s.byID[id] == row {
+		delete(s.byID, id)
+	}
+	isin := s.str(s.isins, row)
+	rows := s.byISIN[isin]
+	for i, r := range rows {
+		if r == row {
+			s.byISIN[isin] = append(rows[:i:i], rows[i+1:]...)
+			break
+		}
+	}
+	if len(s.byISIN[isin]) == 0 {
+		delete(s.byISIN, isin)
+	}
+}
+
+// done completes a load: the intern map is dropped and the columns are
+// trimmed to their length.
+func (s *store) done() {
+	s.interned = nil
+	s.strings = trim(s.strings)
+	for _, col := range s.uint32Columns() {
+		*col = trim(*col)
+	}
+	for _, col := range s.int32Columns() {
+		*col = trim(*col)
+	}
+	for _, col := range s.float64Columns() {
+		*col = trim(*col)
+	}
+	s.active = trim(s.active)
+}
+
+func trim[T any](s []T) []T {
+	if cap(s) == len(s) {
+		return s
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+// count returns the number of instruments.
+func (s *store) count() int {
+	return len(s.byToken)
+}
+
+// live reports whether a row is the current one of its token.
+func (s *store) live(row int32) bool {
+	return s.stale == 0 || s.byToken[s.instrumentTokens[row]] == row
+}
+
+// get returns the instrument with the given token.
+func (s *store) get(token uint32) (Instrument, bool) {
+	row, ok := s.byToken[token]
+	if !ok {
+		return Instrument{}, false
+	}
+	return s.instrument(row), true
+}
+
+// memoryUsage estimates the bytes the store takes on the heap: the
+// columns, the string table and the lookup maps.
+func (s *store) memoryUsage() int64 {
+	size := int64(cap(s.strings))*16 + s.stringBytes + int64(cap(s.active))
+	for _, col := range s.uint32Columns() {
+		size += int64(cap(*col)) * 4
+	}
+	for _, col := range s.int32Columns() {
+		size += int64(cap(*col)) * 4
+	}
+	for _, col := range s.float64Columns() {
+		size += int64(cap(*col)) * 8
+	}
+
+	size += mapBytes(len(s.byToken), 4+4)
+	size += mapBytes(len(s.byID), 16+8) // string key, int32 padded
+	size += mapBytes(len(s.segmentIDs), 16+8)
+	size += mapBytes(len(s.byISIN), 16+24)
+	for _, rows := range s.byISIN {
+		size += int64(cap(rows)) * 4
+	}
+	if s.interned != nil {
+		size += mapBytes(len(s.interned), 16+8)
+	}
+	return size
+}
+
+// mapBytes estimates the memory of a map of n entries of the given size.
+// Maps grow in powers of two to stay under 7/8 full, with a control byte
+// per slot.
+func mapBytes(n int, entry int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	slots := int64(8)
+	for slots*7/8 < int64(n) {
+		slots *= 2
+	}
+	return slots * (entry + 1)
+}
+
+// sizedMapBytes estimates the memory of a map of about n entries made with
+// a size hint of n, which gets just enough slots for them up front.
+func sizedMapBytes(n int, entry int64) int64 {
+	slots := (int64(n)*8 + 6) / 7
+	return slots * (entry + 1)
+}
@@ -0,0 +1,317 @@
+package instruments
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchmarkDump returns the benchmark instruments as a JSONL dump.
+func benchmarkDump(tb testing.TB) []byte {
+	tb.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, inst := range getBenchmarkInstruments() {
+		if inst.InstrumentType != "EQ" {
+			inst.ExpiryDate = "2025-01-30"
+		}
+		if err := encoder.Encode(inst); err != nil {
+			tb.Fatalf("Expected the instruments to encode, got: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// heapOf returns the bytes the value returned by build keeps on the heap.
+func heapOf(build func() any) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
+
+// instrumentMaps are the maps of pointers instruments used to be kept in,
+// to compare the store with.
+type instrumentMaps struct {
+	isinToInstruments map[string][]*Instrument
+	idToInst          map[string]*Instrument
+	idToToken         map[string]uint32
+	tokenToInstrument map[uint32]*Instrument
+}
+
+func loadMaps(tb testing.TB, dump []byte) *instrumentMaps {
+	m := &instrumentMaps{
+		isinToInstruments: make(map[string][]*Instrument),
+		idToInst:          make(map[string]*Instrument),
+		idToToken:         make(map[string]uint32),
+		tokenToInstrument: make(map[uint32]*Instrument),
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	for scanner.Scan() {
+		inst := &Instrument{}
+		if err := json.Unmarshal(scanner.Bytes(), inst); err != nil {
+			tb.Fatalf("Expected the dump to parse, got: %v", err)
+		}
+		if inst.ISIN != "" {
+			m.isinToInstruments[inst.ISIN] = append(m.isinToInstruments[inst.ISIN], inst)
+		}
+		m.idToToken[inst.ID] = inst.InstrumentToken
+		m.idToInst[inst.ID] = inst
+		m.tokenToInstrument[inst.InstrumentToken] = inst
+	}
+	return m
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	instruments := []*Instrument{
+		{ID: "NSE:SBIN", InstrumentToken: 779521, ExchangeToken: 3045, Tradingsymbol: "SBIN", Exchange: "NSE", ISIN: "INE062A01020", Name: "STATE BANK OF INDIA", Series: "EQ", TickSize: 0.05, LotSize: 1, InstrumentType: "EQ", Segment: "NSE", FreezeQuantity: 100000, MaxOrderQuantity: 500000, LowerCircuitLimit: 720.15, UpperCircuitLimit: 880.15, Active: true},
+		{ID: "BSE:SBIN", InstrumentToken: 128028676, Tradingsymbol: "SBIN", Exchange: "BSE", ISIN: "INE062A01020", Name: "STATE BANK OF INDIA", InstrumentType: "EQ", Segment: "BSE", Active: true},
+		{ID: "NFO:NIFTY25JAN22500CE", InstrumentToken: 1002, Tradingsymbol: "NIFTY25JAN22500CE", Exchange: "NFO", Name: "NIFTY", Strike: 22500, LotSize: 75, Multiplier: 1, InstrumentType: "CE", Segment: "NFO-OPT", ExpiryType: "monthly", ExpiryDate: "2025-01-30", LastPrice: 125.5},
+		{ID: "NSE:NIFTY 50", InstrumentToken: 256265, Tradingsymbol: "NIFTY 50", Exchange: "NSE", Name: "NIFTY 50", Segment: segIndices},
+		{ID: "MCX-GOLD", InstrumentToken: 53505799, Tradingsymbol: "GOLD25FEBFUT", Exchange: "MCX", DeliveryUnits: "KGS", PriceUnits: "10GRMS", ExpiryDate: "2025-02-05 23:59:59", InstrumentType: "FUT"},
+	}
+	s := newStore(0)
+	for _, inst := range instruments {
+		s.add(inst)
+	}
+	s.done()
+
+	for row, inst := range instruments {
+		if got := s.instrument(int32(row)); got != *inst {
+			t.Errorf("Expected %+v, got %+v", *inst, got)
+		}
+		expiry, _ := inst.Expiry()
+		if got := s.expiry(int32(row)); !got.Equal(expiry) {
+			t.Errorf("Expected %s to expire on %v, got %v", inst.ID, expiry, got)
+		}
+	}
+	if s.str(s.tradingsymbols, 0) != "SBIN" || s.interned != nil {
+		t.Error("Expected the symbol interned and the intern map dropped once done")
+	}
+	if row, ok := s.byID["INDICES:NIFTY 50"]; !ok || s.instrumentTokens[row] != 256265 {
+		t.Error("Expected indices looked up by segment:tradingsymbol")
+	}
+	if len(s.byISIN["INE062A01020"]) != 2 || s.segmentIDs["BSE"] != 4 {
+		t.Errorf("Expected the ISIN and segment lookups, got %v and %v", s.byISIN, s.segmentIDs)
+	}
+
+	// Adding a token again replaces its instrument
+	changed := *instruments[0]
+	changed.LotSize = 2
+	s.add(&changed)
+	if s.count() != len(instruments) || s.live(0) || !s.live(s.len()-1) {
+		t.Errorf("Expected the old row stale and %d instruments, got %d", len(instruments), s.count())
+	}
+	if inst, _ := s.get(779521); inst.LotSize != 2 {
+		t.Errorf("Expected the replaced instrument, got %+v", inst)
+	}
+	if len(s.byISIN["INE062A01020"]) != 2 {
+		t.Errorf("Expected the ISIN to list the instrument once, got %v", s.byISIN["INE062A01020"])
+	}
+}
+
+func TestParseInstrumentsJSON(t *testing.T) {
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+
+	dump := `{"instrument_token":779521,"tradingsymbol":"SBIN","exchange":"NSE"}
+
+{"instrument_token":738561,"tradingsymbol":"RELIANCE","exchange":"NSE"}
+`
+	instruments, err := manager.parseInstrumentsJSON(strings.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Expected the dump to parse, got: %v", err)
+	}
+	if instruments.count() != 2 {
+		t.Errorf("Expected 2 instruments, got %d", instruments.count())
+	}
+
+	if _, err := manager.parseInstrumentsJSON(strings.NewReader(dump + `{"instrument_token":"x"}`)); err == nil {
+		t.Error("Expected an error for a malformed instrument")
+	}
+}
+
+func TestStoreMemoryUsage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Measuring the heap is slow")
+	}
+	dump := benchmarkDump(t)
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+
+	var instruments *store
+	measured := heapOf(func() any {
+		var err error
+		instruments, err = manager.parseInstrumentsJSON(bytes.NewReader(dump))
+		if err != nil {
+			t.Fatalf("Expected the dump to parse, got: %v", err)
+		}
+		return instruments
+	})
+	runtime.KeepAlive(dump)
+	estimated := instruments.memoryUsage()
+	if off := math.Abs(float64(estimated-measured)) / float64(measured); off > 0.1 {
+		t.Errorf("Expected the memory usage estimate within 10%% of the %d bytes measured, got %d", measured, estimated)
+	}
+}
+
+func TestSearchIndexMemoryUsage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Measuring the heap is slow")
+	}
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+	instruments, err := manager.parseInstrumentsJSON(bytes.NewReader(benchmarkDump(t)))
+	if err != nil {
+		t.Fatalf("Expected the dump to parse, got: %v", err)
+	}
+
+	var index *searchIndex
+	measured := heapOf(func() any {
+		index = newSearchIndex(instruments)
+		return index
+	})
+	estimated := index.memoryUsage()
+	if off := math.Abs(float64(estimated-measured)) / float64(measured); off > 0.1 {
+		t.Errorf("Expected the memory usage estimate within 10%% of the %d bytes measured, got %d", measured, estimated)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	server := setupTestServer()
+	defer server.Close()
+	restore := hijackInstrumentsURL(server.URL)
+	defer restore()
+
+	manager := newTestManager()
+	defer manager.Shutdown()
+
+	usage := manager.GetUpdateStats().MemoryUsageBytes
+	if usage <= 0 {
+		t.Fatalf("Expected the memory usage of the loaded instruments, got %d", usage)
+	}
+	manager.mutex.RLock()
+	stored := manager.loaded.memoryUsage()
+	manager.mutex.RUnlock()
+	if usage <= stored {
+		t.Errorf("Expected the memory usage to count the search index beyond the %d bytes of instruments, got %d", stored, usage)
+	}
+
+	// The index counts towards the limit too
+	config := *manager.GetConfig()
+	config.MemoryLimit = stored
+	config.RetryDelay = time.Millisecond
+	manager.UpdateConfig(&config)
+	if err := manager.ForceUpdateInstruments(); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Expected ErrMemoryLimit counting the search index, got: %v", err)
+	}
+
+	config.MemoryLimit = 1
+	config.RetryDelay = time.Millisecond
+	manager.UpdateConfig(&config)
+
+	before := manager.GetUpdateStats()
+	if err := manager.ForceUpdateInstruments(); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Expected ErrMemoryLimit, got: %v", err)
+	}
+	if stats := manager.GetUpdateStats(); stats.FailedUpdates != before.FailedUpdates+1 {
+		t.Errorf("Expected a single failed update, got %d", stats.FailedUpdates-before.FailedUpdates)
+	}
+	if err := manager.Replace(map[uint32]*Instrument{1: {InstrumentToken: 1}}); !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("Expected ErrMemoryLimit, got: %v", err)
+	}
+	if _, err := manager.GetByID("NSE:SBIN"); err != nil || manager.Count() != len(getTestInstruments()) {
+		t.Errorf("Expected the instruments in use kept, got %d", manager.Count())
+	}
+
+	config.MemoryLimit = 64 * 1024 * 1024
+	manager.UpdateConfig(&config)
+	if err := manager.ForceUpdateInstruments(); err != nil {
+		t.Errorf("Expected the load within the limit, got: %v", err)
+	}
+
+	// A dump too large to fit is abandoned while it is decoded
+	config.MemoryLimit = 16 * 1024 * 1024
+	manager.UpdateConfig(&config)
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		dump := endlessDump(format)
+		into := newStore(0)
+		var err error
+		if format == FormatCSV {
+			err = manager.decodeInstrumentsCSV(dump, into)
+		} else {
+			err = manager.decodeInstrumentsJSON(dump, into)
+		}
+		_ = dump.Close()
+		if !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("Expected ErrMemoryLimit decoding an endless %s dump, got: %v", format, err)
+		}
+		if into.len() > 100000 {
+			t.Errorf("Expected the %s decode to stop once over the limit, got %d instruments taking %d bytes", format, into.len(), into.memoryUsage())
+		}
+	}
+}
+
+// endlessDump streams instruments in the format until it is closed.
+func endlessDump(format string) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		if format == FormatCSV {
+			if _, err := io.WriteString(w, "instrument_token,tradingsymbol,name,exchange\n"); err != nil {
+				return
+			}
+		}
+		for token := 1; ; token++ {
+			line := fmt.Sprintf(`{"instrument_token":%d,"tradingsymbol":"SYM%d","name":"NAME %d","exchange":"NSE"}`+"\n", token, token, token)
+			if format == FormatCSV {
+				line = fmt.Sprintf("%d,SYM%d,NAME %d,NSE\n", token, token, token)
+			}
+			if _, err := io.WriteString(w, line); err != nil {
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// BenchmarkInstrumentsHeap compares the heap taken by a full dump kept in
+// maps of pointers with the store
+func BenchmarkInstrumentsHeap(b *testing.B) {
+	dump := benchmarkDump(b)
+	manager := newTestManagerWithoutUpdate()
+	defer manager.Shutdown()
+	count := float64(bytes.Count(dump, []byte("\n")))
+
+	b.Run("maps", func(b *testing.B) {
+		var heap int64
+		for i := 0; i < b.N; i++ {
+			heap = heapOf(func() any { return loadMaps(b, dump) })
+		}
+		b.ReportMetric(float64(heap)/count, "heap-B/instrument")
+	})
+	b.Run("columns", func(b *testing.B) {
+		var heap int64
+		for i := 0; i < b.N; i++ {
+			heap = heapOf(func() any {
+				instruments, err := manager.parseInstrumentsJSON(bytes.NewReader(dump))
+				if err != nil {
+					b.Fatalf("Expected the dump to parse, got: %v", err)
+				}
+				return instruments
+			})
+		}
+		b.ReportMetric(float64(heap)/count, "heap-B/instrument")
+	})
+}
@@ -0,0 +1,202 @@
+package kitefake
+
+import (
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+const (
+	gttActive    = "active"
+	gttTriggered = "triggered"
+	gttDeleted   = "deleted"
+	gttRejected  = "rejected"
+
+	// gttLifetime is how long a GTT stays active.
+	gttLifetime = 365 * 24 * time.Hour
+)
+
+func (b *Broker) gttFromParams(id int, params kiteconnect.GTTParams) (kiteconnect.GTT, error) {
+	if _, ok := b.markets[params.Exchange+":"+params.Tradingsymbol]; !ok {
+		return kiteconnect.GTT{}, inputError("Invalid `tradingsymbol`.")
+	}
+	if params.Trigger == nil {
+		return kiteconnect.GTT{}, inputError("Invalid `trigger_type`.")
+	}
+	if params.TransactionType != kiteconnect.TransactionTypeBuy && params.TransactionType != kiteconnect.TransactionTypeSell {
+		return kiteconnect.GTT{}, inputError("Invalid `transaction_type`.")
+	}
+
+	triggers := params.Trigger.TriggerValues()
+	prices := params.Trigger.LimitPrices()
+	quantities := params.Trigger.Quantities()
+	orders := make([]kiteconnect.Order, len(triggers))
+	for i := range triggers {
+		if triggers[i] <= 0 || prices[i] <= 0 || quantities[i] <= 0 {
+			return kiteconnect.GTT{}, inputError("Trigger values, prices and quantities should be greater than zero.")
+		}
+		orders[i] = kiteconnect.Order{
+			Exchange:        params.Exchange,
+			TradingSymbol:   params.Tradingsymbol,
+			TransactionType: params.TransactionType,
+			Product:         params.Product,
+			OrderType:       kiteconnect.OrderTypeLimit,
+			Quantity:        quantities[i],
+			Price:           prices[i],
+		}
+	}
+	if len(triggers) == 2 && triggers[0] >= triggers[1] {
+		return kiteconnect.GTT{}, inputError("The lower trigger should be below the upper trigger.")
+	}
+
+	now := b.now()
+	return kiteconnect.GTT{
+		ID:        id,
+		UserID:    b.profile.UserID,
+		Type:      params.Trigger.Type(),
+		CreatedAt: modelTime(now),
+		UpdatedAt: modelTime(now),
+		ExpiresAt: modelTime(now.Add(gttLifetime)),
+		Status:    gttActive,
+		Condition: kiteconnect.GTTCondition{
+			Exchange:      params.Exchange,
+			Tradingsymbol: params.Tradingsymbol,
+			LastPrice:     params.LastPrice,
+			TriggerValues: triggers,
+		},
+		Orders: orders,
+	}, nil
+}
+
+func (c *Client) GetGTTs() (kiteconnect.GTTs, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	gtts := make(kiteconnect.GTTs, 0, len(b.gttIDs))
+	for i := len(b.gttIDs) - 1; i >= 0; i-- {
+		gtts = append(gtts, *b.gtts[b.gttIDs[i]])
+	}
+	return gtts, nil
+}
+
+func (c *Client) GetGTT(triggerID int) (kiteconnect.GTT, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.GTT{}, err
+	}
+	defer c.unlock()
+
+	gtt, ok := c.broker.gtts[triggerID]
+	if !ok {
+		return kiteconnect.GTT{}, inputError("Invalid trigger ID.")
+	}
+	return *gtt, nil
+}
+
+func (c *Client) PlaceGTT(params kiteconnect.GTTParams) (kiteconnect.GTTResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.GTTResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	gtt, err := b.gttFromParams(100000+b.nextID(), params)
+	if err != nil {
+		return kiteconnect.GTTResponse{}, err
+	}
+	b.gtts[gtt.ID] = &gtt
+	b.gttIDs = append(b.gttIDs, gtt.ID)
+	return kiteconnect.GTTResponse{TriggerID: gtt.ID}, nil
+}
+
+func (c *Client) ModifyGTT(triggerID int, params kiteconnect.GTTParams) (kiteconnect.GTTResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.GTTResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	existing, ok := b.gtts[triggerID]
+	if !ok {
+		return kiteconnect.GTTResponse{}, inputError("Invalid trigger ID.")
+	}
+	if existing.Status != gttActive {
+		return kiteconnect.GTTResponse{}, inputError("Trigger is %s and cannot be modified.", existing.Status)
+	}
+	gtt, err := b.gttFromParams(triggerID, params)
+	if err != nil {
+		return kiteconnect.GTTResponse{}, err
+	}
+	gtt.CreatedAt = existing.CreatedAt
+	gtt.ExpiresAt = existing.ExpiresAt
+	*existing = gtt
+	return kiteconnect.GTTResponse{TriggerID: triggerID}, nil
+}
+
+func (c *Client) DeleteGTT(triggerID int) (kiteconnect.GTTResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.GTTResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	gtt, ok := b.gtts[triggerID]
+	if !ok {
+		return kiteconnect.GTTResponse{}, inputError("Invalid trigger ID.")
+	}
+	if gtt.Status == gttDeleted {
+		return kiteconnect.GTTResponse{}, inputError("Trigger is already deleted.")
+	}
+	gtt.Status = gttDeleted
+	gtt.UpdatedAt = modelTime(b.now())
+	return kiteconnect.GTTResponse{TriggerID: triggerID}, nil
+}
+
+// fireGTT places the order of the leg of an active GTT whose trigger the
+// last price crossed. A single-leg trigger fires when the price reaches it
+// from the side it was on when the GTT was placed.
+func (b *Broker) fireGTT(gtt *kiteconnect.GTT, ltp float64) {
+	if gtt.Status != gttActive {
+		return
+	}
+
+	leg := -1
+	triggers := gtt.Condition.TriggerValues
+	switch len(triggers) {
+	case 1:
+		above := triggers[0] >= gtt.Condition.LastPrice
+		if (above && ltp >= triggers[0]) || (!above && ltp <= triggers[0]) {
+			leg = 0
+		}
+	case 2:
+		if ltp <= triggers[0] {
+			leg = 0
+		} else if ltp >= triggers[1] {
+			leg = 1
+		}
+	}
+	if leg < 0 {
+		return
+	}
+
+	o := gtt.Orders[leg]
+	id, err := b.placeOrder(kiteconnect.VarietyRegular, kiteconnect.OrderParams{
+		Exchange:        o.Exchange,
+		Tradingsymbol:   o.TradingSymbol,
+		TransactionType: o.TransactionType,
+		Product:         o.Product,
+		OrderType:       o.OrderType,
+		Quantity:        int(o.Quantity),
+		Price:           o.Price,
+	})
+	gtt.UpdatedAt = modelTime(b.now())
+	if err != nil {
+		gtt.Status = gttRejected
+		gtt.Orders[leg].StatusMessage = err.Error()
+		return
+	}
+	gtt.Status = gttTriggered
+	gtt.Orders[leg].OrderID = id
+}
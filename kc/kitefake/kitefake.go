@@ -0,0 +1,368 @@
+// Package kitefake is an in-memory stand-in for the Kite Connect API. A
+// Broker holds the state of one account and simulates the exchange: orders
+// fill against the last prices of its instruments, fills update positions
+// and trades, GTTs fire as prices cross their triggers, and mutual fund
+// orders and SIPs are kept in books of their own. Each session gets its own
+// Client, which must complete a login like a real one before it can call
+// the API.
+package kitefake
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+const (
+	// UserID is the user the broker logs sessions in as.
+	UserID = "AB1234"
+
+	loginURL = "https://kite.zerodha.com/connect/login"
+
+	// defaultCash is the opening balance of the equity and commodity
+	// segments.
+	defaultCash = 1000000.0
+)
+
+// Instrument is a tradable instrument of the simulated market.
+type Instrument struct {
+	Exchange        string
+	Tradingsymbol   string
+	InstrumentToken int
+	LastPrice       float64
+	Close           float64 // previous close, defaults to LastPrice
+	Volume          int     // volume traded so far today
+
+	// Circuit limits default to 20% either side of the close.
+	LowerCircuitLimit float64
+	UpperCircuitLimit float64
+}
+
+// ID returns the instrument as EXCHANGE:TRADINGSYMBOL.
+func (i Instrument) ID() string {
+	return i.Exchange + ":" + i.Tradingsymbol
+}
+
+// market is the trading state of an instrument for the day.
+type market struct {
+	Instrument
+	open, high, low float64
+	turnover        float64
+	candles         map[string][]kiteconnect.HistoricalData
+}
+
+// order is an order in the order book with its history.
+type order struct {
+	current kiteconnect.Order
+	history []kiteconnect.Order
+}
+
+// Broker is the simulated account and market shared by its clients.
+type Broker struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	profile kiteconnect.UserProfile
+	margins kiteconnect.AllMargins
+	tokens  map[string]bool
+
+	markets map[string]*market
+	tokenID map[int]string
+
+	orders    map[string]*order
+	orderIDs  []string
+	trades    []kiteconnect.Trade
+	positions map[string]*kiteconnect.Position
+	posKeys   []string
+	holdings  []kiteconnect.Holding
+	gtts      map[int]*kiteconnect.GTT
+	gttIDs    []int
+
+	mfInstruments kiteconnect.MFInstruments
+	mfHoldings    kiteconnect.MFHoldings
+	mfOrders      map[string]*kiteconnect.MFOrder
+	mfOrderIDs    []string
+	sips          map[string]*kiteconnect.MFSIP
+	sipIDs        []string
+	allottedISINs kiteconnect.MFAllottedISINs
+
+	seq int
+}
+
+// New creates a broker with an empty market and an account funded with
+// ₹10,00,000 in each segment.
+func New() *Broker {
+	cash := func() kiteconnect.Margins {
+		return kiteconnect.Margins{
+			Enabled: true,
+			Net:     defaultCash,
+			Available: kiteconnect.AvailableMargins{
+				Cash:           defaultCash,
+				LiveBalance:    defaultCash,
+				OpeningBalance: defaultCash,
+			},
+		}
+	}
+
+	return &Broker{
+		now: time.Now,
+		profile: kiteconnect.UserProfile{
+			UserID:        UserID,
+			UserName:      "Kite Fake",
+			UserShortName: "Fake",
+			UserType:      "individual",
+			Email:         "fake@example.com",
+			Broker:        "ZERODHA",
+			Products:      []string{"CNC", "NRML", "MIS", "MTF"},
+			OrderTypes:    []string{"MARKET", "LIMIT", "SL", "SL-M"},
+			Exchanges:     []string{"NSE", "BSE", "NFO", "BFO", "CDS", "BCD", "MCX", "MF"},
+		},
+		margins:   kiteconnect.AllMargins{Equity: cash(), Commodity: cash()},
+		tokens:    make(map[string]bool),
+		markets:   make(map[string]*market),
+		tokenID:   make(map[int]string),
+		orders:    make(map[string]*order),
+		positions: make(map[string]*kiteconnect.Position),
+		gtts:      make(map[int]*kiteconnect.GTT),
+		mfOrders:  make(map[string]*kiteconnect.MFOrder),
+		sips:      make(map[string]*kiteconnect.MFSIP),
+	}
+}
+
+// SetClock replaces the clock that timestamps orders, trades and quotes.
+func (b *Broker) SetClock(now func() time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.now = now
+}
+
+// SetProfile replaces the profile of the account.
+func (b *Broker) SetProfile(profile kiteconnect.UserProfile) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.profile = profile
+}
+
+// SetMargins replaces the funds of the account.
+func (b *Broker) SetMargins(margins kiteconnect.AllMargins) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.margins = margins
+}
+
+// AddInstrument lists an instrument in the market, replacing any with the
+// same exchange and trading symbol.
+func (b *Broker) AddInstrument(inst Instrument) {
+	if inst.Close == 0 {
+		inst.Close = inst.LastPrice
+	}
+	if inst.LowerCircuitLimit == 0 {
+		inst.LowerCircuitLimit = roundTick(inst.Close * 0.8)
+	}
+	if inst.UpperCircuitLimit == 0 {
+		inst.UpperCircuitLimit = roundTick(inst.Close * 1.2)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.markets[inst.ID()] = &market{
+		Instrument: inst,
+		open:       inst.LastPrice,
+		high:       inst.LastPrice,
+		low:        inst.LastPrice,
+		turnover:   inst.LastPrice * float64(inst.Volume),
+		candles:    make(map[string][]kiteconnect.HistoricalData),
+	}
+	b.tokenID[inst.InstrumentToken] = inst.ID()
+}
+
+// SetCandles sets the candles returned for an instrument token and
+// interval. GetHistoricalData returns the ones within the requested range.
+func (b *Broker) SetCandles(instrumentToken int, interval string, candles []kiteconnect.HistoricalData) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.markets[b.tokenID[instrumentToken]]
+	if !ok {
+		return fmt.Errorf("unknown instrument token %d", instrumentToken)
+	}
+	m.candles[interval] = candles
+	return nil
+}
+
+// AddHolding adds a holding to the demat account.
+func (b *Broker) AddHolding(holding kiteconnect.Holding) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.holdings = append(b.holdings, holding)
+}
+
+// AddPosition adds a position carried over from an earlier session.
+func (b *Broker) AddPosition(position kiteconnect.Position) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.position(position.Exchange, position.Tradingsymbol, position.Product)
+	*p = position
+	if p.Multiplier == 0 {
+		p.Multiplier = 1
+	}
+	if p.OvernightQuantity == 0 {
+		p.OvernightQuantity = p.Quantity
+	}
+	if p.BuyQuantity == 0 && p.SellQuantity == 0 {
+		if p.Quantity > 0 {
+			p.BuyQuantity, p.BuyPrice, p.BuyValue = p.Quantity, p.AveragePrice, float64(p.Quantity)*p.AveragePrice
+		} else {
+			p.SellQuantity, p.SellPrice, p.SellValue = -p.Quantity, p.AveragePrice, float64(-p.Quantity)*p.AveragePrice
+		}
+	}
+}
+
+// Tick trades volume units of an instrument at price in the market. Open
+// orders and GTTs the new price crosses are executed.
+func (b *Broker) Tick(instrument string, price float64, volume int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.markets[instrument]
+	if !ok {
+		return fmt.Errorf("unknown instrument %s", instrument)
+	}
+	m.trade(price, volume)
+	b.match(instrument)
+	return nil
+}
+
+// Client creates the client of a new session. It has to complete a login
+// with GenerateSession before it can call the API.
+func (b *Broker) Client(apiKey string) *Client {
+	return &Client{broker: b, apiKey: apiKey}
+}
+
+func (b *Broker) nextID() int {
+	b.seq++
+	return b.seq
+}
+
+func (m *market) trade(price float64, volume int) {
+	m.LastPrice = price
+	m.high = max(m.high, price)
+	m.low = min(m.low, price)
+	m.Volume += volume
+	m.turnover += price * float64(volume)
+}
+
+func (m *market) averagePrice() float64 {
+	if m.Volume == 0 {
+		return m.LastPrice
+	}
+	return roundTick(m.turnover / float64(m.Volume))
+}
+
+// Client is the Kite Connect API of one session. It implements the client
+// interface of the kc package.
+type Client struct {
+	broker *Broker
+	apiKey string
+
+	mu          sync.Mutex
+	accessToken string
+}
+
+func tokenError() error {
+	return kiteconnect.NewError(kiteconnect.TokenError, "Incorrect `api_key` or `access_token`.", nil)
+}
+
+func inputError(format string, args ...any) error {
+	return kiteconnect.NewError(kiteconnect.InputError, fmt.Sprintf(format, args...), nil)
+}
+
+func orderError(format string, args ...any) error {
+	return kiteconnect.NewError(kiteconnect.OrderError, fmt.Sprintf(format, args...), nil)
+}
+
+// lock locks the broker for an API call, failing if the session is not
+// logged in. The caller unlocks the broker when it gets no error.
+func (c *Client) lock() error {
+	c.mu.Lock()
+	token := c.accessToken
+	c.mu.Unlock()
+
+	c.broker.mu.Lock()
+	if token == "" || !c.broker.tokens[token] {
+		c.broker.mu.Unlock()
+		return tokenError()
+	}
+	return nil
+}
+
+func (c *Client) unlock() {
+	c.broker.mu.Unlock()
+}
+
+func (c *Client) GetLoginURL() string {
+	return fmt.Sprintf("%s?api_key=%s&v=3", loginURL, c.apiKey)
+}
+
+// GenerateSession accepts any non-empty request token and logs the session
+// in as the broker's user.
+func (c *Client) GenerateSession(requestToken string, apiSecret string) (kiteconnect.UserSession, error) {
+	if requestToken == "" || apiSecret == "" {
+		return kiteconnect.UserSession{}, tokenError()
+	}
+
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	token := fmt.Sprintf("access-%d", b.nextID())
+	b.tokens[token] = true
+
+	return kiteconnect.UserSession{
+		UserProfile: b.profile,
+		UserSessionTokens: kiteconnect.UserSessionTokens{
+			UserID:      b.profile.UserID,
+			AccessToken: token,
+		},
+		UserID:    b.profile.UserID,
+		APIKey:    c.apiKey,
+		LoginTime: modelTime(b.now()),
+	}, nil
+}
+
+func (c *Client) SetAccessToken(accessToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = accessToken
+}
+
+func (c *Client) InvalidateAccessToken() (bool, error) {
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.unlock()
+
+	c.mu.Lock()
+	delete(c.broker.tokens, c.accessToken)
+	c.accessToken = ""
+	c.mu.Unlock()
+	return true, nil
+}
+
+func (c *Client) GetUserProfile() (kiteconnect.UserProfile, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.UserProfile{}, err
+	}
+	defer c.unlock()
+	return c.broker.profile, nil
+}
+
+func (c *Client) GetUserMargins() (kiteconnect.AllMargins, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.AllMargins{}, err
+	}
+	defer c.unlock()
+	margins := c.broker.margins
+	margins.Equity.Category = "equity"
+	margins.Commodity.Category = "commodity"
+	return margins, nil
+}
@@ -0,0 +1,320 @@
+package kitefake
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+func newTestBroker(t *testing.T) (*Broker, *Client) {
+	t.Helper()
+	b := New()
+	b.SetClock(func() time.Time { return time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC) })
+	b.AddInstrument(Instrument{Exchange: "NSE", Tradingsymbol: "INFY", InstrumentToken: 408065, LastPrice: 1500, Volume: 1000})
+
+	c := b.Client("key")
+	sess, err := c.GenerateSession("request", "secret")
+	if err != nil {
+		t.Fatalf("GenerateSession failed: %v", err)
+	}
+	c.SetAccessToken(sess.AccessToken)
+	return b, c
+}
+
+func buy(qty int, orderType string, price, trigger float64) kiteconnect.OrderParams {
+	return kiteconnect.OrderParams{
+		Exchange:        "NSE",
+		Tradingsymbol:   "INFY",
+		TransactionType: kiteconnect.TransactionTypeBuy,
+		Product:         kiteconnect.ProductMIS,
+		OrderType:       orderType,
+		Quantity:        qty,
+		Price:           price,
+		TriggerPrice:    trigger,
+	}
+}
+
+func lastStatus(t *testing.T, c *Client, orderID string) kiteconnect.Order {
+	t.Helper()
+	history, err := c.GetOrderHistory(orderID)
+	if err != nil {
+		t.Fatalf("GetOrderHistory failed: %v", err)
+	}
+	return history[len(history)-1]
+}
+
+func TestLoginRequired(t *testing.T) {
+	b := New()
+	c := b.Client("key")
+
+	var kerr kiteconnect.Error
+	if _, err := c.GetUserProfile(); !errors.As(err, &kerr) || kerr.ErrorType != kiteconnect.TokenError {
+		t.Errorf("Expected a token error before login, got %v", err)
+	}
+	if _, err := c.GenerateSession("", "secret"); err == nil {
+		t.Error("Expected an empty request token to be rejected")
+	}
+
+	sess, err := c.GenerateSession("request", "secret")
+	if err != nil {
+		t.Fatalf("GenerateSession failed: %v", err)
+	}
+	c.SetAccessToken(sess.AccessToken)
+	profile, err := c.GetUserProfile()
+	if err != nil || profile.UserID != UserID {
+		t.Errorf("Expected profile of %s, got %+v (%v)", UserID, profile, err)
+	}
+
+	if _, err := c.InvalidateAccessToken(); err != nil {
+		t.Fatalf("InvalidateAccessToken failed: %v", err)
+	}
+	if _, err := c.GetUserProfile(); err == nil {
+		t.Error("Expected an invalidated session to be rejected")
+	}
+}
+
+func TestMarketOrderFills(t *testing.T) {
+	_, c := newTestBroker(t)
+
+	resp, err := c.PlaceOrder(kiteconnect.VarietyRegular, buy(10, kiteconnect.OrderTypeMarket, 0, 0))
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	order := lastStatus(t, c, resp.OrderID)
+	if order.Status != kiteconnect.OrderStatusComplete || order.FilledQuantity != 10 || order.AveragePrice != 1500 {
+		t.Errorf("Expected 10 filled at 1500, got %s %v at %v", order.Status, order.FilledQuantity, order.AveragePrice)
+	}
+
+	trades, _ := c.GetOrderTrades(resp.OrderID)
+	if len(trades) != 1 || trades[0].Quantity != 10 {
+		t.Errorf("Expected one trade of 10, got %+v", trades)
+	}
+
+	positions, _ := c.GetPositions()
+	if len(positions.Net) != 1 || positions.Net[0].Quantity != 10 || positions.Net[0].AveragePrice != 1500 {
+		t.Fatalf("Expected a net position of 10 at 1500, got %+v", positions.Net)
+	}
+
+	quotes, _ := c.GetQuote("NSE:INFY", "NSE:UNKNOWN")
+	if _, ok := quotes["NSE:UNKNOWN"]; ok || quotes["NSE:INFY"].Volume != 1010 {
+		t.Errorf("Expected volume 1010 and no unknown quote, got %+v", quotes)
+	}
+}
+
+func TestLimitOrderWaitsForPrice(t *testing.T) {
+	b, c := newTestBroker(t)
+
+	resp, err := c.PlaceOrder(kiteconnect.VarietyRegular, buy(5, kiteconnect.OrderTypeLimit, 1480, 0))
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if status := lastStatus(t, c, resp.OrderID).Status; status != "OPEN" {
+		t.Fatalf("Expected OPEN, got %s", status)
+	}
+
+	if _, err := c.ModifyOrder(kiteconnect.VarietyRegular, resp.OrderID, kiteconnect.OrderParams{Price: 1490}); err != nil {
+		t.Fatalf("ModifyOrder failed: %v", err)
+	}
+	if err := b.Tick("NSE:INFY", 1495, 100); err != nil {
+		t.Fatal(err)
+	}
+	if status := lastStatus(t, c, resp.OrderID).Status; status != "OPEN" {
+		t.Errorf("Expected OPEN above the limit, got %s", status)
+	}
+
+	_ = b.Tick("NSE:INFY", 1488, 100)
+	order := lastStatus(t, c, resp.OrderID)
+	if order.Status != kiteconnect.OrderStatusComplete || order.AveragePrice != 1488 {
+		t.Errorf("Expected fill at 1488, got %s at %v", order.Status, order.AveragePrice)
+	}
+
+	if _, err := c.CancelOrder(kiteconnect.VarietyRegular, resp.OrderID, nil); err == nil {
+		t.Error("Expected a complete order not to be cancellable")
+	}
+}
+
+func TestStopOrderTriggers(t *testing.T) {
+	b, c := newTestBroker(t)
+
+	resp, _ := c.PlaceOrder(kiteconnect.VarietyRegular, buy(5, kiteconnect.OrderTypeSLM, 0, 1520))
+	if status := lastStatus(t, c, resp.OrderID).Status; status != "TRIGGER PENDING" {
+		t.Fatalf("Expected TRIGGER PENDING, got %s", status)
+	}
+	_ = b.Tick("NSE:INFY", 1525, 10)
+	if order := lastStatus(t, c, resp.OrderID); order.Status != kiteconnect.OrderStatusComplete || order.AveragePrice != 1525 {
+		t.Errorf("Expected fill at 1525, got %s at %v", order.Status, order.AveragePrice)
+	}
+
+	resp, _ = c.PlaceOrder(kiteconnect.VarietyRegular, buy(5, kiteconnect.OrderTypeSL, 1560, 1550))
+	if _, err := c.CancelOrder(kiteconnect.VarietyRegular, resp.OrderID, nil); err != nil {
+		t.Fatalf("CancelOrder failed: %v", err)
+	}
+	if order := lastStatus(t, c, resp.OrderID); order.Status != kiteconnect.OrderStatusCancelled || order.CancelledQuantity != 5 {
+		t.Errorf("Expected 5 cancelled, got %s %v", order.Status, order.CancelledQuantity)
+	}
+}
+
+func TestOrderValidation(t *testing.T) {
+	_, c := newTestBroker(t)
+
+	tests := map[string]kiteconnect.OrderParams{
+		"unknown symbol":   {Exchange: "NSE", Tradingsymbol: "NOPE", TransactionType: "BUY", Product: "CNC", OrderType: "MARKET", Quantity: 1},
+		"zero quantity":    buy(0, kiteconnect.OrderTypeMarket, 0, 0),
+		"limit no price":   buy(1, kiteconnect.OrderTypeLimit, 0, 0),
+		"outside circuits": buy(1, kiteconnect.OrderTypeLimit, 2000, 0),
+	}
+	for name, params := range tests {
+		if _, err := c.PlaceOrder(kiteconnect.VarietyRegular, params); err == nil {
+			t.Errorf("%s: expected the order to be rejected", name)
+		}
+	}
+}
+
+func TestGTTFires(t *testing.T) {
+	b, c := newTestBroker(t)
+
+	resp, err := c.PlaceGTT(kiteconnect.GTTParams{
+		Exchange:        "NSE",
+		Tradingsymbol:   "INFY",
+		LastPrice:       1500,
+		TransactionType: kiteconnect.TransactionTypeSell,
+		Product:         kiteconnect.ProductCNC,
+		Trigger: &kiteconnect.GTTOneCancelsOtherTrigger{
+			Upper: kiteconnect.TriggerParams{TriggerValue: 1600, LimitPrice: 1600, Quantity: 5},
+			Lower: kiteconnect.TriggerParams{TriggerValue: 1400, LimitPrice: 1400, Quantity: 5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PlaceGTT failed: %v", err)
+	}
+
+	_ = b.Tick("NSE:INFY", 1550, 10)
+	if gtt, _ := c.GetGTT(resp.TriggerID); gtt.Status != "active" {
+		t.Fatalf("Expected active, got %s", gtt.Status)
+	}
+	_ = b.Tick("NSE:INFY", 1605, 10)
+	gtt, _ := c.GetGTT(resp.TriggerID)
+	if gtt.Status != "triggered" || gtt.Orders[1].OrderID == "" {
+		t.Fatalf("Expected the upper leg to trigger, got %+v", gtt)
+	}
+	if order := lastStatus(t, c, gtt.Orders[1].OrderID); order.Status != kiteconnect.OrderStatusComplete {
+		t.Errorf("Expected the target order to fill, got %s", order.Status)
+	}
+
+	if _, err := c.ModifyGTT(resp.TriggerID, kiteconnect.GTTParams{}); err == nil {
+		t.Error("Expected a triggered GTT not to be modifiable")
+	}
+	if _, err := c.DeleteGTT(resp.TriggerID); err != nil {
+		t.Errorf("DeleteGTT failed: %v", err)
+	}
+}
+
+func TestConvertPosition(t *testing.T) {
+	_, c := newTestBroker(t)
+	_, _ = c.PlaceOrder(kiteconnect.VarietyRegular, buy(10, kiteconnect.OrderTypeMarket, 0, 0))
+
+	ok, err := c.ConvertPosition(kiteconnect.ConvertPositionParams{
+		Exchange: "NSE", TradingSymbol: "INFY", OldProduct: "MIS", NewProduct: "CNC",
+		PositionType: "day", TransactionType: "BUY", Quantity: 4,
+	})
+	if err != nil || !ok {
+		t.Fatalf("ConvertPosition failed: %v", err)
+	}
+
+	positions, _ := c.GetPositions()
+	quantities := map[string]int{}
+	for _, p := range positions.Net {
+		quantities[p.Product] = p.Quantity
+	}
+	if quantities["MIS"] != 6 || quantities["CNC"] != 4 {
+		t.Errorf("Expected 6 MIS and 4 CNC, got %v", quantities)
+	}
+
+	if _, err := c.ConvertPosition(kiteconnect.ConvertPositionParams{Exchange: "NSE", TradingSymbol: "INFY", OldProduct: "MIS", NewProduct: "CNC", Quantity: 20}); err == nil {
+		t.Error("Expected converting more than the position to fail")
+	}
+}
+
+func TestMarginsAndCharges(t *testing.T) {
+	_, c := newTestBroker(t)
+
+	margins, err := c.GetOrderMargins(kiteconnect.GetMarginParams{OrderParams: []kiteconnect.OrderMarginParam{
+		{Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "BUY", Product: "CNC", OrderType: "MARKET", Quantity: 10},
+		{Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "BUY", Product: "MIS", OrderType: "LIMIT", Quantity: 10, Price: 1400},
+	}})
+	if err != nil {
+		t.Fatalf("GetOrderMargins failed: %v", err)
+	}
+	if margins[0].Total != 15000 || margins[1].Total != 2800 {
+		t.Errorf("Expected margins 15000 and 2800, got %v and %v", margins[0].Total, margins[1].Total)
+	}
+
+	charges, err := c.GetOrderCharges(kiteconnect.GetChargesParams{OrderParams: []kiteconnect.OrderChargesParam{
+		{Exchange: "NSE", Tradingsymbol: "INFY", TransactionType: "SELL", Product: "CNC", OrderType: "MARKET", Quantity: 10, AveragePrice: 1500},
+	}})
+	if err != nil {
+		t.Fatalf("GetOrderCharges failed: %v", err)
+	}
+	if c := charges[0].Charges; c.Brokerage != 0 || c.TransactionTax != 15 || c.Total <= c.TransactionTax {
+		t.Errorf("Expected delivery STT of 15 and no brokerage, got %+v", c)
+	}
+}
+
+func TestHistoricalData(t *testing.T) {
+	b, c := newTestBroker(t)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []kiteconnect.HistoricalData
+	for i := range 5 {
+		candles = append(candles, kiteconnect.HistoricalData{Date: modelTime(day.AddDate(0, 0, i)), Close: float64(1500 + i)})
+	}
+	if err := b.SetCandles(408065, "day", candles); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetHistoricalData(408065, "day", day.AddDate(0, 0, 1), day.AddDate(0, 0, 3), false, false)
+	if err != nil {
+		t.Fatalf("GetHistoricalData failed: %v", err)
+	}
+	if len(got) != 3 || got[0].Close != 1501 {
+		t.Errorf("Expected 3 candles from 1501, got %+v", got)
+	}
+	if _, err := c.GetHistoricalData(1, "day", day, day, false, false); err == nil {
+		t.Error("Expected an unknown token to fail")
+	}
+}
+
+func TestMutualFunds(t *testing.T) {
+	b, c := newTestBroker(t)
+	b.AddMFInstrument(kiteconnect.MFInstrument{Tradingsymbol: "INF740K01DP8", Name: "DSP Equity Fund", LastPrice: 80, MinimumPurchaseAmount: 500})
+
+	if _, err := c.PlaceMFOrder(kiteconnect.MFOrderParams{Tradingsymbol: "INF740K01DP8", TransactionType: "BUY", Amount: 100}); err == nil {
+		t.Error("Expected an amount below the minimum to be rejected")
+	}
+	order, err := c.PlaceMFOrder(kiteconnect.MFOrderParams{Tradingsymbol: "INF740K01DP8", TransactionType: "BUY", Amount: 5000})
+	if err != nil {
+		t.Fatalf("PlaceMFOrder failed: %v", err)
+	}
+	if _, err := c.CancelMFOrder(order.OrderID); err != nil {
+		t.Fatalf("CancelMFOrder failed: %v", err)
+	}
+	if orders, _ := c.GetMFOrders(); orders[0].Status != "CANCELLED" {
+		t.Errorf("Expected CANCELLED, got %s", orders[0].Status)
+	}
+
+	sip, err := c.PlaceMFSIP(kiteconnect.MFSIPParams{Tradingsymbol: "INF740K01DP8", Amount: 1000, Instalments: 12, Frequency: "monthly"})
+	if err != nil {
+		t.Fatalf("PlaceMFSIP failed: %v", err)
+	}
+	if _, err := c.ModifyMFSIP(sip.SIPID, kiteconnect.MFSIPModifyParams{Amount: 2000, Status: "paused"}); err != nil {
+		t.Fatalf("ModifyMFSIP failed: %v", err)
+	}
+	sips, _ := c.GetMFSIPs()
+	if sips[0].InstalmentAmount != 2000 || sips[0].Status != "PAUSED" {
+		t.Errorf("Expected a paused SIP of 2000, got %+v", sips[0])
+	}
+	if _, err := c.CancelMFSIP(sip.SIPID); err != nil {
+		t.Errorf("CancelMFSIP failed: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package kitefake
+
+import (
+	"math"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// Rates of the simplified margin and charges model.
+const (
+	intradayMarginRate    = 0.2  // MIS equity
+	spanRate              = 0.1  // derivatives SPAN
+	exposureRate          = 0.05 // derivatives exposure
+	brokerageRate         = 0.0003
+	maxBrokerage          = 20.0
+	deliverySTTRate       = 0.001
+	intradaySTTRate       = 0.00025
+	exchangeTurnoverRate  = 0.0000297
+	sebiTurnoverRate      = 0.000001
+	deliveryStampDutyRate = 0.00015
+	intradayStampDutyRate = 0.00003
+	gstRate               = 0.18
+)
+
+func isDerivative(exchange string) bool {
+	switch exchange {
+	case "NFO", "BFO", "CDS", "BCD", "MCX":
+		return true
+	}
+	return false
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// charges estimates the statutory charges and brokerage of a trade, using
+// zero brokerage for equity delivery like Zerodha.
+func charges(exchange, product, transactionType string, value float64) kiteconnect.Charges {
+	delivery := product == kiteconnect.ProductCNC
+	buy := transactionType == kiteconnect.TransactionTypeBuy
+
+	var c kiteconnect.Charges
+	if !delivery {
+		c.Brokerage = math.Min(maxBrokerage, value*brokerageRate)
+	}
+	c.TransactionTaxType = "stt"
+	switch {
+	case delivery:
+		c.TransactionTax = value * deliverySTTRate
+	case !buy:
+		c.TransactionTax = value * intradaySTTRate
+	}
+	if exchange == "MCX" {
+		c.TransactionTaxType = "ctt"
+	}
+	c.ExchangeTurnoverCharge = value * exchangeTurnoverRate
+	c.SEBITurnoverCharge = value * sebiTurnoverRate
+	if buy {
+		if delivery {
+			c.StampDuty = value * deliveryStampDutyRate
+		} else {
+			c.StampDuty = value * intradayStampDutyRate
+		}
+	}
+	c.GST.IGST = (c.Brokerage + c.ExchangeTurnoverCharge + c.SEBITurnoverCharge) * gstRate
+
+	c.Brokerage = round2(c.Brokerage)
+	c.TransactionTax = round2(c.TransactionTax)
+	c.ExchangeTurnoverCharge = round2(c.ExchangeTurnoverCharge)
+	c.SEBITurnoverCharge = round2(c.SEBITurnoverCharge)
+	c.StampDuty = round2(c.StampDuty)
+	c.GST.IGST = round2(c.GST.IGST)
+	c.GST.Total = c.GST.IGST
+	c.Total = round2(c.Brokerage + c.TransactionTax + c.ExchangeTurnoverCharge + c.SEBITurnoverCharge + c.StampDuty + c.GST.Total)
+	return c
+}
+
+// orderMargin is the margin blocked by an order: SPAN and exposure for
+// derivatives, the full value for delivery and a fifth of it intraday.
+func (b *Broker) orderMargin(p kiteconnect.OrderMarginParam) (kiteconnect.OrderMargins, error) {
+	m, ok := b.markets[p.Exchange+":"+p.Tradingsymbol]
+	if !ok {
+		return kiteconnect.OrderMargins{}, inputError("Invalid `tradingsymbol`.")
+	}
+	if p.Quantity <= 0 {
+		return kiteconnect.OrderMargins{}, inputError("Quantity should be greater than zero.")
+	}
+
+	price := p.Price
+	if price == 0 {
+		price = m.LastPrice
+	}
+	value := price * p.Quantity
+
+	margin := kiteconnect.OrderMargins{
+		Type:          "equity",
+		TradingSymbol: p.Tradingsymbol,
+		Exchange:      p.Exchange,
+	}
+	switch {
+	case isDerivative(p.Exchange):
+		margin.SPAN = round2(value * spanRate)
+		margin.Exposure = round2(value * exposureRate)
+	case p.Product == kiteconnect.ProductMIS:
+		margin.VAR = round2(value * intradayMarginRate)
+	default:
+		margin.VAR = round2(value)
+	}
+	if p.Exchange == "MCX" {
+		margin.Type = "commodity"
+	}
+	margin.Charges = charges(p.Exchange, p.Product, p.TransactionType, value)
+	margin.Total = round2(margin.SPAN + margin.Exposure + margin.VAR)
+	if margin.Total > 0 {
+		margin.Leverage = round2(value / margin.Total)
+	}
+	return margin, nil
+}
+
+func (c *Client) GetOrderMargins(params kiteconnect.GetMarginParams) ([]kiteconnect.OrderMargins, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	margins := make([]kiteconnect.OrderMargins, 0, len(params.OrderParams))
+	for _, p := range params.OrderParams {
+		margin, err := c.broker.orderMargin(p)
+		if err != nil {
+			return nil, err
+		}
+		margins = append(margins, margin)
+	}
+	return margins, nil
+}
+
+// GetBasketMargins adds up the margins of the orders. The model gives no
+// hedge benefit, so the final margin is the initial one.
+func (c *Client) GetBasketMargins(params kiteconnect.GetBasketParams) (kiteconnect.BasketMargins, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.BasketMargins{}, err
+	}
+	defer c.unlock()
+
+	basket := kiteconnect.BasketMargins{Orders: []kiteconnect.OrderMargins{}}
+	for _, p := range params.OrderParams {
+		margin, err := c.broker.orderMargin(p)
+		if err != nil {
+			return kiteconnect.BasketMargins{}, err
+		}
+		basket.Orders = append(basket.Orders, margin)
+		basket.Initial.SPAN += margin.SPAN
+		basket.Initial.Exposure += margin.Exposure
+		basket.Initial.VAR += margin.VAR
+		basket.Initial.Total += margin.Total
+	}
+	basket.Initial.Type = "equity"
+	basket.Final = basket.Initial
+	return basket, nil
+}
+
+func (c *Client) GetOrderCharges(params kiteconnect.GetChargesParams) ([]kiteconnect.OrderCharges, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	out := make([]kiteconnect.OrderCharges, 0, len(params.OrderParams))
+	for _, p := range params.OrderParams {
+		if p.Quantity <= 0 || p.AveragePrice <= 0 {
+			return nil, inputError("Quantity and average price should be greater than zero.")
+		}
+		out = append(out, kiteconnect.OrderCharges{
+			Exchange:        p.Exchange,
+			Tradingsymbol:   p.Tradingsymbol,
+			TransactionType: p.TransactionType,
+			Variety:         p.Variety,
+			Product:         p.Product,
+			OrderType:       p.OrderType,
+			Quantity:        p.Quantity,
+			Price:           p.AveragePrice,
+			Charges:         charges(p.Exchange, p.Product, p.TransactionType, p.Quantity*p.AveragePrice),
+		})
+	}
+	return out, nil
+}
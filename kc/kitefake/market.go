@@ -0,0 +1,112 @@
+package kitefake
+
+import (
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+func (m *market) ohlc() models.OHLC {
+	return models.OHLC{Open: m.open, High: m.high, Low: m.low, Close: m.Close}
+}
+
+// GetQuote returns the quotes of the listed instruments among the given
+// ones, which are EXCHANGE:TRADINGSYMBOL. Like Kite, unknown instruments
+// are left out rather than failing the call.
+func (c *Client) GetQuote(instruments ...string) (kiteconnect.Quote, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	now := modelTime(b.now())
+	quotes := kiteconnect.Quote{}
+	for _, instrument := range instruments {
+		m, ok := b.markets[instrument]
+		if !ok {
+			continue
+		}
+		q := quotes[instrument]
+		q.InstrumentToken = m.InstrumentToken
+		q.Timestamp = now
+		q.LastPrice = m.LastPrice
+		q.LastTradeTime = now
+		q.AveragePrice = m.averagePrice()
+		q.Volume = m.Volume
+		q.OHLC = m.ohlc()
+		q.NetChange = m.LastPrice - m.Close
+		q.LowerCircuitLimit = m.LowerCircuitLimit
+		q.UpperCircuitLimit = m.UpperCircuitLimit
+		quotes[instrument] = q
+	}
+	return quotes, nil
+}
+
+func (c *Client) GetLTP(instruments ...string) (kiteconnect.QuoteLTP, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	quotes := kiteconnect.QuoteLTP{}
+	for _, instrument := range instruments {
+		if m, ok := c.broker.markets[instrument]; ok {
+			q := quotes[instrument]
+			q.InstrumentToken = m.InstrumentToken
+			q.LastPrice = m.LastPrice
+			quotes[instrument] = q
+		}
+	}
+	return quotes, nil
+}
+
+func (c *Client) GetOHLC(instruments ...string) (kiteconnect.QuoteOHLC, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	quotes := kiteconnect.QuoteOHLC{}
+	for _, instrument := range instruments {
+		if m, ok := c.broker.markets[instrument]; ok {
+			q := quotes[instrument]
+			q.InstrumentToken = m.InstrumentToken
+			q.LastPrice = m.LastPrice
+			q.OHLC = m.ohlc()
+			quotes[instrument] = q
+		}
+	}
+	return quotes, nil
+}
+
+// GetHistoricalData returns the candles set for the instrument and interval
+// that fall between fromDate and toDate, both inclusive.
+func (c *Client) GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]kiteconnect.HistoricalData, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	m, ok := b.markets[b.tokenID[instrumentToken]]
+	if !ok {
+		return nil, inputError("invalid token")
+	}
+	if toDate.Before(fromDate) {
+		return nil, inputError("invalid from date and to date")
+	}
+
+	candles := []kiteconnect.HistoricalData{}
+	for _, candle := range m.candles[interval] {
+		if candle.Date.Before(fromDate) || candle.Date.After(toDate) {
+			continue
+		}
+		if !OI {
+			candle.OI = 0
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
@@ -0,0 +1,274 @@
+package kitefake
+
+import (
+	"fmt"
+	"strings"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+const (
+	mfStatusOpen      = "OPEN"
+	mfStatusCancelled = "CANCELLED"
+	sipActive         = "ACTIVE"
+	sipPaused         = "PAUSED"
+	sipCancelled      = "CANCELLED"
+)
+
+// AddMFInstrument lists a mutual fund scheme.
+func (b *Broker) AddMFInstrument(scheme kiteconnect.MFInstrument) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mfInstruments = append(b.mfInstruments, scheme)
+}
+
+// AddMFHolding adds a mutual fund holding. Its ISIN, if given, is listed
+// among the allotted ISINs.
+func (b *Broker) AddMFHolding(holding kiteconnect.MFHolding, isin string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mfHoldings = append(b.mfHoldings, holding)
+	if isin != "" {
+		b.allottedISINs = append(b.allottedISINs, isin)
+	}
+}
+
+func (b *Broker) mfScheme(tradingsymbol string) (kiteconnect.MFInstrument, bool) {
+	for _, scheme := range b.mfInstruments {
+		if scheme.Tradingsymbol == tradingsymbol {
+			return scheme, true
+		}
+	}
+	return kiteconnect.MFInstrument{}, false
+}
+
+func (c *Client) GetMFInstruments() (kiteconnect.MFInstruments, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+	return append(kiteconnect.MFInstruments{}, c.broker.mfInstruments...), nil
+}
+
+func (c *Client) GetMFHoldings() (kiteconnect.MFHoldings, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+	return append(kiteconnect.MFHoldings{}, c.broker.mfHoldings...), nil
+}
+
+func (c *Client) GetMFAllottedISINs() (kiteconnect.MFAllottedISINs, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+	return append(kiteconnect.MFAllottedISINs{}, c.broker.allottedISINs...), nil
+}
+
+func (c *Client) GetMFOrders() (kiteconnect.MFOrders, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	orders := make(kiteconnect.MFOrders, 0, len(b.mfOrderIDs))
+	for _, id := range b.mfOrderIDs {
+		orders = append(orders, *b.mfOrders[id])
+	}
+	return orders, nil
+}
+
+// PlaceMFOrder accepts a purchase by amount or a redemption by quantity.
+// Orders stay open, as they would until the fund's NAV is out.
+func (c *Client) PlaceMFOrder(params kiteconnect.MFOrderParams) (kiteconnect.MFOrderResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.MFOrderResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	scheme, ok := b.mfScheme(params.Tradingsymbol)
+	if !ok {
+		return kiteconnect.MFOrderResponse{}, inputError("Invalid `tradingsymbol`.")
+	}
+	switch params.TransactionType {
+	case kiteconnect.TransactionTypeBuy:
+		if params.Amount <= 0 {
+			return kiteconnect.MFOrderResponse{}, inputError("Amount should be greater than zero.")
+		}
+		if params.Amount < scheme.MinimumPurchaseAmount {
+			return kiteconnect.MFOrderResponse{}, inputError("Amount should be at least %v.", scheme.MinimumPurchaseAmount)
+		}
+	case kiteconnect.TransactionTypeSell:
+		if params.Quantity <= 0 && params.Amount <= 0 {
+			return kiteconnect.MFOrderResponse{}, inputError("Quantity or amount should be greater than zero.")
+		}
+	default:
+		return kiteconnect.MFOrderResponse{}, inputError("Invalid `transaction_type`.")
+	}
+
+	now := modelTime(b.now())
+	id := fmt.Sprintf("mf-%d", b.nextID())
+	b.mfOrders[id] = &kiteconnect.MFOrder{
+		OrderID:         id,
+		Tradingsymbol:   params.Tradingsymbol,
+		Status:          mfStatusOpen,
+		Fund:            scheme.Name,
+		OrderTimestamp:  now,
+		TransactionType: params.TransactionType,
+		Variety:         "regular",
+		PurchaseType:    "FRESH",
+		Quantity:        params.Quantity,
+		Amount:          params.Amount,
+		LastPrice:       scheme.LastPrice,
+		PlacedBy:        b.profile.UserID,
+		Tag:             params.Tag,
+	}
+	b.mfOrderIDs = append(b.mfOrderIDs, id)
+	return kiteconnect.MFOrderResponse{OrderID: id}, nil
+}
+
+func (c *Client) CancelMFOrder(orderID string) (kiteconnect.MFOrderResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.MFOrderResponse{}, err
+	}
+	defer c.unlock()
+
+	o, ok := c.broker.mfOrders[orderID]
+	if !ok {
+		return kiteconnect.MFOrderResponse{}, orderError("Couldn't find that `order_id`.")
+	}
+	if o.Status != mfStatusOpen {
+		return kiteconnect.MFOrderResponse{}, orderError("Order cannot be cancelled as it is %s.", o.Status)
+	}
+	o.Status = mfStatusCancelled
+	return kiteconnect.MFOrderResponse{OrderID: orderID}, nil
+}
+
+func (c *Client) GetMFSIPs() (kiteconnect.MFSIPs, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	sips := make(kiteconnect.MFSIPs, 0, len(b.sipIDs))
+	for _, id := range b.sipIDs {
+		sips = append(sips, *b.sips[id])
+	}
+	return sips, nil
+}
+
+func validSIPFrequency(frequency string) bool {
+	switch frequency {
+	case "weekly", "monthly", "quarterly":
+		return true
+	}
+	return false
+}
+
+func (c *Client) PlaceMFSIP(params kiteconnect.MFSIPParams) (kiteconnect.MFSIPResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.MFSIPResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	scheme, ok := b.mfScheme(params.Tradingsymbol)
+	if !ok {
+		return kiteconnect.MFSIPResponse{}, inputError("Invalid `tradingsymbol`.")
+	}
+	if params.Amount <= 0 || params.Instalments == 0 {
+		return kiteconnect.MFSIPResponse{}, inputError("Amount and instalments should be greater than zero.")
+	}
+	if !validSIPFrequency(params.Frequency) {
+		return kiteconnect.MFSIPResponse{}, inputError("Invalid `frequency`.")
+	}
+
+	id := fmt.Sprintf("sip-%d", b.nextID())
+	b.sips[id] = &kiteconnect.MFSIP{
+		ID:                 id,
+		Tradingsymbol:      params.Tradingsymbol,
+		FundName:           scheme.Name,
+		DividendType:       scheme.DividendType,
+		TransactionType:    kiteconnect.TransactionTypeBuy,
+		Status:             sipActive,
+		SipType:            "sip",
+		Created:            modelTime(b.now()),
+		Frequency:          params.Frequency,
+		InstalmentAmount:   params.Amount,
+		Instalments:        params.Instalments,
+		PendingInstalments: params.Instalments,
+		InstalmentDay:      params.InstalmentDay,
+		Tag:                params.Tag,
+	}
+	b.sipIDs = append(b.sipIDs, id)
+
+	var orderID *string
+	if params.InitialAmount > 0 {
+		initial := fmt.Sprintf("mf-%d", b.nextID())
+		orderID = &initial
+	}
+	return kiteconnect.MFSIPResponse{OrderID: orderID, SIPID: id}, nil
+}
+
+func (c *Client) ModifyMFSIP(sipID string, params kiteconnect.MFSIPModifyParams) (kiteconnect.MFSIPResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.MFSIPResponse{}, err
+	}
+	defer c.unlock()
+
+	sip, ok := c.broker.sips[sipID]
+	if !ok {
+		return kiteconnect.MFSIPResponse{}, inputError("Invalid `sip_id`.")
+	}
+	if sip.Status == sipCancelled {
+		return kiteconnect.MFSIPResponse{}, inputError("SIP is cancelled and cannot be modified.")
+	}
+	if params.Frequency != "" && !validSIPFrequency(params.Frequency) {
+		return kiteconnect.MFSIPResponse{}, inputError("Invalid `frequency`.")
+	}
+
+	if params.Amount > 0 {
+		sip.InstalmentAmount = params.Amount
+	}
+	if params.Frequency != "" {
+		sip.Frequency = params.Frequency
+	}
+	if params.InstalmentDay > 0 {
+		sip.InstalmentDay = params.InstalmentDay
+	}
+	if params.Instalments != 0 {
+		sip.Instalments = params.Instalments
+		sip.PendingInstalments = params.Instalments - sip.CompletedInstalments
+	}
+	switch strings.ToUpper(params.Status) {
+	case "":
+	case sipActive:
+		sip.Status = sipActive
+	case sipPaused:
+		sip.Status = sipPaused
+	default:
+		return kiteconnect.MFSIPResponse{}, inputError("Invalid `status`.")
+	}
+	return kiteconnect.MFSIPResponse{SIPID: sipID}, nil
+}
+
+func (c *Client) CancelMFSIP(sipID string) (kiteconnect.MFSIPResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.MFSIPResponse{}, err
+	}
+	defer c.unlock()
+
+	sip, ok := c.broker.sips[sipID]
+	if !ok {
+		return kiteconnect.MFSIPResponse{}, inputError("Invalid `sip_id`.")
+	}
+	if sip.Status == sipCancelled {
+		return kiteconnect.MFSIPResponse{}, inputError("SIP is already cancelled.")
+	}
+	sip.Status = sipCancelled
+	return kiteconnect.MFSIPResponse{SIPID: sipID}, nil
+}
@@ -0,0 +1,528 @@
+package kitefake
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// Order statuses that are not final.
+const (
+	statusOpen           = "OPEN"
+	statusTriggerPending = "TRIGGER PENDING"
+	statusAMO            = "AMO REQ RECEIVED"
+)
+
+const tickSize = 0.05
+
+func roundTick(price float64) float64 {
+	return math.Round(price/tickSize) * tickSize
+}
+
+func modelTime(t time.Time) models.Time {
+	return models.Time{Time: t}
+}
+
+func (o *order) working() bool {
+	switch o.current.Status {
+	case statusOpen, statusTriggerPending, statusAMO:
+		return true
+	}
+	return false
+}
+
+// record appends the current state of the order to its history.
+func (o *order) record(status, message string, now time.Time) {
+	o.current.Status = status
+	o.current.StatusMessage = message
+	o.current.ExchangeUpdateTimestamp = modelTime(now)
+	o.history = append(o.history, o.current)
+}
+
+func validateOrder(m *market, params kiteconnect.OrderParams) error {
+	if params.TransactionType != kiteconnect.TransactionTypeBuy && params.TransactionType != kiteconnect.TransactionTypeSell {
+		return inputError("Invalid `transaction_type`.")
+	}
+	if params.Quantity <= 0 {
+		return inputError("Quantity should be greater than zero.")
+	}
+	if params.Product == "" {
+		return inputError("Invalid `product`.")
+	}
+	switch params.OrderType {
+	case kiteconnect.OrderTypeMarket:
+	case kiteconnect.OrderTypeLimit:
+		if params.Price <= 0 {
+			return inputError("Price should be greater than zero for LIMIT orders.")
+		}
+	case kiteconnect.OrderTypeSL:
+		if params.Price <= 0 || params.TriggerPrice <= 0 {
+			return inputError("Price and trigger price are required for SL orders.")
+		}
+	case kiteconnect.OrderTypeSLM:
+		if params.TriggerPrice <= 0 {
+			return inputError("Trigger price is required for SL-M orders.")
+		}
+	default:
+		return inputError("Invalid `order_type`.")
+	}
+	if params.Price > 0 && (params.Price < m.LowerCircuitLimit || params.Price > m.UpperCircuitLimit) {
+		return orderError("Your order price is outside the circuit limits of %v - %v.", m.LowerCircuitLimit, m.UpperCircuitLimit)
+	}
+	return nil
+}
+
+// GetOrders returns the day's orders in the order they were placed.
+func (c *Client) GetOrders() (kiteconnect.Orders, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	orders := make(kiteconnect.Orders, 0, len(b.orderIDs))
+	for _, id := range b.orderIDs {
+		orders = append(orders, b.orders[id].current)
+	}
+	return orders, nil
+}
+
+func (c *Client) GetOrderHistory(orderID string) ([]kiteconnect.Order, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	o, ok := c.broker.orders[orderID]
+	if !ok {
+		return nil, orderError("Couldn't find that `order_id`.")
+	}
+	return append([]kiteconnect.Order(nil), o.history...), nil
+}
+
+func (c *Client) GetTrades() (kiteconnect.Trades, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+	return append(kiteconnect.Trades{}, c.broker.trades...), nil
+}
+
+func (c *Client) GetOrderTrades(orderID string) ([]kiteconnect.Trade, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	if _, ok := c.broker.orders[orderID]; !ok {
+		return nil, orderError("Couldn't find that `order_id`.")
+	}
+	trades := []kiteconnect.Trade{}
+	for _, t := range c.broker.trades {
+		if t.OrderID == orderID {
+			trades = append(trades, t)
+		}
+	}
+	return trades, nil
+}
+
+// PlaceOrder adds an order to the book. Marketable orders fill in full at
+// the last price straight away; AMOs wait in the book.
+func (c *Client) PlaceOrder(variety string, params kiteconnect.OrderParams) (kiteconnect.OrderResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.OrderResponse{}, err
+	}
+	defer c.unlock()
+
+	id, err := c.broker.placeOrder(variety, params)
+	if err != nil {
+		return kiteconnect.OrderResponse{}, err
+	}
+	return kiteconnect.OrderResponse{OrderID: id}, nil
+}
+
+// placeOrder adds an order to the book and returns its ID.
+func (b *Broker) placeOrder(variety string, params kiteconnect.OrderParams) (string, error) {
+	m, ok := b.markets[params.Exchange+":"+params.Tradingsymbol]
+	if !ok {
+		return "", inputError("Invalid `tradingsymbol`.")
+	}
+	if err := validateOrder(m, params); err != nil {
+		return "", err
+	}
+
+	now := b.now()
+	id := fmt.Sprintf("%d", 240000000000000+b.nextID())
+	o := &order{current: kiteconnect.Order{
+		PlacedBy:          b.profile.UserID,
+		OrderID:           id,
+		ExchangeOrderID:   fmt.Sprintf("1%015d", b.seq),
+		OrderTimestamp:    modelTime(now),
+		ExchangeTimestamp: modelTime(now),
+		Variety:           variety,
+		Exchange:          params.Exchange,
+		TradingSymbol:     params.Tradingsymbol,
+		InstrumentToken:   uint32(m.InstrumentToken),
+		OrderType:         params.OrderType,
+		TransactionType:   params.TransactionType,
+		Validity:          params.Validity,
+		ValidityTTL:       params.ValidityTTL,
+		Product:           params.Product,
+		Quantity:          float64(params.Quantity),
+		DisclosedQuantity: float64(params.DisclosedQuantity),
+		Price:             params.Price,
+		TriggerPrice:      params.TriggerPrice,
+		PendingQuantity:   float64(params.Quantity),
+		Tag:               params.Tag,
+	}}
+	if o.current.Validity == "" {
+		o.current.Validity = kiteconnect.ValidityDay
+	}
+	if params.Tag != "" {
+		o.current.Tags = []string{params.Tag}
+	}
+	b.orders[id] = o
+	b.orderIDs = append(b.orderIDs, id)
+
+	o.record("PUT ORDER REQ RECEIVED", "", now)
+	switch {
+	case variety == kiteconnect.VarietyAMO:
+		o.record(statusAMO, "", now)
+	case params.OrderType == kiteconnect.OrderTypeSL || params.OrderType == kiteconnect.OrderTypeSLM:
+		o.record(statusTriggerPending, "", now)
+	default:
+		o.record(statusOpen, "", now)
+	}
+	b.execute(o, m)
+	return id, nil
+}
+
+func (c *Client) ModifyOrder(variety string, orderID string, params kiteconnect.OrderParams) (kiteconnect.OrderResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.OrderResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	o, ok := b.orders[orderID]
+	if !ok {
+		return kiteconnect.OrderResponse{}, orderError("Couldn't find that `order_id`.")
+	}
+	if !o.working() {
+		return kiteconnect.OrderResponse{}, orderError("Order cannot be modified as it is %s.", o.current.Status)
+	}
+
+	next := kiteconnect.OrderParams{
+		Exchange:        o.current.Exchange,
+		Tradingsymbol:   o.current.TradingSymbol,
+		Product:         o.current.Product,
+		TransactionType: o.current.TransactionType,
+		OrderType:       o.current.OrderType,
+		Quantity:        int(o.current.Quantity),
+		Price:           o.current.Price,
+		TriggerPrice:    o.current.TriggerPrice,
+	}
+	if params.OrderType != "" {
+		next.OrderType = params.OrderType
+	}
+	if params.Quantity > 0 {
+		next.Quantity = params.Quantity
+	}
+	if params.Price > 0 || next.OrderType == kiteconnect.OrderTypeMarket || next.OrderType == kiteconnect.OrderTypeSLM {
+		next.Price = params.Price
+	}
+	if params.TriggerPrice > 0 {
+		next.TriggerPrice = params.TriggerPrice
+	}
+	m := b.markets[o.current.Exchange+":"+o.current.TradingSymbol]
+	if err := validateOrder(m, next); err != nil {
+		return kiteconnect.OrderResponse{}, err
+	}
+
+	o.current.OrderType = next.OrderType
+	o.current.Quantity = float64(next.Quantity)
+	o.current.PendingQuantity = float64(next.Quantity) - o.current.FilledQuantity
+	o.current.Price = next.Price
+	o.current.TriggerPrice = next.TriggerPrice
+	if params.Validity != "" {
+		o.current.Validity = params.Validity
+	}
+	if params.DisclosedQuantity > 0 {
+		o.current.DisclosedQuantity = float64(params.DisclosedQuantity)
+	}
+	o.current.Modified = true
+
+	now := b.now()
+	o.record("MODIFY VALIDATION PENDING", "", now)
+	status := statusOpen
+	if o.current.OrderType == kiteconnect.OrderTypeSL || o.current.OrderType == kiteconnect.OrderTypeSLM {
+		status = statusTriggerPending
+	}
+	if o.current.Variety == kiteconnect.VarietyAMO {
+		status = statusAMO
+	}
+	o.record(status, "", now)
+	b.execute(o, m)
+	return kiteconnect.OrderResponse{OrderID: orderID}, nil
+}
+
+func (c *Client) CancelOrder(variety string, orderID string, parentOrderID *string) (kiteconnect.OrderResponse, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.OrderResponse{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	o, ok := b.orders[orderID]
+	if !ok {
+		return kiteconnect.OrderResponse{}, orderError("Couldn't find that `order_id`.")
+	}
+	if !o.working() {
+		return kiteconnect.OrderResponse{}, orderError("Order cannot be cancelled as it is %s.", o.current.Status)
+	}
+
+	o.current.CancelledQuantity = o.current.PendingQuantity
+	o.current.PendingQuantity = 0
+	o.record(kiteconnect.OrderStatusCancelled, "", b.now())
+	return kiteconnect.OrderResponse{OrderID: orderID}, nil
+}
+
+// match executes the working orders and active GTTs on an instrument
+// against its last price.
+func (b *Broker) match(instrument string) {
+	m := b.markets[instrument]
+	for _, id := range b.orderIDs {
+		o := b.orders[id]
+		if o.current.Exchange+":"+o.current.TradingSymbol == instrument {
+			b.execute(o, m)
+		}
+	}
+	for _, id := range b.gttIDs {
+		if gtt := b.gtts[id]; gtt.Condition.Exchange+":"+gtt.Condition.Tradingsymbol == instrument {
+			b.fireGTT(gtt, m.LastPrice)
+		}
+	}
+}
+
+// execute triggers a stop order whose trigger price was reached and fills
+// a marketable order in full at the last price.
+func (b *Broker) execute(o *order, m *market) {
+	if o.current.Status == statusAMO {
+		return
+	}
+	buy := o.current.TransactionType == kiteconnect.TransactionTypeBuy
+	ltp := m.LastPrice
+
+	if o.current.Status == statusTriggerPending {
+		if (buy && ltp < o.current.TriggerPrice) || (!buy && ltp > o.current.TriggerPrice) {
+			return
+		}
+		o.record(statusOpen, "", b.now())
+	}
+	if o.current.Status != statusOpen {
+		return
+	}
+
+	switch o.current.OrderType {
+	case kiteconnect.OrderTypeLimit, kiteconnect.OrderTypeSL:
+		if (buy && ltp > o.current.Price) || (!buy && ltp < o.current.Price) {
+			return
+		}
+	}
+	b.fill(o, m, ltp)
+}
+
+// fill executes the pending quantity of an order at price.
+func (b *Broker) fill(o *order, m *market, price float64) {
+	now := b.now()
+	qty := o.current.PendingQuantity
+
+	o.current.AveragePrice = (o.current.AveragePrice*o.current.FilledQuantity + price*qty) / (o.current.FilledQuantity + qty)
+	o.current.FilledQuantity += qty
+	o.current.PendingQuantity = 0
+	o.current.ExchangeTimestamp = modelTime(now)
+	o.record(kiteconnect.OrderStatusComplete, "", now)
+
+	b.trades = append(b.trades, kiteconnect.Trade{
+		AveragePrice:      price,
+		Quantity:          qty,
+		TradeID:           fmt.Sprintf("%d", 50000000+b.nextID()),
+		Product:           o.current.Product,
+		FillTimestamp:     modelTime(now),
+		ExchangeTimestamp: modelTime(now),
+		ExchangeOrderID:   o.current.ExchangeOrderID,
+		OrderID:           o.current.OrderID,
+		TransactionType:   o.current.TransactionType,
+		TradingSymbol:     o.current.TradingSymbol,
+		Exchange:          o.current.Exchange,
+		InstrumentToken:   o.current.InstrumentToken,
+	})
+	m.trade(price, int(qty))
+
+	p := b.position(o.current.Exchange, o.current.TradingSymbol, o.current.Product)
+	p.InstrumentToken = uint32(m.InstrumentToken)
+	value := price * qty
+	if o.current.TransactionType == kiteconnect.TransactionTypeBuy {
+		p.BuyQuantity += int(qty)
+		p.BuyValue += value
+		p.DayBuyQuantity += int(qty)
+		p.DayBuyValue += value
+	} else {
+		p.SellQuantity += int(qty)
+		p.SellValue += value
+		p.DaySellQuantity += int(qty)
+		p.DaySellValue += value
+	}
+	p.Quantity = p.BuyQuantity - p.SellQuantity
+}
+
+// position returns the position in a product, creating an empty one.
+func (b *Broker) position(exchange, tradingsymbol, product string) *kiteconnect.Position {
+	key := exchange + ":" + tradingsymbol + ":" + product
+	p, ok := b.positions[key]
+	if !ok {
+		p = &kiteconnect.Position{Exchange: exchange, Tradingsymbol: tradingsymbol, Product: product, Multiplier: 1}
+		b.positions[key] = p
+		b.posKeys = append(b.posKeys, key)
+	}
+	return p
+}
+
+// mark values a position at the last price, the way Kite reports it.
+func (b *Broker) mark(p kiteconnect.Position) kiteconnect.Position {
+	if m, ok := b.markets[p.Exchange+":"+p.Tradingsymbol]; ok {
+		p.LastPrice = m.LastPrice
+		p.ClosePrice = m.Close
+		if p.InstrumentToken == 0 {
+			p.InstrumentToken = uint32(m.InstrumentToken)
+		}
+	}
+	if p.BuyQuantity > 0 {
+		p.BuyPrice = p.BuyValue / float64(p.BuyQuantity)
+	}
+	if p.SellQuantity > 0 {
+		p.SellPrice = p.SellValue / float64(p.SellQuantity)
+	}
+	if p.DayBuyQuantity > 0 {
+		p.DayBuyPrice = p.DayBuyValue / float64(p.DayBuyQuantity)
+	}
+	if p.DaySellQuantity > 0 {
+		p.DaySellPrice = p.DaySellValue / float64(p.DaySellQuantity)
+	}
+	switch {
+	case p.Quantity > 0:
+		p.AveragePrice = p.BuyPrice
+	case p.Quantity < 0:
+		p.AveragePrice = p.SellPrice
+	default:
+		p.AveragePrice = 0
+	}
+
+	p.Value = p.SellValue - p.BuyValue
+	p.PnL = p.Value + float64(p.Quantity)*p.LastPrice*p.Multiplier
+	p.M2M = p.PnL
+	p.Unrealised = float64(p.Quantity) * (p.LastPrice - p.AveragePrice) * p.Multiplier
+	p.Realised = p.PnL - p.Unrealised
+	return p
+}
+
+// GetPositions returns the net positions, and the day's positions of the
+// ones traded today.
+func (c *Client) GetPositions() (kiteconnect.Positions, error) {
+	if err := c.lock(); err != nil {
+		return kiteconnect.Positions{}, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	positions := kiteconnect.Positions{Net: []kiteconnect.Position{}, Day: []kiteconnect.Position{}}
+	for _, key := range b.posKeys {
+		p := b.mark(*b.positions[key])
+		positions.Net = append(positions.Net, p)
+		if p.DayBuyQuantity > 0 || p.DaySellQuantity > 0 {
+			day := p
+			day.BuyQuantity, day.BuyValue = p.DayBuyQuantity, p.DayBuyValue
+			day.SellQuantity, day.SellValue = p.DaySellQuantity, p.DaySellValue
+			day.Quantity = p.DayBuyQuantity - p.DaySellQuantity
+			day.OvernightQuantity = 0
+			positions.Day = append(positions.Day, b.mark(day))
+		}
+	}
+	return positions, nil
+}
+
+// ConvertPosition moves quantity units of a position to another product at
+// the position's average price.
+func (c *Client) ConvertPosition(params kiteconnect.ConvertPositionParams) (bool, error) {
+	if err := c.lock(); err != nil {
+		return false, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	from, ok := b.positions[params.Exchange+":"+params.TradingSymbol+":"+params.OldProduct]
+	if !ok || from.Quantity == 0 {
+		return false, inputError("No open position in %s %s:%s.", params.OldProduct, params.Exchange, params.TradingSymbol)
+	}
+	qty := params.Quantity
+	if qty <= 0 || qty > abs(from.Quantity) {
+		return false, inputError("Quantity to convert must be between 1 and %d.", abs(from.Quantity))
+	}
+	if (from.Quantity > 0) != (params.TransactionType != kiteconnect.TransactionTypeSell) && params.TransactionType != "" {
+		return false, inputError("Transaction type does not match the position.")
+	}
+
+	price := b.mark(*from).AveragePrice
+	value := price * float64(qty)
+	to := b.position(params.Exchange, params.TradingSymbol, params.NewProduct)
+	to.InstrumentToken = from.InstrumentToken
+	if from.Quantity > 0 {
+		from.BuyQuantity -= qty
+		from.BuyValue -= value
+		to.BuyQuantity += qty
+		to.BuyValue += value
+	} else {
+		from.SellQuantity -= qty
+		from.SellValue -= value
+		to.SellQuantity += qty
+		to.SellValue += value
+	}
+	from.Quantity = from.BuyQuantity - from.SellQuantity
+	to.Quantity = to.BuyQuantity - to.SellQuantity
+	return true, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// GetHoldings returns the holdings valued at the last prices.
+func (c *Client) GetHoldings() (kiteconnect.Holdings, error) {
+	if err := c.lock(); err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	b := c.broker
+	holdings := make(kiteconnect.Holdings, 0, len(b.holdings))
+	for _, h := range b.holdings {
+		if m, ok := b.markets[h.Exchange+":"+h.Tradingsymbol]; ok {
+			h.InstrumentToken = uint32(m.InstrumentToken)
+			h.LastPrice = m.LastPrice
+			h.ClosePrice = m.Close
+			h.DayChange = m.LastPrice - m.Close
+			if m.Close > 0 {
+				h.DayChangePercentage = h.DayChange / m.Close * 100
+			}
+		}
+		h.PnL = float64(h.Quantity) * (h.LastPrice - h.AveragePrice)
+		holdings = append(holdings, h)
+	}
+	return holdings, nil
+}
@@ -12,19 +12,36 @@ import (
 
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"github.com/zerodha/kite-mcp-server/app/metrics"
+	"github.com/zerodha/kite-mcp-server/kc/algo"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/history"
 	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/oco"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+	"github.com/zerodha/kite-mcp-server/kc/scheduler"
 	"github.com/zerodha/kite-mcp-server/kc/templates"
+	"github.com/zerodha/kite-mcp-server/kc/tradebook"
 )
 
 // Config holds configuration for creating a new kc Manager
 type Config struct {
-	APIKey             string                    // required
-	APISecret          string                    // required
-	Logger             *slog.Logger              // required
-	InstrumentsConfig  *instruments.UpdateConfig // optional - defaults to instruments.DefaultUpdateConfig()
-	InstrumentsManager *instruments.Manager      // optional - if provided, skips creating new instruments manager
-	SessionSigner      *SessionSigner            // optional - if nil, creates new session signer
-	Metrics            *metrics.Manager          // optional - for tracking user metrics
+	APIKey               string                    // required
+	APISecret            string                    // required
+	Logger               *slog.Logger              // required
+	InstrumentsConfig    *instruments.UpdateConfig // optional - defaults to instruments.DefaultUpdateConfig()
+	InstrumentsManager   *instruments.Manager      // optional - if provided, skips creating new instruments manager
+	MFInstruments        *instruments.MFCatalog    // optional - if provided, skips creating new MF instruments catalog
+	SessionSigner        *SessionSigner            // optional - if nil, creates new session signer
+	Metrics              *metrics.Manager          // optional - for tracking user metrics
+	TradebookDir         string                    // optional - if empty, imported tradebooks are kept in memory only
+	ScheduledOrdersFile  string                    // optional - if empty, scheduled orders are kept in memory only
+	MarketHolidaysFile   string                    // optional - if empty, the market calendar has no holidays
+	InstrumentsSnapshot  string                    // optional - if set, instruments are snapshotted there and loaded from it when Kite is unreachable
+	InstrumentsSource    instruments.Source        // optional - defaults to the Kite JSON dump
+	CorporateActionsFile string                    // optional - if empty, historical candles are not adjusted for splits and bonuses
+	KiteClientFactory    KiteClientFactory         // optional - defaults to a Kite Connect API client
+	HTTPClient           *http.Client              // optional - HTTP client of the default Kite Connect API client
 }
 
 // New creates a new kc Manager with the given configuration
@@ -40,14 +57,39 @@ func New(cfg Config) (*Manager, error) {
 		return nil, errors.New("logger is required") // TODO: maybe create a default logger later on
 	}
 
+	marketCalendar := calendar.New()
+	if cfg.MarketHolidaysFile != "" {
+		var err error
+		marketCalendar, err = calendar.LoadFile(cfg.MarketHolidaysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load market calendar: %w", err)
+		}
+	}
+
 	// Create or use provided instruments manager
 	var instrumentsManager *instruments.Manager
 	if cfg.InstrumentsManager != nil {
 		instrumentsManager = cfg.InstrumentsManager
 	} else {
+		updateConfig := instruments.DefaultUpdateConfig()
+		if cfg.InstrumentsConfig != nil {
+			*updateConfig = *cfg.InstrumentsConfig
+		}
+		if cfg.InstrumentsSnapshot != "" {
+			updateConfig.SnapshotPath = cfg.InstrumentsSnapshot
+		}
+		if cfg.InstrumentsSource.URL != "" || cfg.InstrumentsSource.Format != "" {
+			updateConfig.Source = cfg.InstrumentsSource
+		}
+		if updateConfig.TradingDay == nil {
+			updateConfig.TradingDay = func(day time.Time) bool {
+				return marketCalendar.IsTradingDay("NSE", day)
+			}
+		}
+
 		var err error
 		instrumentsManager, err = instruments.New(instruments.Config{
-			UpdateConfig: cfg.InstrumentsConfig,
+			UpdateConfig: updateConfig,
 			Logger:       cfg.Logger,
 		})
 		if err != nil {
@@ -56,10 +98,15 @@ func New(cfg Config) (*Manager, error) {
 	}
 
 	m := &Manager{
-		apiKey:    cfg.APIKey,
-		apiSecret: cfg.APISecret,
-		Logger:    cfg.Logger,
-		metrics:   cfg.Metrics,
+		apiKey:     cfg.APIKey,
+		apiSecret:  cfg.APISecret,
+		Logger:     cfg.Logger,
+		metrics:    cfg.Metrics,
+		Calendar:   marketCalendar,
+		kiteClient: cfg.KiteClientFactory,
+	}
+	if m.kiteClient == nil {
+		m.kiteClient = kiteconnectClients(cfg.HTTPClient)
 	}
 
 	if err := m.initializeTemplates(); err != nil {
@@ -71,7 +118,56 @@ func New(cfg Config) (*Manager, error) {
 	}
 
 	m.Instruments = instrumentsManager
+	m.History = history.New(history.Config{
+		Instruments: instrumentsManager,
+	})
+	if cfg.CorporateActionsFile != "" {
+		if err := m.History.LoadFile(cfg.CorporateActionsFile); err != nil {
+			return nil, fmt.Errorf("failed to load corporate actions: %w", err)
+		}
+	}
+	m.MFInstruments = cfg.MFInstruments
+	if m.MFInstruments == nil {
+		mfConfig := instruments.DefaultMFUpdateConfig()
+		if cfg.InstrumentsConfig != nil {
+			mfConfig.EnableScheduler = cfg.InstrumentsConfig.EnableScheduler
+		}
+		m.MFInstruments = instruments.NewMFCatalog(instruments.MFConfig{
+			UpdateConfig: mfConfig,
+			Logger:       cfg.Logger,
+		})
+	}
+	m.Tradebook = tradebook.NewStore(cfg.TradebookDir, cfg.Logger)
+	m.Tradebook.SetISINResolver(func(exchange, symbol string) string {
+		inst, err := m.Instruments.GetByTradingsymbol(exchange, symbol)
+		if err != nil {
+			return ""
+		}
+		return inst.ISIN
+	})
+	m.OrderGroups = orders.NewGroupStore()
+	m.OrderUpdates = orderwatch.NewHub()
+	m.OCO = oco.NewEngine(cfg.Logger, oco.DefaultPollInterval)
+	m.OrderUpdates.OnUpdate(func(u orderwatch.Update) {
+		go m.OCO.HandleUpdate(u)
+	})
+	orderScheduler, err := scheduler.New(scheduler.Config{
+		Path:     cfg.ScheduledOrdersFile,
+		Logger:   cfg.Logger,
+		Brokers:  m.schedulerBroker,
+		Calendar: m.Calendar,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order scheduler: %w", err)
+	}
+	m.Scheduler = orderScheduler
+	m.Algos = algo.New(algo.Config{
+		Logger:   cfg.Logger,
+		Calendar: m.Calendar,
+	})
 	m.initializeSessionManager()
+	m.Scheduler.Start(scheduler.DefaultTickInterval)
+	m.Algos.Start(algo.DefaultTickInterval)
 
 	return m, nil
 }
@@ -79,7 +175,7 @@ func New(cfg Config) (*Manager, error) {
 // KiteConnect wraps the Kite Connect client
 type KiteConnect struct {
 	// Add fields here
-	Client *kiteconnect.Client // TODO: this can be made private ?
+	Client KiteClient // TODO: this can be made private ?
 }
 
 // NewKiteConnect creates a new KiteConnect instance
@@ -107,18 +203,29 @@ var (
 )
 
 type KiteSessionData struct {
-	Kite *KiteConnect
+	Kite   *KiteConnect
+	UserID string // Kite user ID, set once the login completes
 }
 
 type Manager struct {
-	apiKey    string
-	apiSecret string
-	Logger    *slog.Logger
-	metrics   *metrics.Manager
+	apiKey     string
+	apiSecret  string
+	Logger     *slog.Logger
+	metrics    *metrics.Manager
+	kiteClient KiteClientFactory
 
 	templates map[string]*template.Template
 
+	Calendar       *calendar.Calendar
 	Instruments    *instruments.Manager
+	MFInstruments  *instruments.MFCatalog
+	History        *history.Service
+	Tradebook      *tradebook.Store
+	OrderGroups    *orders.GroupStore
+	OrderUpdates   *orderwatch.Hub
+	OCO            *oco.Engine
+	Scheduler      *scheduler.Scheduler
+	Algos          *algo.Engine
 	sessionManager *SessionRegistry
 	sessionSigner  *SessionSigner
 }
@@ -192,7 +299,7 @@ func (m *Manager) validateSessionID(sessionID string) error {
 func (m *Manager) createKiteSessionData(sessionID string) *KiteSessionData {
 	m.Logger.Info("Creating new Kite session data for MCP session ID", "session_id", sessionID)
 	return &KiteSessionData{
-		Kite: NewKiteConnect(m.apiKey),
+		Kite: &KiteConnect{Client: m.kiteClient(m.apiKey)},
 	}
 }
 
@@ -402,6 +509,7 @@ func (m *Manager) CompleteSession(mcpSessionID, kiteRequestToken string) error {
 
 	m.Logger.Info("Setting Kite access token for MCP session", "session_id", mcpSessionID)
 	kiteData.Kite.Client.SetAccessToken(userSess.AccessToken)
+	kiteData.UserID = userSess.UserID
 
 	// Compliance log for successful login
 	m.Logger.Info("COMPLIANCE: User login completed successfully",
@@ -474,6 +582,12 @@ func (m *Manager) Shutdown() {
 
 	// Shutdown instruments manager (stops scheduler)
 	m.Instruments.Shutdown()
+	m.MFInstruments.Shutdown()
+
+	// Stop polling OCO orders, evaluating scheduled orders and stepping algos
+	m.OCO.Shutdown()
+	m.Scheduler.Shutdown()
+	m.Algos.Shutdown()
 
 	m.Logger.Info("Kite manager shutdown complete")
 }
@@ -498,6 +612,34 @@ func (m *Manager) SessionManager() *SessionRegistry {
 	return m.sessionManager
 }
 
+// schedulerBroker returns the Kite client of any active session of userID,
+// for placing the user's scheduled orders.
+func (m *Manager) schedulerBroker(userID string) (scheduler.Broker, error) {
+	for _, session := range m.sessionManager.ListActiveSessions() {
+		if data, ok := session.Data.(*KiteSessionData); ok && data.UserID == userID && data.Kite != nil {
+			return historyBroker{KiteBroker: scheduler.KiteBroker{Client: data.Kite.Client}, history: m.History}, nil
+		}
+	}
+	return nil, fmt.Errorf("no active Kite session for %s; log in again for scheduled orders to be placed", userID)
+}
+
+// historyBroker fetches the candles of scheduled order triggers from the
+// history service, so indicators don't see splits and bonuses as crashes.
+type historyBroker struct {
+	scheduler.KiteBroker
+	history *history.Service
+}
+
+func (b historyBroker) Candles(instrumentToken uint32, interval string, from, to time.Time) ([]kiteconnect.HistoricalData, error) {
+	series, err := b.history.Candles(b.Client, history.Request{
+		InstrumentToken: instrumentToken,
+		Interval:        interval,
+		From:            from,
+		To:              to,
+	})
+	return series.Candles, err
+}
+
 // SessionSigner returns the session signer instance
 func (m *Manager) SessionSigner() *SessionSigner {
 	return m.sessionSigner
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
 )
 
 // newTestInstrumentsManager creates a fast test instruments manager without HTTP calls
@@ -283,6 +284,48 @@ func TestCompleteSession(t *testing.T) {
 	}
 }
 
+var _ KiteClient = (*kitefake.Client)(nil)
+
+func TestCompleteSessionWithKiteClientFactory(t *testing.T) {
+	broker := kitefake.New()
+	var client *kitefake.Client
+	manager, err := New(Config{
+		APIKey:             "test_key",
+		APISecret:          "test_secret",
+		InstrumentsManager: newTestInstrumentsManager(),
+		Logger:             testLogger(),
+		KiteClientFactory: func(apiKey string) KiteClient {
+			client = broker.Client(apiKey)
+			return client
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating manager, got: %v", err)
+	}
+	defer manager.Shutdown()
+
+	sessionID := manager.GenerateSession()
+	if err := manager.CompleteSession(sessionID, "request_token"); err != nil {
+		t.Fatalf("Expected no error completing session, got: %v", err)
+	}
+
+	data, err := manager.GetSession(sessionID)
+	if err != nil {
+		t.Fatalf("Expected no error getting session, got: %v", err)
+	}
+	if data.UserID != kitefake.UserID {
+		t.Errorf("Expected user ID %s, got %s", kitefake.UserID, data.UserID)
+	}
+	if _, err := manager.schedulerBroker(kitefake.UserID); err != nil {
+		t.Errorf("Expected a scheduler broker for the logged in user, got: %v", err)
+	}
+
+	manager.ClearSession(sessionID)
+	if _, err := client.GetUserProfile(); err == nil {
+		t.Error("Expected the access token to be invalidated when the session is cleared")
+	}
+}
+
 func TestGetActiveSessionCount(t *testing.T) {
 	manager, err := newTestManager("test_key", "test_secret")
 	if err != nil {
@@ -0,0 +1,626 @@
+// Package oco emulates one-cancels-other exits for intraday positions. An
+// entry order is placed first; once it fills, a target LIMIT order and a
+// stop-loss order are placed for the filled quantity, and whichever exit
+// fills first cancels the other. Progress is driven by order postbacks and
+// by polling the order book as a fallback.
+package oco
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+const (
+	// DefaultPollInterval is how often open OCO orders are checked against
+	// the order book in case postbacks are missing.
+	DefaultPollInterval = 5 * time.Second
+
+	// DefaultRetention is how long finished OCO orders remain inspectable.
+	DefaultRetention = 24 * time.Hour
+
+	// Tag marks the orders placed by the engine.
+	Tag = "OCO"
+)
+
+var (
+	ErrNotFound    = errors.New("OCO order not found")
+	ErrFinished    = errors.New("OCO order has already finished")
+	ErrInvalidSpec = errors.New("invalid OCO order")
+)
+
+// State is the lifecycle stage of an OCO order.
+type State string
+
+const (
+	StatePendingEntry State = "PENDING_ENTRY" // entry order placed, not yet filled
+	StateActive       State = "ACTIVE"        // target and stop orders working
+	StateTargetHit    State = "TARGET_HIT"    // target filled, stop cancelled
+	StateStoppedOut   State = "STOPPED_OUT"   // stop filled, target cancelled
+	StateCancelled    State = "CANCELLED"     // cancelled by the user or the entry was cancelled
+	StateFailed       State = "FAILED"        // an order was rejected or could not be placed
+)
+
+// Done reports whether the engine no longer manages orders in this state.
+func (s State) Done() bool {
+	switch s {
+	case StatePendingEntry, StateActive:
+		return false
+	}
+	return true
+}
+
+// Broker places and tracks the regular orders of one user.
+type Broker interface {
+	PlaceOrder(params kiteconnect.OrderParams) (string, error)
+	ModifyOrder(orderID string, params kiteconnect.OrderParams) error
+	CancelOrder(orderID string) error
+	OrderStatus(orderID string) (orderwatch.Update, error)
+}
+
+// Spec describes an OCO order. TransactionType is the side of the entry;
+// the exits take the opposite side. A zero StopPrice places the stop as
+// SL-M, otherwise as an SL order with that limit price.
+type Spec struct {
+	UserID           string  `json:"user_id,omitempty"`
+	Exchange         string  `json:"exchange"`
+	Tradingsymbol    string  `json:"tradingsymbol"`
+	TransactionType  string  `json:"transaction_type"`
+	Product          string  `json:"product"`
+	Quantity         int     `json:"quantity"`
+	EntryOrderType   string  `json:"entry_order_type"`
+	EntryPrice       float64 `json:"entry_price,omitempty"`
+	TargetPrice      float64 `json:"target_price"`
+	StopTriggerPrice float64 `json:"stop_trigger_price"`
+	StopPrice        float64 `json:"stop_price,omitempty"`
+}
+
+// ExitTransactionType returns the side of the target and stop orders.
+func (s Spec) ExitTransactionType() string {
+	if s.TransactionType == kiteconnect.TransactionTypeSell {
+		return kiteconnect.TransactionTypeBuy
+	}
+	return kiteconnect.TransactionTypeSell
+}
+
+// Validate checks that the target and stop sit on the correct sides of
+// each other and of a limit entry.
+func (s Spec) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidSpec, fmt.Sprintf(format, args...))
+	}
+
+	if s.Exchange == "" || s.Tradingsymbol == "" {
+		return invalid("exchange and tradingsymbol are required")
+	}
+	if s.Quantity <= 0 {
+		return invalid("quantity must be positive")
+	}
+	if s.TargetPrice <= 0 || s.StopTriggerPrice <= 0 {
+		return invalid("target price and stop trigger price must be positive")
+	}
+
+	buy := true
+	switch s.TransactionType {
+	case kiteconnect.TransactionTypeBuy:
+	case kiteconnect.TransactionTypeSell:
+		buy = false
+	default:
+		return invalid("transaction type must be BUY or SELL")
+	}
+
+	switch s.EntryOrderType {
+	case kiteconnect.OrderTypeMarket:
+	case kiteconnect.OrderTypeLimit:
+		if s.EntryPrice <= 0 {
+			return invalid("entry price is required for a LIMIT entry")
+		}
+	default:
+		return invalid("entry order type must be MARKET or LIMIT")
+	}
+
+	if buy {
+		if s.TargetPrice <= s.StopTriggerPrice {
+			return invalid("target price must be above the stop trigger price for a BUY entry")
+		}
+		if s.EntryPrice > 0 && (s.EntryPrice >= s.TargetPrice || s.EntryPrice <= s.StopTriggerPrice) {
+			return invalid("entry price must be between the stop trigger price and the target price")
+		}
+		if s.StopPrice > s.StopTriggerPrice {
+			return invalid("stop price of a SELL stop-loss cannot be above its trigger price")
+		}
+	} else {
+		if s.TargetPrice >= s.StopTriggerPrice {
+			return invalid("target price must be below the stop trigger price for a SELL entry")
+		}
+		if s.EntryPrice > 0 && (s.EntryPrice <= s.TargetPrice || s.EntryPrice >= s.StopTriggerPrice) {
+			return invalid("entry price must be between the target price and the stop trigger price")
+		}
+		if s.StopPrice > 0 && s.StopPrice < s.StopTriggerPrice {
+			return invalid("stop price of a BUY stop-loss cannot be below its trigger price")
+		}
+	}
+	return nil
+}
+
+// Leg is the last known state of one order of an OCO.
+type Leg struct {
+	OrderID        string  `json:"order_id,omitempty"`
+	Status         string  `json:"status,omitempty"`
+	StatusMessage  string  `json:"status_message,omitempty"`
+	Quantity       int     `json:"quantity,omitempty"`
+	FilledQuantity float64 `json:"filled_quantity"`
+	AveragePrice   float64 `json:"average_price,omitempty"`
+}
+
+func (l *Leg) apply(u orderwatch.Update) {
+	l.Status = u.Status
+	l.StatusMessage = u.StatusMessage
+	l.FilledQuantity = u.FilledQuantity
+	l.AveragePrice = u.AveragePrice
+}
+
+// open reports whether the leg has been placed and may still fill.
+func (l *Leg) open() bool {
+	return l.OrderID != "" && !orderwatch.IsTerminal(l.Status)
+}
+
+func (l *Leg) filled() int {
+	return int(l.FilledQuantity)
+}
+
+// Order is a snapshot of an OCO order.
+type Order struct {
+	ID        string    `json:"oco_id"`
+	Spec      Spec      `json:"spec"`
+	State     State     `json:"state"`
+	Entry     Leg       `json:"entry"`
+	Target    Leg       `json:"target"`
+	Stop      Leg       `json:"stop"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// tracked is an OCO order together with the broker of its owner. mu
+// serializes the state transitions of one order, which may be driven by a
+// postback and a poll at the same time.
+type tracked struct {
+	mu     sync.Mutex
+	order  Order
+	broker Broker
+}
+
+// Engine manages OCO orders. Locks are taken in the order mu, tracked.mu,
+// indexMu.
+type Engine struct {
+	mu     sync.Mutex
+	orders map[string]*tracked
+
+	indexMu sync.Mutex
+	byOrder map[string]*tracked // entry, target and stop order IDs
+
+	logger       *slog.Logger
+	pollInterval time.Duration
+	retention    time.Duration
+	now          func() time.Time
+
+	polling bool
+	done    chan struct{}
+	stop    sync.Once
+}
+
+// NewEngine creates an engine that polls open orders every pollInterval.
+// A non-positive interval uses DefaultPollInterval.
+func NewEngine(logger *slog.Logger, pollInterval time.Duration) *Engine {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	return &Engine{
+		orders:       make(map[string]*tracked),
+		byOrder:      make(map[string]*tracked),
+		logger:       logger,
+		pollInterval: pollInterval,
+		retention:    DefaultRetention,
+		now:          time.Now,
+		done:         make(chan struct{}),
+	}
+}
+
+// Place submits the entry order and starts managing the OCO. The entry
+// order ID doubles as the OCO ID.
+func (e *Engine) Place(broker Broker, spec Spec) (Order, error) {
+	if err := spec.Validate(); err != nil {
+		return Order{}, err
+	}
+
+	entry := kiteconnect.OrderParams{
+		Exchange:        spec.Exchange,
+		Tradingsymbol:   spec.Tradingsymbol,
+		TransactionType: spec.TransactionType,
+		Product:         spec.Product,
+		OrderType:       spec.EntryOrderType,
+		Quantity:        spec.Quantity,
+		Price:           spec.EntryPrice,
+		Validity:        kiteconnect.ValidityDay,
+		Tag:             Tag,
+	}
+	orderID, err := broker.PlaceOrder(entry)
+	if err != nil {
+		return Order{}, fmt.Errorf("failed to place entry order: %w", err)
+	}
+
+	now := e.now()
+	t := &tracked{
+		broker: broker,
+		order: Order{
+			ID:        orderID,
+			Spec:      spec,
+			State:     StatePendingEntry,
+			Entry:     Leg{OrderID: orderID, Quantity: spec.Quantity},
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+
+	e.mu.Lock()
+	e.pruneLocked(now)
+	e.orders[orderID] = t
+	e.index(orderID, t)
+	e.startPollingLocked()
+	e.mu.Unlock()
+
+	return t.order, nil
+}
+
+// Get returns an OCO order owned by userID.
+func (e *Engine) Get(id, userID string) (Order, error) {
+	t, err := e.lookup(id, userID)
+	if err != nil {
+		return Order{}, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.order, nil
+}
+
+// List returns the OCO orders of userID, newest first.
+func (e *Engine) List(userID string) []Order {
+	e.mu.Lock()
+	all := make([]*tracked, 0, len(e.orders))
+	for _, t := range e.orders {
+		all = append(all, t)
+	}
+	e.mu.Unlock()
+
+	list := make([]Order, 0, len(all))
+	for _, t := range all {
+		t.mu.Lock()
+		if t.order.Spec.UserID == userID {
+			list = append(list, t.order)
+		}
+		t.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Cancel cancels the working orders of an OCO. A cancelled entry that had
+// partially filled, or cancelled exits, leave the position open.
+func (e *Engine) Cancel(id, userID string) (Order, error) {
+	t, err := e.lookup(id, userID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o := &t.order
+	if o.State.Done() {
+		return *o, ErrFinished
+	}
+
+	var errs []error
+	for _, leg := range []*Leg{&o.Entry, &o.Target, &o.Stop} {
+		if !leg.open() {
+			continue
+		}
+		if err := t.broker.CancelOrder(leg.OrderID); err != nil {
+			errs = append(errs, fmt.Errorf("order %s: %w", leg.OrderID, err))
+			continue
+		}
+		leg.Status = orderwatch.StatusCancelled
+	}
+	if len(errs) > 0 {
+		return *o, fmt.Errorf("failed to cancel OCO order: %w", errors.Join(errs...))
+	}
+
+	e.finish(o, StateCancelled, "cancelled by user")
+	return *o, nil
+}
+
+// HandleUpdate applies an order update to the OCO it belongs to, if any.
+// It calls the broker and should not run on a postback delivery goroutine.
+func (e *Engine) HandleUpdate(u orderwatch.Update) {
+	e.indexMu.Lock()
+	t, ok := e.byOrder[u.OrderID]
+	e.indexMu.Unlock()
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e.apply(t, u)
+}
+
+// Refresh checks every open leg of every active OCO against the order book
+// and returns the number of OCO orders still active.
+func (e *Engine) Refresh() int {
+	e.mu.Lock()
+	active := make([]*tracked, 0, len(e.orders))
+	for _, t := range e.orders {
+		active = append(active, t)
+	}
+	e.mu.Unlock()
+
+	remaining := 0
+	for _, t := range active {
+		t.mu.Lock()
+		for _, leg := range []*Leg{&t.order.Entry, &t.order.Target, &t.order.Stop} {
+			if t.order.State.Done() || !leg.open() {
+				continue
+			}
+			u, err := t.broker.OrderStatus(leg.OrderID)
+			if err != nil {
+				e.logger.Warn("Failed to refresh OCO leg", "oco_id", t.order.ID, "order_id", leg.OrderID, "error", err)
+				continue
+			}
+			e.apply(t, u)
+		}
+		if !t.order.State.Done() {
+			remaining++
+		}
+		t.mu.Unlock()
+	}
+	return remaining
+}
+
+// Shutdown stops background polling.
+func (e *Engine) Shutdown() {
+	e.stop.Do(func() { close(e.done) })
+}
+
+func (e *Engine) lookup(id, userID string) (*tracked, error) {
+	e.mu.Lock()
+	t, ok := e.orders[id]
+	e.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	t.mu.Lock()
+	owner := t.order.Spec.UserID
+	t.mu.Unlock()
+	if owner != userID {
+		return nil, ErrNotFound
+	}
+	return t, nil
+}
+
+// apply moves an OCO through its states. t.mu must be held.
+func (e *Engine) apply(t *tracked, u orderwatch.Update) {
+	o := &t.order
+	if o.State.Done() {
+		return
+	}
+
+	var leg, sibling *Leg
+	var name string
+	switch u.OrderID {
+	case o.Entry.OrderID:
+		leg, name = &o.Entry, "entry"
+	case o.Target.OrderID:
+		leg, sibling, name = &o.Target, &o.Stop, "target"
+	case o.Stop.OrderID:
+		leg, sibling, name = &o.Stop, &o.Target, "stop"
+	default:
+		return
+	}
+	// Postbacks can arrive out of order.
+	if orderwatch.IsTerminal(leg.Status) {
+		return
+	}
+	prevFilled := leg.FilledQuantity
+	leg.apply(u)
+	o.UpdatedAt = e.now()
+
+	if leg == &o.Entry {
+		e.applyEntry(t)
+		return
+	}
+
+	switch leg.Status {
+	case orderwatch.StatusComplete:
+		if sibling.open() {
+			if err := t.broker.CancelOrder(sibling.OrderID); err != nil {
+				e.finish(o, StateFailed, fmt.Sprintf("%s filled but cancelling order %s failed: %v", name, sibling.OrderID, err))
+				return
+			}
+			sibling.Status = orderwatch.StatusCancelled
+		}
+		if leg == &o.Target {
+			e.finish(o, StateTargetHit, "")
+		} else {
+			e.finish(o, StateStoppedOut, "")
+		}
+	case orderwatch.StatusCancelled, orderwatch.StatusRejected:
+		e.finish(o, StateFailed, fmt.Sprintf("%s order %s %s: %s; order %s left open", name, leg.OrderID, leg.Status, leg.StatusMessage, sibling.OrderID))
+	default:
+		// A partial exit shrinks the other exit to the quantity still held.
+		if leg.FilledQuantity > prevFilled && sibling.open() {
+			remaining := o.Entry.filled() - int(leg.FilledQuantity)
+			if remaining > 0 {
+				if err := t.broker.ModifyOrder(sibling.OrderID, kiteconnect.OrderParams{Quantity: remaining}); err != nil {
+					o.Error = fmt.Sprintf("failed to reduce order %s to %d: %v", sibling.OrderID, remaining, err)
+					e.logger.Warn("Failed to resize OCO exit", "oco_id", o.ID, "order_id", sibling.OrderID, "error", err)
+				} else {
+					sibling.Quantity = remaining
+				}
+			}
+		}
+	}
+}
+
+// applyEntry places the exits once the entry has finished filling.
+func (e *Engine) applyEntry(t *tracked) {
+	o := &t.order
+	switch o.Entry.Status {
+	case orderwatch.StatusComplete:
+	case orderwatch.StatusCancelled, orderwatch.StatusRejected:
+		if o.Entry.filled() == 0 {
+			state := StateCancelled
+			if o.Entry.Status == orderwatch.StatusRejected {
+				state = StateFailed
+			}
+			e.finish(o, state, fmt.Sprintf("entry order %s: %s", o.Entry.Status, o.Entry.StatusMessage))
+			return
+		}
+		// Protect whatever part of the entry filled before it ended.
+	default:
+		return
+	}
+
+	qty := o.Entry.filled()
+	exitSide := o.Spec.ExitTransactionType()
+	base := kiteconnect.OrderParams{
+		Exchange:        o.Spec.Exchange,
+		Tradingsymbol:   o.Spec.Tradingsymbol,
+		TransactionType: exitSide,
+		Product:         o.Spec.Product,
+		Quantity:        qty,
+		Validity:        kiteconnect.ValidityDay,
+		Tag:             Tag,
+	}
+
+	target := base
+	target.OrderType = kiteconnect.OrderTypeLimit
+	target.Price = o.Spec.TargetPrice
+	targetID, err := t.broker.PlaceOrder(target)
+	if err != nil {
+		e.finish(o, StateFailed, fmt.Sprintf("failed to place target order, position left open: %v", err))
+		return
+	}
+	o.Target = Leg{OrderID: targetID, Quantity: qty}
+	e.index(targetID, t)
+
+	stop := base
+	stop.TriggerPrice = o.Spec.StopTriggerPrice
+	if o.Spec.StopPrice > 0 {
+		stop.OrderType = kiteconnect.OrderTypeSL
+		stop.Price = o.Spec.StopPrice
+	} else {
+		stop.OrderType = kiteconnect.OrderTypeSLM
+	}
+	stopID, err := t.broker.PlaceOrder(stop)
+	if err != nil {
+		e.finish(o, StateFailed, fmt.Sprintf("failed to place stop order, target order %s left open: %v", targetID, err))
+		return
+	}
+	o.Stop = Leg{OrderID: stopID, Quantity: qty}
+	e.index(stopID, t)
+
+	o.State = StateActive
+}
+
+func (e *Engine) finish(o *Order, state State, message string) {
+	o.State = state
+	o.Error = message
+	o.UpdatedAt = e.now()
+	if state == StateFailed {
+		e.logger.Warn("OCO order failed", "oco_id", o.ID, "error", message)
+	}
+}
+
+func (e *Engine) index(orderID string, t *tracked) {
+	e.indexMu.Lock()
+	defer e.indexMu.Unlock()
+	e.byOrder[orderID] = t
+}
+
+// startPollingLocked starts the poll loop unless it is running. e.mu must
+// be held.
+func (e *Engine) startPollingLocked() {
+	if e.polling {
+		return
+	}
+	e.polling = true
+	go e.pollLoop()
+}
+
+// pollLoop refreshes open OCO orders until none remain or the engine shuts
+// down.
+func (e *Engine) pollLoop() {
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.Refresh()
+
+			e.mu.Lock()
+			if !e.hasActiveLocked() {
+				e.polling = false
+				e.mu.Unlock()
+				return
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+// hasActiveLocked reports whether any OCO is still working. e.mu must be
+// held; the order state is read under its own lock.
+func (e *Engine) hasActiveLocked() bool {
+	for _, t := range e.orders {
+		t.mu.Lock()
+		done := t.order.State.Done()
+		t.mu.Unlock()
+		if !done {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneLocked forgets finished OCO orders older than the retention period.
+// e.mu must be held.
+func (e *Engine) pruneLocked(now time.Time) {
+	for id, t := range e.orders {
+		t.mu.Lock()
+		expired := t.order.State.Done() && now.Sub(t.order.UpdatedAt) > e.retention
+		legs := []string{t.order.Entry.OrderID, t.order.Target.OrderID, t.order.Stop.OrderID}
+		t.mu.Unlock()
+		if !expired {
+			continue
+		}
+		delete(e.orders, id)
+		e.indexMu.Lock()
+		for _, orderID := range legs {
+			delete(e.byOrder, orderID)
+		}
+		e.indexMu.Unlock()
+	}
+}
@@ -0,0 +1,357 @@
+package oco
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeBroker keeps an in-memory order book. Orders stay OPEN until the test
+// fills or rejects them.
+type fakeBroker struct {
+	mu        sync.Mutex
+	seq       int
+	orders    map[string]*orderwatch.Update
+	placed    []kiteconnect.OrderParams
+	cancelled []string
+	modified  map[string]int
+	placeErr  map[int]error // by 1-based placement number
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{orders: map[string]*orderwatch.Update{}, modified: map[string]int{}, placeErr: map[int]error{}}
+}
+
+func (b *fakeBroker) PlaceOrder(p kiteconnect.OrderParams) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.placeErr[len(b.placed)+1]; err != nil {
+		b.placed = append(b.placed, p)
+		return "", err
+	}
+	b.seq++
+	id := fmt.Sprintf("%d", b.seq)
+	b.placed = append(b.placed, p)
+	b.orders[id] = &orderwatch.Update{OrderID: id, Status: "OPEN", Quantity: float64(p.Quantity)}
+	return id, nil
+}
+
+func (b *fakeBroker) ModifyOrder(orderID string, p kiteconnect.OrderParams) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.modified[orderID] = p.Quantity
+	b.orders[orderID].Quantity = float64(p.Quantity)
+	return nil
+}
+
+func (b *fakeBroker) CancelOrder(orderID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[orderID]
+	if !ok {
+		return errors.New("no such order")
+	}
+	if orderwatch.IsTerminal(o.Status) {
+		return fmt.Errorf("order is %s", o.Status)
+	}
+	o.Status = "CANCELLED"
+	b.cancelled = append(b.cancelled, orderID)
+	return nil
+}
+
+func (b *fakeBroker) OrderStatus(orderID string) (orderwatch.Update, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.orders[orderID]
+	if !ok {
+		return orderwatch.Update{}, errors.New("no such order")
+	}
+	return *o, nil
+}
+
+// set changes an order in the book and returns it as a postback would.
+func (b *fakeBroker) set(orderID, status string, filled float64) orderwatch.Update {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o := b.orders[orderID]
+	o.Status = status
+	o.FilledQuantity = filled
+	return *o
+}
+
+func buySpec() Spec {
+	return Spec{
+		UserID:           "AB1234",
+		Exchange:         "NSE",
+		Tradingsymbol:    "INFY",
+		TransactionType:  "BUY",
+		Product:          "MIS",
+		Quantity:         10,
+		EntryOrderType:   "LIMIT",
+		EntryPrice:       1500,
+		TargetPrice:      1530,
+		StopTriggerPrice: 1485,
+	}
+}
+
+func newTestEngine() *Engine {
+	e := NewEngine(testLogger(), time.Hour)
+	t := time.Date(2024, 1, 2, 9, 20, 0, 0, time.UTC)
+	e.now = func() time.Time { return t }
+	return e
+}
+
+func TestSpecValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Spec)
+		ok     bool
+	}{
+		{"valid buy", func(s *Spec) {}, true},
+		{"valid sell", func(s *Spec) {
+			s.TransactionType = "SELL"
+			s.TargetPrice, s.StopTriggerPrice = 1470, 1515
+		}, true},
+		{"market entry", func(s *Spec) { s.EntryOrderType, s.EntryPrice = "MARKET", 0 }, true},
+		{"target below stop", func(s *Spec) { s.TargetPrice = 1480 }, false},
+		{"entry above target", func(s *Spec) { s.EntryPrice = 1540 }, false},
+		{"limit without price", func(s *Spec) { s.EntryPrice = 0 }, false},
+		{"zero quantity", func(s *Spec) { s.Quantity = 0 }, false},
+		{"sell stop limit above trigger", func(s *Spec) { s.StopPrice = 1490 }, false},
+		{"bad side", func(s *Spec) { s.TransactionType = "HOLD" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := buySpec()
+			tt.modify(&spec)
+			err := spec.Validate()
+			if tt.ok && err != nil {
+				t.Errorf("Expected valid spec, got %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSpec) {
+				t.Errorf("Expected ErrInvalidSpec, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTargetHitCancelsStop(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	order, err := e.Place(b, buySpec())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.State != StatePendingEntry || order.ID != "1" {
+		t.Fatalf("Expected PENDING_ENTRY with ID 1, got %s with %s", order.State, order.ID)
+	}
+
+	e.HandleUpdate(b.set("1", "COMPLETE", 10))
+	order, _ = e.Get("1", "AB1234")
+	if order.State != StateActive {
+		t.Fatalf("Expected ACTIVE after entry fill, got %s (%s)", order.State, order.Error)
+	}
+	if len(b.placed) != 3 {
+		t.Fatalf("Expected entry, target and stop orders, got %d", len(b.placed))
+	}
+	target, stop := b.placed[1], b.placed[2]
+	if target.TransactionType != "SELL" || target.OrderType != "LIMIT" || target.Price != 1530 || target.Quantity != 10 {
+		t.Errorf("Unexpected target order: %+v", target)
+	}
+	if stop.TransactionType != "SELL" || stop.OrderType != "SL-M" || stop.TriggerPrice != 1485 {
+		t.Errorf("Unexpected stop order: %+v", stop)
+	}
+
+	e.HandleUpdate(b.set(order.Target.OrderID, "COMPLETE", 10))
+	order, _ = e.Get("1", "AB1234")
+	if order.State != StateTargetHit {
+		t.Errorf("Expected TARGET_HIT, got %s", order.State)
+	}
+	if len(b.cancelled) != 1 || b.cancelled[0] != order.Stop.OrderID {
+		t.Errorf("Expected stop order to be cancelled, got %v", b.cancelled)
+	}
+}
+
+func TestStopOutByPolling(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	spec := buySpec()
+	spec.StopPrice = 1480
+	order, err := e.Place(b, spec)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	b.set("1", "COMPLETE", 10)
+	if active := e.Refresh(); active != 1 {
+		t.Errorf("Expected 1 active OCO, got %d", active)
+	}
+	order, _ = e.Get(order.ID, "AB1234")
+	if b.placed[2].OrderType != "SL" || b.placed[2].Price != 1480 {
+		t.Errorf("Expected SL stop at 1480, got %+v", b.placed[2])
+	}
+
+	b.set(order.Stop.OrderID, "COMPLETE", 10)
+	if active := e.Refresh(); active != 0 {
+		t.Errorf("Expected no active OCO, got %d", active)
+	}
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.State != StateStoppedOut {
+		t.Errorf("Expected STOPPED_OUT, got %s", order.State)
+	}
+	if len(b.cancelled) != 1 || b.cancelled[0] != order.Target.OrderID {
+		t.Errorf("Expected target order to be cancelled, got %v", b.cancelled)
+	}
+}
+
+func TestPartialExitResizesSibling(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	order, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set("1", "COMPLETE", 10))
+	order, _ = e.Get(order.ID, "AB1234")
+
+	e.HandleUpdate(b.set(order.Target.OrderID, "OPEN", 4))
+	if got := b.modified[order.Stop.OrderID]; got != 6 {
+		t.Errorf("Expected stop to be reduced to 6, got %d", got)
+	}
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.Stop.Quantity != 6 || order.State != StateActive {
+		t.Errorf("Expected active OCO with stop of 6, got %s with %d", order.State, order.Stop.Quantity)
+	}
+}
+
+func TestPartiallyFilledEntryCancelled(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	order, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set("1", "CANCELLED", 3))
+
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.State != StateActive {
+		t.Fatalf("Expected exits for the filled part, got %s", order.State)
+	}
+	if b.placed[1].Quantity != 3 || b.placed[2].Quantity != 3 {
+		t.Errorf("Expected exits of 3, got %d and %d", b.placed[1].Quantity, b.placed[2].Quantity)
+	}
+}
+
+func TestEntryRejected(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	order, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set("1", "REJECTED", 0))
+
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.State != StateFailed {
+		t.Errorf("Expected FAILED, got %s", order.State)
+	}
+	if len(b.placed) != 1 {
+		t.Errorf("Expected no exit orders, got %d orders", len(b.placed))
+	}
+}
+
+func TestStopPlacementFailure(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+	b.placeErr[3] = errors.New("margin exceeded")
+
+	order, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set("1", "COMPLETE", 10))
+
+	order, _ = e.Get(order.ID, "AB1234")
+	if order.State != StateFailed || order.Error == "" {
+		t.Errorf("Expected FAILED with an error, got %s %q", order.State, order.Error)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	order, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set("1", "COMPLETE", 10))
+
+	if _, err := e.Cancel(order.ID, "XY0000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another user, got %v", err)
+	}
+
+	order, err := e.Cancel(order.ID, "AB1234")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.State != StateCancelled {
+		t.Errorf("Expected CANCELLED, got %s", order.State)
+	}
+	if len(b.cancelled) != 2 {
+		t.Errorf("Expected target and stop to be cancelled, got %v", b.cancelled)
+	}
+
+	if _, err := e.Cancel(order.ID, "AB1234"); !errors.Is(err, ErrFinished) {
+		t.Errorf("Expected ErrFinished, got %v", err)
+	}
+}
+
+func TestLateUpdatesIgnored(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	order, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set("1", "COMPLETE", 10))
+	// A stale OPEN postback for the filled entry must not place exits again.
+	e.HandleUpdate(orderwatch.Update{OrderID: "1", Status: "OPEN"})
+	e.HandleUpdate(b.set("1", "COMPLETE", 10))
+
+	if len(b.placed) != 3 {
+		t.Errorf("Expected 3 orders, got %d", len(b.placed))
+	}
+	// Updates for unrelated orders are ignored.
+	e.HandleUpdate(orderwatch.Update{OrderID: "999", Status: "COMPLETE"})
+	if got, _ := e.Get(order.ID, "AB1234"); got.State != StateActive {
+		t.Errorf("Expected ACTIVE, got %s", got.State)
+	}
+}
+
+func TestListAndPrune(t *testing.T) {
+	e := newTestEngine()
+	b := newFakeBroker()
+
+	first, _ := e.Place(b, buySpec())
+	e.HandleUpdate(b.set(first.ID, "REJECTED", 0))
+
+	other := buySpec()
+	other.UserID = "XY0000"
+	if _, err := e.Place(b, other); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if list := e.List("AB1234"); len(list) != 1 || list[0].ID != first.ID {
+		t.Errorf("Expected only the first OCO, got %+v", list)
+	}
+
+	now := e.now().Add(DefaultRetention + time.Hour)
+	e.now = func() time.Time { return now }
+	if _, err := e.Place(b, buySpec()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := e.Get(first.ID, "AB1234"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected finished OCO to be pruned, got %v", err)
+	}
+}
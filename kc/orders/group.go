@@ -0,0 +1,94 @@
+package orders
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultGroupRetention is how long sliced order groups are remembered.
+// Orders do not carry over to the next trading day, so a day is enough.
+const DefaultGroupRetention = 24 * time.Hour
+
+// Group is a set of child orders placed by slicing one oversized order.
+type Group struct {
+	ID              string    `json:"group_id"`
+	Variety         string    `json:"variety"`
+	Exchange        string    `json:"exchange"`
+	Tradingsymbol   string    `json:"tradingsymbol"`
+	TransactionType string    `json:"transaction_type"`
+	Quantity        int       `json:"quantity"`
+	OrderIDs        []string  `json:"order_ids"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GroupStore remembers sliced order groups so that a modify or cancel of
+// any child order can be applied to its siblings.
+type GroupStore struct {
+	mu        sync.RWMutex
+	groups    map[string]*Group
+	byOrderID map[string]string
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewGroupStore creates an empty group store.
+func NewGroupStore() *GroupStore {
+	return &GroupStore{
+		groups:    make(map[string]*Group),
+		byOrderID: make(map[string]string),
+		retention: DefaultGroupRetention,
+		now:       time.Now,
+	}
+}
+
+// Add records a group. The group ID is the first child order ID, so any
+// child ID the user already has identifies the group. Groups past the
+// retention period are dropped.
+func (s *GroupStore) Add(g Group) *Group {
+	if len(g.OrderIDs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.pruneLocked(now)
+
+	g.ID = g.OrderIDs[0]
+	g.OrderIDs = append([]string(nil), g.OrderIDs...)
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = now
+	}
+	s.groups[g.ID] = &g
+	for _, id := range g.OrderIDs {
+		s.byOrderID[id] = g.ID
+	}
+	return &g
+}
+
+// Lookup returns a copy of the group containing orderID.
+func (s *GroupStore) Lookup(orderID string) (Group, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byOrderID[orderID]
+	if !ok {
+		return Group{}, false
+	}
+	g := *s.groups[id]
+	g.OrderIDs = append([]string(nil), g.OrderIDs...)
+	return g, true
+}
+
+func (s *GroupStore) pruneLocked(now time.Time) {
+	for id, g := range s.groups {
+		if now.Sub(g.CreatedAt) <= s.retention {
+			continue
+		}
+		for _, orderID := range g.OrderIDs {
+			delete(s.byOrderID, orderID)
+		}
+		delete(s.groups, id)
+	}
+}
@@ -0,0 +1,38 @@
+package orders
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupStore(t *testing.T) {
+	store := NewGroupStore()
+	now := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	g := store.Add(Group{Variety: "regular", Tradingsymbol: "NIFTY25JANFUT", OrderIDs: []string{"101", "102", "103"}})
+	if g.ID != "101" {
+		t.Errorf("Expected group ID of the first child, got %s", g.ID)
+	}
+	if store.Add(Group{}) != nil {
+		t.Error("Expected empty group to be ignored")
+	}
+
+	found, ok := store.Lookup("103")
+	if !ok || found.ID != "101" || len(found.OrderIDs) != 3 {
+		t.Errorf("Expected lookup by child ID to find the group, got %+v", found)
+	}
+	found.OrderIDs[0] = "changed"
+	if again, _ := store.Lookup("101"); again.OrderIDs[0] != "101" {
+		t.Error("Expected lookup to return a copy")
+	}
+	if _, ok := store.Lookup("999"); ok {
+		t.Error("Expected unknown order not to be found")
+	}
+
+	now = now.Add(DefaultGroupRetention + time.Minute)
+	store.Add(Group{OrderIDs: []string{"201", "202"}})
+	if _, ok := store.Lookup("102"); ok {
+		t.Error("Expected expired group to be pruned")
+	}
+}
@@ -0,0 +1,72 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// MinTriggerDistance is how far, as a fraction of the last price, Kite
+// requires a GTT trigger to be from the last price.
+const MinTriggerDistance = 0.0025
+
+// ErrTriggerTooClose is returned for GTT triggers within MinTriggerDistance
+// of the last price.
+var ErrTriggerTooClose = errors.New("trigger is too close to the last price")
+
+// GTTLeg is one trigger of a GTT and the limit order it places.
+type GTTLeg struct {
+	Name       string // prefix of the leg's fields in errors, e.g. "upper_"
+	Trigger    float64
+	LimitPrice float64
+	Quantity   float64
+}
+
+func (l GTTLeg) validate(lastPrice float64) error {
+	if l.Trigger <= 0 {
+		return fmt.Errorf("%strigger_value must be greater than 0", l.Name)
+	}
+	if l.LimitPrice <= 0 {
+		return fmt.Errorf("%slimit_price must be greater than 0", l.Name)
+	}
+	if l.Quantity <= 0 {
+		return fmt.Errorf("%squantity must be greater than 0", l.Name)
+	}
+	if math.Abs(l.Trigger-lastPrice) < lastPrice*MinTriggerDistance {
+		return fmt.Errorf("%w: %strigger_value %v must be at least %.2f%% away from %v", ErrTriggerTooClose, l.Name, l.Trigger, MinTriggerDistance*100, lastPrice)
+	}
+	return nil
+}
+
+// ValidateSingleGTT checks a single-leg GTT against the last price.
+func ValidateSingleGTT(lastPrice float64, leg GTTLeg) error {
+	if lastPrice <= 0 {
+		return errors.New("last_price must be greater than 0")
+	}
+	return leg.validate(lastPrice)
+}
+
+// ValidateTwoLegGTT checks a two-leg (OCO) GTT against the last price. The
+// upper trigger must be above the last price and the lower one below it,
+// so that only one of them can fire first.
+func ValidateTwoLegGTT(lastPrice float64, upper, lower GTTLeg) error {
+	if lastPrice <= 0 {
+		return errors.New("last_price must be greater than 0")
+	}
+	if err := upper.validate(lastPrice); err != nil {
+		return err
+	}
+	if err := lower.validate(lastPrice); err != nil {
+		return err
+	}
+	if upper.Trigger <= lower.Trigger {
+		return fmt.Errorf("%strigger_value %v must be above %strigger_value %v", upper.Name, upper.Trigger, lower.Name, lower.Trigger)
+	}
+	if upper.Trigger <= lastPrice {
+		return fmt.Errorf("%strigger_value %v must be above the last price %v", upper.Name, upper.Trigger, lastPrice)
+	}
+	if lower.Trigger >= lastPrice {
+		return fmt.Errorf("%strigger_value %v must be below the last price %v", lower.Name, lower.Trigger, lastPrice)
+	}
+	return nil
+}
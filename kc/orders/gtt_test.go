@@ -0,0 +1,63 @@
+package orders
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateSingleGTT(t *testing.T) {
+	leg := GTTLeg{Trigger: 1450, LimitPrice: 1448, Quantity: 10}
+	if err := ValidateSingleGTT(1500, leg); err != nil {
+		t.Errorf("Expected valid GTT, got %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		lastPrice float64
+		leg       GTTLeg
+		err       string
+	}{
+		{"no last price", 0, leg, "last_price"},
+		{"no trigger", 1500, GTTLeg{LimitPrice: 1448, Quantity: 10}, "trigger_value must be greater than 0"},
+		{"no limit price", 1500, GTTLeg{Trigger: 1450, Quantity: 10}, "limit_price must be greater than 0"},
+		{"no quantity", 1500, GTTLeg{Trigger: 1450, LimitPrice: 1448}, "quantity must be greater than 0"},
+		{"too close", 1500, GTTLeg{Trigger: 1497, LimitPrice: 1497, Quantity: 10}, "at least 0.25% away"},
+	}
+	for _, tt := range tests {
+		err := ValidateSingleGTT(tt.lastPrice, tt.leg)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+
+	if err := ValidateSingleGTT(1500, GTTLeg{Trigger: 1500, LimitPrice: 1500, Quantity: 1}); !errors.Is(err, ErrTriggerTooClose) {
+		t.Errorf("Expected ErrTriggerTooClose, got %v", err)
+	}
+}
+
+func TestValidateTwoLegGTT(t *testing.T) {
+	upper := GTTLeg{Name: "upper_", Trigger: 1600, LimitPrice: 1598, Quantity: 10}
+	lower := GTTLeg{Name: "lower_", Trigger: 1400, LimitPrice: 1398, Quantity: 10}
+	if err := ValidateTwoLegGTT(1500, upper, lower); err != nil {
+		t.Errorf("Expected valid GTT, got %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		upper, lower GTTLeg
+		err          string
+	}{
+		{"inverted", lower, upper, "must be above"},
+		{"both above", upper, GTTLeg{Name: "lower_", Trigger: 1550, LimitPrice: 1550, Quantity: 10}, "lower_trigger_value 1550 must be below the last price"},
+		{"both below", GTTLeg{Name: "upper_", Trigger: 1450, LimitPrice: 1450, Quantity: 10}, lower, "upper_trigger_value 1450 must be above the last price"},
+		{"upper too close", GTTLeg{Name: "upper_", Trigger: 1501, LimitPrice: 1501, Quantity: 10}, lower, "upper_trigger_value 1501 must be at least"},
+		{"lower missing", upper, GTTLeg{Name: "lower_"}, "lower_trigger_value must be greater than 0"},
+	}
+	for _, tt := range tests {
+		err := ValidateTwoLegGTT(1500, tt.upper, tt.lower)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrOutsideCircuit is returned for prices beyond the instrument's circuit limits.
+var ErrOutsideCircuit = errors.New("price is outside the circuit limits")
+
+// Spec holds the instrument rules orders are normalized against. Zero
+// values disable the corresponding check.
+type Spec struct {
+	TickSize          float64
+	LotSize           int
+	Multiplier        int
+	LowerCircuitLimit float64
+	UpperCircuitLimit float64
+}
+
+// Mode controls what happens to values that break the instrument rules.
+type Mode string
+
+const (
+	// ModeAdjust corrects values to the nearest valid one and records the change.
+	ModeAdjust Mode = "adjust"
+	// ModeStrict rejects values that would need correcting.
+	ModeStrict Mode = "strict"
+)
+
+// ParseMode validates a mode name, defaulting to ModeAdjust.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeAdjust:
+		return ModeAdjust, nil
+	case ModeStrict:
+		return ModeStrict, nil
+	}
+	return "", fmt.Errorf("invalid normalization mode %q: must be adjust or strict", s)
+}
+
+// Rounding selects the direction prices are moved to reach a tick.
+type Rounding int
+
+const (
+	RoundNearest Rounding = iota
+	RoundDown
+	RoundUp
+)
+
+// LimitRounding returns the rounding that never makes a limit price worse
+// for the order's side: buys round down, sells round up.
+func LimitRounding(transactionType string) Rounding {
+	if transactionType == "SELL" {
+		return RoundUp
+	}
+	return RoundDown
+}
+
+// Adjustment records a value changed during normalization.
+type Adjustment struct {
+	Field    string  `json:"field"`
+	Original float64 `json:"original"`
+	Adjusted float64 `json:"adjusted"`
+	Reason   string  `json:"reason"`
+}
+
+// Normalizer validates or corrects order values against a Spec and keeps a
+// record of every adjustment made.
+type Normalizer struct {
+	Spec        Spec
+	Mode        Mode
+	Adjustments []Adjustment
+}
+
+// NewNormalizer creates a normalizer for an instrument.
+func NewNormalizer(spec Spec, mode Mode) *Normalizer {
+	return &Normalizer{Spec: spec, Mode: mode}
+}
+
+// RoundToTick rounds price to a multiple of tick in the given direction.
+func RoundToTick(price, tick float64, rounding Rounding) float64 {
+	if tick <= 0 {
+		return price
+	}
+
+	// The epsilon absorbs float error in prices that already sit on a tick.
+	steps := price / tick
+	switch rounding {
+	case RoundDown:
+		steps = math.Floor(steps + 1e-9)
+	case RoundUp:
+		steps = math.Ceil(steps - 1e-9)
+	default:
+		steps = math.Round(steps)
+	}
+	return math.Round(steps*tick*1e8) / 1e8
+}
+
+// Price aligns a price to the tick size and checks it against the circuit
+// limits. A zero price (market orders) passes through unchanged.
+func (n *Normalizer) Price(field string, price float64, rounding Rounding) (float64, error) {
+	if price == 0 {
+		return 0, nil
+	}
+	if price < 0 {
+		return 0, fmt.Errorf("%s must be greater than 0", field)
+	}
+
+	adjusted := RoundToTick(price, n.Spec.TickSize, rounding)
+	if adjusted <= 0 {
+		adjusted = n.Spec.TickSize
+	}
+	if adjusted != price {
+		if n.Mode == ModeStrict {
+			return 0, fmt.Errorf("%s %v is not a multiple of the tick size %v", field, price, n.Spec.TickSize)
+		}
+		n.record(field, price, adjusted, fmt.Sprintf("rounded to tick size %v", n.Spec.TickSize))
+	}
+
+	lower, upper := n.Spec.LowerCircuitLimit, n.Spec.UpperCircuitLimit
+	if lower > 0 && upper > 0 && (adjusted < lower || adjusted > upper) {
+		return 0, fmt.Errorf("%w: %s %v not within %v-%v", ErrOutsideCircuit, field, adjusted, lower, upper)
+	}
+	return adjusted, nil
+}
+
+// Quantity aligns a quantity to whole lots. Adjustments round down so that
+// normalization never increases exposure; a quantity below one lot is an
+// error in either mode.
+func (n *Normalizer) Quantity(field string, qty int) (int, error) {
+	if qty <= 0 {
+		return 0, fmt.Errorf("%s must be greater than 0", field)
+	}
+
+	lot := n.Spec.LotSize
+	if lot <= 1 || qty%lot == 0 {
+		return qty, nil
+	}
+	if n.Mode == ModeStrict || qty < lot {
+		return 0, fmt.Errorf("%w: %s %d is not a multiple of %d", ErrNotLotMultiple, field, qty, lot)
+	}
+
+	adjusted := qty - qty%lot
+	n.record(field, float64(qty), float64(adjusted), fmt.Sprintf("rounded down to a multiple of lot size %d", lot))
+	return adjusted, nil
+}
+
+// NotionalValue is the contract value of qty at price, accounting for the
+// price multiplier of lot-quoted contracts such as commodities.
+func (n *Normalizer) NotionalValue(qty int, price float64) float64 {
+	multiplier := n.Spec.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	return float64(qty) * price * float64(multiplier)
+}
+
+func (n *Normalizer) record(field string, original, adjusted float64, reason string) {
+	n.Adjustments = append(n.Adjustments, Adjustment{Field: field, Original: original, Adjusted: adjusted, Reason: reason})
+}
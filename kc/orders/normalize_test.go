@@ -0,0 +1,83 @@
+package orders
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRoundToTick(t *testing.T) {
+	tests := []struct {
+		price, tick float64
+		rounding    Rounding
+		want        float64
+	}{
+		{2450.37, 0.05, RoundNearest, 2450.35},
+		{2450.37, 0.05, RoundUp, 2450.40},
+		{2450.37, 0.05, RoundDown, 2450.35},
+		{2450.35, 0.05, RoundDown, 2450.35}, // already on a tick
+		{2450.35, 0.05, RoundUp, 2450.35},
+		{83.12345, 0.0025, RoundNearest, 83.1225},
+		{101.3, 1, RoundNearest, 101},
+		{101.3, 0, RoundNearest, 101.3},
+	}
+
+	for _, tt := range tests {
+		if got := RoundToTick(tt.price, tt.tick, tt.rounding); got != tt.want {
+			t.Errorf("RoundToTick(%v, %v, %v) = %v, want %v", tt.price, tt.tick, tt.rounding, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizerAdjust(t *testing.T) {
+	n := NewNormalizer(Spec{TickSize: 0.05, LotSize: 75, LowerCircuitLimit: 100, UpperCircuitLimit: 300}, ModeAdjust)
+
+	price, err := n.Price("price", 120.12, LimitRounding("BUY"))
+	if err != nil || price != 120.10 {
+		t.Errorf("Expected buy price rounded down to 120.10, got %v (err: %v)", price, err)
+	}
+	price, err = n.Price("price", 120.12, LimitRounding("SELL"))
+	if err != nil || price != 120.15 {
+		t.Errorf("Expected sell price rounded up to 120.15, got %v (err: %v)", price, err)
+	}
+	qty, err := n.Quantity("quantity", 160)
+	if err != nil || qty != 150 {
+		t.Errorf("Expected quantity rounded down to 150, got %d (err: %v)", qty, err)
+	}
+	if len(n.Adjustments) != 3 {
+		t.Errorf("Expected 3 adjustments, got %+v", n.Adjustments)
+	}
+
+	if _, err := n.Price("price", 350, RoundNearest); !errors.Is(err, ErrOutsideCircuit) {
+		t.Errorf("Expected ErrOutsideCircuit, got: %v", err)
+	}
+	if _, err := n.Quantity("quantity", 50); !errors.Is(err, ErrNotLotMultiple) {
+		t.Errorf("Expected quantity below one lot to fail, got: %v", err)
+	}
+	if price, _ := n.Price("price", 0, RoundNearest); price != 0 {
+		t.Errorf("Expected market price to pass through, got %v", price)
+	}
+}
+
+func TestNormalizerStrict(t *testing.T) {
+	n := NewNormalizer(Spec{TickSize: 0.05, LotSize: 75}, ModeStrict)
+
+	if _, err := n.Price("trigger_price", 120.12, RoundNearest); err == nil {
+		t.Error("Expected off-tick price to be rejected")
+	}
+	if _, err := n.Quantity("quantity", 160); err == nil {
+		t.Error("Expected off-lot quantity to be rejected")
+	}
+	if price, err := n.Price("price", 120.15, RoundNearest); err != nil || price != 120.15 {
+		t.Errorf("Expected valid price to pass, got %v (err: %v)", price, err)
+	}
+	if len(n.Adjustments) != 0 {
+		t.Errorf("Expected no adjustments in strict mode, got %+v", n.Adjustments)
+	}
+}
+
+func TestNotionalValue(t *testing.T) {
+	n := NewNormalizer(Spec{Multiplier: 100}, ModeAdjust)
+	if got := n.NotionalValue(2, 6500); got != 1300000 {
+		t.Errorf("Expected 1300000, got %v", got)
+	}
+}
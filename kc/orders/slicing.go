@@ -0,0 +1,105 @@
+// Package orders holds exchange order rules shared by the order tools:
+// lot validation and freeze-quantity slicing.
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidQuantity = errors.New("quantity must be greater than 0")
+	ErrNotLotMultiple  = errors.New("quantity is not a multiple of the lot size")
+)
+
+// Limits describes the per-order quantity constraints of an instrument.
+// Zero values mean no constraint, unless Unknown is set.
+type Limits struct {
+	LotSize          int
+	FreezeQuantity   int
+	MaxOrderQuantity int
+	// Unknown is set when the freeze and order quantities weren't
+	// available, so zero ones may still be limited by the exchange.
+	Unknown bool
+}
+
+// ValidateLots checks that qty is a positive multiple of the lot size.
+func ValidateLots(qty int, limits Limits) error {
+	if qty <= 0 {
+		return ErrInvalidQuantity
+	}
+	if limits.LotSize > 1 && qty%limits.LotSize != 0 {
+		return fmt.Errorf("%w: %d is not a multiple of %d", ErrNotLotMultiple, qty, limits.LotSize)
+	}
+	return nil
+}
+
+// MaxSliceQuantity returns the largest quantity a single order may carry.
+// Exchanges freeze orders at or above the freeze quantity, so slices stay
+// strictly below it, rounded down to whole lots. It returns 0 when the
+// instrument has no per-order limit.
+func MaxSliceQuantity(limits Limits) int {
+	limit := 0
+	if limits.FreezeQuantity > 0 {
+		limit = limits.FreezeQuantity - 1
+	}
+	if limits.MaxOrderQuantity > 0 && (limit == 0 || limits.MaxOrderQuantity < limit) {
+		limit = limits.MaxOrderQuantity
+	}
+	if limit > 0 && limits.LotSize > 1 {
+		limit -= limit % limits.LotSize
+	}
+	return limit
+}
+
+// Slice splits qty into order quantities that each stay within the
+// instrument's per-order limit. Every slice is a whole number of lots.
+func Slice(qty int, limits Limits) ([]int, error) {
+	if err := ValidateLots(qty, limits); err != nil {
+		return nil, err
+	}
+
+	limit := MaxSliceQuantity(limits)
+	if limit <= 0 || qty <= limit {
+		return []int{qty}, nil
+	}
+
+	parts := make([]int, 0, qty/limit+1)
+	for qty > 0 {
+		n := min(qty, limit)
+		parts = append(parts, n)
+		qty -= n
+	}
+	return parts, nil
+}
+
+// DefaultSliceInterval paces child orders to stay within the broker's
+// order rate limit of 10 orders per second.
+const DefaultSliceInterval = 200 * time.Millisecond
+
+// PlaceSlices places one order per quantity through place, waiting interval
+// between consecutive orders. It stops at the first failure or when ctx is
+// cancelled and returns the IDs of the orders placed so far.
+func PlaceSlices(ctx context.Context, quantities []int, interval time.Duration, place func(qty int) (string, error)) ([]string, error) {
+	orderIDs := make([]string, 0, len(quantities))
+	for i, qty := range quantities {
+		if i > 0 && interval > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return orderIDs, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		orderID, err := place(qty)
+		if err != nil {
+			return orderIDs, err
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+	return orderIDs, nil
+}
@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidateLots(t *testing.T) {
+	limits := Limits{LotSize: 75}
+
+	if err := ValidateLots(150, limits); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+	if err := ValidateLots(100, limits); !errors.Is(err, ErrNotLotMultiple) {
+		t.Errorf("Expected ErrNotLotMultiple, got: %v", err)
+	}
+	if err := ValidateLots(0, limits); !errors.Is(err, ErrInvalidQuantity) {
+		t.Errorf("Expected ErrInvalidQuantity, got: %v", err)
+	}
+	if err := ValidateLots(7, Limits{}); err != nil {
+		t.Errorf("Expected no lot constraint for equity, got: %v", err)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	tests := []struct {
+		name   string
+		qty    int
+		limits Limits
+		want   []int
+	}{
+		{"below freeze", 150, Limits{LotSize: 75, FreezeQuantity: 1800}, []int{150}},
+		{"exactly freeze", 1800, Limits{LotSize: 75, FreezeQuantity: 1800}, []int{1725, 75}},
+		{"multiple slices", 4500, Limits{LotSize: 75, FreezeQuantity: 1800}, []int{1725, 1725, 1050}},
+		{"max order quantity", 25, Limits{MaxOrderQuantity: 10}, []int{10, 10, 5}},
+		{"no limits", 100000, Limits{}, []int{100000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Slice(tt.qty, tt.limits)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Slice(100, Limits{LotSize: 75, FreezeQuantity: 1800}); err == nil {
+		t.Error("Expected error for quantity that is not a lot multiple")
+	}
+}
+
+func TestPlaceSlices(t *testing.T) {
+	var placed []int
+	place := func(qty int) (string, error) {
+		if len(placed) == 2 {
+			return "", errors.New("rejected")
+		}
+		placed = append(placed, qty)
+		return fmt.Sprintf("order-%d", len(placed)), nil
+	}
+
+	start := time.Now()
+	ids, err := PlaceSlices(context.Background(), []int{1725, 1725, 1050}, 10*time.Millisecond, place)
+	if err == nil {
+		t.Error("Expected the third slice to fail")
+	}
+	if !slices.Equal(ids, []string{"order-1", "order-2"}) {
+		t.Errorf("Expected the two placed order IDs, got %v", ids)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected slices to be paced, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	placed = nil
+	ids, err = PlaceSlices(ctx, []int{75, 75}, time.Hour, place)
+	if !errors.Is(err, context.Canceled) || len(ids) != 1 {
+		t.Errorf("Expected cancellation after the first slice, got %v (err: %v)", ids, err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package orderwatch tracks order status updates pushed by Kite postbacks
+// and lets callers wait for an order to reach a terminal status.
+package orderwatch
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+)
+
+// DefaultRetention is how long the latest update of an order is kept.
+const DefaultRetention = 24 * time.Hour
+
+// Order statuses after which an order no longer changes.
+const (
+	StatusComplete  = "COMPLETE"
+	StatusCancelled = "CANCELLED"
+	StatusRejected  = "REJECTED"
+)
+
+// IsTerminal reports whether an order status is final.
+func IsTerminal(status string) bool {
+	switch status {
+	case StatusComplete, StatusCancelled, StatusRejected:
+		return true
+	}
+	return false
+}
+
+// Update is an order update as sent in a Kite postback.
+type Update struct {
+	UserID            string  `json:"user_id"`
+	OrderID           string  `json:"order_id"`
+	ExchangeOrderID   string  `json:"exchange_order_id"`
+	ParentOrderID     string  `json:"parent_order_id"`
+	Status            string  `json:"status"`
+	StatusMessage     string  `json:"status_message"`
+	OrderTimestamp    string  `json:"order_timestamp"`
+	ExchangeTimestamp string  `json:"exchange_timestamp"`
+	Variety           string  `json:"variety"`
+	Exchange          string  `json:"exchange"`
+	Tradingsymbol     string  `json:"tradingsymbol"`
+	InstrumentToken   uint32  `json:"instrument_token"`
+	OrderType         string  `json:"order_type"`
+	TransactionType   string  `json:"transaction_type"`
+	Validity          string  `json:"validity"`
+	Product           string  `json:"product"`
+	Quantity          float64 `json:"quantity"`
+	Price             float64 `json:"price"`
+	TriggerPrice      float64 `json:"trigger_price"`
+	AveragePrice      float64 `json:"average_price"`
+	FilledQuantity    float64 `json:"filled_quantity"`
+	PendingQuantity   float64 `json:"pending_quantity"`
+	CancelledQuantity float64 `json:"cancelled_quantity"`
+	Tag               string  `json:"tag"`
+	Checksum          string  `json:"checksum,omitempty"`
+}
+
+// FromOrder converts an order book entry to the postback shape.
+func FromOrder(o kiteconnect.Order) Update {
+	return Update{
+		UserID:            o.PlacedBy,
+		OrderID:           o.OrderID,
+		ExchangeOrderID:   o.ExchangeOrderID,
+		ParentOrderID:     o.ParentOrderID,
+		Status:            o.Status,
+		StatusMessage:     o.StatusMessage,
+		OrderTimestamp:    formatOrderTime(o.OrderTimestamp),
+		ExchangeTimestamp: formatOrderTime(o.ExchangeTimestamp),
+		Variety:           o.Variety,
+		Exchange:          o.Exchange,
+		Tradingsymbol:     o.TradingSymbol,
+		InstrumentToken:   o.InstrumentToken,
+		OrderType:         o.OrderType,
+		TransactionType:   o.TransactionType,
+		Validity:          o.Validity,
+		Product:           o.Product,
+		Quantity:          o.Quantity,
+		Price:             o.Price,
+		TriggerPrice:      o.TriggerPrice,
+		AveragePrice:      o.AveragePrice,
+		FilledQuantity:    o.FilledQuantity,
+		PendingQuantity:   o.PendingQuantity,
+		CancelledQuantity: o.CancelledQuantity,
+		Tag:               o.Tag,
+	}
+}
+
+// formatOrderTime formats an order timestamp the way postbacks carry it.
+func formatOrderTime(t models.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateTime)
+}
+
+// Checksum computes the postback checksum Kite sends with every update:
+// the hex SHA-256 of order ID, order timestamp and API secret.
+func Checksum(orderID, orderTimestamp, apiSecret string) string {
+	sum := sha256.Sum256([]byte(orderID + orderTimestamp + apiSecret))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyChecksum reports whether the update was signed with apiSecret.
+func VerifyChecksum(u Update, apiSecret string) bool {
+	if u.Checksum == "" || apiSecret == "" {
+		return false
+	}
+	expected := Checksum(u.OrderID, u.OrderTimestamp, apiSecret)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(u.Checksum)) == 1
+}
+
+type entry struct {
+	update     Update
+	receivedAt time.Time
+}
+
+// Hub keeps the latest update of every order, notifies listeners of each
+// update and wakes goroutines waiting for orders to finish.
+type Hub struct {
+	mu        sync.Mutex
+	latest    map[string]entry
+	waiters   map[string][]chan Update
+	listeners []func(Update)
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{
+		latest:    make(map[string]entry),
+		waiters:   make(map[string][]chan Update),
+		retention: DefaultRetention,
+		now:       time.Now,
+	}
+}
+
+// OnUpdate registers a listener called for every published update.
+// Listeners run on the publishing goroutine and must not block.
+func (h *Hub) OnUpdate(fn func(Update)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, fn)
+}
+
+// Publish records an update. Postbacks can arrive out of order, so an
+// update never replaces a terminal one.
+func (h *Hub) Publish(u Update) {
+	if u.OrderID == "" {
+		return
+	}
+
+	h.mu.Lock()
+	now := h.now()
+	h.pruneLocked(now)
+
+	if prev, ok := h.latest[u.OrderID]; ok && IsTerminal(prev.update.Status) && !IsTerminal(u.Status) {
+		h.mu.Unlock()
+		return
+	}
+	h.latest[u.OrderID] = entry{update: u, receivedAt: now}
+
+	var waiters []chan Update
+	if IsTerminal(u.Status) {
+		waiters = h.waiters[u.OrderID]
+		delete(h.waiters, u.OrderID)
+	}
+	listeners := append([]func(Update){}, h.listeners...)
+	h.mu.Unlock()
+
+	for _, ch := range waiters {
+		ch <- u
+	}
+	for _, fn := range listeners {
+		fn(u)
+	}
+}
+
+// Latest returns the most recent update received for an order.
+func (h *Hub) Latest(orderID string) (Update, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	e, ok := h.latest[orderID]
+	return e.update, ok
+}
+
+// Subscribe returns a channel that receives the order's terminal update.
+// If the order has already finished the update is delivered immediately.
+// The returned function releases the subscription.
+func (h *Hub) Subscribe(orderID string) (<-chan Update, func()) {
+	ch := make(chan Update, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e, ok := h.latest[orderID]; ok && IsTerminal(e.update.Status) {
+		ch <- e.update
+		return ch, func() {}
+	}
+	h.waiters[orderID] = append(h.waiters[orderID], ch)
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		waiters := h.waiters[orderID]
+		for i, w := range waiters {
+			if w == ch {
+				h.waiters[orderID] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(h.waiters[orderID]) == 0 {
+			delete(h.waiters, orderID)
+		}
+	}
+}
+
+// Wait blocks until the order reaches a terminal status or ctx is done.
+func (h *Hub) Wait(ctx context.Context, orderID string) (Update, error) {
+	ch, cancel := h.Subscribe(orderID)
+	defer cancel()
+
+	select {
+	case u := <-ch:
+		return u, nil
+	case <-ctx.Done():
+		return Update{}, ctx.Err()
+	}
+}
+
+func (h *Hub) pruneLocked(now time.Time) {
+	for id, e := range h.latest {
+		if now.Sub(e.receivedAt) > h.retention {
+			delete(h.latest, id)
+		}
+	}
+}
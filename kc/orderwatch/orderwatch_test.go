@@ -0,0 +1,199 @@
+package orderwatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+func TestChecksum(t *testing.T) {
+	got := Checksum("12345", "2024-01-02 09:15:00", "secret")
+	if len(got) != 64 {
+		t.Fatalf("Expected 64 hex characters, got %d", len(got))
+	}
+	if got != Checksum("12345", "2024-01-02 09:15:00", "secret") {
+		t.Error("Expected checksum to be deterministic")
+	}
+	if got == Checksum("12345", "2024-01-02 09:15:01", "secret") {
+		t.Error("Expected checksum to depend on the order timestamp")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	u := Update{OrderID: "1", OrderTimestamp: "2024-01-02 09:15:00"}
+	u.Checksum = Checksum(u.OrderID, u.OrderTimestamp, "secret")
+
+	if !VerifyChecksum(u, "secret") {
+		t.Error("Expected valid checksum to verify")
+	}
+	if VerifyChecksum(u, "other") {
+		t.Error("Expected checksum with a different secret to fail")
+	}
+	if VerifyChecksum(u, "") {
+		t.Error("Expected verification without a secret to fail")
+	}
+
+	u.Checksum = ""
+	if VerifyChecksum(u, "secret") {
+		t.Error("Expected missing checksum to fail")
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	for status, want := range map[string]bool{
+		"COMPLETE":        true,
+		"CANCELLED":       true,
+		"REJECTED":        true,
+		"OPEN":            false,
+		"TRIGGER PENDING": false,
+		"UPDATE":          false,
+		"":                false,
+	} {
+		if got := IsTerminal(status); got != want {
+			t.Errorf("Expected IsTerminal(%q) = %v, got %v", status, want, got)
+		}
+	}
+}
+
+func TestPublishKeepsTerminalUpdate(t *testing.T) {
+	hub := NewHub()
+	hub.Publish(Update{OrderID: "1", Status: "OPEN"})
+	hub.Publish(Update{OrderID: "1", Status: "COMPLETE"})
+	// A late OPEN postback must not revive a completed order.
+	hub.Publish(Update{OrderID: "1", Status: "OPEN"})
+
+	u, ok := hub.Latest("1")
+	if !ok {
+		t.Fatal("Expected an update for order 1")
+	}
+	if u.Status != "COMPLETE" {
+		t.Errorf("Expected status COMPLETE, got %s", u.Status)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	hub := NewHub()
+	ch, cancel := hub.Subscribe("1")
+	defer cancel()
+
+	hub.Publish(Update{OrderID: "1", Status: "OPEN"})
+	select {
+	case u := <-ch:
+		t.Fatalf("Expected no delivery for non-terminal update, got %s", u.Status)
+	default:
+	}
+
+	hub.Publish(Update{OrderID: "1", Status: "CANCELLED"})
+	select {
+	case u := <-ch:
+		if u.Status != "CANCELLED" {
+			t.Errorf("Expected status CANCELLED, got %s", u.Status)
+		}
+	default:
+		t.Fatal("Expected terminal update to be delivered")
+	}
+
+	// Subscribing after the order finished delivers immediately.
+	late, lateCancel := hub.Subscribe("1")
+	defer lateCancel()
+	select {
+	case u := <-late:
+		if u.Status != "CANCELLED" {
+			t.Errorf("Expected status CANCELLED, got %s", u.Status)
+		}
+	default:
+		t.Fatal("Expected late subscriber to receive the terminal update")
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	hub := NewHub()
+	_, cancel := hub.Subscribe("1")
+	cancel()
+
+	if len(hub.waiters) != 0 {
+		t.Errorf("Expected no waiters after unsubscribe, got %d", len(hub.waiters))
+	}
+	// Publishing after unsubscribe must not block.
+	hub.Publish(Update{OrderID: "1", Status: "COMPLETE"})
+}
+
+func TestWait(t *testing.T) {
+	hub := NewHub()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		hub.Publish(Update{OrderID: "1", Status: "REJECTED"})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	u, err := hub.Wait(ctx, "1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if u.Status != "REJECTED" {
+		t.Errorf("Expected status REJECTED, got %s", u.Status)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := hub.Wait(ctx, "1"); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestOnUpdate(t *testing.T) {
+	hub := NewHub()
+	var got []string
+	hub.OnUpdate(func(u Update) {
+		got = append(got, u.Status)
+	})
+
+	hub.Publish(Update{OrderID: "1", Status: "OPEN"})
+	hub.Publish(Update{OrderID: "1", Status: "COMPLETE"})
+	hub.Publish(Update{Status: "OPEN"}) // no order ID, ignored
+
+	if len(got) != 2 || got[0] != "OPEN" || got[1] != "COMPLETE" {
+		t.Errorf("Expected [OPEN COMPLETE], got %v", got)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	hub := NewHub()
+	now := time.Date(2024, 1, 2, 9, 15, 0, 0, time.UTC)
+	hub.now = func() time.Time { return now }
+
+	hub.Publish(Update{OrderID: "1", Status: "COMPLETE"})
+	now = now.Add(DefaultRetention + time.Minute)
+	hub.Publish(Update{OrderID: "2", Status: "OPEN"})
+
+	if _, ok := hub.Latest("1"); ok {
+		t.Error("Expected order 1 to be pruned")
+	}
+	if _, ok := hub.Latest("2"); !ok {
+		t.Error("Expected order 2 to be kept")
+	}
+}
+
+func TestFromOrder(t *testing.T) {
+	u := FromOrder(kiteconnect.Order{
+		PlacedBy:       "AB1234",
+		OrderID:        "1",
+		Status:         "COMPLETE",
+		TradingSymbol:  "INFY",
+		FilledQuantity: 10,
+	})
+	if u.UserID != "AB1234" || u.Tradingsymbol != "INFY" || u.FilledQuantity != 10 {
+		t.Errorf("Expected AB1234/INFY/10, got %s/%s/%v", u.UserID, u.Tradingsymbol, u.FilledQuantity)
+	}
+	if u.OrderTimestamp != "" {
+		t.Errorf("Expected empty timestamp for zero time, got %q", u.OrderTimestamp)
+	}
+}
@@ -0,0 +1,68 @@
+package kc
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+// maxPostbackBytes bounds the size of an order postback body.
+const maxPostbackBytes = 64 << 10
+
+// HandleOrderPostback receives Kite order postbacks. Updates are accepted
+// only when their checksum matches the API secret and are published to
+// OrderUpdates.
+func (m *Manager) HandleOrderPostback() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var update orderwatch.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPostbackBytes)).Decode(&update); err != nil {
+			m.Logger.Warn("Invalid order postback body", "error", err)
+			http.Error(w, "invalid postback body", http.StatusBadRequest)
+			return
+		}
+
+		if !orderwatch.VerifyChecksum(update, m.apiSecret) {
+			m.Logger.Warn("Order postback checksum mismatch", "order_id", update.OrderID)
+			http.Error(w, "invalid checksum", http.StatusUnauthorized)
+			return
+		}
+
+		m.Logger.Debug("Received order postback", "order_id", update.OrderID, "status", update.Status, "user_id", update.UserID)
+		m.OrderUpdates.Publish(update)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// SessionIDsForUser returns the active MCP sessions logged in as a Kite user.
+func (m *Manager) SessionIDsForUser(userID string) []string {
+	if userID == "" {
+		return nil
+	}
+
+	var ids []string
+	for _, session := range m.sessionManager.ListActiveSessions() {
+		if data, ok := session.Data.(*KiteSessionData); ok && data.UserID == userID {
+			ids = append(ids, session.ID)
+		}
+	}
+	return ids
+}
+
+// KiteSessions returns the active MCP sessions logged in to Kite, by
+// session ID.
+func (m *Manager) KiteSessions() map[string]*KiteSessionData {
+	sessions := make(map[string]*KiteSessionData)
+	for _, session := range m.sessionManager.ListActiveSessions() {
+		if data, ok := session.Data.(*KiteSessionData); ok && data.UserID != "" && data.Kite != nil {
+			sessions[session.ID] = data
+		}
+	}
+	return sessions
+}
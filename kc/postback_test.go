@@ -0,0 +1,140 @@
+package kc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+// postbackSender plays the part of Kite, posting signed order updates to a
+// postback URL.
+type postbackSender struct {
+	url       string
+	apiSecret string
+}
+
+func (s postbackSender) send(t *testing.T, u orderwatch.Update) int {
+	t.Helper()
+	if u.Checksum == "" {
+		u.Checksum = orderwatch.Checksum(u.OrderID, u.OrderTimestamp, s.apiSecret)
+	}
+	body, err := json.Marshal(u)
+	if err != nil {
+		t.Fatalf("Failed to encode postback: %v", err)
+	}
+	resp, err := http.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to send postback: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func newPostbackServer(t *testing.T) (*Manager, *httptest.Server) {
+	t.Helper()
+	manager, err := newTestManager("test_key", "test_secret")
+	if err != nil {
+		t.Fatalf("Expected no error creating manager, got: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(manager.HandleOrderPostback()))
+	t.Cleanup(srv.Close)
+	return manager, srv
+}
+
+func TestHandleOrderPostback(t *testing.T) {
+	manager, srv := newPostbackServer(t)
+	sender := postbackSender{url: srv.URL, apiSecret: "test_secret"}
+
+	var received []orderwatch.Update
+	manager.OrderUpdates.OnUpdate(func(u orderwatch.Update) {
+		received = append(received, u)
+	})
+
+	status := sender.send(t, orderwatch.Update{
+		UserID:         "AB1234",
+		OrderID:        "240102000000001",
+		Status:         "COMPLETE",
+		OrderTimestamp: "2024-01-02 09:15:00",
+		Tradingsymbol:  "INFY",
+		FilledQuantity: 10,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 published update, got %d", len(received))
+	}
+	if received[0].Tradingsymbol != "INFY" || received[0].FilledQuantity != 10 {
+		t.Errorf("Expected INFY with 10 filled, got %s with %v", received[0].Tradingsymbol, received[0].FilledQuantity)
+	}
+	if u, ok := manager.OrderUpdates.Latest("240102000000001"); !ok || u.Status != "COMPLETE" {
+		t.Errorf("Expected latest status COMPLETE, got %+v", u)
+	}
+}
+
+func TestHandleOrderPostbackBadChecksum(t *testing.T) {
+	manager, srv := newPostbackServer(t)
+	sender := postbackSender{url: srv.URL, apiSecret: "wrong_secret"}
+
+	status := sender.send(t, orderwatch.Update{OrderID: "1", Status: "COMPLETE", OrderTimestamp: "2024-01-02 09:15:00"})
+	if status != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", status)
+	}
+	if _, ok := manager.OrderUpdates.Latest("1"); ok {
+		t.Error("Expected forged update not to be published")
+	}
+}
+
+func TestHandleOrderPostbackRejectsInvalidRequests(t *testing.T) {
+	_, srv := newPostbackServer(t)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", bytes.NewReader([]byte("not json")))
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for malformed body, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionIDsForUser(t *testing.T) {
+	manager, err := newTestManager("test_key", "test_secret")
+	if err != nil {
+		t.Fatalf("Expected no error creating manager, got: %v", err)
+	}
+
+	first := manager.sessionManager.GenerateWithData(&KiteSessionData{UserID: "AB1234"})
+	manager.sessionManager.GenerateWithData(&KiteSessionData{UserID: "CD5678"})
+	second := manager.sessionManager.GenerateWithData(&KiteSessionData{UserID: "AB1234"})
+
+	ids := manager.SessionIDsForUser("AB1234")
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(ids))
+	}
+	found := map[string]bool{}
+	for _, id := range ids {
+		found[id] = true
+	}
+	if !found[first] || !found[second] {
+		t.Errorf("Expected sessions %s and %s, got %v", first, second, ids)
+	}
+
+	if ids := manager.SessionIDsForUser(""); len(ids) != 0 {
+		t.Errorf("Expected no sessions for empty user, got %v", ids)
+	}
+}
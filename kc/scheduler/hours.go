@@ -0,0 +1,18 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+)
+
+// Calendar tells the scheduler when an exchange is trading.
+type Calendar interface {
+	// IsOpen reports whether the exchange is in its normal trading session at t.
+	IsOpen(exchange string, t time.Time) bool
+	// Session returns the trading session of the exchange on the day of t,
+	// with ok false if the exchange does not trade that day.
+	Session(exchange string, day time.Time) (open, close time.Time, ok bool)
+}
+
+var ist = calendar.IST
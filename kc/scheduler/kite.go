@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// KiteClient is the part of the Kite Connect API used by KiteBroker.
+type KiteClient interface {
+	PlaceOrder(variety string, orderParams kiteconnect.OrderParams) (kiteconnect.OrderResponse, error)
+	GetLTP(instruments ...string) (kiteconnect.QuoteLTP, error)
+	GetHistoricalData(instrumentToken int, interval string, fromDate time.Time, toDate time.Time, continuous bool, OI bool) ([]kiteconnect.HistoricalData, error)
+}
+
+// KiteBroker evaluates triggers and places orders through a Kite client.
+type KiteBroker struct {
+	Client KiteClient
+}
+
+func (b KiteBroker) PlaceOrder(variety string, params kiteconnect.OrderParams) (string, error) {
+	resp, err := b.Client.PlaceOrder(variety, params)
+	if err != nil {
+		return "", err
+	}
+	return resp.OrderID, nil
+}
+
+func (b KiteBroker) LTP(instrument string) (float64, error) {
+	quotes, err := b.Client.GetLTP(instrument)
+	if err != nil {
+		return 0, err
+	}
+	q, ok := quotes[instrument]
+	if !ok {
+		return 0, fmt.Errorf("no quote for %s", instrument)
+	}
+	return q.LastPrice, nil
+}
+
+// Candles fetches historical candles. Kite reads the range as IST wall
+// clock times.
+func (b KiteBroker) Candles(instrumentToken uint32, interval string, from, to time.Time) ([]kiteconnect.HistoricalData, error) {
+	return b.Client.GetHistoricalData(int(instrumentToken), interval, from.In(ist), to.In(ist), false, false)
+}
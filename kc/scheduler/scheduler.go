@@ -0,0 +1,606 @@
+// Package scheduler holds orders until a time or a market condition is
+// reached and then places them through the owner's Kite session. The queue
+// is persisted so that pending orders survive restarts.
+package scheduler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+)
+
+const (
+	// DefaultTickInterval is how often pending orders are evaluated.
+	DefaultTickInterval = 15 * time.Second
+
+	// DefaultTimeGrace is how late a time-triggered order may still be
+	// placed, e.g. when no session was available at the scheduled time.
+	DefaultTimeGrace = 5 * time.Minute
+
+	// DefaultConditionValidity is how long a condition-triggered order waits
+	// for its condition unless an expiry is given.
+	DefaultConditionValidity = 7 * 24 * time.Hour
+
+	// DefaultRetention is how long finished orders stay listed after they
+	// expire.
+	DefaultRetention = 7 * 24 * time.Hour
+
+	// MaxPendingPerUser bounds the queue of one user.
+	MaxPendingPerUser = 50
+
+	// Tag marks the orders placed by the scheduler.
+	Tag = "SCHEDULED"
+)
+
+var (
+	ErrNotFound  = errors.New("scheduled order not found")
+	ErrNotActive = errors.New("scheduled order is no longer pending")
+	ErrPlacing   = errors.New("scheduled order is being placed")
+	ErrQueueFull = fmt.Errorf("too many pending scheduled orders, at most %d are allowed", MaxPendingPerUser)
+)
+
+// Status is the lifecycle stage of a scheduled order.
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"   // waiting for the trigger
+	StatusTriggered Status = "TRIGGERED" // trigger met, waiting for the market to open
+	StatusPlaced    Status = "PLACED"    // order sent to the exchange
+	StatusFailed    Status = "FAILED"    // placing the order failed
+	StatusCancelled Status = "CANCELLED"
+	StatusExpired   Status = "EXPIRED"
+)
+
+// Active reports whether the scheduler still acts on orders in this state.
+func (s Status) Active() bool {
+	return s == StatusPending || s == StatusTriggered
+}
+
+// Order is the order to place once the trigger is met.
+type Order struct {
+	Variety         string  `json:"variety"`
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	TransactionType string  `json:"transaction_type"`
+	Product         string  `json:"product"`
+	OrderType       string  `json:"order_type"`
+	Quantity        int     `json:"quantity"`
+	Price           float64 `json:"price,omitempty"`
+	TriggerPrice    float64 `json:"trigger_price,omitempty"`
+	Validity        string  `json:"validity,omitempty"`
+}
+
+func (o Order) params() kiteconnect.OrderParams {
+	return kiteconnect.OrderParams{
+		Exchange:        o.Exchange,
+		Tradingsymbol:   o.Tradingsymbol,
+		TransactionType: o.TransactionType,
+		Product:         o.Product,
+		OrderType:       o.OrderType,
+		Quantity:        o.Quantity,
+		Price:           o.Price,
+		TriggerPrice:    o.TriggerPrice,
+		Validity:        o.Validity,
+		Tag:             Tag,
+	}
+}
+
+// afterMarket reports whether the order may be placed while the exchange is
+// closed.
+func (o Order) afterMarket() bool {
+	return o.Variety == kiteconnect.VarietyAMO
+}
+
+// Job is a scheduled order and its progress.
+type Job struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	Order        Order     `json:"order"`
+	Trigger      Trigger   `json:"trigger"`
+	Status       Status    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	TriggeredAt  time.Time `json:"triggered_at,omitzero"`
+	TriggerValue float64   `json:"trigger_value,omitempty"`
+	PlacedAt     time.Time `json:"placed_at,omitzero"`
+	OrderID      string    `json:"order_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+
+	// LastCandle is the start of the latest candle a candle or indicator
+	// trigger has evaluated.
+	LastCandle time.Time `json:"last_candle,omitzero"`
+	nextCheck  time.Time
+	// placing is set while the order is sent to the broker, which is done
+	// without holding the lock
+	placing bool
+}
+
+// Broker is the Kite API of one user as used by the scheduler.
+type Broker interface {
+	PlaceOrder(variety string, params kiteconnect.OrderParams) (string, error)
+	LTP(instrument string) (float64, error)
+	Candles(instrumentToken uint32, interval string, from, to time.Time) ([]kiteconnect.HistoricalData, error)
+}
+
+// Clock tells the scheduler the time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// Config configures a Scheduler.
+type Config struct {
+	// Path of the queue file. If empty the queue is kept in memory only.
+	Path   string
+	Logger *slog.Logger
+	// Brokers returns the Kite API of a user, or an error if the user has
+	// no active session.
+	Brokers  func(userID string) (Broker, error)
+	Calendar Calendar // optional - defaults to a market calendar without holidays
+	Clock    Clock    // optional - defaults to the system clock
+}
+
+// Scheduler is a persisted queue of scheduled orders.
+type Scheduler struct {
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	path     string
+	logger   *slog.Logger
+	brokers  func(userID string) (Broker, error)
+	calendar Calendar
+	clock    Clock
+
+	tickMu sync.Mutex
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// New creates a scheduler and loads the queue saved at cfg.Path.
+func New(cfg Config) (*Scheduler, error) {
+	s := &Scheduler{
+		jobs:     make(map[string]*Job),
+		path:     cfg.Path,
+		logger:   cfg.Logger,
+		brokers:  cfg.Brokers,
+		calendar: cfg.Calendar,
+		clock:    cfg.Clock,
+		stop:     make(chan struct{}),
+	}
+	if s.calendar == nil {
+		s.calendar = calendar.New()
+	}
+	if s.clock == nil {
+		s.clock = systemClock{}
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Scheduler) load() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return fmt.Errorf("error reading scheduled orders: %w", err)
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("error decoding scheduled orders: %w", err)
+	}
+	for _, j := range jobs {
+		s.jobs[j.ID] = j
+	}
+	return nil
+}
+
+// saveLocked writes the queue atomically. s.mu must be held.
+func (s *Scheduler) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("error creating scheduled orders directory: %w", err)
+	}
+
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].CreatedAt.Before(jobs[b].CreatedAt) })
+
+	data, err := json.Marshal(jobs)
+	if err != nil {
+		return fmt.Errorf("error encoding scheduled orders: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("error writing scheduled orders: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("error writing scheduled orders: %w", err)
+	}
+	return nil
+}
+
+// Schedule queues an order for userID. A zero expiresAt uses the default
+// validity of the trigger type.
+func (s *Scheduler) Schedule(userID string, order Order, trigger Trigger, expiresAt time.Time) (Job, error) {
+	if err := trigger.Validate(); err != nil {
+		return Job{}, err
+	}
+	if order.Exchange == "" || order.Tradingsymbol == "" || order.Quantity <= 0 {
+		return Job{}, errors.New("order needs an exchange, a tradingsymbol and a positive quantity")
+	}
+
+	now := s.clock.Now()
+	if trigger.Type == TriggerTime {
+		if !trigger.At.After(now) {
+			return Job{}, errors.New("scheduled time must be in the future")
+		}
+		if !order.afterMarket() && !s.calendar.IsOpen(order.Exchange, trigger.At) {
+			return Job{}, fmt.Errorf("%s is closed at %s; pick a time within trading hours or use the amo variety", order.Exchange, trigger.At.In(ist).Format("2006-01-02 15:04 MST"))
+		}
+	}
+
+	if expiresAt.IsZero() {
+		if trigger.Type == TriggerTime {
+			expiresAt = trigger.At.Add(DefaultTimeGrace)
+		} else {
+			expiresAt = now.Add(DefaultConditionValidity)
+		}
+	}
+	if !expiresAt.After(now) {
+		return Job{}, errors.New("expiry must be in the future")
+	}
+	if trigger.Type == TriggerTime && expiresAt.Before(trigger.At) {
+		return Job{}, errors.New("expiry must be after the scheduled time")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := 0
+	for _, j := range s.jobs {
+		if j.UserID == userID && j.Status.Active() {
+			pending++
+		}
+	}
+	if pending >= MaxPendingPerUser {
+		return Job{}, ErrQueueFull
+	}
+
+	job := &Job{
+		ID:        newID(),
+		UserID:    userID,
+		Order:     order,
+		Trigger:   trigger,
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	s.jobs[job.ID] = job
+	if err := s.saveLocked(); err != nil {
+		delete(s.jobs, job.ID)
+		return Job{}, err
+	}
+
+	s.logger.Info("Scheduled order", "id", job.ID, "user_id", userID, "trigger", trigger.Type, "tradingsymbol", order.Tradingsymbol)
+	return *job, nil
+}
+
+// List returns the scheduled orders of userID, oldest first.
+func (s *Scheduler) List(userID string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []Job
+	for _, j := range s.jobs {
+		if j.UserID == userID {
+			jobs = append(jobs, *j)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].CreatedAt.Before(jobs[b].CreatedAt) })
+	return jobs
+}
+
+// Cancel cancels a pending or triggered order of userID.
+func (s *Scheduler) Cancel(id, userID string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok || j.UserID != userID {
+		return Job{}, ErrNotFound
+	}
+	if !j.Status.Active() {
+		return *j, ErrNotActive
+	}
+	if j.placing {
+		return *j, ErrPlacing
+	}
+
+	prev := j.Status
+	j.Status = StatusCancelled
+	if err := s.saveLocked(); err != nil {
+		j.Status = prev
+		return Job{}, err
+	}
+	return *j, nil
+}
+
+// Start evaluates the queue every interval until Shutdown.
+func (s *Scheduler) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.Tick()
+			}
+		}
+	}()
+}
+
+// Shutdown stops the loop started by Start.
+func (s *Scheduler) Shutdown() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// Tick evaluates every active order once: expired orders are retired, met
+// triggers are recorded and triggered orders are placed while their
+// exchange is open.
+func (s *Scheduler) Tick() {
+	s.tickMu.Lock()
+	defer s.tickMu.Unlock()
+
+	changed := false
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	var active []Job
+	for id, j := range s.jobs {
+		switch {
+		case j.Status.Active():
+			active = append(active, *j)
+		case now.Sub(j.ExpiresAt) > DefaultRetention:
+			delete(s.jobs, id)
+			changed = true
+		}
+	}
+	s.mu.Unlock()
+
+	for _, snapshot := range active {
+		if s.process(snapshot) {
+			changed = true
+		}
+	}
+
+	if changed {
+		s.mu.Lock()
+		if err := s.saveLocked(); err != nil {
+			s.logger.Error("Failed to save scheduled orders", "error", err)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// process advances one job from a snapshot taken without the lock and
+// reports whether its persisted state changed.
+func (s *Scheduler) process(j Job) bool {
+	now := s.clock.Now()
+	if now.After(j.ExpiresAt) {
+		return s.commit(j, func(cur *Job) bool {
+			cur.Status = StatusExpired
+			if cur.Error == "" {
+				cur.Error = "expired before the order could be placed"
+			}
+			return true
+		})
+	}
+
+	broker, err := s.brokers(j.UserID)
+	if err != nil {
+		// Keep waiting; the user may log in before the order expires.
+		return s.setError(j, err.Error())
+	}
+
+	changed := false
+	if j.Status == StatusPending {
+		met, value, err := s.evaluate(&j, broker, now)
+		changed = s.commit(j, func(cur *Job) bool {
+			cur.nextCheck = j.nextCheck
+			if cur.LastCandle.Equal(j.LastCandle) {
+				return false
+			}
+			cur.LastCandle = j.LastCandle
+			return true
+		})
+		if err != nil {
+			s.logger.Warn("Failed to evaluate scheduled order", "id", j.ID, "error", err)
+			return s.setError(j, err.Error()) || changed
+		}
+		if !met {
+			return changed
+		}
+
+		if !s.commit(j, func(cur *Job) bool {
+			cur.Status = StatusTriggered
+			cur.TriggeredAt = now
+			cur.TriggerValue = value
+			cur.Error = ""
+			return true
+		}) {
+			return changed
+		}
+		changed = true
+		j.Status = StatusTriggered
+		s.logger.Info("Scheduled order triggered", "id", j.ID, "trigger", j.Trigger.Type, "value", value)
+	}
+
+	if !j.Order.afterMarket() && !s.calendar.IsOpen(j.Order.Exchange, now) {
+		return changed
+	}
+
+	// Mark the job in flight so that it can't be cancelled while the order
+	// is sent, without holding the lock across the broker call
+	s.mu.Lock()
+	cur, ok := s.jobs[j.ID]
+	if !ok || cur.Status != StatusTriggered {
+		s.mu.Unlock()
+		return changed
+	}
+	cur.placing = true
+	order := cur.Order
+	s.mu.Unlock()
+
+	orderID, err := broker.PlaceOrder(order.Variety, order.params())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur.placing = false
+	if err != nil {
+		cur.Status = StatusFailed
+		cur.Error = err.Error()
+		s.logger.Error("Failed to place scheduled order", "id", cur.ID, "error", err)
+		return true
+	}
+	cur.Status = StatusPlaced
+	cur.OrderID = orderID
+	cur.PlacedAt = now
+	cur.Error = ""
+	s.logger.Info("Placed scheduled order", "id", cur.ID, "order_id", orderID)
+	return true
+}
+
+// setError records why a job is not progressing.
+func (s *Scheduler) setError(j Job, message string) bool {
+	return s.commit(j, func(cur *Job) bool {
+		if cur.Error == message {
+			return false
+		}
+		cur.Error = message
+		return true
+	})
+}
+
+// commit applies update to the stored job unless it was cancelled or
+// otherwise moved on since snapshot was taken. update reports whether it
+// changed anything worth saving.
+func (s *Scheduler) commit(snapshot Job, update func(cur *Job) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.jobs[snapshot.ID]
+	if !ok || cur.Status != snapshot.Status {
+		return false
+	}
+	return update(cur)
+}
+
+// evaluate reports whether the trigger of a pending job is met, with the
+// observed value for condition triggers.
+func (s *Scheduler) evaluate(j *Job, broker Broker, now time.Time) (bool, float64, error) {
+	t := j.Trigger
+	switch t.Type {
+	case TriggerTime:
+		return !now.Before(t.At), 0, nil
+
+	case TriggerLTP:
+		if !s.calendar.IsOpen(t.Exchange(), now) {
+			return false, 0, nil
+		}
+		price, err := broker.LTP(t.Instrument)
+		if err != nil {
+			return false, 0, fmt.Errorf("error fetching LTP of %s: %w", t.Instrument, err)
+		}
+		return t.compare(price), price, nil
+	}
+
+	if now.Before(j.nextCheck) {
+		return false, 0, nil
+	}
+	interval := intervals[t.Interval]
+	j.nextCheck = now.Add(checkInterval(interval))
+
+	period := 1
+	if t.Type == TriggerIndicator {
+		// EMA and RSI are smoothed and settle with a few periods of history.
+		period = t.Period * 3
+	}
+	candles, err := broker.Candles(t.InstrumentToken, t.Interval, now.Add(-lookback(interval, period)), now)
+	if err != nil {
+		return false, 0, fmt.Errorf("error fetching candles of %s: %w", t.Instrument, err)
+	}
+
+	// Only candles completed after the order was scheduled can trigger it,
+	// but older ones still feed indicators.
+	var closes []float64
+	var last time.Time
+	var lastClose float64
+	for _, c := range candles {
+		completedAt := s.candleEnd(t.Exchange(), c.Date.Time, interval)
+		if completedAt.After(now) {
+			break
+		}
+		closes = append(closes, c.Close)
+		last, lastClose = c.Date.Time, c.Close
+		if !completedAt.After(j.CreatedAt) {
+			last = time.Time{}
+		}
+	}
+	if last.IsZero() || !last.After(j.LastCandle) {
+		return false, 0, nil
+	}
+	j.LastCandle = last
+
+	value := lastClose
+	if t.Type == TriggerIndicator {
+		value = indicatorValue(t.Indicator, closes, t.Period)
+		if math.IsNaN(value) {
+			return false, 0, fmt.Errorf("not enough %s candles of %s for %s(%d)", t.Interval, t.Instrument, t.Indicator, t.Period)
+		}
+	}
+	return t.compare(value), value, nil
+}
+
+// candleEnd returns when a candle starting at start completes. Day candles
+// complete when the session closes.
+func (s *Scheduler) candleEnd(exchange string, start time.Time, interval time.Duration) time.Time {
+	if interval < 24*time.Hour {
+		return start.Add(interval)
+	}
+	if _, close, ok := s.calendar.Session(exchange, start); ok {
+		return close
+	}
+	return start.Add(interval)
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "sch_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,524 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeBroker records placed orders and serves LTPs and candles set by tests.
+type fakeBroker struct {
+	mu       sync.Mutex
+	ltp      float64
+	candles  []kiteconnect.HistoricalData
+	placed   []kiteconnect.OrderParams
+	placeErr error
+	fetches  int
+
+	// entered and release, when set, hold PlaceOrder until released
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *fakeBroker) PlaceOrder(_ string, p kiteconnect.OrderParams) (string, error) {
+	if b.release != nil {
+		b.entered <- struct{}{}
+		<-b.release
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.placeErr != nil {
+		return "", b.placeErr
+	}
+	b.placed = append(b.placed, p)
+	return "240102000000001", nil
+}
+
+func (b *fakeBroker) LTP(string) (float64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ltp, nil
+}
+
+func (b *fakeBroker) Candles(_ uint32, _ string, from, to time.Time) ([]kiteconnect.HistoricalData, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fetches++
+	var out []kiteconnect.HistoricalData
+	for _, c := range b.candles {
+		if !c.Date.Before(from) && !c.Date.After(to) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (b *fakeBroker) addCandle(start time.Time, close float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.candles = append(b.candles, kiteconnect.HistoricalData{Date: models.Time{Time: start}, Close: close})
+}
+
+func at(date string, clock string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, ist)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// 2024-01-02 is a Tuesday.
+func newTestScheduler(t *testing.T, path string) (*Scheduler, *fakeClock, *fakeBroker) {
+	t.Helper()
+	clock := &fakeClock{now: at("2024-01-01", "18:00")}
+	broker := &fakeBroker{}
+	cal := calendar.New()
+	if err := cal.Set(calendar.Holidays{Holidays: []calendar.Holiday{{Date: "2024-01-26", Description: "Republic Day"}}}); err != nil {
+		t.Fatalf("Expected no error setting holidays, got %v", err)
+	}
+	s, err := New(Config{
+		Path:     path,
+		Logger:   testLogger(),
+		Clock:    clock,
+		Calendar: cal,
+		Brokers: func(userID string) (Broker, error) {
+			if userID != "AB1234" {
+				return nil, errors.New("no active session")
+			}
+			return broker, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating scheduler, got %v", err)
+	}
+	return s, clock, broker
+}
+
+func buyInfy() Order {
+	return Order{
+		Variety:         "regular",
+		Exchange:        "NSE",
+		Tradingsymbol:   "INFY",
+		TransactionType: "BUY",
+		Product:         "CNC",
+		OrderType:       "MARKET",
+		Quantity:        10,
+	}
+}
+
+func TestTimeTrigger(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+
+	job, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !job.ExpiresAt.Equal(at("2024-01-02", "09:25")) {
+		t.Errorf("Expected default expiry 09:25, got %s", job.ExpiresAt)
+	}
+
+	clock.Set(at("2024-01-02", "09:19"))
+	s.Tick()
+	if len(broker.placed) != 0 {
+		t.Fatalf("Expected no order before the scheduled time, got %d", len(broker.placed))
+	}
+
+	clock.Set(at("2024-01-02", "09:20"))
+	s.Tick()
+	if len(broker.placed) != 1 {
+		t.Fatalf("Expected 1 order at the scheduled time, got %d", len(broker.placed))
+	}
+	if broker.placed[0].Tag != Tag || broker.placed[0].Quantity != 10 {
+		t.Errorf("Unexpected order: %+v", broker.placed[0])
+	}
+
+	jobs := s.List("AB1234")
+	if len(jobs) != 1 || jobs[0].Status != StatusPlaced || jobs[0].OrderID != "240102000000001" {
+		t.Errorf("Expected placed job, got %+v", jobs)
+	}
+
+	// A placed order is never placed again.
+	clock.Advance(time.Minute)
+	s.Tick()
+	if len(broker.placed) != 1 {
+		t.Errorf("Expected 1 order, got %d", len(broker.placed))
+	}
+}
+
+func TestTimeTriggerOutsideMarketHours(t *testing.T) {
+	s, _, _ := newTestScheduler(t, "")
+
+	if _, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-26", "10:00")}, time.Time{}); err == nil {
+		t.Error("Expected error for an order on a holiday")
+	}
+	if _, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "08:00")}, time.Time{}); err == nil {
+		t.Error("Expected error for an order before the open")
+	}
+
+	amo := buyInfy()
+	amo.Variety = "amo"
+	if _, err := s.Schedule("AB1234", amo, Trigger{Type: TriggerTime, At: at("2024-01-02", "08:00")}, time.Time{}); err != nil {
+		t.Errorf("Expected AMO order outside market hours to be accepted, got %v", err)
+	}
+}
+
+func TestTimeTriggerExpiresWithoutSession(t *testing.T) {
+	s, clock, _ := newTestScheduler(t, "")
+
+	job, err := s.Schedule("XY0000", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	clock.Set(at("2024-01-02", "09:20"))
+	s.Tick()
+	jobs := s.List("XY0000")
+	if jobs[0].Status != StatusPending || jobs[0].Error == "" {
+		t.Errorf("Expected pending job with a session error, got %s %q", jobs[0].Status, jobs[0].Error)
+	}
+
+	clock.Set(at("2024-01-02", "09:26"))
+	s.Tick()
+	jobs = s.List("XY0000")
+	if jobs[0].ID != job.ID || jobs[0].Status != StatusExpired {
+		t.Errorf("Expected expired job, got %s", jobs[0].Status)
+	}
+}
+
+func TestLTPTrigger(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+
+	order := buyInfy()
+	order.TransactionType = "SELL"
+	trigger := Trigger{Type: TriggerLTP, Instrument: "NSE:INFY", Operator: Below, Value: 1500}
+	if _, err := s.Schedule("AB1234", order, trigger, time.Time{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Below the level but the market is closed.
+	broker.ltp = 1490
+	s.Tick()
+	if len(broker.placed) != 0 {
+		t.Fatal("Expected no order while the market is closed")
+	}
+
+	clock.Set(at("2024-01-02", "10:00"))
+	broker.ltp = 1510
+	s.Tick()
+	if len(broker.placed) != 0 {
+		t.Fatal("Expected no order above the level")
+	}
+
+	clock.Advance(15 * time.Second)
+	broker.ltp = 1499.5
+	s.Tick()
+	if len(broker.placed) != 1 {
+		t.Fatalf("Expected order once the LTP fell below the level, got %d", len(broker.placed))
+	}
+	if jobs := s.List("AB1234"); jobs[0].TriggerValue != 1499.5 {
+		t.Errorf("Expected trigger value 1499.5, got %v", jobs[0].TriggerValue)
+	}
+}
+
+func TestDailyCloseTriggerWaitsForNextSession(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+	clock.Set(at("2024-01-02", "11:00"))
+
+	order := buyInfy()
+	order.TransactionType = "SELL"
+	trigger := Trigger{Type: TriggerCandleClose, Instrument: "NSE:NIFTY 50", InstrumentToken: 256265, Operator: Below, Value: 22000, Interval: "day"}
+	if _, err := s.Schedule("AB1234", order, trigger, time.Time{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Yesterday's close completed before the order was scheduled.
+	broker.addCandle(at("2024-01-01", "00:00"), 21900)
+	s.Tick()
+	if jobs := s.List("AB1234"); jobs[0].Status != StatusPending {
+		t.Fatalf("Expected old candles to be ignored, got %s", jobs[0].Status)
+	}
+
+	// Today's candle is still forming during the session.
+	clock.Set(at("2024-01-02", "15:00"))
+	broker.addCandle(at("2024-01-02", "00:00"), 21950)
+	s.Tick()
+	if jobs := s.List("AB1234"); jobs[0].Status != StatusPending {
+		t.Fatalf("Expected an incomplete candle to be ignored, got %s", jobs[0].Status)
+	}
+
+	clock.Set(at("2024-01-02", "15:45"))
+	s.Tick()
+	jobs := s.List("AB1234")
+	if jobs[0].Status != StatusTriggered || jobs[0].TriggerValue != 21950 {
+		t.Fatalf("Expected trigger on the daily close, got %s %v", jobs[0].Status, jobs[0].TriggerValue)
+	}
+	if len(broker.placed) != 0 {
+		t.Fatal("Expected the order to wait for the next session")
+	}
+
+	clock.Set(at("2024-01-03", "09:15"))
+	s.Tick()
+	if len(broker.placed) != 1 {
+		t.Errorf("Expected order at the next open, got %d", len(broker.placed))
+	}
+}
+
+func TestIndicatorTrigger(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+	clock.Set(at("2024-01-02", "10:00"))
+
+	start := at("2024-01-02", "09:15")
+	for i := range 9 {
+		broker.addCandle(start.Add(time.Duration(i)*5*time.Minute), 100+float64(i))
+	}
+
+	trigger := Trigger{Type: TriggerIndicator, Instrument: "NSE:INFY", InstrumentToken: 408065, Operator: Above, Value: 106, Interval: "5minute", Indicator: IndicatorSMA, Period: 3}
+	if _, err := s.Schedule("AB1234", buyInfy(), trigger, time.Time{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	s.Tick()
+	if len(broker.placed) != 0 {
+		t.Fatal("Expected candles completed before scheduling not to trigger")
+	}
+
+	// The 10:00 candle completes at 10:05; SMA(3) of 107, 108, 109 = 108.
+	clock.Set(at("2024-01-02", "10:05"))
+	broker.addCandle(at("2024-01-02", "10:00"), 109)
+	s.Tick()
+	jobs := s.List("AB1234")
+	if jobs[0].Status != StatusPlaced || jobs[0].TriggerValue != 108 {
+		t.Errorf("Expected placed order with SMA 108, got %s %v", jobs[0].Status, jobs[0].TriggerValue)
+	}
+}
+
+func TestCandleChecksAreThrottled(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+	clock.Set(at("2024-01-02", "10:00"))
+
+	trigger := Trigger{Type: TriggerCandleClose, Instrument: "NSE:INFY", InstrumentToken: 408065, Operator: Above, Value: 2000, Interval: "15minute"}
+	if _, err := s.Schedule("AB1234", buyInfy(), trigger, time.Time{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	s.Tick()
+	clock.Advance(time.Minute)
+	s.Tick()
+	if broker.fetches != 1 {
+		t.Errorf("Expected 1 candle fetch within the interval, got %d", broker.fetches)
+	}
+	clock.Advance(15 * time.Minute)
+	s.Tick()
+	if broker.fetches != 2 {
+		t.Errorf("Expected another fetch after the interval, got %d", broker.fetches)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+
+	job, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := s.Cancel(job.ID, "XY0000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another user, got %v", err)
+	}
+	cancelled, err := s.Cancel(job.ID, "AB1234")
+	if err != nil || cancelled.Status != StatusCancelled {
+		t.Fatalf("Expected cancelled job, got %s, %v", cancelled.Status, err)
+	}
+	if _, err := s.Cancel(job.ID, "AB1234"); !errors.Is(err, ErrNotActive) {
+		t.Errorf("Expected ErrNotActive, got %v", err)
+	}
+
+	clock.Set(at("2024-01-02", "09:20"))
+	s.Tick()
+	if len(broker.placed) != 0 {
+		t.Error("Expected cancelled order not to be placed")
+	}
+}
+
+func TestCancelWhilePlacing(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+	broker.entered, broker.release = make(chan struct{}), make(chan struct{})
+
+	job, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clock.Set(at("2024-01-02", "09:20"))
+	done := make(chan struct{})
+	go func() {
+		s.Tick()
+		close(done)
+	}()
+	<-broker.entered
+
+	// The scheduler isn't locked while the order is sent
+	if jobs := s.List("AB1234"); len(jobs) != 1 || jobs[0].Status != StatusTriggered {
+		t.Errorf("Expected the triggered job listed while placing, got %+v", jobs)
+	}
+	if _, err := s.Cancel(job.ID, "AB1234"); !errors.Is(err, ErrPlacing) {
+		t.Errorf("Expected ErrPlacing, got %v", err)
+	}
+
+	close(broker.release)
+	<-done
+	if jobs := s.List("AB1234"); jobs[0].Status != StatusPlaced || len(broker.placed) != 1 {
+		t.Errorf("Expected the order placed once, got %s and %d orders", jobs[0].Status, len(broker.placed))
+	}
+	if _, err := s.Cancel(job.ID, "AB1234"); !errors.Is(err, ErrNotActive) {
+		t.Errorf("Expected ErrNotActive once placed, got %v", err)
+	}
+}
+
+func TestPlaceFailure(t *testing.T) {
+	s, clock, broker := newTestScheduler(t, "")
+	broker.placeErr = errors.New("insufficient funds")
+
+	if _, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clock.Set(at("2024-01-02", "09:20"))
+	s.Tick()
+
+	jobs := s.List("AB1234")
+	if jobs[0].Status != StatusFailed || jobs[0].Error != "insufficient funds" {
+		t.Errorf("Expected failed job, got %s %q", jobs[0].Status, jobs[0].Error)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduled.json")
+	s, _, _ := newTestScheduler(t, path)
+
+	job, err := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	restored, clock, broker := newTestScheduler(t, path)
+	jobs := restored.List("AB1234")
+	if len(jobs) != 1 || jobs[0].ID != job.ID || jobs[0].Status != StatusPending {
+		t.Fatalf("Expected restored pending job, got %+v", jobs)
+	}
+
+	clock.Set(at("2024-01-02", "09:20"))
+	restored.Tick()
+	if len(broker.placed) != 1 {
+		t.Errorf("Expected restored job to be placed, got %d orders", len(broker.placed))
+	}
+}
+
+func TestRetention(t *testing.T) {
+	s, clock, _ := newTestScheduler(t, "")
+
+	job, _ := s.Schedule("AB1234", buyInfy(), Trigger{Type: TriggerTime, At: at("2024-01-02", "09:20")}, time.Time{})
+	if _, err := s.Cancel(job.ID, "AB1234"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	clock.Set(job.ExpiresAt.Add(DefaultRetention + time.Hour))
+	s.Tick()
+	if jobs := s.List("AB1234"); len(jobs) != 0 {
+		t.Errorf("Expected finished job to be pruned, got %d", len(jobs))
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+	s, _, _ := newTestScheduler(t, "")
+	trigger := Trigger{Type: TriggerLTP, Instrument: "NSE:INFY", Operator: Above, Value: 2000}
+
+	for range MaxPendingPerUser {
+		if _, err := s.Schedule("AB1234", buyInfy(), trigger, time.Time{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := s.Schedule("AB1234", buyInfy(), trigger, time.Time{}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestTriggerValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger Trigger
+		ok      bool
+	}{
+		{"time", Trigger{Type: TriggerTime, At: time.Now()}, true},
+		{"time without at", Trigger{Type: TriggerTime}, false},
+		{"ltp", Trigger{Type: TriggerLTP, Instrument: "NSE:INFY", Operator: Above, Value: 1}, true},
+		{"ltp without exchange", Trigger{Type: TriggerLTP, Instrument: "INFY", Operator: Above, Value: 1}, false},
+		{"bad operator", Trigger{Type: TriggerLTP, Instrument: "NSE:INFY", Operator: "equals", Value: 1}, false},
+		{"candle without token", Trigger{Type: TriggerCandleClose, Instrument: "NSE:INFY", Operator: Above, Value: 1, Interval: "day"}, false},
+		{"candle bad interval", Trigger{Type: TriggerCandleClose, Instrument: "NSE:INFY", InstrumentToken: 1, Operator: Above, Value: 1, Interval: "week"}, false},
+		{"rsi", Trigger{Type: TriggerIndicator, Instrument: "NSE:INFY", InstrumentToken: 1, Operator: Below, Value: 30, Interval: "day", Indicator: IndicatorRSI, Period: 14}, true},
+		{"rsi bad period", Trigger{Type: TriggerIndicator, Instrument: "NSE:INFY", InstrumentToken: 1, Operator: Below, Value: 30, Interval: "day", Indicator: IndicatorRSI, Period: 1}, false},
+		{"unknown", Trigger{Type: "moon"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.trigger.Validate()
+			if tt.ok && err != nil {
+				t.Errorf("Expected valid trigger, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestIndicators(t *testing.T) {
+	closes := []float64{44, 44.5, 44.25, 43.75, 44.5, 45, 45.5, 46}
+
+	if got := sma(closes, 4); got != 45.25 {
+		t.Errorf("Expected SMA 45.25, got %v", got)
+	}
+	if got := ema([]float64{1, 2, 3, 4}, 2); got != 3.5 {
+		t.Errorf("Expected EMA 3.5, got %v", got)
+	}
+	if got := rsi([]float64{1, 2, 3, 4, 5}, 3); got != 100 {
+		t.Errorf("Expected RSI 100 for a steady rise, got %v", got)
+	}
+	if got := rsi([]float64{5, 4, 3, 2, 1}, 3); got != 0 {
+		t.Errorf("Expected RSI 0 for a steady fall, got %v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// TriggerType selects what releases a scheduled order.
+type TriggerType string
+
+const (
+	TriggerTime        TriggerType = "time"         // at a point in time
+	TriggerLTP         TriggerType = "ltp"          // last traded price crosses a level
+	TriggerCandleClose TriggerType = "candle_close" // a completed candle closes beyond a level
+	TriggerIndicator   TriggerType = "indicator"    // an indicator on completed candles crosses a level
+)
+
+// Comparison operators of condition triggers.
+const (
+	Above = "above"
+	Below = "below"
+)
+
+// Indicators supported by indicator triggers.
+const (
+	IndicatorSMA = "sma"
+	IndicatorEMA = "ema"
+	IndicatorRSI = "rsi"
+)
+
+// intervals maps Kite candle intervals to their length. Day candles span
+// the whole session.
+var intervals = map[string]time.Duration{
+	"minute":   time.Minute,
+	"3minute":  3 * time.Minute,
+	"5minute":  5 * time.Minute,
+	"10minute": 10 * time.Minute,
+	"15minute": 15 * time.Minute,
+	"30minute": 30 * time.Minute,
+	"60minute": time.Hour,
+	"day":      24 * time.Hour,
+}
+
+// Trigger is the condition that releases a scheduled order. Condition
+// triggers watch Instrument ("EXCHANGE:TRADINGSYMBOL"); candle and indicator
+// triggers also need its InstrumentToken.
+type Trigger struct {
+	Type            TriggerType `json:"type"`
+	At              time.Time   `json:"at,omitzero"`
+	Instrument      string      `json:"instrument,omitempty"`
+	InstrumentToken uint32      `json:"instrument_token,omitempty"`
+	Operator        string      `json:"operator,omitempty"`
+	Value           float64     `json:"value,omitempty"`
+	Interval        string      `json:"interval,omitempty"`
+	Indicator       string      `json:"indicator,omitempty"`
+	Period          int         `json:"period,omitempty"`
+}
+
+// Exchange returns the exchange of the watched instrument.
+func (t Trigger) Exchange() string {
+	exchange, _, _ := strings.Cut(t.Instrument, ":")
+	return exchange
+}
+
+// Validate checks that the trigger has the fields its type needs.
+func (t Trigger) Validate() error {
+	switch t.Type {
+	case TriggerTime:
+		if t.At.IsZero() {
+			return errors.New("time trigger needs a time")
+		}
+		return nil
+	case TriggerLTP, TriggerCandleClose, TriggerIndicator:
+	default:
+		return fmt.Errorf("unknown trigger type %q", t.Type)
+	}
+
+	if !strings.Contains(t.Instrument, ":") {
+		return errors.New("condition trigger needs an instrument as EXCHANGE:TRADINGSYMBOL")
+	}
+	if t.Operator != Above && t.Operator != Below {
+		return fmt.Errorf("operator must be %q or %q", Above, Below)
+	}
+	if t.Type == TriggerLTP {
+		if t.Value <= 0 {
+			return errors.New("price level must be positive")
+		}
+		return nil
+	}
+
+	if t.InstrumentToken == 0 {
+		return fmt.Errorf("unknown instrument %s", t.Instrument)
+	}
+	if _, ok := intervals[t.Interval]; !ok {
+		return fmt.Errorf("unsupported candle interval %q", t.Interval)
+	}
+	if t.Type == TriggerIndicator {
+		switch t.Indicator {
+		case IndicatorSMA, IndicatorEMA, IndicatorRSI:
+		default:
+			return fmt.Errorf("unsupported indicator %q", t.Indicator)
+		}
+		if t.Period < 2 || t.Period > 200 {
+			return errors.New("indicator period must be between 2 and 200")
+		}
+	}
+	return nil
+}
+
+// compare applies the trigger's operator.
+func (t Trigger) compare(v float64) bool {
+	if t.Operator == Above {
+		return v > t.Value
+	}
+	return v < t.Value
+}
+
+// checkInterval is how often a candle trigger fetches candles. Fetching
+// more often than a candle completes would only hit the historical data
+// rate limits.
+func checkInterval(interval time.Duration) time.Duration {
+	return min(interval, 15*time.Minute)
+}
+
+// lookback returns how far back to fetch candles so that an indicator of
+// period has enough history, allowing for nights, weekends and holidays.
+func lookback(interval time.Duration, period int) time.Duration {
+	if interval >= 24*time.Hour {
+		return time.Duration(period*2+30) * 24 * time.Hour
+	}
+	return max(time.Duration(period*4)*interval, 5*24*time.Hour)
+}
+
+func sma(closes []float64, period int) float64 {
+	if len(closes) < period {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, c := range closes[len(closes)-period:] {
+		sum += c
+	}
+	return sum / float64(period)
+}
+
+func ema(closes []float64, period int) float64 {
+	if len(closes) < period {
+		return math.NaN()
+	}
+	k := 2 / float64(period+1)
+	value := sma(closes[:period], period)
+	for _, c := range closes[period:] {
+		value = c*k + value*(1-k)
+	}
+	return value
+}
+
+// rsi is Wilder's relative strength index.
+func rsi(closes []float64, period int) float64 {
+	if len(closes) <= period {
+		return math.NaN()
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		g, l := 0.0, 0.0
+		if change > 0 {
+			g = change
+		} else {
+			l = -change
+		}
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+	}
+
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+func indicatorValue(indicator string, closes []float64, period int) float64 {
+	switch indicator {
+	case IndicatorSMA:
+		return sma(closes, period)
+	case IndicatorEMA:
+		return ema(closes, period)
+	case IndicatorRSI:
+		return rsi(closes, period)
+	}
+	return math.NaN()
+}
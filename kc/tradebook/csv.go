@@ -0,0 +1,249 @@
+package tradebook
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format identifies the kind of Console export being imported.
+type Format string
+
+const (
+	FormatAuto      Format = "auto"
+	FormatTradebook Format = "tradebook"
+	FormatPnL       Format = "pnl"
+)
+
+// Batch is the parsed content of a single CSV export.
+type Batch struct {
+	Format Format
+	Trades []Trade
+	PnL    []PnLEntry
+}
+
+// Parse reads a Console CSV export. With FormatAuto the format is detected
+// from the header row. financialYear is only used for P&L statements, which
+// do not carry trade dates.
+func Parse(r io.Reader, format Format, financialYear string) (Batch, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // P&L statements have preamble rows of varying width
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return Batch{}, fmt.Errorf("error reading CSV: %w", err)
+	}
+
+	// Console P&L statements start with a few rows of account details, so
+	// scan for the first row that looks like a header.
+	for i, row := range records {
+		header := normalizeHeader(row)
+		detected := detectFormat(header)
+		if detected == "" {
+			continue
+		}
+		if format != FormatAuto && format != "" && format != detected {
+			return Batch{}, fmt.Errorf("expected %s export, found %s", format, detected)
+		}
+
+		switch detected {
+		case FormatTradebook:
+			trades, err := parseTradebookRows(header, records[i+1:])
+			if err != nil {
+				return Batch{}, err
+			}
+			return Batch{Format: FormatTradebook, Trades: trades}, nil
+		case FormatPnL:
+			if _, _, err := ParseFinancialYear(financialYear); err != nil {
+				return Batch{}, fmt.Errorf("P&L statements need a financial year: %w", err)
+			}
+			entries, err := parsePnLRows(header, records[i+1:], financialYear)
+			if err != nil {
+				return Batch{}, err
+			}
+			return Batch{Format: FormatPnL, PnL: entries}, nil
+		}
+	}
+
+	return Batch{}, ErrUnknownFormat
+}
+
+// normalizeHeader lowercases header cells and maps them onto snake_case keys.
+func normalizeHeader(row []string) map[string]int {
+	out := make(map[string]int, len(row))
+	replacer := strings.NewReplacer(" ", "_", "&", "n", ".", "", "-", "_")
+	for i, cell := range row {
+		key := replacer.Replace(strings.ToLower(strings.TrimSpace(cell)))
+		if key != "" {
+			out[key] = i
+		}
+	}
+	return out
+}
+
+func detectFormat(header map[string]int) Format {
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if _, ok := header[k]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case has("symbol", "trade_type", "quantity", "price", "trade_id"):
+		return FormatTradebook
+	case has("symbol", "buy_value", "sell_value", "realized_pnl"):
+		return FormatPnL
+	default:
+		return ""
+	}
+}
+
+func cell(row []string, header map[string]int, key string) string {
+	i, ok := header[key]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+func parseNumber(s string) (float64, error) {
+	s = strings.ReplaceAll(s, ",", "")
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseTradebookRows(header map[string]int, rows [][]string) ([]Trade, error) {
+	trades := make([]Trade, 0, len(rows))
+	for n, row := range rows {
+		if isBlank(row) {
+			continue
+		}
+		line := n + 2 // 1-based, after the header
+
+		qty, err := parseNumber(cell(row, header, "quantity"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity: %w", line, err)
+		}
+		price, err := parseNumber(cell(row, header, "price"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid price: %w", line, err)
+		}
+
+		tradeType := strings.ToLower(cell(row, header, "trade_type"))
+		if tradeType != TradeTypeBuy && tradeType != TradeTypeSell {
+			return nil, fmt.Errorf("line %d: invalid trade_type %q", line, tradeType)
+		}
+
+		tradeDate, err := parseDate(cell(row, header, "trade_date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid trade_date: %w", line, err)
+		}
+		execTime, err := parseDateTime(cell(row, header, "order_execution_time"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid order_execution_time: %w", line, err)
+		}
+		if tradeDate.IsZero() {
+			tradeDate = execTime
+		}
+		if tradeDate.IsZero() {
+			return nil, fmt.Errorf("line %d: missing trade_date", line)
+		}
+
+		trades = append(trades, Trade{
+			TradeID:       cell(row, header, "trade_id"),
+			OrderID:       cell(row, header, "order_id"),
+			Symbol:        strings.ToUpper(cell(row, header, "symbol")),
+			ISIN:          strings.ToUpper(cell(row, header, "isin")),
+			Exchange:      strings.ToUpper(cell(row, header, "exchange")),
+			Segment:       strings.ToUpper(cell(row, header, "segment")),
+			Series:        strings.ToUpper(cell(row, header, "series")),
+			TradeType:     tradeType,
+			Quantity:      qty,
+			Price:         price,
+			TradeDate:     tradeDate,
+			ExecutionTime: execTime,
+		})
+	}
+	return trades, nil
+}
+
+func parsePnLRows(header map[string]int, rows [][]string, financialYear string) ([]PnLEntry, error) {
+	entries := make([]PnLEntry, 0, len(rows))
+	for n, row := range rows {
+		symbol := cell(row, header, "symbol")
+		if isBlank(row) || symbol == "" {
+			// P&L statements end with a blank row followed by totals.
+			if len(entries) > 0 {
+				break
+			}
+			continue
+		}
+		line := n + 2
+
+		var values [4]float64
+		for i, key := range []string{"quantity", "buy_value", "sell_value", "realized_pnl"} {
+			v, err := parseNumber(cell(row, header, key))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", line, key, err)
+			}
+			values[i] = v
+		}
+
+		entries = append(entries, PnLEntry{
+			Symbol:        strings.ToUpper(symbol),
+			ISIN:          strings.ToUpper(cell(row, header, "isin")),
+			Quantity:      values[0],
+			BuyValue:      values[1],
+			SellValue:     values[2],
+			RealizedPnL:   values[3],
+			FinancialYear: financialYear,
+		})
+	}
+	return entries, nil
+}
+
+func isBlank(row []string) bool {
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+var dateLayouts = []string{"2006-01-02", "02-01-2006", "02/01/2006"}
+
+var dateTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "02-01-2006 15:04:05"}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, ist); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", s)
+}
+
+func parseDateTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, ist); err == nil {
+			return t, nil
+		}
+	}
+	return parseDate(s)
+}
@@ -0,0 +1,102 @@
+package tradebook
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTradebookCSV = `symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time
+INFY,INE009A01021,2023-05-10,NSE,EQ,EQ,buy,false,10.000000,1250.50,1001,2001,2023-05-10T09:20:11
+INFY,INE009A01021,2024-08-12,BSE,EQ,A,sell,false,4.000000,1800.00,1002,2002,2024-08-12T10:01:45
+NIFTY24AUG24500CE,,2024-08-01,NFO,FO,,sell,false,50.000000,120.00,1003,2003,2024-08-01T11:00:00
+`
+
+const testPnLCSV = `Client ID,AB1234
+,
+P&L Statement for Equity from 2023-04-01 to 2024-03-31
+,
+Symbol,ISIN,Quantity,Buy Value,Sell Value,Realized P&L,Realized P&L Pct.,Previous Closing Price
+INFY,INE009A01021,10,"12,505.00","18,000.00","5,495.00",43.94,1500
+TCS,INE467B01029,5,17000,16000,-1000,-5.88,3400
+,
+Total,,,,,"4,495.00",,
+`
+
+func TestParseTradebook(t *testing.T) {
+	batch, err := Parse(strings.NewReader(testTradebookCSV), FormatAuto, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if batch.Format != FormatTradebook {
+		t.Errorf("Expected tradebook format, got %s", batch.Format)
+	}
+	if len(batch.Trades) != 3 {
+		t.Fatalf("Expected 3 trades, got %d", len(batch.Trades))
+	}
+
+	first := batch.Trades[0]
+	if first.Symbol != "INFY" || first.ISIN != "INE009A01021" || !first.IsBuy() || !first.IsEquity() {
+		t.Errorf("Unexpected first trade: %+v", first)
+	}
+	if first.Quantity != 10 || first.Price != 1250.50 {
+		t.Errorf("Unexpected quantity/price: %v/%v", first.Quantity, first.Price)
+	}
+	if first.ExecutionTime.Hour() != 9 || first.ExecutionTime.Minute() != 20 {
+		t.Errorf("Expected execution time in IST, got %v", first.ExecutionTime)
+	}
+
+	fno := batch.Trades[2]
+	if fno.IsEquity() {
+		t.Error("Expected F&O trade not to be classified as equity")
+	}
+	if fno.Key() != "NFO:NIFTY24AUG24500CE" {
+		t.Errorf("Expected derivative key to fall back to exchange:symbol, got %s", fno.Key())
+	}
+}
+
+func TestParsePnLStatement(t *testing.T) {
+	if _, err := Parse(strings.NewReader(testPnLCSV), FormatAuto, ""); err == nil {
+		t.Error("Expected error when financial year is missing for a P&L statement")
+	}
+
+	batch, err := Parse(strings.NewReader(testPnLCSV), FormatAuto, "2023-24")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if batch.Format != FormatPnL {
+		t.Errorf("Expected pnl format, got %s", batch.Format)
+	}
+	if len(batch.PnL) != 2 {
+		t.Fatalf("Expected 2 entries (totals row skipped), got %d", len(batch.PnL))
+	}
+	if batch.PnL[0].RealizedPnL != 5495 || batch.PnL[0].BuyValue != 12505 {
+		t.Errorf("Expected thousands separators to be parsed, got %+v", batch.PnL[0])
+	}
+	if batch.PnL[1].RealizedPnL != -1000 || batch.PnL[1].FinancialYear != "2023-24" {
+		t.Errorf("Unexpected second entry: %+v", batch.PnL[1])
+	}
+}
+
+func TestParseFormatMismatch(t *testing.T) {
+	if _, err := Parse(strings.NewReader(testTradebookCSV), FormatPnL, "2023-24"); err == nil {
+		t.Error("Expected error when forcing pnl format on a tradebook")
+	}
+	if _, err := Parse(strings.NewReader("a,b,c\n1,2,3\n"), FormatAuto, ""); err != ErrUnknownFormat {
+		t.Errorf("Expected ErrUnknownFormat, got: %v", err)
+	}
+}
+
+func TestParseInvalidRows(t *testing.T) {
+	bad := `symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time
+INFY,INE009A01021,2023-05-10,NSE,EQ,EQ,hold,false,10,1250.50,1001,2001,2023-05-10T09:20:11
+`
+	if _, err := Parse(strings.NewReader(bad), FormatAuto, ""); err == nil {
+		t.Error("Expected error for invalid trade_type")
+	}
+
+	bad = strings.Replace(bad, "hold", "buy", 1)
+	bad = strings.Replace(bad, "1250.50", "abc", 1)
+	if _, err := Parse(strings.NewReader(bad), FormatAuto, ""); err == nil {
+		t.Error("Expected error for invalid price")
+	}
+}
@@ -0,0 +1,256 @@
+package tradebook
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Holding terms reported by the harvester.
+const (
+	TermShort   = "short_term"
+	TermLong    = "long_term"
+	TermUnknown = "unknown"
+)
+
+// Re-entry risk flags raised on harvest candidates.
+const (
+	// FlagRecentPurchase means the security was bought within the wash window,
+	// so selling now looks like a round trip rather than an exit.
+	FlagRecentPurchase = "recent_purchase"
+	// FlagRepeatLossBooking means a loss on the security was already booked
+	// within the wash window and the position was bought back.
+	FlagRepeatLossBooking = "repeat_loss_booking"
+	// FlagTurnsLongTermSoon means the lot becomes long-term within the wash
+	// window; waiting may move the loss into the long-term bucket instead.
+	FlagTurnsLongTermSoon = "turns_long_term_soon"
+	// FlagUntrackedQuantity means part of the holding has no imported buy
+	// trades, so its acquisition date and term are unknown.
+	FlagUntrackedQuantity = "untracked_quantity"
+)
+
+// HoldingSnapshot is the current state of a demat holding.
+type HoldingSnapshot struct {
+	ISIN         string
+	Symbol       string
+	Exchange     string
+	Quantity     float64
+	AveragePrice float64
+	LastPrice    float64
+}
+
+// HarvestLot is an open lot currently trading below its cost.
+type HarvestLot struct {
+	Symbol           string    `json:"symbol"`
+	ISIN             string    `json:"isin,omitempty"`
+	Exchange         string    `json:"exchange"`
+	Quantity         float64   `json:"quantity"`
+	BuyPrice         float64   `json:"buy_price"`
+	LastPrice        float64   `json:"last_price"`
+	UnrealizedLoss   float64   `json:"unrealized_loss"`
+	AcquiredAt       time.Time `json:"acquired_at,omitzero"`
+	HoldingDays      int       `json:"holding_days,omitempty"`
+	Term             string    `json:"term"`
+	DaysToLongTerm   int       `json:"days_to_long_term,omitempty"`
+	ReentryRiskFlags []string  `json:"reentry_risk_flags,omitempty"`
+}
+
+// HarvestPlan summarises the harvestable losses and their tax effect.
+type HarvestPlan struct {
+	FinancialYear     string       `json:"financial_year"`
+	RealizedSTCG      float64      `json:"realized_stcg"`
+	RealizedLTCG      float64      `json:"realized_ltcg"`
+	ShortTermLoss     float64      `json:"short_term_harvestable_loss"`
+	LongTermLoss      float64      `json:"long_term_harvestable_loss"`
+	UnknownTermLoss   float64      `json:"unknown_term_harvestable_loss"`
+	TaxBefore         float64      `json:"estimated_tax_before"`
+	TaxAfter          float64      `json:"estimated_tax_after"`
+	TaxSaved          float64      `json:"estimated_tax_saved"`
+	ShortTermTaxSaved float64      `json:"short_term_tax_saved"`
+	LongTermTaxSaved  float64      `json:"long_term_tax_saved"`
+	ShortTerm         []HarvestLot `json:"short_term"`
+	LongTerm          []HarvestLot `json:"long_term"`
+	UnknownTerm       []HarvestLot `json:"unknown_term,omitempty"`
+}
+
+// HarvestOptions tunes candidate selection.
+type HarvestOptions struct {
+	AsOf       time.Time
+	WashWindow time.Duration // look-back/ahead for re-entry flags
+	MinLoss    float64       // ignore lots losing less than this (absolute)
+}
+
+// PlanHarvest matches current holdings to the ledger's open lots and lists
+// the lots trading below cost. Lots are matched by ISIN; when the imported
+// lots exceed the holding (sold outside the imported range) the oldest are
+// dropped first, and a holding larger than its lots is reported as an
+// untracked lot at the average price. Tax saved is estimated against the
+// equity gains realized so far in the financial year of opts.AsOf.
+func PlanHarvest(ledger Ledger, holdings []HoldingSnapshot, opts HarvestOptions) HarvestPlan {
+	asOf := opts.AsOf
+	fy := FinancialYearOf(asOf)
+	fyStart, _, _ := ParseFinancialYear(fy)
+
+	plan := HarvestPlan{FinancialYear: fy}
+	ytd := FilterRealized(ledger.Realized, RealizedFilter{From: fyStart, To: asOf.Add(time.Nanosecond)})
+	summary := Summarize(ytd, asOf)
+	plan.RealizedSTCG = summary.Net(CategorySTCG)
+	plan.RealizedLTCG = summary.Net(CategoryLTCG)
+
+	lotsByKey := ledger.OpenLotsByKey()
+	for _, h := range holdings {
+		if h.Quantity <= 0 || h.LastPrice <= 0 {
+			continue
+		}
+		flags := reentryFlags(ledger, h.ISIN, asOf, opts.WashWindow)
+
+		tracked := trimToQuantity(lotsByKey[h.ISIN], h.Quantity)
+		var trackedQty float64
+		for _, lot := range tracked {
+			trackedQty += lot.Quantity
+			if c, ok := harvestLot(h, lot.Quantity, lot.Price, lot.AcquiredAt, asOf, opts, flags); ok {
+				plan.add(c)
+			}
+		}
+
+		if untracked := h.Quantity - trackedQty; untracked > quantityEpsilon {
+			untrackedFlags := append(append([]string{}, flags...), FlagUntrackedQuantity)
+			if c, ok := harvestLot(h, untracked, h.AveragePrice, time.Time{}, asOf, opts, untrackedFlags); ok {
+				plan.add(c)
+			}
+		}
+	}
+
+	sortByLoss(plan.ShortTerm)
+	sortByLoss(plan.LongTerm)
+	sortByLoss(plan.UnknownTerm)
+
+	plan.TaxBefore = EstimateCapitalGainsTax(plan.RealizedSTCG, plan.RealizedLTCG, asOf)
+	plan.TaxAfter = EstimateCapitalGainsTax(plan.RealizedSTCG-plan.ShortTermLoss, plan.RealizedLTCG-plan.LongTermLoss, asOf)
+	plan.TaxSaved = plan.TaxBefore - plan.TaxAfter
+	plan.ShortTermTaxSaved = plan.TaxBefore - EstimateCapitalGainsTax(plan.RealizedSTCG-plan.ShortTermLoss, plan.RealizedLTCG, asOf)
+	plan.LongTermTaxSaved = plan.TaxBefore - EstimateCapitalGainsTax(plan.RealizedSTCG, plan.RealizedLTCG-plan.LongTermLoss, asOf)
+	return plan
+}
+
+func (p *HarvestPlan) add(c HarvestLot) {
+	switch c.Term {
+	case TermShort:
+		p.ShortTerm = append(p.ShortTerm, c)
+		p.ShortTermLoss += c.UnrealizedLoss
+	case TermLong:
+		p.LongTerm = append(p.LongTerm, c)
+		p.LongTermLoss += c.UnrealizedLoss
+	default:
+		p.UnknownTerm = append(p.UnknownTerm, c)
+		p.UnknownTermLoss += c.UnrealizedLoss
+	}
+}
+
+// harvestLot builds a candidate if the lot is trading at a loss of at least
+// opts.MinLoss. Losses are reported as positive amounts.
+func harvestLot(h HoldingSnapshot, qty, price float64, acquiredAt, asOf time.Time, opts HarvestOptions, flags []string) (HarvestLot, bool) {
+	loss := (price - h.LastPrice) * qty
+	if loss <= 0 || loss < opts.MinLoss {
+		return HarvestLot{}, false
+	}
+
+	c := HarvestLot{
+		Symbol:         h.Symbol,
+		ISIN:           h.ISIN,
+		Exchange:       h.Exchange,
+		Quantity:       qty,
+		BuyPrice:       price,
+		LastPrice:      h.LastPrice,
+		UnrealizedLoss: loss,
+		AcquiredAt:     acquiredAt,
+		Term:           TermUnknown,
+	}
+	if !acquiredAt.IsZero() {
+		lot := Lot{AcquiredAt: acquiredAt}
+		c.HoldingDays = lot.HoldingDays(asOf)
+		if lot.IsLongTerm(asOf) {
+			c.Term = TermLong
+		} else {
+			c.Term = TermShort
+			longTermAt := acquiredAt.AddDate(1, 0, 0)
+			c.DaysToLongTerm = int(math.Ceil(longTermAt.Sub(asOf).Hours() / 24))
+			if opts.WashWindow > 0 && longTermAt.Sub(asOf) <= opts.WashWindow {
+				flags = append(append([]string{}, flags...), FlagTurnsLongTermSoon)
+			}
+		}
+	}
+	c.ReentryRiskFlags = flags
+	return c, true
+}
+
+// trimToQuantity drops the oldest lots until they add up to at most qty.
+func trimToQuantity(lots []Lot, qty float64) []Lot {
+	var total float64
+	for _, lot := range lots {
+		if lot.Quantity > 0 {
+			total += lot.Quantity
+		}
+	}
+
+	excess := total - qty
+	out := make([]Lot, 0, len(lots))
+	for _, lot := range lots {
+		if lot.Quantity <= 0 {
+			continue
+		}
+		if excess > quantityEpsilon {
+			drop := math.Min(excess, lot.Quantity)
+			excess -= drop
+			lot.Quantity -= drop
+			if lot.Quantity <= quantityEpsilon {
+				continue
+			}
+		}
+		out = append(out, lot)
+	}
+	return out
+}
+
+// reentryFlags looks for trading patterns on the security within the wash
+// window before asOf that make booking a loss now look like a sham round trip.
+func reentryFlags(ledger Ledger, isin string, asOf time.Time, window time.Duration) []string {
+	if window <= 0 || isin == "" {
+		return nil
+	}
+	since := asOf.Add(-window)
+
+	var flags []string
+	var lastLoss time.Time
+	for _, g := range ledger.Realized {
+		if g.ISIN == isin && g.PnL < 0 && !g.CloseDate.Before(since) && g.CloseDate.After(lastLoss) {
+			lastLoss = g.CloseDate
+		}
+	}
+
+	recentBuy := false
+	repeat := false
+	for _, lot := range ledger.Open {
+		if lot.ISIN != isin || lot.Quantity <= 0 || lot.AcquiredAt.Before(since) {
+			continue
+		}
+		recentBuy = true
+		if !lastLoss.IsZero() && !lot.AcquiredAt.Before(lastLoss) {
+			repeat = true
+		}
+	}
+
+	if recentBuy {
+		flags = append(flags, FlagRecentPurchase)
+	}
+	if repeat {
+		flags = append(flags, FlagRepeatLossBooking)
+	}
+	return flags
+}
+
+func sortByLoss(lots []HarvestLot) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		return lots[i].UnrealizedLoss > lots[j].UnrealizedLoss
+	})
+}
@@ -0,0 +1,102 @@
+package tradebook
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlanHarvest(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeBuy, 10, 200, "2023-05-10 10:00"), // long-term by Feb 2025
+		equityTrade("2", TradeTypeBuy, 10, 150, "2024-12-01 10:00"), // short-term
+		equityTrade("3", TradeTypeBuy, 10, 90, "2024-12-02 10:00"),  // in profit
+	}
+	tcs := Trade{
+		TradeID: "4", Symbol: "TCS", ISIN: "INE467B01029", Exchange: "NSE", Segment: "EQ",
+		TradeType: TradeTypeBuy, Quantity: 5, Price: 3000, ExecutionTime: at("2024-06-01 10:00"),
+	}
+	tcsSell := tcs
+	tcsSell.TradeID, tcsSell.TradeType, tcsSell.Price, tcsSell.ExecutionTime = "5", TradeTypeSell, 4000, at("2024-09-01 10:00")
+	trades = append(trades, tcs, tcsSell)
+
+	holdings := []HoldingSnapshot{
+		// 5 more than the tradebook knows about.
+		{ISIN: "INE009A01021", Symbol: "INFY", Exchange: "NSE", Quantity: 35, AveragePrice: 160, LastPrice: 100},
+	}
+
+	plan := PlanHarvest(BuildLedger(trades), holdings, HarvestOptions{AsOf: at("2025-02-15 10:00")})
+
+	if plan.FinancialYear != "2024-25" {
+		t.Errorf("Expected FY 2024-25, got %s", plan.FinancialYear)
+	}
+	if plan.RealizedSTCG != 5000 {
+		t.Errorf("Expected realized STCG of 5000, got %v", plan.RealizedSTCG)
+	}
+
+	if len(plan.LongTerm) != 1 || plan.LongTerm[0].UnrealizedLoss != 1000 {
+		t.Errorf("Expected one long-term candidate losing 1000, got %+v", plan.LongTerm)
+	}
+	if len(plan.ShortTerm) != 1 || plan.ShortTerm[0].UnrealizedLoss != 500 {
+		t.Errorf("Expected one short-term candidate losing 500, got %+v", plan.ShortTerm)
+	}
+	if len(plan.UnknownTerm) != 1 || plan.UnknownTerm[0].Quantity != 5 ||
+		!slices.Contains(plan.UnknownTerm[0].ReentryRiskFlags, FlagUntrackedQuantity) {
+		t.Errorf("Expected 5 untracked units, got %+v", plan.UnknownTerm)
+	}
+
+	// Short-term loss offsets STCG at 20%; long-term loss cannot offset STCG.
+	if !approxEqual(plan.ShortTermTaxSaved, 100) || !approxEqual(plan.LongTermTaxSaved, 0) || !approxEqual(plan.TaxSaved, 100) {
+		t.Errorf("Unexpected tax saved: st=%v lt=%v total=%v", plan.ShortTermTaxSaved, plan.LongTermTaxSaved, plan.TaxSaved)
+	}
+}
+
+func TestPlanHarvestTrimsSoldLots(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeBuy, 10, 200, "2024-05-10 10:00"),
+		equityTrade("2", TradeTypeBuy, 10, 150, "2024-06-10 10:00"),
+	}
+	// Only 10 still held: the oldest lot was sold outside the imported range.
+	holdings := []HoldingSnapshot{{ISIN: "INE009A01021", Symbol: "INFY", Quantity: 10, AveragePrice: 150, LastPrice: 100}}
+
+	plan := PlanHarvest(BuildLedger(trades), holdings, HarvestOptions{AsOf: at("2024-12-01 10:00")})
+	if len(plan.ShortTerm) != 1 || plan.ShortTerm[0].BuyPrice != 150 {
+		t.Errorf("Expected only the newer lot to remain, got %+v", plan.ShortTerm)
+	}
+}
+
+func TestPlanHarvestReentryFlags(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeBuy, 10, 200, "2024-01-20 10:00"),
+		equityTrade("2", TradeTypeSell, 10, 150, "2024-12-20 10:00"),
+		equityTrade("3", TradeTypeBuy, 10, 140, "2024-12-23 10:00"),
+	}
+	holdings := []HoldingSnapshot{{ISIN: "INE009A01021", Symbol: "INFY", Quantity: 10, AveragePrice: 140, LastPrice: 120}}
+
+	plan := PlanHarvest(BuildLedger(trades), holdings, HarvestOptions{
+		AsOf:       at("2025-01-05 10:00"),
+		WashWindow: 30 * 24 * time.Hour,
+	})
+	if len(plan.ShortTerm) != 1 {
+		t.Fatalf("Expected one short-term candidate, got %+v", plan.ShortTerm)
+	}
+	flags := plan.ShortTerm[0].ReentryRiskFlags
+	if !slices.Contains(flags, FlagRecentPurchase) || !slices.Contains(flags, FlagRepeatLossBooking) {
+		t.Errorf("Expected recent purchase and repeat loss flags, got %v", flags)
+	}
+
+	// A lot close to turning long-term is flagged.
+	trades = []Trade{equityTrade("1", TradeTypeBuy, 10, 200, "2024-01-20 10:00")}
+	holdings[0].AveragePrice = 200
+	plan = PlanHarvest(BuildLedger(trades), holdings, HarvestOptions{
+		AsOf:       at("2025-01-05 10:00"),
+		WashWindow: 30 * 24 * time.Hour,
+		MinLoss:    100,
+	})
+	if len(plan.ShortTerm) != 1 || !slices.Contains(plan.ShortTerm[0].ReentryRiskFlags, FlagTurnsLongTermSoon) {
+		t.Errorf("Expected turns-long-term flag, got %+v", plan.ShortTerm)
+	}
+	if plan.ShortTerm[0].DaysToLongTerm != 15 {
+		t.Errorf("Expected 15 days to long-term, got %d", plan.ShortTerm[0].DaysToLongTerm)
+	}
+}
@@ -0,0 +1,323 @@
+package tradebook
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Category is the Indian income-tax head a realized gain falls under.
+type Category string
+
+const (
+	// CategoryIntraday is speculative business income from equity bought and
+	// sold on the same day.
+	CategoryIntraday Category = "intraday"
+	// CategorySTCG is short-term capital gain on equity held for 12 months or less.
+	CategorySTCG Category = "stcg"
+	// CategoryLTCG is long-term capital gain on equity held for more than 12 months.
+	CategoryLTCG Category = "ltcg"
+	// CategoryFNO is non-speculative business income from derivatives.
+	CategoryFNO Category = "fno"
+)
+
+// quantityEpsilon absorbs float rounding when matching fractional quantities.
+const quantityEpsilon = 1e-9
+
+// Lot is an open FIFO tax lot. Quantity is negative for short lots, and for
+// equity sold beyond the lots imported.
+type Lot struct {
+	Key        string    `json:"key"`
+	ISIN       string    `json:"isin,omitempty"`
+	Symbol     string    `json:"symbol"`
+	Exchange   string    `json:"exchange"`
+	Segment    string    `json:"segment"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	TradeID    string    `json:"trade_id"`
+}
+
+// HoldingDays returns how long the lot has been held as of the given time.
+func (l Lot) HoldingDays(asOf time.Time) int {
+	return int(asOf.Sub(l.AcquiredAt).Hours() / 24)
+}
+
+// IsLongTerm reports whether a lot sold at the given time would qualify as
+// long-term, i.e. it has been held for more than 12 months.
+func (l Lot) IsLongTerm(asOf time.Time) bool {
+	return asOf.After(l.AcquiredAt.AddDate(1, 0, 0))
+}
+
+// RealizedGain is the outcome of closing (part of) a lot.
+type RealizedGain struct {
+	Key         string    `json:"key"`
+	ISIN        string    `json:"isin,omitempty"`
+	Symbol      string    `json:"symbol"`
+	Exchange    string    `json:"exchange"`
+	Segment     string    `json:"segment"`
+	Quantity    float64   `json:"quantity"`
+	OpenDate    time.Time `json:"open_date"`
+	CloseDate   time.Time `json:"close_date"`
+	OpenPrice   float64   `json:"open_price"`
+	ClosePrice  float64   `json:"close_price"`
+	PnL         float64   `json:"pnl"`
+	HoldingDays int       `json:"holding_days"`
+	Category    Category  `json:"category"`
+	Short       bool      `json:"short,omitempty"` // sold first, bought back to close
+}
+
+// SaleValue returns the value the sell side of the gain was traded at.
+func (g RealizedGain) SaleValue() float64 {
+	if g.Short {
+		return g.OpenPrice * g.Quantity
+	}
+	return g.ClosePrice * g.Quantity
+}
+
+// Ledger is the result of replaying a tradebook through FIFO matching.
+type Ledger struct {
+	Open     []Lot          `json:"open_lots"`
+	Realized []RealizedGain `json:"realized"`
+}
+
+// OpenLotsByKey groups the open lots by their key, oldest first.
+func (l Ledger) OpenLotsByKey() map[string][]Lot {
+	out := make(map[string][]Lot)
+	for _, lot := range l.Open {
+		out[lot.Key] = append(out[lot.Key], lot)
+	}
+	return out
+}
+
+// BuildLedger replays trades in execution order and matches them into FIFO
+// lots per security. Equity trades are first netted within the day, so that
+// same-day round trips are classified as intraday regardless of any older
+// holding; the remainder is matched against older lots and classified as
+// STCG or LTCG by holding period. Derivatives are matched FIFO in either
+// direction and are always business income.
+func BuildLedger(trades []Trade) Ledger {
+	sorted := make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].executedAt(), sorted[j].executedAt()
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return sorted[i].TradeID < sorted[j].TradeID
+	})
+
+	// Group by security, preserving order.
+	order := []string{}
+	byKey := make(map[string][]Trade)
+	for _, t := range sorted {
+		k := t.Key()
+		if _, ok := byKey[k]; !ok {
+			order = append(order, k)
+		}
+		byKey[k] = append(byKey[k], t)
+	}
+
+	var ledger Ledger
+	for _, k := range order {
+		var lots []Lot
+		var realized []RealizedGain
+		group := byKey[k]
+		if group[0].IsEquity() {
+			lots, realized = matchEquity(group)
+		} else {
+			lots, realized = matchDerivatives(group)
+		}
+		ledger.Open = append(ledger.Open, lots...)
+		ledger.Realized = append(ledger.Realized, realized...)
+	}
+
+	sort.SliceStable(ledger.Realized, func(i, j int) bool {
+		return ledger.Realized[i].CloseDate.Before(ledger.Realized[j].CloseDate)
+	})
+	return ledger
+}
+
+func lotFromTrade(t Trade, qty float64) Lot {
+	return Lot{
+		Key:        t.Key(),
+		ISIN:       t.ISIN,
+		Symbol:     t.Symbol,
+		Exchange:   t.Exchange,
+		Segment:    t.Segment,
+		Quantity:   qty,
+		Price:      t.Price,
+		AcquiredAt: t.executedAt(),
+		TradeID:    t.TradeID,
+	}
+}
+
+// closeLot realizes qty units (always positive) of lot against a closing trade.
+func closeLot(lot Lot, closing Trade, qty float64, category Category) RealizedGain {
+	pnl := (closing.Price - lot.Price) * qty
+	if lot.Quantity < 0 {
+		pnl = -pnl
+	}
+	closeDate := closing.executedAt()
+	return RealizedGain{
+		Key:         lot.Key,
+		ISIN:        lot.ISIN,
+		Symbol:      lot.Symbol,
+		Exchange:    closing.Exchange,
+		Segment:     lot.Segment,
+		Quantity:    qty,
+		OpenDate:    lot.AcquiredAt,
+		CloseDate:   closeDate,
+		OpenPrice:   lot.Price,
+		ClosePrice:  closing.Price,
+		PnL:         pnl,
+		HoldingDays: lot.HoldingDays(closeDate),
+		Category:    category,
+		Short:       lot.Quantity < 0,
+	}
+}
+
+// consume closes lots FIFO with the closing trade for up to qty units and
+// returns the remaining lots, the realized gains and the unmatched quantity.
+func consume(lots []Lot, closing Trade, qty float64, classify func(Lot, Trade) Category) ([]Lot, []RealizedGain, float64) {
+	var realized []RealizedGain
+	for qty > quantityEpsilon && len(lots) > 0 {
+		lot := lots[0]
+		matched := math.Min(qty, math.Abs(lot.Quantity))
+		realized = append(realized, closeLot(lot, closing, matched, classify(lot, closing)))
+		qty -= matched
+
+		remaining := math.Abs(lot.Quantity) - matched
+		if remaining <= quantityEpsilon {
+			lots = lots[1:]
+			continue
+		}
+		if lot.Quantity < 0 {
+			remaining = -remaining
+		}
+		lots[0].Quantity = remaining
+	}
+	return lots, realized, qty
+}
+
+func classifyEquity(lot Lot, closing Trade) Category {
+	if lot.IsLongTerm(closing.executedAt()) {
+		return CategoryLTCG
+	}
+	return CategorySTCG
+}
+
+func classifyIntraday(Lot, Trade) Category { return CategoryIntraday }
+
+func classifyDerivative(Lot, Trade) Category { return CategoryFNO }
+
+func dayOf(t time.Time) string {
+	return t.In(ist).Format("2006-01-02")
+}
+
+// matchEquity matches equity trades for a single security. Delivery sells
+// only close long lots. What they sell beyond those is kept as a negative
+// lot: a disposal of holdings bought before the imported range, whose cost
+// is unknown. Such lots realize nothing, and later buys open new lots
+// rather than cover them, as delivery equity can't be held short.
+func matchEquity(trades []Trade) ([]Lot, []RealizedGain) {
+	var lots, disposals []Lot
+	var realized []RealizedGain
+
+	for start := 0; start < len(trades); {
+		// Collect the trades of one trading day.
+		day := dayOf(trades[start].executedAt())
+		end := start
+		for end < len(trades) && dayOf(trades[end].executedAt()) == day {
+			end++
+		}
+
+		var buys, sells []Trade
+		var buyQty, sellQty float64
+		for _, t := range trades[start:end] {
+			if t.IsBuy() {
+				buys = append(buys, t)
+				buyQty += t.Quantity
+			} else {
+				sells = append(sells, t)
+				sellQty += t.Quantity
+			}
+		}
+
+		// Same-day round trips are speculative, whichever side came first.
+		intraday := math.Min(buyQty, sellQty)
+		buyLots := make([]Lot, 0, len(buys))
+		for _, b := range buys {
+			buyLots = append(buyLots, lotFromTrade(b, b.Quantity))
+		}
+		for i := range sells {
+			if intraday <= quantityEpsilon {
+				break
+			}
+			qty := math.Min(sells[i].Quantity, intraday)
+			var gains []RealizedGain
+			buyLots, gains, _ = consume(buyLots, sells[i], qty, classifyIntraday)
+			realized = append(realized, gains...)
+			sells[i].Quantity -= qty
+			intraday -= qty
+		}
+
+		// Leftover delivery sells close older long lots; anything beyond
+		// that was bought before the imported range.
+		for _, s := range sells {
+			if s.Quantity <= quantityEpsilon {
+				continue
+			}
+			var gains []RealizedGain
+			var unmatched float64
+			lots, gains, unmatched = consume(lots, s, s.Quantity, classifyEquity)
+			realized = append(realized, gains...)
+			if unmatched > quantityEpsilon {
+				disposals = append(disposals, lotFromTrade(s, -unmatched))
+			}
+		}
+
+		// Leftover buys become new lots.
+		for _, b := range buyLots {
+			if b.Quantity > quantityEpsilon {
+				lots = append(lots, b)
+			}
+		}
+
+		start = end
+	}
+
+	open := append(disposals, lots...)
+	sort.SliceStable(open, func(i, j int) bool { return open[i].AcquiredAt.Before(open[j].AcquiredAt) })
+	return open, realized
+}
+
+// matchDerivatives matches derivative trades for a single contract FIFO in
+// either direction.
+func matchDerivatives(trades []Trade) ([]Lot, []RealizedGain) {
+	var lots []Lot
+	var realized []RealizedGain
+
+	for _, t := range trades {
+		signed := t.Quantity
+		if !t.IsBuy() {
+			signed = -signed
+		}
+
+		// Opposite side closes existing lots first.
+		if len(lots) > 0 && (lots[0].Quantity > 0) != (signed > 0) {
+			var gains []RealizedGain
+			var unmatched float64
+			lots, gains, unmatched = consume(lots, t, math.Abs(signed), classifyDerivative)
+			realized = append(realized, gains...)
+			if unmatched <= quantityEpsilon {
+				continue
+			}
+			signed = math.Copysign(unmatched, signed)
+		}
+
+		lots = append(lots, lotFromTrade(t, signed))
+	}
+
+	return lots, realized
+}
@@ -0,0 +1,255 @@
+package tradebook
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func at(date string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", date, ist)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func equityTrade(id, side string, qty, price float64, when string) Trade {
+	return Trade{
+		TradeID:       id,
+		Symbol:        "INFY",
+		ISIN:          "INE009A01021",
+		Exchange:      "NSE",
+		Segment:       "EQ",
+		TradeType:     side,
+		Quantity:      qty,
+		Price:         price,
+		ExecutionTime: at(when),
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestBuildLedgerFIFO(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeBuy, 10, 100, "2022-01-10 10:00"),
+		equityTrade("2", TradeTypeBuy, 10, 150, "2023-06-10 10:00"),
+		equityTrade("3", TradeTypeSell, 15, 200, "2023-09-01 10:00"),
+	}
+
+	ledger := BuildLedger(trades)
+	if len(ledger.Realized) != 2 {
+		t.Fatalf("Expected 2 realized gains, got %d", len(ledger.Realized))
+	}
+
+	ltcg := ledger.Realized[0]
+	if ltcg.Category != CategoryLTCG || ltcg.Quantity != 10 || !approxEqual(ltcg.PnL, 1000) {
+		t.Errorf("Expected first lot to be LTCG of 1000, got %+v", ltcg)
+	}
+
+	stcg := ledger.Realized[1]
+	if stcg.Category != CategorySTCG || stcg.Quantity != 5 || !approxEqual(stcg.PnL, 250) {
+		t.Errorf("Expected second lot to be STCG of 250, got %+v", stcg)
+	}
+
+	if len(ledger.Open) != 1 || ledger.Open[0].Quantity != 5 || ledger.Open[0].Price != 150 {
+		t.Errorf("Expected 5 units of the 150 lot to remain open, got %+v", ledger.Open)
+	}
+}
+
+func TestBuildLedgerIntradayNettedFirst(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeBuy, 10, 100, "2023-01-10 10:00"),
+		// Sell before buying on the same day still nets as intraday.
+		equityTrade("2", TradeTypeSell, 5, 120, "2023-03-01 09:30"),
+		equityTrade("3", TradeTypeBuy, 5, 110, "2023-03-01 14:00"),
+	}
+
+	ledger := BuildLedger(trades)
+	if len(ledger.Realized) != 1 {
+		t.Fatalf("Expected 1 realized gain, got %d", len(ledger.Realized))
+	}
+	g := ledger.Realized[0]
+	if g.Category != CategoryIntraday || !approxEqual(g.PnL, 50) {
+		t.Errorf("Expected intraday gain of 50, got %+v", g)
+	}
+
+	// The older delivery lot must be untouched.
+	if len(ledger.Open) != 1 || ledger.Open[0].Quantity != 10 || ledger.Open[0].Price != 100 {
+		t.Errorf("Expected original lot to remain open, got %+v", ledger.Open)
+	}
+}
+
+func TestBuildLedgerDerivatives(t *testing.T) {
+	opt := func(id, side string, qty, price float64, when string) Trade {
+		return Trade{
+			TradeID: id, Symbol: "NIFTY24AUG24500CE", Exchange: "NFO", Segment: "FO",
+			TradeType: side, Quantity: qty, Price: price, ExecutionTime: at(when),
+		}
+	}
+
+	trades := []Trade{
+		opt("1", TradeTypeSell, 50, 120, "2024-08-01 10:00"),
+		opt("2", TradeTypeBuy, 75, 80, "2024-08-05 10:00"),
+	}
+
+	ledger := BuildLedger(trades)
+	if len(ledger.Realized) != 1 {
+		t.Fatalf("Expected 1 realized gain, got %d", len(ledger.Realized))
+	}
+	g := ledger.Realized[0]
+	if g.Category != CategoryFNO || !approxEqual(g.PnL, 2000) || !g.Short {
+		t.Errorf("Expected short option profit of 2000, got %+v", g)
+	}
+	if len(ledger.Open) != 1 || ledger.Open[0].Quantity != 25 {
+		t.Errorf("Expected remaining 25 long, got %+v", ledger.Open)
+	}
+}
+
+func TestBuildLedgerUnmatchedSell(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeSell, 5, 100, "2023-03-01 09:30"),
+	}
+
+	ledger := BuildLedger(trades)
+	if len(ledger.Realized) != 0 {
+		t.Errorf("Expected no realized gain without a matching buy, got %+v", ledger.Realized)
+	}
+	if len(ledger.Open) != 1 || ledger.Open[0].Quantity != -5 {
+		t.Errorf("Expected unmatched sell to be tracked as a short lot, got %+v", ledger.Open)
+	}
+}
+
+func TestBuildLedgerConsecutiveUnmatchedSells(t *testing.T) {
+	trades := []Trade{
+		equityTrade("1", TradeTypeSell, 10, 100, "2024-05-01 10:00"),
+		equityTrade("2", TradeTypeSell, 5, 120, "2024-05-02 10:00"),
+	}
+
+	// The second sale doesn't close the first one
+	ledger := BuildLedger(trades)
+	if len(ledger.Realized) != 0 {
+		t.Errorf("Expected no realized gain between two sells, got %+v", ledger.Realized)
+	}
+	short := 0.0
+	for _, lot := range ledger.Open {
+		short += lot.Quantity
+	}
+	if len(ledger.Open) != 2 || short != -15 {
+		t.Errorf("Expected both sells kept as unmatched, 15 in all, got %+v", ledger.Open)
+	}
+
+	// A later buy is a new holding, not a cover of the earlier sales
+	ledger = BuildLedger(append(trades, equityTrade("3", TradeTypeBuy, 8, 90, "2024-06-03 10:00")))
+	if len(ledger.Realized) != 0 {
+		t.Errorf("Expected no gain booked by buying after unmatched sells, got %+v", ledger.Realized)
+	}
+	if last := ledger.Open[len(ledger.Open)-1]; len(ledger.Open) != 3 || last.Quantity != 8 || last.Price != 90 {
+		t.Errorf("Expected the buy open as a lot of its own, got %+v", ledger.Open)
+	}
+
+	// and a sale after it closes the buy
+	ledger = BuildLedger(append(trades,
+		equityTrade("3", TradeTypeBuy, 8, 90, "2024-06-03 10:00"),
+		equityTrade("4", TradeTypeSell, 8, 95, "2024-06-10 10:00"),
+	))
+	if len(ledger.Realized) != 1 || ledger.Realized[0].PnL != 40 || ledger.Realized[0].Short {
+		t.Errorf("Expected the sale to close the bought lot, got %+v", ledger.Realized)
+	}
+}
+
+func TestFinancialYear(t *testing.T) {
+	if fy := FinancialYearOf(at("2024-03-31 23:00")); fy != "2023-24" {
+		t.Errorf("Expected 2023-24, got %s", fy)
+	}
+	if fy := FinancialYearOf(at("2024-04-01 00:00")); fy != "2024-25" {
+		t.Errorf("Expected 2024-25, got %s", fy)
+	}
+	if fy := FinancialYearOf(at("1999-06-01 00:00")); fy != "1999-00" {
+		t.Errorf("Expected 1999-00, got %s", fy)
+	}
+
+	from, to, err := ParseFinancialYear("2024-25")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !from.Equal(at("2024-04-01 00:00")) || !to.Equal(at("2025-04-01 00:00")) {
+		t.Errorf("Unexpected range %v - %v", from, to)
+	}
+
+	for _, invalid := range []string{"2024", "2024-26", "abcd-ef", "2024/25"} {
+		if _, _, err := ParseFinancialYear(invalid); err != ErrInvalidFinancialYear {
+			t.Errorf("Expected ErrInvalidFinancialYear for %q, got: %v", invalid, err)
+		}
+	}
+}
+
+func TestEstimateCapitalGainsTax(t *testing.T) {
+	after := at("2024-09-01 00:00")
+	before := at("2024-01-01 00:00")
+
+	tests := []struct {
+		name       string
+		stcg, ltcg float64
+		asOf       time.Time
+		want       float64
+	}{
+		{"stcg after budget", 100000, 0, after, 20000},
+		{"stcg before budget", 100000, 0, before, 15000},
+		{"ltcg within exemption", 0, 125000, after, 0},
+		{"ltcg above exemption", 0, 225000, after, 12500},
+		{"ltcg above old exemption", 0, 200000, before, 10000},
+		{"short-term loss offsets ltcg", -50000, 225000, after, 6250},
+		{"long-term loss does not offset stcg", 100000, -50000, after, 20000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateCapitalGainsTax(tt.stcg, tt.ltcg, tt.asOf); !approxEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSummarizeAndFilter(t *testing.T) {
+	gains := []RealizedGain{
+		{Symbol: "INFY", Quantity: 10, OpenPrice: 1400, ClosePrice: 1500, PnL: 1000, Category: CategorySTCG, CloseDate: at("2024-05-01 10:00")},
+		{Symbol: "TCS", Quantity: 2, OpenPrice: 4000, ClosePrice: 3800, PnL: -400, Category: CategorySTCG, CloseDate: at("2024-06-01 10:00")},
+		{Symbol: "RELIANCE", Quantity: 5, OpenPrice: 2500, ClosePrice: 2450, PnL: 250, Category: CategoryFNO, CloseDate: at("2024-07-01 10:00"), Short: true},
+		{Symbol: "INFY", PnL: 300, Category: CategoryIntraday, CloseDate: at("2025-05-01 10:00")},
+	}
+
+	from, to, _ := ParseFinancialYear("2024-25")
+	filtered := FilterRealized(gains, RealizedFilter{From: from, To: to})
+	if len(filtered) != 3 {
+		t.Fatalf("Expected 3 gains in FY 2024-25, got %d", len(filtered))
+	}
+
+	summary := Summarize(filtered, to)
+	stcg := summary.Categories[CategorySTCG]
+	if stcg == nil || stcg.Net != 600 || stcg.Gains != 1000 || stcg.Losses != -400 || stcg.Trades != 2 {
+		t.Errorf("Unexpected STCG totals: %+v", stcg)
+	}
+	// Capital gains turnover is the sale value, business income's the
+	// absolute P&L
+	if stcg.Turnover != 22600 {
+		t.Errorf("Expected the STCG turnover to be the sale value of 22600, got %v", stcg.Turnover)
+	}
+	if fno := summary.Categories[CategoryFNO]; fno == nil || fno.Turnover != 250 {
+		t.Errorf("Expected the F&O turnover to be the absolute P&L of 250, got %+v", fno)
+	}
+	if short := filtered[2]; short.SaleValue() != 12500 {
+		t.Errorf("Expected the sale value of the short to be at its open price, got %v", short.SaleValue())
+	}
+	if !approxEqual(summary.EstimatedTax, 120) {
+		t.Errorf("Expected estimated tax of 120, got %v", summary.EstimatedTax)
+	}
+
+	bySymbol := FilterRealized(gains, RealizedFilter{Symbol: "INFY", Categories: []Category{CategoryIntraday}})
+	if len(bySymbol) != 1 || bySymbol[0].PnL != 300 {
+		t.Errorf("Expected single INFY intraday gain, got %+v", bySymbol)
+	}
+}
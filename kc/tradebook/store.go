@@ -0,0 +1,236 @@
+package tradebook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// ImportResult reports what an import added to the store.
+type ImportResult struct {
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+	Total      int `json:"total"`
+}
+
+// book holds everything imported for a single Kite user.
+type book struct {
+	Trades []Trade    `json:"trades"`
+	PnL    []PnLEntry `json:"pnl"`
+}
+
+// Store keeps imported tradebooks per Kite user. When a directory is
+// configured each user's book is persisted as JSON so imports survive
+// restarts; otherwise the store is in-memory only.
+// All public methods are thread-safe.
+type Store struct {
+	dir    string
+	logger *slog.Logger
+
+	books       map[string]*book
+	resolveISIN ISINResolver
+	mu          sync.Mutex
+}
+
+// ISINResolver returns the ISIN of an equity by its exchange and symbol,
+// or "" when it isn't known.
+type ISINResolver func(exchange, symbol string) string
+
+// NewStore creates a store persisting to dir. An empty dir keeps data in memory.
+func NewStore(dir string, logger *slog.Logger) *Store {
+	return &Store{
+		dir:    dir,
+		logger: logger,
+		books:  make(map[string]*book),
+	}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+func (s *Store) path(userID string) string {
+	return filepath.Join(s.dir, unsafeFileChars.ReplaceAllString(userID, "_")+".json")
+}
+
+// load returns the user's book, reading it from disk on first access.
+// Must be called with the write lock held.
+func (s *Store) load(userID string) (*book, error) {
+	if b, ok := s.books[userID]; ok {
+		return b, nil
+	}
+
+	b := &book{}
+	if s.dir != "" {
+		data, err := os.ReadFile(s.path(userID))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("error reading tradebook: %w", err)
+		default:
+			if err := json.Unmarshal(data, b); err != nil {
+				return nil, fmt.Errorf("error decoding tradebook: %w", err)
+			}
+		}
+	}
+
+	s.books[userID] = b
+	return b, nil
+}
+
+// save writes the user's book atomically. Must be called with the write lock held.
+func (s *Store) save(userID string, b *book) error {
+	if s.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("error creating tradebook directory: %w", err)
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("error encoding tradebook: %w", err)
+	}
+
+	tmp := s.path(userID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("error writing tradebook: %w", err)
+	}
+	if err := os.Rename(tmp, s.path(userID)); err != nil {
+		return fmt.Errorf("error writing tradebook: %w", err)
+	}
+	return nil
+}
+
+// ImportTrades adds trades to the user's book, skipping trade IDs that were
+// already imported so overlapping exports can be loaded repeatedly.
+func (s *Store) ImportTrades(userID string, trades []Trade) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.load(userID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	seen := make(map[string]bool, len(b.Trades))
+	for _, t := range b.Trades {
+		seen[t.Exchange+":"+t.TradeID] = true
+	}
+
+	var res ImportResult
+	for _, t := range trades {
+		id := t.Exchange + ":" + t.TradeID
+		if t.TradeID != "" && seen[id] {
+			res.Duplicates++
+			continue
+		}
+		seen[id] = true
+		b.Trades = append(b.Trades, t)
+		res.Imported++
+	}
+	res.Total = len(b.Trades)
+
+	if res.Imported > 0 {
+		if err := s.save(userID, b); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	s.logger.Info("Imported tradebook", "user_id", userID, "imported", res.Imported, "duplicates", res.Duplicates)
+	return res, nil
+}
+
+// ImportPnL replaces the user's P&L statement entries for each financial
+// year present in entries.
+func (s *Store) ImportPnL(userID string, entries []PnLEntry) (ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.load(userID)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	years := make(map[string]bool)
+	for _, e := range entries {
+		years[e.FinancialYear] = true
+	}
+
+	kept := b.PnL[:0]
+	replaced := 0
+	for _, e := range b.PnL {
+		if years[e.FinancialYear] {
+			replaced++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	b.PnL = append(kept, entries...)
+
+	if err := s.save(userID, b); err != nil {
+		return ImportResult{}, err
+	}
+
+	s.logger.Info("Imported P&L statement", "user_id", userID, "entries", len(entries), "replaced", replaced)
+	return ImportResult{Imported: len(entries), Duplicates: replaced, Total: len(b.PnL)}, nil
+}
+
+// Trades returns a copy of the user's imported trades.
+func (s *Store) Trades(userID string) ([]Trade, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Trade, len(b.Trades))
+	copy(out, b.Trades)
+	return out, nil
+}
+
+// PnLEntries returns a copy of the user's imported P&L statement entries.
+func (s *Store) PnLEntries(userID string) ([]PnLEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PnLEntry, len(b.PnL))
+	copy(out, b.PnL)
+	return out, nil
+}
+
+// SetISINResolver sets how Ledger fills in the ISINs of equity trades
+// imported without one, so that their NSE and BSE trades share a queue.
+func (s *Store) SetISINResolver(resolve ISINResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolveISIN = resolve
+}
+
+// Ledger replays the user's trades into FIFO lots and realized gains.
+func (s *Store) Ledger(userID string) (Ledger, error) {
+	trades, err := s.Trades(userID)
+	if err != nil {
+		return Ledger{}, err
+	}
+
+	s.mu.Lock()
+	resolve := s.resolveISIN
+	s.mu.Unlock()
+	if resolve != nil {
+		for i := range trades {
+			if trades[i].ISIN == "" && trades[i].IsEquity() {
+				trades[i].ISIN = resolve(trades[i].Exchange, trades[i].Symbol)
+			}
+		}
+	}
+	return BuildLedger(trades), nil
+}
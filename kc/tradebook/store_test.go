@@ -0,0 +1,139 @@
+package tradebook
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// testLogger creates a discard logger for tests
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestStoreImportDeduplicates(t *testing.T) {
+	store := NewStore("", testLogger())
+
+	trades := []Trade{
+		equityTrade("1", TradeTypeBuy, 10, 100, "2023-01-10 10:00"),
+		equityTrade("2", TradeTypeSell, 5, 120, "2023-03-01 09:30"),
+	}
+
+	res, err := store.ImportTrades("AB1234", trades)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.Imported != 2 || res.Duplicates != 0 || res.Total != 2 {
+		t.Errorf("Unexpected first import result: %+v", res)
+	}
+
+	// Overlapping export: one old, one new trade.
+	res, err = store.ImportTrades("AB1234", []Trade{
+		trades[1],
+		equityTrade("3", TradeTypeSell, 5, 130, "2023-04-01 09:30"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if res.Imported != 1 || res.Duplicates != 1 || res.Total != 3 {
+		t.Errorf("Unexpected second import result: %+v", res)
+	}
+
+	// Other users are isolated.
+	other, _ := store.Trades("XY9999")
+	if len(other) != 0 {
+		t.Errorf("Expected no trades for another user, got %d", len(other))
+	}
+
+	ledger, err := store.Ledger("AB1234")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(ledger.Open) != 0 || len(ledger.Realized) != 2 {
+		t.Errorf("Expected fully closed position with 2 realized gains, got %+v", ledger)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	store := NewStore(dir, testLogger())
+	if _, err := store.ImportTrades("AB1234", []Trade{equityTrade("1", TradeTypeBuy, 10, 100, "2023-01-10 10:00")}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := store.ImportPnL("AB1234", []PnLEntry{{Symbol: "TCS", RealizedPnL: -1000, FinancialYear: "2023-24"}}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	reopened := NewStore(dir, testLogger())
+	trades, err := reopened.Trades("AB1234")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(trades) != 1 || trades[0].TradeID != "1" {
+		t.Errorf("Expected persisted trade, got %+v", trades)
+	}
+
+	// Re-importing a financial year replaces it.
+	if _, err := reopened.ImportPnL("AB1234", []PnLEntry{{Symbol: "INFY", RealizedPnL: 500, FinancialYear: "2023-24"}}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	entries, _ := reopened.PnLEntries("AB1234")
+	if len(entries) != 1 || entries[0].Symbol != "INFY" {
+		t.Errorf("Expected P&L entries for the year to be replaced, got %+v", entries)
+	}
+}
+
+func TestStoreConcurrentImports(t *testing.T) {
+	store := NewStore(t.TempDir(), testLogger())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := string(rune('a' + i))
+			_, _ = store.ImportTrades("AB1234", []Trade{equityTrade(id, TradeTypeBuy, 1, 100, "2023-01-10 10:00")})
+			_, _ = store.Ledger("AB1234")
+		}(i)
+	}
+	wg.Wait()
+
+	trades, _ := store.Trades("AB1234")
+	if len(trades) != 20 {
+		t.Errorf("Expected 20 trades, got %d", len(trades))
+	}
+}
+
+func TestStoreLedgerResolvesISINs(t *testing.T) {
+	store := NewStore("", testLogger())
+
+	// A P&L statement style import without ISINs, bought on NSE and sold
+	// on BSE
+	buy := equityTrade("1", TradeTypeBuy, 10, 100, "2023-01-10 10:00")
+	sell := equityTrade("2", TradeTypeSell, 10, 120, "2023-03-01 09:30")
+	buy.ISIN, sell.ISIN, sell.Exchange = "", "", "BSE"
+	if _, err := store.ImportTrades("AB1234", []Trade{buy, sell}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	ledger, _ := store.Ledger("AB1234")
+	if len(ledger.Realized) != 0 || len(ledger.Open) != 2 {
+		t.Errorf("Expected separate queues per exchange without ISINs, got %+v", ledger)
+	}
+
+	store.SetISINResolver(func(exchange, symbol string) string {
+		if symbol == "INFY" {
+			return "INE009A01021"
+		}
+		return ""
+	})
+	ledger, _ = store.Ledger("AB1234")
+	if len(ledger.Realized) != 1 || len(ledger.Open) != 0 || ledger.Realized[0].ISIN != "INE009A01021" {
+		t.Errorf("Expected the sale on BSE to close the NSE lot of the same ISIN, got %+v", ledger)
+	}
+	if trades, _ := store.Trades("AB1234"); trades[0].ISIN != "" {
+		t.Error("Expected the imported trades left as they were")
+	}
+}
@@ -0,0 +1,183 @@
+package tradebook
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Budget 2024 revised equity capital gains rates for transfers on or after
+// 23 July 2024, and raised the LTCG exemption from FY 2024-25 onwards.
+var budget2024Effective = time.Date(2024, time.July, 23, 0, 0, 0, 0, ist)
+
+const (
+	stcgRateOld      = 0.15
+	stcgRateNew      = 0.20
+	ltcgRateOld      = 0.10
+	ltcgRateNew      = 0.125
+	ltcgExemptionOld = 100000
+	ltcgExemptionNew = 125000
+)
+
+// FinancialYearOf returns the Indian financial year (April to March) label
+// for the given time, e.g. "2024-25".
+func FinancialYearOf(t time.Time) string {
+	t = t.In(ist)
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// ParseFinancialYear parses a "YYYY-YY" label and returns the first instant
+// of the year and the first instant of the following year.
+func ParseFinancialYear(fy string) (time.Time, time.Time, error) {
+	if len(fy) != 7 || fy[4] != '-' {
+		return time.Time{}, time.Time{}, ErrInvalidFinancialYear
+	}
+	start, err := strconv.Atoi(fy[:4])
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidFinancialYear
+	}
+	end, err := strconv.Atoi(fy[5:])
+	if err != nil || end != (start+1)%100 {
+		return time.Time{}, time.Time{}, ErrInvalidFinancialYear
+	}
+
+	from := time.Date(start, time.April, 1, 0, 0, 0, 0, ist)
+	return from, from.AddDate(1, 0, 0), nil
+}
+
+// EquityTaxRates returns the STCG and LTCG rates applicable to listed equity
+// transferred at the given time.
+func EquityTaxRates(on time.Time) (stcg, ltcg float64) {
+	if on.Before(budget2024Effective) {
+		return stcgRateOld, ltcgRateOld
+	}
+	return stcgRateNew, ltcgRateNew
+}
+
+// LTCGExemption returns the annual LTCG exemption for the financial year the
+// given time falls in.
+func LTCGExemption(on time.Time) float64 {
+	from, _, _ := ParseFinancialYear("2024-25")
+	if on.Before(from) {
+		return ltcgExemptionOld
+	}
+	return ltcgExemptionNew
+}
+
+// EstimateCapitalGainsTax estimates tax on net equity capital gains for a
+// financial year using the set-off rules: short-term losses can be set off
+// against both short and long-term gains, long-term losses only against
+// long-term gains. Rates and the exemption are taken as of asOf. Surcharge
+// and cess are not included.
+func EstimateCapitalGainsTax(stcg, ltcg float64, asOf time.Time) float64 {
+	if ltcg < 0 {
+		// Long-term losses cannot reduce short-term gains.
+		ltcg = 0
+	}
+	if stcg < 0 {
+		ltcg = math.Max(0, ltcg+stcg)
+		stcg = 0
+	}
+
+	stcgRate, ltcgRate := EquityTaxRates(asOf)
+	taxableLTCG := math.Max(0, ltcg-LTCGExemption(asOf))
+	return stcg*stcgRate + taxableLTCG*ltcgRate
+}
+
+// CategoryTotal aggregates realized gains within a category. Turnover is
+// the sale value for capital gains, and the sum of the absolute P&L for
+// speculative and F&O business income, as the tax audit limit counts it.
+type CategoryTotal struct {
+	Gains    float64 `json:"gains"`
+	Losses   float64 `json:"losses"`
+	Net      float64 `json:"net"`
+	Turnover float64 `json:"turnover"`
+	Trades   int     `json:"trades"`
+}
+
+// Summary aggregates realized gains by tax category.
+type Summary struct {
+	Categories   map[Category]*CategoryTotal `json:"categories"`
+	EstimatedTax float64                     `json:"estimated_capital_gains_tax"`
+}
+
+// Summarize aggregates gains by category and estimates the capital gains tax
+// on the equity portion. Business income (intraday and F&O) is taxed at slab
+// rates and is reported but not estimated.
+func Summarize(gains []RealizedGain, asOf time.Time) Summary {
+	s := Summary{Categories: make(map[Category]*CategoryTotal)}
+	for _, g := range gains {
+		total, ok := s.Categories[g.Category]
+		if !ok {
+			total = &CategoryTotal{}
+			s.Categories[g.Category] = total
+		}
+		if g.PnL >= 0 {
+			total.Gains += g.PnL
+		} else {
+			total.Losses += g.PnL
+		}
+		total.Net += g.PnL
+		if g.Category == CategorySTCG || g.Category == CategoryLTCG {
+			total.Turnover += g.SaleValue()
+		} else {
+			total.Turnover += math.Abs(g.PnL)
+		}
+		total.Trades++
+	}
+
+	s.EstimatedTax = EstimateCapitalGainsTax(s.Net(CategorySTCG), s.Net(CategoryLTCG), asOf)
+	return s
+}
+
+// Net returns the net realized amount for a category.
+func (s Summary) Net(c Category) float64 {
+	if total, ok := s.Categories[c]; ok {
+		return total.Net
+	}
+	return 0
+}
+
+// RealizedFilter narrows realized gains by close date, category and symbol.
+// Zero values match everything; To is exclusive.
+type RealizedFilter struct {
+	From       time.Time
+	To         time.Time
+	Categories []Category
+	Symbol     string
+}
+
+// FilterRealized returns the gains matching the filter.
+func FilterRealized(gains []RealizedGain, f RealizedFilter) []RealizedGain {
+	out := make([]RealizedGain, 0, len(gains))
+	for _, g := range gains {
+		if !f.From.IsZero() && g.CloseDate.Before(f.From) {
+			continue
+		}
+		if !f.To.IsZero() && !g.CloseDate.Before(f.To) {
+			continue
+		}
+		if f.Symbol != "" && g.Symbol != f.Symbol && g.ISIN != f.Symbol {
+			continue
+		}
+		if len(f.Categories) > 0 && !containsCategory(f.Categories, g.Category) {
+			continue
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+func containsCategory(list []Category, c Category) bool {
+	for _, v := range list {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
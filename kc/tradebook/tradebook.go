@@ -0,0 +1,92 @@
+package tradebook
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownFormat is returned when a CSV export does not look like a
+	// Console tradebook or P&L statement.
+	ErrUnknownFormat = errors.New("unrecognised CSV format, expected a Console tradebook or P&L statement export")
+
+	// ErrInvalidFinancialYear is returned when a financial year label cannot
+	// be parsed.
+	ErrInvalidFinancialYear = errors.New("invalid financial year, use the YYYY-YY format (e.g. 2024-25)")
+)
+
+// ist is the timezone all Console exports are reported in.
+var ist = time.FixedZone("IST", 5*60*60+30*60)
+
+// Trade types as they appear in Console tradebook exports.
+const (
+	TradeTypeBuy  = "buy"
+	TradeTypeSell = "sell"
+)
+
+// Trade is a single executed trade from a Zerodha Console tradebook export.
+type Trade struct {
+	TradeID       string    `json:"trade_id"`
+	OrderID       string    `json:"order_id"`
+	Symbol        string    `json:"symbol"`
+	ISIN          string    `json:"isin"`
+	Exchange      string    `json:"exchange"`
+	Segment       string    `json:"segment"`
+	Series        string    `json:"series"`
+	TradeType     string    `json:"trade_type"`
+	Quantity      float64   `json:"quantity"`
+	Price         float64   `json:"price"`
+	TradeDate     time.Time `json:"trade_date"`
+	ExecutionTime time.Time `json:"order_execution_time"`
+}
+
+// Key returns the identifier lots are tracked under. Equity lots are keyed by
+// ISIN so that NSE and BSE trades of the same security share one FIFO queue;
+// derivatives have no ISIN and fall back to exchange:symbol.
+func (t Trade) Key() string {
+	if t.ISIN != "" {
+		return t.ISIN
+	}
+	return t.Exchange + ":" + t.Symbol
+}
+
+// IsBuy reports whether the trade is a purchase.
+func (t Trade) IsBuy() bool {
+	return strings.EqualFold(t.TradeType, TradeTypeBuy)
+}
+
+// IsEquity reports whether the trade is a cash-segment equity trade, which is
+// taxed as capital gains rather than business income.
+func (t Trade) IsEquity() bool {
+	switch strings.ToUpper(t.Segment) {
+	case "EQ":
+		return true
+	case "":
+		exch := strings.ToUpper(t.Exchange)
+		return exch == "NSE" || exch == "BSE"
+	default:
+		return false
+	}
+}
+
+// executedAt returns the best available timestamp for ordering trades.
+func (t Trade) executedAt() time.Time {
+	if !t.ExecutionTime.IsZero() {
+		return t.ExecutionTime
+	}
+	return t.TradeDate
+}
+
+// PnLEntry is a single row of a Console P&L statement export. P&L statements
+// are aggregated per symbol, so they carry no trade dates and are attributed
+// to the financial year they were exported for.
+type PnLEntry struct {
+	Symbol        string  `json:"symbol"`
+	ISIN          string  `json:"isin"`
+	Quantity      float64 `json:"quantity"`
+	BuyValue      float64 `json:"buy_value"`
+	SellValue     float64 `json:"sell_value"`
+	RealizedPnL   float64 `json:"realized_pnl"`
+	FinancialYear string  `json:"financial_year"`
+}
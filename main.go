@@ -18,8 +18,9 @@ var (
 	buildString = "dev build"
 )
 
-func initLogger() *slog.Logger {
-	// Default to INFO level, can be overridden by LOG_LEVEL env var
+func initLogger() (*slog.Logger, *slog.LevelVar) {
+	// Default to INFO level, can be overridden by LOG_LEVEL env var or the
+	// configuration file
 	// Valid levels: debug, info, warn, error
 	var level slog.Level
 	logLevel := os.Getenv("LOG_LEVEL")
@@ -36,11 +37,13 @@ func initLogger() *slog.Logger {
 		level = slog.LevelInfo // Default to INFO if invalid
 	}
 
+	levelVar := &slog.LevelVar{}
+	levelVar.Set(level)
 	opts := &slog.HandlerOptions{
-		Level: level,
+		Level: levelVar,
 	}
 	handler := slog.NewTextHandler(os.Stderr, opts)
-	return slog.New(handler)
+	return slog.New(handler), levelVar
 }
 
 func main() {
@@ -52,12 +55,13 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := initLogger()
+	logger, logLevel := initLogger()
 
 	// Create a new application instance
 	application := app.NewApp(logger)
+	application.SetLogLevel(logLevel)
 
-	// Load configuration from environment
+	// Load configuration from environment and the configuration file
 	if err := application.LoadConfig(); err != nil {
 		logger.Error("Failed to load configuration", "error", err)
 		os.Exit(1)
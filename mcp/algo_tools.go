@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/algo"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+// NormalizedAlgoOrder is an algo order with the adjustments made to the
+// request before it started.
+type NormalizedAlgoOrder struct {
+	algo.Order
+	Adjustments []orders.Adjustment `json:"adjustments,omitempty"`
+	Warning     string              `json:"warning,omitempty"`
+}
+
+// algoError converts an engine error to a tool result.
+func algoError(handler *ToolHandler, action, algoID string, err error) *mcp.CallToolResult {
+	switch {
+	case errors.Is(err, algo.ErrNotFound), errors.Is(err, algo.ErrFinished), errors.Is(err, algo.ErrInvalidSpec):
+		return mcp.NewToolResultError(err.Error())
+	}
+	handler.manager.Logger.Error("Failed to "+action+" algo order", "algo_id", algoID, "error", err)
+	return mcp.NewToolResultError(fmt.Sprintf("Failed to %s algo order: %s", action, err.Error()))
+}
+
+// algoSpec builds the spec of an algo order from the tool arguments,
+// aligned to the instrument's tick and lot sizes and kept below its freeze
+// quantity.
+func algoSpec(manager *kc.Manager, args map[string]interface{}) (algo.Spec, *orders.Normalizer, error) {
+	spec := algo.Spec{
+		Type:              algo.Type(SafeAssertString(args["algo"], "")),
+		Exchange:          SafeAssertString(args["exchange"], "NSE"),
+		Tradingsymbol:     SafeAssertString(args["tradingsymbol"], ""),
+		TransactionType:   SafeAssertString(args["transaction_type"], ""),
+		Product:           SafeAssertString(args["product"], kiteconnect.ProductCNC),
+		Quantity:          SafeAssertInt(args["quantity"], 0),
+		LimitPrice:        SafeAssertFloat64(args["limit_price"], 0),
+		Duration:          time.Duration(SafeAssertInt(args["duration_minutes"], 0)) * time.Minute,
+		Slices:            SafeAssertInt(args["slices"], 0),
+		ParticipationRate: SafeAssertFloat64(args["participation_rate"], 0) / 100,
+	}
+
+	normalizer, err := newOrderNormalizer(manager, args, spec.Exchange, spec.Tradingsymbol, true)
+	if err != nil {
+		return algo.Spec{}, nil, err
+	}
+	if spec.LimitPrice, err = normalizer.Price("limit_price", spec.LimitPrice, orders.LimitRounding(spec.TransactionType)); err != nil {
+		return algo.Spec{}, nil, err
+	}
+	if spec.Quantity, err = normalizer.Quantity("quantity", spec.Quantity); err != nil {
+		return algo.Spec{}, nil, err
+	}
+
+	limits := instrumentLimits(manager, spec.Exchange, spec.Tradingsymbol)
+	spec.LotSize = limits.LotSize
+	spec.MaxChildQuantity = orders.MaxSliceQuantity(limits)
+	return spec, normalizer, nil
+}
+
+type PlaceAlgoOrderTool struct{}
+
+func (*PlaceAlgoOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("place_algo_order",
+		mcp.WithDescription("Work a large order as smaller child orders over time. 'twap' releases equal slices at equal intervals over the window; 'participation' takes a fixed share of the volume traded in the market, so the fills track the VWAP. Children are MARKET orders, or LIMIT orders at limit_price with releases held back while the market trades beyond it. Use get_algo_orders to follow progress and cancel_algo_order to stop."),
+		mcp.WithString("algo",
+			mcp.Description("Execution algorithm"),
+			mcp.Required(),
+			mcp.Enum(string(algo.TypeTWAP), string(algo.TypeParticipation)),
+		),
+		mcp.WithString("exchange",
+			mcp.Description("The exchange to which the orders should be placed"),
+			mcp.Required(),
+			mcp.DefaultString("NSE"),
+			mcp.Enum("NSE", "BSE", "MCX", "NFO", "BFO"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Trading symbol"),
+			mcp.Required(),
+		),
+		mcp.WithString("transaction_type",
+			mcp.Description("Transaction type"),
+			mcp.Required(),
+			mcp.Enum("BUY", "SELL"),
+		),
+		mcp.WithNumber("quantity",
+			mcp.Description("Total quantity to execute"),
+			mcp.Required(),
+			mcp.Min(1),
+		),
+		mcp.WithString("product",
+			mcp.Description("Product type. Default: CNC"),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
+		mcp.WithNumber("duration_minutes",
+			mcp.Description("Length of the execution window in minutes. Quantity still unfilled when it ends is left unexecuted"),
+			mcp.Required(),
+			mcp.Min(1),
+			mcp.Max(float64(algo.MaxDuration/time.Minute)),
+		),
+		mcp.WithNumber("slices",
+			mcp.Description("Number of TWAP slices. Default: one per minute"),
+			mcp.Min(1),
+		),
+		mcp.WithNumber("participation_rate",
+			mcp.Description("Share of the market volume to take, in percent (for participation), eg. 10"),
+			mcp.Min(0),
+			mcp.Max(algo.MaxParticipationRate*100),
+		),
+		mcp.WithNumber("limit_price",
+			mcp.Description("Worst price to execute at. If omitted, child orders are MARKET orders"),
+		),
+		withNormalization(),
+	)
+}
+
+func (*PlaceAlgoOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "place_algo_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "algo", "exchange", "tradingsymbol", "transaction_type", "quantity", "duration_minutes"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		spec, normalizer, err := algoSpec(handler.manager, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := spec.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		// Algos pause while the exchange is closed
+		warning := marketClosedWarning(handler.manager.Calendar, kiteconnect.VarietyRegular, spec.Exchange, timeNow())
+		if warning != "" {
+			warning += "; the algo order places its child orders once it opens"
+		}
+		if handler.manager.Instruments.Partial() {
+			warning = joinWarnings(warning, unknownLimitsWarning(spec.Tradingsymbol))
+		}
+
+		return handler.WithSession(ctx, "place_algo_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			spec.UserID = session.UserID
+			order, err := handler.manager.Algos.Place(algo.KiteBroker{Client: session.Kite.Client}, spec)
+			if err != nil {
+				return algoError(handler, "place", "", err), nil
+			}
+
+			return handler.MarshalResponse(NormalizedAlgoOrder{Order: order, Adjustments: normalizer.Adjustments, Warning: warning}, "place_algo_order")
+		})
+	}
+}
+
+type GetAlgoOrdersTool struct{}
+
+func (*GetAlgoOrdersTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_algo_orders",
+		mcp.WithDescription("Get the progress of algo orders placed with place_algo_order: filled quantity and average price, child orders, and the market volume and VWAP over the window. Finished algo orders are kept for a day."),
+		mcp.WithString("algo_id",
+			mcp.Description("ID of a single algo order. If omitted, all algo orders are returned"),
+		),
+	)
+}
+
+func (*GetAlgoOrdersTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_algo_orders")
+		args := request.GetArguments()
+		algoID := SafeAssertString(args["algo_id"], "")
+
+		return handler.WithSession(ctx, "get_algo_orders", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			if algoID == "" {
+				return handler.MarshalResponse(handler.manager.Algos.List(session.UserID), "get_algo_orders")
+			}
+
+			order, err := handler.manager.Algos.Get(algoID, session.UserID)
+			if err != nil {
+				return algoError(handler, "get", algoID, err), nil
+			}
+			return handler.MarshalResponse(order, "get_algo_orders")
+		})
+	}
+}
+
+type CancelAlgoOrderTool struct{}
+
+func (*CancelAlgoOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("cancel_algo_order",
+		mcp.WithDescription("Stop an algo order and cancel its working child orders. Quantity that already filled stays filled."),
+		mcp.WithString("algo_id",
+			mcp.Description("ID of the algo order"),
+			mcp.Required(),
+		),
+	)
+}
+
+func (*CancelAlgoOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "cancel_algo_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "algo_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		algoID := SafeAssertString(args["algo_id"], "")
+
+		return handler.WithSession(ctx, "cancel_algo_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			order, err := handler.manager.Algos.Cancel(algoID, session.UserID)
+			if err != nil {
+				return algoError(handler, "cancel", algoID, err), nil
+			}
+			return handler.MarshalResponse(order, "cancel_algo_order")
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/kite-mcp-server/kc/algo"
+)
+
+func TestAlgoSpec(t *testing.T) {
+	manager := newGTTTestManager(t)
+
+	spec, n, err := algoSpec(manager, map[string]interface{}{
+		"algo":               "participation",
+		"exchange":           "NFO",
+		"tradingsymbol":      "NIFTY24JANFUT",
+		"transaction_type":   "BUY",
+		"quantity":           520.0,
+		"product":            "NRML",
+		"duration_minutes":   30.0,
+		"participation_rate": 10.0,
+		"limit_price":        21500.03,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, algo.TypeParticipation, spec.Type)
+	assert.Equal(t, 500, spec.Quantity)
+	assert.Equal(t, 21500.0, spec.LimitPrice)
+	assert.Equal(t, 30*time.Minute, spec.Duration)
+	assert.InDelta(t, 0.1, spec.ParticipationRate, 1e-9)
+	assert.Equal(t, 50, spec.LotSize)
+	assert.Len(t, n.Adjustments, 2)
+	require.NoError(t, spec.Validate())
+
+	_, _, err = algoSpec(manager, map[string]interface{}{
+		"algo":             "twap",
+		"exchange":         "NFO",
+		"tradingsymbol":    "NIFTY24JANFUT",
+		"transaction_type": "BUY",
+		"quantity":         520.0,
+		"duration_minutes": 30.0,
+		"normalization":    "strict",
+	})
+	assert.Error(t, err)
+}
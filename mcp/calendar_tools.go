@@ -0,0 +1,99 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+)
+
+// MarketStatusResponse is the trading status of each requested exchange.
+type MarketStatusResponse struct {
+	Time      time.Time         `json:"time"`
+	Exchanges []calendar.Status `json:"exchanges"`
+}
+
+// marketStatus returns the status of exchanges at now, or of every known
+// exchange if none are given.
+func marketStatus(cal *calendar.Calendar, exchanges []string, now time.Time) (MarketStatusResponse, error) {
+	if len(exchanges) == 0 {
+		exchanges = cal.Exchanges()
+	}
+
+	resp := MarketStatusResponse{Time: now.In(calendar.IST)}
+	for _, exchange := range exchanges {
+		if !cal.Known(exchange) {
+			return MarketStatusResponse{}, ValidationError{Parameter: "exchanges", Message: fmt.Sprintf("unknown exchange %q, expected one of %s", exchange, strings.Join(cal.Exchanges(), ", "))}
+		}
+		resp.Exchanges = append(resp.Exchanges, cal.Status(exchange, now))
+	}
+	return resp, nil
+}
+
+// timeNow is the clock the order tools check the market calendar at.
+var timeNow = time.Now
+
+// marketClosedWarning warns about orders other than AMOs placed while the
+// market calendar has the exchange closed. The calendar's timings can be
+// off, e.g. for special sessions missing from the holidays file, so orders
+// are still sent and Kite decides whether to reject them. Orders are
+// expected during pre-open.
+func marketClosedWarning(cal *calendar.Calendar, variety, exchange string, now time.Time) string {
+	if cal == nil || variety == kiteconnect.VarietyAMO || cal.Phase(exchange, now) != calendar.PhaseClosed {
+		return ""
+	}
+
+	message := fmt.Sprintf("%s is closed according to the market calendar", exchange)
+	if description, ok := cal.Holiday(exchange, now); ok {
+		message += fmt.Sprintf(" for %s", description)
+	}
+	if next := cal.NextOpen(exchange, now); !next.IsZero() {
+		message += fmt.Sprintf(" until %s IST", next.Format("Mon 02 Jan 15:04"))
+	}
+	return message
+}
+
+// orderWarning is the warning of an order placed while the exchange looks
+// closed, suggesting how to place it if Kite rejects it.
+func orderWarning(cal *calendar.Calendar, variety, exchange string) string {
+	warning := marketClosedWarning(cal, variety, exchange, timeNow())
+	if warning == "" {
+		return ""
+	}
+	return warning + "; if Kite rejects the order, use variety amo to queue it for the next session, or schedule_order to place it later"
+}
+
+type GetMarketStatusTool struct{}
+
+func (*GetMarketStatusTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_market_status",
+		mcp.WithDescription("Get whether exchanges are open right now, with today's session timings, holidays, muhurat sessions and when each exchange opens next. Times are in IST."),
+		mcp.WithArray("exchanges",
+			mcp.Description("Exchanges to check, eg. ['NSE', 'MCX']. Defaults to all exchanges."),
+			mcp.Items(map[string]any{
+				"type": "string",
+				"enum": []string{"NSE", "BSE", "NFO", "BFO", "CDS", "BCD", "MCX"},
+			}),
+		),
+	)
+}
+
+func (*GetMarketStatusTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_market_status")
+		args := request.GetArguments()
+
+		resp, err := marketStatus(manager.Calendar, SafeAssertStringArray(args["exchanges"]), time.Now())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return handler.MarshalResponse(resp, "get_market_status")
+	}
+}
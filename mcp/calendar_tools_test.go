@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+)
+
+func newTestCalendar(t *testing.T) *calendar.Calendar {
+	t.Helper()
+	cal := calendar.New()
+	require.NoError(t, cal.Set(calendar.Holidays{
+		Holidays: []calendar.Holiday{
+			{Date: "2024-11-01", Description: "Diwali Laxmi Pujan"},
+		},
+		SpecialSessions: []calendar.SpecialSession{
+			{Date: "2024-11-01", Name: calendar.SessionMuhurat, Exchanges: []string{"NSE", "NFO"}, Open: "18:00", Close: "19:00"},
+		},
+	}))
+	return cal
+}
+
+func istTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	got, err := time.ParseInLocation("2006-01-02 15:04", value, calendar.IST)
+	require.NoError(t, err)
+	return got
+}
+
+func TestMarketStatus(t *testing.T) {
+	cal := newTestCalendar(t)
+
+	t.Run("all exchanges", func(t *testing.T) {
+		resp, err := marketStatus(cal, nil, istTime(t, "2024-01-02 20:00"))
+		require.NoError(t, err)
+		require.Len(t, resp.Exchanges, len(cal.Exchanges()))
+		for _, status := range resp.Exchanges {
+			assert.Equal(t, status.Exchange == "MCX", status.Open, status.Exchange)
+		}
+	})
+
+	t.Run("muhurat", func(t *testing.T) {
+		resp, err := marketStatus(cal, []string{"nse", "MCX"}, istTime(t, "2024-11-01 18:30"))
+		require.NoError(t, err)
+		require.Len(t, resp.Exchanges, 2)
+		assert.True(t, resp.Exchanges[0].Open)
+		assert.Equal(t, "Diwali Laxmi Pujan", resp.Exchanges[0].Holiday)
+		require.NotNil(t, resp.Exchanges[0].Session)
+		assert.Equal(t, calendar.SessionMuhurat, resp.Exchanges[0].Session.Name)
+		assert.False(t, resp.Exchanges[1].Open)
+		assert.Equal(t, istTime(t, "2024-11-04 09:00"), resp.Exchanges[1].NextOpen)
+	})
+
+	t.Run("unknown exchange", func(t *testing.T) {
+		_, err := marketStatus(cal, []string{"NYSE"}, time.Now())
+		assert.ErrorContains(t, err, "unknown exchange")
+	})
+}
+
+func TestMarketClosedWarning(t *testing.T) {
+	cal := newTestCalendar(t)
+
+	assert.Empty(t, marketClosedWarning(cal, "regular", "NSE", istTime(t, "2024-01-02 10:00")))
+	assert.Empty(t, marketClosedWarning(cal, "regular", "NSE", istTime(t, "2024-01-02 09:05")), "pre-open accepts orders")
+	assert.Empty(t, marketClosedWarning(cal, "regular", "NFO", istTime(t, "2024-01-02 09:05")), "pre-open accepts orders")
+	assert.Empty(t, marketClosedWarning(cal, "regular", "MCX", istTime(t, "2024-01-02 23:40")), "MCX trades until 23:55 in winter")
+	assert.Empty(t, marketClosedWarning(cal, "amo", "NSE", istTime(t, "2024-01-06 10:00")))
+	assert.Empty(t, marketClosedWarning(nil, "regular", "NSE", istTime(t, "2024-01-06 10:00")))
+
+	assert.Equal(t, "NSE is closed according to the market calendar until Mon 08 Jan 09:15 IST",
+		marketClosedWarning(cal, "regular", "NSE", istTime(t, "2024-01-06 10:00")))
+	assert.Equal(t, "MCX is closed according to the market calendar until Thu 04 Jul 09:00 IST",
+		marketClosedWarning(cal, "regular", "MCX", istTime(t, "2024-07-03 23:40")))
+	assert.Contains(t, marketClosedWarning(cal, "regular", "NSE", istTime(t, "2024-11-01 10:00")),
+		"NSE is closed according to the market calendar for Diwali Laxmi Pujan until Fri 01 Nov 18:00 IST")
+}
+
+func TestPlaceOrderWhileClosed(t *testing.T) {
+	e := newE2EServer(t)
+	require.NoError(t, e.manager.Calendar.Set(calendar.Holidays{}))
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+
+	// MCX trades until 23:55 while US daylight saving time is off
+	timeNow = func() time.Time { return istTime(t, "2025-01-15 23:40") }
+	placed := e.callJSON(t, "place_order", map[string]any{
+		"variety": "regular", "exchange": "MCX", "tradingsymbol": "GOLDM25FEBFUT", "transaction_type": "BUY",
+		"quantity": 1.0, "product": "NRML", "order_type": "MARKET",
+	})
+	assert.NotEmpty(t, placed["order_id"])
+	assert.Nil(t, placed["warning"])
+
+	// Orders the calendar has closed are still sent, with a warning
+	timeNow = func() time.Time { return istTime(t, "2025-01-18 10:00") }
+	placed = e.callJSON(t, "place_order", map[string]any{
+		"variety": "regular", "exchange": "NSE", "tradingsymbol": "INFY", "transaction_type": "BUY",
+		"quantity": 1.0, "product": "CNC", "order_type": "MARKET",
+	})
+	assert.NotEmpty(t, placed["order_id"])
+	assert.Contains(t, placed["warning"], "NSE is closed according to the market calendar until Mon 20 Jan 09:15 IST")
+	assert.Contains(t, placed["warning"], "variety amo")
+}
+
+func TestGenerateDailyGameplanOnHoliday(t *testing.T) {
+	cal := newTestCalendar(t)
+
+	gameplan := generateDailyGameplan(100000, "moderate", "neutral", nil, cal.Status("NSE", istTime(t, "2024-01-06 08:00")))
+	assert.Equal(t, "2024-01-08", gameplan["date"])
+	assert.Contains(t, gameplan["note"], "the weekend")
+
+	gameplan = generateDailyGameplan(100000, "moderate", "neutral", nil, cal.Status("NSE", istTime(t, "2024-11-01 08:00")))
+	assert.Equal(t, "2024-11-01", gameplan["date"])
+	assert.NotContains(t, gameplan, "note")
+	schedule := gameplan["schedule"].([]map[string]interface{})
+	require.Len(t, schedule, 1)
+	assert.Equal(t, "18:00 - 19:00", schedule[0]["time"])
+
+	gameplan = generateDailyGameplan(100000, "moderate", "neutral", nil, cal.Status("NSE", istTime(t, "2024-01-02 08:00")))
+	assert.Equal(t, "2024-01-02", gameplan["date"])
+	assert.Len(t, gameplan["schedule"], 5)
+}
@@ -0,0 +1,532 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
+)
+
+// e2eSession is the MCP client session the end-to-end tests call tools in.
+type e2eSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s e2eSession) Initialize()                                         {}
+func (s e2eSession) Initialized() bool                                   { return true }
+func (s e2eSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s e2eSession) SessionID() string                                   { return s.id }
+
+// e2eServer is an MCP server with all tools registered, backed by a fake
+// Kite broker and logged in as kitefake.UserID.
+type e2eServer struct {
+	srv       *server.MCPServer
+	ctx       context.Context
+	manager   *kc.Manager
+	sessionID string
+	called    map[string]bool
+	nextID    int
+}
+
+var e2eInstruments = []kitefake.Instrument{
+	{Exchange: "NSE", Tradingsymbol: "INFY", InstrumentToken: 408065, LastPrice: 1500, Volume: 100000},
+	{Exchange: "NSE", Tradingsymbol: "RELIANCE", InstrumentToken: 738561, LastPrice: 2500, Volume: 100000},
+	{Exchange: "NSE", Tradingsymbol: "TCS", InstrumentToken: 2953217, LastPrice: 3500, Close: 3450, Volume: 50000},
+	{Exchange: "NSE", Tradingsymbol: "HDFCBANK", InstrumentToken: 341249, LastPrice: 1600, Close: 1620, Volume: 80000},
+	{Exchange: "NSE", Tradingsymbol: "NIFTY50", InstrumentToken: 256265, LastPrice: 22000, Close: 21900},
+	{Exchange: "MCX", Tradingsymbol: "GOLDM25FEBFUT", InstrumentToken: 53505799, LastPrice: 78000, Volume: 20000},
+	{Exchange: "NSE", Tradingsymbol: "NIFTYBANK", InstrumentToken: 260105, LastPrice: 47000, Close: 47200},
+}
+
+const e2eFund = "INF740K01NY4"
+
+// openAllDay opens every exchange all day from yesterday to tomorrow, so
+// that orders placed don't depend on when the tests run.
+func openAllDay(t *testing.T, cal *calendar.Calendar) {
+	t.Helper()
+	now := time.Now().In(calendar.IST)
+	if now.Hour() == 23 && now.Minute() >= 58 {
+		t.Skip("the test market session closes at 23:59 IST")
+	}
+	var sessions []calendar.SpecialSession
+	for _, day := range []time.Time{now.AddDate(0, 0, -1), now, now.AddDate(0, 0, 1)} {
+		sessions = append(sessions, calendar.SpecialSession{
+			Date:  day.Format(time.DateOnly),
+			Name:  calendar.SessionNormal,
+			Open:  "00:00",
+			Close: "23:59",
+		})
+	}
+	require.NoError(t, cal.Set(calendar.Holidays{SpecialSessions: sessions}))
+}
+
+func newE2EServer(t *testing.T) *e2eServer {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	broker := kitefake.New()
+	testData := make(map[uint32]*instruments.Instrument)
+	for _, inst := range e2eInstruments {
+		broker.AddInstrument(inst)
+		testData[uint32(inst.InstrumentToken)] = &instruments.Instrument{
+			ID:              inst.ID(),
+			InstrumentToken: uint32(inst.InstrumentToken),
+			Exchange:        inst.Exchange,
+			Tradingsymbol:   inst.Tradingsymbol,
+			Name:            inst.Tradingsymbol,
+			Segment:         inst.Exchange,
+			InstrumentType:  "EQ",
+			LastPrice:       inst.LastPrice,
+			TickSize:        0.05,
+			LotSize:         1,
+		}
+	}
+
+	// Six months of daily candles rising from 1200 to the last price
+	var candles []kiteconnect.HistoricalData
+	start := time.Now().AddDate(0, -6, 0).Truncate(24 * time.Hour)
+	for i := 0; i < 180; i++ {
+		price := 1200 + float64(i)*300/179
+		candles = append(candles, kiteconnect.HistoricalData{
+			Date:   models.Time{Time: start.AddDate(0, 0, i)},
+			Open:   price - 5,
+			High:   price + 10,
+			Low:    price - 10,
+			Close:  price,
+			Volume: 100000 + i*100,
+		})
+	}
+	require.NoError(t, broker.SetCandles(408065, "day", candles))
+
+	broker.AddHolding(kiteconnect.Holding{
+		Tradingsymbol: "TCS", Exchange: "NSE", InstrumentToken: 2953217, ISIN: "INE467B01029",
+		Product: kiteconnect.ProductCNC, Quantity: 10, AveragePrice: 4000, LastPrice: 3500,
+	})
+	broker.AddPosition(kiteconnect.Position{
+		Tradingsymbol: "RELIANCE", Exchange: "NSE", InstrumentToken: 738561,
+		Product: kiteconnect.ProductNRML, Quantity: 4, AveragePrice: 2400, LastPrice: 2500,
+	})
+
+	scheme := kiteconnect.MFInstrument{
+		Tradingsymbol: e2eFund, Name: "DSP Nifty 50 Index Fund - Direct Plan", AMC: "DSPBlackRockMutualFund_MF",
+		PurchaseAllowed: true, RedemtpionAllowed: true, MinimumPurchaseAmount: 100, PurchaseAmountMultiplier: 1,
+		DividendType: "growth", Plan: "direct", LastPrice: 21.5,
+	}
+	broker.AddMFInstrument(scheme)
+	broker.AddMFHolding(kiteconnect.MFHolding{Tradingsymbol: e2eFund, Fund: scheme.Name, Quantity: 100, AveragePrice: 20, LastPrice: 21.5}, "INF740K01NY4")
+
+	updateConfig := instruments.DefaultUpdateConfig()
+	updateConfig.EnableScheduler = false
+	im, err := instruments.New(instruments.Config{UpdateConfig: updateConfig, Logger: logger, TestData: testData})
+	require.NoError(t, err)
+
+	mfConfig := instruments.DefaultMFUpdateConfig()
+	mfConfig.EnableScheduler = false
+	mfCatalog := instruments.NewMFCatalog(instruments.MFConfig{
+		UpdateConfig: mfConfig,
+		Logger:       logger,
+		TestData: []instruments.MFInstrument{{
+			Tradingsymbol: scheme.Tradingsymbol, AMC: scheme.AMC, Name: scheme.Name,
+			PurchaseAllowed: true, RedemptionAllowed: true, MinimumPurchaseAmount: 100, PurchaseAmountMultiplier: 1,
+			DividendType: "growth", Plan: "direct", SchemeType: "index", LastPrice: 21.5,
+		}},
+	})
+
+	return startE2EServer(t, kc.Config{
+		Logger:             logger,
+		InstrumentsManager: im,
+		MFInstruments:      mfCatalog,
+		KiteClientFactory: func(apiKey string) kc.KiteClient {
+			return broker.Client(apiKey)
+		},
+	})
+}
+
+// startE2EServer creates a manager with cfg, logs in a session and
+// registers the tools on an MCP server.
+func startE2EServer(t *testing.T, cfg kc.Config) *e2eServer {
+	t.Helper()
+	cfg.APIKey = "test_key"
+	cfg.APISecret = "test_secret"
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if cfg.InstrumentsManager == nil {
+		updateConfig := instruments.DefaultUpdateConfig()
+		updateConfig.EnableScheduler = false
+		im, err := instruments.New(instruments.Config{
+			UpdateConfig: updateConfig,
+			Logger:       cfg.Logger,
+			TestData:     map[uint32]*instruments.Instrument{},
+		})
+		require.NoError(t, err)
+		cfg.InstrumentsManager = im
+	}
+	if cfg.MFInstruments == nil {
+		mfConfig := instruments.DefaultMFUpdateConfig()
+		mfConfig.EnableScheduler = false
+		cfg.MFInstruments = instruments.NewMFCatalog(instruments.MFConfig{
+			UpdateConfig: mfConfig,
+			Logger:       cfg.Logger,
+			TestData:     []instruments.MFInstrument{},
+		})
+	}
+
+	manager, err := kc.New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(manager.Shutdown)
+	openAllDay(t, manager.Calendar)
+
+	sessionID := manager.GenerateSession()
+	require.NoError(t, manager.CompleteSession(sessionID, "request_token"))
+
+	srv := server.NewMCPServer("kite-mcp-test", "test", server.WithToolCapabilities(true))
+	RegisterTools(srv, manager, "", cfg.Logger)
+
+	session := e2eSession{id: sessionID, notifications: make(chan mcp.JSONRPCNotification, 16)}
+	return &e2eServer{
+		srv:       srv,
+		ctx:       srv.WithContext(context.Background(), session),
+		manager:   manager,
+		sessionID: sessionID,
+		called:    make(map[string]bool),
+	}
+}
+
+// client returns the Kite client of the logged in session, to check the
+// broker's state after tool calls.
+func (e *e2eServer) client(t *testing.T) kc.KiteClient {
+	session, err := e.manager.GetSession(e.sessionID)
+	require.NoError(t, err)
+	return session.Kite.Client
+}
+
+// callResult calls a tool with a tools/call request, like an MCP client.
+func (e *e2eServer) callResult(t *testing.T, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	e.called[name] = true
+	e.nextID++
+
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      e.nextID,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	require.NoError(t, err)
+
+	switch resp := e.srv.HandleMessage(e.ctx, message).(type) {
+	case mcp.JSONRPCResponse:
+		switch result := resp.Result.(type) {
+		case *mcp.CallToolResult:
+			return result
+		case mcp.CallToolResult:
+			return &result
+		default:
+			t.Fatalf("Unexpected result of %s: %T", name, resp.Result)
+		}
+	case mcp.JSONRPCError:
+		t.Fatalf("%s failed: %s", name, resp.Error.Message)
+	default:
+		t.Fatalf("Unexpected response to %s: %T", name, resp)
+	}
+	return nil
+}
+
+// call calls a tool that should succeed and returns its text output.
+func (e *e2eServer) call(t *testing.T, name string, args map[string]any) string {
+	t.Helper()
+	result := e.callResult(t, name, args)
+	text := resultText(result)
+	require.False(t, result.IsError, "%s: %s", name, text)
+	return text
+}
+
+// callJSON calls a tool that should succeed and decodes its JSON output.
+func (e *e2eServer) callJSON(t *testing.T, name string, args map[string]any) map[string]any {
+	t.Helper()
+	var out map[string]any
+	text := e.call(t, name, args)
+	require.NoError(t, json.Unmarshal([]byte(text), &out), "%s: %s", name, text)
+	return out
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			return text.Text
+		}
+	}
+	return ""
+}
+
+func order(exchange, tradingsymbol, transactionType string, quantity float64) map[string]any {
+	return map[string]any{
+		"exchange":         exchange,
+		"tradingsymbol":    tradingsymbol,
+		"transaction_type": transactionType,
+		"variety":          "regular",
+		"product":          "CNC",
+		"order_type":       "MARKET",
+		"quantity":         quantity,
+		"average_price":    1500.0,
+	}
+}
+
+func orderStatus(t *testing.T, client kc.KiteClient, orderID string) string {
+	t.Helper()
+	history, err := client.GetOrderHistory(orderID)
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	return history[len(history)-1].Status
+}
+
+func netPosition(t *testing.T, client kc.KiteClient, tradingsymbol, product string) int {
+	t.Helper()
+	positions, err := client.GetPositions()
+	require.NoError(t, err)
+	for _, p := range positions.Net {
+		if p.Tradingsymbol == tradingsymbol && p.Product == product {
+			return p.Quantity
+		}
+	}
+	return 0
+}
+
+func TestEndToEndTools(t *testing.T) {
+	e := newE2EServer(t)
+	client := e.client(t)
+
+	t.Run("session and account", func(t *testing.T) {
+		assert.Contains(t, e.call(t, "login", nil), "logged in")
+		assert.Equal(t, kitefake.UserID, e.callJSON(t, "get_profile", nil)["user_id"])
+		assert.Contains(t, e.callJSON(t, "get_margins", nil), "equity")
+		assert.Contains(t, e.call(t, "get_holdings", nil), "TCS")
+		assert.Contains(t, e.call(t, "get_positions", nil), "RELIANCE")
+		e.call(t, "get_market_status", map[string]any{"exchanges": []string{"NSE"}})
+	})
+
+	t.Run("market data", func(t *testing.T) {
+		assert.Contains(t, e.call(t, "get_quotes", map[string]any{"instruments": []string{"NSE:INFY"}}), "408065")
+		assert.Contains(t, e.call(t, "get_ltp", map[string]any{"instruments": []string{"NSE:INFY"}}), "1500")
+		assert.Contains(t, e.call(t, "get_ohlc", map[string]any{"instruments": []string{"NSE:TCS"}}), "3450")
+		assert.Contains(t, e.call(t, "search_instruments", map[string]any{"query": "INFY"}), "NSE:INFY")
+		var found []instruments.Instrument
+		require.NoError(t, json.Unmarshal([]byte(e.call(t, "search_instruments", map[string]any{"query": "relaince"})), &found))
+		require.NotEmpty(t, found)
+		assert.Equal(t, "NSE:RELIANCE", found[0].ID)
+		assert.Equal(t, "[]", e.call(t, "search_instruments", map[string]any{"query": "INFY", "exchange": "BSE"}))
+		assert.Contains(t, e.call(t, "get_instrument_changes", nil), "No changes recorded yet")
+		assert.Equal(t, "[]", e.call(t, "search_instruments", map[string]any{"query": "INFY", "filter_on": "tradingsymbol", "instrument_type": "FUT"}))
+		assert.Contains(t, e.call(t, "search_mf_instruments", map[string]any{"query": "Nifty"}), e2eFund)
+
+		now := time.Now()
+		candles := e.call(t, "get_historical_data", map[string]any{
+			"instrument_token": 408065.0,
+			"from_date":        now.AddDate(0, 0, -30).Format(time.DateTime),
+			"to_date":          now.Format(time.DateTime),
+			"interval":         "day",
+		})
+		assert.Contains(t, candles, "close")
+	})
+
+	t.Run("margins and charges", func(t *testing.T) {
+		orders := []any{order("NSE", "INFY", "BUY", 10)}
+		assert.Contains(t, e.call(t, "calculate_order_margins", map[string]any{"orders": orders}), "INFY")
+		assert.Contains(t, e.call(t, "calculate_basket_margins", map[string]any{"orders": orders}), "initial")
+		assert.Contains(t, e.call(t, "get_order_charges", map[string]any{"orders": orders}), "charges")
+	})
+
+	var marketOrderID string
+	t.Run("orders", func(t *testing.T) {
+		placed := e.callJSON(t, "place_order", map[string]any{
+			"variety": "regular", "exchange": "NSE", "tradingsymbol": "INFY", "transaction_type": "BUY",
+			"quantity": 10.0, "product": "MIS", "order_type": "MARKET",
+		})
+		marketOrderID, _ = placed["order_id"].(string)
+		require.NotEmpty(t, marketOrderID)
+
+		assert.Contains(t, e.call(t, "wait_for_order", map[string]any{"order_id": marketOrderID, "timeout_seconds": 5.0}), kiteconnect.OrderStatusComplete)
+		assert.Contains(t, e.call(t, "get_order_history", map[string]any{"order_id": marketOrderID}), kiteconnect.OrderStatusComplete)
+		assert.Contains(t, e.call(t, "get_order_trades", map[string]any{"order_id": marketOrderID}), marketOrderID)
+		assert.Contains(t, e.call(t, "get_trades", nil), marketOrderID)
+		assert.Equal(t, 10, netPosition(t, client, "INFY", kiteconnect.ProductMIS))
+
+		placed = e.callJSON(t, "place_order", map[string]any{
+			"variety": "regular", "exchange": "NSE", "tradingsymbol": "INFY", "transaction_type": "BUY",
+			"quantity": 5.0, "product": "CNC", "order_type": "LIMIT", "price": 1400.0,
+		})
+		limitOrderID, _ := placed["order_id"].(string)
+		require.NotEmpty(t, limitOrderID)
+		assert.Equal(t, "OPEN", orderStatus(t, client, limitOrderID))
+
+		e.call(t, "modify_order", map[string]any{
+			"variety": "regular", "order_id": limitOrderID, "order_type": "LIMIT", "price": 1410.0, "quantity": 5.0,
+		})
+		assert.Contains(t, e.call(t, "get_orders", nil), "1410")
+
+		e.call(t, "cancel_order", map[string]any{"variety": "regular", "order_id": limitOrderID})
+		assert.Equal(t, kiteconnect.OrderStatusCancelled, orderStatus(t, client, limitOrderID))
+	})
+
+	t.Run("positions", func(t *testing.T) {
+		e.call(t, "convert_position", map[string]any{
+			"exchange": "NSE", "tradingsymbol": "INFY", "old_product": "MIS", "new_product": "CNC", "quantity": 10.0,
+		})
+		assert.Equal(t, 0, netPosition(t, client, "INFY", kiteconnect.ProductMIS))
+		assert.Equal(t, 10, netPosition(t, client, "INFY", kiteconnect.ProductCNC))
+
+		e.call(t, "square_off_position", map[string]any{"exchange": "NSE", "tradingsymbol": "INFY", "product": "CNC"})
+		assert.Equal(t, 0, netPosition(t, client, "INFY", kiteconnect.ProductCNC))
+
+		// No futures or options expiring to roll over
+		assert.Contains(t, e.call(t, "rollover_positions", nil), `"rollovers":[]`)
+
+		e.call(t, "monitor_positions", map[string]any{"include_holdings": true})
+		e.call(t, "set_emergency_exit", map[string]any{"exit_type": "specific_symbol", "symbol": "RELIANCE"})
+		assert.Equal(t, 0, netPosition(t, client, "RELIANCE", kiteconnect.ProductNRML))
+	})
+
+	t.Run("gtts", func(t *testing.T) {
+		placed := e.callJSON(t, "place_gtt_order", map[string]any{
+			"exchange": "NSE", "tradingsymbol": "INFY", "last_price": 1500.0, "transaction_type": "BUY",
+			"product": "CNC", "trigger_type": "single", "trigger_value": 1450.0, "limit_price": 1451.0, "quantity": 2.0,
+		})
+		triggerID, _ := placed["trigger_id"].(float64)
+		require.NotZero(t, triggerID)
+
+		e.call(t, "modify_gtt_order", map[string]any{
+			"trigger_id": triggerID, "exchange": "NSE", "tradingsymbol": "INFY", "last_price": 1500.0,
+			"transaction_type": "BUY", "product": "CNC", "trigger_type": "single", "trigger_value": 1440.0,
+			"limit_price": 1441.0, "quantity": 2.0,
+		})
+		assert.Contains(t, e.call(t, "get_gtt", map[string]any{"trigger_id": triggerID}), "1440")
+		assert.Contains(t, e.call(t, "get_gtts", nil), "1440")
+
+		e.call(t, "delete_gtt_order", map[string]any{"trigger_id": triggerID})
+		gtt, err := client.GetGTT(int(triggerID))
+		require.NoError(t, err)
+		assert.Equal(t, "deleted", gtt.Status)
+
+		e.call(t, "place_smart_gtt_order", map[string]any{
+			"exchange": "NSE", "tradingsymbol": "INFY", "transaction_type": "SELL", "quantity": 1.0, "entry_price": 1500.0,
+		})
+		e.call(t, "delete_gtts", map[string]any{"instrument": "NSE:INFY"})
+		gtts, err := client.GetGTTs()
+		require.NoError(t, err)
+		for _, gtt := range gtts {
+			assert.Equal(t, "deleted", gtt.Status, "GTT %d", gtt.ID)
+		}
+	})
+
+	t.Run("oco orders", func(t *testing.T) {
+		placed := e.callJSON(t, "place_oco_order", map[string]any{
+			"exchange": "NSE", "tradingsymbol": "TCS", "transaction_type": "BUY", "quantity": 1.0, "product": "MIS",
+			"entry_order_type": "LIMIT", "entry_price": 3450.0, "target_price": 3600.0, "stop_trigger_price": 3400.0,
+		})
+		ocoID, _ := placed["oco_id"].(string)
+		require.NotEmpty(t, ocoID)
+		assert.Contains(t, e.call(t, "get_oco_orders", map[string]any{"oco_id": ocoID}), ocoID)
+		e.call(t, "cancel_oco_order", map[string]any{"oco_id": ocoID})
+	})
+
+	t.Run("scheduled orders", func(t *testing.T) {
+		placed := e.callJSON(t, "schedule_order", map[string]any{
+			"exchange": "NSE", "tradingsymbol": "INFY", "transaction_type": "BUY", "quantity": 1.0, "product": "CNC",
+			"trigger_type": "ltp", "watch_instrument": "NSE:INFY", "operator": "below", "value": 1000.0,
+		})
+		id, _ := placed["id"].(string)
+		require.NotEmpty(t, id)
+		assert.Contains(t, e.call(t, "list_scheduled_orders", nil), id)
+		e.call(t, "cancel_scheduled_order", map[string]any{"id": id})
+	})
+
+	t.Run("algo orders", func(t *testing.T) {
+		placed := e.callJSON(t, "place_algo_order", map[string]any{
+			"algo": "twap", "exchange": "NSE", "tradingsymbol": "HDFCBANK", "transaction_type": "BUY",
+			"quantity": 10.0, "duration_minutes": 10.0, "slices": 5.0, "limit_price": 1550.0,
+		})
+		algoID, _ := placed["algo_id"].(string)
+		require.NotEmpty(t, algoID)
+		assert.Contains(t, e.call(t, "get_algo_orders", map[string]any{"algo_id": algoID}), algoID)
+		e.call(t, "cancel_algo_order", map[string]any{"algo_id": algoID})
+	})
+
+	t.Run("mutual funds", func(t *testing.T) {
+		assert.Contains(t, e.call(t, "get_mf_holdings", nil), e2eFund)
+		assert.Contains(t, e.call(t, "get_mf_allotted_isins", nil), "INF740K01NY4")
+
+		placed := e.callJSON(t, "place_mf_order", map[string]any{"tradingsymbol": e2eFund, "transaction_type": "BUY", "amount": 5000.0})
+		orderID, _ := placed["order_id"].(string)
+		require.NotEmpty(t, orderID)
+		assert.Contains(t, e.call(t, "get_mf_orders", nil), orderID)
+		e.call(t, "cancel_mf_order", map[string]any{"order_id": orderID})
+
+		placed = e.callJSON(t, "place_mf_sip", map[string]any{
+			"tradingsymbol": e2eFund, "amount": 1000.0, "instalments": 12.0, "frequency": "monthly",
+		})
+		sipID, _ := placed["sip_id"].(string)
+		require.NotEmpty(t, sipID)
+		e.call(t, "modify_mf_sip", map[string]any{"sip_id": sipID, "status": "paused"})
+		assert.Contains(t, e.call(t, "get_mf_sips", nil), "PAUSED")
+		e.call(t, "cancel_mf_sip", map[string]any{"sip_id": sipID})
+	})
+
+	t.Run("analysis", func(t *testing.T) {
+		e.call(t, "analyze_trade_opportunity", map[string]any{"symbol": "INFY", "capital": 100000.0})
+		e.call(t, "get_wealth_builder_signals", nil)
+		e.call(t, "calculate_poverty_escape_position", map[string]any{
+			"capital": 100000.0, "entry_price": 1500.0, "stop_loss": 1450.0, "tradingsymbol": "INFY",
+		})
+		e.call(t, "detect_momentum_stocks", map[string]any{"sector": "it"})
+		e.call(t, "analyze_sector_rotation", nil)
+		e.call(t, "get_daily_gameplan", map[string]any{"capital": 100000.0})
+	})
+
+	t.Run("tax", func(t *testing.T) {
+		csv := "symbol,isin,trade_date,exchange,segment,series,trade_type,auction,quantity,price,trade_id,order_id,order_execution_time\n" +
+			"INFY,INE009A01021,2023-05-10,NSE,EQ,EQ,buy,false,10.000000,1250.50,1001,2001,2023-05-10T09:20:11\n" +
+			"INFY,INE009A01021,2023-08-01,NSE,EQ,EQ,sell,false,10.000000,1400.00,1002,2002,2023-08-01T10:05:42\n"
+		e.call(t, "import_tradebook", map[string]any{"csv_content": csv})
+		assert.Contains(t, e.call(t, "get_realized_pnl", map[string]any{"financial_year": "2023-24"}), "INFY")
+		assert.Contains(t, e.call(t, "tax_loss_harvest", nil), "TCS")
+	})
+
+	for _, tool := range GetAllTools() {
+		name := tool.Tool().Name
+		assert.True(t, e.called[name], "tool %s is not covered", name)
+	}
+}
+
+func TestEndToEndLoginRequired(t *testing.T) {
+	e := newE2EServer(t)
+	require.NoError(t, e.manager.ClearSessionData(e.sessionID))
+
+	result := e.callResult(t, "get_holdings", nil)
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(result), "log in")
+}
+
+func TestEndToEndBrokerErrors(t *testing.T) {
+	e := newE2EServer(t)
+
+	result := e.callResult(t, "place_order", map[string]any{
+		"variety": "regular", "exchange": "NSE", "tradingsymbol": "INFY", "transaction_type": "BUY",
+		"quantity": 1.0, "product": "CNC", "order_type": "LIMIT", "price": 2500.0,
+	})
+	assert.True(t, result.IsError, "limit price above the circuit: %s", resultText(result))
+}
@@ -0,0 +1,244 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+// gttStatuses are the statuses Kite reports for GTTs.
+var gttStatuses = []string{"active", "triggered", "disabled", "expired", "cancelled", "rejected", "deleted"}
+
+// gttTrigger builds the trigger of a GTT from the single or two-leg
+// parameters shared by the place and modify tools.
+func gttTrigger(args map[string]interface{}) (kiteconnect.Trigger, error) {
+	switch SafeAssertString(args["trigger_type"], "") {
+	case string(kiteconnect.GTTTypeSingle):
+		return &kiteconnect.GTTSingleLegTrigger{
+			TriggerParams: kiteconnect.TriggerParams{
+				TriggerValue: SafeAssertFloat64(args["trigger_value"], 0.0),
+				Quantity:     SafeAssertFloat64(args["quantity"], 0.0),
+				LimitPrice:   SafeAssertFloat64(args["limit_price"], 0.0),
+			},
+		}, nil
+	case string(kiteconnect.GTTTypeOCO):
+		return &kiteconnect.GTTOneCancelsOtherTrigger{
+			Upper: kiteconnect.TriggerParams{
+				TriggerValue: SafeAssertFloat64(args["upper_trigger_value"], 0.0),
+				Quantity:     SafeAssertFloat64(args["upper_quantity"], 0.0),
+				LimitPrice:   SafeAssertFloat64(args["upper_limit_price"], 0.0),
+			},
+			Lower: kiteconnect.TriggerParams{
+				TriggerValue: SafeAssertFloat64(args["lower_trigger_value"], 0.0),
+				Quantity:     SafeAssertFloat64(args["lower_quantity"], 0.0),
+				LimitPrice:   SafeAssertFloat64(args["lower_limit_price"], 0.0),
+			},
+		}, nil
+	}
+	return nil, ValidationError{Parameter: "trigger_type", Message: "must be 'single' or 'two-leg'"}
+}
+
+func gttLeg(name string, tp kiteconnect.TriggerParams) orders.GTTLeg {
+	return orders.GTTLeg{Name: name, Trigger: tp.TriggerValue, LimitPrice: tp.LimitPrice, Quantity: tp.Quantity}
+}
+
+// validateGTTParams checks the triggers of a normalized GTT against its
+// last price.
+func validateGTTParams(params kiteconnect.GTTParams) error {
+	switch trigger := params.Trigger.(type) {
+	case *kiteconnect.GTTSingleLegTrigger:
+		return orders.ValidateSingleGTT(params.LastPrice, gttLeg("", trigger.TriggerParams))
+	case *kiteconnect.GTTOneCancelsOtherTrigger:
+		return orders.ValidateTwoLegGTT(params.LastPrice, gttLeg("upper_", trigger.Upper), gttLeg("lower_", trigger.Lower))
+	}
+	return nil
+}
+
+// prepareGTTParams normalizes a GTT to the instrument's tick and lot sizes
+// and validates its triggers.
+func prepareGTTParams(manager *kc.Manager, args map[string]interface{}, params *kiteconnect.GTTParams) (*orders.Normalizer, error) {
+	normalizer, err := newOrderNormalizer(manager, args, params.Exchange, params.Tradingsymbol, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeGTTParams(normalizer, params); err != nil {
+		return nil, err
+	}
+	if err := validateGTTParams(*params); err != nil {
+		return nil, err
+	}
+	return normalizer, nil
+}
+
+// mergeGTTTrigger fills the values a modification leaves out from the
+// existing GTT, so that one leg of a two-leg GTT can be moved without
+// restating the other. Kite lists the legs of a two-leg GTT lower first.
+func mergeGTTTrigger(existing kiteconnect.GTT, params *kiteconnect.GTTParams) {
+	if params.Product == "" && len(existing.Orders) > 0 {
+		params.Product = existing.Orders[0].Product
+	}
+	if existing.Type != params.Trigger.Type() || len(existing.Orders) != len(existing.Condition.TriggerValues) {
+		return
+	}
+
+	fill := func(tp *kiteconnect.TriggerParams, i int) {
+		if tp.TriggerValue == 0 {
+			tp.TriggerValue = existing.Condition.TriggerValues[i]
+		}
+		if tp.LimitPrice == 0 {
+			tp.LimitPrice = existing.Orders[i].Price
+		}
+		if tp.Quantity == 0 {
+			tp.Quantity = existing.Orders[i].Quantity
+		}
+	}
+
+	switch trigger := params.Trigger.(type) {
+	case *kiteconnect.GTTSingleLegTrigger:
+		if len(existing.Orders) == 1 {
+			fill(&trigger.TriggerParams, 0)
+		}
+	case *kiteconnect.GTTOneCancelsOtherTrigger:
+		if len(existing.Orders) == 2 {
+			fill(&trigger.Lower, 0)
+			fill(&trigger.Upper, 1)
+		}
+	}
+}
+
+// filterGTTs returns the GTTs on instrument, given as EXCHANGE:TRADINGSYMBOL
+// or just the trading symbol, with the given status. Empty filters match
+// every GTT.
+func filterGTTs(gtts kiteconnect.GTTs, instrument, status string) []kiteconnect.GTT {
+	exchange, symbol, ok := strings.Cut(instrument, ":")
+	if !ok {
+		exchange, symbol = "", instrument
+	}
+
+	var out []kiteconnect.GTT
+	for _, gtt := range gtts {
+		if exchange != "" && !strings.EqualFold(gtt.Condition.Exchange, exchange) {
+			continue
+		}
+		if symbol != "" && !strings.EqualFold(gtt.Condition.Tradingsymbol, symbol) {
+			continue
+		}
+		if status != "" && !strings.EqualFold(gtt.Status, status) {
+			continue
+		}
+		out = append(out, gtt)
+	}
+	return out
+}
+
+type GetGTTTool struct{}
+
+func (*GetGTTTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_gtt",
+		mcp.WithDescription("Get a single GTT (Good Till Triggered) order with its trigger condition, orders and status"),
+		mcp.WithNumber("trigger_id",
+			mcp.Description("The ID of the GTT order"),
+			mcp.Required(),
+		),
+	)
+}
+
+func (*GetGTTTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_gtt")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "trigger_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		triggerID := SafeAssertInt(args["trigger_id"], 0)
+
+		return handler.WithSession(ctx, "get_gtt", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			gtt, err := session.Kite.Client.GetGTT(triggerID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get GTT order", "trigger_id", triggerID, "error", err)
+				return mcp.NewToolResultError("Failed to get GTT order"), nil
+			}
+
+			return handler.MarshalResponse(gtt, "get_gtt")
+		})
+	}
+}
+
+// GTTDeleteFailure is a GTT that could not be deleted.
+type GTTDeleteFailure struct {
+	TriggerID int    `json:"trigger_id"`
+	Error     string `json:"error"`
+}
+
+// DeleteGTTsResponse reports the outcome of a bulk GTT deletion.
+type DeleteGTTsResponse struct {
+	Matched []int              `json:"matched"`
+	Deleted []int              `json:"deleted"`
+	Failed  []GTTDeleteFailure `json:"failed,omitempty"`
+	DryRun  bool               `json:"dry_run,omitempty"`
+}
+
+type DeleteGTTsTool struct{}
+
+func (*DeleteGTTsTool) Tool() mcp.Tool {
+	return mcp.NewTool("delete_gtts",
+		mcp.WithDescription("Delete every GTT order matching an instrument and/or status. At least one filter is required."),
+		mcp.WithString("instrument",
+			mcp.Description("Instrument whose GTTs to delete, as EXCHANGE:TRADINGSYMBOL (eg. NSE:INFY) or just the trading symbol"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Only delete GTTs with this status"),
+			mcp.Enum(gttStatuses...),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("List the matching GTTs without deleting them. Default: false"),
+		),
+	)
+}
+
+func (*DeleteGTTsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "delete_gtts")
+		args := request.GetArguments()
+
+		instrument := strings.TrimSpace(SafeAssertString(args["instrument"], ""))
+		status := SafeAssertString(args["status"], "")
+		dryRun := SafeAssertBool(args["dry_run"], false)
+		if instrument == "" && status == "" {
+			return mcp.NewToolResultError("Specify an instrument, a status or both to select the GTTs to delete"), nil
+		}
+
+		return handler.WithSession(ctx, "delete_gtts", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			gtts, err := session.Kite.Client.GetGTTs()
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get GTT orders", "error", err)
+				return mcp.NewToolResultError("Failed to get GTT orders"), nil
+			}
+
+			resp := DeleteGTTsResponse{Matched: []int{}, Deleted: []int{}, DryRun: dryRun}
+			for _, gtt := range filterGTTs(gtts, instrument, status) {
+				resp.Matched = append(resp.Matched, gtt.ID)
+				if dryRun {
+					continue
+				}
+				if _, err := session.Kite.Client.DeleteGTT(gtt.ID); err != nil {
+					handler.manager.Logger.Error("Failed to delete GTT order", "trigger_id", gtt.ID, "error", err)
+					resp.Failed = append(resp.Failed, GTTDeleteFailure{TriggerID: gtt.ID, Error: err.Error()})
+					continue
+				}
+				resp.Deleted = append(resp.Deleted, gtt.ID)
+			}
+
+			return handler.MarshalResponse(resp, "delete_gtts")
+		})
+	}
+}
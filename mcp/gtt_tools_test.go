@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+func newGTTTestManager(t *testing.T) *kc.Manager {
+	t.Helper()
+	config := instruments.DefaultUpdateConfig()
+	config.EnableScheduler = false
+	im, err := instruments.New(instruments.Config{
+		UpdateConfig: config,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		TestData: map[uint32]*instruments.Instrument{
+			12345: {ID: "NFO:NIFTY24JANFUT", InstrumentToken: 12345, Exchange: "NFO", Tradingsymbol: "NIFTY24JANFUT", TickSize: 0.05, LotSize: 50},
+		},
+	})
+	require.NoError(t, err)
+	return &kc.Manager{Instruments: im}
+}
+
+func TestGTTTrigger(t *testing.T) {
+	trigger, err := gttTrigger(map[string]interface{}{"trigger_type": "single", "trigger_value": 100.0, "quantity": 5.0, "limit_price": 99.0})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{100}, trigger.TriggerValues())
+
+	trigger, err = gttTrigger(map[string]interface{}{"trigger_type": "two-leg", "upper_trigger_value": 120.0, "lower_trigger_value": 80.0})
+	require.NoError(t, err)
+	assert.Equal(t, []float64{80, 120}, trigger.TriggerValues())
+
+	_, err = gttTrigger(map[string]interface{}{"trigger_type": "oco"})
+	assert.ErrorContains(t, err, "trigger_type")
+}
+
+func TestPrepareGTTParams(t *testing.T) {
+	manager := newGTTTestManager(t)
+	twoLeg := func(upper, lower float64, qty float64) kiteconnect.GTTParams {
+		return kiteconnect.GTTParams{
+			Exchange:        "NFO",
+			Tradingsymbol:   "NIFTY24JANFUT",
+			LastPrice:       21500,
+			TransactionType: "SELL",
+			Trigger: &kiteconnect.GTTOneCancelsOtherTrigger{
+				Upper: kiteconnect.TriggerParams{TriggerValue: upper, LimitPrice: upper, Quantity: qty},
+				Lower: kiteconnect.TriggerParams{TriggerValue: lower, LimitPrice: lower, Quantity: qty},
+			},
+		}
+	}
+
+	t.Run("aligns to tick and lot", func(t *testing.T) {
+		params := twoLeg(22000.02, 21000.04, 120)
+		n, err := prepareGTTParams(manager, map[string]interface{}{}, &params)
+		require.NoError(t, err)
+		trigger := params.Trigger.(*kiteconnect.GTTOneCancelsOtherTrigger)
+		assert.Equal(t, 22000.0, trigger.Upper.TriggerValue)
+		assert.Equal(t, 21000.05, trigger.Lower.TriggerValue)
+		assert.Equal(t, 100.0, trigger.Upper.Quantity)
+		assert.NotEmpty(t, n.Adjustments)
+	})
+
+	t.Run("strict rejects misaligned quantity", func(t *testing.T) {
+		params := twoLeg(22000, 21000, 120)
+		_, err := prepareGTTParams(manager, map[string]interface{}{"normalization": "strict"}, &params)
+		assert.ErrorIs(t, err, orders.ErrNotLotMultiple)
+	})
+
+	t.Run("upper below lower", func(t *testing.T) {
+		params := twoLeg(21000, 22000, 50)
+		_, err := prepareGTTParams(manager, map[string]interface{}{}, &params)
+		assert.ErrorContains(t, err, "upper_trigger_value 21000 must be above lower_trigger_value 22000")
+	})
+
+	t.Run("trigger too close to the last price", func(t *testing.T) {
+		params := twoLeg(21510, 21000, 50)
+		_, err := prepareGTTParams(manager, map[string]interface{}{}, &params)
+		assert.ErrorIs(t, err, orders.ErrTriggerTooClose)
+	})
+}
+
+func TestMergeGTTTrigger(t *testing.T) {
+	existing := kiteconnect.GTT{
+		Type:      kiteconnect.GTTTypeOCO,
+		Condition: kiteconnect.GTTCondition{TriggerValues: []float64{1400, 1600}},
+		Orders: []kiteconnect.Order{
+			{Price: 1398, Quantity: 10, Product: "CNC"},
+			{Price: 1598, Quantity: 10, Product: "CNC"},
+		},
+	}
+
+	// Move only the stop-loss leg.
+	params := kiteconnect.GTTParams{Trigger: &kiteconnect.GTTOneCancelsOtherTrigger{
+		Lower: kiteconnect.TriggerParams{TriggerValue: 1450, LimitPrice: 1448},
+	}}
+	mergeGTTTrigger(existing, &params)
+
+	trigger := params.Trigger.(*kiteconnect.GTTOneCancelsOtherTrigger)
+	assert.Equal(t, kiteconnect.TriggerParams{TriggerValue: 1450, LimitPrice: 1448, Quantity: 10}, trigger.Lower)
+	assert.Equal(t, kiteconnect.TriggerParams{TriggerValue: 1600, LimitPrice: 1598, Quantity: 10}, trigger.Upper)
+	assert.Equal(t, "CNC", params.Product)
+
+	// A change of type keeps only the product.
+	single := kiteconnect.GTTParams{Product: "NRML", Trigger: &kiteconnect.GTTSingleLegTrigger{}}
+	mergeGTTTrigger(existing, &single)
+	assert.Equal(t, kiteconnect.TriggerParams{}, single.Trigger.(*kiteconnect.GTTSingleLegTrigger).TriggerParams)
+	assert.Equal(t, "NRML", single.Product)
+}
+
+func TestFilterGTTs(t *testing.T) {
+	gtts := kiteconnect.GTTs{
+		{ID: 1, Status: "active", Condition: kiteconnect.GTTCondition{Exchange: "NSE", Tradingsymbol: "INFY"}},
+		{ID: 2, Status: "triggered", Condition: kiteconnect.GTTCondition{Exchange: "NSE", Tradingsymbol: "INFY"}},
+		{ID: 3, Status: "active", Condition: kiteconnect.GTTCondition{Exchange: "BSE", Tradingsymbol: "INFY"}},
+		{ID: 4, Status: "active", Condition: kiteconnect.GTTCondition{Exchange: "NSE", Tradingsymbol: "TCS"}},
+	}
+	ids := func(list []kiteconnect.GTT) []int {
+		out := []int{}
+		for _, gtt := range list {
+			out = append(out, gtt.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, ids(filterGTTs(gtts, "infy", "")))
+	assert.Equal(t, []int{1, 2}, ids(filterGTTs(gtts, "NSE:INFY", "")))
+	assert.Equal(t, []int{1}, ids(filterGTTs(gtts, "NSE:INFY", "active")))
+	assert.Equal(t, []int{2}, ids(filterGTTs(gtts, "", "triggered")))
+	assert.Equal(t, []int{1, 2, 3, 4}, ids(filterGTTs(gtts, "", "")))
+}
@@ -0,0 +1,40 @@
+package mcp
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/httpfixture"
+)
+
+// newReplayServer serves the tools from Kite API calls recorded in the
+// fixture file, captured with HTTP_FIXTURES_MODE=record.
+func newReplayServer(t *testing.T, fixture string) *e2eServer {
+	t.Helper()
+	replayer, err := httpfixture.NewReplayer(fixture)
+	require.NoError(t, err)
+	return startE2EServer(t, kc.Config{HTTPClient: &http.Client{Transport: replayer}})
+}
+
+func TestReplayedPortfolio(t *testing.T) {
+	e := newReplayServer(t, "testdata/http_fixtures/portfolio.json")
+
+	assert.Equal(t, httpfixture.RedactedUserID, e.callJSON(t, "get_profile", nil)["user_id"])
+
+	var holdings []kiteconnect.Holding
+	require.NoError(t, json.Unmarshal([]byte(e.call(t, "get_holdings", nil)), &holdings))
+	require.Len(t, holdings, 2)
+	assert.Equal(t, "INFY", holdings[0].Tradingsymbol)
+	assert.Equal(t, -1260.0, holdings[1].PnL)
+
+	assert.Contains(t, e.call(t, "get_orders", nil), "240603000123456")
+
+	// Calls missing from the fixture fail like a broken connection would
+	result := e.callResult(t, "get_positions", nil)
+	assert.True(t, result.IsError)
+}
@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+)
+
+const (
+	// instrumentNotificationLogger names the source of instrument change
+	// notifications.
+	instrumentNotificationLogger = "kite.instruments"
+
+	defaultInstrumentChangesLimit = 200
+)
+
+// PositionChange is an open position in a contract that changed.
+type PositionChange struct {
+	Exchange      string                         `json:"exchange"`
+	Tradingsymbol string                         `json:"tradingsymbol"`
+	Product       string                         `json:"product"`
+	Quantity      int                            `json:"quantity"`
+	Changes       []instruments.InstrumentChange `json:"changes"`
+}
+
+// positionsInChangedContracts returns the open positions in contracts that
+// were delisted before expiry, changed lot size or circuit band, or went
+// inactive.
+func positionsInChangedContracts(diff instruments.Diff, positions []kiteconnect.Position) []PositionChange {
+	changed := make(map[uint32][]instruments.InstrumentChange)
+	for _, c := range diff.Changes {
+		if c.Kind == instruments.ChangeListed || c.Expired {
+			continue
+		}
+		changed[c.InstrumentToken] = append(changed[c.InstrumentToken], c)
+	}
+
+	var out []PositionChange
+	for _, p := range positions {
+		if p.Quantity == 0 || len(changed[p.InstrumentToken]) == 0 {
+			continue
+		}
+		out = append(out, PositionChange{
+			Exchange:      p.Exchange,
+			Tradingsymbol: p.Tradingsymbol,
+			Product:       p.Product,
+			Quantity:      p.Quantity,
+			Changes:       changed[p.InstrumentToken],
+		})
+	}
+	return out
+}
+
+// RegisterInstrumentChangeNotifications warns the MCP sessions logged in
+// to Kite about their open positions in contracts that changed when the
+// instruments are loaded again, as logging notifications.
+func RegisterInstrumentChangeNotifications(srv *server.MCPServer, manager *kc.Manager) {
+	manager.Instruments.OnChange(func(diff instruments.Diff) {
+		// Fetching positions goes to Kite, so don't hold up the load
+		go func() {
+			for sessionID, session := range manager.KiteSessions() {
+				positions, err := session.Kite.Client.GetPositions()
+				if err != nil {
+					manager.Logger.Debug("Failed to get positions to check instrument changes", "session_id", sessionID, "error", err)
+					continue
+				}
+				affected := positionsInChangedContracts(diff, positions.Net)
+				if len(affected) == 0 {
+					continue
+				}
+				params := map[string]any{
+					"level":  mcp.LoggingLevelWarning,
+					"logger": instrumentNotificationLogger,
+					"data": map[string]any{
+						"message":   fmt.Sprintf("%d open positions are in contracts that changed since the last instruments load", len(affected)),
+						"positions": affected,
+					},
+				}
+				if err := srv.SendNotificationToSpecificClient(sessionID, "notifications/message", params); err != nil {
+					manager.Logger.Debug("Failed to send instrument change notification", "session_id", sessionID, "error", err)
+				}
+			}
+		}()
+	})
+}
+
+// InstrumentChangesResponse is the changes of the recent instruments loads.
+type InstrumentChangesResponse struct {
+	Loads     []instruments.Diff `json:"loads"`
+	Truncated bool               `json:"truncated,omitempty"`
+	Note      string             `json:"note,omitempty"`
+}
+
+type InstrumentChangesTool struct{}
+
+func (*InstrumentChangesTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_instrument_changes",
+		mcp.WithDescription("Get what changed in the instruments between the daily loads: new listings, delistings, lot size changes, circuit band changes and strikes that went inactive. Delistings of contracts at expiry are left out unless include_expired is set."),
+		mcp.WithString("kind",
+			mcp.Description("Only changes of this kind (Optional)"),
+			mcp.Enum(instruments.ChangeKinds...),
+		),
+		mcp.WithString("exchange",
+			mcp.Description("Only changes on this exchange, e.g. NSE or NFO (Optional)"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Only changes to the instruments of this underlying or company name, e.g. NIFTY (Optional)"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Only changes to this instrument (Optional)"),
+		),
+		mcp.WithBoolean("include_expired",
+			mcp.Description("Include contracts delisted at expiry. Default: false"),
+		),
+		mcp.WithNumber("loads",
+			mcp.Description("Number of recent loads to return the changes of, latest first. Default: 1"),
+			mcp.Min(1),
+			mcp.Max(7),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of changes per load. Default: %d", defaultInstrumentChangesLimit)),
+			mcp.Min(1),
+		),
+	)
+}
+
+func (*InstrumentChangesTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_instrument_changes")
+		args := request.GetArguments()
+
+		kind := SafeAssertString(args["kind"], "")
+		exchange := strings.ToUpper(SafeAssertString(args["exchange"], ""))
+		name := strings.ToUpper(SafeAssertString(args["name"], ""))
+		tradingsymbol := strings.ToUpper(SafeAssertString(args["tradingsymbol"], ""))
+		includeExpired := SafeAssertBool(args["include_expired"], false)
+		loads := SafeAssertInt(args["loads"], 1)
+		limit := SafeAssertInt(args["limit"], defaultInstrumentChangesLimit)
+		if loads < 1 || limit < 1 {
+			return mcp.NewToolResultError("loads and limit must be positive"), nil
+		}
+
+		resp := InstrumentChangesResponse{Loads: []instruments.Diff{}}
+		diffs := manager.Instruments.Changes()
+		if len(diffs) == 0 {
+			resp.Note = "No changes recorded yet: the instruments have been loaded once since the server started"
+			return handler.MarshalResponse(resp, "get_instrument_changes")
+		}
+		if len(diffs) > loads {
+			diffs = diffs[:loads]
+		}
+
+		for _, diff := range diffs {
+			filtered := diff.Filter(func(c instruments.InstrumentChange) bool {
+				return (kind == "" || c.Kind == kind) &&
+					(exchange == "" || c.Exchange == exchange) &&
+					(name == "" || strings.ToUpper(c.Name) == name) &&
+					(tradingsymbol == "" || c.Tradingsymbol == tradingsymbol) &&
+					(includeExpired || !c.Expired)
+			})
+			if len(filtered.Changes) > limit {
+				filtered.Changes = filtered.Changes[:limit]
+				resp.Truncated = true
+			}
+			if filtered.Changes == nil {
+				filtered.Changes = []instruments.InstrumentChange{}
+			}
+			resp.Loads = append(resp.Loads, filtered)
+		}
+		return handler.MarshalResponse(resp, "get_instrument_changes")
+	}
+}
@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
+)
+
+func changeTestInstruments() map[uint32]*instruments.Instrument {
+	return map[uint32]*instruments.Instrument{
+		408065: {ID: "NSE:INFY", InstrumentToken: 408065, Tradingsymbol: "INFY", Name: "INFOSYS", Exchange: "NSE", InstrumentType: "EQ", LotSize: 1, Active: true},
+		1001:   {ID: "NFO:NIFTYFUT", InstrumentToken: 1001, Tradingsymbol: "NIFTYFUT", Name: "NIFTY", Exchange: "NFO", InstrumentType: "FUT", LotSize: 75, Active: true},
+		1002:   {ID: "NFO:NIFTYOLDCE", InstrumentToken: 1002, Tradingsymbol: "NIFTYOLDCE", Name: "NIFTY", Exchange: "NFO", InstrumentType: "CE", ExpiryDate: "2020-01-30", LotSize: 75, Active: true},
+		1003:   {ID: "NFO:NIFTY22500CE", InstrumentToken: 1003, Tradingsymbol: "NIFTY22500CE", Name: "NIFTY", Exchange: "NFO", InstrumentType: "CE", LotSize: 75, Active: true},
+	}
+}
+
+func TestGetInstrumentChanges(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	updateConfig := instruments.DefaultUpdateConfig()
+	updateConfig.EnableScheduler = false
+	im, err := instruments.New(instruments.Config{UpdateConfig: updateConfig, Logger: logger, TestData: changeTestInstruments()})
+	require.NoError(t, err)
+
+	broker := kitefake.New()
+	e := startE2EServer(t, kc.Config{
+		Logger:             logger,
+		InstrumentsManager: im,
+		KiteClientFactory: func(apiKey string) kc.KiteClient {
+			return broker.Client(apiKey)
+		},
+	})
+
+	var received []instruments.Diff
+	im.OnChange(func(d instruments.Diff) { received = append(received, d) })
+
+	next := changeTestInstruments()
+	next[1001].LotSize = 65
+	next[1003].Active = false
+	delete(next, 1002)
+	next[408066] = &instruments.Instrument{ID: "NSE:NEWCO", InstrumentToken: 408066, Tradingsymbol: "NEWCO", Name: "NEWCO", Exchange: "NSE", InstrumentType: "EQ", LotSize: 1, Active: true}
+	im.Replace(next)
+	require.Len(t, received, 1)
+
+	changes := func(args map[string]any) InstrumentChangesResponse {
+		var resp InstrumentChangesResponse
+		require.NoError(t, json.Unmarshal([]byte(e.call(t, "get_instrument_changes", args)), &resp))
+		return resp
+	}
+	symbols := func(resp InstrumentChangesResponse) []string {
+		require.Len(t, resp.Loads, 1)
+		var out []string
+		for _, c := range resp.Loads[0].Changes {
+			out = append(out, c.Kind+" "+c.Tradingsymbol)
+		}
+		return out
+	}
+
+	resp := changes(nil)
+	assert.Equal(t, []string{"listed NEWCO", "lot_size NIFTYFUT", "inactive NIFTY22500CE"}, symbols(resp))
+	assert.Equal(t, 1, resp.Loads[0].Counts[instruments.ChangeDelisted])
+
+	assert.Equal(t, []string{"listed NEWCO", "delisted NIFTYOLDCE", "lot_size NIFTYFUT", "inactive NIFTY22500CE"}, symbols(changes(map[string]any{"include_expired": true})))
+	assert.Equal(t, []string{"lot_size NIFTYFUT"}, symbols(changes(map[string]any{"kind": "lot_size"})))
+	assert.Equal(t, []string{"lot_size NIFTYFUT", "inactive NIFTY22500CE"}, symbols(changes(map[string]any{"exchange": "nfo", "name": "nifty"})))
+	assert.Equal(t, []string{"inactive NIFTY22500CE"}, symbols(changes(map[string]any{"tradingsymbol": "NIFTY22500CE"})))
+
+	resp = changes(map[string]any{"limit": 1})
+	assert.True(t, resp.Truncated)
+	assert.Len(t, resp.Loads[0].Changes, 1)
+
+	im.Replace(changeTestInstruments())
+	resp = changes(map[string]any{"loads": 5})
+	require.Len(t, resp.Loads, 2)
+	assert.False(t, resp.Loads[0].To.Before(resp.Loads[1].To), "latest load first")
+	assert.Equal(t, 1, resp.Loads[0].Counts[instruments.ChangeListed]) // NIFTYOLDCE is back
+}
+
+func TestPositionsInChangedContracts(t *testing.T) {
+	diff := instruments.Diff{Changes: []instruments.InstrumentChange{
+		{Kind: instruments.ChangeListed, InstrumentToken: 1},
+		{Kind: instruments.ChangeDelisted, InstrumentToken: 2, Expired: true},
+		{Kind: instruments.ChangeLotSize, InstrumentToken: 3, OldLotSize: 75, NewLotSize: 65},
+		{Kind: instruments.ChangeInactive, InstrumentToken: 3},
+		{Kind: instruments.ChangeDelisted, InstrumentToken: 4},
+	}}
+	positions := []kiteconnect.Position{
+		{Tradingsymbol: "NEW", InstrumentToken: 1, Quantity: 10},
+		{Tradingsymbol: "EXPIRED", InstrumentToken: 2, Quantity: 75},
+		{Tradingsymbol: "LOTSIZE", InstrumentToken: 3, Quantity: -150, Product: "NRML"},
+		{Tradingsymbol: "CLOSED", InstrumentToken: 4, Quantity: 0},
+	}
+
+	affected := positionsInChangedContracts(diff, positions)
+	require.Len(t, affected, 1)
+	assert.Equal(t, "LOTSIZE", affected[0].Tradingsymbol)
+	assert.Equal(t, -150, affected[0].Quantity)
+	assert.Len(t, affected[0].Changes, 2)
+}
@@ -0,0 +1,476 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+)
+
+// maxMarginOrders caps the number of orders per margin or charges request.
+const maxMarginOrders = 50
+
+// marginOrderSchema describes an order in the margin calculator tools.
+var marginOrderSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"exchange":         map[string]any{"type": "string", "enum": []string{"NSE", "BSE", "MCX", "NFO", "BFO", "CDS", "BCD"}},
+		"tradingsymbol":    map[string]any{"type": "string"},
+		"transaction_type": map[string]any{"type": "string", "enum": []string{"BUY", "SELL"}},
+		"variety":          map[string]any{"type": "string", "enum": []string{"regular", "co", "amo", "iceberg", "auction"}},
+		"product":          map[string]any{"type": "string", "enum": []string{"CNC", "NRML", "MIS", "MTF"}},
+		"order_type":       map[string]any{"type": "string", "enum": []string{"MARKET", "LIMIT", "SL", "SL-M"}},
+		"quantity":         map[string]any{"type": "number", "minimum": 1},
+		"price":            map[string]any{"type": "number"},
+		"trigger_price":    map[string]any{"type": "number"},
+	},
+	"required": []string{"exchange", "tradingsymbol", "transaction_type", "product", "order_type", "quantity"},
+}
+
+// chargesOrderSchema describes an executed order in get_order_charges.
+var chargesOrderSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"order_id":         map[string]any{"type": "string"},
+		"exchange":         map[string]any{"type": "string", "enum": []string{"NSE", "BSE", "MCX", "NFO", "BFO", "CDS", "BCD"}},
+		"tradingsymbol":    map[string]any{"type": "string"},
+		"transaction_type": map[string]any{"type": "string", "enum": []string{"BUY", "SELL"}},
+		"variety":          map[string]any{"type": "string", "enum": []string{"regular", "co", "amo", "iceberg", "auction"}},
+		"product":          map[string]any{"type": "string", "enum": []string{"CNC", "NRML", "MIS", "MTF"}},
+		"order_type":       map[string]any{"type": "string", "enum": []string{"MARKET", "LIMIT", "SL", "SL-M"}},
+		"quantity":         map[string]any{"type": "number", "minimum": 1},
+		"average_price":    map[string]any{"type": "number"},
+	},
+	"required": []string{"exchange", "tradingsymbol", "transaction_type", "product", "order_type", "quantity", "average_price"},
+}
+
+// parseOrderList extracts the array of order objects from a tool argument.
+func parseOrderList(v interface{}) ([]map[string]interface{}, error) {
+	items, ok := v.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, ValidationError{Parameter: "orders", Message: "must be a non-empty array of orders"}
+	}
+	if len(items) > maxMarginOrders {
+		return nil, ValidationError{Parameter: "orders", Message: fmt.Sprintf("cannot contain more than %d orders", maxMarginOrders)}
+	}
+
+	out := make([]map[string]interface{}, 0, len(items))
+	for i, item := range items {
+		order, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, ValidationError{Parameter: fmt.Sprintf("orders[%d]", i), Message: "must be an object"}
+		}
+		out = append(out, order)
+	}
+	return out, nil
+}
+
+// validateOrderFields checks the fields shared by margin and charges orders.
+func validateOrderFields(i int, order map[string]interface{}, required ...string) error {
+	if err := ValidateRequired(order, required...); err != nil {
+		return fmt.Errorf("orders[%d]: %w", i, err)
+	}
+	if side := strings.ToUpper(SafeAssertString(order["transaction_type"], "")); side != kiteconnect.TransactionTypeBuy && side != kiteconnect.TransactionTypeSell {
+		return fmt.Errorf("orders[%d]: transaction_type must be BUY or SELL", i)
+	}
+	if SafeAssertFloat64(order["quantity"], 0) <= 0 {
+		return fmt.Errorf("orders[%d]: quantity must be greater than 0", i)
+	}
+	return nil
+}
+
+// parseMarginOrders converts and validates the orders argument of the margin tools.
+func parseMarginOrders(v interface{}) ([]kiteconnect.OrderMarginParam, error) {
+	orders, err := parseOrderList(v)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]kiteconnect.OrderMarginParam, 0, len(orders))
+	for i, order := range orders {
+		if err := validateOrderFields(i, order, "exchange", "tradingsymbol", "transaction_type", "product", "order_type", "quantity"); err != nil {
+			return nil, err
+		}
+
+		p := kiteconnect.OrderMarginParam{
+			Exchange:        SafeAssertString(order["exchange"], ""),
+			Tradingsymbol:   SafeAssertString(order["tradingsymbol"], ""),
+			TransactionType: strings.ToUpper(SafeAssertString(order["transaction_type"], "")),
+			Variety:         SafeAssertString(order["variety"], kiteconnect.VarietyRegular),
+			Product:         SafeAssertString(order["product"], ""),
+			OrderType:       SafeAssertString(order["order_type"], ""),
+			Quantity:        SafeAssertFloat64(order["quantity"], 0),
+			Price:           SafeAssertFloat64(order["price"], 0),
+			TriggerPrice:    SafeAssertFloat64(order["trigger_price"], 0),
+		}
+
+		switch p.OrderType {
+		case kiteconnect.OrderTypeLimit:
+			if p.Price <= 0 {
+				return nil, fmt.Errorf("orders[%d]: price is required for LIMIT orders", i)
+			}
+		case kiteconnect.OrderTypeSL:
+			if p.Price <= 0 || p.TriggerPrice <= 0 {
+				return nil, fmt.Errorf("orders[%d]: price and trigger_price are required for SL orders", i)
+			}
+		case kiteconnect.OrderTypeSLM:
+			if p.TriggerPrice <= 0 {
+				return nil, fmt.Errorf("orders[%d]: trigger_price is required for SL-M orders", i)
+			}
+		}
+
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// parseChargesOrders converts and validates the orders argument of get_order_charges.
+func parseChargesOrders(v interface{}) ([]kiteconnect.OrderChargesParam, error) {
+	orders, err := parseOrderList(v)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]kiteconnect.OrderChargesParam, 0, len(orders))
+	for i, order := range orders {
+		if err := validateOrderFields(i, order, "exchange", "tradingsymbol", "transaction_type", "product", "order_type", "quantity", "average_price"); err != nil {
+			return nil, err
+		}
+
+		p := kiteconnect.OrderChargesParam{
+			OrderID:         SafeAssertString(order["order_id"], ""),
+			Exchange:        SafeAssertString(order["exchange"], ""),
+			Tradingsymbol:   SafeAssertString(order["tradingsymbol"], ""),
+			TransactionType: strings.ToUpper(SafeAssertString(order["transaction_type"], "")),
+			Variety:         SafeAssertString(order["variety"], kiteconnect.VarietyRegular),
+			Product:         SafeAssertString(order["product"], ""),
+			OrderType:       SafeAssertString(order["order_type"], ""),
+			Quantity:        SafeAssertFloat64(order["quantity"], 0),
+			AveragePrice:    SafeAssertFloat64(order["average_price"], 0),
+		}
+		if p.AveragePrice <= 0 {
+			return nil, fmt.Errorf("orders[%d]: average_price must be greater than 0", i)
+		}
+
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+type OrderMarginsTool struct{}
+
+func (*OrderMarginsTool) Tool() mcp.Tool {
+	return mcp.NewTool("calculate_order_margins",
+		mcp.WithDescription("Calculate the margin required for one or more orders before placing them, including SPAN, exposure, option premium and charges. Each order is evaluated independently; use calculate_basket_margins for spread benefits. Also reports whether the account's available margin in each segment covers the orders in it."),
+		mcp.WithArray("orders",
+			mcp.Description("Orders to evaluate"),
+			mcp.Required(),
+			mcp.MinItems(1),
+			mcp.MaxItems(maxMarginOrders),
+			mcp.Items(marginOrderSchema),
+		),
+		mcp.WithBoolean("compact",
+			mcp.Description("Return only the total margin per order without the breakdown. Default: false"),
+		),
+	)
+}
+
+func (*OrderMarginsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "calculate_order_margins")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "orders"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		orders, err := parseMarginOrders(args["orders"])
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		compact := SafeAssertBool(args["compact"], false)
+
+		return handler.WithSession(ctx, "calculate_order_margins", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			margins, err := session.Kite.Client.GetOrderMargins(kiteconnect.GetMarginParams{
+				OrderParams: orders,
+				Compact:     compact,
+			})
+			if err != nil {
+				handler.manager.Logger.Error("Failed to calculate order margins", "error", err)
+				return mcp.NewToolResultError("Failed to calculate order margins"), nil
+			}
+
+			var total float64
+			for _, m := range margins {
+				total += m.Total
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"orders":       margins,
+				"total":        total,
+				"availability": marginAvailability(session.Kite.Client, requiredBySegment(margins)),
+			}, "calculate_order_margins")
+		})
+	}
+}
+
+type BasketMarginsTool struct{}
+
+func (*BasketMarginsTool) Tool() mcp.Tool {
+	return mcp.NewTool("calculate_basket_margins",
+		mcp.WithDescription("Calculate the combined margin for a basket of orders, such as option spreads or hedged futures, including the spread benefit from offsetting legs. Returns the initial margin, the final margin after benefits, and per-order margins."),
+		mcp.WithArray("orders",
+			mcp.Description("Orders in the basket"),
+			mcp.Required(),
+			mcp.MinItems(1),
+			mcp.MaxItems(maxMarginOrders),
+			mcp.Items(marginOrderSchema),
+		),
+		mcp.WithBoolean("consider_positions",
+			mcp.Description("Take existing open positions into account when computing the basket margin. Default: true"),
+			mcp.DefaultBool(true),
+		),
+		mcp.WithBoolean("compact",
+			mcp.Description("Return only the total margin per order without the breakdown. Default: false"),
+		),
+	)
+}
+
+func (*BasketMarginsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "calculate_basket_margins")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "orders"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		orders, err := parseMarginOrders(args["orders"])
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		considerPositions := SafeAssertBool(args["consider_positions"], true)
+		compact := SafeAssertBool(args["compact"], false)
+
+		return handler.WithSession(ctx, "calculate_basket_margins", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			basket, err := session.Kite.Client.GetBasketMargins(kiteconnect.GetBasketParams{
+				OrderParams:       orders,
+				Compact:           compact,
+				ConsiderPositions: considerPositions,
+			})
+			if err != nil {
+				handler.manager.Logger.Error("Failed to calculate basket margins", "error", err)
+				return mcp.NewToolResultError("Failed to calculate basket margins"), nil
+			}
+
+			// Kite reports the spread benefit for the whole basket, so a
+			// basket spanning segments is checked without it
+			required := requiredBySegment(basket.Orders)
+			if len(required) == 1 {
+				for segment := range required {
+					required[segment] = basket.Final.Total
+				}
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"initial":        basket.Initial,
+				"final":          basket.Final,
+				"orders":         basket.Orders,
+				"spread_benefit": math.Max(0, basket.Initial.Total-basket.Final.Total),
+				"availability":   marginAvailability(session.Kite.Client, required),
+			}, "calculate_basket_margins")
+		})
+	}
+}
+
+type OrderChargesTool struct{}
+
+func (*OrderChargesTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_order_charges",
+		mcp.WithDescription("Calculate brokerage, STT/CTT, exchange turnover charges, SEBI fees, stamp duty and GST for executed or hypothetical orders at a given average price."),
+		mcp.WithArray("orders",
+			mcp.Description("Orders to calculate charges for"),
+			mcp.Required(),
+			mcp.MinItems(1),
+			mcp.MaxItems(maxMarginOrders),
+			mcp.Items(chargesOrderSchema),
+		),
+	)
+}
+
+func (*OrderChargesTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_order_charges")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "orders"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		orders, err := parseChargesOrders(args["orders"])
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return handler.WithSession(ctx, "get_order_charges", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			charges, err := session.Kite.Client.GetOrderCharges(kiteconnect.GetChargesParams{OrderParams: orders})
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get order charges", "error", err)
+				return mcp.NewToolResultError("Failed to get order charges"), nil
+			}
+
+			var total float64
+			for _, c := range charges {
+				total += c.Charges.Total
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"orders": charges,
+				"total":  total,
+			}, "get_order_charges")
+		})
+	}
+}
+
+// MarginCheck compares the margin required for the orders of a segment with
+// what the account has available in it.
+type MarginCheck struct {
+	Segment    string  `json:"segment"`
+	Required   float64 `json:"required"`
+	Available  float64 `json:"available"`
+	Shortfall  float64 `json:"shortfall"`
+	Sufficient bool    `json:"sufficient"`
+}
+
+// marginSegment returns the account segment margins are blocked from.
+func marginSegment(exchange string) string {
+	if exchange == "MCX" {
+		return "commodity"
+	}
+	return "equity"
+}
+
+// requiredBySegment adds up the margins of orders by the account segment
+// they are blocked from.
+func requiredBySegment(margins []kiteconnect.OrderMargins) map[string]float64 {
+	required := make(map[string]float64)
+	for _, m := range margins {
+		required[marginSegment(m.Exchange)] += m.Total
+	}
+	return required
+}
+
+// marginAvailability checks the margin required in each segment against the
+// funds available in it, equity first. It returns nil when the account
+// margins cannot be fetched, so that the calculation itself is still
+// reported.
+func marginAvailability(client kc.KiteClient, required map[string]float64) []MarginCheck {
+	if len(required) == 0 {
+		return nil
+	}
+	margins, err := client.GetUserMargins()
+	if err != nil {
+		return nil
+	}
+
+	var checks []MarginCheck
+	for _, segment := range []string{"equity", "commodity"} {
+		req, ok := required[segment]
+		if !ok {
+			continue
+		}
+		available := margins.Equity.Net
+		if segment == "commodity" {
+			available = margins.Commodity.Net
+		}
+		checks = append(checks, MarginCheck{
+			Segment:    segment,
+			Required:   req,
+			Available:  available,
+			Shortfall:  math.Max(0, req-available),
+			Sufficient: available >= req,
+		})
+	}
+	return checks
+}
+
+// PositionMarginEstimate is the margin and charges for a planned position,
+// used by the position sizing helpers to keep recommendations affordable.
+type PositionMarginEstimate struct {
+	MarginPerUnit    float64      `json:"margin_per_unit"`
+	Check            *MarginCheck `json:"margin_check,omitempty"`
+	MaxAffordableQty int          `json:"max_affordable_quantity"`
+	EstimatedCharges float64      `json:"estimated_round_trip_charges"`
+}
+
+// estimatePositionMargin calculates the margin and round-trip charges for a
+// position of qty units entered at price. The quantity the account can
+// afford is derived from the per-unit margin.
+func estimatePositionMargin(client kc.KiteClient, order kiteconnect.OrderMarginParam, qty int, price float64) (PositionMarginEstimate, error) {
+	var est PositionMarginEstimate
+	if qty <= 0 {
+		return est, fmt.Errorf("quantity must be greater than 0")
+	}
+
+	order.Quantity = float64(qty)
+	margins, err := client.GetOrderMargins(kiteconnect.GetMarginParams{OrderParams: []kiteconnect.OrderMarginParam{order}})
+	if err != nil {
+		return est, err
+	}
+	if len(margins) == 0 {
+		return est, fmt.Errorf("no margin returned for %s:%s", order.Exchange, order.Tradingsymbol)
+	}
+
+	est.MarginPerUnit = margins[0].Total / float64(qty)
+	if checks := marginAvailability(client, map[string]float64{marginSegment(order.Exchange): margins[0].Total}); len(checks) > 0 {
+		est.Check = &checks[0]
+	}
+	if est.Check != nil && est.MarginPerUnit > 0 {
+		est.MaxAffordableQty = int(est.Check.Available / est.MarginPerUnit)
+	}
+
+	exit := kiteconnect.TransactionTypeSell
+	if order.TransactionType == kiteconnect.TransactionTypeSell {
+		exit = kiteconnect.TransactionTypeBuy
+	}
+	legs := []kiteconnect.OrderChargesParam{
+		{Exchange: order.Exchange, Tradingsymbol: order.Tradingsymbol, TransactionType: order.TransactionType, Variety: order.Variety, Product: order.Product, OrderType: order.OrderType, Quantity: float64(qty), AveragePrice: price},
+		{Exchange: order.Exchange, Tradingsymbol: order.Tradingsymbol, TransactionType: exit, Variety: order.Variety, Product: order.Product, OrderType: order.OrderType, Quantity: float64(qty), AveragePrice: price},
+	}
+	if charges, err := client.GetOrderCharges(kiteconnect.GetChargesParams{OrderParams: legs}); err == nil {
+		for _, c := range charges {
+			est.EstimatedCharges += c.Charges.Total
+		}
+	}
+
+	return est, nil
+}
+
+// applyMarginCheck adds a margin and charges estimate to position sizing
+// output from calculateOptimalPosition, capping the recommended size to what
+// the account can afford. Failures are reported in the output rather than
+// failing the sizing itself.
+func applyMarginCheck(client kc.KiteClient, positionData map[string]interface{}, order kiteconnect.OrderMarginParam, price float64) {
+	qty, _ := positionData["recommended_position_size"].(int)
+	if qty <= 0 {
+		return
+	}
+
+	est, err := estimatePositionMargin(client, order, qty, price)
+	if err != nil {
+		positionData["margin_check_error"] = err.Error()
+		return
+	}
+	positionData["margin"] = est
+
+	if est.Check != nil && !est.Check.Sufficient {
+		positionData["recommended_position_size"] = est.MaxAffordableQty
+		positionData["margin_limited"] = true
+		positionData["unconstrained_position_size"] = qty
+	}
+}
@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
+)
+
+func TestParseMarginOrders(t *testing.T) {
+	order := func(overrides map[string]interface{}) map[string]interface{} {
+		o := map[string]interface{}{
+			"exchange":         "NFO",
+			"tradingsymbol":    "NIFTY25JAN24000CE",
+			"transaction_type": "buy",
+			"product":          "NRML",
+			"order_type":       "LIMIT",
+			"quantity":         75.0,
+			"price":            120.5,
+		}
+		for k, v := range overrides {
+			o[k] = v
+		}
+		return o
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		params, err := parseMarginOrders([]interface{}{order(nil), order(map[string]interface{}{"transaction_type": "SELL", "order_type": "MARKET"})})
+		require.NoError(t, err)
+		require.Len(t, params, 2)
+		assert.Equal(t, kiteconnect.TransactionTypeBuy, params[0].TransactionType)
+		assert.Equal(t, kiteconnect.VarietyRegular, params[0].Variety)
+		assert.Equal(t, 75.0, params[0].Quantity)
+		assert.Equal(t, 120.5, params[0].Price)
+	})
+
+	invalid := map[string]interface{}{
+		"not an array":         "orders",
+		"empty":                []interface{}{},
+		"not an object":        []interface{}{"order"},
+		"missing symbol":       []interface{}{order(map[string]interface{}{"tradingsymbol": ""})},
+		"bad side":             []interface{}{order(map[string]interface{}{"transaction_type": "HOLD"})},
+		"zero quantity":        []interface{}{order(map[string]interface{}{"quantity": 0.0})},
+		"limit without price":  []interface{}{order(map[string]interface{}{"price": nil})},
+		"sl-m without trigger": []interface{}{order(map[string]interface{}{"order_type": "SL-M"})},
+	}
+	for name, v := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseMarginOrders(v)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseChargesOrders(t *testing.T) {
+	o := map[string]interface{}{
+		"exchange":         "NSE",
+		"tradingsymbol":    "INFY",
+		"transaction_type": "SELL",
+		"product":          "CNC",
+		"order_type":       "MARKET",
+		"quantity":         10.0,
+		"average_price":    1500.0,
+		"order_id":         "250110000000001",
+	}
+	params, err := parseChargesOrders([]interface{}{o})
+	require.NoError(t, err)
+	assert.Equal(t, "250110000000001", params[0].OrderID)
+	assert.Equal(t, 1500.0, params[0].AveragePrice)
+
+	delete(o, "average_price")
+	_, err = parseChargesOrders([]interface{}{o})
+	assert.Error(t, err)
+}
+
+func TestMarginAvailabilityPerSegment(t *testing.T) {
+	broker := kitefake.New()
+	broker.SetMargins(kiteconnect.AllMargins{
+		Equity:    kiteconnect.Margins{Net: 100000},
+		Commodity: kiteconnect.Margins{Net: 20000},
+	})
+	client := broker.Client("key")
+	sess, err := client.GenerateSession("request", "secret")
+	require.NoError(t, err)
+	client.SetAccessToken(sess.AccessToken)
+
+	// The equity funds cover both orders together, but not the commodity
+	// order on their own
+	required := requiredBySegment([]kiteconnect.OrderMargins{
+		{Exchange: "NFO", Total: 40000},
+		{Exchange: "NSE", Total: 10000},
+		{Exchange: "MCX", Total: 30000},
+	})
+	assert.Equal(t, map[string]float64{"equity": 50000, "commodity": 30000}, required)
+	assert.Equal(t, []MarginCheck{
+		{Segment: "equity", Required: 50000, Available: 100000, Sufficient: true},
+		{Segment: "commodity", Required: 30000, Available: 20000, Shortfall: 10000},
+	}, marginAvailability(client, required))
+
+	assert.Nil(t, marginAvailability(client, requiredBySegment(nil)))
+}
@@ -2,12 +2,15 @@ package mcp
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
 	"github.com/zerodha/kite-mcp-server/kc/instruments"
 )
 
@@ -53,16 +56,70 @@ func (*QuotesTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 type InstrumentsSearchTool struct{}
 
 func (*InstrumentsSearchTool) Tool() mcp.Tool {
-	return mcp.NewTool("search_instruments", // TODO this can be multiplexed into various modes. Currently only the filter mode is implemented but other instruments queries in the instruments manager can be exposed here as well.
-		mcp.WithDescription("Search instruments. Supports pagination for large result sets."),
+	return mcp.NewTool("search_instruments",
+		mcp.WithDescription("Search instruments. Look up one by instrument_token, or by exchange and exchange_token. Otherwise query finds instruments by free text, ranked best match first: equities before derivatives and NSE before BSE. The structured filters narrow down the results, and without a query list the matching contracts by expiry and strike, like an option chain. expiry=nearest or next and atm resolve contracts like next week's NIFTY ATM CE in one call. Supports pagination for large result sets."),
 		mcp.WithString("query",
-			mcp.Description("Search query"),
-			mcp.Required(),
+			mcp.Description("Search query. Without filter_on, every word must match the tradingsymbol, name, exchange, instrument type, strike or ISIN, as a whole word, a prefix or with a typo. Eg. 'reliance', 'NSE:INFY', 'nifty 24000 ce'"),
 		),
 		mcp.WithString("filter_on",
-			mcp.Description("Filter on a specific field. (Optional). [id(default)=exch:tradingsymbol, name=nice name of the instrument, tradingsymbol=used to trade in a specific exchange, isin=universal identifier for an instrument across exchanges], underlying=[query=underlying instrument, result=futures and options. note=query format -> exch:tradingsymbol where NSE/BSE:PNB converted to -> NFO/BFO:PNB for query since futures and options available under them]"),
+			mcp.Description("Filter on a specific field instead of ranking matches. (Optional). [id=exch:tradingsymbol, name=nice name of the instrument, tradingsymbol=used to trade in a specific exchange, isin=universal identifier for an instrument across exchanges], underlying=[query=underlying instrument, result=futures and options. note=query format -> exch:tradingsymbol where NSE/BSE:PNB converted to -> NFO/BFO:PNB for query since futures and options available under them]"),
 			mcp.Enum("id", "name", "isin", "tradingsymbol", "underlying"),
 		),
+		mcp.WithNumber("instrument_token",
+			mcp.Description("Look up the instrument with this instrument token (Optional)"),
+		),
+		mcp.WithNumber("exchange_token",
+			mcp.Description("Look up the instrument with this exchange token, on the given exchange (Optional)"),
+		),
+		mcp.WithString("exchange",
+			mcp.Description("Only return instruments of this exchange. Eg. NSE, BSE, NFO, MCX (Optional)"),
+		),
+		mcp.WithString("segment",
+			mcp.Description("Only return instruments of this segment. Eg. NSE, NFO-OPT, INDICES (Optional)"),
+		),
+		mcp.WithString("instrument_type",
+			mcp.Description("Only return instruments of this type. Eg. EQ, FUT, CE, PE (Optional)"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Only return derivatives of this underlying. Eg. NIFTY, BANKNIFTY, RELIANCE (Optional)"),
+		),
+		mcp.WithString("option_type",
+			mcp.Description("Only return call or put options (Optional)"),
+			mcp.Enum("CE", "PE"),
+		),
+		mcp.WithString("expiry",
+			mcp.Description("Only return contracts of this expiry: a date in YYYY-MM-DD format, or nearest or next for the nearest or the following upcoming expiry of the matching contracts, which needs name (Optional)"),
+		),
+		mcp.WithString("expiry_from",
+			mcp.Description("Only return contracts expiring on or after this date, in YYYY-MM-DD format (Optional)"),
+		),
+		mcp.WithString("expiry_to",
+			mcp.Description("Only return contracts expiring on or before this date, in YYYY-MM-DD format (Optional)"),
+		),
+		mcp.WithNumber("strike",
+			mcp.Description("Only return options of this strike (Optional)"),
+		),
+		mcp.WithNumber("strike_min",
+			mcp.Description("Only return options with a strike of at least this (Optional)"),
+		),
+		mcp.WithNumber("strike_max",
+			mcp.Description("Only return options with a strike of at most this (Optional)"),
+		),
+		mcp.WithBoolean("atm",
+			mcp.Description("Only return the at-the-money strike of the options of name, for the nearest expiry unless another is given (Optional)"),
+		),
+		mcp.WithNumber("underlying_price",
+			mcp.Description("Price of the underlying to pick the at-the-money strike for. Default: the last price of its nearest future, which needs a login (Optional)"),
+		),
+		mcp.WithNumber("strikes_around_atm",
+			mcp.Description("With atm, also return this many strikes above and below the at-the-money strike (Optional)"),
+		),
+		mcp.WithBoolean("active_only",
+			mcp.Description("Only return active instruments (Optional)"),
+		),
+		mcp.WithNumber("lot_size",
+			mcp.Description("Only return instruments with this lot size (Optional)"),
+		),
 		mcp.WithNumber("from",
 			mcp.Description("Starting index for pagination (0-based). Default: 0"),
 		),
@@ -72,91 +129,307 @@ func (*InstrumentsSearchTool) Tool() mcp.Tool {
 	)
 }
 
+// parseInstrumentFilters reads the structured filters of search_instruments.
+// The expiry argument is returned for resolving against the instruments.
+func parseInstrumentFilters(args map[string]any) (instruments.SearchOptions, string, error) {
+	opts := instruments.SearchOptions{
+		Query:          SafeAssertString(args["query"], ""),
+		Exchange:       SafeAssertString(args["exchange"], ""),
+		Segment:        SafeAssertString(args["segment"], ""),
+		InstrumentType: SafeAssertString(args["instrument_type"], ""),
+		Name:           SafeAssertString(args["name"], ""),
+		StrikeMin:      SafeAssertFloat64(args["strike_min"], 0),
+		StrikeMax:      SafeAssertFloat64(args["strike_max"], 0),
+		ActiveOnly:     SafeAssertBool(args["active_only"], false),
+		LotSize:        SafeAssertInt(args["lot_size"], 0),
+	}
+
+	if optionType := SafeAssertString(args["option_type"], ""); optionType != "" {
+		if opts.InstrumentType != "" && !strings.EqualFold(opts.InstrumentType, optionType) {
+			return opts, "", fmt.Errorf("option_type %s contradicts instrument_type %s", optionType, opts.InstrumentType)
+		}
+		opts.InstrumentType = optionType
+	}
+
+	if strike := SafeAssertFloat64(args["strike"], 0); strike > 0 {
+		if opts.StrikeMin > 0 || opts.StrikeMax > 0 {
+			return opts, "", fmt.Errorf("strike cannot be combined with strike_min/strike_max")
+		}
+		opts.StrikeMin, opts.StrikeMax = strike, strike
+	}
+	if opts.StrikeMin > 0 && opts.StrikeMax > 0 && opts.StrikeMin > opts.StrikeMax {
+		return opts, "", fmt.Errorf("strike_min must not be above strike_max")
+	}
+
+	for _, bound := range []struct {
+		arg string
+		to  *time.Time
+	}{{"expiry_from", &opts.ExpiryFrom}, {"expiry_to", &opts.ExpiryTo}} {
+		value := SafeAssertString(args[bound.arg], "")
+		if value == "" {
+			continue
+		}
+		date, err := time.ParseInLocation(time.DateOnly, value, calendar.IST)
+		if err != nil {
+			return opts, "", fmt.Errorf("%s must be in YYYY-MM-DD format", bound.arg)
+		}
+		*bound.to = date
+	}
+	if !opts.ExpiryFrom.IsZero() && !opts.ExpiryTo.IsZero() && opts.ExpiryFrom.After(opts.ExpiryTo) {
+		return opts, "", fmt.Errorf("expiry_from must not be after expiry_to")
+	}
+
+	expiry := strings.ToLower(SafeAssertString(args["expiry"], ""))
+	switch expiry {
+	case "", "nearest", "next":
+	default:
+		date, err := time.ParseInLocation(time.DateOnly, expiry, calendar.IST)
+		if err != nil {
+			return opts, "", fmt.Errorf("expiry must be nearest, next or a date in YYYY-MM-DD format")
+		}
+		opts.ExpiryFrom, opts.ExpiryTo = date, date
+		expiry = ""
+	}
+	if expiry != "" && (!opts.ExpiryFrom.IsZero() || !opts.ExpiryTo.IsZero()) {
+		return opts, "", fmt.Errorf("expiry cannot be combined with expiry_from/expiry_to")
+	}
+	return opts, expiry, nil
+}
+
+// nearFuturePrice returns the last price of the nearest future of the
+// underlying of opts, a stand-in for its spot price.
+func nearFuturePrice(manager *kc.Manager, session *kc.KiteSessionData, opts instruments.SearchOptions) (float64, error) {
+	futures := manager.Instruments.Search(instruments.SearchOptions{
+		Name:           opts.Name,
+		Exchange:       opts.Exchange,
+		InstrumentType: "FUT",
+		ExpiryFrom:     time.Now(),
+		Limit:          1,
+	})
+	if len(futures) == 0 {
+		return 0, fmt.Errorf("no future of %s to price it, pass underlying_price", opts.Name)
+	}
+	id := futures[0].Exchange + ":" + futures[0].Tradingsymbol
+	ltp, err := session.Kite.Client.GetLTP(id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the price of %s: %w", id, err)
+	}
+	quote, ok := ltp[id]
+	if !ok || quote.LastPrice <= 0 {
+		return 0, fmt.Errorf("no price for %s, pass underlying_price", id)
+	}
+	return quote.LastPrice, nil
+}
+
+// selectATM narrows the strikes of opts to the at-the-money strike for
+// price and n strikes on either side of it.
+func selectATM(manager *kc.Manager, opts instruments.SearchOptions, price float64, n int) (instruments.SearchOptions, error) {
+	atm, err := manager.Instruments.ATMStrike(opts, price)
+	if err != nil {
+		return opts, fmt.Errorf("no options of %s to pick the at-the-money strike from", opts.Name)
+	}
+	opts.StrikeMin, opts.StrikeMax = atm, atm
+	if n > 0 {
+		all := opts
+		all.StrikeMin, all.StrikeMax = 0, 0
+		strikes := manager.Instruments.Strikes(all)
+		i := sort.SearchFloat64s(strikes, atm)
+		opts.StrikeMin = strikes[max(0, i-n)]
+		opts.StrikeMax = strikes[min(len(strikes)-1, i+n)]
+	}
+	return opts, nil
+}
+
 func (*InstrumentsSearchTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 	handler := NewToolHandler(manager)
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		handler.trackToolCall(ctx, "search_instruments")
 		args := request.GetArguments()
 
-		// Validate required parameters
-		if err := ValidateRequired(args, "query"); err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
-		}
-
-		query := SafeAssertString(args["query"], "")
-		filterOn := SafeAssertString(args["filter_on"], "id")
-
 		// Don't call UpdateInstruments() here since it might already be happening in another thread
 		// But we do need to ensure instruments are loaded
 		if manager.Instruments.Count() == 0 {
 			manager.Logger.Warn("No instruments loaded, search may return incomplete results")
 		}
 
-		var out []instruments.Instrument
-
-		switch filterOn {
-		case "underlying":
-			// query needs to be split by `:` into exch and underlying.
-			if strings.Contains(query, ":") {
-				parts := strings.Split(query, ":")
-				if len(parts) != 2 {
-					return mcp.NewToolResultError("Invalid query format, specify exch:underlying, where exchange is BFO/NFO"), nil
-				}
-
-				exch := parts[0]
-				underlying := parts[1]
-
-				instruments, _ := manager.Instruments.GetAllByUnderlying(exch, underlying)
-				out = instruments
-			} else {
-				// Assume query is just the underlying symbol and try. Just to save prompt calls.
-				exch := "NFO"
-				underlying := query
-
-				instruments, _ := manager.Instruments.GetAllByUnderlying(exch, underlying)
-				out = instruments
+		// Token lookups
+		if token := SafeAssertInt(args["instrument_token"], 0); token > 0 {
+			inst, err := manager.Instruments.GetByInstToken(uint32(token))
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("No instrument with instrument_token %d", token)), nil
+			}
+			return handler.MarshalResponse([]instruments.Instrument{inst}, "search_instruments")
+		}
+		if token := SafeAssertInt(args["exchange_token"], 0); token > 0 {
+			exchange := SafeAssertString(args["exchange"], "")
+			if exchange == "" {
+				return mcp.NewToolResultError("exchange is required with exchange_token"), nil
+			}
+			inst, err := manager.Instruments.GetByExchToken(exchange, uint32(token))
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("No instrument with exchange_token %d on %s", token, exchange)), nil
 			}
-		default:
-			instruments := manager.Instruments.Filter(func(instrument instruments.Instrument) bool {
-				switch filterOn {
-				case "name":
-					return strings.Contains(strings.ToLower(instrument.Name), strings.ToLower(query))
-				case "tradingsymbol":
-					return strings.Contains(strings.ToLower(instrument.Tradingsymbol), strings.ToLower(query))
-				case "isin":
-					return strings.Contains(strings.ToLower(instrument.ISIN), strings.ToLower(query))
-				case "id":
-					return strings.Contains(strings.ToLower(instrument.ID), strings.ToLower(query))
-				default:
-					return strings.Contains(strings.ToLower(instrument.ID), strings.ToLower(query))
-				}
-			})
+			return handler.MarshalResponse([]instruments.Instrument{inst}, "search_instruments")
+		}
 
-			out = instruments
+		opts, expiry, err := parseInstrumentFilters(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		query := opts.Query
+		filterOn := SafeAssertString(args["filter_on"], "")
+		if filterOn != "" && query == "" {
+			return mcp.NewToolResultError("query is required with filter_on"), nil
+		}
+		if query == "" && opts.Name == "" && opts.Exchange == "" && opts.Segment == "" {
+			return mcp.NewToolResultError("Specify a query, name, exchange, segment, instrument_token or exchange_token"), nil
 		}
 
-		// Parse pagination parameters
-		params := ParsePaginationParams(args)
+		atm := SafeAssertBool(args["atm"], false)
+		if atm && expiry == "" && opts.ExpiryFrom.IsZero() && opts.ExpiryTo.IsZero() {
+			expiry = "nearest"
+		}
+		if expiry != "" {
+			if opts.Name == "" {
+				return mcp.NewToolResultError("name is required to resolve the nearest expiry"), nil
+			}
+			n := 0
+			if expiry == "next" {
+				n = 1
+			}
+			date, err := manager.Instruments.NearestExpiry(opts, time.Now(), n)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("No %s expiry of %s", expiry, opts.Name)), nil
+			}
+			opts.ExpiryFrom, opts.ExpiryTo = date, date
+		}
 
-		// Apply pagination if limit is specified
-		originalLength := len(out)
-		paginatedData := ApplyPagination(out, params)
+		respond := func(opts instruments.SearchOptions) (*mcp.CallToolResult, error) {
+			out, err := searchInstruments(manager, opts, filterOn)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return instrumentsResponse(handler, out, args)
+		}
+		if !atm {
+			return respond(opts)
+		}
 
-		// Create response with pagination metadata if pagination was applied
-		var responseData interface{}
-		if params.Limit > 0 {
-			// Convert to []interface{} for pagination response
-			interfaceData := make([]interface{}, len(paginatedData))
-			for i, instrument := range paginatedData {
-				interfaceData[i] = instrument
+		if opts.Name == "" {
+			return mcp.NewToolResultError("name is required with atm"), nil
+		}
+		if opts.StrikeMin > 0 || opts.StrikeMax > 0 {
+			return mcp.NewToolResultError("atm cannot be combined with strike, strike_min or strike_max"), nil
+		}
+		around := SafeAssertInt(args["strikes_around_atm"], 0)
+		if price := SafeAssertFloat64(args["underlying_price"], 0); price > 0 {
+			opts, err := selectATM(manager, opts, price, around)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
 			}
-			responseData = CreatePaginatedResponse(out, interfaceData, params, originalLength)
+			return respond(opts)
+		}
+		return handler.WithSession(ctx, "search_instruments", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			price, err := nearFuturePrice(manager, session, opts)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			opts, err := selectATM(manager, opts, price, around)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return respond(opts)
+		})
+	}
+}
+
+// searchInstruments returns the instruments found by search_instruments:
+// ranked by the index without filter_on, otherwise filtered on the field.
+func searchInstruments(manager *kc.Manager, opts instruments.SearchOptions, filterOn string) ([]instruments.Instrument, error) {
+	query := opts.Query
+
+	var out []instruments.Instrument
+
+	switch filterOn {
+	case "":
+		results := manager.Instruments.Search(opts)
+		out = make([]instruments.Instrument, len(results))
+		for i, r := range results {
+			out[i] = r.Instrument
+		}
+	case "underlying":
+		// query needs to be split by `:` into exch and underlying.
+		if strings.Contains(query, ":") {
+			parts := strings.Split(query, ":")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid query format, specify exch:underlying, where exchange is BFO/NFO")
+			}
+
+			exch := parts[0]
+			underlying := parts[1]
+
+			instruments, _ := manager.Instruments.GetAllByUnderlying(exch, underlying)
+			out = instruments
 		} else {
-			responseData = paginatedData
+			// Assume query is just the underlying symbol and try. Just to save prompt calls.
+			exch := "NFO"
+			underlying := query
+
+			instruments, _ := manager.Instruments.GetAllByUnderlying(exch, underlying)
+			out = instruments
 		}
+		filtered := out[:0]
+		for _, inst := range out {
+			if opts.Matches(inst) {
+				filtered = append(filtered, inst)
+			}
+		}
+		out = filtered
+	default:
+		out = manager.Instruments.Filter(func(instrument instruments.Instrument) bool {
+			if !opts.Matches(instrument) {
+				return false
+			}
+			switch filterOn {
+			case "name":
+				return strings.Contains(strings.ToLower(instrument.Name), strings.ToLower(query))
+			case "tradingsymbol":
+				return strings.Contains(strings.ToLower(instrument.Tradingsymbol), strings.ToLower(query))
+			case "isin":
+				return strings.Contains(strings.ToLower(instrument.ISIN), strings.ToLower(query))
+			case "id":
+				return strings.Contains(strings.ToLower(instrument.ID), strings.ToLower(query))
+			default:
+				return strings.Contains(strings.ToLower(instrument.ID), strings.ToLower(query))
+			}
+		})
+	}
+	return out, nil
+}
 
-		return handler.MarshalResponse(responseData, "search_instruments")
+// instrumentsResponse paginates the instruments found by search_instruments.
+func instrumentsResponse(handler *ToolHandler, out []instruments.Instrument, args map[string]any) (*mcp.CallToolResult, error) {
+	// Parse pagination parameters
+	params := ParsePaginationParams(args)
+
+	// Apply pagination if limit is specified
+	originalLength := len(out)
+	paginatedData := ApplyPagination(out, params)
+
+	// Create response with pagination metadata if pagination was applied
+	var responseData interface{}
+	if params.Limit > 0 {
+		// Convert to []interface{} for pagination response
+		interfaceData := make([]interface{}, len(paginatedData))
+		for i, instrument := range paginatedData {
+			interfaceData[i] = instrument
+		}
+		responseData = CreatePaginatedResponse(out, interfaceData, params, originalLength)
+	} else {
+		responseData = paginatedData
 	}
+
+	return handler.MarshalResponse(responseData, "search_instruments")
 }
 
 type HistoricalDataTool struct{}
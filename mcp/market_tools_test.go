@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
+)
+
+// newChainServer serves INFY and NIFTY options for this week, next week
+// and the month, with the month's future trading at 22480.
+func newChainServer(t *testing.T) (*e2eServer, []string) {
+	t.Helper()
+	today := time.Now().In(time.FixedZone("IST", 19800))
+	expiries := []string{
+		today.AddDate(0, 0, 2).Format(time.DateOnly),
+		today.AddDate(0, 0, 9).Format(time.DateOnly),
+		today.AddDate(0, 0, 30).Format(time.DateOnly),
+	}
+
+	testData := map[uint32]*instruments.Instrument{
+		408065: {ID: "NSE:INFY", InstrumentToken: 408065, ExchangeToken: 1594, Tradingsymbol: "INFY", Name: "INFOSYS", Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ", LotSize: 1, Active: true},
+	}
+	exchToken := uint32(35000)
+	add := func(inst *instruments.Instrument) {
+		exchToken++
+		inst.ExchangeToken = exchToken
+		inst.InstrumentToken = exchToken<<8 | 2
+		inst.ID = "NFO:" + inst.Tradingsymbol
+		inst.Name = "NIFTY"
+		inst.Exchange = "NFO"
+		inst.LotSize = 75
+		inst.Active = true
+		testData[inst.InstrumentToken] = inst
+	}
+	for i, expiry := range expiries {
+		code := fmt.Sprintf("W%d", i)
+		for strike := 22000; strike <= 23000; strike += 100 {
+			for _, kind := range []string{"CE", "PE"} {
+				add(&instruments.Instrument{
+					Tradingsymbol:  fmt.Sprintf("NIFTY%s%d%s", code, strike, kind),
+					Strike:         float64(strike),
+					InstrumentType: kind,
+					Segment:        "NFO-OPT",
+					ExpiryDate:     expiry,
+				})
+			}
+		}
+	}
+	add(&instruments.Instrument{Tradingsymbol: "NIFTYMFUT", InstrumentType: "FUT", Segment: "NFO-FUT", ExpiryDate: expiries[2]})
+
+	broker := kitefake.New()
+	broker.AddInstrument(kitefake.Instrument{Exchange: "NFO", Tradingsymbol: "NIFTYMFUT", InstrumentToken: int(exchToken<<8 | 2), LastPrice: 22480})
+
+	updateConfig := instruments.DefaultUpdateConfig()
+	updateConfig.EnableScheduler = false
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	im, err := instruments.New(instruments.Config{UpdateConfig: updateConfig, Logger: logger, TestData: testData})
+	require.NoError(t, err)
+
+	return startE2EServer(t, kc.Config{
+		Logger:             logger,
+		InstrumentsManager: im,
+		KiteClientFactory: func(apiKey string) kc.KiteClient {
+			return broker.Client(apiKey)
+		},
+	}), expiries
+}
+
+func searchSymbols(t *testing.T, e *e2eServer, args map[string]any) []string {
+	t.Helper()
+	var found []instruments.Instrument
+	require.NoError(t, json.Unmarshal([]byte(e.call(t, "search_instruments", args)), &found))
+	symbols := make([]string, len(found))
+	for i, inst := range found {
+		symbols[i] = inst.Tradingsymbol
+	}
+	return symbols
+}
+
+func TestSearchInstrumentsModes(t *testing.T) {
+	e, expiries := newChainServer(t)
+
+	t.Run("token lookups", func(t *testing.T) {
+		assert.Equal(t, []string{"INFY"}, searchSymbols(t, e, map[string]any{"instrument_token": 408065}))
+		assert.Equal(t, []string{"INFY"}, searchSymbols(t, e, map[string]any{"exchange_token": 1594, "exchange": "NSE"}))
+		assert.Contains(t, resultText(e.callResult(t, "search_instruments", map[string]any{"instrument_token": 1})), "No instrument")
+		assert.Contains(t, resultText(e.callResult(t, "search_instruments", map[string]any{"exchange_token": 1594})), "exchange is required")
+	})
+
+	t.Run("structured filters", func(t *testing.T) {
+		assert.Equal(t, []string{"NIFTYW122500CE"}, searchSymbols(t, e, map[string]any{
+			"name": "nifty", "option_type": "CE", "expiry": "next", "strike": 22500,
+		}))
+		assert.Equal(t, []string{"NIFTYW022900PE", "NIFTYW023000PE"}, searchSymbols(t, e, map[string]any{
+			"name": "NIFTY", "instrument_type": "PE", "expiry": "nearest", "strike_min": 22900,
+		}))
+		assert.Equal(t, []string{"NIFTYW122000CE", "NIFTYW122100CE", "NIFTYW222000CE", "NIFTYW222100CE"}, searchSymbols(t, e, map[string]any{
+			"name": "NIFTY", "option_type": "CE", "expiry_from": expiries[1], "expiry_to": expiries[2], "strike_max": 22100, "lot_size": 75, "active_only": true,
+		}))
+		assert.Equal(t, []string{"NIFTYMFUT"}, searchSymbols(t, e, map[string]any{"name": "NIFTY", "instrument_type": "FUT"}))
+		assert.Equal(t, []string{"NIFTYW222500CE"}, searchSymbols(t, e, map[string]any{"query": "nifty 22500 ce", "expiry": expiries[2]}))
+	})
+
+	t.Run("atm", func(t *testing.T) {
+		assert.Equal(t, []string{"NIFTYW022500CE"}, searchSymbols(t, e, map[string]any{
+			"name": "NIFTY", "option_type": "CE", "atm": true, "underlying_price": 22460,
+		}))
+		// Priced off the month's future at 22480
+		assert.Equal(t, []string{"NIFTYW122400PE", "NIFTYW122500PE", "NIFTYW122600PE"}, searchSymbols(t, e, map[string]any{
+			"name": "NIFTY", "option_type": "PE", "expiry": "next", "atm": true, "strikes_around_atm": 1,
+		}))
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		for _, tt := range []struct {
+			args     map[string]any
+			expected string
+		}{
+			{map[string]any{}, "Specify a query"},
+			{map[string]any{"name": "NIFTY", "expiry": "26-12-2024"}, "expiry must be"},
+			{map[string]any{"name": "NIFTY", "expiry": "next", "expiry_from": expiries[0]}, "cannot be combined"},
+			{map[string]any{"name": "NIFTY", "option_type": "CE", "instrument_type": "PE"}, "contradicts"},
+			{map[string]any{"exchange": "NFO", "expiry": "nearest"}, "name is required"},
+			{map[string]any{"name": "BANKNIFTY", "expiry": "nearest"}, "No nearest expiry of BANKNIFTY"},
+			{map[string]any{"name": "NIFTY", "atm": true, "strike": 22500}, "atm cannot be combined"},
+			{map[string]any{"filter_on": "name"}, "query is required"},
+		} {
+			result := e.callResult(t, "search_instruments", tt.args)
+			assert.True(t, result.IsError, tt.args)
+			assert.Contains(t, resultText(result), tt.expected, tt.args)
+		}
+	})
+}
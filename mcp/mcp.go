@@ -32,22 +32,53 @@ func GetAllTools() []Tool {
 		&OrderHistoryTool{},
 		&OrderTradesTool{},
 		&GTTOrdersTool{},
+		&GetGTTTool{},
 		&MFHoldingsTool{},
+		&MFOrdersTool{},
+		&MFSIPsTool{},
+		&MFAllottedISINsTool{},
 
 		// Tools for market data
 		&QuotesTool{},
 		&InstrumentsSearchTool{},
+		&InstrumentChangesTool{},
+		&SearchMFInstrumentsTool{},
 		&HistoricalDataTool{},
 		&LTPTool{},
 		&OHLCTool{},
+		&GetMarketStatusTool{},
+
+		// Tools for margin and charges calculation
+		&OrderMarginsTool{},
+		&BasketMarginsTool{},
+		&OrderChargesTool{},
 
 		// Tools that post data to Kite Connect
 		&PlaceOrderTool{},
 		&ModifyOrderTool{},
 		&CancelOrderTool{},
+		&ConvertPositionTool{},
+		&SquareOffPositionTool{},
+		&RolloverPositionsTool{},
+		&WaitForOrderTool{},
+		&PlaceOCOOrderTool{},
+		&GetOCOOrdersTool{},
+		&CancelOCOOrderTool{},
+		&ScheduleOrderTool{},
+		&ListScheduledOrdersTool{},
+		&CancelScheduledOrderTool{},
+		&PlaceAlgoOrderTool{},
+		&GetAlgoOrdersTool{},
+		&CancelAlgoOrderTool{},
 		&PlaceGTTOrderTool{},
 		&ModifyGTTOrderTool{},
 		&DeleteGTTOrderTool{},
+		&DeleteGTTsTool{},
+		&PlaceMFOrderTool{},
+		&CancelMFOrderTool{},
+		&PlaceMFSIPTool{},
+		&ModifyMFSIPTool{},
+		&CancelMFSIPTool{},
 
 		// AI-powered trading strategy tools
 		&AnalyzeTradeOpportunityTool{},
@@ -59,6 +90,11 @@ func GetAllTools() []Tool {
 		&MonitorPositionsTool{},
 		&SetEmergencyExitTool{},
 		&GetDailyGameplanTool{},
+
+		// Tools for tax reporting from imported tradebooks
+		&ImportTradebookTool{},
+		&RealizedPnLTool{},
+		&TaxLossHarvestTool{},
 	}
 }
 
@@ -120,3 +156,47 @@ func RegisterTools(srv *server.MCPServer, manager *kc.Manager, excludedTools str
 		"excluded", excludedCount,
 		"total_available", len(allTools))
 }
+
+// UpdateExcludedTools applies a new list of excluded tools to a running
+// server, removing the tools newly excluded and registering the ones no
+// longer excluded. The other tools and the sessions are untouched, and
+// clients are notified the tool list changed.
+func UpdateExcludedTools(srv *server.MCPServer, manager *kc.Manager, previous, excludedTools string, logger *slog.Logger) {
+	wasExcluded := parseExcludedTools(previous)
+	excludedSet := parseExcludedTools(excludedTools)
+
+	var removed []string
+	var added []server.ServerTool
+	for _, tool := range GetAllTools() {
+		toolName := tool.Tool().Name
+		switch {
+		case excludedSet[toolName] && !wasExcluded[toolName]:
+			removed = append(removed, toolName)
+		case !excludedSet[toolName] && wasExcluded[toolName]:
+			added = append(added, server.ServerTool{Tool: tool.Tool(), Handler: tool.Handler(manager)})
+		}
+	}
+
+	if len(removed) > 0 {
+		srv.DeleteTools(removed...)
+	}
+	if len(added) > 0 {
+		srv.AddTools(added...)
+	}
+	for _, toolName := range removed {
+		logger.Info("Excluded tool removed", "tool", toolName)
+	}
+	for _, tool := range added {
+		logger.Info("Tool no longer excluded registered", "tool", tool.Tool.Name)
+	}
+}
+
+// ToolNames returns the names of all the tools, to validate tool names in
+// the configuration against.
+func ToolNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, tool := range GetAllTools() {
+		names[tool.Tool().Name] = true
+	}
+	return names
+}
@@ -1,9 +1,14 @@
 package mcp
 
 import (
+	"context"
+	"errors"
+
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
 )
 
 type MFHoldingsTool struct{}
@@ -35,3 +40,546 @@ func (*MFHoldingsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 		return result, nil
 	})
 }
+
+type MFOrdersTool struct{}
+
+func (*MFOrdersTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_mf_orders",
+		mcp.WithDescription("Get all mutual fund orders. Supports pagination for large datasets."),
+		mcp.WithNumber("from",
+			mcp.Description("Starting index for pagination (0-based). Default: 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of MF orders to return. If not specified, returns all orders. When specified, response includes pagination metadata."),
+		),
+	)
+}
+
+func (*MFOrdersTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	return PaginatedToolHandler(manager, "get_mf_orders", func(session *kc.KiteSessionData) ([]interface{}, error) {
+		orders, err := session.Kite.Client.GetMFOrders()
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert to []interface{} for generic pagination
+		result := make([]interface{}, len(orders))
+		for i, order := range orders {
+			result[i] = order
+		}
+		return result, nil
+	})
+}
+
+type PlaceMFOrderTool struct{}
+
+func (*PlaceMFOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("place_mf_order",
+		mcp.WithDescription("Place a mutual fund order. Purchases are placed by amount; redemptions by quantity (units) or amount."),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Mutual fund tradingsymbol (ISIN of the scheme)"),
+			mcp.Required(),
+		),
+		mcp.WithString("transaction_type",
+			mcp.Description("Transaction type"),
+			mcp.Required(),
+			mcp.Enum("BUY", "SELL"),
+		),
+		mcp.WithNumber("amount",
+			mcp.Description("Amount to purchase or redeem (required for BUY)"),
+		),
+		mcp.WithNumber("quantity",
+			mcp.Description("Units to redeem (SELL only)"),
+		),
+		mcp.WithString("tag",
+			mcp.Description("An optional tag to apply to an order to identify it (alphanumeric, max 20 chars)"),
+			mcp.MaxLength(20),
+		),
+	)
+}
+
+func (*PlaceMFOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "place_mf_order")
+		args := request.GetArguments()
+
+		// Validate required parameters
+		if err := ValidateRequired(args, "tradingsymbol", "transaction_type"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		orderParams := kiteconnect.MFOrderParams{
+			Tradingsymbol:   SafeAssertString(args["tradingsymbol"], ""),
+			TransactionType: SafeAssertString(args["transaction_type"], ""),
+			Amount:          SafeAssertFloat64(args["amount"], 0.0),
+			Quantity:        SafeAssertFloat64(args["quantity"], 0.0),
+			Tag:             SafeAssertString(args["tag"], ""),
+		}
+
+		switch orderParams.TransactionType {
+		case kiteconnect.TransactionTypeBuy:
+			if orderParams.Amount <= 0 {
+				return mcp.NewToolResultError("amount must be greater than 0 for BUY orders"), nil
+			}
+			if orderParams.Quantity != 0 {
+				return mcp.NewToolResultError("quantity is only supported for SELL orders"), nil
+			}
+		case kiteconnect.TransactionTypeSell:
+			if orderParams.Amount <= 0 && orderParams.Quantity <= 0 {
+				return mcp.NewToolResultError("either amount or quantity must be greater than 0 for SELL orders"), nil
+			}
+		default:
+			return mcp.NewToolResultError("Invalid transaction_type. Must be 'BUY' or 'SELL'"), nil
+		}
+
+		return handler.WithSession(ctx, "place_mf_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			resp, err := session.Kite.Client.PlaceMFOrder(orderParams)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to place MF order", "error", err)
+				return mcp.NewToolResultError("Failed to place MF order"), nil
+			}
+
+			return handler.MarshalResponse(resp, "place_mf_order")
+		})
+	}
+}
+
+type CancelMFOrderTool struct{}
+
+func (*CancelMFOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("cancel_mf_order",
+		mcp.WithDescription("Cancel a pending mutual fund order"),
+		mcp.WithString("order_id",
+			mcp.Description("MF order ID"),
+			mcp.Required(),
+		),
+	)
+}
+
+func (*CancelMFOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "cancel_mf_order")
+		args := request.GetArguments()
+
+		// Validate required parameters
+		if err := ValidateRequired(args, "order_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		orderID := SafeAssertString(args["order_id"], "")
+
+		return handler.WithSession(ctx, "cancel_mf_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			resp, err := session.Kite.Client.CancelMFOrder(orderID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to cancel MF order", "error", err)
+				return mcp.NewToolResultError("Failed to cancel MF order"), nil
+			}
+
+			return handler.MarshalResponse(resp, "cancel_mf_order")
+		})
+	}
+}
+
+type MFSIPsTool struct{}
+
+func (*MFSIPsTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_mf_sips",
+		mcp.WithDescription("Get all mutual fund SIPs. Supports pagination for large datasets."),
+		mcp.WithNumber("from",
+			mcp.Description("Starting index for pagination (0-based). Default: 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of SIPs to return. If not specified, returns all SIPs. When specified, response includes pagination metadata."),
+		),
+	)
+}
+
+func (*MFSIPsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	return PaginatedToolHandler(manager, "get_mf_sips", func(session *kc.KiteSessionData) ([]interface{}, error) {
+		sips, err := session.Kite.Client.GetMFSIPs()
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert to []interface{} for generic pagination
+		result := make([]interface{}, len(sips))
+		for i, sip := range sips {
+			result[i] = sip
+		}
+		return result, nil
+	})
+}
+
+// validateSIPSchedule checks the instalment settings shared by place_mf_sip
+// and modify_mf_sip. Zero values are treated as not provided.
+func validateSIPSchedule(frequency string, instalments, instalmentDay int) error {
+	if instalments != 0 && instalments != -1 && instalments < 1 {
+		return ValidationError{Parameter: "instalments", Message: "must be -1 (perpetual) or at least 1"}
+	}
+	if instalmentDay != 0 {
+		if frequency == "weekly" {
+			return ValidationError{Parameter: "instalment_day", Message: "is not supported for weekly SIPs"}
+		}
+		if instalmentDay < 1 || instalmentDay > 28 {
+			return ValidationError{Parameter: "instalment_day", Message: "must be between 1 and 28"}
+		}
+	}
+	return nil
+}
+
+type PlaceMFSIPTool struct{}
+
+func (*PlaceMFSIPTool) Tool() mcp.Tool {
+	return mcp.NewTool("place_mf_sip",
+		mcp.WithDescription("Start a mutual fund SIP (systematic investment plan)"),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Mutual fund tradingsymbol (ISIN of the scheme)"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("amount",
+			mcp.Description("Amount per instalment"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("instalments",
+			mcp.Description("Number of instalments. Use -1 for a perpetual SIP"),
+			mcp.Required(),
+		),
+		mcp.WithString("frequency",
+			mcp.Description("Instalment frequency"),
+			mcp.Required(),
+			mcp.DefaultString("monthly"),
+			mcp.Enum("weekly", "monthly", "quarterly"),
+		),
+		mcp.WithNumber("instalment_day",
+			mcp.Description("Day of the month (1-28) for monthly and quarterly SIPs"),
+		),
+		mcp.WithNumber("initial_amount",
+			mcp.Description("Optional amount to invest immediately when the SIP is created"),
+		),
+		mcp.WithString("step_up",
+			mcp.Description("Optional annual step-up as 'DD-MM:percentage', e.g. '15-02:10'"),
+		),
+		mcp.WithString("tag",
+			mcp.Description("An optional tag to apply to the SIP to identify it (alphanumeric, max 20 chars)"),
+			mcp.MaxLength(20),
+		),
+	)
+}
+
+func (*PlaceMFSIPTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "place_mf_sip")
+		args := request.GetArguments()
+
+		// Validate required parameters
+		if err := ValidateRequired(args, "tradingsymbol", "amount", "instalments", "frequency"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		sipParams := kiteconnect.MFSIPParams{
+			Tradingsymbol: SafeAssertString(args["tradingsymbol"], ""),
+			Amount:        SafeAssertFloat64(args["amount"], 0.0),
+			Instalments:   SafeAssertInt(args["instalments"], 0),
+			Frequency:     SafeAssertString(args["frequency"], "monthly"),
+			InstalmentDay: SafeAssertInt(args["instalment_day"], 0),
+			InitialAmount: SafeAssertFloat64(args["initial_amount"], 0.0),
+			StepUp:        SafeAssertString(args["step_up"], ""),
+			Tag:           SafeAssertString(args["tag"], ""),
+		}
+
+		if sipParams.Amount <= 0 {
+			return mcp.NewToolResultError("amount must be greater than 0"), nil
+		}
+		if sipParams.Instalments == 0 {
+			return mcp.NewToolResultError("instalments must be -1 (perpetual) or at least 1"), nil
+		}
+		if sipParams.InitialAmount < 0 {
+			return mcp.NewToolResultError("initial_amount must not be negative"), nil
+		}
+		if err := validateSIPSchedule(sipParams.Frequency, sipParams.Instalments, sipParams.InstalmentDay); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return handler.WithSession(ctx, "place_mf_sip", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			resp, err := session.Kite.Client.PlaceMFSIP(sipParams)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to place MF SIP", "error", err)
+				return mcp.NewToolResultError("Failed to place MF SIP"), nil
+			}
+
+			return handler.MarshalResponse(resp, "place_mf_sip")
+		})
+	}
+}
+
+type ModifyMFSIPTool struct{}
+
+func (*ModifyMFSIPTool) Tool() mcp.Tool {
+	return mcp.NewTool("modify_mf_sip",
+		mcp.WithDescription("Modify an existing mutual fund SIP, including pausing or resuming it. Only the provided fields are changed."),
+		mcp.WithString("sip_id",
+			mcp.Description("SIP ID"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("amount",
+			mcp.Description("New amount per instalment"),
+		),
+		mcp.WithString("frequency",
+			mcp.Description("New instalment frequency"),
+			mcp.Enum("weekly", "monthly", "quarterly"),
+		),
+		mcp.WithNumber("instalment_day",
+			mcp.Description("New day of the month (1-28) for monthly and quarterly SIPs"),
+		),
+		mcp.WithNumber("instalments",
+			mcp.Description("New number of instalments. Use -1 for a perpetual SIP"),
+		),
+		mcp.WithString("step_up",
+			mcp.Description("New annual step-up as 'DD-MM:percentage', e.g. '15-02:10'"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Set to 'paused' to pause the SIP or 'active' to resume it"),
+			mcp.Enum("active", "paused"),
+		),
+	)
+}
+
+func (*ModifyMFSIPTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "modify_mf_sip")
+		args := request.GetArguments()
+
+		// Validate required parameters
+		if err := ValidateRequired(args, "sip_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		sipID := SafeAssertString(args["sip_id"], "")
+		sipParams := kiteconnect.MFSIPModifyParams{
+			Amount:        SafeAssertFloat64(args["amount"], 0.0),
+			Frequency:     SafeAssertString(args["frequency"], ""),
+			InstalmentDay: SafeAssertInt(args["instalment_day"], 0),
+			Instalments:   SafeAssertInt(args["instalments"], 0),
+			StepUp:        SafeAssertString(args["step_up"], ""),
+			Status:        SafeAssertString(args["status"], ""),
+		}
+
+		if sipParams == (kiteconnect.MFSIPModifyParams{}) {
+			return mcp.NewToolResultError("At least one field to modify must be provided"), nil
+		}
+		if sipParams.Amount < 0 {
+			return mcp.NewToolResultError("amount must not be negative"), nil
+		}
+		if sipParams.Status != "" && sipParams.Status != "active" && sipParams.Status != "paused" {
+			return mcp.NewToolResultError("status must be 'active' or 'paused'"), nil
+		}
+		if err := validateSIPSchedule(sipParams.Frequency, sipParams.Instalments, sipParams.InstalmentDay); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return handler.WithSession(ctx, "modify_mf_sip", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			resp, err := session.Kite.Client.ModifyMFSIP(sipID, sipParams)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to modify MF SIP", "error", err)
+				return mcp.NewToolResultError("Failed to modify MF SIP"), nil
+			}
+
+			return handler.MarshalResponse(resp, "modify_mf_sip")
+		})
+	}
+}
+
+type CancelMFSIPTool struct{}
+
+func (*CancelMFSIPTool) Tool() mcp.Tool {
+	return mcp.NewTool("cancel_mf_sip",
+		mcp.WithDescription("Cancel a mutual fund SIP"),
+		mcp.WithString("sip_id",
+			mcp.Description("SIP ID"),
+			mcp.Required(),
+		),
+	)
+}
+
+func (*CancelMFSIPTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "cancel_mf_sip")
+		args := request.GetArguments()
+
+		// Validate required parameters
+		if err := ValidateRequired(args, "sip_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		sipID := SafeAssertString(args["sip_id"], "")
+
+		return handler.WithSession(ctx, "cancel_mf_sip", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			resp, err := session.Kite.Client.CancelMFSIP(sipID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to cancel MF SIP", "error", err)
+				return mcp.NewToolResultError("Failed to cancel MF SIP"), nil
+			}
+
+			return handler.MarshalResponse(resp, "cancel_mf_sip")
+		})
+	}
+}
+
+type MFAllottedISINsTool struct{}
+
+func (*MFAllottedISINsTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_mf_allotted_isins",
+		mcp.WithDescription("Get the ISINs of mutual fund schemes in which units have been allotted to the user. Supports pagination for large datasets."),
+		mcp.WithNumber("from",
+			mcp.Description("Starting index for pagination (0-based). Default: 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of ISINs to return. If not specified, returns all ISINs. When specified, response includes pagination metadata."),
+		),
+	)
+}
+
+func (*MFAllottedISINsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	return PaginatedToolHandler(manager, "get_mf_allotted_isins", func(session *kc.KiteSessionData) ([]interface{}, error) {
+		isins, err := session.Kite.Client.GetMFAllottedISINs()
+		if err != nil {
+			return nil, err
+		}
+
+		// Convert to []interface{} for generic pagination
+		result := make([]interface{}, len(isins))
+		for i, isin := range isins {
+			result[i] = isin
+		}
+		return result, nil
+	})
+}
+
+type SearchMFInstrumentsTool struct{}
+
+func (*SearchMFInstrumentsTool) Tool() mcp.Tool {
+	return mcp.NewTool("search_mf_instruments",
+		mcp.WithDescription("Search the mutual fund schemes catalog to find the tradingsymbol for place_mf_order and place_mf_sip. Matches are fuzzy and ranked, so partial names and small typos work. Supports pagination for large result sets."),
+		mcp.WithString("query",
+			mcp.Description("Free-text search on scheme name, AMC and tradingsymbol, e.g. 'parag parikh flexi cap'"),
+		),
+		mcp.WithString("amc",
+			mcp.Description("Fuzzy filter on the fund house, e.g. 'hdfc', 'axis'"),
+		),
+		mcp.WithString("category",
+			mcp.Description("Fuzzy filter on scheme category, e.g. 'equity', 'debt', 'elss', 'liquid', 'index'"),
+		),
+		mcp.WithString("plan",
+			mcp.Description("Filter on plan"),
+			mcp.Enum("direct", "regular"),
+		),
+		mcp.WithString("dividend_type",
+			mcp.Description("Filter on dividend option"),
+			mcp.Enum("growth", "payout", "reinvest"),
+		),
+		mcp.WithBoolean("purchase_allowed",
+			mcp.Description("Only return schemes currently open for purchase. Default: false"),
+		),
+		mcp.WithNumber("max_minimum_purchase",
+			mcp.Description("Only return schemes whose minimum purchase amount is at most this amount"),
+		),
+		mcp.WithNumber("from",
+			mcp.Description("Starting index for pagination (0-based). Default: 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of schemes to return. Default: 20"),
+		),
+	)
+}
+
+func (*SearchMFInstrumentsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "search_mf_instruments")
+		args := request.GetArguments()
+
+		opts := instruments.MFSearchOptions{
+			Query:              SafeAssertString(args["query"], ""),
+			AMC:                SafeAssertString(args["amc"], ""),
+			Category:           SafeAssertString(args["category"], ""),
+			Plan:               SafeAssertString(args["plan"], ""),
+			DividendType:       SafeAssertString(args["dividend_type"], ""),
+			PurchasableOnly:    SafeAssertBool(args["purchase_allowed"], false),
+			MaxMinimumPurchase: SafeAssertFloat64(args["max_minimum_purchase"], 0),
+		}
+		if opts.Query == "" && opts.AMC == "" && opts.Category == "" {
+			return mcp.NewToolResultError("At least one of query, amc or category must be provided"), nil
+		}
+
+		results, err := manager.MFInstruments.SearchMF(opts)
+		if errors.Is(err, instruments.ErrMFCatalogEmpty) {
+			// The public list may be unavailable; fall back to the user's session.
+			return handler.WithSession(ctx, "search_mf_instruments", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+				schemes, err := session.Kite.Client.GetMFInstruments()
+				if err != nil {
+					handler.manager.Logger.Error("Failed to get MF instruments", "error", err)
+					handler.trackToolError(ctx, "search_mf_instruments", "api_error")
+					return mcp.NewToolResultError("Mutual fund instruments are not available right now"), nil
+				}
+				manager.MFInstruments.Load(convertMFInstruments(schemes))
+
+				results, err := manager.MFInstruments.SearchMF(opts)
+				if err != nil {
+					return mcp.NewToolResultError("Mutual fund instruments are not available right now"), nil
+				}
+				return handler.MarshalResponse(paginateMFResults(results, args), "search_mf_instruments")
+			})
+		}
+		if err != nil {
+			handler.manager.Logger.Error("Failed to search MF instruments", "error", err)
+			return mcp.NewToolResultError("Failed to search mutual fund instruments"), nil
+		}
+
+		return handler.MarshalResponse(paginateMFResults(results, args), "search_mf_instruments")
+	}
+}
+
+// paginateMFResults applies pagination to search results, returning the
+// top 20 matches when no limit is given.
+func paginateMFResults(results []instruments.MFSearchResult, args map[string]interface{}) *PaginatedResponse {
+	params := ParsePaginationParams(args)
+	if params.Limit <= 0 {
+		params.Limit = 20
+	}
+	paginated := ApplyPagination(results, params)
+	return CreatePaginatedResponse(results, paginated, params, len(results))
+}
+
+// convertMFInstruments maps the Kite client's MF instruments to catalog entries.
+func convertMFInstruments(schemes kiteconnect.MFInstruments) []instruments.MFInstrument {
+	out := make([]instruments.MFInstrument, 0, len(schemes))
+	for _, s := range schemes {
+		inst := instruments.MFInstrument{
+			Tradingsymbol:                   s.Tradingsymbol,
+			AMC:                             s.AMC,
+			Name:                            s.Name,
+			PurchaseAllowed:                 s.PurchaseAllowed,
+			RedemptionAllowed:               s.RedemtpionAllowed,
+			MinimumPurchaseAmount:           s.MinimumPurchaseAmount,
+			PurchaseAmountMultiplier:        s.PurchaseAmountMultiplier,
+			MinimumAdditionalPurchaseAmount: s.MinimumAdditionalPurchaseAmount,
+			MinimumRedemptionQuantity:       s.MinimumRedemptionQuantity,
+			RedemptionQuantityMultiplier:    s.RedemptionQuantityMultiplier,
+			DividendType:                    s.DividendType,
+			SchemeType:                      s.SchemeType,
+			Plan:                            s.Plan,
+			SettlementType:                  s.SettlementType,
+			LastPrice:                       s.LastPrice,
+		}
+		if !s.LastPriceDate.IsZero() {
+			inst.LastPriceDate = s.LastPriceDate.Format("2006-01-02")
+		}
+		out = append(out, inst)
+	}
+	return out
+}
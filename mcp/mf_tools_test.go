@@ -0,0 +1,49 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFOrderAndSIPValidation(t *testing.T) {
+	e := newE2EServer(t)
+
+	// The fake broker has no mutual funds, so parameters that pass the
+	// checks fail at the broker instead
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]any
+		expected string
+	}{
+		{"buy without amount", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "BUY"}, "amount must be greater than 0 for BUY orders"},
+		{"buy with negative amount", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "BUY", "amount": -500.0}, "amount must be greater than 0 for BUY orders"},
+		{"buy by quantity", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "BUY", "amount": 5000.0, "quantity": 10.0}, "quantity is only supported for SELL orders"},
+		{"buy", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "BUY", "amount": 5000.0}, "Failed to place MF order"},
+		{"sell without amount or quantity", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "SELL"}, "either amount or quantity must be greater than 0 for SELL orders"},
+		{"sell by quantity", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "SELL", "quantity": 10.0}, "Failed to place MF order"},
+		{"sell by amount", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "SELL", "amount": 5000.0}, "Failed to place MF order"},
+		{"switch", "place_mf_order", map[string]any{"tradingsymbol": "INF740K01DP8", "transaction_type": "SWITCH", "amount": 5000.0}, "Invalid transaction_type"},
+
+		{"sip with negative amount", "place_mf_sip", map[string]any{"tradingsymbol": "INF740K01DP8", "amount": -500.0, "instalments": 12.0, "frequency": "monthly"}, "amount must be greater than 0"},
+		{"sip with negative initial amount", "place_mf_sip", map[string]any{"tradingsymbol": "INF740K01DP8", "amount": 500.0, "instalments": 12.0, "frequency": "monthly", "initial_amount": -1000.0}, "initial_amount must not be negative"},
+		{"sip with initial amount", "place_mf_sip", map[string]any{"tradingsymbol": "INF740K01DP8", "amount": 500.0, "instalments": 12.0, "frequency": "monthly", "initial_amount": 1000.0}, "Failed to place MF SIP"},
+		{"sip with zero instalments", "place_mf_sip", map[string]any{"tradingsymbol": "INF740K01DP8", "amount": 500.0, "instalments": 0.0, "frequency": "monthly"}, "instalments must be -1 (perpetual) or at least 1"},
+		{"weekly sip on a day", "place_mf_sip", map[string]any{"tradingsymbol": "INF740K01DP8", "amount": 500.0, "instalments": -1.0, "frequency": "weekly", "instalment_day": 5.0}, "is not supported for weekly SIPs"},
+
+		{"modify nothing", "modify_mf_sip", map[string]any{"sip_id": "1234"}, "At least one field to modify must be provided"},
+		{"modify to a negative amount", "modify_mf_sip", map[string]any{"sip_id": "1234", "amount": -500.0}, "amount must not be negative"},
+		{"modify to an unknown status", "modify_mf_sip", map[string]any{"sip_id": "1234", "status": "stopped"}, "status must be 'active' or 'paused'"},
+		{"pause", "modify_mf_sip", map[string]any{"sip_id": "1234", "status": "paused"}, "Failed to modify MF SIP"},
+		{"resume", "modify_mf_sip", map[string]any{"sip_id": "1234", "status": "active"}, "Failed to modify MF SIP"},
+		{"modify to day 29", "modify_mf_sip", map[string]any{"sip_id": "1234", "instalment_day": 29.0}, "must be between 1 and 28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := e.callResult(t, tt.tool, tt.args)
+			assert.True(t, result.IsError)
+			assert.Contains(t, resultText(result), tt.expected)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/oco"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+// NormalizedOCOOrder is an OCO order with the adjustments made to the
+// request before the entry was placed.
+type NormalizedOCOOrder struct {
+	oco.Order
+	Adjustments []orders.Adjustment `json:"adjustments,omitempty"`
+	Warning     string              `json:"warning,omitempty"`
+}
+
+// normalizeOCOSpec aligns the prices and quantity of an OCO order to the
+// instrument. Limit prices round in the trader's favour for their side.
+func normalizeOCOSpec(n *orders.Normalizer, spec *oco.Spec) error {
+	exitSide := spec.ExitTransactionType()
+
+	var err error
+	if spec.EntryPrice, err = n.Price("entry_price", spec.EntryPrice, orders.LimitRounding(spec.TransactionType)); err != nil {
+		return err
+	}
+	if spec.TargetPrice, err = n.Price("target_price", spec.TargetPrice, orders.LimitRounding(exitSide)); err != nil {
+		return err
+	}
+	if spec.StopTriggerPrice, err = n.Price("stop_trigger_price", spec.StopTriggerPrice, orders.RoundNearest); err != nil {
+		return err
+	}
+	if spec.StopPrice, err = n.Price("stop_price", spec.StopPrice, orders.LimitRounding(exitSide)); err != nil {
+		return err
+	}
+	spec.Quantity, err = n.Quantity("quantity", spec.Quantity)
+	return err
+}
+
+// ocoError converts an engine error to a tool result.
+func ocoError(handler *ToolHandler, action, ocoID string, err error) *mcp.CallToolResult {
+	switch {
+	case errors.Is(err, oco.ErrNotFound), errors.Is(err, oco.ErrFinished):
+		return mcp.NewToolResultError(err.Error())
+	}
+	handler.manager.Logger.Error("Failed to "+action+" OCO order", "oco_id", ocoID, "error", err)
+	return mcp.NewToolResultError(fmt.Sprintf("Failed to %s OCO order: %s", action, err.Error()))
+}
+
+type PlaceOCOOrderTool struct{}
+
+func (*PlaceOCOOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("place_oco_order",
+		mcp.WithDescription("Place an entry order and, once it fills, a target LIMIT order and a stop-loss order for the filled quantity. When one exit fills the other is cancelled. Use get_oco_orders to follow its state and cancel_oco_order to stop it."),
+		mcp.WithString("exchange",
+			mcp.Description("The exchange to which the order should be placed"),
+			mcp.Required(),
+			mcp.DefaultString("NSE"),
+			mcp.Enum("NSE", "BSE", "MCX", "NFO", "BFO"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Trading symbol"),
+			mcp.Required(),
+		),
+		mcp.WithString("transaction_type",
+			mcp.Description("Side of the entry order; the exits take the opposite side"),
+			mcp.Required(),
+			mcp.Enum("BUY", "SELL"),
+		),
+		mcp.WithNumber("quantity",
+			mcp.Description("Quantity"),
+			mcp.Required(),
+			mcp.Min(1),
+		),
+		mcp.WithString("product",
+			mcp.Description("Product type. Default: MIS"),
+			mcp.Enum("MIS", "NRML", "CNC"),
+		),
+		mcp.WithString("entry_order_type",
+			mcp.Description("Entry order type. Default: MARKET"),
+			mcp.Enum("MARKET", "LIMIT"),
+		),
+		mcp.WithNumber("entry_price",
+			mcp.Description("Entry limit price, required for a LIMIT entry"),
+		),
+		mcp.WithNumber("target_price",
+			mcp.Description("Limit price of the target exit"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("stop_trigger_price",
+			mcp.Description("Trigger price of the stop-loss exit"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("stop_price",
+			mcp.Description("Limit price of the stop-loss exit. If omitted the stop is placed as SL-M"),
+		),
+		withNormalization(),
+	)
+}
+
+func (*PlaceOCOOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "place_oco_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "exchange", "tradingsymbol", "transaction_type", "quantity", "target_price", "stop_trigger_price"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		spec := oco.Spec{
+			Exchange:         SafeAssertString(args["exchange"], "NSE"),
+			Tradingsymbol:    SafeAssertString(args["tradingsymbol"], ""),
+			TransactionType:  SafeAssertString(args["transaction_type"], ""),
+			Product:          SafeAssertString(args["product"], kiteconnect.ProductMIS),
+			Quantity:         SafeAssertInt(args["quantity"], 0),
+			EntryOrderType:   SafeAssertString(args["entry_order_type"], kiteconnect.OrderTypeMarket),
+			EntryPrice:       SafeAssertFloat64(args["entry_price"], 0),
+			TargetPrice:      SafeAssertFloat64(args["target_price"], 0),
+			StopTriggerPrice: SafeAssertFloat64(args["stop_trigger_price"], 0),
+			StopPrice:        SafeAssertFloat64(args["stop_price"], 0),
+		}
+		if spec.EntryOrderType == kiteconnect.OrderTypeMarket {
+			spec.EntryPrice = 0
+		}
+
+		normalizer, err := newOrderNormalizer(handler.manager, args, spec.Exchange, spec.Tradingsymbol, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := normalizeOCOSpec(normalizer, &spec); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := spec.Validate(); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		warning := orderWarning(handler.manager.Calendar, kiteconnect.VarietyRegular, spec.Exchange)
+
+		return handler.WithSession(ctx, "place_oco_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			spec.UserID = session.UserID
+			order, err := handler.manager.OCO.Place(oco.KiteBroker{Client: session.Kite.Client}, spec)
+			if err != nil {
+				return ocoError(handler, "place", "", err), nil
+			}
+
+			return handler.MarshalResponse(NormalizedOCOOrder{Order: order, Adjustments: normalizer.Adjustments, Warning: warning}, "place_oco_order")
+		})
+	}
+}
+
+type GetOCOOrdersTool struct{}
+
+func (*GetOCOOrdersTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_oco_orders",
+		mcp.WithDescription("Get the state of OCO orders placed with place_oco_order: the entry, target and stop orders and whether the target or stop was hit. Finished OCO orders are kept for a day."),
+		mcp.WithString("oco_id",
+			mcp.Description("ID of a single OCO order. If omitted, all OCO orders are returned"),
+		),
+	)
+}
+
+func (*GetOCOOrdersTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_oco_orders")
+		args := request.GetArguments()
+		ocoID := SafeAssertString(args["oco_id"], "")
+
+		return handler.WithSession(ctx, "get_oco_orders", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			if ocoID == "" {
+				return handler.MarshalResponse(handler.manager.OCO.List(session.UserID), "get_oco_orders")
+			}
+
+			order, err := handler.manager.OCO.Get(ocoID, session.UserID)
+			if err != nil {
+				return ocoError(handler, "get", ocoID, err), nil
+			}
+			return handler.MarshalResponse(order, "get_oco_orders")
+		})
+	}
+}
+
+type CancelOCOOrderTool struct{}
+
+func (*CancelOCOOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("cancel_oco_order",
+		mcp.WithDescription("Cancel an OCO order: the entry order if it has not filled, otherwise the target and stop orders. Cancelling after the entry fills leaves the position open without exits."),
+		mcp.WithString("oco_id",
+			mcp.Description("ID of the OCO order"),
+			mcp.Required(),
+		),
+	)
+}
+
+func (*CancelOCOOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "cancel_oco_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "oco_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ocoID := SafeAssertString(args["oco_id"], "")
+
+		return handler.WithSession(ctx, "cancel_oco_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			order, err := handler.manager.OCO.Cancel(ocoID, session.UserID)
+			if err != nil {
+				return ocoError(handler, "cancel", ocoID, err), nil
+			}
+			return handler.MarshalResponse(order, "cancel_oco_order")
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/kite-mcp-server/kc/oco"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+func TestNormalizeOCOSpec(t *testing.T) {
+	n := orders.NewNormalizer(orders.Spec{TickSize: 0.05, LotSize: 1}, orders.ModeAdjust)
+	spec := oco.Spec{
+		TransactionType:  "BUY",
+		Quantity:         10,
+		EntryOrderType:   "LIMIT",
+		EntryPrice:       1500.02,
+		TargetPrice:      1530.01,
+		StopTriggerPrice: 1485.03,
+		StopPrice:        1480.02,
+	}
+
+	require.NoError(t, normalizeOCOSpec(n, &spec))
+	// The buy entry rounds down, the sell exits round up.
+	assert.Equal(t, 1500.0, spec.EntryPrice)
+	assert.Equal(t, 1530.05, spec.TargetPrice)
+	assert.Equal(t, 1485.05, spec.StopTriggerPrice)
+	assert.Equal(t, 1480.05, spec.StopPrice)
+	assert.Len(t, n.Adjustments, 4)
+}
+
+func TestNormalizeOCOSpecStrict(t *testing.T) {
+	n := orders.NewNormalizer(orders.Spec{TickSize: 0.05, LotSize: 75}, orders.ModeStrict)
+	spec := oco.Spec{TransactionType: "SELL", Quantity: 100, TargetPrice: 95, StopTriggerPrice: 105}
+
+	assert.Error(t, normalizeOCOSpec(n, &spec))
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+// withNormalization adds the normalization option shared by order and GTT tools.
+func withNormalization() mcp.ToolOption {
+	return mcp.WithString("normalization",
+		mcp.Description("How prices and quantities that do not match the instrument's tick size or lot size are handled: 'adjust' rounds them to valid values (limit prices never move against you, quantities round down) and reports the changes, 'strict' rejects the order. Default: adjust"),
+		mcp.Enum(string(orders.ModeAdjust), string(orders.ModeStrict)),
+	)
+}
+
+// newOrderNormalizer builds a normalizer for an instrument. Circuit limits
+// only apply to orders that go to the exchange today, not to GTT triggers.
+// Instruments missing from the instruments dump are passed through
+// unchecked.
+func newOrderNormalizer(manager *kc.Manager, args map[string]interface{}, exchange, tradingsymbol string, withCircuit bool) (*orders.Normalizer, error) {
+	mode, err := orders.ParseMode(SafeAssertString(args["normalization"], ""))
+	if err != nil {
+		return nil, ValidationError{Parameter: "normalization", Message: err.Error()}
+	}
+
+	var spec orders.Spec
+	if inst, err := manager.Instruments.GetByTradingsymbol(exchange, tradingsymbol); err == nil {
+		spec = orders.Spec{
+			TickSize:   inst.TickSize,
+			LotSize:    inst.LotSize,
+			Multiplier: inst.Multiplier,
+		}
+		if withCircuit {
+			spec.LowerCircuitLimit = inst.LowerCircuitLimit
+			spec.UpperCircuitLimit = inst.UpperCircuitLimit
+		}
+	}
+	return orders.NewNormalizer(spec, mode), nil
+}
+
+// normalizeOrderParams normalizes the price, trigger price and quantity of
+// an order in place. A zero quantity is left alone so that modifications
+// without a quantity keep the order's own.
+func normalizeOrderParams(n *orders.Normalizer, params *kiteconnect.OrderParams) error {
+	var err error
+	if params.Price, err = n.Price("price", params.Price, orders.LimitRounding(params.TransactionType)); err != nil {
+		return err
+	}
+	if params.TriggerPrice, err = n.Price("trigger_price", params.TriggerPrice, orders.RoundNearest); err != nil {
+		return err
+	}
+	if params.Quantity != 0 {
+		if params.Quantity, err = n.Quantity("quantity", params.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeTriggerParams normalizes one GTT leg in place. field prefixes the
+// reported field names, e.g. "upper_" for the upper leg of a two-leg GTT.
+func normalizeTriggerParams(n *orders.Normalizer, field, transactionType string, tp *kiteconnect.TriggerParams) error {
+	var err error
+	if tp.TriggerValue, err = n.Price(field+"trigger_value", tp.TriggerValue, orders.RoundNearest); err != nil {
+		return err
+	}
+	if tp.LimitPrice, err = n.Price(field+"limit_price", tp.LimitPrice, orders.LimitRounding(transactionType)); err != nil {
+		return err
+	}
+
+	if float64(int(tp.Quantity)) != tp.Quantity {
+		return fmt.Errorf("%squantity must be a whole number", field)
+	}
+	qty, err := n.Quantity(field+"quantity", int(tp.Quantity))
+	if err != nil {
+		return err
+	}
+	tp.Quantity = float64(qty)
+	return nil
+}
+
+// normalizeGTTParams normalizes every leg of a GTT in place.
+func normalizeGTTParams(n *orders.Normalizer, params *kiteconnect.GTTParams) error {
+	switch trigger := params.Trigger.(type) {
+	case *kiteconnect.GTTSingleLegTrigger:
+		return normalizeTriggerParams(n, "", params.TransactionType, &trigger.TriggerParams)
+	case *kiteconnect.GTTOneCancelsOtherTrigger:
+		if err := normalizeTriggerParams(n, "upper_", params.TransactionType, &trigger.Upper); err != nil {
+			return err
+		}
+		return normalizeTriggerParams(n, "lower_", params.TransactionType, &trigger.Lower)
+	}
+	return nil
+}
+
+// NormalizedOrderResponse is an order response with the adjustments made
+// to the request before it was sent.
+type NormalizedOrderResponse struct {
+	kiteconnect.OrderResponse
+	Adjustments []orders.Adjustment `json:"adjustments,omitempty"`
+	Warning     string              `json:"warning,omitempty"`
+}
+
+// NormalizedGTTResponse is a GTT response with the adjustments made to the
+// request before it was sent.
+type NormalizedGTTResponse struct {
+	kiteconnect.GTTResponse
+	Adjustments []orders.Adjustment `json:"adjustments,omitempty"`
+}
+
+// normalizeModifyParams normalizes an order modification. The transaction
+// type only steers limit price rounding and is not sent with the request.
+func normalizeModifyParams(n *orders.Normalizer, transactionType string, params *kiteconnect.OrderParams) error {
+	params.TransactionType = transactionType
+	err := normalizeOrderParams(n, params)
+	params.TransactionType = ""
+	return err
+}
+
+// defaultTickSize is the NSE equity tick, used when an instrument is not in
+// the instruments dump.
+const defaultTickSize = 0.05
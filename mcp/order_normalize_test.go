@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+func TestNormalizeOrderParams(t *testing.T) {
+	n := orders.NewNormalizer(orders.Spec{TickSize: 0.05, LotSize: 75}, orders.ModeAdjust)
+	params := kiteconnect.OrderParams{TransactionType: "SELL", Quantity: 160, Price: 101.01, TriggerPrice: 101.42}
+
+	require.NoError(t, normalizeOrderParams(n, &params))
+	assert.Equal(t, 150, params.Quantity)
+	assert.Equal(t, 101.05, params.Price)
+	assert.Equal(t, 101.40, params.TriggerPrice)
+	assert.Len(t, n.Adjustments, 3)
+
+	// Modifications without a quantity leave it out of the request.
+	modify := kiteconnect.OrderParams{Price: 101.01}
+	require.NoError(t, normalizeModifyParams(n, "BUY", &modify))
+	assert.Equal(t, 0, modify.Quantity)
+	assert.Equal(t, 101.0, modify.Price)
+	assert.Empty(t, modify.TransactionType)
+}
+
+func TestNormalizeGTTParams(t *testing.T) {
+	n := orders.NewNormalizer(orders.Spec{TickSize: 0.05, LotSize: 75}, orders.ModeAdjust)
+	params := kiteconnect.GTTParams{
+		TransactionType: "SELL",
+		Trigger: &kiteconnect.GTTOneCancelsOtherTrigger{
+			Upper: kiteconnect.TriggerParams{TriggerValue: 120.02, LimitPrice: 119.93, Quantity: 75},
+			Lower: kiteconnect.TriggerParams{TriggerValue: 90.01, LimitPrice: 89.96, Quantity: 75},
+		},
+	}
+
+	require.NoError(t, normalizeGTTParams(n, &params))
+	trigger := params.Trigger.(*kiteconnect.GTTOneCancelsOtherTrigger)
+	assert.Equal(t, 120.0, trigger.Upper.TriggerValue)
+	assert.Equal(t, 119.95, trigger.Upper.LimitPrice)
+	assert.Equal(t, 90.0, trigger.Lower.TriggerValue)
+	assert.Equal(t, "upper_trigger_value", n.Adjustments[0].Field)
+
+	single := kiteconnect.GTTParams{Trigger: &kiteconnect.GTTSingleLegTrigger{
+		TriggerParams: kiteconnect.TriggerParams{TriggerValue: 100, LimitPrice: 100, Quantity: 10.5},
+	}}
+	assert.Error(t, normalizeGTTParams(n, &single))
+}
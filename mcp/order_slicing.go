@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+// maxSliceIntervalMs bounds the user-supplied pacing between child orders.
+const maxSliceIntervalMs = 5000
+
+// instrumentLimits looks up the lot size and per-order limits of an
+// instrument. Unknown instruments have no limits, so the exchange remains
+// the final judge of quantities the instruments dump does not describe.
+func instrumentLimits(manager *kc.Manager, exchange, tradingsymbol string) orders.Limits {
+	inst, err := manager.Instruments.GetByTradingsymbol(exchange, tradingsymbol)
+	if err != nil {
+		return orders.Limits{}
+	}
+	return orders.Limits{
+		LotSize:          inst.LotSize,
+		FreezeQuantity:   int(inst.FreezeQuantity),
+		MaxOrderQuantity: inst.MaxOrderQuantity,
+		Unknown:          manager.Instruments.Partial(),
+	}
+}
+
+// unknownLimitsWarning is the warning of an order for an instrument whose
+// freeze quantity and circuit limits are unknown, as the instruments were
+// loaded from a CSV dump without them.
+func unknownLimitsWarning(tradingsymbol string) string {
+	return fmt.Sprintf("the freeze quantity and circuit limits of %s are unknown, as the instruments were loaded from a CSV dump without them, so the order wasn't checked against them", tradingsymbol)
+}
+
+// joinWarnings joins the warnings of an order, skipping empty ones.
+func joinWarnings(warnings ...string) string {
+	return strings.Join(slices.DeleteFunc(warnings, func(w string) bool { return w == "" }), "; ")
+}
+
+// sliceInterval reads the slice_interval_ms argument, falling back to the
+// default pacing.
+func sliceInterval(args map[string]interface{}) (time.Duration, error) {
+	if args["slice_interval_ms"] == nil {
+		return orders.DefaultSliceInterval, nil
+	}
+	ms := SafeAssertInt(args["slice_interval_ms"], 0)
+	if ms < 0 || ms > maxSliceIntervalMs {
+		return 0, ValidationError{Parameter: "slice_interval_ms", Message: fmt.Sprintf("must be between 0 and %d", maxSliceIntervalMs)}
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// placeSlicedOrder places params as one or more child orders that each stay
+// below the instrument's freeze quantity, waiting interval between them.
+// Groups of more than one child are recorded so that they can be modified
+// or cancelled together. The returned group holds the orders placed before
+// any failure.
+func placeSlicedOrder(ctx context.Context, manager *kc.Manager, client kc.KiteClient, variety string, params kiteconnect.OrderParams, limits orders.Limits, interval time.Duration) (orders.Group, error) {
+	group := orders.Group{
+		Variety:         variety,
+		Exchange:        params.Exchange,
+		Tradingsymbol:   params.Tradingsymbol,
+		TransactionType: params.TransactionType,
+		Quantity:        params.Quantity,
+	}
+
+	quantities, err := orders.Slice(params.Quantity, limits)
+	if err != nil {
+		return group, err
+	}
+
+	orderIDs, err := orders.PlaceSlices(ctx, quantities, interval, func(qty int) (string, error) {
+		p := params
+		p.Quantity = qty
+		resp, err := client.PlaceOrder(variety, p)
+		if err != nil {
+			return "", err
+		}
+		return resp.OrderID, nil
+	})
+	group.OrderIDs = orderIDs
+
+	if len(orderIDs) > 1 {
+		if stored := manager.OrderGroups.Add(group); stored != nil {
+			group = *stored
+		}
+	} else if len(orderIDs) == 1 {
+		group.ID = orderIDs[0]
+	}
+	return group, err
+}
+
+// ChildOrderStatus is the state of one child of a sliced order.
+type ChildOrderStatus struct {
+	OrderID         string  `json:"order_id"`
+	Status          string  `json:"status"`
+	StatusMessage   string  `json:"status_message,omitempty"`
+	Quantity        float64 `json:"quantity"`
+	FilledQuantity  float64 `json:"filled_quantity"`
+	PendingQuantity float64 `json:"pending_quantity"`
+	AveragePrice    float64 `json:"average_price"`
+}
+
+// OrderGroupStatus aggregates the fill state of a sliced order group.
+type OrderGroupStatus struct {
+	orders.Group
+	Children        []ChildOrderStatus  `json:"children"`
+	FilledQuantity  float64             `json:"filled_quantity"`
+	PendingQuantity float64             `json:"pending_quantity"`
+	AveragePrice    float64             `json:"average_price"`
+	Complete        bool                `json:"complete"`
+	Adjustments     []orders.Adjustment `json:"adjustments,omitempty"`
+	Warning         string              `json:"warning,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+// orderGroupStatus fetches the order book once and aggregates the status of
+// the group's children. Children missing from the order book are reported
+// with status UNKNOWN.
+func orderGroupStatus(client kc.KiteClient, group orders.Group) OrderGroupStatus {
+	status := OrderGroupStatus{Group: group, Children: make([]ChildOrderStatus, 0, len(group.OrderIDs))}
+
+	book := make(map[string]kiteconnect.Order)
+	if all, err := client.GetOrders(); err == nil {
+		for _, o := range all {
+			book[o.OrderID] = o
+		}
+	}
+
+	var filledValue float64
+	status.Complete = len(group.OrderIDs) > 0
+	for _, id := range group.OrderIDs {
+		o, ok := book[id]
+		if !ok {
+			status.Children = append(status.Children, ChildOrderStatus{OrderID: id, Status: "UNKNOWN"})
+			status.Complete = false
+			continue
+		}
+
+		status.Children = append(status.Children, ChildOrderStatus{
+			OrderID:         id,
+			Status:          o.Status,
+			StatusMessage:   o.StatusMessage,
+			Quantity:        o.Quantity,
+			FilledQuantity:  o.FilledQuantity,
+			PendingQuantity: o.PendingQuantity,
+			AveragePrice:    o.AveragePrice,
+		})
+		status.FilledQuantity += o.FilledQuantity
+		status.PendingQuantity += o.PendingQuantity
+		filledValue += o.FilledQuantity * o.AveragePrice
+		if o.Status != "COMPLETE" {
+			status.Complete = false
+		}
+	}
+	if status.FilledQuantity > 0 {
+		status.AveragePrice = filledValue / status.FilledQuantity
+	}
+	return status
+}
+
+// OrderGroupActionResult reports a modify or cancel applied to a sliced group.
+type OrderGroupActionResult struct {
+	GroupID     string              `json:"group_id"`
+	Succeeded   []string            `json:"succeeded"`
+	Skipped     []string            `json:"skipped,omitempty"`
+	Failed      map[string]string   `json:"failed,omitempty"`
+	Status      OrderGroupStatus    `json:"status"`
+	Adjustments []orders.Adjustment `json:"adjustments,omitempty"`
+}
+
+// applyToOrderGroup runs action on every open child of group. Children that
+// have already completed, been cancelled or been rejected are skipped.
+func applyToOrderGroup(client kc.KiteClient, group orders.Group, action func(child ChildOrderStatus) error) OrderGroupActionResult {
+	result := OrderGroupActionResult{GroupID: group.ID, Succeeded: []string{}, Failed: map[string]string{}}
+
+	for _, child := range orderGroupStatus(client, group).Children {
+		if orderwatch.IsTerminal(child.Status) {
+			result.Skipped = append(result.Skipped, child.OrderID)
+			continue
+		}
+		if err := action(child); err != nil {
+			result.Failed[child.OrderID] = err.Error()
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, child.OrderID)
+	}
+
+	result.Status = orderGroupStatus(client, group)
+	return result
+}
@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+// fakeOrderBook is a minimal Kite order API that fills the first child order
+// and leaves the rest open.
+type fakeOrderBook struct {
+	mu        sync.Mutex
+	orders    []map[string]interface{}
+	cancelled []string
+}
+
+func (f *fakeOrderBook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var data interface{}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/orders/regular":
+		_ = r.ParseForm()
+		qty, _ := strconv.ParseFloat(r.PostForm.Get("quantity"), 64)
+		id := fmt.Sprintf("%d", 1000+len(f.orders))
+		status, filled := "OPEN", 0.0
+		if len(f.orders) == 0 {
+			status, filled = "COMPLETE", qty
+		}
+		f.orders = append(f.orders, map[string]interface{}{
+			"order_id": id, "status": status, "quantity": qty,
+			"filled_quantity": filled, "pending_quantity": qty - filled, "average_price": 100.0,
+		})
+		data = map[string]string{"order_id": id}
+	case r.Method == http.MethodGet && r.URL.Path == "/orders":
+		data = f.orders
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/orders/regular/"):
+		id := strings.TrimPrefix(r.URL.Path, "/orders/regular/")
+		f.cancelled = append(f.cancelled, id)
+		for _, o := range f.orders {
+			if o["order_id"] == id {
+				o["status"] = "CANCELLED"
+			}
+		}
+		data = map[string]string{"order_id": id}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": data})
+}
+
+func TestSlicedOrderGroup(t *testing.T) {
+	book := &fakeOrderBook{}
+	server := httptest.NewServer(book)
+	defer server.Close()
+
+	client := kiteconnect.New("test_key")
+	client.SetBaseURI(server.URL)
+	manager := &kc.Manager{OrderGroups: orders.NewGroupStore()}
+
+	params := kiteconnect.OrderParams{
+		Exchange: "NFO", Tradingsymbol: "NIFTY25JANFUT", TransactionType: "BUY",
+		Product: "NRML", OrderType: "MARKET", Quantity: 4500,
+	}
+	limits := orders.Limits{LotSize: 75, FreezeQuantity: 1800}
+
+	group, err := placeSlicedOrder(context.Background(), manager, client, "regular", params, limits, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1000", "1001", "1002"}, group.OrderIDs)
+	assert.Equal(t, "1000", group.ID)
+
+	status := orderGroupStatus(client, group)
+	assert.Equal(t, 1725.0, status.FilledQuantity)
+	assert.Equal(t, 2775.0, status.PendingQuantity)
+	assert.False(t, status.Complete)
+
+	stored, ok := manager.OrderGroups.Lookup("1002")
+	require.True(t, ok)
+
+	result := applyToOrderGroup(client, stored, func(child ChildOrderStatus) error {
+		_, err := client.CancelOrder(stored.Variety, child.OrderID, nil)
+		return err
+	})
+	assert.Equal(t, []string{"1001", "1002"}, result.Succeeded)
+	assert.Equal(t, []string{"1000"}, result.Skipped)
+	assert.Equal(t, []string{"1001", "1002"}, book.cancelled)
+}
+
+func TestSliceInterval(t *testing.T) {
+	d, err := sliceInterval(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, orders.DefaultSliceInterval, d)
+
+	d, err = sliceInterval(map[string]interface{}{"slice_interval_ms": 500.0})
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), d.Milliseconds())
+
+	_, err = sliceInterval(map[string]interface{}{"slice_interval_ms": -1.0})
+	assert.Error(t, err)
+}
+
+func TestPlaceOrderWithUnknownLimits(t *testing.T) {
+	// The CSV dump has no freeze quantities or circuit limits
+	path := filepath.Join(t.TempDir(), "instruments.csv")
+	require.NoError(t, os.WriteFile(path, []byte("instrument_token,exchange_token,tradingsymbol,name,last_price,expiry,strike,tick_size,lot_size,instrument_type,segment,exchange\n"+
+		"13238274,51712,NIFTY25JANFUT,NIFTY,0.0,2025-01-30,0.0,0.05,75,FUT,NFO-FUT,NFO\n"), 0o600))
+	updateConfig := instruments.DefaultUpdateConfig()
+	updateConfig.EnableScheduler = false
+	updateConfig.Source = instruments.Source{URL: path}
+	im, err := instruments.New(instruments.Config{UpdateConfig: updateConfig, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	require.NoError(t, err)
+	require.True(t, im.Partial())
+
+	broker := kitefake.New()
+	broker.AddInstrument(kitefake.Instrument{Exchange: "NFO", Tradingsymbol: "NIFTY25JANFUT", InstrumentToken: 13238274, LastPrice: 23500})
+	e := startE2EServer(t, kc.Config{
+		InstrumentsManager: im,
+		KiteClientFactory: func(apiKey string) kc.KiteClient {
+			return broker.Client(apiKey)
+		},
+	})
+
+	args := map[string]any{
+		"variety": "regular", "exchange": "NFO", "tradingsymbol": "NIFTY25JANFUT", "transaction_type": "BUY",
+		"quantity": 3600.0, "product": "NRML", "order_type": "MARKET", "slice_orders": true,
+	}
+	result := e.callResult(t, "place_order", args)
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(result), "freeze quantity of NIFTY25JANFUT is unknown")
+
+	args["slice_orders"] = false
+	placed := e.callJSON(t, "place_order", args)
+	assert.NotEmpty(t, placed["order_id"])
+	assert.Contains(t, placed["warning"], "freeze quantity and circuit limits of NIFTY25JANFUT are unknown")
+}
@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+const (
+	// orderNotificationLogger names the source of order update notifications.
+	orderNotificationLogger = "kite.orders"
+
+	defaultOrderWaitSeconds = 60
+	maxOrderWaitSeconds     = 300
+
+	// orderPollInterval is how often wait_for_order checks the order book
+	// in case postbacks are not configured or get lost.
+	orderPollInterval = 5 * time.Second
+)
+
+// RegisterOrderNotifications forwards order postbacks to the MCP sessions
+// logged in as the order's user, as logging notifications.
+func RegisterOrderNotifications(srv *server.MCPServer, manager *kc.Manager) {
+	manager.OrderUpdates.OnUpdate(func(u orderwatch.Update) {
+		u.Checksum = ""
+
+		level := mcp.LoggingLevelInfo
+		if u.Status == orderwatch.StatusRejected {
+			level = mcp.LoggingLevelWarning
+		}
+		params := map[string]any{
+			"level":  level,
+			"logger": orderNotificationLogger,
+			"data":   u,
+		}
+
+		for _, sessionID := range manager.SessionIDsForUser(u.UserID) {
+			if err := srv.SendNotificationToSpecificClient(sessionID, "notifications/message", params); err != nil {
+				manager.Logger.Debug("Failed to send order notification", "session_id", sessionID, "order_id", u.OrderID, "error", err)
+			}
+		}
+	})
+}
+
+// OrderWaitResult is the outcome of waiting for an order.
+type OrderWaitResult struct {
+	OrderID  string            `json:"order_id"`
+	Status   string            `json:"status"`
+	Terminal bool              `json:"terminal"`
+	TimedOut bool              `json:"timed_out"`
+	Source   string            `json:"source"`
+	Order    orderwatch.Update `json:"order"`
+}
+
+// waitForOrder blocks until the order reaches a terminal status, the timeout
+// expires or ctx is cancelled. Postbacks from hub end the wait immediately;
+// fetch is polled every pollInterval as a fallback and supplies the last
+// known state on timeout.
+func waitForOrder(ctx context.Context, hub *orderwatch.Hub, orderID string, timeout, pollInterval time.Duration, fetch func() (orderwatch.Update, error)) (OrderWaitResult, error) {
+	result := OrderWaitResult{OrderID: orderID}
+	finish := func(u orderwatch.Update, source string) OrderWaitResult {
+		result.Order = u
+		result.Status = u.Status
+		result.Terminal = orderwatch.IsTerminal(u.Status)
+		result.Source = source
+		return result
+	}
+
+	current, err := fetch()
+	if err != nil {
+		return result, err
+	}
+	if orderwatch.IsTerminal(current.Status) {
+		return finish(current, "order_history"), nil
+	}
+
+	updates, unsubscribe := hub.Subscribe(orderID)
+	defer unsubscribe()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case u := <-updates:
+			return finish(u, "postback"), nil
+		case <-poll.C:
+			if u, err := fetch(); err == nil {
+				current = u
+				if orderwatch.IsTerminal(u.Status) {
+					return finish(u, "order_history"), nil
+				}
+			}
+		case <-deadline.C:
+			result = finish(current, "order_history")
+			result.TimedOut = true
+			return result, nil
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+}
+
+type WaitForOrderTool struct{}
+
+func (*WaitForOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("wait_for_order",
+		mcp.WithDescription("Wait until an order is complete, cancelled or rejected, or until the timeout expires, and return its latest status. Uses Kite order postbacks when configured and falls back to checking the order book."),
+		mcp.WithString("order_id",
+			mcp.Description("ID of the order to wait for"),
+			mcp.Required(),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description(fmt.Sprintf("Maximum time to wait in seconds. Default: %d", defaultOrderWaitSeconds)),
+			mcp.Min(1),
+			mcp.Max(maxOrderWaitSeconds),
+		),
+	)
+}
+
+func (*WaitForOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "wait_for_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "order_id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		orderID := SafeAssertString(args["order_id"], "")
+		timeoutSeconds := SafeAssertInt(args["timeout_seconds"], defaultOrderWaitSeconds)
+		if timeoutSeconds < 1 || timeoutSeconds > maxOrderWaitSeconds {
+			return mcp.NewToolResultError(fmt.Sprintf("timeout_seconds must be between 1 and %d", maxOrderWaitSeconds)), nil
+		}
+
+		return handler.WithSession(ctx, "wait_for_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			// Fetching through the session's own client also proves the
+			// order belongs to this user before any postback is trusted.
+			fetch := func() (orderwatch.Update, error) {
+				history, err := session.Kite.Client.GetOrderHistory(orderID)
+				if err != nil {
+					return orderwatch.Update{}, err
+				}
+				if len(history) == 0 {
+					return orderwatch.Update{}, errors.New("order has no history")
+				}
+				return orderwatch.FromOrder(history[len(history)-1]), nil
+			}
+
+			result, err := waitForOrder(ctx, handler.manager.OrderUpdates, orderID, time.Duration(timeoutSeconds)*time.Second, orderPollInterval, fetch)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to wait for order", "order_id", orderID, "error", err)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to wait for order: %s", err.Error())), nil
+			}
+
+			return handler.MarshalResponse(result, "wait_for_order")
+		})
+	}
+}
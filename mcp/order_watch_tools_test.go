@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/kite-mcp-server/kc/orderwatch"
+)
+
+// fakeOrderHistory returns a fixed sequence of statuses, repeating the last.
+type fakeOrderHistory struct {
+	mu       sync.Mutex
+	statuses []string
+	calls    int
+}
+
+func (f *fakeOrderHistory) fetch() (orderwatch.Update, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := min(f.calls, len(f.statuses)-1)
+	f.calls++
+	return orderwatch.Update{OrderID: "1", Status: f.statuses[i]}, nil
+}
+
+func TestWaitForOrderPostback(t *testing.T) {
+	hub := orderwatch.NewHub()
+	history := &fakeOrderHistory{statuses: []string{"OPEN"}}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		hub.Publish(orderwatch.Update{OrderID: "1", Status: "COMPLETE", AveragePrice: 101.5})
+	}()
+
+	result, err := waitForOrder(context.Background(), hub, "1", time.Second, time.Hour, history.fetch)
+	require.NoError(t, err)
+	assert.Equal(t, "COMPLETE", result.Status)
+	assert.True(t, result.Terminal)
+	assert.False(t, result.TimedOut)
+	assert.Equal(t, "postback", result.Source)
+	assert.Equal(t, 101.5, result.Order.AveragePrice)
+}
+
+func TestWaitForOrderAlreadyTerminal(t *testing.T) {
+	hub := orderwatch.NewHub()
+	history := &fakeOrderHistory{statuses: []string{"REJECTED"}}
+
+	result, err := waitForOrder(context.Background(), hub, "1", time.Second, time.Hour, history.fetch)
+	require.NoError(t, err)
+	assert.Equal(t, "REJECTED", result.Status)
+	assert.Equal(t, "order_history", result.Source)
+	assert.Equal(t, 1, history.calls)
+}
+
+func TestWaitForOrderPollFallback(t *testing.T) {
+	hub := orderwatch.NewHub()
+	history := &fakeOrderHistory{statuses: []string{"OPEN", "OPEN", "CANCELLED"}}
+
+	result, err := waitForOrder(context.Background(), hub, "1", time.Second, 5*time.Millisecond, history.fetch)
+	require.NoError(t, err)
+	assert.Equal(t, "CANCELLED", result.Status)
+	assert.Equal(t, "order_history", result.Source)
+	assert.False(t, result.TimedOut)
+}
+
+func TestWaitForOrderTimeout(t *testing.T) {
+	hub := orderwatch.NewHub()
+	history := &fakeOrderHistory{statuses: []string{"OPEN", "TRIGGER PENDING"}}
+
+	result, err := waitForOrder(context.Background(), hub, "1", 30*time.Millisecond, 5*time.Millisecond, history.fetch)
+	require.NoError(t, err)
+	assert.True(t, result.TimedOut)
+	assert.False(t, result.Terminal)
+	assert.Equal(t, "TRIGGER PENDING", result.Status)
+}
+
+func TestWaitForOrderFetchError(t *testing.T) {
+	hub := orderwatch.NewHub()
+	fetch := func() (orderwatch.Update, error) {
+		return orderwatch.Update{}, errors.New("order not found")
+	}
+
+	_, err := waitForOrder(context.Background(), hub, "1", time.Second, time.Hour, fetch)
+	assert.Error(t, err)
+}
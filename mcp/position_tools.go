@@ -0,0 +1,309 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+)
+
+// findPosition returns the open net position for an instrument. When product
+// is empty and the instrument is held under several products, it fails so
+// that the caller picks one explicitly.
+func findPosition(positions kiteconnect.Positions, exchange, tradingsymbol, product string) (kiteconnect.Position, error) {
+	var matches []kiteconnect.Position
+	for _, p := range positions.Net {
+		if p.Exchange != exchange || p.Tradingsymbol != tradingsymbol || p.Quantity == 0 {
+			continue
+		}
+		if product != "" && p.Product != product {
+			continue
+		}
+		matches = append(matches, p)
+	}
+
+	switch len(matches) {
+	case 0:
+		if product != "" {
+			return kiteconnect.Position{}, fmt.Errorf("no open %s position in %s:%s", product, exchange, tradingsymbol)
+		}
+		return kiteconnect.Position{}, fmt.Errorf("no open position in %s:%s", exchange, tradingsymbol)
+	case 1:
+		return matches[0], nil
+	}
+
+	products := make([]string, 0, len(matches))
+	for _, p := range matches {
+		products = append(products, p.Product)
+	}
+	return kiteconnect.Position{}, fmt.Errorf("%s:%s is open under multiple products (%s); specify product", exchange, tradingsymbol, strings.Join(products, ", "))
+}
+
+// exitQuantity resolves the quantity to close, defaulting to the whole position.
+func exitQuantity(position kiteconnect.Position, requested int) (int, error) {
+	open := position.Quantity
+	if open < 0 {
+		open = -open
+	}
+	if requested == 0 {
+		return open, nil
+	}
+	if requested < 0 {
+		return 0, fmt.Errorf("quantity must be greater than 0")
+	}
+	if requested > open {
+		return 0, fmt.Errorf("quantity %d exceeds open position of %d", requested, open)
+	}
+	return requested, nil
+}
+
+// validateConversion checks that a product conversion is supported for the exchange.
+func validateConversion(exchange, oldProduct, newProduct string) error {
+	if oldProduct == newProduct {
+		return fmt.Errorf("old_product and new_product must differ")
+	}
+
+	allowed := map[string]bool{kiteconnect.ProductMIS: true, kiteconnect.ProductNRML: true}
+	if exchange == "NSE" || exchange == "BSE" {
+		allowed = map[string]bool{kiteconnect.ProductMIS: true, kiteconnect.ProductCNC: true, kiteconnect.ProductMTF: true}
+	}
+	if !allowed[oldProduct] || !allowed[newProduct] {
+		return fmt.Errorf("cannot convert %s to %s on %s", oldProduct, newProduct, exchange)
+	}
+	return nil
+}
+
+type ConvertPositionTool struct{}
+
+func (*ConvertPositionTool) Tool() mcp.Tool {
+	return mcp.NewTool("convert_position",
+		mcp.WithDescription("Convert an open position between products, e.g. MIS to CNC for equity or MIS to NRML for F&O, so it is not squared off intraday. Converts the whole position unless a quantity is given."),
+		mcp.WithString("exchange",
+			mcp.Description("The exchange of the position"),
+			mcp.Required(),
+			mcp.Enum("NSE", "BSE", "MCX", "NFO", "BFO", "CDS", "BCD"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Trading symbol of the position"),
+			mcp.Required(),
+		),
+		mcp.WithString("old_product",
+			mcp.Description("Current product of the position"),
+			mcp.Required(),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
+		mcp.WithString("new_product",
+			mcp.Description("Product to convert to"),
+			mcp.Required(),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
+		mcp.WithNumber("quantity",
+			mcp.Description("Quantity to convert. Must be a multiple of the lot size. Defaults to the whole position"),
+			mcp.Min(1),
+		),
+		mcp.WithString("position_type",
+			mcp.Description("Position to convert: today's (day) or carried forward (overnight)"),
+			mcp.DefaultString("day"),
+			mcp.Enum("day", "overnight"),
+		),
+	)
+}
+
+func (*ConvertPositionTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "convert_position")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "exchange", "tradingsymbol", "old_product", "new_product"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		exchange := SafeAssertString(args["exchange"], "")
+		tradingsymbol := SafeAssertString(args["tradingsymbol"], "")
+		oldProduct := SafeAssertString(args["old_product"], "")
+		newProduct := SafeAssertString(args["new_product"], "")
+		positionType := SafeAssertString(args["position_type"], "day")
+
+		if err := validateConversion(exchange, oldProduct, newProduct); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return handler.WithSession(ctx, "convert_position", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			positions, err := session.Kite.Client.GetPositions()
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get positions", "error", err)
+				return mcp.NewToolResultError("Failed to get positions"), nil
+			}
+
+			position, err := findPosition(positions, exchange, tradingsymbol, oldProduct)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			qty, err := exitQuantity(position, SafeAssertInt(args["quantity"], 0))
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if err := orders.ValidateLots(qty, instrumentLimits(handler.manager, exchange, tradingsymbol)); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			// The transaction type is the side the position was built with.
+			side := kiteconnect.TransactionTypeBuy
+			if position.Quantity < 0 {
+				side = kiteconnect.TransactionTypeSell
+			}
+
+			if _, err := session.Kite.Client.ConvertPosition(kiteconnect.ConvertPositionParams{
+				Exchange:        exchange,
+				TradingSymbol:   tradingsymbol,
+				OldProduct:      oldProduct,
+				NewProduct:      newProduct,
+				PositionType:    positionType,
+				TransactionType: side,
+				Quantity:        qty,
+			}); err != nil {
+				handler.manager.Logger.Error("Failed to convert position", "error", err)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to convert position: %s", err.Error())), nil
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"exchange":         exchange,
+				"tradingsymbol":    tradingsymbol,
+				"old_product":      oldProduct,
+				"new_product":      newProduct,
+				"position_type":    positionType,
+				"transaction_type": side,
+				"quantity":         qty,
+				"converted":        true,
+			}, "convert_position")
+		})
+	}
+}
+
+type SquareOffPositionTool struct{}
+
+func (*SquareOffPositionTool) Tool() mcp.Tool {
+	return mcp.NewTool("square_off_position",
+		mcp.WithDescription("Close all or part of an open position with an opposite order in the same product. Quantities above the exchange freeze limit are split into multiple orders."),
+		mcp.WithString("exchange",
+			mcp.Description("The exchange of the position"),
+			mcp.Required(),
+			mcp.Enum("NSE", "BSE", "MCX", "NFO", "BFO", "CDS", "BCD"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Trading symbol of the position"),
+			mcp.Required(),
+		),
+		mcp.WithString("product",
+			mcp.Description("Product of the position. Required only when the instrument is open under several products"),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
+		mcp.WithNumber("quantity",
+			mcp.Description("Quantity to close. Must be a multiple of the lot size. Defaults to the whole position"),
+			mcp.Min(1),
+		),
+		mcp.WithString("order_type",
+			mcp.Description("Order type for the exit"),
+			mcp.DefaultString("MARKET"),
+			mcp.Enum("MARKET", "LIMIT"),
+		),
+		mcp.WithNumber("price",
+			mcp.Description("Limit price (required for LIMIT exits)"),
+		),
+		withNormalization(),
+	)
+}
+
+func (*SquareOffPositionTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "square_off_position")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "exchange", "tradingsymbol"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		exchange := SafeAssertString(args["exchange"], "")
+		tradingsymbol := SafeAssertString(args["tradingsymbol"], "")
+		product := SafeAssertString(args["product"], "")
+		orderType := SafeAssertString(args["order_type"], kiteconnect.OrderTypeMarket)
+		price := SafeAssertFloat64(args["price"], 0)
+
+		if orderType == kiteconnect.OrderTypeLimit && price <= 0 {
+			return mcp.NewToolResultError("price is required for LIMIT exits"), nil
+		}
+		normalizer, err := newOrderNormalizer(handler.manager, args, exchange, tradingsymbol, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return handler.WithSession(ctx, "square_off_position", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			positions, err := session.Kite.Client.GetPositions()
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get positions", "error", err)
+				return mcp.NewToolResultError("Failed to get positions"), nil
+			}
+
+			position, err := findPosition(positions, exchange, tradingsymbol, product)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			open, _ := exitQuantity(position, 0)
+			qty, err := exitQuantity(position, SafeAssertInt(args["quantity"], 0))
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			side := kiteconnect.TransactionTypeSell
+			if position.Quantity < 0 {
+				side = kiteconnect.TransactionTypeBuy
+			}
+
+			params := kiteconnect.OrderParams{
+				Exchange:        exchange,
+				Tradingsymbol:   tradingsymbol,
+				TransactionType: side,
+				Product:         position.Product,
+				OrderType:       orderType,
+				Quantity:        qty,
+				Validity:        kiteconnect.ValidityDay,
+				Tag:             "SQUARE_OFF",
+			}
+			if orderType == kiteconnect.OrderTypeLimit {
+				params.Price = price
+			}
+			if err := normalizeOrderParams(normalizer, &params); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			limits := instrumentLimits(handler.manager, exchange, tradingsymbol)
+			group, err := placeSlicedOrder(ctx, handler.manager, session.Kite.Client, kiteconnect.VarietyRegular, params, limits, orders.DefaultSliceInterval)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to square off position", "error", err, "placed", len(group.OrderIDs))
+				if len(group.OrderIDs) == 0 {
+					return mcp.NewToolResultError(fmt.Sprintf("Failed to square off position: %s", err.Error())), nil
+				}
+				return mcp.NewToolResultError(fmt.Sprintf("Square-off partially placed (orders %s): %s", strings.Join(group.OrderIDs, ", "), err.Error())), nil
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"exchange":         exchange,
+				"tradingsymbol":    tradingsymbol,
+				"product":          position.Product,
+				"transaction_type": side,
+				"quantity":         params.Quantity,
+				"remaining":        open - params.Quantity,
+				"group_id":         group.ID,
+				"order_ids":        group.OrderIDs,
+				"adjustments":      normalizer.Adjustments,
+			}, "square_off_position")
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+func TestFindPosition(t *testing.T) {
+	positions := kiteconnect.Positions{Net: []kiteconnect.Position{
+		{Exchange: "NFO", Tradingsymbol: "NIFTY25JANFUT", Product: "NRML", Quantity: 150},
+		{Exchange: "NFO", Tradingsymbol: "NIFTY25JANFUT", Product: "MIS", Quantity: -75},
+		{Exchange: "NSE", Tradingsymbol: "INFY", Product: "MIS", Quantity: 10},
+		{Exchange: "NSE", Tradingsymbol: "TCS", Product: "MIS", Quantity: 0},
+	}}
+
+	p, err := findPosition(positions, "NSE", "INFY", "")
+	require.NoError(t, err)
+	assert.Equal(t, "MIS", p.Product)
+
+	_, err = findPosition(positions, "NFO", "NIFTY25JANFUT", "")
+	assert.ErrorContains(t, err, "multiple products")
+
+	p, err = findPosition(positions, "NFO", "NIFTY25JANFUT", "MIS")
+	require.NoError(t, err)
+	assert.Equal(t, -75, p.Quantity)
+
+	_, err = findPosition(positions, "NSE", "TCS", "")
+	assert.Error(t, err, "closed positions should not match")
+}
+
+func TestExitQuantity(t *testing.T) {
+	short := kiteconnect.Position{Quantity: -150}
+
+	qty, err := exitQuantity(short, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 150, qty)
+
+	qty, err = exitQuantity(short, 75)
+	require.NoError(t, err)
+	assert.Equal(t, 75, qty)
+
+	_, err = exitQuantity(short, 225)
+	assert.Error(t, err)
+}
+
+func TestValidateConversion(t *testing.T) {
+	assert.NoError(t, validateConversion("NSE", "MIS", "CNC"))
+	assert.NoError(t, validateConversion("NFO", "MIS", "NRML"))
+	assert.Error(t, validateConversion("NFO", "MIS", "CNC"))
+	assert.Error(t, validateConversion("NSE", "MIS", "NRML"))
+	assert.Error(t, validateConversion("NSE", "CNC", "CNC"))
+}
@@ -2,11 +2,13 @@ package mcp
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
 )
 
 type PlaceOrderTool struct{}
@@ -77,6 +79,15 @@ func (*PlaceOrderTool) Tool() mcp.Tool {
 			mcp.Description("An optional tag to apply to an order to identify it (alphanumeric, max 20 chars)"),
 			mcp.MaxLength(20),
 		),
+		mcp.WithBoolean("slice_orders",
+			mcp.Description("Split a quantity above the exchange freeze limit into multiple child orders instead of rejecting it. The response lists every child order and its fill status"),
+		),
+		mcp.WithNumber("slice_interval_ms",
+			mcp.Description("Delay between child orders when slicing, in milliseconds. Default: 200"),
+			mcp.Min(0),
+			mcp.Max(maxSliceIntervalMs),
+		),
+		withNormalization(),
 	)
 }
 
@@ -109,6 +120,53 @@ func (*PlaceOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 			Tag:               SafeAssertString(args["tag"], ""),
 		}
 
+		warning := orderWarning(handler.manager.Calendar, variety, orderParams.Exchange)
+
+		normalizer, err := newOrderNormalizer(handler.manager, args, orderParams.Exchange, orderParams.Tradingsymbol, true)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if err := normalizeOrderParams(normalizer, &orderParams); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		sliceOrders := SafeAssertBool(args["slice_orders"], false)
+		interval, err := sliceInterval(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Iceberg orders are split into legs by the exchange itself.
+		limits := instrumentLimits(handler.manager, orderParams.Exchange, orderParams.Tradingsymbol)
+		if limits.Unknown && variety != kiteconnect.VarietyIceberg {
+			if sliceOrders {
+				return mcp.NewToolResultError(fmt.Sprintf("The freeze quantity of %s is unknown, as the instruments were loaded from a CSV dump without it, so the order can't be sliced; place it without slice_orders", orderParams.Tradingsymbol)), nil
+			}
+			warning = joinWarnings(warning, unknownLimitsWarning(orderParams.Tradingsymbol))
+		}
+		if maxQty := orders.MaxSliceQuantity(limits); variety != kiteconnect.VarietyIceberg && maxQty > 0 && orderParams.Quantity > maxQty {
+			if !sliceOrders {
+				return mcp.NewToolResultError(fmt.Sprintf("Quantity %d exceeds the freeze limit of %s (at most %d per order); set slice_orders to split it into multiple orders", orderParams.Quantity, orderParams.Tradingsymbol, maxQty)), nil
+			}
+
+			return handler.WithSession(ctx, "place_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+				group, err := placeSlicedOrder(ctx, handler.manager, session.Kite.Client, variety, orderParams, limits, interval)
+				if err != nil && len(group.OrderIDs) == 0 {
+					handler.manager.Logger.Error("Failed to place sliced order", "error", err)
+					return mcp.NewToolResultError(fmt.Sprintf("Failed to place order: %s", err.Error())), nil
+				}
+
+				status := orderGroupStatus(session.Kite.Client, group)
+				status.Adjustments = normalizer.Adjustments
+				status.Warning = warning
+				if err != nil {
+					handler.manager.Logger.Error("Sliced order partially placed", "error", err, "placed", len(group.OrderIDs))
+					status.Error = fmt.Sprintf("only %d of the child orders were placed: %s", len(group.OrderIDs), err.Error())
+				}
+				return handler.MarshalResponse(status, "place_order")
+			})
+		}
+
 		return handler.WithSession(ctx, "place_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
 			resp, err := session.Kite.Client.PlaceOrder(variety, orderParams)
 			if err != nil {
@@ -116,7 +174,7 @@ func (*PlaceOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 				return mcp.NewToolResultError("Failed to place order"), nil
 			}
 
-			return handler.MarshalResponse(resp, "place_order")
+			return handler.MarshalResponse(NormalizedOrderResponse{OrderResponse: resp, Adjustments: normalizer.Adjustments, Warning: warning}, "place_order")
 		})
 	}
 }
@@ -159,6 +217,10 @@ func (*ModifyOrderTool) Tool() mcp.Tool {
 		mcp.WithNumber("disclosed_quantity",
 			mcp.Description("Quantity to disclose publicly (for equity trades)"),
 		),
+		mcp.WithBoolean("apply_to_group",
+			mcp.Description("Apply the modification to every open child of the sliced order group that order_id belongs to. Quantity cannot be changed for a group"),
+		),
+		withNormalization(),
 	)
 }
 
@@ -176,8 +238,9 @@ func (*ModifyOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 		variety := SafeAssertString(args["variety"], "regular")
 		orderID := SafeAssertString(args["order_id"], "")
 
+		// An omitted quantity is left out of the request so the order keeps its own.
 		orderParams := kiteconnect.OrderParams{
-			Quantity:          SafeAssertInt(args["quantity"], 1),
+			Quantity:          SafeAssertInt(args["quantity"], 0),
 			Price:             SafeAssertFloat64(args["price"], 0.0),
 			OrderType:         SafeAssertString(args["order_type"], ""),
 			TriggerPrice:      SafeAssertFloat64(args["trigger_price"], 0.0),
@@ -185,14 +248,66 @@ func (*ModifyOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 			DisclosedQuantity: SafeAssertInt(args["disclosed_quantity"], 0),
 		}
 
+		if SafeAssertBool(args["apply_to_group"], false) {
+			group, ok := handler.manager.OrderGroups.Lookup(orderID)
+			if !ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Order %s is not part of a sliced order group", orderID)), nil
+			}
+			if args["quantity"] != nil {
+				return mcp.NewToolResultError("quantity cannot be modified for a sliced order group"), nil
+			}
+
+			normalizer, err := newOrderNormalizer(handler.manager, args, group.Exchange, group.Tradingsymbol, true)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if err := normalizeModifyParams(normalizer, group.TransactionType, &orderParams); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
+			return handler.WithSession(ctx, "modify_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+				result := applyToOrderGroup(session.Kite.Client, group, func(child ChildOrderStatus) error {
+					params := orderParams
+					params.Quantity = int(child.Quantity)
+					_, err := session.Kite.Client.ModifyOrder(group.Variety, child.OrderID, params)
+					return err
+				})
+				if len(result.Failed) > 0 {
+					handler.manager.Logger.Error("Failed to modify some orders in group", "group_id", group.ID, "failed", len(result.Failed))
+				}
+				result.Adjustments = normalizer.Adjustments
+				return handler.MarshalResponse(result, "modify_order")
+			})
+		}
+
+		if _, err := orders.ParseMode(SafeAssertString(args["normalization"], "")); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
 		return handler.WithSession(ctx, "modify_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			// The order's instrument is needed to normalize prices and quantity.
+			var adjustments []orders.Adjustment
+			if history, err := session.Kite.Client.GetOrderHistory(orderID); err == nil && len(history) > 0 {
+				current := history[len(history)-1]
+				normalizer, err := newOrderNormalizer(handler.manager, args, current.Exchange, current.TradingSymbol, true)
+				if err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				if err := normalizeModifyParams(normalizer, current.TransactionType, &orderParams); err != nil {
+					return mcp.NewToolResultError(err.Error()), nil
+				}
+				adjustments = normalizer.Adjustments
+			} else {
+				handler.manager.Logger.Warn("Could not fetch order to normalize modification", "order_id", orderID, "error", err)
+			}
+
 			resp, err := session.Kite.Client.ModifyOrder(variety, orderID, orderParams)
 			if err != nil {
 				handler.manager.Logger.Error("Failed to modify order", "error", err)
 				return mcp.NewToolResultError("Failed to modify order"), nil
 			}
 
-			return handler.MarshalResponse(resp, "modify_order")
+			return handler.MarshalResponse(NormalizedOrderResponse{OrderResponse: resp, Adjustments: adjustments}, "modify_order")
 		})
 	}
 }
@@ -212,6 +327,9 @@ func (*CancelOrderTool) Tool() mcp.Tool {
 			mcp.Description("Order ID"),
 			mcp.Required(),
 		),
+		mcp.WithBoolean("apply_to_group",
+			mcp.Description("Cancel every open child of the sliced order group that order_id belongs to"),
+		),
 	)
 }
 
@@ -229,6 +347,24 @@ func (*CancelOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 		variety := SafeAssertString(args["variety"], "regular")
 		orderID := SafeAssertString(args["order_id"], "")
 
+		if SafeAssertBool(args["apply_to_group"], false) {
+			group, ok := handler.manager.OrderGroups.Lookup(orderID)
+			if !ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Order %s is not part of a sliced order group", orderID)), nil
+			}
+
+			return handler.WithSession(ctx, "cancel_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+				result := applyToOrderGroup(session.Kite.Client, group, func(child ChildOrderStatus) error {
+					_, err := session.Kite.Client.CancelOrder(group.Variety, child.OrderID, nil)
+					return err
+				})
+				if len(result.Failed) > 0 {
+					handler.manager.Logger.Error("Failed to cancel some orders in group", "group_id", group.ID, "failed", len(result.Failed))
+				}
+				return handler.MarshalResponse(result, "cancel_order")
+			})
+		}
+
 		return handler.WithSession(ctx, "cancel_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
 			resp, err := session.Kite.Client.CancelOrder(variety, orderID, nil)
 			if err != nil {
@@ -245,7 +381,7 @@ type PlaceGTTOrderTool struct{}
 
 func (*PlaceGTTOrderTool) Tool() mcp.Tool {
 	return mcp.NewTool("place_gtt_order",
-		mcp.WithDescription("Place a GTT (Good Till Triggered) order"),
+		mcp.WithDescription("Place a GTT (Good Till Triggered) order. Triggers must be at least 0.25% away from the last price, and a two-leg GTT needs its upper trigger above the last price and its lower trigger below it."),
 		mcp.WithString("exchange",
 			mcp.Description("The exchange to which the order should be placed"),
 			mcp.Required(),
@@ -304,6 +440,7 @@ func (*PlaceGTTOrderTool) Tool() mcp.Tool {
 		mcp.WithNumber("lower_limit_price",
 			mcp.Description("Limit price for the lower trigger order (for two-leg)"),
 		),
+		withNormalization(),
 	)
 }
 
@@ -328,32 +465,15 @@ func (*PlaceGTTOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 		}
 
 		// Set up trigger based on trigger_type
-		triggerType := SafeAssertString(args["trigger_type"], "")
-
-		switch triggerType {
-		case "single":
-			gttParams.Trigger = &kiteconnect.GTTSingleLegTrigger{
-				TriggerParams: kiteconnect.TriggerParams{
-					TriggerValue: SafeAssertFloat64(args["trigger_value"], 0.0),
-					Quantity:     SafeAssertFloat64(args["quantity"], 0.0),
-					LimitPrice:   SafeAssertFloat64(args["limit_price"], 0.0),
-				},
-			}
-		case "two-leg":
-			gttParams.Trigger = &kiteconnect.GTTOneCancelsOtherTrigger{
-				Upper: kiteconnect.TriggerParams{
-					TriggerValue: SafeAssertFloat64(args["upper_trigger_value"], 0.0),
-					Quantity:     SafeAssertFloat64(args["upper_quantity"], 0.0),
-					LimitPrice:   SafeAssertFloat64(args["upper_limit_price"], 0.0),
-				},
-				Lower: kiteconnect.TriggerParams{
-					TriggerValue: SafeAssertFloat64(args["lower_trigger_value"], 0.0),
-					Quantity:     SafeAssertFloat64(args["lower_quantity"], 0.0),
-					LimitPrice:   SafeAssertFloat64(args["lower_limit_price"], 0.0),
-				},
-			}
-		default:
-			return mcp.NewToolResultError("Invalid trigger_type. Must be 'single' or 'two-leg'"), nil
+		trigger, err := gttTrigger(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		gttParams.Trigger = trigger
+
+		normalizer, err := prepareGTTParams(handler.manager, args, &gttParams)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
 		return handler.WithSession(ctx, "place_gtt_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
@@ -363,7 +483,7 @@ func (*PlaceGTTOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 				return mcp.NewToolResultError("Failed to place GTT order"), nil
 			}
 
-			return handler.MarshalResponse(resp, "place_gtt_order")
+			return handler.MarshalResponse(NormalizedGTTResponse{GTTResponse: resp, Adjustments: normalizer.Adjustments}, "place_gtt_order")
 		})
 	}
 }
@@ -410,7 +530,7 @@ type ModifyGTTOrderTool struct{}
 
 func (*ModifyGTTOrderTool) Tool() mcp.Tool {
 	return mcp.NewTool("modify_gtt_order",
-		mcp.WithDescription("Modify an existing GTT (Good Till Triggered) order"),
+		mcp.WithDescription("Modify an existing GTT (Good Till Triggered) order. Values left out keep the GTT's current ones, so one leg of a two-leg GTT can be moved on its own. Triggers must be at least 0.25% away from the last price, and a two-leg GTT needs its upper trigger above the last price and its lower trigger below it."),
 		mcp.WithNumber("trigger_id",
 			mcp.Description("The ID of the GTT order to modify"),
 			mcp.Required(),
@@ -434,6 +554,10 @@ func (*ModifyGTTOrderTool) Tool() mcp.Tool {
 			mcp.Required(),
 			mcp.Enum("BUY", "SELL"),
 		),
+		mcp.WithString("product",
+			mcp.Description("Product type. Defaults to the GTT's current product"),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
 		mcp.WithString("trigger_type",
 			mcp.Description("GTT trigger type"),
 			mcp.Required(),
@@ -441,33 +565,34 @@ func (*ModifyGTTOrderTool) Tool() mcp.Tool {
 		),
 		// For single leg trigger
 		mcp.WithNumber("trigger_value",
-			mcp.Description("Price point at which the GTT will be triggered (for single-leg)"),
+			mcp.Description("Price point at which the GTT will be triggered (for single-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("quantity",
-			mcp.Description("Quantity for the order (for single-leg)"),
+			mcp.Description("Quantity for the order (for single-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("limit_price",
-			mcp.Description("Limit price for the order (for single-leg)"),
+			mcp.Description("Limit price for the order (for single-leg). Omit to keep the current value"),
 		),
 		// For two-leg trigger
 		mcp.WithNumber("upper_trigger_value",
-			mcp.Description("Upper price point at which the GTT will be triggered (for two-leg)"),
+			mcp.Description("Upper price point at which the GTT will be triggered (for two-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("upper_quantity",
-			mcp.Description("Quantity for the upper trigger order (for two-leg)"),
+			mcp.Description("Quantity for the upper trigger order (for two-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("upper_limit_price",
-			mcp.Description("Limit price for the upper trigger order (for two-leg)"),
+			mcp.Description("Limit price for the upper trigger order (for two-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("lower_trigger_value",
-			mcp.Description("Lower price point at which the GTT will be triggered (for two-leg)"),
+			mcp.Description("Lower price point at which the GTT will be triggered (for two-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("lower_quantity",
-			mcp.Description("Quantity for the lower trigger order (for two-leg)"),
+			mcp.Description("Quantity for the lower trigger order (for two-leg). Omit to keep the current value"),
 		),
 		mcp.WithNumber("lower_limit_price",
-			mcp.Description("Limit price for the lower trigger order (for two-leg)"),
+			mcp.Description("Limit price for the lower trigger order (for two-leg). Omit to keep the current value"),
 		),
+		withNormalization(),
 	)
 }
 
@@ -491,45 +616,36 @@ func (*ModifyGTTOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
 			Tradingsymbol:   SafeAssertString(args["tradingsymbol"], ""),
 			LastPrice:       SafeAssertFloat64(args["last_price"], 0.0),
 			TransactionType: SafeAssertString(args["transaction_type"], ""),
+			Product:         SafeAssertString(args["product"], ""),
 		}
 
 		// Set up trigger based on trigger_type
-		triggerType := SafeAssertString(args["trigger_type"], "")
-
-		switch triggerType {
-		case "single":
-			gttParams.Trigger = &kiteconnect.GTTSingleLegTrigger{
-				TriggerParams: kiteconnect.TriggerParams{
-					TriggerValue: SafeAssertFloat64(args["trigger_value"], 0.0),
-					Quantity:     SafeAssertFloat64(args["quantity"], 0.0),
-					LimitPrice:   SafeAssertFloat64(args["limit_price"], 0.0),
-				},
-			}
-		case "two-leg":
-			gttParams.Trigger = &kiteconnect.GTTOneCancelsOtherTrigger{
-				Upper: kiteconnect.TriggerParams{
-					TriggerValue: SafeAssertFloat64(args["upper_trigger_value"], 0.0),
-					Quantity:     SafeAssertFloat64(args["upper_quantity"], 0.0),
-					LimitPrice:   SafeAssertFloat64(args["upper_limit_price"], 0.0),
-				},
-				Lower: kiteconnect.TriggerParams{
-					TriggerValue: SafeAssertFloat64(args["lower_trigger_value"], 0.0),
-					Quantity:     SafeAssertFloat64(args["lower_quantity"], 0.0),
-					LimitPrice:   SafeAssertFloat64(args["lower_limit_price"], 0.0),
-				},
-			}
-		default:
-			return mcp.NewToolResultError("Invalid trigger_type. Must be 'single' or 'two-leg'"), nil
+		trigger, err := gttTrigger(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
+		gttParams.Trigger = trigger
 
 		return handler.WithSession(ctx, "modify_gtt_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			existing, err := session.Kite.Client.GetGTT(triggerID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get GTT order", "trigger_id", triggerID, "error", err)
+				return mcp.NewToolResultError("Failed to get GTT order"), nil
+			}
+			mergeGTTTrigger(existing, &gttParams)
+
+			normalizer, err := prepareGTTParams(handler.manager, args, &gttParams)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
 			resp, err := session.Kite.Client.ModifyGTT(triggerID, gttParams)
 			if err != nil {
 				handler.manager.Logger.Error("Failed to modify GTT order", "error", err)
 				return mcp.NewToolResultError("Failed to modify GTT order"), nil
 			}
 
-			return handler.MarshalResponse(resp, "modify_gtt_order")
+			return handler.MarshalResponse(NormalizedGTTResponse{GTTResponse: resp, Adjustments: normalizer.Adjustments}, "modify_gtt_order")
 		})
 	}
 }
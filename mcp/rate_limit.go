@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RateLimiter limits the tool calls of each MCP session per minute. Its
+// limits can be changed while the server runs; calls already counted in
+// the current minute count against the new limits.
+type RateLimiter struct {
+	mu      sync.Mutex
+	limit   int                    // calls per minute of all tools, 0 is unlimited
+	perTool map[string]int         // calls per minute of a tool
+	windows map[string]*rateWindow // by session, and by session and tool
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// rateWindow counts the calls made in the minute since start.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter returns a rate limiter without limits.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		windows: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// SetLimits sets the calls per minute a session can make of all tools and
+// of each tool in perTool. Zero is unlimited.
+func (l *RateLimiter) SetLimits(perMinute int, perTool map[string]int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = perMinute
+	l.perTool = perTool
+}
+
+// allow counts a call of a tool by a session, unless it's over a limit.
+func (l *RateLimiter) allow(sessionID, toolName string) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.pruned) >= time.Minute {
+		for key, window := range l.windows {
+			if now.Sub(window.start) >= time.Minute {
+				delete(l.windows, key)
+			}
+		}
+		l.pruned = now
+	}
+
+	type check struct {
+		key   string
+		limit int
+		what  string
+	}
+	checks := []check{
+		{sessionID, l.limit, "tool calls"},
+		{sessionID + "\x00" + toolName, l.perTool[toolName], toolName + " calls"},
+	}
+	// Only count a call against the limits once it's within all of them
+	windows := make([]*rateWindow, 0, len(checks))
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		window, ok := l.windows[c.key]
+		if !ok || now.Sub(window.start) >= time.Minute {
+			window = &rateWindow{start: now}
+			l.windows[c.key] = window
+		}
+		if window.count >= c.limit {
+			return false, fmt.Sprintf("Rate limit of %d %s per minute exceeded. Please try again in %s.",
+				c.limit, c.what, window.start.Add(time.Minute).Sub(now).Round(time.Second))
+		}
+		windows = append(windows, window)
+	}
+	for _, window := range windows {
+		window.count++
+	}
+	return true, ""
+}
+
+// Middleware returns the tool handler middleware enforcing the limits.
+func (l *RateLimiter) Middleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			sessionID := ""
+			if sess := server.ClientSessionFromContext(ctx); sess != nil {
+				sessionID = sess.SessionID()
+			}
+			if ok, message := l.allow(sessionID, request.Params.Name); !ok {
+				return mcp.NewToolResultError(message), nil
+			}
+			return next(ctx, request)
+		}
+	}
+}
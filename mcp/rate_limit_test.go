@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter()
+	limiter.now = func() time.Time { return now }
+
+	calls := func(session, tool string, n int) int {
+		allowed := 0
+		for i := 0; i < n; i++ {
+			if ok, _ := limiter.allow(session, tool); ok {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	assert.Equal(t, 100, calls("a", "get_quotes", 100), "no limits by default")
+
+	limiter.SetLimits(5, map[string]int{"place_order": 2})
+	assert.Equal(t, 2, calls("b", "place_order", 3), "place_order is limited to 2 calls")
+	assert.Equal(t, 3, calls("b", "get_quotes", 5), "the calls refused don't count")
+	assert.Equal(t, 5, calls("c", "get_quotes", 6), "each session has its own limit")
+
+	ok, message := limiter.allow("b", "get_quotes")
+	assert.False(t, ok)
+	assert.Contains(t, message, "Rate limit of 5 tool calls per minute exceeded")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, 2, calls("b", "place_order", 3), "the limits reset every minute")
+	assert.Len(t, limiter.windows, 2, "the windows of the past minute are pruned")
+
+	limiter.SetLimits(0, nil)
+	assert.Equal(t, 10, calls("b", "place_order", 10), "the limits can be lifted")
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	limiter := NewRateLimiter()
+	limiter.SetLimits(1, nil)
+	handler := limiter.Middleware()(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "get_quotes"
+	result, err := handler(context.Background(), request)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	result, err = handler(context.Background(), request)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+}
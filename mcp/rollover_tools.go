@@ -0,0 +1,336 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/gokiteconnect/v4/models"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+)
+
+// Targets of a rollover.
+const (
+	rollToSame    = "same"    // the next expiry of the same kind
+	rollToNext    = "next"    // the next expiry of any kind
+	rollToMonthly = "monthly" // the next monthly expiry
+)
+
+// Prices the legs of a rollover are quoted at.
+const (
+	priceBasisDepth     = "depth"      // best bid and ask
+	priceBasisLastPrice = "last_price" // no market depth, last traded prices
+)
+
+// derivativeExchanges are the exchanges of contracts that expire.
+var derivativeExchanges = map[string]bool{"NFO": true, "BFO": true, "MCX": true, "CDS": true, "BCD": true}
+
+// RolloverLeg is an order of a rollover, priced at the touch.
+type RolloverLeg struct {
+	Exchange        string  `json:"exchange"`
+	Tradingsymbol   string  `json:"tradingsymbol"`
+	TransactionType string  `json:"transaction_type"`
+	Quantity        int     `json:"quantity"`
+	Product         string  `json:"product"`
+	OrderType       string  `json:"order_type"`
+	Price           float64 `json:"price,omitempty"`
+}
+
+// Rollover is an expiring position and the legs that roll it to a later
+// expiry: closing it and opening the same contract in the later expiry.
+type Rollover struct {
+	Exchange      string `json:"exchange"`
+	Tradingsymbol string `json:"tradingsymbol"`
+	Product       string `json:"product"`
+	Quantity      int    `json:"quantity"`
+	Expiry        string `json:"expiry"`
+	ExpiryKind    string `json:"expiry_kind,omitempty"`
+	DaysToExpiry  int    `json:"days_to_expiry"`
+
+	NextTradingsymbol string        `json:"next_tradingsymbol,omitempty"`
+	NextExpiry        string        `json:"next_expiry,omitempty"`
+	NextExpiryKind    string        `json:"next_expiry_kind,omitempty"`
+	Legs              []RolloverLeg `json:"legs,omitempty"`
+
+	// Spread is the last price of the next contract less the expiring
+	// one. RollCost is what crossing the spread on both legs costs, each
+	// at its own quantity, a credit when negative.
+	Spread          float64 `json:"spread"`
+	RollCostPerUnit float64 `json:"roll_cost_per_unit"`
+	RollCost        float64 `json:"roll_cost"`
+	PriceBasis      string  `json:"price_basis,omitempty"`
+
+	Note string `json:"note,omitempty"`
+}
+
+// RolloverResponse lists the rollovers of the expiring positions.
+type RolloverResponse struct {
+	AsOf          time.Time  `json:"as_of"`
+	WithinDays    int        `json:"within_days"`
+	RollTo        string     `json:"roll_to"`
+	Rollovers     []Rollover `json:"rollovers"`
+	TotalRollCost float64    `json:"total_roll_cost"`
+}
+
+// findRollovers returns the rollovers of the derivatives positions
+// expiring within withinDays days of now, with the contracts to roll to
+// and unpriced legs.
+func findRollovers(im *instruments.Manager, positions []kiteconnect.Position, now time.Time, withinDays int, rollTo string) []Rollover {
+	today := now.In(calendar.IST)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, calendar.IST)
+	last := today.AddDate(0, 0, withinDays)
+
+	rollovers := []Rollover{}
+	for _, p := range positions {
+		if p.Quantity == 0 || !derivativeExchanges[p.Exchange] {
+			continue
+		}
+		inst, err := im.GetByTradingsymbol(p.Exchange, p.Tradingsymbol)
+		if err != nil {
+			continue
+		}
+		expiry, ok := inst.Expiry()
+		if !ok || expiry.Before(today) || expiry.After(last) {
+			continue
+		}
+
+		r := Rollover{
+			Exchange:      p.Exchange,
+			Tradingsymbol: p.Tradingsymbol,
+			Product:       p.Product,
+			Quantity:      p.Quantity,
+			Expiry:        expiry.Format(time.DateOnly),
+			DaysToExpiry:  int(expiry.Sub(today).Hours() / 24),
+		}
+		rollovers = append(rollovers, r)
+		rp := &rollovers[len(rollovers)-1]
+
+		schedule, err := im.ExpirySchedule(inst.Exchange, inst.Name)
+		if err != nil {
+			rp.Note = fmt.Sprintf("no expiry calendar for %s", inst.Name)
+			continue
+		}
+		rp.ExpiryKind = schedule.Kind(expiry)
+
+		kind := ""
+		switch rollTo {
+		case rollToSame:
+			// Weekly contracts roll to the next week, which may be a monthly
+			if rp.ExpiryKind == instruments.ExpiryMonthly {
+				kind = instruments.ExpiryMonthly
+			}
+		case rollToMonthly:
+			kind = instruments.ExpiryMonthly
+		}
+		// Skip the days only the other of futures and options expire
+		futures := inst.InstrumentType == "FUT"
+		next, ok := schedule.Next(expiry, kind)
+		for ok && (futures && !next.Futures || !futures && !next.Options) {
+			next, ok = schedule.Next(next.Date, kind)
+		}
+		if !ok {
+			rp.Note = fmt.Sprintf("no later %s expiry of %s", kindOrAny(kind), inst.Name)
+			continue
+		}
+
+		opts := instruments.SearchOptions{
+			Name:           inst.Name,
+			Exchange:       inst.Exchange,
+			InstrumentType: inst.InstrumentType,
+			ExpiryFrom:     next.Date,
+			ExpiryTo:       next.Date,
+			StrikeMin:      inst.Strike,
+			StrikeMax:      inst.Strike,
+			Limit:          1,
+		}
+		contracts := im.Search(opts)
+		if len(contracts) == 0 {
+			rp.Note = fmt.Sprintf("no %s %s contract expiring %s", inst.Name, inst.InstrumentType, next.Date.Format(time.DateOnly))
+			if inst.Strike > 0 {
+				rp.Note = fmt.Sprintf("no %s %v %s contract expiring %s", inst.Name, inst.Strike, inst.InstrumentType, next.Date.Format(time.DateOnly))
+			}
+			continue
+		}
+		target := contracts[0].Instrument
+		rp.NextTradingsymbol = target.Tradingsymbol
+		rp.NextExpiry = next.Date.Format(time.DateOnly)
+		rp.NextExpiryKind = next.Kind
+
+		qty := p.Quantity
+		if qty < 0 {
+			qty = -qty
+		}
+		nextQty := qty
+		if target.LotSize > 0 && nextQty%target.LotSize != 0 {
+			nextQty -= nextQty % target.LotSize
+			rp.Note = fmt.Sprintf("lot size changes to %d, %d of %d rolled", target.LotSize, nextQty, qty)
+		}
+
+		closeSide, openSide := kiteconnect.TransactionTypeSell, kiteconnect.TransactionTypeBuy
+		if p.Quantity < 0 {
+			closeSide, openSide = openSide, closeSide
+		}
+		rp.Legs = []RolloverLeg{
+			{Exchange: p.Exchange, Tradingsymbol: p.Tradingsymbol, TransactionType: closeSide, Quantity: qty, Product: p.Product, OrderType: kiteconnect.OrderTypeMarket},
+		}
+		if nextQty == 0 {
+			rp.Note = fmt.Sprintf("lot size changes to %d, more than the %d held, so nothing is opened in %s", target.LotSize, qty, target.Tradingsymbol)
+			continue
+		}
+		rp.Legs = append(rp.Legs, RolloverLeg{Exchange: target.Exchange, Tradingsymbol: target.Tradingsymbol, TransactionType: openSide, Quantity: nextQty, Product: p.Product, OrderType: kiteconnect.OrderTypeMarket})
+	}
+	return rollovers
+}
+
+func kindOrAny(kind string) string {
+	if kind == "" {
+		return "any"
+	}
+	return kind
+}
+
+// touch returns the best bid and ask of a quote, the last price for a
+// side without depth.
+func touch(lastPrice float64, depth models.Depth) (bid, ask float64, ok bool) {
+	bid, ask = depth.Buy[0].Price, depth.Sell[0].Price
+	ok = bid > 0 && ask > 0
+	if bid <= 0 {
+		bid = lastPrice
+	}
+	if ask <= 0 {
+		ask = lastPrice
+	}
+	return bid, ask, ok
+}
+
+// priceRollovers prices the legs of the rollovers with live quotes: each
+// leg crosses the spread, selling at the bid and buying at the ask.
+// Rollovers without an opening leg aren't priced.
+func priceRollovers(rollovers []Rollover, quotes kiteconnect.Quote) float64 {
+	total := 0.0
+	for i := range rollovers {
+		r := &rollovers[i]
+		if len(r.Legs) != 2 {
+			continue
+		}
+		closing, opening := &r.Legs[0], &r.Legs[1]
+		current, ok1 := quotes[closing.Exchange+":"+closing.Tradingsymbol]
+		next, ok2 := quotes[opening.Exchange+":"+opening.Tradingsymbol]
+		if !ok1 || !ok2 {
+			r.Note = "no live quote to price the rollover"
+			continue
+		}
+
+		curBid, curAsk, curDepth := touch(current.LastPrice, current.Depth)
+		nextBid, nextAsk, nextDepth := touch(next.LastPrice, next.Depth)
+		r.PriceBasis = priceBasisLastPrice
+		if curDepth && nextDepth {
+			r.PriceBasis = priceBasisDepth
+		}
+		r.Spread = next.LastPrice - current.LastPrice
+
+		if closing.TransactionType == kiteconnect.TransactionTypeSell {
+			closing.Price, opening.Price = curBid, nextAsk
+			r.RollCostPerUnit = nextAsk - curBid
+		} else {
+			closing.Price, opening.Price = curAsk, nextBid
+			r.RollCostPerUnit = curAsk - nextBid
+		}
+		// The legs differ in quantity when the lot size changes
+		r.RollCost = opening.Price*float64(opening.Quantity) - closing.Price*float64(closing.Quantity)
+		if closing.TransactionType != kiteconnect.TransactionTypeSell {
+			r.RollCost = -r.RollCost
+		}
+		total += r.RollCost
+	}
+	return total
+}
+
+type RolloverPositionsTool struct{}
+
+func (*RolloverPositionsTool) Tool() mcp.Tool {
+	return mcp.NewTool("rollover_positions",
+		mcp.WithDescription("List the futures and options positions expiring soon and build the legs to roll each to a later expiry: closing it and opening the same contract and strike in the later expiry. The calendar spread cost of each rollover is quoted live, crossing the spread on both legs. Expiries are classed weekly or monthly from the loaded contracts. Nothing is placed; place the legs with place_order."),
+		mcp.WithNumber("within_days",
+			mcp.Description("Roll positions expiring within this many days. Default: 3, 0 for expiring today"),
+			mcp.Min(0),
+		),
+		mcp.WithString("roll_to",
+			mcp.Description("Expiry to roll to: same for the next monthly for monthly contracts and the next expiry for weekly ones, next for the next expiry of any kind, monthly for the next monthly. Default: same"),
+			mcp.Enum(rollToSame, rollToNext, rollToMonthly),
+		),
+		mcp.WithString("exchange",
+			mcp.Description("Only roll positions of this exchange (Optional)"),
+			mcp.Enum("NFO", "BFO", "MCX", "CDS", "BCD"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Only roll the position in this contract (Optional)"),
+		),
+	)
+}
+
+func (*RolloverPositionsTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "rollover_positions")
+		args := request.GetArguments()
+
+		withinDays := SafeAssertInt(args["within_days"], 3)
+		if withinDays < 0 {
+			return mcp.NewToolResultError("within_days must not be negative"), nil
+		}
+		rollTo := SafeAssertString(args["roll_to"], rollToSame)
+		switch rollTo {
+		case rollToSame, rollToNext, rollToMonthly:
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("roll_to must be %s, %s or %s", rollToSame, rollToNext, rollToMonthly)), nil
+		}
+		exchange := SafeAssertString(args["exchange"], "")
+		tradingsymbol := SafeAssertString(args["tradingsymbol"], "")
+
+		return handler.WithSession(ctx, "rollover_positions", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			positions, err := session.Kite.Client.GetPositions()
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get positions", "error", err)
+				return mcp.NewToolResultError("Failed to get positions"), nil
+			}
+
+			var net []kiteconnect.Position
+			for _, p := range positions.Net {
+				if (exchange == "" || p.Exchange == exchange) && (tradingsymbol == "" || p.Tradingsymbol == tradingsymbol) {
+					net = append(net, p)
+				}
+			}
+
+			now := time.Now()
+			resp := RolloverResponse{
+				AsOf:       now.In(calendar.IST),
+				WithinDays: withinDays,
+				RollTo:     rollTo,
+				Rollovers:  findRollovers(handler.manager.Instruments, net, now, withinDays, rollTo),
+			}
+
+			var ids []string
+			for _, r := range resp.Rollovers {
+				for _, leg := range r.Legs {
+					ids = append(ids, leg.Exchange+":"+leg.Tradingsymbol)
+				}
+			}
+			if len(ids) > 0 {
+				quotes, err := session.Kite.Client.GetQuote(ids...)
+				if err != nil {
+					handler.manager.Logger.Error("Failed to get quotes", "error", err)
+					return mcp.NewToolResultError("Failed to get quotes to price the rollovers"), nil
+				}
+				resp.TotalRollCost = priceRollovers(resp.Rollovers, quotes)
+			}
+
+			return handler.MarshalResponse(resp, "rollover_positions")
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/kitefake"
+)
+
+// newRolloverServer holds a long weekly NIFTY call expiring tomorrow and a
+// short month's future expiring in two days, whose next month's contract
+// trades in lots of 50.
+func newRolloverServer(t *testing.T) (*e2eServer, []string) {
+	t.Helper()
+	today := time.Now().In(time.FixedZone("IST", 19800))
+	expiries := []string{
+		today.AddDate(0, 0, 1).Format(time.DateOnly),
+		today.AddDate(0, 0, 2).Format(time.DateOnly),
+		today.AddDate(0, 0, 8).Format(time.DateOnly),
+		today.AddDate(0, 0, 29).Format(time.DateOnly),
+	}
+
+	broker := kitefake.New()
+	testData := map[uint32]*instruments.Instrument{
+		408065: {ID: "NSE:INFY", InstrumentToken: 408065, Tradingsymbol: "INFY", Name: "INFOSYS", Exchange: "NSE", Segment: "NSE", InstrumentType: "EQ", LotSize: 1, Active: true},
+	}
+	token := uint32(9000)
+	add := func(symbol, kind, expiry, expiryType string, strike float64, lotSize int, lastPrice float64) {
+		token++
+		testData[token] = &instruments.Instrument{
+			ID: "NFO:" + symbol, InstrumentToken: token, Tradingsymbol: symbol, Name: "NIFTY", Exchange: "NFO",
+			InstrumentType: kind, ExpiryDate: expiry, ExpiryType: expiryType, Strike: strike, LotSize: lotSize, Active: true,
+		}
+		broker.AddInstrument(kitefake.Instrument{Exchange: "NFO", Tradingsymbol: symbol, InstrumentToken: int(token), LastPrice: lastPrice, Close: lastPrice})
+	}
+	add("NIFTYW022500CE", "CE", expiries[0], "weekly", 22500, 75, 40)
+	add("NIFTYW122500CE", "CE", expiries[2], "weekly", 22500, 75, 120)
+	add("NIFTYM22500CE", "CE", expiries[3], "monthly", 22500, 75, 310)
+	add("NIFTYCURFUT", "FUT", expiries[1], "monthly", 0, 75, 22480)
+	add("NIFTYNXTFUT", "FUT", expiries[3], "monthly", 0, 50, 22600)
+
+	broker.AddPosition(kiteconnect.Position{Exchange: "NFO", Tradingsymbol: "NIFTYW022500CE", Product: "NRML", Quantity: 150, AveragePrice: 35})
+	broker.AddPosition(kiteconnect.Position{Exchange: "NFO", Tradingsymbol: "NIFTYCURFUT", Product: "NRML", Quantity: -75, AveragePrice: 22550})
+	broker.AddPosition(kiteconnect.Position{Exchange: "NFO", Tradingsymbol: "NIFTYW122500CE", Product: "NRML", Quantity: 75, AveragePrice: 100})
+	broker.AddPosition(kiteconnect.Position{Exchange: "NSE", Tradingsymbol: "INFY", Product: "CNC", Quantity: 10, AveragePrice: 1500})
+
+	updateConfig := instruments.DefaultUpdateConfig()
+	updateConfig.EnableScheduler = false
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	im, err := instruments.New(instruments.Config{UpdateConfig: updateConfig, Logger: logger, TestData: testData})
+	require.NoError(t, err)
+
+	return startE2EServer(t, kc.Config{
+		Logger:             logger,
+		InstrumentsManager: im,
+		KiteClientFactory: func(apiKey string) kc.KiteClient {
+			return broker.Client(apiKey)
+		},
+	}), expiries
+}
+
+func rollovers(t *testing.T, e *e2eServer, args map[string]any) RolloverResponse {
+	t.Helper()
+	var resp RolloverResponse
+	require.NoError(t, json.Unmarshal([]byte(e.call(t, "rollover_positions", args)), &resp))
+	return resp
+}
+
+func TestRolloverPositions(t *testing.T) {
+	e, expiries := newRolloverServer(t)
+
+	t.Run("same kind", func(t *testing.T) {
+		resp := rollovers(t, e, map[string]any{})
+		require.Len(t, resp.Rollovers, 2)
+
+		option := resp.Rollovers[0]
+		assert.Equal(t, "NIFTYW022500CE", option.Tradingsymbol)
+		assert.Equal(t, instruments.ExpiryWeekly, option.ExpiryKind)
+		assert.Equal(t, 1, option.DaysToExpiry)
+		assert.Equal(t, "NIFTYW122500CE", option.NextTradingsymbol)
+		assert.Equal(t, expiries[2], option.NextExpiry)
+		assert.Equal(t, []RolloverLeg{
+			{Exchange: "NFO", Tradingsymbol: "NIFTYW022500CE", TransactionType: "SELL", Quantity: 150, Product: "NRML", OrderType: "MARKET", Price: 40},
+			{Exchange: "NFO", Tradingsymbol: "NIFTYW122500CE", TransactionType: "BUY", Quantity: 150, Product: "NRML", OrderType: "MARKET", Price: 120},
+		}, option.Legs)
+		assert.InDelta(t, 80, option.Spread, 1e-9)
+		assert.InDelta(t, 12000, option.RollCost, 1e-9)
+		assert.Equal(t, priceBasisLastPrice, option.PriceBasis)
+
+		// The short future rolls to the next month, which trades in lots of 50
+		future := resp.Rollovers[1]
+		assert.Equal(t, "NIFTYNXTFUT", future.NextTradingsymbol)
+		assert.Equal(t, instruments.ExpiryMonthly, future.NextExpiryKind)
+		assert.Equal(t, []RolloverLeg{
+			{Exchange: "NFO", Tradingsymbol: "NIFTYCURFUT", TransactionType: "BUY", Quantity: 75, Product: "NRML", OrderType: "MARKET", Price: 22480},
+			{Exchange: "NFO", Tradingsymbol: "NIFTYNXTFUT", TransactionType: "SELL", Quantity: 50, Product: "NRML", OrderType: "MARKET", Price: 22600},
+		}, future.Legs)
+		assert.InDelta(t, -120, future.RollCostPerUnit, 1e-9)
+		// Buying back 75 and selling 50
+		assert.InDelta(t, 22480*75-22600*50, future.RollCost, 1e-9)
+		assert.Contains(t, future.Note, "lot size changes to 50")
+
+		assert.InDelta(t, 12000+22480*75-22600*50, resp.TotalRollCost, 1e-9)
+	})
+
+	t.Run("monthly", func(t *testing.T) {
+		resp := rollovers(t, e, map[string]any{"roll_to": "monthly", "tradingsymbol": "NIFTYW022500CE"})
+		require.Len(t, resp.Rollovers, 1)
+		assert.Equal(t, "NIFTYM22500CE", resp.Rollovers[0].NextTradingsymbol)
+		assert.InDelta(t, 270*150, resp.TotalRollCost, 1e-9)
+	})
+
+	t.Run("window", func(t *testing.T) {
+		assert.Empty(t, rollovers(t, e, map[string]any{"within_days": 0}).Rollovers)
+		assert.Len(t, rollovers(t, e, map[string]any{"within_days": 1}).Rollovers, 1)
+		assert.Len(t, rollovers(t, e, map[string]any{"within_days": 10}).Rollovers, 3)
+	})
+
+	t.Run("no later expiry", func(t *testing.T) {
+		resp := rollovers(t, e, map[string]any{"within_days": 30, "tradingsymbol": "NIFTYNXTFUT"})
+		assert.Empty(t, resp.Rollovers) // no position in it
+		resp = rollovers(t, e, map[string]any{"within_days": 10, "roll_to": "next", "tradingsymbol": "NIFTYW122500CE"})
+		require.Len(t, resp.Rollovers, 1)
+		assert.Equal(t, "NIFTYM22500CE", resp.Rollovers[0].NextTradingsymbol)
+	})
+
+	t.Run("smaller than the next lot", func(t *testing.T) {
+		positions := []kiteconnect.Position{{Exchange: "NFO", Tradingsymbol: "NIFTYCURFUT", Product: "NRML", Quantity: -25}}
+		found := findRollovers(e.manager.Instruments, positions, time.Now(), 3, rollToSame)
+		require.Len(t, found, 1)
+		assert.Equal(t, []RolloverLeg{
+			{Exchange: "NFO", Tradingsymbol: "NIFTYCURFUT", TransactionType: "BUY", Quantity: 25, Product: "NRML", OrderType: "MARKET"},
+		}, found[0].Legs)
+		assert.Contains(t, found[0].Note, "nothing is opened in NIFTYNXTFUT")
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		result := e.callResult(t, "rollover_positions", map[string]any{"roll_to": "quarterly"})
+		assert.True(t, result.IsError)
+		assert.Contains(t, resultText(result), "roll_to must be")
+	})
+}
+
+func TestPriceRolloversAtTheTouch(t *testing.T) {
+	quotes := kiteconnect.Quote{}
+	quote := func(id string, last, bid, ask float64) {
+		q := quotes[id]
+		q.LastPrice = last
+		q.Depth.Buy[0].Price = bid
+		q.Depth.Sell[0].Price = ask
+		quotes[id] = q
+	}
+	quote("NFO:CUR", 100, 99, 101)
+	quote("NFO:NEXT", 150, 148, 152)
+
+	short := []Rollover{{Legs: []RolloverLeg{
+		{Exchange: "NFO", Tradingsymbol: "CUR", TransactionType: "BUY", Quantity: 50},
+		{Exchange: "NFO", Tradingsymbol: "NEXT", TransactionType: "SELL", Quantity: 50},
+	}}}
+	total := priceRollovers(short, quotes)
+	assert.Equal(t, priceBasisDepth, short[0].PriceBasis)
+	assert.Equal(t, 101.0, short[0].Legs[0].Price)
+	assert.Equal(t, 148.0, short[0].Legs[1].Price)
+	assert.InDelta(t, -47, short[0].RollCostPerUnit, 1e-9)
+	assert.InDelta(t, -47*50, total, 1e-9)
+	assert.InDelta(t, 50, short[0].Spread, 1e-9)
+
+	missing := []Rollover{{Legs: []RolloverLeg{
+		{Exchange: "NFO", Tradingsymbol: "CUR", TransactionType: "SELL", Quantity: 50},
+		{Exchange: "NFO", Tradingsymbol: "GONE", TransactionType: "BUY", Quantity: 50},
+	}}}
+	assert.Zero(t, priceRollovers(missing, quotes))
+	assert.Contains(t, missing[0].Note, "no live quote")
+}
@@ -0,0 +1,299 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
+	"github.com/zerodha/kite-mcp-server/kc/scheduler"
+)
+
+// scheduleTimeLayouts are the accepted formats of IST times.
+var scheduleTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// parseISTTime parses a wall clock time in IST.
+func parseISTTime(parameter, value string) (time.Time, error) {
+	ist, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		ist = time.FixedZone("IST", 5*3600+1800)
+	}
+	for _, layout := range scheduleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, ist); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ValidationError{Parameter: parameter, Message: "use the format YYYY-MM-DD HH:MM in IST"}
+}
+
+// scheduleTrigger builds the trigger of a schedule_order request. Candle
+// and indicator triggers resolve the watched instrument's token from the
+// instruments dump.
+func scheduleTrigger(manager *kc.Manager, args map[string]interface{}, order scheduler.Order) (scheduler.Trigger, error) {
+	trigger := scheduler.Trigger{
+		Type:       scheduler.TriggerType(SafeAssertString(args["trigger_type"], "")),
+		Instrument: SafeAssertString(args["watch_instrument"], order.Exchange+":"+order.Tradingsymbol),
+		Operator:   SafeAssertString(args["operator"], ""),
+		Value:      SafeAssertFloat64(args["value"], 0),
+		Interval:   SafeAssertString(args["interval"], ""),
+		Indicator:  SafeAssertString(args["indicator"], ""),
+		Period:     SafeAssertInt(args["period"], 14),
+	}
+
+	switch trigger.Type {
+	case scheduler.TriggerTime:
+		if err := ValidateRequired(args, "at"); err != nil {
+			return trigger, err
+		}
+		at, err := parseISTTime("at", SafeAssertString(args["at"], ""))
+		if err != nil {
+			return trigger, err
+		}
+		return scheduler.Trigger{Type: scheduler.TriggerTime, At: at}, nil
+	case scheduler.TriggerLTP:
+		trigger.Interval, trigger.Indicator, trigger.Period = "", "", 0
+	case scheduler.TriggerCandleClose:
+		trigger.Indicator, trigger.Period = "", 0
+	}
+	if err := ValidateRequired(args, "operator", "value"); err != nil {
+		return trigger, err
+	}
+
+	if trigger.Type == scheduler.TriggerCandleClose || trigger.Type == scheduler.TriggerIndicator {
+		inst, err := manager.Instruments.GetByID(trigger.Instrument)
+		if err != nil {
+			return trigger, ValidationError{Parameter: "watch_instrument", Message: fmt.Sprintf("instrument %s not found", trigger.Instrument)}
+		}
+		trigger.InstrumentToken = inst.InstrumentToken
+	}
+	return trigger, nil
+}
+
+// NormalizedScheduledOrder is a scheduled order with the adjustments made
+// to the request before it was queued.
+type NormalizedScheduledOrder struct {
+	scheduler.Job
+	Adjustments []orders.Adjustment `json:"adjustments,omitempty"`
+}
+
+type ScheduleOrderTool struct{}
+
+func (*ScheduleOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("schedule_order",
+		mcp.WithDescription("Hold an order on the server and place it later: at a time (e.g. buy at 9:20 tomorrow), when the LTP crosses a level, when a completed candle closes beyond a level (e.g. sell if NIFTY closes below 22000), or when an SMA, EMA or RSI on completed candles crosses a level. Orders triggered while the market is closed are placed at the next open. Times are in IST. Scheduled orders are placed only while you have an active login."),
+		mcp.WithString("variety",
+			mcp.Description("Order variety. Default: regular. Use amo to place time-triggered orders outside market hours"),
+			mcp.Enum("regular", "amo"),
+		),
+		mcp.WithString("exchange",
+			mcp.Description("The exchange to which the order should be placed"),
+			mcp.Required(),
+			mcp.DefaultString("NSE"),
+			mcp.Enum("NSE", "BSE", "MCX", "NFO", "BFO"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Trading symbol"),
+			mcp.Required(),
+		),
+		mcp.WithString("transaction_type",
+			mcp.Description("Transaction type"),
+			mcp.Required(),
+			mcp.Enum("BUY", "SELL"),
+		),
+		mcp.WithNumber("quantity",
+			mcp.Description("Quantity"),
+			mcp.Required(),
+			mcp.Min(1),
+		),
+		mcp.WithString("product",
+			mcp.Description("Product type"),
+			mcp.Required(),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
+		mcp.WithString("order_type",
+			mcp.Description("Order type. Default: MARKET"),
+			mcp.Enum("MARKET", "LIMIT", "SL", "SL-M"),
+		),
+		mcp.WithNumber("price",
+			mcp.Description("Price (required for LIMIT and SL orders)"),
+		),
+		mcp.WithNumber("trigger_price",
+			mcp.Description("Trigger price (required for SL and SL-M orders)"),
+		),
+		mcp.WithString("trigger_type",
+			mcp.Description("What releases the order"),
+			mcp.Required(),
+			mcp.Enum(string(scheduler.TriggerTime), string(scheduler.TriggerLTP), string(scheduler.TriggerCandleClose), string(scheduler.TriggerIndicator)),
+		),
+		mcp.WithString("at",
+			mcp.Description("Time to place the order for the time trigger, as YYYY-MM-DD HH:MM in IST"),
+		),
+		mcp.WithString("watch_instrument",
+			mcp.Description("Instrument watched by condition triggers as EXCHANGE:TRADINGSYMBOL, e.g. 'NSE:NIFTY 50'. Default: the order's instrument"),
+		),
+		mcp.WithString("operator",
+			mcp.Description("Whether the watched value must go above or below the level"),
+			mcp.Enum(scheduler.Above, scheduler.Below),
+		),
+		mcp.WithNumber("value",
+			mcp.Description("Level of the price, close or indicator that triggers the order"),
+		),
+		mcp.WithString("interval",
+			mcp.Description("Candle interval for candle_close and indicator triggers"),
+			mcp.Enum("minute", "3minute", "5minute", "10minute", "15minute", "30minute", "60minute", "day"),
+		),
+		mcp.WithString("indicator",
+			mcp.Description("Indicator for the indicator trigger"),
+			mcp.Enum(scheduler.IndicatorSMA, scheduler.IndicatorEMA, scheduler.IndicatorRSI),
+		),
+		mcp.WithNumber("period",
+			mcp.Description("Indicator period. Default: 14"),
+			mcp.Min(2),
+			mcp.Max(200),
+		),
+		mcp.WithString("expires_at",
+			mcp.Description("When to give up, as YYYY-MM-DD HH:MM in IST. Default: 5 minutes after the scheduled time for time triggers, 7 days for condition triggers"),
+		),
+		withNormalization(),
+	)
+}
+
+func (*ScheduleOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "schedule_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "exchange", "tradingsymbol", "transaction_type", "quantity", "product", "trigger_type"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		order := scheduler.Order{
+			Variety:         SafeAssertString(args["variety"], "regular"),
+			Exchange:        SafeAssertString(args["exchange"], "NSE"),
+			Tradingsymbol:   SafeAssertString(args["tradingsymbol"], ""),
+			TransactionType: SafeAssertString(args["transaction_type"], ""),
+			Product:         SafeAssertString(args["product"], ""),
+			OrderType:       SafeAssertString(args["order_type"], "MARKET"),
+			Quantity:        SafeAssertInt(args["quantity"], 0),
+			Price:           SafeAssertFloat64(args["price"], 0),
+			TriggerPrice:    SafeAssertFloat64(args["trigger_price"], 0),
+		}
+
+		// Circuit limits change daily, so they are left to the exchange.
+		normalizer, err := newOrderNormalizer(handler.manager, args, order.Exchange, order.Tradingsymbol, false)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if order.Price, err = normalizer.Price("price", order.Price, orders.LimitRounding(order.TransactionType)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if order.TriggerPrice, err = normalizer.Price("trigger_price", order.TriggerPrice, orders.RoundNearest); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if order.Quantity, err = normalizer.Quantity("quantity", order.Quantity); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		trigger, err := scheduleTrigger(handler.manager, args, order)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var expiresAt time.Time
+		if s := SafeAssertString(args["expires_at"], ""); s != "" {
+			if expiresAt, err = parseISTTime("expires_at", s); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		return handler.WithSession(ctx, "schedule_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			if session.UserID == "" {
+				return mcp.NewToolResultError("Complete the Kite login before scheduling orders"), nil
+			}
+
+			job, err := handler.manager.Scheduler.Schedule(session.UserID, order, trigger, expiresAt)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to schedule order: %s", err.Error())), nil
+			}
+
+			return handler.MarshalResponse(NormalizedScheduledOrder{Job: job, Adjustments: normalizer.Adjustments}, "schedule_order")
+		})
+	}
+}
+
+type ListScheduledOrdersTool struct{}
+
+func (*ListScheduledOrdersTool) Tool() mcp.Tool {
+	return mcp.NewTool("list_scheduled_orders",
+		mcp.WithDescription("List orders held by schedule_order with their trigger, status and, once placed, the Kite order ID"),
+		mcp.WithString("status",
+			mcp.Description("Only return orders with this status"),
+			mcp.Enum(string(scheduler.StatusPending), string(scheduler.StatusTriggered), string(scheduler.StatusPlaced), string(scheduler.StatusFailed), string(scheduler.StatusCancelled), string(scheduler.StatusExpired)),
+		),
+	)
+}
+
+func (*ListScheduledOrdersTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "list_scheduled_orders")
+		args := request.GetArguments()
+		status := scheduler.Status(SafeAssertString(args["status"], ""))
+
+		return handler.WithSession(ctx, "list_scheduled_orders", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			jobs := []scheduler.Job{}
+			for _, job := range handler.manager.Scheduler.List(session.UserID) {
+				if status == "" || job.Status == status {
+					jobs = append(jobs, job)
+				}
+			}
+			return handler.MarshalResponse(jobs, "list_scheduled_orders")
+		})
+	}
+}
+
+type CancelScheduledOrderTool struct{}
+
+func (*CancelScheduledOrderTool) Tool() mcp.Tool {
+	return mcp.NewTool("cancel_scheduled_order",
+		mcp.WithDescription("Cancel a scheduled order that has not been placed yet. Orders already placed must be cancelled with cancel_order."),
+		mcp.WithString("id",
+			mcp.Description("ID of the scheduled order"),
+			mcp.Required(),
+		),
+	)
+}
+
+func (*CancelScheduledOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "cancel_scheduled_order")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "id"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		id := SafeAssertString(args["id"], "")
+
+		return handler.WithSession(ctx, "cancel_scheduled_order", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			job, err := handler.manager.Scheduler.Cancel(id, session.UserID)
+			switch {
+			case errors.Is(err, scheduler.ErrNotFound):
+				return mcp.NewToolResultError(err.Error()), nil
+			case errors.Is(err, scheduler.ErrNotActive):
+				return mcp.NewToolResultError(fmt.Sprintf("Scheduled order is already %s", job.Status)), nil
+			case errors.Is(err, scheduler.ErrPlacing):
+				return mcp.NewToolResultError("Scheduled order is being placed and can no longer be cancelled"), nil
+			case err != nil:
+				handler.manager.Logger.Error("Failed to cancel scheduled order", "id", id, "error", err)
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to cancel scheduled order: %s", err.Error())), nil
+			}
+			return handler.MarshalResponse(job, "cancel_scheduled_order")
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/instruments"
+	"github.com/zerodha/kite-mcp-server/kc/scheduler"
+)
+
+func newScheduleTestManager(t *testing.T) *kc.Manager {
+	t.Helper()
+	config := instruments.DefaultUpdateConfig()
+	config.EnableScheduler = false
+	im, err := instruments.New(instruments.Config{
+		UpdateConfig: config,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		TestData: map[uint32]*instruments.Instrument{
+			256265: {ID: "NSE:NIFTY 50", InstrumentToken: 256265, Exchange: "NSE", Tradingsymbol: "NIFTY 50", Segment: "INDICES"},
+		},
+	})
+	require.NoError(t, err)
+	return &kc.Manager{Instruments: im}
+}
+
+func TestParseISTTime(t *testing.T) {
+	got, err := parseISTTime("at", "2024-01-02 09:20")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02T03:50:00Z", got.UTC().Format("2006-01-02T15:04:05Z"))
+
+	_, err = parseISTTime("at", "2024-01-02T09:20:00Z")
+	assert.Error(t, err)
+}
+
+func TestScheduleTrigger(t *testing.T) {
+	manager := newScheduleTestManager(t)
+	order := scheduler.Order{Exchange: "NSE", Tradingsymbol: "INFY"}
+
+	t.Run("time", func(t *testing.T) {
+		trigger, err := scheduleTrigger(manager, map[string]interface{}{"trigger_type": "time", "at": "2024-01-02 09:20", "operator": "above"}, order)
+		require.NoError(t, err)
+		assert.Equal(t, scheduler.TriggerTime, trigger.Type)
+		assert.Empty(t, trigger.Operator)
+		assert.False(t, trigger.At.IsZero())
+	})
+
+	t.Run("ltp defaults to the order instrument", func(t *testing.T) {
+		trigger, err := scheduleTrigger(manager, map[string]interface{}{"trigger_type": "ltp", "operator": "below", "value": 1500.0}, order)
+		require.NoError(t, err)
+		assert.Equal(t, "NSE:INFY", trigger.Instrument)
+		assert.Zero(t, trigger.Period)
+	})
+
+	t.Run("candle close resolves the token", func(t *testing.T) {
+		trigger, err := scheduleTrigger(manager, map[string]interface{}{
+			"trigger_type":     "candle_close",
+			"watch_instrument": "NSE:NIFTY 50",
+			"operator":         "below",
+			"value":            22000.0,
+			"interval":         "day",
+		}, order)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(256265), trigger.InstrumentToken)
+		require.NoError(t, trigger.Validate())
+	})
+
+	t.Run("unknown instrument", func(t *testing.T) {
+		_, err := scheduleTrigger(manager, map[string]interface{}{"trigger_type": "indicator", "operator": "below", "value": 30.0, "interval": "day", "indicator": "rsi"}, order)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing level", func(t *testing.T) {
+		_, err := scheduleTrigger(manager, map[string]interface{}{"trigger_type": "ltp", "operator": "below"}, order)
+		assert.Error(t, err)
+	})
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/history"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
 )
 
 // AnalyzeTradeOpportunityTool performs comprehensive 50+ factor analysis
@@ -80,19 +82,28 @@ func (*AnalyzeTradeOpportunityTool) Handler(manager *kc.Manager) server.ToolHand
 				return mcp.NewToolResultError(fmt.Sprintf("No data available for %s", instrument)), nil
 			}
 
-			// Get historical data for technical analysis
+			// Get historical data for technical analysis, adjusted for splits
+			// and bonuses, and stitched across expiries for futures
 			to := time.Now()
 			from := to.AddDate(0, -6, 0) // 6 months of data
-			
-			historicalData, err := session.Kite.Client.GetHistoricalData(
-				quote.InstrumentToken,
-				"day",
-				from,
-				to,
-				false,
-				false,
-			)
-			
+
+			historyRequest := history.Request{
+				InstrumentToken: uint32(quote.InstrumentToken),
+				Interval:        "day",
+				From:            from,
+				To:              to,
+			}
+			if inst, err := handler.manager.Instruments.GetByTradingsymbol(exchange, symbol); err == nil && inst.InstrumentType == "FUT" {
+				historyRequest.Continuous = true
+			}
+			series, err := handler.manager.History.Candles(session.Kite.Client, historyRequest)
+			if err != nil && historyRequest.Continuous {
+				handler.manager.Logger.Warn("Failed to get continuous series, using the contract's own", "error", err)
+				historyRequest.Continuous = false
+				series, err = handler.manager.History.Candles(session.Kite.Client, historyRequest)
+			}
+			historicalData := series.Candles
+
 			if err != nil {
 				handler.manager.Logger.Warn("Failed to get historical data", "error", err)
 				// Continue with limited analysis
@@ -283,6 +294,17 @@ func (*CalculatePovertyEscapePositionTool) Tool() mcp.Tool {
 			mcp.Description("Enable aggressive position sizing for faster wealth building"),
 			mcp.DefaultString("true"),
 		),
+		mcp.WithString("exchange",
+			mcp.Description("Exchange of the instrument. With tradingsymbol, checks the recommended size against available margin and estimates charges"),
+			mcp.Enum("NSE", "BSE", "MCX", "NFO", "BFO", "CDS", "BCD"),
+		),
+		mcp.WithString("tradingsymbol",
+			mcp.Description("Trading symbol of the instrument for the margin check"),
+		),
+		mcp.WithString("product",
+			mcp.Description("Product type for the margin check. Defaults to MIS for scalping/intraday and CNC otherwise"),
+			mcp.Enum("CNC", "NRML", "MIS", "MTF"),
+		),
 	)
 }
 
@@ -305,8 +327,35 @@ func (*CalculatePovertyEscapePositionTool) Handler(manager *kc.Manager) server.T
 
 		// Calculate position size
 		positionData := calculateOptimalPosition(capital, entryPrice, stopLoss, strategy, confidence, povertyEscapeMode)
-		
-		return handler.MarshalResponse(positionData, "calculate_poverty_escape_position")
+
+		exchange := SafeAssertString(args["exchange"], "")
+		tradingsymbol := SafeAssertString(args["tradingsymbol"], "")
+		if exchange == "" || tradingsymbol == "" {
+			return handler.MarshalResponse(positionData, "calculate_poverty_escape_position")
+		}
+
+		product := SafeAssertString(args["product"], kiteconnect.ProductCNC)
+		if args["product"] == nil && (strategy == "scalping" || strategy == "intraday") {
+			product = kiteconnect.ProductMIS
+		}
+		side := kiteconnect.TransactionTypeBuy
+		if stopLoss > entryPrice {
+			side = kiteconnect.TransactionTypeSell
+		}
+
+		return handler.WithSession(ctx, "calculate_poverty_escape_position", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			order := kiteconnect.OrderMarginParam{
+				Exchange:        exchange,
+				Tradingsymbol:   tradingsymbol,
+				TransactionType: side,
+				Variety:         kiteconnect.VarietyRegular,
+				Product:         product,
+				OrderType:       kiteconnect.OrderTypeLimit,
+				Price:           entryPrice,
+			}
+			applyMarginCheck(session.Kite.Client, positionData, order, entryPrice)
+			return handler.MarshalResponse(positionData, "calculate_poverty_escape_position")
+		})
 	}
 }
 
@@ -362,6 +411,7 @@ func (*PlaceSmartGTTOrderTool) Tool() mcp.Tool {
 			mcp.Description("Enable trailing stop-loss"),
 			mcp.DefaultString("true"),
 		),
+		withNormalization(),
 	)
 }
 
@@ -412,10 +462,18 @@ func (*PlaceSmartGTTOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFu
 				targetPrice = entryPrice * (1 - targetPercent/100)
 			}
 
-			// Round prices to tick size
-			stopLossPrice = roundToTick(stopLossPrice)
-			targetPrice = roundToTick(targetPrice)
-			entryPrice = roundToTick(entryPrice)
+			// Round prices to the instrument's tick size
+			normalizer, err := newOrderNormalizer(handler.manager, args, exchange, symbol, false)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			tick := normalizer.Spec.TickSize
+			if tick == 0 {
+				tick = defaultTickSize
+			}
+			stopLossPrice = orders.RoundToTick(stopLossPrice, tick, orders.RoundNearest)
+			targetPrice = orders.RoundToTick(targetPrice, tick, orders.RoundNearest)
+			entryPrice = orders.RoundToTick(entryPrice, tick, orders.RoundNearest)
 
 			// Create two-leg GTT order (OCO - One Cancels Other)
 			// Upper trigger for profit target, lower trigger for stop-loss
@@ -461,6 +519,14 @@ func (*PlaceSmartGTTOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFu
 				}
 			}
 
+			// Align the limit prices and quantity of both legs
+			if err := normalizeGTTParams(normalizer, &gttParams); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if err := validateGTTParams(gttParams); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+
 			// Place the GTT order
 			resp, err := session.Kite.Client.PlaceGTT(gttParams)
 			if err != nil {
@@ -484,6 +550,7 @@ func (*PlaceSmartGTTOrderTool) Handler(manager *kc.Manager) server.ToolHandlerFu
 				"strategy":        strategyType,
 				"trailing_stop":   trailingStop,
 				"message":         fmt.Sprintf("Smart GTT order placed successfully. Risk-Reward: 1:%.1f", targetPercent/stopLossPercent),
+				"adjustments":     normalizer.Adjustments,
 			}
 
 			return handler.MarshalResponse(result, "place_smart_gtt_order")
@@ -1011,7 +1078,7 @@ func calculateOptimalPosition(capital, entryPrice, stopLoss float64, strategy st
 func generatePositionRecommendation(positionSize int, riskPercent float64, povertyEscapeMode bool) string {
 	if povertyEscapeMode {
 		if riskPercent >= 4 {
-			return fmt.Sprintf("AGGRESSIVE POSITION: %d shares. This is a high-conviction trade with %% .1f%% capital at risk. Only proceed if analysis strongly supports entry.", positionSize, riskPercent)
+			return fmt.Sprintf("AGGRESSIVE POSITION: %d shares. This is a high-conviction trade with %.1f%% capital at risk. Only proceed if analysis strongly supports entry.", positionSize, riskPercent)
 		} else if riskPercent >= 2 {
 			return fmt.Sprintf("MODERATE POSITION: %d shares. Balanced risk-reward suitable for wealth building with %.1f%% capital at risk.", positionSize, riskPercent)
 		}
@@ -1020,19 +1087,4 @@ func generatePositionRecommendation(positionSize int, riskPercent float64, pover
 	return fmt.Sprintf("CONSERVATIVE POSITION: %d shares. Low risk approach with %.1f%% capital at risk.", positionSize, riskPercent)
 }
 
-func roundToTick(price float64) float64 {
-	// Indian market tick sizes
-	if price < 1 {
-		return math.Round(price*100) / 100 // 0.01
-	} else if price < 10 {
-		return math.Round(price*20) / 20 // 0.05
-	} else if price < 100 {
-		return math.Round(price*10) / 10 // 0.10
-	} else if price < 1000 {
-		return math.Round(price*4) / 4 // 0.25
-	} else {
-		return math.Round(price*2) / 2 // 0.50
-	}
-}
-
 // SafeAssertBool safely converts interface{} to bool
@@ -0,0 +1,379 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/tradebook"
+)
+
+// sessionUserID returns the Kite user ID for the session, looking it up from
+// the profile for sessions that logged in before the ID was recorded. The
+// session is shared by concurrent tool calls, so the ID looked up isn't
+// stored in it.
+func sessionUserID(session *kc.KiteSessionData) (string, error) {
+	if session.UserID != "" {
+		return session.UserID, nil
+	}
+
+	profile, err := session.Kite.Client.GetUserProfile()
+	if err != nil {
+		return "", err
+	}
+	return profile.UserID, nil
+}
+
+type ImportTradebookTool struct{}
+
+func (*ImportTradebookTool) Tool() mcp.Tool {
+	return mcp.NewTool("import_tradebook",
+		mcp.WithDescription("Import a Zerodha Console tradebook or P&L statement CSV export. Tradebook imports are de-duplicated by trade ID, so overlapping exports can be imported repeatedly. Imported trades are matched into FIFO tax lots used by get_realized_pnl."),
+		mcp.WithString("csv_content",
+			mcp.Description("Raw contents of the CSV file exported from Console"),
+			mcp.Required(),
+		),
+		mcp.WithString("format",
+			mcp.Description("CSV format. 'auto' detects the format from the header row"),
+			mcp.DefaultString("auto"),
+			mcp.Enum("auto", "tradebook", "pnl"),
+		),
+		mcp.WithString("financial_year",
+			mcp.Description("Financial year the P&L statement covers (e.g. 2024-25). Required for P&L statements"),
+		),
+	)
+}
+
+func (*ImportTradebookTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "import_tradebook")
+		args := request.GetArguments()
+
+		if err := ValidateRequired(args, "csv_content"); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		format := tradebook.Format(SafeAssertString(args["format"], string(tradebook.FormatAuto)))
+		financialYear := SafeAssertString(args["financial_year"], "")
+		if financialYear != "" {
+			if _, _, err := tradebook.ParseFinancialYear(financialYear); err != nil {
+				return mcp.NewToolResultError("financial_year must be in YYYY-YY format, e.g. 2024-25"), nil
+			}
+		}
+
+		batch, err := tradebook.Parse(strings.NewReader(SafeAssertString(args["csv_content"], "")), format, financialYear)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to parse CSV: %s", err)), nil
+		}
+
+		return handler.WithSession(ctx, "import_tradebook", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			userID, err := sessionUserID(session)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to resolve user ID", "error", err)
+				return mcp.NewToolResultError("Failed to resolve user for import"), nil
+			}
+
+			var result tradebook.ImportResult
+			if batch.Format == tradebook.FormatPnL {
+				result, err = handler.manager.Tradebook.ImportPnL(userID, batch.PnL)
+			} else {
+				result, err = handler.manager.Tradebook.ImportTrades(userID, batch.Trades)
+			}
+			if err != nil {
+				handler.manager.Logger.Error("Failed to import tradebook", "error", err)
+				return mcp.NewToolResultError("Failed to store imported data"), nil
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"format":     batch.Format,
+				"imported":   result.Imported,
+				"duplicates": result.Duplicates,
+				"total":      result.Total,
+			}, "import_tradebook")
+		})
+	}
+}
+
+type RealizedPnLTool struct{}
+
+func (*RealizedPnLTool) Tool() mcp.Tool {
+	return mcp.NewTool("get_realized_pnl",
+		mcp.WithDescription("Get realized P&L from imported tradebooks, matched into FIFO tax lots and classified as intraday (speculative business income), STCG, LTCG or F&O (non-speculative business income). Includes a per-category summary and an estimated equity capital gains tax. Import data first with import_tradebook. Supports pagination of the realized lots."),
+		mcp.WithString("financial_year",
+			mcp.Description("Financial year to report on (e.g. 2024-25). Cannot be combined with from_date/to_date"),
+		),
+		mcp.WithString("from_date",
+			mcp.Description("Include gains closed on or after this date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("to_date",
+			mcp.Description("Include gains closed on or before this date (YYYY-MM-DD)"),
+		),
+		mcp.WithString("category",
+			mcp.Description("Only include gains of this tax category"),
+			mcp.Enum("intraday", "stcg", "ltcg", "fno"),
+		),
+		mcp.WithString("symbol",
+			mcp.Description("Only include gains for this trading symbol or ISIN"),
+		),
+		mcp.WithNumber("from",
+			mcp.Description("Starting index for pagination (0-based). Default: 0"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of realized lots to return. If not specified, returns all lots."),
+		),
+	)
+}
+
+// RealizedPnLResponse is the response of get_realized_pnl.
+type RealizedPnLResponse struct {
+	FinancialYear string               `json:"financial_year,omitempty"`
+	Summary       tradebook.Summary    `json:"summary"`
+	Realized      interface{}          `json:"realized"`
+	Statement     []tradebook.PnLEntry `json:"pnl_statement,omitempty"`
+	OpenLots      int                  `json:"open_lots"`
+}
+
+func (*RealizedPnLTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "get_realized_pnl")
+		args := request.GetArguments()
+
+		filter, financialYear, err := parseRealizedFilter(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return handler.WithSession(ctx, "get_realized_pnl", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			userID, err := sessionUserID(session)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to resolve user ID", "error", err)
+				return mcp.NewToolResultError("Failed to resolve user for tradebook lookup"), nil
+			}
+
+			ledger, err := handler.manager.Tradebook.Ledger(userID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to load tradebook", "error", err)
+				return mcp.NewToolResultError("Failed to load imported tradebook"), nil
+			}
+			entries, err := handler.manager.Tradebook.PnLEntries(userID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to load P&L statement", "error", err)
+				return mcp.NewToolResultError("Failed to load imported P&L statement"), nil
+			}
+
+			realized := tradebook.FilterRealized(ledger.Realized, filter)
+
+			// Rates depend on the transfer date, so use the end of the period.
+			asOf := time.Now()
+			if !filter.To.IsZero() && filter.To.Before(asOf) {
+				asOf = filter.To.Add(-time.Second)
+			}
+
+			response := RealizedPnLResponse{
+				FinancialYear: financialYear,
+				Summary:       tradebook.Summarize(realized, asOf),
+				OpenLots:      len(ledger.Open),
+			}
+			for _, e := range entries {
+				if financialYear == "" || e.FinancialYear == financialYear {
+					response.Statement = append(response.Statement, e)
+				}
+			}
+
+			params := ParsePaginationParams(args)
+			paginated := ApplyPagination(realized, params)
+			if params.Limit > 0 {
+				response.Realized = CreatePaginatedResponse(realized, paginated, params, len(realized))
+			} else {
+				response.Realized = paginated
+			}
+
+			return handler.MarshalResponse(response, "get_realized_pnl")
+		})
+	}
+}
+
+// parseRealizedFilter builds the realized gain filter from tool arguments.
+func parseRealizedFilter(args map[string]interface{}) (tradebook.RealizedFilter, string, error) {
+	var filter tradebook.RealizedFilter
+
+	financialYear := SafeAssertString(args["financial_year"], "")
+	fromDate := SafeAssertString(args["from_date"], "")
+	toDate := SafeAssertString(args["to_date"], "")
+
+	if financialYear != "" {
+		if fromDate != "" || toDate != "" {
+			return filter, "", fmt.Errorf("financial_year cannot be combined with from_date/to_date")
+		}
+		from, to, err := tradebook.ParseFinancialYear(financialYear)
+		if err != nil {
+			return filter, "", fmt.Errorf("financial_year must be in YYYY-YY format, e.g. 2024-25")
+		}
+		filter.From, filter.To = from, to
+	}
+
+	if fromDate != "" {
+		from, err := time.ParseInLocation("2006-01-02", fromDate, calendar.IST)
+		if err != nil {
+			return filter, "", fmt.Errorf("from_date must be in YYYY-MM-DD format")
+		}
+		filter.From = from
+	}
+	if toDate != "" {
+		to, err := time.ParseInLocation("2006-01-02", toDate, calendar.IST)
+		if err != nil {
+			return filter, "", fmt.Errorf("to_date must be in YYYY-MM-DD format")
+		}
+		// to_date is inclusive
+		filter.To = to.AddDate(0, 0, 1)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, "", fmt.Errorf("from_date must not be after to_date")
+	}
+
+	if category := SafeAssertString(args["category"], ""); category != "" {
+		filter.Categories = []tradebook.Category{tradebook.Category(category)}
+	}
+	filter.Symbol = strings.ToUpper(SafeAssertString(args["symbol"], ""))
+
+	return filter, financialYear, nil
+}
+
+type TaxLossHarvestTool struct{}
+
+func (*TaxLossHarvestTool) Tool() mcp.Tool {
+	return mcp.NewTool("tax_loss_harvest",
+		mcp.WithDescription("Suggest holdings to book losses on before the financial year ends. Combines current holdings, live LTP and tax lots from imported tradebooks to list lots trading below cost, split into short-term and long-term, with the estimated tax saved against equity gains realized so far this financial year. Lots are flagged for wash-trade style re-entry risks. Import tradebooks first with import_tradebook for accurate lot dates."),
+		mcp.WithNumber("min_loss",
+			mcp.Description("Ignore lots with an unrealized loss below this amount. Default: 0"),
+			mcp.Min(0),
+		),
+		mcp.WithNumber("wash_window_days",
+			mcp.Description("Window in days used to flag recent purchases, repeat loss booking and lots about to turn long-term. Default: 30"),
+			mcp.DefaultNumber(30),
+			mcp.Min(0),
+		),
+		mcp.WithString("term",
+			mcp.Description("Only list candidates of this holding term"),
+			mcp.DefaultString("all"),
+			mcp.Enum("all", "short_term", "long_term"),
+		),
+	)
+}
+
+// harvestNotes explains the caveats that apply to every harvesting plan.
+var harvestNotes = []string{
+	"Selling and buying back on the same day is netted as intraday (speculative) and does not book a capital loss; re-enter on a later day if at all.",
+	"Repeatedly booking losses and immediately buying back may be treated as a sham transaction; consider a similar but different security for re-entry.",
+	"Short-term losses can be set off against both short and long-term gains; long-term losses only against long-term gains. Unused losses can be carried forward for 8 years if the return is filed on time.",
+	"Tax estimates exclude surcharge, cess and business income, and lots without imported buy trades are reported under unknown_term.",
+}
+
+func (*TaxLossHarvestTool) Handler(manager *kc.Manager) server.ToolHandlerFunc {
+	handler := NewToolHandler(manager)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handler.trackToolCall(ctx, "tax_loss_harvest")
+		args := request.GetArguments()
+
+		minLoss := SafeAssertFloat64(args["min_loss"], 0)
+		washDays := SafeAssertInt(args["wash_window_days"], 30)
+		term := SafeAssertString(args["term"], "all")
+		if minLoss < 0 || washDays < 0 {
+			return mcp.NewToolResultError("min_loss and wash_window_days must not be negative"), nil
+		}
+
+		return handler.WithSession(ctx, "tax_loss_harvest", func(session *kc.KiteSessionData) (*mcp.CallToolResult, error) {
+			userID, err := sessionUserID(session)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to resolve user ID", "error", err)
+				return mcp.NewToolResultError("Failed to resolve user for tradebook lookup"), nil
+			}
+
+			trades, err := handler.manager.Tradebook.Trades(userID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to load tradebook", "error", err)
+				return mcp.NewToolResultError("Failed to load imported tradebook"), nil
+			}
+			ledger, err := handler.manager.Tradebook.Ledger(userID)
+			if err != nil {
+				handler.manager.Logger.Error("Failed to build tradebook ledger", "error", err)
+				return mcp.NewToolResultError("Failed to load imported tradebook"), nil
+			}
+
+			holdings, err := session.Kite.Client.GetHoldings()
+			if err != nil {
+				handler.manager.Logger.Error("Failed to get holdings", "error", err)
+				return mcp.NewToolResultError("Failed to get holdings"), nil
+			}
+
+			plan := tradebook.PlanHarvest(ledger, harvestSnapshots(handler.manager, session, holdings), tradebook.HarvestOptions{
+				AsOf:       time.Now(),
+				WashWindow: time.Duration(washDays) * 24 * time.Hour,
+				MinLoss:    minLoss,
+			})
+
+			switch term {
+			case tradebook.TermShort:
+				plan.LongTerm, plan.UnknownTerm = nil, nil
+			case tradebook.TermLong:
+				plan.ShortTerm, plan.UnknownTerm = nil, nil
+			}
+
+			return handler.MarshalResponse(map[string]interface{}{
+				"plan":             plan,
+				"tradebook_trades": len(trades),
+				"notes":            harvestNotes,
+			}, "tax_loss_harvest")
+		})
+	}
+}
+
+// harvestSnapshots converts holdings into harvest inputs, refreshing prices
+// with a live LTP call and falling back to the holdings' last price.
+func harvestSnapshots(manager *kc.Manager, session *kc.KiteSessionData, holdings kiteconnect.Holdings) []tradebook.HoldingSnapshot {
+	ids := make([]string, 0, len(holdings))
+	for _, h := range holdings {
+		ids = append(ids, h.Exchange+":"+h.Tradingsymbol)
+	}
+
+	var ltp kiteconnect.QuoteLTP
+	if len(ids) > 0 {
+		var err error
+		if ltp, err = session.Kite.Client.GetLTP(ids...); err != nil {
+			manager.Logger.Warn("Failed to get LTP for holdings, using last price from holdings", "error", err)
+		}
+	}
+
+	out := make([]tradebook.HoldingSnapshot, 0, len(holdings))
+	for _, h := range holdings {
+		isin := h.ISIN
+		if isin == "" {
+			if inst, err := manager.Instruments.GetByTradingsymbol(h.Exchange, h.Tradingsymbol); err == nil {
+				isin = inst.ISIN
+			}
+		}
+
+		lastPrice := h.LastPrice
+		if q, ok := ltp[h.Exchange+":"+h.Tradingsymbol]; ok && q.LastPrice > 0 {
+			lastPrice = q.LastPrice
+		}
+
+		out = append(out, tradebook.HoldingSnapshot{
+			ISIN:         isin,
+			Symbol:       h.Tradingsymbol,
+			Exchange:     h.Exchange,
+			Quantity:     float64(h.Quantity + h.T1Quantity),
+			AveragePrice: h.AveragePrice,
+			LastPrice:    lastPrice,
+		})
+	}
+	return out
+}
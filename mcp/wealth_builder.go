@@ -11,6 +11,8 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"github.com/zerodha/kite-mcp-server/kc"
+	"github.com/zerodha/kite-mcp-server/kc/calendar"
+	"github.com/zerodha/kite-mcp-server/kc/orders"
 )
 
 // DetectMomentumStocksTool finds stocks ready to explode
@@ -436,11 +438,20 @@ func (*SetEmergencyExitTool) Handler(manager *kc.Manager) server.ToolHandlerFunc
 					Tag:             "EMERGENCY_EXIT",
 				}
 
-				resp, err := session.Kite.Client.PlaceOrder("regular", orderParams)
+				// Exits always adjust: a rejected exit is worse than a rounded price.
+				normalizer, _ := newOrderNormalizer(handler.manager, nil, exitOrder.Exchange, exitOrder.Symbol, true)
+				if err := normalizeOrderParams(normalizer, &orderParams); err != nil {
+					failedOrders = append(failedOrders, fmt.Sprintf("%s: %v", exitOrder.Symbol, err))
+					continue
+				}
+
+				limits := instrumentLimits(handler.manager, exitOrder.Exchange, exitOrder.Symbol)
+				group, err := placeSlicedOrder(ctx, handler.manager, session.Kite.Client, kiteconnect.VarietyRegular, orderParams, limits, orders.DefaultSliceInterval)
+				for _, orderID := range group.OrderIDs {
+					placedOrders = append(placedOrders, fmt.Sprintf("%s: %s", exitOrder.Symbol, orderID))
+				}
 				if err != nil {
 					failedOrders = append(failedOrders, fmt.Sprintf("%s: %v", exitOrder.Symbol, err))
-				} else {
-					placedOrders = append(placedOrders, fmt.Sprintf("%s: %s", exitOrder.Symbol, resp.OrderID))
 				}
 			}
 
@@ -516,7 +527,8 @@ func (*GetDailyGameplanTool) Handler(manager *kc.Manager) server.ToolHandlerFunc
 			}
 
 			// Generate gameplan
-			gameplan := generateDailyGameplan(capital, riskAppetite, marketView, indexData)
+			market := handler.manager.Calendar.Status("NSE", time.Now())
+			gameplan := generateDailyGameplan(capital, riskAppetite, marketView, indexData, market)
 
 			return handler.MarshalResponse(gameplan, "get_daily_gameplan")
 		})
@@ -1066,15 +1078,26 @@ func createEmergencyExitOrder(position kiteconnect.Position, reason string, mark
 	return exit
 }
 
-func generateDailyGameplan(capital float64, riskAppetite, marketView string, indexData map[string]interface{}) map[string]interface{} {
+func generateDailyGameplan(capital float64, riskAppetite, marketView string, indexData map[string]interface{}, market calendar.Status) map[string]interface{} {
 	gameplan := map[string]interface{}{
-		"date":          time.Now().Format("2006-01-02"),
+		"date":          market.Time.Format("2006-01-02"),
 		"market_data":   indexData,
+		"market_status": market,
 		"capital":       fmt.Sprintf("₹%.2f", capital),
 		"risk_appetite": riskAppetite,
 		"market_view":   marketView,
 	}
 
+	// Plan for the next session when the market does not trade today
+	if market.Session == nil && !market.NextOpen.IsZero() {
+		gameplan["date"] = market.NextOpen.Format("2006-01-02")
+		closedFor := "the weekend"
+		if market.Holiday != "" {
+			closedFor = market.Holiday
+		}
+		gameplan["note"] = fmt.Sprintf("Market is closed today for %s; this plan is for the next session on %s", closedFor, market.NextOpen.Format("Mon 02 Jan"))
+	}
+
 	// Calculate position sizing
 	var maxPositions int
 	var positionSize float64
@@ -1181,5 +1204,15 @@ func generateDailyGameplan(capital float64, riskAppetite, marketView string, ind
 		},
 	}
 
+	// Special sessions such as muhurat trading are short and thin
+	if market.Session != nil && market.Session.Name != calendar.SessionNormal {
+		gameplan["schedule"] = []map[string]interface{}{
+			{
+				"time":   fmt.Sprintf("%s - %s", market.Session.Open.Format("15:04"), market.Session.Close.Format("15:04")),
+				"action": fmt.Sprintf("%s session only: trade light and close intraday positions before it ends", market.Session.Name),
+			},
+		}
+	}
+
 	return gameplan
 }
\ No newline at end of file